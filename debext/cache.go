@@ -0,0 +1,68 @@
+package debext
+
+import (
+	"log/slog"
+	"path/filepath"
+	"sync"
+
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/aptly-dev/aptly/utils"
+)
+
+// ControlStore stores parsed binary package control stanzas keyed by the SHA256 of the package file
+// Implementations must be safe for concurrent use
+type ControlStore interface {
+	// Load returns the stanza for the given SHA256, if present
+	Load(sha256 string) (deb.Stanza, bool)
+	// Store saves the stanza for the given SHA256
+	Store(sha256 string, stanza deb.Stanza) error
+}
+
+// cachedControl returns the control stanza of a .deb file from store, parsing and storing it on a miss
+// The returned stanza is modified by the caller, stores must hand out copies
+func cachedControl(debFile string, checksums utils.ChecksumInfo, store ControlStore) (deb.Stanza, error) {
+	if store == nil || checksums.SHA256 == "" {
+		return deb.GetControlFileFromDeb(debFile)
+	}
+
+	if stanza, ok := store.Load(checksums.SHA256); ok {
+		return stanza, nil
+	}
+
+	stanza, err := deb.GetControlFileFromDeb(debFile)
+	if err != nil {
+		return nil, err
+	}
+
+	// A failing store only costs a re-parse next time
+	if err := store.Store(checksums.SHA256, stanza.Copy()); err != nil {
+		slog.Warn("Failed to cache control file", "file", filepath.Base(debFile), "error", err)
+	}
+
+	return stanza, nil
+}
+
+// MemoryControlStore keeps control stanzas in memory
+type MemoryControlStore struct {
+	stanzas sync.Map // sha256 -> deb.Stanza
+}
+
+// NewMemoryControlStore creates an empty in-memory store
+func NewMemoryControlStore() *MemoryControlStore {
+	return &MemoryControlStore{}
+}
+
+// Load returns a copy of the stanza for the given SHA256
+func (s *MemoryControlStore) Load(sha256 string) (deb.Stanza, bool) {
+	stanza, ok := s.stanzas.Load(sha256)
+	if !ok {
+		return nil, false
+	}
+	return stanza.(deb.Stanza).Copy(), true
+}
+
+// Store saves a copy of the stanza for the given SHA256
+func (s *MemoryControlStore) Store(sha256 string, stanza deb.Stanza) error {
+	s.stanzas.Store(sha256, stanza.Copy())
+	return nil
+}
@@ -0,0 +1,49 @@
+package debext
+
+import (
+	"testing"
+
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/aptly-dev/aptly/utils"
+	"github.com/dionysius/aarg/debext/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryControlStore(t *testing.T) {
+	stanza := deb.Stanza{"Package": "hello", "Version": "1.0-1", "Architecture": "amd64"}
+
+	store := NewMemoryControlStore()
+	_, ok := store.Load("abcdef")
+	assert.False(t, ok)
+
+	require.NoError(t, store.Store("abcdef", stanza))
+	loaded, ok := store.Load("abcdef")
+	require.True(t, ok)
+	assert.Equal(t, stanza, loaded)
+
+	// Returned stanzas are copies
+	loaded["Package"] = "changed"
+	again, ok := store.Load("abcdef")
+	require.True(t, ok)
+	assert.Equal(t, "hello", again["Package"])
+}
+
+func TestParseBinary_ControlStore(t *testing.T) {
+	debFile, err := testutil.WriteDeb(t.TempDir(), testutil.Package{Name: "hello", Version: "1.0-1", Architecture: "amd64", Description: "hello"})
+	require.NoError(t, err)
+
+	store := NewMemoryControlStore()
+	uncached, err := ParseBinary(debFile, "pool", store)
+	require.NoError(t, err)
+
+	checksums, err := utils.ChecksumsForFile(debFile)
+	require.NoError(t, err)
+	_, ok := store.Load(checksums.SHA256)
+	assert.True(t, ok)
+
+	// Second parse is served from the store with identical result
+	cached, err := ParseBinary(debFile, "pool", store)
+	require.NoError(t, err)
+	assert.Equal(t, uncached.Stanza(), cached.Stanza())
+}
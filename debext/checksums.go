@@ -0,0 +1,128 @@
+package debext
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrChecksumMismatch is returned when a file does not match its published checksum
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// ParseChecksums parses SHA256 checksums in sha256sum format ("<hash>  <file>" or "<hash> *<file>")
+// or BSD format ("SHA256 (<file>) = <hash>") into a map of file name to lowercase hex hash
+// Lines without a SHA256 hash are ignored, so mixed files (e.g., SHA512 entries) are accepted
+func ParseChecksums(r io.Reader) (map[string]string, error) {
+	sums := make(map[string]string)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var hash, name string
+		if rest, ok := strings.CutPrefix(line, "SHA256 ("); ok {
+			// BSD format
+			var found bool
+			name, hash, found = strings.Cut(rest, ") = ")
+			if !found {
+				continue
+			}
+		} else {
+			// sha256sum format, binary mode marks the name with *
+			var found bool
+			hash, name, found = strings.Cut(line, " ")
+			if !found {
+				continue
+			}
+			name = strings.TrimPrefix(strings.TrimLeft(name, " "), "*")
+		}
+
+		if len(hash) != 64 {
+			continue
+		}
+		if _, err := hex.DecodeString(hash); err != nil {
+			continue
+		}
+
+		// Checksums may be generated in a subdirectory, assets are matched by name
+		sums[filepath.Base(name)] = strings.ToLower(hash)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return sums, nil
+}
+
+// ParseChecksumsFile parses a checksums file, verifying its signature if it has one
+// The signature is either a clear signature of the file itself or the detached signature at signaturePath
+// (empty if there is none). Unsigned files are accepted and reported as such, since the
+// checksums still protect against corrupted downloads
+// Returns the checksums and whether a signature was verified
+func ParseChecksumsFile(checksumsFile, signaturePath string, verifier *Verifier) (map[string]string, bool, error) {
+	file, err := os.Open(checksumsFile)
+	if err != nil {
+		return nil, false, err
+	}
+	defer func() { _ = file.Close() }()
+
+	if signaturePath != "" {
+		if err := verifyDetached(file, signaturePath, verifier); err != nil {
+			return nil, false, fmt.Errorf("%s: %w", filepath.Base(checksumsFile), err)
+		}
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return nil, false, err
+		}
+		sums, err := ParseChecksums(file)
+		return sums, !verifier.IgnoreSignatures, err
+	}
+
+	isClearSigned, err := verifier.IsClearSigned(file)
+	if err != nil {
+		return nil, false, err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, false, err
+	}
+
+	if !isClearSigned {
+		sums, err := ParseChecksums(file)
+		return sums, false, err
+	}
+
+	text, _, err := verifier.VerifyAndClear(file)
+	if err != nil {
+		return nil, false, fmt.Errorf("%s: %w", filepath.Base(checksumsFile), err)
+	}
+	defer func() { _ = text.Close() }()
+
+	sums, err := ParseChecksums(text)
+	return sums, !verifier.IgnoreSignatures, err
+}
+
+// verifyDetached verifies the detached signature at signaturePath for text
+func verifyDetached(text io.Reader, signaturePath string, verifier *Verifier) error {
+	if verifier.IgnoreSignatures {
+		return nil
+	}
+
+	signature, err := os.Open(signaturePath)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = signature.Close() }()
+
+	if err := verifier.VerifyDetachedSignature(signature, text, false); err != nil {
+		return ErrSignatureVerificationFailed
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package debext
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testHashA = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	testHashB = "9F86D081884C7D659A2FEAA0C55AD015A3BF4F1B2B0B822CD15D6C15B0F00A08"
+)
+
+func TestParseChecksums(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  map[string]string
+	}{
+		{
+			name:  "sha256sum text mode",
+			input: testHashA + "  foo_1.0_amd64.deb\n",
+			want:  map[string]string{"foo_1.0_amd64.deb": testHashA},
+		},
+		{
+			name:  "sha256sum binary mode with path",
+			input: testHashB + " *dist/foo_1.0_arm64.deb\n",
+			want:  map[string]string{"foo_1.0_arm64.deb": strings.ToLower(testHashB)},
+		},
+		{
+			name:  "bsd format",
+			input: "SHA256 (foo_1.0_amd64.deb) = " + testHashA + "\n",
+			want:  map[string]string{"foo_1.0_amd64.deb": testHashA},
+		},
+		{
+			name:  "ignores comments and other hashes",
+			input: "# generated\n" + "d41d8cd98f00b204e9800998ecf8427e  foo.deb\n" + testHashA + "  bar.deb\n",
+			want:  map[string]string{"bar.deb": testHashA},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseChecksums(strings.NewReader(tt.input))
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParseChecksumsFile_Unsigned(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "SHA256SUMS")
+	require.NoError(t, os.WriteFile(path, []byte(testHashA+"  foo.deb\n"), 0644))
+
+	sums, signed, err := ParseChecksumsFile(path, "", testVerifier())
+	require.NoError(t, err)
+	assert.False(t, signed)
+	assert.Equal(t, map[string]string{"foo.deb": testHashA}, sums)
+}
@@ -0,0 +1,101 @@
+package debext
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/aptly-dev/aptly/deb"
+)
+
+// ContentsStore stores the file lists of binary packages keyed by the SHA256 of the package file
+// Implementations must be safe for concurrent use
+type ContentsStore interface {
+	// Load returns the file list for the given SHA256, if present
+	Load(sha256 string) ([]string, bool)
+	// Store saves the file list for the given SHA256
+	Store(sha256 string, files []string) error
+}
+
+// PackageContents returns the files installed by a .deb file, relative to the root directory
+// The file list is reused from store if sha256 matches, nil store extracts it every time
+func PackageContents(debFile, sha256 string, store ContentsStore) ([]string, error) {
+	if store != nil && sha256 != "" {
+		if files, ok := store.Load(sha256); ok {
+			return files, nil
+		}
+	}
+
+	f, err := os.Open(debFile)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	files, err := deb.GetContentsFromDeb(f, debFile)
+	if err != nil {
+		return nil, err
+	}
+
+	// A failing store only costs another extraction next time
+	if store != nil && sha256 != "" {
+		if err := store.Store(sha256, files); err != nil {
+			slog.Warn("Failed to cache package contents", "file", filepath.Base(debFile), "error", err)
+		}
+	}
+
+	return files, nil
+}
+
+// ContentsIndex maps installed files to the packages installing them, written as Contents-<arch> index
+type ContentsIndex struct {
+	files map[string][]string // path -> qualified package names
+}
+
+// NewContentsIndex creates an empty index
+func NewContentsIndex() *ContentsIndex {
+	return &ContentsIndex{files: make(map[string][]string)}
+}
+
+// Add records the files installed by the package with the qualified name location, see ContentsLocation
+func (c *ContentsIndex) Add(location string, files []string) {
+	for _, file := range files {
+		if !slices.Contains(c.files[file], location) {
+			c.files[file] = append(c.files[file], location)
+		}
+	}
+}
+
+// Len returns the number of files in the index
+func (c *ContentsIndex) Len() int {
+	return len(c.files)
+}
+
+// WriteTo writes the index sorted by path, one file per line followed by its comma separated packages
+func (c *ContentsIndex) WriteTo(w io.Writer) (int64, error) {
+	bw := bufio.NewWriter(w)
+	var n int64
+	for _, file := range slices.Sorted(maps.Keys(c.files)) {
+		locations := slices.Sorted(slices.Values(c.files[file]))
+		written, err := fmt.Fprintf(bw, "%s %s\n", file, strings.Join(locations, ","))
+		n += int64(written)
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, bw.Flush()
+}
+
+// ContentsLocation returns the qualified name of a package in Contents indexes: [$SECTION/]$NAME
+func ContentsLocation(pkg *deb.Package) string {
+	if section := pkg.Extra()["Section"]; section != "" {
+		return section + "/" + pkg.Name
+	}
+	return pkg.Name
+}
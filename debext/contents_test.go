@@ -0,0 +1,81 @@
+package debext
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/dionysius/aarg/debext/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContentsIndex_WriteTo(t *testing.T) {
+	index := NewContentsIndex()
+	index.Add("web/hello", []string{"usr/bin/hello", "usr/share/doc/hello/README"})
+	index.Add("admin/other", []string{"usr/bin/other", "usr/share/doc/hello/README"})
+	index.Add("web/hello", []string{"usr/bin/hello"})
+
+	var buf bytes.Buffer
+	_, err := index.WriteTo(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, 3, index.Len())
+	assert.Equal(t, "usr/bin/hello web/hello\n"+
+		"usr/bin/other admin/other\n"+
+		"usr/share/doc/hello/README admin/other,web/hello\n", buf.String())
+}
+
+func TestContentsLocation(t *testing.T) {
+	tests := []struct {
+		name   string
+		stanza deb.Stanza
+		want   string
+	}{
+		{name: "with section", stanza: deb.Stanza{"Package": "hello", "Section": "contrib/web"}, want: "contrib/web/hello"},
+		{name: "without section", stanza: deb.Stanza{"Package": "hello"}, want: "hello"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.stanza["Version"] = "1.0-1"
+			tt.stanza["Architecture"] = "amd64"
+			assert.Equal(t, tt.want, ContentsLocation(deb.NewPackageFromControlFile(tt.stanza)))
+		})
+	}
+}
+
+func TestPackageContents_Store(t *testing.T) {
+	debFile, err := testutil.WriteDeb(t.TempDir(), testutil.Package{
+		Name:         "hello",
+		Version:      "1.0-1",
+		Architecture: "amd64",
+		Description:  "hello",
+		Files:        map[string]string{"./usr/bin/hello": "#!/bin/sh\n", "./usr/share/doc/README": "hello\n"},
+	})
+	require.NoError(t, err)
+
+	store := memoryContentsStore{}
+	files, err := PackageContents(debFile, "abcdef", store)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"usr/bin/hello", "usr/share/doc/README"}, files)
+	assert.Equal(t, files, store["abcdef"])
+
+	// Second call is served from the store
+	store["abcdef"] = []string{"cached"}
+	cached, err := PackageContents(debFile, "abcdef", store)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"cached"}, cached)
+}
+
+// memoryContentsStore keeps file lists in a map, not safe for concurrent use
+type memoryContentsStore map[string][]string
+
+func (s memoryContentsStore) Load(sha256 string) ([]string, bool) {
+	files, ok := s[sha256]
+	return files, ok
+}
+
+func (s memoryContentsStore) Store(sha256 string, files []string) error {
+	s[sha256] = files
+	return nil
+}
@@ -0,0 +1,124 @@
+package debext
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	ar "github.com/mkrautz/goar"
+	xz "github.com/smira/go-xz"
+)
+
+// buildIDDir is the directory of separate debug files named after the build ID of their binary
+const buildIDDir = "usr/lib/debug/.build-id/"
+
+// BuildIDFromPath returns the build ID of a separate debug file at usr/lib/debug/.build-id/ab/cdef.debug
+func BuildIDFromPath(path string) (string, bool) {
+	rel, ok := strings.CutPrefix(strings.TrimPrefix(path, "./"), buildIDDir)
+	if !ok {
+		return "", false
+	}
+	rel, ok = strings.CutSuffix(rel, ".debug")
+	if !ok {
+		return "", false
+	}
+	prefix, rest, ok := strings.Cut(rel, "/")
+	if !ok || len(prefix) != 2 || rest == "" || strings.Contains(rest, "/") {
+		return "", false
+	}
+
+	buildID := strings.ToLower(prefix + rest)
+	if strings.Trim(buildID, "0123456789abcdef") != "" {
+		return "", false
+	}
+	return buildID, true
+}
+
+// ExtractDebugFiles calls fn with the build ID and content of every separate debug file in a .deb or .ddeb
+// Links in the .build-id directory pointing to the installed binaries are skipped
+func ExtractDebugFiles(debFile string, fn func(buildID string, r io.Reader) error) error {
+	f, err := os.Open(debFile)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+
+	data, closeData, err := openDataTar(f, debFile)
+	if err != nil {
+		return err
+	}
+	defer closeData()
+
+	untar := tar.NewReader(data)
+	for {
+		header, err := untar.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read data archive of %s: %w", debFile, err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		buildID, ok := BuildIDFromPath(header.Name)
+		if !ok {
+			continue
+		}
+		if err := fn(buildID, untar); err != nil {
+			return err
+		}
+	}
+}
+
+// openDataTar returns the decompressed data.tar member of a .deb archive and a function to release it
+func openDataTar(r io.Reader, debFile string) (io.Reader, func(), error) {
+	library := ar.NewReader(r)
+	for {
+		header, err := library.Next()
+		if err == io.EOF {
+			return nil, nil, fmt.Errorf("no data.tar member in %s", debFile)
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read .deb archive %s: %w", debFile, err)
+		}
+		if !strings.HasPrefix(header.Name, "data.tar") {
+			continue
+		}
+
+		member := bufio.NewReader(library)
+		switch header.Name {
+		case "data.tar":
+			return member, func() {}, nil
+		case "data.tar.gz":
+			unzip, err := gzip.NewReader(member)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to decompress %s of %s: %w", header.Name, debFile, err)
+			}
+			return unzip, func() { _ = unzip.Close() }, nil
+		case "data.tar.bz2":
+			return bzip2.NewReader(member), func() {}, nil
+		case "data.tar.xz":
+			unxz, err := xz.NewReader(member)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to decompress %s of %s: %w", header.Name, debFile, err)
+			}
+			return unxz, func() { _ = unxz.Close() }, nil
+		case "data.tar.zst":
+			unzstd, err := zstd.NewReader(member)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to decompress %s of %s: %w", header.Name, debFile, err)
+			}
+			return unzstd, unzstd.Close, nil
+		default:
+			return nil, nil, fmt.Errorf("unsupported compression of %s in %s", header.Name, debFile)
+		}
+	}
+}
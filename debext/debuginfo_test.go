@@ -0,0 +1,90 @@
+package debext
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	ar "github.com/mkrautz/goar"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildIDFromPath(t *testing.T) {
+	tests := []struct {
+		name   string
+		path   string
+		want   string
+		wantOk bool
+	}{
+		{name: "debug file", path: "./usr/lib/debug/.build-id/ab/cdef01.debug", want: "abcdef01", wantOk: true},
+		{name: "uppercase", path: "usr/lib/debug/.build-id/AB/CDEF.debug", want: "abcdef", wantOk: true},
+		{name: "binary link", path: "./usr/lib/debug/.build-id/ab/cdef01", wantOk: false},
+		{name: "not hex", path: "./usr/lib/debug/.build-id/ab/xyz.debug", wantOk: false},
+		{name: "nested", path: "./usr/lib/debug/.build-id/ab/cd/ef.debug", wantOk: false},
+		{name: "other directory", path: "./usr/lib/debug/usr/bin/hello.debug", wantOk: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := BuildIDFromPath(tt.path)
+			assert.Equal(t, tt.wantOk, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestExtractDebugFiles(t *testing.T) {
+	// data.tar.gz with a debug file, a link to the binary and an unrelated file
+	var data bytes.Buffer
+	gz := gzip.NewWriter(&data)
+	tw := tar.NewWriter(gz)
+	for _, entry := range []struct {
+		name, content, link string
+	}{
+		{name: "./usr/lib/debug/.build-id/ab/cdef.debug", content: "symbols"},
+		{name: "./usr/lib/debug/.build-id/ab/cdef", link: "../../../../bin/hello"},
+		{name: "./usr/share/doc/hello-dbgsym/copyright", content: "copyright"},
+	} {
+		header := &tar.Header{Name: entry.name, Mode: 0644, Size: int64(len(entry.content)), Typeflag: tar.TypeReg}
+		if entry.link != "" {
+			header = &tar.Header{Name: entry.name, Linkname: entry.link, Typeflag: tar.TypeSymlink}
+		}
+		require.NoError(t, tw.WriteHeader(header))
+		_, err := tw.Write([]byte(entry.content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+
+	var deb bytes.Buffer
+	aw := ar.NewWriter(&deb)
+	for _, member := range []struct {
+		name string
+		data []byte
+	}{
+		{name: "debian-binary", data: []byte("2.0\n")},
+		{name: "data.tar.gz", data: data.Bytes()},
+	} {
+		require.NoError(t, aw.WriteHeader(&ar.Header{Name: member.name, Mode: 0644, Size: int64(len(member.data))}))
+		_, err := aw.Write(member.data)
+		require.NoError(t, err)
+	}
+	require.NoError(t, aw.Close())
+
+	debFile := filepath.Join(t.TempDir(), "hello-dbgsym_1.0_amd64.ddeb")
+	require.NoError(t, os.WriteFile(debFile, deb.Bytes(), 0644))
+
+	extracted := make(map[string]string)
+	err := ExtractDebugFiles(debFile, func(buildID string, r io.Reader) error {
+		content, err := io.ReadAll(r)
+		extracted[buildID] = string(content)
+		return err
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"abcdef": "symbols"}, extracted)
+}
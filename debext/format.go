@@ -19,6 +19,7 @@ import (
 const (
 	DebugPackageSuffix  = "-dbgsym"
 	DebugPackageSection = "debug"
+	BuildinfoExtension  = ".buildinfo"
 )
 
 // ParseRelease parses an InRelease file and extracts index metadata
@@ -62,33 +63,30 @@ func ParseRelease(inReleaseFile string, verifier *Verifier) (*Release, error) {
 		Description:   stanza["Description"],
 		Files:         make(map[string]utils.ChecksumInfo),
 	}
-
-	// Parse Date - try multiple formats for compatibility
-	// RFC 2822/1123 is the spec, but some repositories use other formats
-	dateFormats := []string{
-		"Mon, 2 Jan 2006 15:04:05 MST",   // RFC 1123 with timezone (spec)
-		"Mon, 2 Jan 2006 15:04:05 -0700", // RFC 1123 with numeric timezone
-		"Mon Jan _2 15:04:05 2006",       // Unix date format (no timezone)
-		"Mon Jan _2 15:04:05 2006 MST",   // Unix date format with timezone
-		time.RFC1123Z,                    // Go stdlib RFC1123 with numeric zone
-		time.RFC1123,                     // Go stdlib RFC1123
+	for _, key := range keys {
+		config.Signers = append(config.Signers, string(key))
 	}
 
 	var parseErr error
-	for _, format := range dateFormats {
-		config.Date, parseErr = time.Parse(format, stanza["Date"])
-		if parseErr == nil {
-			// If parsed date has no timezone info, assume UTC
-			if config.Date.Location() == time.UTC || config.Date.Location().String() == "UTC" {
-				config.Date = config.Date.UTC()
-			}
-			break
-		}
-	}
+	config.Date, parseErr = parseReleaseDate(stanza["Date"])
 	if parseErr != nil {
 		return nil, fmt.Errorf("%s: invalid Date format: %w (tried RFC1123, Unix date formats)", inReleaseFile, parseErr)
 	}
 
+	// Refuse Release files not valid at the local time like apt does, naming the clock difference
+	if err := verifier.CheckNotBefore(config.Date, "Date", ErrReleaseNotYetValid); err != nil {
+		return nil, fmt.Errorf("%s: %w", inReleaseFile, err)
+	}
+	if validUntil := stanza["Valid-Until"]; validUntil != "" {
+		expires, err := parseReleaseDate(validUntil)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid Valid-Until format: %w", inReleaseFile, err)
+		}
+		if err := verifier.CheckNotAfter(expires, "Valid-Until", ErrReleaseExpired); err != nil {
+			return nil, fmt.Errorf("%s: %w", inReleaseFile, err)
+		}
+	}
+
 	// Parse SHA256 section for index files
 	sha256Section := stanza["SHA256"]
 	if sha256Section == "" {
@@ -159,18 +157,20 @@ func ParsePackageIndex(path string, isSource bool) ([]*deb.Package, error) {
 
 // ParseBinary creates a *deb.Package from a .deb file with proper pool path and checksums.
 // It parses the control file, calculates checksums, and sets the required fields.
-func ParseBinary(debFile string, poolPath string) (*deb.Package, error) {
-	// Parse the .deb control file
-	stanza, err := deb.GetControlFileFromDeb(debFile)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse %s: %w", debFile, err)
-	}
+// The control file is reused from store if the checksum matches, nil store parses it every time.
+func ParseBinary(debFile string, poolPath string, store ControlStore) (*deb.Package, error) {
 	// Calculate checksums using aptly's utility
 	checksums, err := utils.ChecksumsForFile(debFile)
 	if err != nil {
 		return nil, fmt.Errorf("%s: failed to calculate checksums: %w", debFile, err)
 	}
 
+	// Parse the .deb control file
+	stanza, err := cachedControl(debFile, checksums, store)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", debFile, err)
+	}
+
 	// Set the Filename field to poolPath/filename
 	filename := filepath.Base(debFile)
 	filePath := filepath.Join(poolPath, filename)
@@ -189,6 +189,7 @@ func ParseBinary(debFile string, poolPath string) (*deb.Package, error) {
 
 // ParseSource creates a *deb.Package from a .dsc file with proper directory path and checksums.
 // It verifies the signature, parses the control file, and processes all referenced source files.
+// Unlike ParseBinary it does not use the control store since the signature must be verified on every parse.
 func ParseSource(dscFile string, verifier *Verifier, poolPath string) (*deb.Package, error) {
 	// Parse and verify the .dsc file
 	file, err := os.Open(dscFile)
@@ -273,6 +274,11 @@ func ParseChanges(changesFile string, verifier *Verifier) (*deb.Changes, error)
 // GeneratePackageIndex generates a package index file (Packages or Sources) and writes to w.
 // Set isSource to true for Sources files, false for Packages files.
 func GeneratePackageIndex(w io.Writer, list *deb.PackageList, isSource bool) error {
+	return writePackageIndex(w, list, isSource, nil)
+}
+
+// writePackageIndex writes the stanzas of all packages of list, modify changes each stanza before it is written if set
+func writePackageIndex(w io.Writer, list *deb.PackageList, isSource bool, modify func(deb.Stanza)) error {
 	// PrepareIndex sorts the packages by name and version (latest to oldest)
 	list.PrepareIndex()
 
@@ -280,8 +286,13 @@ func GeneratePackageIndex(w io.Writer, list *deb.PackageList, isSource bool) err
 	bufWriter := bufio.NewWriter(w)
 
 	err := list.ForEachIndexed(func(pkg *deb.Package) error {
+		// Stanza returns a copy, modifications don't affect the package
+		stanza := pkg.Stanza()
+		if modify != nil {
+			modify(stanza)
+		}
 		// WriteTo handles canonical field ordering internally
-		if err := pkg.Stanza().WriteTo(bufWriter, isSource, false, false); err != nil {
+		if err := stanza.WriteTo(bufWriter, isSource, false, false); err != nil {
 			return err
 		}
 		// Add blank line between stanzas
@@ -298,6 +309,33 @@ func GeneratePackageIndex(w io.Writer, list *deb.PackageList, isSource bool) err
 	return bufWriter.Flush()
 }
 
+// parseReleaseDate parses a date field of a Release file
+// RFC 2822/1123 is the spec, but some repositories use other formats
+func parseReleaseDate(value string) (time.Time, error) {
+	dateFormats := []string{
+		"Mon, 2 Jan 2006 15:04:05 MST",   // RFC 1123 with timezone (spec)
+		"Mon, 2 Jan 2006 15:04:05 -0700", // RFC 1123 with numeric timezone
+		"Mon Jan _2 15:04:05 2006",       // Unix date format (no timezone)
+		"Mon Jan _2 15:04:05 2006 MST",   // Unix date format with timezone
+		time.RFC1123Z,                    // Go stdlib RFC1123 with numeric zone
+		time.RFC1123,                     // Go stdlib RFC1123
+	}
+
+	var parseErr error
+	for _, format := range dateFormats {
+		date, err := time.Parse(format, value)
+		if err == nil {
+			// If parsed date has no timezone info, assume UTC
+			if date.Location() == time.UTC || date.Location().String() == "UTC" {
+				date = date.UTC()
+			}
+			return date, nil
+		}
+		parseErr = err
+	}
+	return time.Time{}, parseErr
+}
+
 // Release holds configuration for generating Release file
 type Release struct {
 	Origin        string
@@ -308,8 +346,12 @@ type Release struct {
 	Architectures []string
 	Components    []string
 	Description   string
+	// Fields contains additional custom fields (e.g. "X-Homepage")
+	Fields map[string]string
 	// Files maps relative paths to their checksums (following aptly's indexFiles.generatedFiles pattern)
 	Files map[string]utils.ChecksumInfo
+	// Signers are the IDs of the keys with valid signatures, set by ParseRelease
+	Signers []string
 }
 
 // GenerateRelease creates a Release file using aptly's Stanza formatting and writes to w.
@@ -326,6 +368,9 @@ func GenerateRelease(w io.Writer, config Release) error {
 	// Description is a multiline field, needs leading space and trailing newline (aptly pattern)
 	release["Description"] = " " + config.Description + "\n"
 
+	// Custom fields are written after the canonical ones
+	maps.Copy(release, config.Fields)
+
 	// Build checksum sections (following aptly's pattern in publish.go lines 1143-1150)
 	release["MD5Sum"] = ""
 	release["SHA1"] = ""
@@ -389,6 +434,22 @@ func IsDebugPackage(pkg *deb.Package) bool {
 	return IsDebugByName(pkg.Name)
 }
 
+// ParseBuildinfoFilename splits a .buildinfo filename into source name and version.
+// Uses Debian convention: source_version_architecture.buildinfo, the version has no epoch.
+func ParseBuildinfoFilename(filename string) (source, version string, ok bool) {
+	name, found := strings.CutSuffix(filepath.Base(filename), BuildinfoExtension)
+	if !found {
+		return "", "", false
+	}
+
+	parts := strings.Split(name, "_")
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" {
+		return "", "", false
+	}
+
+	return parts[0], parts[1], true
+}
+
 // GetPoolPath returns the pool directory path for a package.
 // Uses Debian convention: pool/component/first-letter/package-name
 // Packages starting with "lib" use first 4 characters (lib + one letter).
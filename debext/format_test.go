@@ -2,14 +2,19 @@ package debext
 
 import (
 	"bytes"
+	"fmt"
+	"math/rand/v2"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/ProtonMail/go-crypto/openpgp"
 	"github.com/aptly-dev/aptly/deb"
+	"github.com/aptly-dev/aptly/pgp"
 	"github.com/aptly-dev/aptly/utils"
+	"github.com/dionysius/aarg/debext/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -61,10 +66,10 @@ func TestGeneratePackageIndex(t *testing.T) {
 		packages := deb.NewPackageList()
 
 		for _, debFile := range debFiles {
-			tempPkg, err := ParseBinary(debFile, "")
+			tempPkg, err := ParseBinary(debFile, "", nil)
 			require.NoError(t, err)
 			poolPath := GetPoolPath("main", tempPkg.Name)
-			pkg, err := ParseBinary(debFile, poolPath)
+			pkg, err := ParseBinary(debFile, poolPath, nil)
 			require.NoError(t, err)
 			err = packages.Add(pkg)
 			require.NoError(t, err)
@@ -110,6 +115,26 @@ func TestGenerateRelease(t *testing.T) {
 	assert.Equal(t, string(expected), output.String())
 }
 
+func TestGenerateRelease_Fields(t *testing.T) {
+	config := Release{
+		Suite:       "noble",
+		Codename:    "noble",
+		Description: "Generated by aarg",
+		Fields: map[string]string{
+			"X-Homepage": "https://example.com",
+			"X-Bugs":     "https://example.com/issues",
+		},
+	}
+
+	var output bytes.Buffer
+	require.NoError(t, GenerateRelease(&output, config))
+
+	// Custom fields follow the canonical fields in alphabetical order
+	out := output.String()
+	assert.Contains(t, out, "X-Bugs: https://example.com/issues\nX-Homepage: https://example.com\n")
+	assert.Less(t, strings.Index(out, "Description:"), strings.Index(out, "X-Bugs:"))
+}
+
 func TestParseRelease(t *testing.T) {
 	releaseData, err := os.ReadFile("testdata/Release")
 	require.NoError(t, err)
@@ -142,50 +167,145 @@ func TestParsePackageIndex(t *testing.T) {
 }
 
 func TestParseBinary(t *testing.T) {
-	debFiles, err := filepath.Glob("testdata/files-stripped-cleared/*.deb")
+	debFile, err := testutil.WriteDeb(t.TempDir(), testutil.Package{Name: "hello", Version: "1.0-1", Architecture: "amd64", Description: "hello"})
 	require.NoError(t, err)
-	require.NotEmpty(t, debFiles)
 
-	tempPkg, err := ParseBinary(debFiles[0], "")
-	require.NoError(t, err)
-	poolPath := GetPoolPath("main", tempPkg.Name)
-	pkg, err := ParseBinary(debFiles[0], poolPath)
+	poolPath := GetPoolPath("main", "hello")
+	pkg, err := ParseBinary(debFile, poolPath, nil)
 
 	assert.NoError(t, err)
-	assert.NotEmpty(t, pkg.Name)
+	assert.Equal(t, "hello", pkg.Name)
 	assert.False(t, pkg.IsSource)
 	assert.True(t, pkg.Files()[0].Checksums.Complete())
 	assert.Contains(t, pkg.Files()[0].DownloadURL(), poolPath)
 }
 
 func TestParseSource(t *testing.T) {
-	dscFiles, err := filepath.Glob("testdata/files-stripped-cleared/*.dsc")
-	require.NoError(t, err)
-	require.NotEmpty(t, dscFiles)
+	tests := []struct {
+		name      string
+		version   string
+		wantFiles int
+	}{
+		{name: "native", version: "1.0", wantFiles: 2},
+		{name: "quilt", version: "1:1.0-1", wantFiles: 3},
+	}
 
-	verifier := testVerifier()
-	poolPath := "pool/main/v/vaultwarden"
-	pkg, err := ParseSource(dscFiles[0], verifier, poolPath)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dscFile, err := testutil.WriteSource(t.TempDir(), testutil.Source{Name: "hello", Version: tt.version, Binaries: []string{"hello", "hello-doc"}}, nil)
+			require.NoError(t, err)
 
-	assert.NoError(t, err)
-	assert.NotEmpty(t, pkg.Name)
-	assert.True(t, pkg.IsSource)
-	assert.NotEmpty(t, pkg.Files())
-	assert.Equal(t, poolPath, pkg.Extra()["Directory"])
+			poolPath := "pool/main/h/hello"
+			pkg, err := ParseSource(dscFile, testVerifier(), poolPath)
+
+			require.NoError(t, err)
+			assert.Equal(t, "hello", pkg.Name)
+			assert.Equal(t, tt.version, pkg.Version)
+			assert.True(t, pkg.IsSource)
+			assert.Len(t, pkg.Files(), tt.wantFiles)
+			assert.Equal(t, poolPath, pkg.Extra()["Directory"])
+		})
+	}
 }
 
 func TestParseChanges(t *testing.T) {
-	changesFiles, err := filepath.Glob("testdata/files-stripped-cleared/*.changes")
+	dir := t.TempDir()
+	source := testutil.Source{Name: "hello", Version: "1.0-1"}
+	_, err := testutil.WriteSource(dir, source, nil)
+	require.NoError(t, err)
+	debFile, err := testutil.WriteDeb(dir, testutil.Package{Name: "hello", Version: "1.0-1", Architecture: "amd64", Description: "hello"})
 	require.NoError(t, err)
-	require.NotEmpty(t, changesFiles)
 
-	verifier := testVerifier()
-	changes, err := ParseChanges(changesFiles[0], verifier)
+	files := []string{debFile}
+	for _, name := range source.Filenames() {
+		files = append(files, filepath.Join(dir, name))
+	}
+	signer, err := openpgp.NewEntity("uploader", "", "uploader@example.com", nil)
+	require.NoError(t, err)
+	changesFile, err := testutil.WriteChanges(dir, testutil.Changes{Source: "hello", Version: "1.0-1", Files: files}, signer)
+	require.NoError(t, err)
+	assert.Equal(t, "hello_1.0-1_amd64.changes", filepath.Base(changesFile))
 
-	assert.NoError(t, err)
+	var keyring bytes.Buffer
+	require.NoError(t, signer.Serialize(&keyring))
+	keyringPath := filepath.Join(t.TempDir(), "uploader.gpg")
+	require.NoError(t, os.WriteFile(keyringPath, keyring.Bytes(), 0644))
+	goVerifier := &pgp.GoVerifier{}
+	goVerifier.AddKeyring(keyringPath)
+	require.NoError(t, goVerifier.InitKeyring(false))
+
+	changes, err := ParseChanges(changesFile, &Verifier{Verifier: goVerifier})
+
+	require.NoError(t, err)
 	assert.NotEmpty(t, changes.Changes)
-	assert.NotEmpty(t, changes.Files)
-	assert.NotEmpty(t, changes.Source)
+	assert.Len(t, changes.Files, 4) // .dsc, .orig and .debian tarballs and .deb
+	assert.Equal(t, "hello", changes.Source)
+	assert.Equal(t, "source amd64", changes.Stanza["Architecture"])
+	assert.Len(t, changes.SignatureKeys, 1)
+}
+
+// TestGeneratedPackages_RoundTrip parses generated packages of random names and versions and checks
+// that their index entries survive generating and parsing a Packages and Sources index
+func TestGeneratedPackages_RoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	architectures := []string{"amd64", "arm64", "all"}
+	dir := t.TempDir()
+
+	binaries := deb.NewPackageList()
+	sources := deb.NewPackageList()
+	for i := range 20 {
+		name := fmt.Sprintf("pkg%d-%c", i, 'a'+rng.IntN(26))
+		version := fmt.Sprintf("%d.%d-%d", rng.IntN(10), rng.IntN(100), 1+rng.IntN(3))
+		if rng.IntN(4) == 0 {
+			version = fmt.Sprintf("%d:%s", 1+rng.IntN(3), version)
+		}
+
+		debFile, err := testutil.WriteDeb(dir, testutil.Package{
+			Name:         name,
+			Version:      version,
+			Architecture: architectures[rng.IntN(len(architectures))],
+			Description:  "generated " + name + "\nExtended description\n\nof " + name,
+		})
+		require.NoError(t, err)
+		pkg, err := ParseBinary(debFile, GetPoolPath("main", name), nil)
+		require.NoError(t, err)
+		require.NoError(t, binaries.Add(pkg))
+
+		dscFile, err := testutil.WriteSource(dir, testutil.Source{Name: name, Version: version}, nil)
+		require.NoError(t, err)
+		src, err := ParseSource(dscFile, testVerifier(), GetPoolPath("main", name))
+		require.NoError(t, err)
+		require.NoError(t, sources.Add(src))
+	}
+
+	for _, list := range []*deb.PackageList{binaries, sources} {
+		isSource := list == sources
+		var buf bytes.Buffer
+		require.NoError(t, GeneratePackageIndex(&buf, list, isSource))
+		indexPath := filepath.Join(t.TempDir(), "index")
+		require.NoError(t, os.WriteFile(indexPath, buf.Bytes(), 0644))
+
+		parsed, err := ParsePackageIndex(indexPath, isSource)
+		require.NoError(t, err)
+		require.Len(t, parsed, list.Len())
+		for _, pkg := range parsed {
+			original := list.SearchByKey(pkg.Architecture, pkg.Name, pkg.Version)
+			require.Equal(t, 1, original.Len(), pkg.String())
+			require.NoError(t, original.ForEach(func(want *deb.Package) error {
+				assert.Equal(t, fileSHA256s(want), fileSHA256s(pkg), pkg.String())
+				return nil
+			}))
+		}
+	}
+}
+
+// fileSHA256s returns the SHA256 checksums of the files of a package by file name
+func fileSHA256s(pkg *deb.Package) map[string]string {
+	checksums := make(map[string]string)
+	for _, file := range pkg.Files() {
+		checksums[file.Filename] = file.Checksums.SHA256
+	}
+	return checksums
 }
 
 func TestGetPoolPath(t *testing.T) {
@@ -194,6 +314,18 @@ func TestGetPoolPath(t *testing.T) {
 	assert.Equal(t, "pool/contrib/p/python", GetPoolPath("contrib", "python"))
 }
 
+func TestParseBuildinfoFilename(t *testing.T) {
+	source, version, ok := ParseBuildinfoFilename("vaultwarden_1.34.3-1_amd64.buildinfo")
+	assert.True(t, ok)
+	assert.Equal(t, "vaultwarden", source)
+	assert.Equal(t, "1.34.3-1", version)
+
+	_, _, ok = ParseBuildinfoFilename("vaultwarden_1.34.3-1_amd64.deb")
+	assert.False(t, ok)
+	_, _, ok = ParseBuildinfoFilename("vaultwarden.buildinfo")
+	assert.False(t, ok)
+}
+
 func TestIsDebugPackage(t *testing.T) {
 	assert.True(t, IsDebugByName("package-dbgsym"))
 	assert.False(t, IsDebugByName("package"))
@@ -0,0 +1,43 @@
+module github.com/dionysius/aarg/debext
+
+go 1.24.0
+
+require (
+	github.com/ProtonMail/go-crypto v1.0.0
+	github.com/aptly-dev/aptly v1.6.2
+	github.com/klauspost/compress v1.17.9
+	github.com/mkrautz/goar v0.0.0-20150919110319-282caa8bd9da
+	github.com/smira/go-xz v0.1.0
+	github.com/stretchr/testify v1.11.1
+)
+
+require (
+	github.com/AlekSi/pointer v1.1.0 // indirect
+	github.com/DisposaBoy/JsonConfigReader v0.0.0-20171218180944-5ea4d0ddac55 // indirect
+	github.com/awalterschulze/gographviz v2.0.1+incompatible // indirect
+	github.com/cavaliergopher/grab/v3 v3.0.1 // indirect
+	github.com/cloudflare/circl v1.4.0 // indirect
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/google/uuid v1.6.0 // indirect
+	github.com/h2non/filetype v1.1.3 // indirect
+	github.com/hashicorp/errwrap v1.1.0 // indirect
+	github.com/hashicorp/go-multierror v1.1.1 // indirect
+	github.com/jlaffaye/ftp v0.2.0 // indirect
+	github.com/kjk/lzma v0.0.0-20120628231508-2a7c55cad4a2 // indirect
+	github.com/klauspost/pgzip v1.2.5 // indirect
+	github.com/mattn/go-colorable v0.1.13 // indirect
+	github.com/mattn/go-isatty v0.0.20 // indirect
+	github.com/mxk/go-flowrate v0.0.0-20140419014527-cca7078d478f // indirect
+	github.com/pkg/errors v0.9.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/rs/zerolog v1.29.1 // indirect
+	github.com/saracen/walker v0.1.2 // indirect
+	github.com/smira/go-ftp-protocol v0.0.0-20140829150050-066b75c2b70d // indirect
+	github.com/ugorji/go/codec v1.2.11 // indirect
+	golang.org/x/crypto v0.36.0 // indirect
+	golang.org/x/sync v0.12.0 // indirect
+	golang.org/x/sys v0.31.0 // indirect
+	golang.org/x/term v0.30.0 // indirect
+	golang.org/x/time v0.5.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
@@ -0,0 +1,133 @@
+package debext
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/aptly-dev/aptly/pgp"
+)
+
+// ErrGPGFailed is returned when running gpg fails
+var ErrGPGFailed = errors.New("gpg failed")
+
+var _ pgp.Signer = &GPGSigner{}
+
+// GPGSigner signs files by running gpg and implements aptly's pgp.Signer
+// The private keys never enter the process, gpg-agent holds them or forwards to a hardware token
+// (e.g. a YubiKey or an HSM via scdaemon). Every key adds a signature like with Signer
+type GPGSigner struct {
+	Keys       []string // Fingerprints or long key IDs of the keys to sign with
+	GPG        string   // gpg binary, empty = gpg from PATH
+	Home       string   // GnuPG home directory, empty = gpg's default
+	Passphrase string   // Passphrase or PIN passed with loopback pinentry, empty = gpg-agent asks or has it cached
+}
+
+// SetKey sets the first key
+func (g *GPGSigner) SetKey(keyRef string) {
+	if len(g.Keys) == 0 {
+		g.Keys = append(g.Keys, keyRef)
+		return
+	}
+	g.Keys[0] = keyRef
+}
+
+// SetKeyRing is a no-op, the keys are taken from the GnuPG home directory
+func (g *GPGSigner) SetKeyRing(_, _ string) {}
+
+// SetPassphrase sets the passphrase, read from passphraseFile if passphrase is empty
+func (g *GPGSigner) SetPassphrase(passphrase, passphraseFile string) {
+	if passphrase == "" && passphraseFile != "" {
+		if data, err := os.ReadFile(passphraseFile); err == nil {
+			passphrase = strings.TrimSpace(string(data))
+		}
+	}
+	g.Passphrase = passphrase
+}
+
+// SetBatch is a no-op, gpg always runs in batch mode
+func (g *GPGSigner) SetBatch(bool) {}
+
+// Init checks that gpg is available and has a secret key for every key
+func (g *GPGSigner) Init() error {
+	if len(g.Keys) == 0 {
+		return fmt.Errorf("%w: no keys configured", ErrSigningKeyNotFound)
+	}
+	if g.GPG == "" {
+		g.GPG = "gpg"
+	}
+	if _, err := exec.LookPath(g.GPG); err != nil {
+		return fmt.Errorf("%w: %w", ErrGPGFailed, err)
+	}
+
+	for _, key := range g.Keys {
+		if _, err := g.run(nil, "--with-colons", "--list-secret-keys", key); err != nil {
+			return fmt.Errorf("%w: no secret key %s in gpg: %w", ErrSigningKeyNotFound, key, err)
+		}
+	}
+	return nil
+}
+
+// ExportPublicKeys returns the ASCII-armored public keys of all keys
+func (g *GPGSigner) ExportPublicKeys() ([]byte, error) {
+	args := append([]string{"--armor", "--export"}, g.Keys...)
+	output, err := g.run(nil, args...)
+	if err != nil {
+		return nil, err
+	}
+	if len(output) == 0 {
+		return nil, fmt.Errorf("%w: no public keys exported for %s", ErrSigningKeyNotFound, strings.Join(g.Keys, ", "))
+	}
+	return output, nil
+}
+
+// DetachedSign writes the ASCII-armored signatures of all keys of source to destination
+func (g *GPGSigner) DetachedSign(source string, destination string) error {
+	return g.sign("--detach-sign", source, destination)
+}
+
+// ClearSign writes source clearsigned by all keys to destination
+func (g *GPGSigner) ClearSign(source string, destination string) error {
+	return g.sign("--clearsign", source, destination)
+}
+
+// sign runs gpg with a signing command for all keys
+func (g *GPGSigner) sign(command, source, destination string) error {
+	args := []string{"--armor", "--yes", "--digest-algo", "SHA256", "--output", destination}
+	for _, key := range g.Keys {
+		args = append(args, "--local-user", key)
+	}
+
+	var stdin []byte
+	if g.Passphrase != "" {
+		args = append(args, "--pinentry-mode", "loopback", "--passphrase-fd", "0")
+		stdin = []byte(g.Passphrase + "\n")
+	}
+
+	args = append(args, command, source)
+	if _, err := g.run(stdin, args...); err != nil {
+		return fmt.Errorf("failed to sign %s: %w", source, err)
+	}
+	return nil
+}
+
+// run runs gpg non-interactively and returns its output, errors carry the output of gpg
+func (g *GPGSigner) run(stdin []byte, args ...string) ([]byte, error) {
+	args = append([]string{"--batch", "--no-tty"}, args...)
+	if g.Home != "" {
+		args = append([]string{"--homedir", g.Home}, args...)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(g.GPG, args...)
+	cmd.Stdin = bytes.NewReader(stdin)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%w: %w: %s", ErrGPGFailed, err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
@@ -0,0 +1,137 @@
+package debext
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/aptly-dev/aptly/pgp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testGPGHome imports the private keys into a new GnuPG home directory, skipping the test without gpg
+func testGPGHome(t *testing.T, keyPaths ...string) string {
+	t.Helper()
+	if _, err := exec.LookPath("gpg"); err != nil {
+		t.Skip("gpg not installed")
+	}
+
+	// Short path, gpg-agent sockets are limited to about 100 characters
+	home, err := os.MkdirTemp("", "gpg")
+	require.NoError(t, err)
+	require.NoError(t, os.Chmod(home, 0700))
+	t.Cleanup(func() {
+		_ = exec.Command("gpgconf", "--homedir", home, "--kill", "gpg-agent").Run()
+		_ = os.RemoveAll(home)
+	})
+
+	for _, path := range keyPaths {
+		output, err := exec.Command("gpg", "--homedir", home, "--batch", "--import", path).CombinedOutput()
+		require.NoError(t, err, string(output))
+	}
+	return home
+}
+
+func TestGPGSigner(t *testing.T) {
+	current, currentPath := testPrivateKey(t, "current", "")
+	next, nextPath := testPrivateKey(t, "next", "")
+	home := testGPGHome(t, currentPath, nextPath)
+
+	var keyring bytes.Buffer
+	require.NoError(t, current.Serialize(&keyring))
+	require.NoError(t, next.Serialize(&keyring))
+	keyringPath := filepath.Join(t.TempDir(), "keyring.gpg")
+	require.NoError(t, os.WriteFile(keyringPath, keyring.Bytes(), 0644))
+	verifier := &pgp.GoVerifier{}
+	verifier.AddKeyring(keyringPath)
+	require.NoError(t, verifier.InitKeyring(false))
+
+	signer := &GPGSigner{Keys: []string{testFingerprint(next, -1), testFingerprint(current, -1)}, Home: home}
+	require.NoError(t, signer.Init())
+
+	dir := t.TempDir()
+	release := filepath.Join(dir, "Release")
+	require.NoError(t, os.WriteFile(release, []byte("Origin: test\nSuite: stable\n"), 0644))
+
+	t.Run("ClearSign", func(t *testing.T) {
+		inRelease := filepath.Join(dir, "InRelease")
+		require.NoError(t, signer.ClearSign(release, inRelease))
+
+		data, err := os.ReadFile(inRelease)
+		require.NoError(t, err)
+		issuers, err := SignatureIssuers(data)
+		require.NoError(t, err)
+		assert.Len(t, issuers, 2)
+
+		keyInfo, err := verifier.VerifyClearsigned(bytes.NewReader(data), false)
+		require.NoError(t, err)
+		assert.Len(t, keyInfo.GoodKeys, 2)
+	})
+
+	t.Run("DetachedSign", func(t *testing.T) {
+		signature := filepath.Join(dir, "Release.gpg")
+		require.NoError(t, signer.DetachedSign(release, signature))
+
+		sig, err := os.Open(signature)
+		require.NoError(t, err)
+		defer func() { _ = sig.Close() }()
+		text, err := os.Open(release)
+		require.NoError(t, err)
+		defer func() { _ = text.Close() }()
+		assert.NoError(t, verifier.VerifyDetachedSignature(sig, text, false))
+	})
+
+	t.Run("ExportPublicKeys", func(t *testing.T) {
+		data, err := signer.ExportPublicKeys()
+		require.NoError(t, err)
+		keys, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(data))
+		require.NoError(t, err)
+		assert.Len(t, keys, 2)
+		for _, key := range keys {
+			assert.Nil(t, key.PrivateKey)
+		}
+	})
+
+	t.Run("unknown key", func(t *testing.T) {
+		signer := &GPGSigner{Keys: []string{"0123456789ABCDEF"}, Home: home}
+		assert.ErrorIs(t, signer.Init(), ErrSigningKeyNotFound)
+	})
+}
+
+func TestGPGSigner_Passphrase(t *testing.T) {
+	entity, path := testPrivateKey(t, "locked", "secret")
+	home := testGPGHome(t, path)
+
+	dir := t.TempDir()
+	release := filepath.Join(dir, "Release")
+	require.NoError(t, os.WriteFile(release, []byte("Origin: test\n"), 0644))
+
+	tests := []struct {
+		name       string
+		passphrase string
+		wantErr    error
+	}{
+		// gpg-agent caches the passphrase once it succeeded
+		{name: "wrong passphrase", passphrase: "wrong", wantErr: ErrGPGFailed},
+		{name: "loopback passphrase", passphrase: "secret"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signer := &GPGSigner{Keys: []string{testFingerprint(entity, -1)}, Home: home}
+			signer.SetPassphrase(tt.passphrase, "")
+			require.NoError(t, signer.Init())
+
+			err := signer.ClearSign(release, filepath.Join(dir, "InRelease"))
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
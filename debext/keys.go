@@ -0,0 +1,395 @@
+package debext
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/clearsign"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+)
+
+// ErrKeyInvalid is returned when a key file can't be read
+var ErrKeyInvalid = errors.New("invalid key file")
+
+// KeyFormat is the detected format of a key file
+type KeyFormat string
+
+const (
+	KeyFormatBinary  KeyFormat = "binary"  // OpenPGP packets (.gpg)
+	KeyFormatArmored KeyFormat = "armored" // One or more ASCII-armored blocks (.asc)
+	KeyFormatKeybox  KeyFormat = "keybox"  // GnuPG keybox (.kbx)
+)
+
+// Keybox blob types, see kbx/keybox-blob.c of GnuPG
+const (
+	keyboxBlobHeader  = 1
+	keyboxBlobOpenPGP = 2
+)
+
+// keyboxMagic identifies the header blob of a keybox
+var keyboxMagic = []byte("KBXf")
+
+// DetectKeyFormat returns the format of key data
+func DetectKeyFormat(data []byte) KeyFormat {
+	trimmed := bytes.TrimLeft(data, " \t\r\n")
+	if bytes.HasPrefix(trimmed, []byte("-----")) {
+		return KeyFormatArmored
+	}
+	if len(data) >= 12 && data[4] == keyboxBlobHeader && bytes.Equal(data[8:12], keyboxMagic) {
+		return KeyFormatKeybox
+	}
+	return KeyFormatBinary
+}
+
+// ReadKeys reads the OpenPGP keys of a key file or of all files in a directory
+// Errors name the offending file and the block or keybox blob within it
+func ReadKeys(path string) (openpgp.EntityList, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return ReadKeyFile(path)
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys openpgp.EntityList
+	for _, entry := range entries {
+		// Skip subdirectories and hidden files such as editor leftovers or .gitkeep
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		fileKeys, err := ReadKeyFile(filepath.Join(path, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, fileKeys...)
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%w: %s: no keys found in directory", ErrKeyInvalid, path)
+	}
+	return keys, nil
+}
+
+// ReadKeyFile reads the OpenPGP keys of a binary, armored or keybox file
+func ReadKeyFile(path string) (openpgp.EntityList, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := ReadKeyData(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", ErrKeyInvalid, path, err)
+	}
+	return keys, nil
+}
+
+// ReadKeyData reads the OpenPGP keys of key data in any supported format
+func ReadKeyData(data []byte) (openpgp.EntityList, error) {
+	var keys openpgp.EntityList
+	var err error
+
+	switch DetectKeyFormat(data) {
+	case KeyFormatArmored:
+		keys, err = readArmoredKeys(data)
+	case KeyFormatKeybox:
+		keys, err = readKeyboxKeys(data)
+	default:
+		keys, err = readBinaryKeys(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("no keys found")
+	}
+	return keys, nil
+}
+
+// readBinaryKeys reads keys from OpenPGP packets
+func readBinaryKeys(data []byte) (openpgp.EntityList, error) {
+	keys, err := openpgp.ReadKeyRing(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("binary keyring: %w", err)
+	}
+	return keys, nil
+}
+
+// readArmoredKeys reads keys from one or more concatenated ASCII-armored blocks
+// Blocks are split before decoding since the armor decoder buffers beyond the end of a block
+func readArmoredKeys(data []byte) (openpgp.EntityList, error) {
+	var keys openpgp.EntityList
+
+	begin := []byte("-----BEGIN ")
+	for block, rest := 1, data; ; block++ {
+		start := bytes.Index(rest, begin)
+		if start < 0 {
+			break
+		}
+		rest = rest[start:]
+
+		end := bytes.Index(rest[len(begin):], begin)
+		chunk := rest
+		if end >= 0 {
+			chunk = rest[:len(begin)+end]
+		}
+		rest = rest[len(chunk):]
+
+		decoded, err := armor.Decode(bytes.NewReader(chunk))
+		if err != nil {
+			return nil, fmt.Errorf("armored block %d: %w", block, err)
+		}
+
+		blockKeys, err := openpgp.ReadKeyRing(decoded.Body)
+		if err != nil {
+			return nil, fmt.Errorf("armored block %d (%s): %w", block, decoded.Type, err)
+		}
+		keys = append(keys, blockKeys...)
+	}
+
+	return keys, nil
+}
+
+// WriteArmored writes the output of serialize ASCII armored as blockType to w, followed by a newline
+func WriteArmored(w io.Writer, blockType string, serialize func(io.Writer) error) error {
+	encoder, err := armor.Encode(w, blockType, nil)
+	if err != nil {
+		return err
+	}
+	if err := serialize(encoder); err != nil {
+		return err
+	}
+	if err := encoder.Close(); err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "\n")
+	return err
+}
+
+// readKeyboxKeys reads keys from the OpenPGP blobs of a GnuPG keybox, X.509 blobs are skipped
+func readKeyboxKeys(data []byte) (openpgp.EntityList, error) {
+	var keys openpgp.EntityList
+
+	for offset, blob := 0, 1; offset < len(data); blob++ {
+		if len(data)-offset < 5 {
+			return nil, fmt.Errorf("keybox blob %d: truncated", blob)
+		}
+		length := int(binary.BigEndian.Uint32(data[offset:]))
+		if length < 5 || length > len(data)-offset {
+			return nil, fmt.Errorf("keybox blob %d: invalid length %d", blob, length)
+		}
+		image := data[offset : offset+length]
+		offset += length
+
+		if image[4] != keyboxBlobOpenPGP {
+			continue
+		}
+
+		// Fixed part: version, flags, keyblock offset and length, number and size of key infos
+		if len(image) < 20 {
+			return nil, fmt.Errorf("keybox blob %d: truncated", blob)
+		}
+		start := int(binary.BigEndian.Uint32(image[8:]))
+		size := int(binary.BigEndian.Uint32(image[12:]))
+		if start < 0 || size < 0 || start+size > len(image) {
+			return nil, fmt.Errorf("keybox blob %d: keyblock out of bounds", blob)
+		}
+
+		blobKeys, err := openpgp.ReadKeyRing(bytes.NewReader(image[start : start+size]))
+		if err != nil {
+			return nil, fmt.Errorf("keybox blob %d (key %s): %w", blob, keyboxFingerprint(image), err)
+		}
+		keys = append(keys, blobKeys...)
+	}
+
+	return keys, nil
+}
+
+// keyboxFingerprint returns the fingerprint of the primary key of an OpenPGP keybox blob
+func keyboxFingerprint(image []byte) string {
+	keyInfoSize := int(binary.BigEndian.Uint16(image[18:]))
+	if keyInfoSize < 20 || len(image) < 20+keyInfoSize {
+		return "unknown"
+	}
+	// Version 4 fingerprints fill the first 20 bytes of the key info
+	return strings.ToUpper(hex.EncodeToString(image[20:40]))
+}
+
+// KeyIDs returns the long key IDs of the primary keys for error and log messages
+func KeyIDs(keys openpgp.EntityList) []string {
+	ids := make([]string, 0, len(keys))
+	for _, key := range keys {
+		ids = append(ids, key.PrimaryKey.KeyIdString())
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+// KeyInfo describes an OpenPGP key for diagnostics
+type KeyInfo struct {
+	Fingerprint string
+	KeyID       string
+	UIDs        []string
+	Algorithm   string
+	Bits        int // Key size, 0 for algorithms with fixed sizes
+	Created     time.Time
+	Expires     *time.Time // nil if the key doesn't expire
+	Revoked     bool
+	Subkeys     []KeyInfo
+}
+
+// Expired reports whether the key is expired at t
+func (k KeyInfo) Expired(t time.Time) bool {
+	return k.Expires != nil && t.After(*k.Expires)
+}
+
+// DescribeKeys returns the details of the primary keys and their subkeys
+func DescribeKeys(keys openpgp.EntityList) []KeyInfo {
+	now := time.Now()
+	infos := make([]KeyInfo, 0, len(keys))
+
+	for _, entity := range keys {
+		var lifetime *uint32
+		if identity := entity.PrimaryIdentity(); identity != nil && identity.SelfSignature != nil {
+			lifetime = identity.SelfSignature.KeyLifetimeSecs
+		}
+		info := describeKey(entity.PrimaryKey, lifetime)
+		info.Revoked = entity.Revoked(now)
+
+		for name := range entity.Identities {
+			info.UIDs = append(info.UIDs, name)
+		}
+		slices.Sort(info.UIDs)
+
+		for _, subkey := range entity.Subkeys {
+			var subLifetime *uint32
+			if subkey.Sig != nil {
+				subLifetime = subkey.Sig.KeyLifetimeSecs
+			}
+			subInfo := describeKey(subkey.PublicKey, subLifetime)
+			subInfo.Revoked = subkey.Revoked(now)
+			info.Subkeys = append(info.Subkeys, subInfo)
+		}
+
+		infos = append(infos, info)
+	}
+
+	return infos
+}
+
+// describeKey returns the details of a single public key
+func describeKey(key *packet.PublicKey, lifetime *uint32) KeyInfo {
+	info := KeyInfo{
+		Fingerprint: strings.ToUpper(hex.EncodeToString(key.Fingerprint)),
+		KeyID:       key.KeyIdString(),
+		Algorithm:   algorithmName(key.PubKeyAlgo),
+		Created:     key.CreationTime,
+	}
+	if bits, err := key.BitLength(); err == nil {
+		info.Bits = int(bits)
+	}
+	if lifetime != nil && *lifetime > 0 {
+		expires := key.CreationTime.Add(time.Duration(*lifetime) * time.Second)
+		info.Expires = &expires
+	}
+	return info
+}
+
+// algorithmName returns the name of a public key algorithm
+func algorithmName(algo packet.PublicKeyAlgorithm) string {
+	switch algo {
+	case packet.PubKeyAlgoRSA, packet.PubKeyAlgoRSASignOnly, packet.PubKeyAlgoRSAEncryptOnly:
+		return "RSA"
+	case packet.PubKeyAlgoDSA:
+		return "DSA"
+	case packet.PubKeyAlgoElGamal:
+		return "ElGamal"
+	case packet.PubKeyAlgoECDH:
+		return "ECDH"
+	case packet.PubKeyAlgoECDSA:
+		return "ECDSA"
+	case packet.PubKeyAlgoEdDSA:
+		return "EdDSA"
+	default:
+		return fmt.Sprintf("algorithm %d", algo)
+	}
+}
+
+// SignatureIssuers returns the key IDs (or fingerprints, if present) of the signatures of a
+// clearsigned file, also if the signing key isn't known
+func SignatureIssuers(data []byte) ([]string, error) {
+	block, _ := clearsign.Decode(data)
+	if block == nil {
+		return nil, ErrMissingSignature
+	}
+
+	var issuers []string
+	reader := packet.NewReader(block.ArmoredSignature.Body)
+	for {
+		p, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read signature: %w", err)
+		}
+
+		sig, ok := p.(*packet.Signature)
+		if !ok {
+			continue
+		}
+		switch {
+		case len(sig.IssuerFingerprint) > 0:
+			issuers = append(issuers, strings.ToUpper(hex.EncodeToString(sig.IssuerFingerprint)))
+		case sig.IssuerKeyId != nil:
+			issuers = append(issuers, fmt.Sprintf("%016X", *sig.IssuerKeyId))
+		}
+	}
+
+	return issuers, nil
+}
+
+// FindKey returns the key (or the key whose subkey) matches an issuer key ID or fingerprint
+func FindKey(infos []KeyInfo, issuer string) (KeyInfo, bool) {
+	matches := func(info KeyInfo) bool {
+		return strings.EqualFold(info.Fingerprint, issuer) || strings.EqualFold(info.KeyID, issuer)
+	}
+	for _, info := range infos {
+		if matches(info) || slices.ContainsFunc(info.Subkeys, matches) {
+			return info, true
+		}
+	}
+	return KeyInfo{}, false
+}
+
+// CrossSign certifies the user IDs of each key with the other key, so clients trusting one key of a
+// key rotation can validate the other. The primary private keys of both keys must be decrypted
+func CrossSign(a, b *openpgp.Entity) error {
+	for _, pair := range [][2]*openpgp.Entity{{a, b}, {b, a}} {
+		key, signer := pair[0], pair[1]
+		for name := range key.Identities {
+			if err := key.SignIdentity(name, signer, nil); err != nil {
+				return fmt.Errorf("failed to certify %q with key %s: %w", name, signer.PrimaryKey.KeyIdString(), err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,271 @@
+package debext
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/clearsign"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testKey creates a public key and returns it with its binary serialization
+func testKey(t *testing.T, name string) (*openpgp.Entity, []byte) {
+	t.Helper()
+	entity, err := openpgp.NewEntity(name, "", name+"@example.com", nil)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, entity.Serialize(&buf))
+	return entity, buf.Bytes()
+}
+
+// testArmor armors binary key data
+func testArmor(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := armor.Encode(&buf, openpgp.PublicKeyType, nil)
+	require.NoError(t, err)
+	_, err = w.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	buf.WriteString("\n")
+	return buf.Bytes()
+}
+
+// testKeybox builds a GnuPG keybox with a header blob and one OpenPGP blob per keyblock
+func testKeybox(keyblocks ...[]byte) []byte {
+	var kbx bytes.Buffer
+
+	header := make([]byte, 32)
+	binary.BigEndian.PutUint32(header, 32)
+	header[4] = keyboxBlobHeader
+	header[5] = 1
+	copy(header[8:], keyboxMagic)
+	kbx.Write(header)
+
+	for _, keyblock := range keyblocks {
+		const fixed = 20 + 28 // fixed part and one key info
+		blob := make([]byte, fixed+len(keyblock))
+		binary.BigEndian.PutUint32(blob, uint32(len(blob)))
+		blob[4] = keyboxBlobOpenPGP
+		blob[5] = 1
+		binary.BigEndian.PutUint32(blob[8:], fixed)
+		binary.BigEndian.PutUint32(blob[12:], uint32(len(keyblock)))
+		binary.BigEndian.PutUint16(blob[16:], 1)
+		binary.BigEndian.PutUint16(blob[18:], 28)
+		copy(blob[fixed:], keyblock)
+		kbx.Write(blob)
+	}
+
+	return kbx.Bytes()
+}
+
+func TestReadKeyData(t *testing.T) {
+	keyA, binaryA := testKey(t, "a")
+	keyB, binaryB := testKey(t, "b")
+
+	tests := []struct {
+		name   string
+		data   []byte
+		format KeyFormat
+		want   []*openpgp.Entity
+	}{
+		{
+			name:   "binary",
+			data:   append(append([]byte{}, binaryA...), binaryB...),
+			format: KeyFormatBinary,
+			want:   []*openpgp.Entity{keyA, keyB},
+		},
+		{
+			name:   "single armored block",
+			data:   testArmor(t, binaryA),
+			format: KeyFormatArmored,
+			want:   []*openpgp.Entity{keyA},
+		},
+		{
+			name:   "concatenated armored blocks",
+			data:   append(testArmor(t, binaryA), testArmor(t, binaryB)...),
+			format: KeyFormatArmored,
+			want:   []*openpgp.Entity{keyA, keyB},
+		},
+		{
+			name:   "keybox",
+			data:   testKeybox(binaryA, binaryB),
+			format: KeyFormatKeybox,
+			want:   []*openpgp.Entity{keyA, keyB},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.format, DetectKeyFormat(tt.data))
+
+			keys, err := ReadKeyData(tt.data)
+			require.NoError(t, err)
+			assert.Equal(t, KeyIDs(tt.want), KeyIDs(keys))
+		})
+	}
+}
+
+func TestReadKeyDataErrors(t *testing.T) {
+	_, binaryA := testKey(t, "a")
+
+	tests := []struct {
+		name      string
+		data      []byte
+		errSubstr string
+	}{
+		{
+			name:      "broken second armored block",
+			data:      append(testArmor(t, binaryA), []byte("-----BEGIN PGP PUBLIC KEY BLOCK-----\n\ngarbage\n-----END PGP PUBLIC KEY BLOCK-----\n")...),
+			errSubstr: "armored block 2",
+		},
+		{
+			name:      "truncated keybox",
+			data:      testKeybox(binaryA)[:40],
+			errSubstr: "keybox blob 2",
+		},
+		{
+			name:      "garbage",
+			data:      []byte("not a key"),
+			errSubstr: "binary keyring",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ReadKeyData(tt.data)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errSubstr)
+		})
+	}
+}
+
+func TestReadKeys(t *testing.T) {
+	keyA, binaryA := testKey(t, "a")
+	keyB, binaryB := testKey(t, "b")
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.gpg"), binaryA, 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "b.asc"), testArmor(t, binaryB), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".gitkeep"), nil, 0644))
+
+	t.Run("directory", func(t *testing.T) {
+		keys, err := ReadKeys(dir)
+		require.NoError(t, err)
+		assert.Equal(t, KeyIDs([]*openpgp.Entity{keyA, keyB}), KeyIDs(keys))
+	})
+
+	t.Run("file", func(t *testing.T) {
+		keys, err := ReadKeys(filepath.Join(dir, "b.asc"))
+		require.NoError(t, err)
+		assert.Equal(t, KeyIDs([]*openpgp.Entity{keyB}), KeyIDs(keys))
+	})
+
+	t.Run("error names the file", func(t *testing.T) {
+		broken := filepath.Join(dir, "broken.gpg")
+		require.NoError(t, os.WriteFile(broken, []byte("not a key"), 0644))
+		defer os.Remove(broken)
+
+		_, err := ReadKeys(dir)
+		require.ErrorIs(t, err, ErrKeyInvalid)
+		assert.Contains(t, err.Error(), broken)
+	})
+
+	t.Run("empty directory", func(t *testing.T) {
+		_, err := ReadKeys(t.TempDir())
+		require.ErrorIs(t, err, ErrKeyInvalid)
+	})
+}
+
+func TestDescribeKeys(t *testing.T) {
+	key, _ := testKey(t, "a")
+
+	infos := DescribeKeys(openpgp.EntityList{key})
+	require.Len(t, infos, 1)
+
+	info := infos[0]
+	assert.Equal(t, key.PrimaryKey.KeyIdString(), info.KeyID)
+	assert.Len(t, info.Fingerprint, 40)
+	assert.Equal(t, []string{"a <a@example.com>"}, info.UIDs)
+	assert.Equal(t, "RSA", info.Algorithm)
+	assert.Equal(t, 2048, info.Bits)
+	assert.False(t, info.Revoked)
+	assert.False(t, info.Expired(time.Now()))
+	assert.Len(t, info.Subkeys, 1)
+}
+
+func TestSignatureIssuers(t *testing.T) {
+	key, _ := testKey(t, "a")
+	other, _ := testKey(t, "b")
+
+	var signed bytes.Buffer
+	w, err := clearsign.Encode(&signed, key.PrivateKey, nil)
+	require.NoError(t, err)
+	_, err = w.Write([]byte("Origin: test\n"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	issuers, err := SignatureIssuers(signed.Bytes())
+	require.NoError(t, err)
+	require.Len(t, issuers, 1)
+
+	infos := DescribeKeys(openpgp.EntityList{other, key})
+	found, ok := FindKey(infos, issuers[0])
+	require.True(t, ok)
+	assert.Equal(t, key.PrimaryKey.KeyIdString(), found.KeyID)
+
+	_, ok = FindKey(DescribeKeys(openpgp.EntityList{other}), issuers[0])
+	assert.False(t, ok)
+
+	_, err = SignatureIssuers([]byte("Origin: test\n"))
+	assert.ErrorIs(t, err, ErrMissingSignature)
+}
+
+func TestCrossSign(t *testing.T) {
+	oldKey, _ := testKey(t, "old")
+	newKey, _ := testKey(t, "new")
+	require.NoError(t, CrossSign(oldKey, newKey))
+
+	// Each user ID carries a certification by the other key, also after serialization
+	for _, pair := range [][2]*openpgp.Entity{{oldKey, newKey}, {newKey, oldKey}} {
+		key, signer := pair[0], pair[1]
+
+		var buf bytes.Buffer
+		require.NoError(t, key.Serialize(&buf))
+		keys, err := ReadKeyData(buf.Bytes())
+		require.NoError(t, err)
+		require.Len(t, keys, 1)
+
+		for name, identity := range keys[0].Identities {
+			var certifications int
+			for _, sig := range identity.Signatures {
+				if sig.IssuerKeyId != nil && *sig.IssuerKeyId == signer.PrimaryKey.KeyId {
+					certifications++
+					assert.NoError(t, signer.PrimaryKey.VerifyUserIdSignature(name, keys[0].PrimaryKey, sig))
+				}
+			}
+			assert.Equal(t, 1, certifications, name)
+		}
+	}
+}
+
+func TestWriteArmored(t *testing.T) {
+	entity, data := testKey(t, "armored")
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteArmored(&buf, openpgp.PublicKeyType, entity.Serialize))
+	assert.Equal(t, testArmor(t, data), buf.Bytes())
+
+	keys, err := ReadKeyData(buf.Bytes())
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	assert.Equal(t, entity.PrimaryKey.Fingerprint, keys[0].PrimaryKey.Fingerprint)
+}
@@ -0,0 +1,290 @@
+package debext
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ErrPDiffTooLarge is returned if two index versions differ in more lines than allowed for a patch
+var ErrPDiffTooLarge = errors.New("index changes too large for a patch")
+
+// PDiffFile is the SHA256 checksum and size of a file listed in a pdiff Index
+type PDiffFile struct {
+	SHA256 string
+	Size   int64
+}
+
+// PDiffEntry is a patch of a pdiff Index
+type PDiffEntry struct {
+	Name     string    // Patch name without extension, the download has ".gz" appended
+	History  PDiffFile // Index version the patch applies to
+	Patch    PDiffFile // Uncompressed patch
+	Download PDiffFile // Gzip compressed patch
+}
+
+// PDiffIndex is the Index file of an <index>.diff directory read by apt with Acquire::PDiffs
+// Patches are applied in order, starting with the entry whose history matches the local index
+type PDiffIndex struct {
+	Current PDiffFile    // Current version of the index
+	Entries []PDiffEntry // Patches, oldest first
+}
+
+// ParsePDiffIndex parses a pdiff Index, only SHA256 checksums are read
+func ParsePDiffIndex(r io.Reader) (*PDiffIndex, error) {
+	index := &PDiffIndex{}
+	sections := make(map[string][][3]string)
+
+	var section string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+
+		// Continuation lines list the files of the current section
+		if strings.HasPrefix(line, " ") {
+			fields := strings.Fields(line)
+			if len(fields) != 3 {
+				return nil, fmt.Errorf("invalid pdiff index line: %q", line)
+			}
+			sections[section] = append(sections[section], [3]string{fields[0], fields[1], fields[2]})
+			continue
+		}
+
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			return nil, fmt.Errorf("invalid pdiff index line: %q", line)
+		}
+		section = key
+
+		if key == "SHA256-Current" {
+			fields := strings.Fields(value)
+			if len(fields) != 2 {
+				return nil, fmt.Errorf("invalid pdiff index line: %q", line)
+			}
+			size, err := strconv.ParseInt(fields[1], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pdiff index line: %q", line)
+			}
+			index.Current = PDiffFile{SHA256: fields[0], Size: size}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	// Entries are keyed by the patch name in every section
+	entries := make(map[string]*PDiffEntry)
+	for _, f := range sections["SHA256-History"] {
+		index.Entries = append(index.Entries, PDiffEntry{Name: f[2]})
+	}
+	for i := range index.Entries {
+		entries[index.Entries[i].Name] = &index.Entries[i]
+	}
+
+	for _, key := range []string{"SHA256-History", "SHA256-Patches", "SHA256-Download"} {
+		for _, f := range sections[key] {
+			size, err := strconv.ParseInt(f[1], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid size in %s: %q", key, f[1])
+			}
+			name := f[2]
+			if key == "SHA256-Download" {
+				name = strings.TrimSuffix(name, ".gz")
+			}
+			entry, ok := entries[name]
+			if !ok {
+				return nil, fmt.Errorf("%s lists unknown patch %s", key, f[2])
+			}
+
+			file := PDiffFile{SHA256: f[0], Size: size}
+			switch key {
+			case "SHA256-History":
+				entry.History = file
+			case "SHA256-Patches":
+				entry.Patch = file
+			case "SHA256-Download":
+				entry.Download = file
+			}
+		}
+	}
+
+	return index, nil
+}
+
+// GeneratePDiffIndex writes a pdiff Index in the format of dak, without merged patches
+func GeneratePDiffIndex(w io.Writer, index *PDiffIndex) error {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "SHA256-Current: %s %d\n", index.Current.SHA256, index.Current.Size)
+
+	buf.WriteString("SHA256-History:\n")
+	for _, entry := range index.Entries {
+		fmt.Fprintf(&buf, " %s %7d %s\n", entry.History.SHA256, entry.History.Size, entry.Name)
+	}
+	buf.WriteString("SHA256-Patches:\n")
+	for _, entry := range index.Entries {
+		fmt.Fprintf(&buf, " %s %7d %s\n", entry.Patch.SHA256, entry.Patch.Size, entry.Name)
+	}
+	buf.WriteString("SHA256-Download:\n")
+	for _, entry := range index.Entries {
+		fmt.Fprintf(&buf, " %s %7d %s.gz\n", entry.Download.SHA256, entry.Download.Size, entry.Name)
+	}
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// GenerateEdDiff returns the ed script turning old into new, as written by "diff --ed" and applied by apt
+// Returns ErrPDiffTooLarge if more than maxEdits lines are added or removed
+func GenerateEdDiff(old, new []byte, maxEdits int) ([]byte, error) {
+	a, b := splitLines(old), splitLines(new)
+	removed, added, ok := diffLines(a, b, maxEdits)
+	if !ok {
+		return nil, ErrPDiffTooLarge
+	}
+
+	// Group the edits into hunks, unchanged lines are in the same order in both versions
+	type hunk struct{ oldStart, oldEnd, newStart, newEnd int }
+	var hunks []hunk
+	for i, j := 0, 0; i < len(a) || j < len(b); {
+		if i < len(a) && j < len(b) && !removed[i] && !added[j] {
+			i++
+			j++
+			continue
+		}
+		h := hunk{oldStart: i, newStart: j}
+		for (i < len(a) && removed[i]) || (j < len(b) && added[j]) {
+			for i < len(a) && removed[i] {
+				i++
+			}
+			for j < len(b) && added[j] {
+				j++
+			}
+		}
+		h.oldEnd, h.newEnd = i, j
+		hunks = append(hunks, h)
+	}
+
+	// Commands are applied from the end, so line numbers of earlier hunks stay valid
+	var buf bytes.Buffer
+	for k := len(hunks) - 1; k >= 0; k-- {
+		h := hunks[k]
+		switch {
+		case h.oldStart == h.oldEnd:
+			fmt.Fprintf(&buf, "%da\n", h.oldStart)
+		case h.newStart == h.newEnd:
+			fmt.Fprintf(&buf, "%sd\n", lineRange(h.oldStart, h.oldEnd))
+			continue
+		default:
+			fmt.Fprintf(&buf, "%sc\n", lineRange(h.oldStart, h.oldEnd))
+		}
+		for _, line := range b[h.newStart:h.newEnd] {
+			buf.WriteString(line)
+			buf.WriteByte('\n')
+		}
+		buf.WriteString(".\n")
+	}
+
+	return buf.Bytes(), nil
+}
+
+// lineRange formats the 0-based lines [start, end) as 1-based ed address
+func lineRange(start, end int) string {
+	if end-start == 1 {
+		return strconv.Itoa(end)
+	}
+	return fmt.Sprintf("%d,%d", start+1, end)
+}
+
+// splitLines splits newline terminated content into lines without their newline
+func splitLines(data []byte) []string {
+	lines := strings.Split(string(data), "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffLines computes a shortest edit script between a and b with the Myers algorithm
+// Returns which lines of a are removed and which lines of b are added, ok is false above maxEdits
+func diffLines(a, b []string, maxEdits int) (removed, added []bool, ok bool) {
+	// Compare line ids instead of strings
+	ids := make(map[string]int32)
+	toIDs := func(lines []string) []int32 {
+		out := make([]int32, len(lines))
+		for i, line := range lines {
+			id, found := ids[line]
+			if !found {
+				id = int32(len(ids))
+				ids[line] = id
+			}
+			out[i] = id
+		}
+		return out
+	}
+	x, y := toIDs(a), toIDs(b)
+	n, m := len(x), len(y)
+
+	// trace[d] holds the furthest reaching x per diagonal k in [-d, d] after d edits, at index k+d
+	var trace [][]int32
+	found := false
+	for d := 0; d <= maxEdits && !found; d++ {
+		next := make([]int32, 2*d+1)
+		for k := -d; k <= d; k += 2 {
+			var px int32
+			switch {
+			case d == 0:
+				px = 0
+			case k == -d || (k != d && trace[d-1][k-1+d-1] < trace[d-1][k+1+d-1]):
+				px = trace[d-1][k+1+d-1] // Insertion, down from diagonal k+1
+			default:
+				px = trace[d-1][k-1+d-1] + 1 // Deletion, right from diagonal k-1
+			}
+			py := px - int32(k)
+			for int(px) < n && int(py) < m && x[px] == y[py] {
+				px++
+				py++
+			}
+			next[k+d] = px
+			if int(px) >= n && int(py) >= m {
+				found = true
+			}
+		}
+		trace = append(trace, next)
+	}
+	if !found {
+		return nil, nil, false
+	}
+
+	// Walk back from the end, every step of d is one removed or added line
+	removed, added = make([]bool, n), make([]bool, m)
+	px, py := n, m
+	for d := len(trace) - 1; d > 0; d-- {
+		prev := trace[d-1]
+		get := func(k int) int32 { return prev[k+d-1] }
+		k := px - py
+		var prevK int
+		if k == -d || (k != d && get(k-1) < get(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := int(get(prevK))
+		prevY := prevX - prevK
+
+		if prevK == k+1 {
+			added[prevY] = true
+		} else {
+			removed[prevX] = true
+		}
+		px, py = prevX, prevY
+	}
+
+	return removed, added, true
+}
@@ -0,0 +1,129 @@
+package debext
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// applyEdDiff applies an ed script like apt's rred method
+func applyEdDiff(t *testing.T, old, script []byte) []byte {
+	t.Helper()
+	lines := splitLines(old)
+	cmds := splitLines(script)
+
+	for i := 0; i < len(cmds); i++ {
+		cmd := cmds[i]
+		op := cmd[len(cmd)-1]
+		start, end := 0, 0
+		addr := cmd[:len(cmd)-1]
+		if from, to, found := strings.Cut(addr, ","); found {
+			start, _ = strconv.Atoi(from)
+			end, _ = strconv.Atoi(to)
+		} else {
+			start, _ = strconv.Atoi(addr)
+			end = start
+		}
+
+		var text []string
+		if op != 'd' {
+			for i++; cmds[i] != "."; i++ {
+				text = append(text, cmds[i])
+			}
+		}
+
+		var head, tail []string
+		switch op {
+		case 'a':
+			head, tail = lines[:start], lines[start:]
+		case 'c', 'd':
+			head, tail = lines[:start-1], lines[end:]
+		default:
+			t.Fatalf("unknown ed command %q", cmd)
+		}
+		lines = append(append(append([]string{}, head...), text...), tail...)
+	}
+
+	if len(lines) == 0 {
+		return nil
+	}
+	return []byte(strings.Join(lines, "\n") + "\n")
+}
+
+func TestGenerateEdDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		old  string
+		new  string
+	}{
+		{name: "identical", old: "a\nb\nc\n", new: "a\nb\nc\n"},
+		{name: "append", old: "a\nb\n", new: "a\nb\nc\nd\n"},
+		{name: "prepend", old: "b\nc\n", new: "a\nb\nc\n"},
+		{name: "remove", old: "a\nb\nc\nd\n", new: "a\nd\n"},
+		{name: "change", old: "a\nb\nc\n", new: "a\nx\nc\n"},
+		{name: "from empty", old: "", new: "a\nb\n"},
+		{name: "to empty", old: "a\nb\n", new: ""},
+		{
+			name: "packages stanzas",
+			old:  "Package: foo\nVersion: 1.0\n\nPackage: bar\nVersion: 2.0\n\nPackage: baz\nVersion: 3.0\n",
+			new:  "Package: foo\nVersion: 1.1\n\nPackage: baz\nVersion: 3.0\n\nPackage: qux\nVersion: 1.0\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			script, err := GenerateEdDiff([]byte(tt.old), []byte(tt.new), 100)
+			require.NoError(t, err)
+			assert.Equal(t, tt.new, string(applyEdDiff(t, []byte(tt.old), script)))
+		})
+	}
+
+	t.Run("commands in reverse order", func(t *testing.T) {
+		script, err := GenerateEdDiff([]byte("a\nb\nc\nd\n"), []byte("x\nb\nc\n"), 100)
+		require.NoError(t, err)
+		assert.Equal(t, "4d\n1c\nx\n.\n", string(script))
+	})
+
+	t.Run("too many edits", func(t *testing.T) {
+		_, err := GenerateEdDiff([]byte("a\nb\nc\n"), []byte("x\ny\nz\n"), 2)
+		assert.ErrorIs(t, err, ErrPDiffTooLarge)
+	})
+}
+
+func TestPDiffIndex(t *testing.T) {
+	index := &PDiffIndex{
+		Current: PDiffFile{SHA256: strings.Repeat("c", 64), Size: 300},
+		Entries: []PDiffEntry{
+			{
+				Name:     "2025-01-01-1200.00",
+				History:  PDiffFile{SHA256: strings.Repeat("a", 64), Size: 100},
+				Patch:    PDiffFile{SHA256: strings.Repeat("1", 64), Size: 10},
+				Download: PDiffFile{SHA256: strings.Repeat("2", 64), Size: 20},
+			},
+			{
+				Name:     "2025-01-02-1200.00",
+				History:  PDiffFile{SHA256: strings.Repeat("b", 64), Size: 200},
+				Patch:    PDiffFile{SHA256: strings.Repeat("3", 64), Size: 30},
+				Download: PDiffFile{SHA256: strings.Repeat("4", 64), Size: 40},
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, GeneratePDiffIndex(&buf, index))
+	assert.Contains(t, buf.String(), "SHA256-Current: "+strings.Repeat("c", 64)+" 300\n")
+	assert.Contains(t, buf.String(), " "+strings.Repeat("4", 64)+"      40 2025-01-02-1200.00.gz\n")
+
+	parsed, err := ParsePDiffIndex(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, index, parsed)
+
+	t.Run("unknown patch", func(t *testing.T) {
+		_, err := ParsePDiffIndex(strings.NewReader("SHA256-History:\nSHA256-Patches:\n " + strings.Repeat("1", 64) + " 10 missing\n"))
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,186 @@
+package debext
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/aptly-dev/aptly/deb"
+)
+
+// ErrRepositoryFormat is returned when a saved repository cannot be read
+var ErrRepositoryFormat = errors.New("unsupported repository file")
+
+const (
+	// repositoryFormat identifies the layout of saved repositories, bump on incompatible changes
+	repositoryFormat = "aarg-repository/1"
+
+	// Fields recording where a package is placed, stripped when loading
+	formatField       = "X-Aarg-Format"
+	distributionField = "X-Aarg-Distribution"
+	componentField    = "X-Aarg-Component"
+	typeField         = "X-Aarg-Type"
+)
+
+// Package types of saved stanzas
+const (
+	packageTypeBinary = "deb"
+	packageTypeUdeb   = "udeb"
+	packageTypeSource = "dsc"
+)
+
+// Save writes the repository as gzip compressed control stanzas to w
+// Each stanza is a package index entry extended by its distribution, component and type
+func (r *Repository) Save(w io.Writer) error {
+	gz := gzip.NewWriter(w)
+	bufWriter := bufio.NewWriter(gz)
+
+	header := deb.Stanza{formatField: repositoryFormat}
+	if err := header.WriteTo(bufWriter, false, false, false); err != nil {
+		return err
+	}
+	if err := bufWriter.WriteByte('\n'); err != nil {
+		return err
+	}
+
+	for _, dist := range r.GetDistributions() {
+		for _, comp := range r.GetComponents(dist) {
+			list := r.packages[dist][comp]
+			list.PrepareIndex()
+
+			err := list.ForEachIndexed(func(pkg *deb.Package) error {
+				stanza := pkg.Stanza()
+				stanza[distributionField] = dist
+				stanza[componentField] = comp
+				stanza[typeField] = packageType(pkg)
+
+				if err := stanza.WriteTo(bufWriter, pkg.IsSource, false, false); err != nil {
+					return err
+				}
+				return bufWriter.WriteByte('\n')
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := bufWriter.Flush(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// LoadRepository reads a repository written by Save from r
+func LoadRepository(r io.Reader) (*Repository, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrRepositoryFormat, err)
+	}
+	defer func() { _ = gz.Close() }()
+
+	controlReader := deb.NewControlFileReader(gz, false, false)
+
+	header, err := controlReader.ReadStanza()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrRepositoryFormat, err)
+	}
+	if header == nil || header[formatField] != repositoryFormat {
+		return nil, fmt.Errorf("%w: missing %s header", ErrRepositoryFormat, repositoryFormat)
+	}
+
+	repo := NewRepository()
+	for {
+		stanza, err := controlReader.ReadStanza()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read stanza: %w", err)
+		}
+		if stanza == nil {
+			break
+		}
+
+		dist := stanza[distributionField]
+		comp := stanza[componentField]
+		pkgType := stanza[typeField]
+		delete(stanza, distributionField)
+		delete(stanza, componentField)
+		delete(stanza, typeField)
+
+		var pkg *deb.Package
+		switch pkgType {
+		case packageTypeSource:
+			pkg, err = deb.NewSourcePackageFromControlFile(stanza)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse source package: %w", err)
+			}
+		case packageTypeUdeb:
+			pkg = deb.NewUdebPackageFromControlFile(stanza)
+		case packageTypeBinary:
+			pkg = deb.NewPackageFromControlFile(stanza)
+		default:
+			return nil, fmt.Errorf("%w: unknown package type %q", ErrRepositoryFormat, pkgType)
+		}
+
+		if err := repo.AddPackage(pkg, dist, comp); err != nil {
+			return nil, err
+		}
+	}
+
+	return repo, nil
+}
+
+// SaveRepositoryFile writes the repository to path, replacing it atomically
+func SaveRepositoryFile(path string, repo *Repository) (err error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = os.Remove(tmp.Name())
+		}
+	}()
+
+	if err = repo.Save(tmp); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// LoadRepositoryFile reads a repository written by SaveRepositoryFile
+func LoadRepositoryFile(path string) (*Repository, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+
+	repo, err := LoadRepository(bufio.NewReader(file))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return repo, nil
+}
+
+// packageType returns the saved type of a package
+func packageType(pkg *deb.Package) string {
+	switch {
+	case pkg.IsSource:
+		return packageTypeSource
+	case pkg.IsUdeb:
+		return packageTypeUdeb
+	default:
+		return packageTypeBinary
+	}
+}
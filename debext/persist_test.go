@@ -0,0 +1,116 @@
+package debext
+
+import (
+	"bytes"
+	"compress/gzip"
+	"path/filepath"
+	"testing"
+
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepositorySaveLoad(t *testing.T) {
+	repo := NewRepository()
+
+	binary := deb.NewPackageFromControlFile(deb.Stanza{
+		"Package": "hello", "Version": "1.0-1", "Architecture": "amd64", "Source": "hello-src",
+		"Filename": "pool/main/h/hello-src/hello_1.0-1_amd64.deb", "Size": "1234",
+		"SHA256": "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
+	})
+	require.NoError(t, repo.AddPackage(binary, "noble", "main"))
+
+	udeb := deb.NewUdebPackageFromControlFile(deb.Stanza{
+		"Package": "hello-udeb", "Version": "1.0-1", "Architecture": "amd64",
+		"Filename": "pool/main/h/hello-src/hello-udeb_1.0-1_amd64.udeb", "Size": "10",
+	})
+	require.NoError(t, repo.AddPackage(udeb, "noble", "main"))
+
+	source, err := deb.NewSourcePackageFromControlFile(deb.Stanza{
+		"Package": "hello-src", "Version": "1.0-1", "Architecture": "any", "Directory": "pool/main/h/hello-src",
+		"Files": " 900150983cd24fb0d6963f7d28e17f72 3 hello-src_1.0-1.dsc",
+	})
+	require.NoError(t, err)
+	require.NoError(t, repo.AddPackage(source, "noble", "main"))
+
+	older := deb.NewPackageFromControlFile(deb.Stanza{
+		"Package": "hello", "Version": "0.9-1", "Architecture": "arm64",
+	})
+	require.NoError(t, repo.AddPackage(older, "jammy", "contrib"))
+
+	var buf bytes.Buffer
+	require.NoError(t, repo.Save(&buf))
+
+	loaded, err := LoadRepository(&buf)
+	require.NoError(t, err)
+
+	assert.Equal(t, repo.NumPackages(), loaded.NumPackages())
+	assert.Equal(t, []string{"jammy", "noble"}, loaded.GetDistributions())
+	assert.Equal(t, []string{"contrib"}, loaded.GetComponents("jammy"))
+	assert.ElementsMatch(t, repo.GetArchitectures("noble", "main", true), loaded.GetArchitectures("noble", "main", true))
+
+	pkg := loaded.GetLatest("hello", "noble", "amd64")
+	require.NotNil(t, pkg)
+	assert.Equal(t, "1.0-1", pkg.Version)
+	assert.Equal(t, "hello-src", GetSourceNameFromPackage(pkg))
+	require.Len(t, pkg.Files(), 1)
+	assert.Equal(t, binary.Files()[0].DownloadURL(), pkg.Files()[0].DownloadURL())
+	assert.Equal(t, binary.Files()[0].Checksums.SHA256, pkg.Files()[0].Checksums.SHA256)
+	_, hasPlacement := pkg.Stanza()[distributionField]
+	assert.False(t, hasPlacement)
+
+	udebPkg := loaded.GetLatest("hello-udeb", "noble", "amd64")
+	require.NotNil(t, udebPkg)
+	assert.True(t, udebPkg.IsUdeb)
+
+	sourcePkg := loaded.GetLatest("hello-src", "noble", SourceArchitecture)
+	require.NotNil(t, sourcePkg)
+	assert.True(t, sourcePkg.IsSource)
+	require.Len(t, sourcePkg.Files(), 1)
+	assert.Equal(t, "pool/main/h/hello-src/hello-src_1.0-1.dsc", sourcePkg.Files()[0].DownloadURL())
+}
+
+func TestRepositorySaveLoadFile(t *testing.T) {
+	repo := NewRepository()
+	require.NoError(t, repo.AddPackage(deb.NewPackageFromControlFile(deb.Stanza{
+		"Package": "hello", "Version": "1.0", "Architecture": "all",
+	}), "stable", "main"))
+
+	path := filepath.Join(t.TempDir(), "repository.gz")
+	require.NoError(t, SaveRepositoryFile(path, repo))
+
+	loaded, err := LoadRepositoryFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, 1, loaded.NumPackages())
+}
+
+func TestLoadRepository_Invalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		input func() []byte
+	}{
+		{
+			name:  "not compressed",
+			input: func() []byte { return []byte("Package: hello\n") },
+		},
+		{
+			name: "missing header",
+			input: func() []byte {
+				var buf bytes.Buffer
+				gz := gzip.NewWriter(&buf)
+				_, err := gz.Write([]byte("Package: hello\nVersion: 1.0\n\n"))
+				require.NoError(t, err)
+				require.NoError(t, gz.Close())
+				return buf.Bytes()
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadRepository(bytes.NewReader(tt.input()))
+			assert.ErrorIs(t, err, ErrRepositoryFormat)
+		})
+	}
+}
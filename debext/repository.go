@@ -176,6 +176,18 @@ func (r *Repository) GetLatest(packageName, distribution, arch string) *deb.Pack
 	return nil
 }
 
+// ForEachLatest calls fn with the latest package of every package name, distribution and architecture.
+// Packages are visited sorted by name, distribution and architecture.
+func (r *Repository) ForEachLatest(fn func(distribution, arch string, pkg *deb.Package)) {
+	for _, pkgName := range slices.Sorted(maps.Keys(r.latest)) {
+		for _, distribution := range slices.Sorted(maps.Keys(r.latest[pkgName])) {
+			for _, arch := range slices.Sorted(maps.Keys(r.latest[pkgName][distribution])) {
+				fn(distribution, arch, r.latest[pkgName][distribution][arch])
+			}
+		}
+	}
+}
+
 // GetPackageNamesForComponent returns unique package names that exist in the specified component
 // across any distribution. Returns a sorted slice of package names.
 func (r *Repository) GetPackageNames(component string) []string {
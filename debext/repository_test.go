@@ -149,3 +149,31 @@ func TestArchitectureAll(t *testing.T) {
 		return nil
 	})
 }
+
+func TestForEachLatest(t *testing.T) {
+	repo := NewRepository()
+	for _, entry := range []struct{ name, version, arch, dist string }{
+		{"b-pkg", "1.0", "amd64", "noble"},
+		{"a-pkg", "2.0", "amd64", "noble"},
+		{"a-pkg", "1.0", "amd64", "noble"},
+		{"a-pkg", "1.5", "arm64", "noble"},
+		{"a-pkg", "0.9", "amd64", "jammy"},
+	} {
+		pkg := deb.NewPackageFromControlFile(deb.Stanza{
+			"Package": entry.name, "Version": entry.version, "Architecture": entry.arch,
+		})
+		require.NoError(t, repo.AddPackage(pkg, entry.dist, ""))
+	}
+
+	var visited []string
+	repo.ForEachLatest(func(distribution, arch string, pkg *deb.Package) {
+		visited = append(visited, distribution+"/"+arch+"/"+pkg.Name+"_"+pkg.Version)
+	})
+
+	assert.Equal(t, []string{
+		"jammy/amd64/a-pkg_0.9",
+		"noble/amd64/a-pkg_2.0",
+		"noble/arm64/a-pkg_1.5",
+		"noble/amd64/b-pkg_1.0",
+	}, visited)
+}
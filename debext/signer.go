@@ -0,0 +1,254 @@
+package debext
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/clearsign"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/aptly-dev/aptly/pgp"
+)
+
+var (
+	// ErrSigningKeyNotFound is returned when no usable signing key matches a key reference
+	ErrSigningKeyNotFound = errors.New("signing key not found")
+	// ErrSigningKeyLocked is returned when a private key can't be unlocked
+	ErrSigningKeyLocked = errors.New("signing key is locked")
+)
+
+var _ pgp.Signer = &Signer{}
+
+// SigningKey is a private key signing Release files
+type SigningKey struct {
+	Path           string // Private key file or directory in any format supported by ReadKeys
+	KeyRef         string // Fingerprint or long key ID of the key or subkey to sign with, empty picks the first key able to sign
+	Passphrase     string // Passphrase of an encrypted key
+	PassphraseFile string // File containing the passphrase, used if Passphrase is empty
+}
+
+// Signer signs files with one or more private keys and implements aptly's pgp.Signer
+// Every key adds a signature to InRelease and Release.gpg, so during a key rotation clients trusting
+// either the old or the new key keep verifying. The Set methods of pgp.Signer configure the first key
+type Signer struct {
+	Keys []SigningKey
+
+	signers []openpgp.Key // Unlocked keys selected by Init
+	config  *packet.Config
+}
+
+// SetKey sets the key reference of the first key
+func (s *Signer) SetKey(keyRef string) {
+	s.first().KeyRef = keyRef
+}
+
+// SetKeyRing sets the private key file of the first key, public keys are taken from the private keys
+func (s *Signer) SetKeyRing(_, secretKeyring string) {
+	s.first().Path = secretKeyring
+}
+
+// SetPassphrase sets the passphrase of the first key
+func (s *Signer) SetPassphrase(passphrase, passphraseFile string) {
+	key := s.first()
+	key.Passphrase, key.PassphraseFile = passphrase, passphraseFile
+}
+
+// SetBatch is a no-op, keys are never unlocked interactively
+func (s *Signer) SetBatch(bool) {}
+
+// first returns the first key, adding it if there are no keys yet
+func (s *Signer) first() *SigningKey {
+	if len(s.Keys) == 0 {
+		s.Keys = append(s.Keys, SigningKey{})
+	}
+	return &s.Keys[0]
+}
+
+// Init reads and unlocks the signing keys
+func (s *Signer) Init() error {
+	if len(s.Keys) == 0 {
+		return fmt.Errorf("%w: no keys configured", ErrSigningKeyNotFound)
+	}
+
+	s.config = &packet.Config{}
+	s.signers = nil
+	now := time.Now()
+	for _, key := range s.Keys {
+		signer, err := key.load(now)
+		if err != nil {
+			return err
+		}
+		for _, other := range s.signers {
+			if other.PrivateKey.KeyId == signer.PrivateKey.KeyId {
+				return fmt.Errorf("key %s is configured twice", signer.PrivateKey.KeyIdString())
+			}
+		}
+		s.signers = append(s.signers, signer)
+	}
+	return nil
+}
+
+// load reads the key file and returns the unlocked (sub)key selected by the key reference
+func (k SigningKey) load(now time.Time) (openpgp.Key, error) {
+	keys, err := ReadKeys(k.Path)
+	if err != nil {
+		return openpgp.Key{}, err
+	}
+
+	key, err := selectSigningKey(keys, k.KeyRef, now)
+	if err != nil {
+		return openpgp.Key{}, fmt.Errorf("%s: %w", k.Path, err)
+	}
+
+	if signer := key.PrivateKey; signer.Encrypted {
+		passphrase := k.Passphrase
+		if passphrase == "" && k.PassphraseFile != "" {
+			data, err := os.ReadFile(k.PassphraseFile)
+			if err != nil {
+				return openpgp.Key{}, fmt.Errorf("failed to read passphrase file: %w", err)
+			}
+			passphrase = strings.TrimSpace(string(data))
+		}
+		if passphrase == "" {
+			return openpgp.Key{}, fmt.Errorf("%w: key %s requires a passphrase", ErrSigningKeyLocked, signer.KeyIdString())
+		}
+		if err := signer.Decrypt([]byte(passphrase)); err != nil {
+			return openpgp.Key{}, fmt.Errorf("%w: key %s: %w", ErrSigningKeyLocked, signer.KeyIdString(), err)
+		}
+	}
+	return key, nil
+}
+
+// selectSigningKey returns the private key or subkey matching the key reference
+// Without reference the first key able to sign is used, preferring its newest signing subkey
+func selectSigningKey(keys openpgp.EntityList, keyRef string, now time.Time) (openpgp.Key, error) {
+	for _, entity := range keys {
+		if entity.PrivateKey == nil {
+			continue
+		}
+
+		var id uint64
+		if keyRef != "" {
+			key, ok := matchingKey(entity, keyRef)
+			if !ok {
+				continue
+			}
+			id = key.KeyId
+		}
+
+		signing, ok := entity.SigningKeyById(now, id)
+		if !ok || signing.PrivateKey == nil {
+			if keyRef != "" {
+				return openpgp.Key{}, fmt.Errorf("%w: key %s can't sign, it is expired, revoked or not a signing key", ErrSigningKeyNotFound, keyRef)
+			}
+			continue
+		}
+		return signing, nil
+	}
+
+	if keyRef != "" {
+		return openpgp.Key{}, fmt.Errorf("%w: no private key %s", ErrSigningKeyNotFound, keyRef)
+	}
+	return openpgp.Key{}, fmt.Errorf("%w: no private key able to sign", ErrSigningKeyNotFound)
+}
+
+// matchingKey returns the primary key or subkey of entity whose fingerprint or key ID is the key reference
+// Spaces and 0x prefixes of the key reference are ignored
+func matchingKey(entity *openpgp.Entity, keyRef string) (*packet.PublicKey, bool) {
+	ref := strings.TrimPrefix(strings.ToUpper(strings.ReplaceAll(keyRef, " ", "")), "0X")
+	matches := func(key *packet.PublicKey) bool {
+		return describeKey(key, nil).Fingerprint == ref || strings.ToUpper(key.KeyIdString()) == ref
+	}
+
+	if matches(entity.PrimaryKey) {
+		return entity.PrimaryKey, true
+	}
+	for _, subkey := range entity.Subkeys {
+		if matches(subkey.PublicKey) {
+			return subkey.PublicKey, true
+		}
+	}
+	return nil, false
+}
+
+// DetachedSign writes the ASCII-armored signatures of all keys of source to destination
+func (s *Signer) DetachedSign(source string, destination string) error {
+	message, err := os.ReadFile(source)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", source, err)
+	}
+
+	// Release.gpg holds one signature packet per key in a single armored block
+	var signatures bytes.Buffer
+	for _, signer := range s.signers {
+		config := *s.config
+		config.SigningKeyId = signer.PrivateKey.KeyId
+		if err := openpgp.DetachSign(&signatures, signer.Entity, bytes.NewReader(message), &config); err != nil {
+			return fmt.Errorf("failed to sign %s with key %s: %w", source, signer.PrivateKey.KeyIdString(), err)
+		}
+	}
+
+	return writeArmored(destination, signatures.Bytes())
+}
+
+// writeArmored writes binary signature packets as an ASCII-armored signature file
+func writeArmored(destination string, signatures []byte) error {
+	file, err := os.Create(destination)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", destination, err)
+	}
+	defer func() { _ = file.Close() }()
+
+	w, err := armor.Encode(file, openpgp.SignatureType, nil)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(signatures); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	if _, err := file.WriteString("\n"); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// ClearSign writes source clearsigned by all keys to destination
+func (s *Signer) ClearSign(source string, destination string) error {
+	message, err := os.Open(source)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", source, err)
+	}
+	defer func() { _ = message.Close() }()
+
+	file, err := os.Create(destination)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", destination, err)
+	}
+	defer func() { _ = file.Close() }()
+
+	privateKeys := make([]*packet.PrivateKey, 0, len(s.signers))
+	for _, signer := range s.signers {
+		privateKeys = append(privateKeys, signer.PrivateKey)
+	}
+	stream, err := clearsign.EncodeMulti(file, privateKeys, s.config)
+	if err != nil {
+		return fmt.Errorf("failed to clearsign %s: %w", source, err)
+	}
+	if _, err := io.Copy(stream, message); err != nil {
+		_ = stream.Close()
+		return fmt.Errorf("failed to clearsign %s: %w", source, err)
+	}
+	if err := stream.Close(); err != nil {
+		return fmt.Errorf("failed to clearsign %s: %w", source, err)
+	}
+	return file.Close()
+}
@@ -0,0 +1,160 @@
+package debext
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/clearsign"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testPrivateKey creates a private key with a signing subkey and writes it to a file, encrypted if passphrase is set
+func testPrivateKey(t *testing.T, name, passphrase string) (*openpgp.Entity, string) {
+	t.Helper()
+	entity, err := openpgp.NewEntity(name, "", name+"@example.com", nil)
+	require.NoError(t, err)
+	require.NoError(t, entity.AddSigningSubkey(nil))
+	if passphrase != "" {
+		require.NoError(t, entity.EncryptPrivateKeys([]byte(passphrase), nil))
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, entity.SerializePrivateWithoutSigning(&buf, nil))
+	path := filepath.Join(t.TempDir(), name+".gpg")
+	require.NoError(t, os.WriteFile(path, buf.Bytes(), 0600))
+	return entity, path
+}
+
+// testFingerprint returns the fingerprint of the primary key or of subkey i
+func testFingerprint(entity *openpgp.Entity, subkey int) string {
+	if subkey < 0 {
+		return describeKey(entity.PrimaryKey, nil).Fingerprint
+	}
+	return describeKey(entity.Subkeys[subkey].PublicKey, nil).Fingerprint
+}
+
+func TestSigner_KeySelection(t *testing.T) {
+	entity, path := testPrivateKey(t, "archive", "")
+	signingSubkey := testFingerprint(entity, 1) // Subkey 0 is the encryption subkey of NewEntity
+
+	tests := []struct {
+		name       string
+		keyRef     string
+		wantIssuer string
+		wantErr    error
+	}{
+		{name: "default prefers signing subkey", wantIssuer: signingSubkey},
+		{name: "primary key by fingerprint", keyRef: testFingerprint(entity, -1), wantIssuer: testFingerprint(entity, -1)},
+		{name: "subkey by fingerprint", keyRef: strings.ToLower(signingSubkey), wantIssuer: signingSubkey},
+		{name: "subkey by key ID", keyRef: "0x" + entity.Subkeys[1].PublicKey.KeyIdString(), wantIssuer: signingSubkey},
+		{name: "encryption subkey can't sign", keyRef: testFingerprint(entity, 0), wantErr: ErrSigningKeyNotFound},
+		{name: "unknown key", keyRef: "0123456789ABCDEF", wantErr: ErrSigningKeyNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signer := &Signer{}
+			signer.SetKeyRing("", path)
+			signer.SetKey(tt.keyRef)
+
+			err := signer.Init()
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+
+			dir := t.TempDir()
+			release := filepath.Join(dir, "Release")
+			require.NoError(t, os.WriteFile(release, []byte("Origin: test\nSuite: stable\n"), 0644))
+			require.NoError(t, signer.ClearSign(release, filepath.Join(dir, "InRelease")))
+
+			data, err := os.ReadFile(filepath.Join(dir, "InRelease"))
+			require.NoError(t, err)
+			issuers, err := SignatureIssuers(data)
+			require.NoError(t, err)
+			assert.Equal(t, []string{tt.wantIssuer}, issuers)
+		})
+	}
+}
+
+func TestSigner_Passphrase(t *testing.T) {
+	_, path := testPrivateKey(t, "locked", "secret")
+
+	tests := []struct {
+		name       string
+		passphrase string
+		wantErr    error
+	}{
+		{name: "unlocked with passphrase", passphrase: "secret"},
+		{name: "missing passphrase", wantErr: ErrSigningKeyLocked},
+		{name: "wrong passphrase", passphrase: "wrong", wantErr: ErrSigningKeyLocked},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signer := &Signer{Keys: []SigningKey{{Path: path, Passphrase: tt.passphrase}}}
+			err := signer.Init()
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestSigner_MultipleKeys(t *testing.T) {
+	oldKey, oldPath := testPrivateKey(t, "old", "")
+	newKey, newPath := testPrivateKey(t, "new", "secret")
+
+	signer := &Signer{Keys: []SigningKey{
+		{Path: oldPath},
+		{Path: newPath, KeyRef: testFingerprint(newKey, -1), Passphrase: "secret"},
+	}}
+	require.NoError(t, signer.Init())
+
+	dir := t.TempDir()
+	release := filepath.Join(dir, "Release")
+	content := []byte("Origin: test\nSuite: stable\n")
+	require.NoError(t, os.WriteFile(release, content, 0644))
+	require.NoError(t, signer.ClearSign(release, filepath.Join(dir, "InRelease")))
+	require.NoError(t, signer.DetachedSign(release, filepath.Join(dir, "Release.gpg")))
+
+	inRelease, err := os.ReadFile(filepath.Join(dir, "InRelease"))
+	require.NoError(t, err)
+	issuers, err := SignatureIssuers(inRelease)
+	require.NoError(t, err)
+	assert.Equal(t, []string{testFingerprint(oldKey, 1), testFingerprint(newKey, -1)}, issuers)
+
+	block, _ := clearsign.Decode(inRelease)
+	require.NotNil(t, block)
+	assert.Equal(t, content, block.Plaintext)
+
+	releaseGpg, err := os.ReadFile(filepath.Join(dir, "Release.gpg"))
+	require.NoError(t, err)
+
+	// Clients trusting either key verify both files
+	for _, key := range []*openpgp.Entity{oldKey, newKey} {
+		keyring := openpgp.EntityList{key}
+
+		block, _ := clearsign.Decode(inRelease)
+		_, err := block.VerifySignature(keyring, nil)
+		assert.NoError(t, err, key.PrimaryKey.KeyIdString())
+
+		_, err = openpgp.CheckArmoredDetachedSignature(keyring, bytes.NewReader(content), bytes.NewReader(releaseGpg), nil)
+		assert.NoError(t, err, key.PrimaryKey.KeyIdString())
+	}
+}
+
+func TestSigner_DuplicateKey(t *testing.T) {
+	_, path := testPrivateKey(t, "archive", "")
+
+	signer := &Signer{Keys: []SigningKey{{Path: path}, {Path: path}}}
+	assert.Error(t, signer.Init())
+}
@@ -0,0 +1,85 @@
+package debext
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// ErrCosignFailed is returned when running cosign fails
+var ErrCosignFailed = errors.New("cosign failed")
+
+// SigstoreBundleExtension is appended to the name of a file for its sigstore bundle
+const SigstoreBundleExtension = ".sigstore"
+
+// SigstoreSigner signs files with sigstore by running cosign sign-blob
+// The bundle written next to a file carries the signature, the certificate (keyless) and the proof of the
+// transparency log entry, consumers verify it with cosign verify-blob --bundle beyond the GPG signatures
+type SigstoreSigner struct {
+	Cosign     string // cosign binary, empty = cosign from PATH
+	Key        string // cosign private key file or KMS URI, empty = keyless signing with an OIDC identity
+	Passphrase string // Passphrase of Key
+	FulcioURL  string // Fulcio instance issuing keyless certificates, empty = public sigstore instance
+	RekorURL   string // Rekor transparency log, empty = public sigstore instance
+	SkipTlog   bool   // Don't upload to the transparency log, bundles then only carry the signature
+}
+
+// Init checks that cosign is available
+func (s *SigstoreSigner) Init() error {
+	if s.Cosign == "" {
+		s.Cosign = "cosign"
+	}
+	if _, err := exec.LookPath(s.Cosign); err != nil {
+		return fmt.Errorf("%w: %w", ErrCosignFailed, err)
+	}
+	return nil
+}
+
+// SignBlob writes the sigstore bundle of source to bundle
+// Keyless signing takes the OIDC identity token from SIGSTORE_ID_TOKEN or the CI environment
+func (s *SigstoreSigner) SignBlob(source, bundle string) error {
+	args := []string{"sign-blob", "--yes", "--bundle", bundle, "--output-signature", os.DevNull}
+	if s.Key != "" {
+		args = append(args, "--key", s.Key)
+	}
+	if s.FulcioURL != "" {
+		args = append(args, "--fulcio-url", s.FulcioURL)
+	}
+	if s.RekorURL != "" {
+		args = append(args, "--rekor-url", s.RekorURL)
+	}
+	if s.SkipTlog {
+		args = append(args, "--tlog-upload=false")
+	}
+	args = append(args, source)
+
+	if _, err := s.run(args...); err != nil {
+		return fmt.Errorf("failed to sign %s: %w", source, err)
+	}
+	return nil
+}
+
+// PublicKey returns the public key of Key in PEM format, nil for keyless signing
+func (s *SigstoreSigner) PublicKey() ([]byte, error) {
+	if s.Key == "" {
+		return nil, nil
+	}
+	return s.run("public-key", "--key", s.Key)
+}
+
+// run runs cosign non-interactively and returns its output, errors carry the output of cosign
+func (s *SigstoreSigner) run(args ...string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(s.Cosign, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	// cosign prompts for a passphrase unless COSIGN_PASSWORD is set, also if empty
+	cmd.Env = append(os.Environ(), "COSIGN_PASSWORD="+s.Passphrase)
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%w: %w: %s", ErrCosignFailed, err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
@@ -0,0 +1,96 @@
+package debext
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testCosign writes a fake cosign recording its arguments and COSIGN_PASSWORD and writing the bundle
+func testCosign(t *testing.T) (string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	record := filepath.Join(dir, "args")
+	script := `#!/bin/sh
+echo "$COSIGN_PASSWORD|$*" > ` + record + `
+case "$1" in
+sign-blob) while [ $# -gt 0 ]; do [ "$1" = "--bundle" ] && echo '{"mediaType":"bundle"}' > "$2"; shift; done ;;
+public-key) echo "-----BEGIN PUBLIC KEY-----" ;;
+esac
+`
+	cosign := filepath.Join(dir, "cosign")
+	require.NoError(t, os.WriteFile(cosign, []byte(script), 0755))
+	return cosign, record
+}
+
+func TestSigstoreSigner_SignBlob(t *testing.T) {
+	cosign, record := testCosign(t)
+
+	tests := []struct {
+		name     string
+		signer   SigstoreSigner
+		wantArgs string
+	}{
+		{
+			name:     "keyless",
+			wantArgs: "|sign-blob --yes --bundle %[1]s --output-signature /dev/null %[2]s",
+		},
+		{
+			name:     "key with private instances",
+			signer:   SigstoreSigner{Key: "cosign.key", Passphrase: "secret", FulcioURL: "https://fulcio.example.com", RekorURL: "https://rekor.example.com"},
+			wantArgs: "secret|sign-blob --yes --bundle %[1]s --output-signature /dev/null --key cosign.key --fulcio-url https://fulcio.example.com --rekor-url https://rekor.example.com %[2]s",
+		},
+		{
+			name:     "without transparency log",
+			signer:   SigstoreSigner{Key: "cosign.key", SkipTlog: true},
+			wantArgs: "|sign-blob --yes --bundle %[1]s --output-signature /dev/null --key cosign.key --tlog-upload=false %[2]s",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			release := filepath.Join(dir, "Release")
+			require.NoError(t, os.WriteFile(release, []byte("Origin: test\n"), 0644))
+			bundle := release + SigstoreBundleExtension
+
+			signer := tt.signer
+			signer.Cosign = cosign
+			require.NoError(t, signer.Init())
+			require.NoError(t, signer.SignBlob(release, bundle))
+
+			assert.FileExists(t, bundle)
+			args, err := os.ReadFile(record)
+			require.NoError(t, err)
+			assert.Equal(t, fmt.Sprintf(tt.wantArgs, bundle, release), strings.TrimSpace(string(args)))
+		})
+	}
+}
+
+func TestSigstoreSigner_PublicKey(t *testing.T) {
+	cosign, _ := testCosign(t)
+
+	keyless := &SigstoreSigner{Cosign: cosign}
+	key, err := keyless.PublicKey()
+	require.NoError(t, err)
+	assert.Nil(t, key)
+
+	signer := &SigstoreSigner{Cosign: cosign, Key: "cosign.key"}
+	key, err = signer.PublicKey()
+	require.NoError(t, err)
+	assert.Contains(t, string(key), "BEGIN PUBLIC KEY")
+}
+
+func TestSigstoreSigner_Errors(t *testing.T) {
+	missing := &SigstoreSigner{Cosign: filepath.Join(t.TempDir(), "cosign")}
+	assert.ErrorIs(t, missing.Init(), ErrCosignFailed)
+
+	failing := &SigstoreSigner{Cosign: "false"}
+	require.NoError(t, failing.Init())
+	assert.ErrorIs(t, failing.SignBlob("Release", "Release.sigstore"), ErrCosignFailed)
+}
@@ -0,0 +1,122 @@
+package testutil
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+)
+
+// Changes is an upload of a source package and its binaries written as .changes file
+type Changes struct {
+	Source       string
+	Version      string
+	Distribution string   // Empty = unstable
+	Maintainer   string   // Empty = DefaultMaintainer
+	Files        []string // Paths of the uploaded .dsc, tarballs, .deb and .buildinfo files
+}
+
+// Filename returns the .changes file name, named after the first binary architecture or source
+func (c Changes) Filename() string {
+	architecture := "source"
+	if architectures := c.binaryArchitectures(); len(architectures) > 0 {
+		architecture = architectures[0]
+	}
+	return fmt.Sprintf("%s_%s_%s.changes", c.Source, withoutEpoch(c.Version), architecture)
+}
+
+// binaryArchitectures returns the architectures of the uploaded .deb files in order of appearance
+func (c Changes) binaryArchitectures() []string {
+	var architectures []string
+	for _, path := range c.Files {
+		name, ok := strings.CutSuffix(filepath.Base(path), ".deb")
+		if !ok {
+			continue
+		}
+		if _, architecture, found := cutLast(name, "_"); found && !slices.Contains(architectures, architecture) {
+			architectures = append(architectures, architecture)
+		}
+	}
+	return architectures
+}
+
+// binaries returns the package names of the uploaded .deb files in order of appearance
+func (c Changes) binaries() []string {
+	var binaries []string
+	for _, path := range c.Files {
+		name, ok := strings.CutSuffix(filepath.Base(path), ".deb")
+		if !ok {
+			continue
+		}
+		if binary, _, found := strings.Cut(name, "_"); found && !slices.Contains(binaries, binary) {
+			binaries = append(binaries, binary)
+		}
+	}
+	return binaries
+}
+
+// WriteChanges writes the .changes file of the upload into dir and returns its path
+// The listed files are read for their checksums, the .changes is clearsigned if signer is set
+func WriteChanges(dir string, changes Changes, signer *openpgp.Entity) (string, error) {
+	files := make([]fileChecksums, 0, len(changes.Files))
+	hasSource := false
+	for _, path := range changes.Files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		files = append(files, checksumsOf(filepath.Base(path), data))
+		hasSource = hasSource || strings.HasSuffix(path, ".dsc")
+	}
+
+	architectures := changes.binaryArchitectures()
+	if hasSource {
+		architectures = append([]string{"source"}, architectures...)
+	}
+	distribution := changes.Distribution
+	if distribution == "" {
+		distribution = "unstable"
+	}
+	maintainer := changes.Maintainer
+	if maintainer == "" {
+		maintainer = DefaultMaintainer
+	}
+	binaries := changes.binaries()
+
+	var control strings.Builder
+	control.WriteString("Format: 1.8\n")
+	fmt.Fprintf(&control, "Date: %s\n", FixtureTime.Format("Mon, 02 Jan 2006 15:04:05 -0700"))
+	fmt.Fprintf(&control, "Source: %s\n", changes.Source)
+	if len(binaries) > 0 {
+		fmt.Fprintf(&control, "Binary: %s\n", strings.Join(binaries, " "))
+	}
+	fmt.Fprintf(&control, "Architecture: %s\n", strings.Join(architectures, " "))
+	fmt.Fprintf(&control, "Version: %s\n", changes.Version)
+	fmt.Fprintf(&control, "Distribution: %s\n", distribution)
+	control.WriteString("Urgency: medium\n")
+	fmt.Fprintf(&control, "Maintainer: %s\n", maintainer)
+	fmt.Fprintf(&control, "Changed-By: %s\n", maintainer)
+	if len(binaries) > 0 {
+		control.WriteString("Description:\n")
+		for _, binary := range binaries {
+			fmt.Fprintf(&control, " %s - generated by aarg testutil\n", binary)
+		}
+	}
+	control.WriteString("Changes:\n")
+	fmt.Fprintf(&control, " %s (%s) %s; urgency=medium\n .\n   * Generated by aarg testutil\n", changes.Source, changes.Version, distribution)
+	writeChecksums(&control, files, func(name string) string {
+		if strings.Contains(name, "-dbgsym_") {
+			return "debug"
+		}
+		return "misc"
+	})
+
+	data, err := sign([]byte(control.String()), signer)
+	if err != nil {
+		return "", err
+	}
+	return writeFile(dir, changes.Filename(), data)
+}
@@ -0,0 +1,208 @@
+// Package testutil builds minimal valid .deb, .dsc and .changes files for tests and the demo
+// The files are generated in-process and reproducible, they contain just enough for all index fields
+package testutil
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// FixtureTime is the modification time of all generated files, keeping them reproducible
+var FixtureTime = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// DefaultMaintainer is the maintainer of generated packages without one
+const DefaultMaintainer = "aarg testutil <testutil@example.invalid>"
+
+// Package is a binary package built into a .deb file
+type Package struct {
+	Name         string
+	Source       string // Source package name, empty = Name
+	Version      string
+	Architecture string
+	Maintainer   string            // Empty = DefaultMaintainer
+	Section      string            // Empty = misc
+	Description  string            // Synopsis, further lines are the extended description
+	Depends      string            // Optional Depends field
+	Files        map[string]string // Contents of data.tar.gz by path, empty = a README below /usr/share/doc
+}
+
+// Filename returns the .deb file name of the package
+func (p Package) Filename() string {
+	return fmt.Sprintf("%s_%s_%s.deb", p.Name, withoutEpoch(p.Version), p.Architecture)
+}
+
+// SourceName returns the source package name
+func (p Package) SourceName() string {
+	if p.Source == "" {
+		return p.Name
+	}
+	return p.Source
+}
+
+// withoutEpoch strips the epoch of a version, epochs are not part of file names
+func withoutEpoch(version string) string {
+	if _, after, found := strings.Cut(version, ":"); found {
+		return after
+	}
+	return version
+}
+
+// Control returns the control file of the package
+func (p Package) Control() string {
+	maintainer := p.Maintainer
+	if maintainer == "" {
+		maintainer = DefaultMaintainer
+	}
+	section := p.Section
+	if section == "" {
+		section = "misc"
+	}
+
+	fields := []string{
+		"Package: " + p.Name,
+		"Source: " + p.SourceName(),
+		"Version: " + p.Version,
+		"Architecture: " + p.Architecture,
+		"Maintainer: " + maintainer,
+		"Installed-Size: 1",
+	}
+	if p.Depends != "" {
+		fields = append(fields, "Depends: "+p.Depends)
+	}
+	fields = append(fields,
+		"Section: "+section,
+		"Priority: optional",
+		"Description: "+formatDescription(p.Description),
+	)
+	return strings.Join(fields, "\n") + "\n"
+}
+
+// formatDescription continues the extended description lines of a description field
+func formatDescription(description string) string {
+	synopsis, extended, found := strings.Cut(description, "\n")
+	if !found {
+		return synopsis
+	}
+	lines := strings.Split(strings.TrimRight(extended, "\n"), "\n")
+	for i, line := range lines {
+		if line == "" {
+			line = "."
+		}
+		lines[i] = " " + line
+	}
+	return synopsis + "\n" + strings.Join(lines, "\n")
+}
+
+// BuildDeb writes the .deb archive of the package to w
+func BuildDeb(w io.Writer, pkg Package) error {
+	files := pkg.Files
+	if len(files) == 0 {
+		files = map[string]string{
+			"./usr/share/doc/" + pkg.Name + "/README": fmt.Sprintf("%s %s generated by aarg testutil\n", pkg.Name, pkg.Version),
+		}
+	}
+	data, err := TarGz(files)
+	if err != nil {
+		return err
+	}
+
+	control, err := TarGz(map[string]string{"./control": pkg.Control()})
+	if err != nil {
+		return err
+	}
+
+	return writeAr(w, []arMember{
+		{name: "debian-binary", data: []byte("2.0\n")},
+		{name: "control.tar.gz", data: control},
+		{name: "data.tar.gz", data: data},
+	})
+}
+
+// WriteDeb builds the .deb file of the package into dir and returns its path
+func WriteDeb(dir string, pkg Package) (string, error) {
+	var buf bytes.Buffer
+	if err := BuildDeb(&buf, pkg); err != nil {
+		return "", fmt.Errorf("failed to build %s: %w", pkg.Filename(), err)
+	}
+	return writeFile(dir, pkg.Filename(), buf.Bytes())
+}
+
+// writeFile writes data to name in dir, creating dir if needed, and returns its path
+func writeFile(dir, name string, data []byte) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// TarGz returns a gzip compressed tar archive of files, names are written in sorted order
+func TarGz(files map[string]string) ([]byte, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, name := range slices.Sorted(maps.Keys(files)) {
+		content := files[name]
+		header := &tar.Header{
+			Name:    name,
+			Mode:    0644,
+			Size:    int64(len(content)),
+			ModTime: FixtureTime,
+			Format:  tar.FormatGNU,
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(tw, content); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// arMember is a file of an ar archive
+type arMember struct {
+	name string
+	data []byte
+}
+
+// writeAr writes members as common ar archive, the container format of .deb files
+func writeAr(w io.Writer, members []arMember) error {
+	if _, err := io.WriteString(w, "!<arch>\n"); err != nil {
+		return err
+	}
+	for _, member := range members {
+		header := fmt.Sprintf("%-16s%-12d%-6d%-6d%-8o%-10d`\n", member.name, FixtureTime.Unix(), 0, 0, 0644, len(member.data))
+		if _, err := io.WriteString(w, header); err != nil {
+			return err
+		}
+		if _, err := w.Write(member.data); err != nil {
+			return err
+		}
+		// Members are aligned to even offsets
+		if len(member.data)%2 == 1 {
+			if _, err := io.WriteString(w, "\n"); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
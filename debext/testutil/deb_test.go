@@ -0,0 +1,53 @@
+package testutil
+
+import (
+	"testing"
+
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteDeb(t *testing.T) {
+	// aptly keeps descriptions in their continuation format
+	tests := []struct {
+		name            string
+		pkg             Package
+		wantFile        string
+		wantSource      string
+		wantDescription string
+	}{
+		{
+			name:            "defaults",
+			pkg:             Package{Name: "hello", Version: "1.0-1", Architecture: "amd64", Description: "hello world"},
+			wantFile:        "hello_1.0-1_amd64.deb",
+			wantSource:      "hello",
+			wantDescription: " hello world\n",
+		},
+		{
+			name:            "epoch and extended description",
+			pkg:             Package{Name: "hello-doc", Source: "hello", Version: "2:1.0-1", Architecture: "all", Description: "hello docs\nFirst paragraph\n\nSecond paragraph"},
+			wantFile:        "hello-doc_1.0-1_all.deb",
+			wantSource:      "hello",
+			wantDescription: " hello docs\n First paragraph\n .\n Second paragraph\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, err := WriteDeb(t.TempDir(), tt.pkg)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantFile, tt.pkg.Filename())
+			assert.FileExists(t, path)
+
+			stanza, err := deb.GetControlFileFromDeb(path)
+			require.NoError(t, err)
+			assert.Equal(t, tt.pkg.Name, stanza["Package"])
+			assert.Equal(t, tt.pkg.Version, stanza["Version"])
+			assert.Equal(t, tt.pkg.Architecture, stanza["Architecture"])
+			assert.Equal(t, tt.wantSource, stanza["Source"])
+			assert.Equal(t, DefaultMaintainer, stanza["Maintainer"])
+			assert.Equal(t, tt.wantDescription, stanza["Description"])
+		})
+	}
+}
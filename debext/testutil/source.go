@@ -0,0 +1,208 @@
+package testutil
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/clearsign"
+)
+
+// Source is a source package written as .dsc file with its tarballs
+// Versions with a Debian revision use format 3.0 (quilt) with .orig and .debian tarballs, others 3.0 (native)
+type Source struct {
+	Name         string
+	Version      string
+	Binaries     []string // Binary packages built from the source, empty = Name
+	Architecture string   // Empty = any
+	Maintainer   string   // Empty = DefaultMaintainer
+	Section      string   // Empty = misc
+}
+
+// Filename returns the .dsc file name of the source package
+func (s Source) Filename() string {
+	return fmt.Sprintf("%s_%s.dsc", s.Name, withoutEpoch(s.Version))
+}
+
+// Filenames returns the file names of the .dsc file and its tarballs
+func (s Source) Filenames() []string {
+	return append([]string{s.Filename()}, s.tarballNames()...)
+}
+
+// tarballNames returns the file names of the tarballs of the source package
+func (s Source) tarballNames() []string {
+	version := withoutEpoch(s.Version)
+	upstream, _, quilt := cutLast(version, "-")
+	if !quilt {
+		return []string{fmt.Sprintf("%s_%s.tar.gz", s.Name, version)}
+	}
+	return []string{
+		fmt.Sprintf("%s_%s.orig.tar.gz", s.Name, upstream),
+		fmt.Sprintf("%s_%s.debian.tar.gz", s.Name, version),
+	}
+}
+
+// tarballs returns the contents of the tarballs of the source package by file name
+func (s Source) tarballs() (map[string][]byte, error) {
+	upstream, _, _ := cutLast(withoutEpoch(s.Version), "-")
+	dir := fmt.Sprintf("./%s-%s/", s.Name, upstream)
+	changelog := fmt.Sprintf("%s (%s) unstable; urgency=medium\n\n  * Generated by aarg testutil\n\n -- %s  %s\n",
+		s.Name, s.Version, s.maintainer(), FixtureTime.Format("Mon, 02 Jan 2006 15:04:05 -0700"))
+
+	// Native sources have a single tarball including debian/, quilt sources a separate .debian tarball
+	contents := []map[string]string{{dir + "README": s.Name + "\n", dir + "debian/changelog": changelog}}
+	names := s.tarballNames()
+	if len(names) > 1 {
+		contents = []map[string]string{{dir + "README": s.Name + "\n"}, {"./debian/changelog": changelog}}
+	}
+
+	tarballs := make(map[string][]byte, len(names))
+	for i, name := range names {
+		data, err := TarGz(contents[i])
+		if err != nil {
+			return nil, err
+		}
+		tarballs[name] = data
+	}
+	return tarballs, nil
+}
+
+// maintainer returns the maintainer of the source package
+func (s Source) maintainer() string {
+	if s.Maintainer == "" {
+		return DefaultMaintainer
+	}
+	return s.Maintainer
+}
+
+// cutLast slices s around the last instance of sep
+func cutLast(s, sep string) (before, after string, found bool) {
+	if i := strings.LastIndex(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
+
+// WriteSource writes the .dsc file and tarballs of the source package into dir and returns the .dsc path
+// The .dsc is clearsigned if signer is set
+func WriteSource(dir string, src Source, signer *openpgp.Entity) (string, error) {
+	tarballs, err := src.tarballs()
+	if err != nil {
+		return "", err
+	}
+	names := src.tarballNames()
+	files := make([]fileChecksums, 0, len(names))
+	for _, name := range names {
+		if _, err := writeFile(dir, name, tarballs[name]); err != nil {
+			return "", err
+		}
+		files = append(files, checksumsOf(name, tarballs[name]))
+	}
+
+	binaries := src.Binaries
+	if len(binaries) == 0 {
+		binaries = []string{src.Name}
+	}
+	architecture := src.Architecture
+	if architecture == "" {
+		architecture = "any"
+	}
+	section := src.Section
+	if section == "" {
+		section = "misc"
+	}
+	format := "3.0 (native)"
+	if len(names) > 1 {
+		format = "3.0 (quilt)"
+	}
+
+	var control strings.Builder
+	fmt.Fprintf(&control, "Format: %s\n", format)
+	fmt.Fprintf(&control, "Source: %s\n", src.Name)
+	fmt.Fprintf(&control, "Binary: %s\n", strings.Join(binaries, ", "))
+	fmt.Fprintf(&control, "Architecture: %s\n", architecture)
+	fmt.Fprintf(&control, "Version: %s\n", src.Version)
+	fmt.Fprintf(&control, "Maintainer: %s\n", src.maintainer())
+	control.WriteString("Standards-Version: 4.6.2\n")
+	control.WriteString("Package-List:\n")
+	for _, binary := range binaries {
+		fmt.Fprintf(&control, " %s deb %s optional arch=%s\n", binary, section, architecture)
+	}
+	writeChecksums(&control, files, nil)
+
+	data, err := sign([]byte(control.String()), signer)
+	if err != nil {
+		return "", err
+	}
+	return writeFile(dir, src.Filename(), data)
+}
+
+// fileChecksums are the size and checksums of a file listed in a .dsc or .changes file
+type fileChecksums struct {
+	name   string
+	size   int
+	md5    string
+	sha1   string
+	sha256 string
+}
+
+// checksumsOf returns the checksums of file contents
+func checksumsOf(name string, data []byte) fileChecksums {
+	md5sum := md5.Sum(data)
+	sha1sum := sha1.Sum(data)
+	sha256sum := sha256.Sum256(data)
+	return fileChecksums{
+		name:   name,
+		size:   len(data),
+		md5:    hex.EncodeToString(md5sum[:]),
+		sha1:   hex.EncodeToString(sha1sum[:]),
+		sha256: hex.EncodeToString(sha256sum[:]),
+	}
+}
+
+// writeChecksums writes the Checksums-Sha1, Checksums-Sha256 and Files fields
+// Files entries of .changes files carry section and priority, returned by sectionOf if set
+func writeChecksums(w *strings.Builder, files []fileChecksums, sectionOf func(name string) string) {
+	w.WriteString("Checksums-Sha1:\n")
+	for _, file := range files {
+		fmt.Fprintf(w, " %s %d %s\n", file.sha1, file.size, file.name)
+	}
+	w.WriteString("Checksums-Sha256:\n")
+	for _, file := range files {
+		fmt.Fprintf(w, " %s %d %s\n", file.sha256, file.size, file.name)
+	}
+	w.WriteString("Files:\n")
+	for _, file := range files {
+		if sectionOf != nil {
+			fmt.Fprintf(w, " %s %d %s optional %s\n", file.md5, file.size, sectionOf(file.name), file.name)
+		} else {
+			fmt.Fprintf(w, " %s %d %s\n", file.md5, file.size, file.name)
+		}
+	}
+}
+
+// sign clearsigns text with signer, text is returned as-is without signer
+func sign(text []byte, signer *openpgp.Entity) ([]byte, error) {
+	if signer == nil {
+		return text, nil
+	}
+
+	var buf bytes.Buffer
+	w, err := clearsign.Encode(&buf, signer.PrivateKey, nil)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(text); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	buf.WriteString("\n")
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,93 @@
+package debext
+
+import (
+	"bufio"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/aptly-dev/aptly/deb"
+)
+
+// TranslationLanguage is the language of the descriptions in the control files of packages
+const TranslationLanguage = "en"
+
+// descriptionMD5Field is the Description-md5 field in aptly's canonical case of control file fields
+const descriptionMD5Field = "Description-Md5"
+
+// DescriptionMD5 returns the Description-md5 of a package description as in its control file,
+// the MD5 over the synopsis and long description lines including the trailing newline
+func DescriptionMD5(description string) string {
+	if !strings.HasSuffix(description, "\n") {
+		description += "\n"
+	}
+	sum := md5.Sum([]byte(description))
+	return hex.EncodeToString(sum[:])
+}
+
+// fullDescription returns the description of a binary package stanza as in its control file
+// Returns false if there is no description or it was already split off upstream, which leaves only
+// the synopsis and a Description-md5 of the unknown long description
+func fullDescription(stanza deb.Stanza) (string, bool) {
+	// aptly keeps the space after the field name for multiline fields
+	description := strings.TrimPrefix(stanza["Description"], " ")
+	if strings.TrimSpace(description) == "" || stanza[descriptionMD5Field] != "" {
+		return "", false
+	}
+	if !strings.HasSuffix(description, "\n") {
+		description += "\n"
+	}
+	return description, true
+}
+
+// splitDescription reduces the description of a binary package stanza to its synopsis
+// and references the full description in the Translation-en index with Description-md5
+func splitDescription(stanza deb.Stanza) {
+	description, ok := fullDescription(stanza)
+	if !ok {
+		return
+	}
+	synopsis, _, _ := strings.Cut(description, "\n")
+	stanza["Description"] = " " + synopsis
+	stanza[descriptionMD5Field] = DescriptionMD5(description)
+}
+
+// GenerateTranslatedPackageIndex generates a Packages index with the long descriptions split off
+// into the Translation-en index per Debian policy, see GenerateTranslationIndex
+func GenerateTranslatedPackageIndex(w io.Writer, list *deb.PackageList) error {
+	return writePackageIndex(w, list, false, splitDescription)
+}
+
+// GenerateTranslationIndex generates the Translation-en index of the binary packages of list and
+// returns the number of descriptions written. Packages sharing name and description are listed once.
+func GenerateTranslationIndex(w io.Writer, list *deb.PackageList) (int, error) {
+	list.PrepareIndex()
+
+	bufWriter := bufio.NewWriter(w)
+	written := make(map[string]bool)
+
+	err := list.ForEachIndexed(func(pkg *deb.Package) error {
+		if pkg.IsSource {
+			return nil
+		}
+		description, ok := fullDescription(pkg.Stanza())
+		if !ok {
+			return nil
+		}
+
+		md5sum := DescriptionMD5(description)
+		if key := pkg.Name + " " + md5sum; !written[key] {
+			written[key] = true
+			_, err := fmt.Fprintf(bufWriter, "Package: %s\nDescription-md5: %s\nDescription-%s: %s\n", pkg.Name, md5sum, TranslationLanguage, description)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return len(written), bufWriter.Flush()
+}
@@ -0,0 +1,106 @@
+package debext
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// translationTestPackages returns packages as read from control files: with long description,
+// synopsis only and split off upstream
+func translationTestPackages(t *testing.T) *deb.PackageList {
+	control := `Package: example
+Version: 1.0
+Architecture: amd64
+Description: Example tool
+ Longer explanation.
+ .
+ Second paragraph.
+
+Package: example
+Version: 1.1
+Architecture: amd64
+Description: Example tool
+ Longer explanation.
+ .
+ Second paragraph.
+
+Package: short
+Version: 1.0
+Architecture: all
+Description: Short only
+
+Package: upstream
+Version: 1.0
+Architecture: amd64
+Description: Split upstream
+Description-md5: 00000000000000000000000000000000
+
+`
+	list := deb.NewPackageList()
+	reader := deb.NewControlFileReader(strings.NewReader(control), false, false)
+	for {
+		stanza, err := reader.ReadStanza()
+		require.NoError(t, err)
+		if stanza == nil {
+			break
+		}
+		require.NoError(t, list.Add(deb.NewPackageFromControlFile(stanza)))
+	}
+	return list
+}
+
+func TestDescriptionMD5(t *testing.T) {
+	tests := []struct {
+		name        string
+		description string
+		want        string
+	}{
+		{name: "long description", description: "Example tool\n Longer explanation.\n .\n Second paragraph.\n", want: "0ef8c0532f40effe01cc31c2b13b959c"},
+		{name: "synopsis only", description: "Short only\n", want: "1b11f92cf312ab44110ffc451f9f23d1"},
+		{name: "without trailing newline", description: "Short only", want: "1b11f92cf312ab44110ffc451f9f23d1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, DescriptionMD5(tt.description))
+		})
+	}
+}
+
+func TestGenerateTranslatedPackageIndex(t *testing.T) {
+	var output bytes.Buffer
+	require.NoError(t, GenerateTranslatedPackageIndex(&output, translationTestPackages(t)))
+
+	stanzas := strings.Split(strings.TrimSuffix(output.String(), "\n\n"), "\n\n")
+	require.Len(t, stanzas, 4)
+	assert.Contains(t, stanzas[0], "Description: Example tool\nDescription-Md5: 0ef8c0532f40effe01cc31c2b13b959c")
+	assert.NotContains(t, stanzas[0], "Longer explanation")
+	assert.Contains(t, stanzas[2], "Description: Short only\nDescription-Md5: 1b11f92cf312ab44110ffc451f9f23d1")
+	assert.Contains(t, stanzas[3], "Description-Md5: 00000000000000000000000000000000")
+}
+
+func TestGenerateTranslationIndex(t *testing.T) {
+	var output bytes.Buffer
+	count, err := GenerateTranslationIndex(&output, translationTestPackages(t))
+	require.NoError(t, err)
+
+	// Both versions of example share the description, upstream has no long description to translate
+	assert.Equal(t, 2, count)
+	assert.Equal(t, `Package: example
+Description-md5: 0ef8c0532f40effe01cc31c2b13b959c
+Description-en: Example tool
+ Longer explanation.
+ .
+ Second paragraph.
+
+Package: short
+Description-md5: 1b11f92cf312ab44110ffc451f9f23d1
+Description-en: Short only
+
+`, output.String())
+}
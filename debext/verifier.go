@@ -2,8 +2,13 @@ package debext
 
 import (
 	"errors"
+	"fmt"
 	"io"
+	"time"
 
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/clearsign"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
 	"github.com/aptly-dev/aptly/pgp"
 )
 
@@ -12,13 +17,33 @@ var (
 	ErrSignatureVerificationFailed = errors.New("signature verification failed")
 	// ErrMissingSignature indicates a file is not signed
 	ErrMissingSignature = errors.New("file is not signed")
+	// ErrSignatureNotYetValid indicates a signature created after the local time
+	ErrSignatureNotYetValid = errors.New("signature is not valid yet")
+	// ErrSignatureExpired indicates a signature past its expiration time
+	ErrSignatureExpired = errors.New("signature expired")
+	// ErrKeyExpired indicates a signature made by an expired key
+	ErrKeyExpired = errors.New("signing key expired")
+	// ErrReleaseNotYetValid indicates a Release file dated after the local time
+	ErrReleaseNotYetValid = errors.New("release file is not valid yet")
+	// ErrReleaseExpired indicates a Release file past its Valid-Until
+	ErrReleaseExpired = errors.New("release file expired")
 )
 
+// DefaultClockSkew is the tolerated difference between the local clock and the clocks of signers
+const DefaultClockSkew = 5 * time.Minute
+
 // Verifier wraps aptly's pgp.Verifier with configuration options
 type Verifier struct {
 	pgp.Verifier
 	AcceptUnsigned   bool // Accept files without signatures
 	IgnoreSignatures bool // Skip signature verification
+
+	// Validity times of signatures, keys and Release files are checked against the local clock
+	// Keys enables checking key expiry, ClockSkew is the tolerance (0 = DefaultClockSkew, negative
+	// disables the checks) and Now the local clock (nil = time.Now)
+	Keys      openpgp.EntityList
+	ClockSkew time.Duration
+	Now       func() time.Time
 }
 
 // VerifyAndClear verifies and extracts cleartext from a clearsigned file.
@@ -57,6 +82,15 @@ func (v *Verifier) VerifyAndClear(file io.ReadSeeker) (io.ReadCloser, []pgp.Key,
 			return nil, nil, ErrSignatureVerificationFailed
 		}
 
+		_, _ = file.Seek(0, 0)
+		data, err := io.ReadAll(file)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := v.checkSignatureTimes(data, keyInfo.GoodKeys); err != nil {
+			return nil, nil, err
+		}
+
 		_, _ = file.Seek(0, 0)
 
 		rc, err := v.ExtractClearsigned(file)
@@ -66,3 +100,121 @@ func (v *Verifier) VerifyAndClear(file io.ReadSeeker) (io.ReadCloser, []pgp.Key,
 	// Not clearsigned and AcceptUnsigned is true, return as-is
 	return io.NopCloser(file), nil, nil
 }
+
+// now returns the local time
+func (v *Verifier) now() time.Time {
+	if v.Now != nil {
+		return v.Now()
+	}
+	return time.Now()
+}
+
+// clockSkew returns the tolerated clock difference and whether validity times are checked
+func (v *Verifier) clockSkew() (time.Duration, bool) {
+	switch {
+	case v.IgnoreSignatures || v.ClockSkew < 0:
+		return 0, false
+	case v.ClockSkew == 0:
+		return DefaultClockSkew, true
+	default:
+		return v.ClockSkew, true
+	}
+}
+
+// CheckNotBefore returns err if t lies ahead of the local clock by more than the tolerated skew
+// The error names the difference, a wrong local clock is the usual cause on freshly booted machines
+func (v *Verifier) CheckNotBefore(t time.Time, what string, err error) error {
+	skew, ok := v.clockSkew()
+	if !ok {
+		return nil
+	}
+	now := v.now()
+	if ahead := t.Sub(now); ahead > skew {
+		return fmt.Errorf("%w: %s %s is %s ahead of the local clock %s (tolerance %s), check the system clock",
+			err, what, t.UTC().Format(time.RFC3339), ahead.Round(time.Second), now.UTC().Format(time.RFC3339), skew)
+	}
+	return nil
+}
+
+// CheckNotAfter returns err if t lies behind the local clock by more than the tolerated skew
+func (v *Verifier) CheckNotAfter(t time.Time, what string, err error) error {
+	skew, ok := v.clockSkew()
+	if !ok {
+		return nil
+	}
+	now := v.now()
+	if behind := now.Sub(t); behind > skew {
+		return fmt.Errorf("%w: %s %s is %s behind the local clock %s (tolerance %s), check the system clock if this is unexpected",
+			err, what, t.UTC().Format(time.RFC3339), behind.Round(time.Second), now.UTC().Format(time.RFC3339), skew)
+	}
+	return nil
+}
+
+// checkSignatureTimes checks the validity times of the good signatures of a clearsigned file and their keys
+// One signature valid at the local time is enough, as with the signatures of a key rotation
+func (v *Verifier) checkSignatureTimes(data []byte, goodKeys []pgp.Key) error {
+	if _, ok := v.clockSkew(); !ok || len(goodKeys) == 0 {
+		return nil
+	}
+	block, _ := clearsign.Decode(data)
+	if block == nil {
+		return nil
+	}
+
+	var errs []error
+	reader := packet.NewReader(block.ArmoredSignature.Body)
+	for {
+		p, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read signature: %w", err)
+		}
+		sig, ok := p.(*packet.Signature)
+		if !ok || sig.IssuerKeyId == nil || !containsKey(goodKeys, *sig.IssuerKeyId) {
+			continue
+		}
+
+		err = v.checkSignatureTime(sig)
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// checkSignatureTime checks the creation and expiration time of a signature and the expiry of its key
+func (v *Verifier) checkSignatureTime(sig *packet.Signature) error {
+	issuer := fmt.Sprintf("%016X", *sig.IssuerKeyId)
+	if err := v.CheckNotBefore(sig.CreationTime, "creation time of the signature by "+issuer, ErrSignatureNotYetValid); err != nil {
+		return err
+	}
+	if sig.SigLifetimeSecs != nil && *sig.SigLifetimeSecs > 0 {
+		expires := sig.CreationTime.Add(time.Duration(*sig.SigLifetimeSecs) * time.Second)
+		if err := v.CheckNotAfter(expires, "expiry of the signature by "+issuer, ErrSignatureExpired); err != nil {
+			return err
+		}
+	}
+	for _, key := range v.Keys.KeysById(*sig.IssuerKeyId) {
+		if key.SelfSignature == nil || key.SelfSignature.KeyLifetimeSecs == nil || *key.SelfSignature.KeyLifetimeSecs == 0 {
+			continue
+		}
+		expires := key.PublicKey.CreationTime.Add(time.Duration(*key.SelfSignature.KeyLifetimeSecs) * time.Second)
+		if err := v.CheckNotAfter(expires, "expiry of key "+issuer, ErrKeyExpired); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// containsKey reports whether keys contain the key ID
+func containsKey(keys []pgp.Key, keyID uint64) bool {
+	for _, key := range keys {
+		if key.Matches(pgp.KeyFromUint64(keyID)) {
+			return true
+		}
+	}
+	return false
+}
@@ -2,10 +2,16 @@ package debext
 
 import (
 	"bytes"
+	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/clearsign"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
 	"github.com/aptly-dev/aptly/pgp"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -79,3 +85,80 @@ func TestVerifierConfiguration(t *testing.T) {
 		_ = rc.Close()
 	})
 }
+
+func TestVerifier_ClockSkew(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name        string
+		keyCreated  time.Duration // Offsets relative to the local clock
+		keyLifetime uint32
+		signed      time.Duration
+		date        time.Duration
+		validUntil  *time.Duration
+		clockSkew   time.Duration
+		wantErr     error
+		wantMessage string
+	}{
+		{name: "in sync"},
+		{name: "skew within tolerance", signed: 3 * time.Minute, date: 3 * time.Minute},
+		{name: "release date ahead", date: 2 * time.Hour, wantErr: ErrReleaseNotYetValid, wantMessage: "2h0m0s ahead of the local clock"},
+		{name: "signature ahead", signed: time.Hour, date: time.Hour, wantErr: ErrSignatureNotYetValid, wantMessage: "1h0m0s ahead of the local clock"},
+		{name: "release expired", validUntil: ptr(-time.Hour), wantErr: ErrReleaseExpired, wantMessage: "1h0m0s behind the local clock"},
+		{name: "key expired", keyCreated: -3 * time.Hour, keyLifetime: 3600, signed: -150 * time.Minute, date: -150 * time.Minute, wantErr: ErrKeyExpired, wantMessage: "2h0m0s behind the local clock"},
+		{name: "custom tolerance", date: 2 * time.Hour, clockSkew: 3 * time.Hour},
+		{name: "checks disabled", signed: time.Hour, date: 2 * time.Hour, validUntil: ptr(-time.Hour), clockSkew: -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entity, err := openpgp.NewEntity("signer", "", "signer@example.com", &packet.Config{
+				Time:            func() time.Time { return now.Add(tt.keyCreated) },
+				KeyLifetimeSecs: tt.keyLifetime,
+			})
+			require.NoError(t, err)
+
+			release := fmt.Sprintf("Origin: test\nSuite: stable\nDate: %s\n", now.Add(tt.date).Format(time.RFC1123))
+			if tt.validUntil != nil {
+				release += fmt.Sprintf("Valid-Until: %s\n", now.Add(*tt.validUntil).Format(time.RFC1123))
+			}
+			release += "SHA256:\n e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855 0 main/binary-amd64/Packages\n"
+
+			var signed bytes.Buffer
+			w, err := clearsign.Encode(&signed, entity.PrivateKey, &packet.Config{Time: func() time.Time { return now.Add(tt.signed) }})
+			require.NoError(t, err)
+			_, err = w.Write([]byte(release))
+			require.NoError(t, err)
+			require.NoError(t, w.Close())
+
+			dir := t.TempDir()
+			releasePath := filepath.Join(dir, "InRelease")
+			require.NoError(t, os.WriteFile(releasePath, signed.Bytes(), 0644))
+
+			var keyring bytes.Buffer
+			require.NoError(t, entity.Serialize(&keyring))
+			keyringPath := filepath.Join(dir, "signer.gpg")
+			require.NoError(t, os.WriteFile(keyringPath, keyring.Bytes(), 0644))
+			goVerifier := &pgp.GoVerifier{}
+			goVerifier.AddKeyring(keyringPath)
+			require.NoError(t, goVerifier.InitKeyring(false))
+
+			verifier := &Verifier{
+				Verifier:  goVerifier,
+				Keys:      openpgp.EntityList{entity},
+				ClockSkew: tt.clockSkew,
+				Now:       func() time.Time { return now },
+			}
+			_, err = ParseRelease(releasePath, verifier)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				assert.Contains(t, err.Error(), tt.wantMessage)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,100 @@
+// Command local is a reference aarg plugin implementing both plugin kinds:
+//   - feed: provides .deb files from a local directory laid out as {path}/{distribution}/*.deb
+//   - provider: publishes the generated output by copying it into a local directory
+//
+// Configure it in config.yaml:
+//
+//	plugins:
+//	  local:
+//	    command: ./plugins/local
+//	    settings:
+//	      target: /srv/www/apt # provider: directory receiving the published output
+//
+// and use it in a repository feed:
+//
+//	feeds:
+//	  - plugin: local
+//	    location: builds
+//	    settings:
+//	      path: /srv/builds # feed: directory containing one subdirectory per distribution
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/dionysius/aarg/internal/plugin"
+)
+
+func main() {
+	server := &plugin.Server{
+		Name:  "local",
+		Kinds: []plugin.Kind{plugin.KindFeed, plugin.KindProvider},
+		Handlers: map[string]plugin.Handler{
+			plugin.MethodFetch:   fetch,
+			plugin.MethodPublish: publish,
+		},
+	}
+
+	if err := server.Serve(context.Background()); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// fetch copies all .deb files of the source directory into the download directory
+func fetch(ctx context.Context, raw json.RawMessage) (any, error) {
+	var params plugin.FetchParams
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return nil, err
+	}
+
+	root := params.Settings["path"]
+	if root == "" {
+		return nil, errors.New("setting 'path' is required")
+	}
+
+	result, err := plugin.FetchDirectory(ctx, root, params.DownloadDir)
+	for _, file := range result.Files {
+		fmt.Fprintln(os.Stderr, "provided", file.Path)
+	}
+
+	return result, err
+}
+
+// publish copies the output directory into the target directory
+func publish(ctx context.Context, raw json.RawMessage) (any, error) {
+	var params plugin.PublishParams
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return nil, err
+	}
+
+	target := params.Settings["target"]
+	if target == "" {
+		return nil, errors.New("setting 'target' is required")
+	}
+
+	err := filepath.WalkDir(params.OutputDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(params.OutputDir, path)
+		if err != nil {
+			return err
+		}
+		if _, err := plugin.CopyFile(path, filepath.Join(target, rel)); err != nil {
+			return err
+		}
+		return ctx.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return plugin.PublishResult{URL: "file://" + target}, nil
+}
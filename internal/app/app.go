@@ -5,8 +5,10 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/ProtonMail/go-crypto/openpgp"
@@ -16,9 +18,15 @@ import (
 	"github.com/dionysius/aarg/debext"
 	"github.com/dionysius/aarg/internal/common"
 	"github.com/dionysius/aarg/internal/config"
+	"github.com/dionysius/aarg/internal/feed"
+	"github.com/dionysius/aarg/internal/log"
+	"github.com/dionysius/aarg/internal/telemetry"
 	"github.com/google/go-github/v80/github"
 )
 
+// tracingShutdownTimeout is how long pending spans may take to be exported on shutdown
+const tracingShutdownTimeout = 10 * time.Second
+
 // Application holds the initialized runtime components and configuration
 type Application struct {
 	Config             *config.Config
@@ -28,24 +36,70 @@ type Application struct {
 	Downloader         *common.Downloader
 	DeCompressor       *common.DeCompressor
 	Storage            *common.Storage
+	MetadataStore      common.MetadataStore // Reusable metadata like parsed control files, flat files or SQLite
+	ControlStore       debext.ControlStore  // Parsed control files of packages, backed by MetadataStore
+	ContentsStore      debext.ContentsStore // File lists of packages, backed by MetadataStore
 	GitHubClient       *github.Client
 	HTTPClient         *http.Client
 	Signer             pgp.Signer
-	PublicKeyASCII     []byte // ASCII-armored public key
-	PublicKeyBinary    []byte // Binary (dearmored) public key
-	PreparedPublicKey  string // Path to prepared public key file
-	PreparedPrivateKey string // Path to prepared private key file
-	KeyCleanup         func() // Cleanup function for temporary key files
+	PublicKeyASCII     []byte                      // ASCII-armored public key
+	PublicKeyBinary    []byte                      // Binary (dearmored) public key
+	PreparedPublicKey  string                      // Path to prepared public key file
+	PreparedPrivateKey string                      // Path to prepared private key file
+	KeyCleanup         func()                      // Cleanup function for temporary key files
+	TracingShutdown    func(context.Context) error // Flushes pending trace spans, nil if tracing is disabled
+	PoolMonitor        *common.PoolMonitor         // Tracks the worker pools and subpools
+	StopWatchdog       func()                      // Stops checking the worker pools for stalls
+	ConfigHash         string                      // Fingerprint of the effective configuration, published with every build
+	Sigstore           *debext.SigstoreSigner      // Signs sigstore bundles of Release files, nil if disabled
+	PublishOnly        []string                    // Providers publishing is limited to, empty = all enabled providers
 }
 
 // New creates and initializes a new Application from configuration
 func New(ctx context.Context, cfg *config.Config) (*Application, error) {
 	dirs := cfg.Directories
 
+	configHash, err := cfg.Fingerprint()
+	if err != nil {
+		return nil, fmt.Errorf("failed to fingerprint configuration: %w", err)
+	}
+
+	// Show timestamps of web pages, reports and logs in the configured time zone
+	common.SetDisplayLocation(cfg.DisplayLocation())
+
+	// Export traces if configured, spans are no-ops otherwise
+	var tracingShutdown func(context.Context) error
+	if cfg.Tracing.IsEnabled() {
+		tracingShutdown, err = telemetry.SetupTracing(ctx, telemetry.TracingOptions{
+			Endpoint:    cfg.Tracing.Endpoint,
+			Insecure:    cfg.Tracing.Insecure,
+			Headers:     cfg.Tracing.Headers,
+			ServiceName: cfg.Tracing.ServiceName,
+			SampleRatio: cfg.Tracing.GetSampleRatio(),
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Flushes the spans recorded so far if the application cannot be created
+	stopTracing := func() {
+		if tracingShutdown != nil {
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), tracingShutdownTimeout)
+			defer cancel()
+			_ = tracingShutdown(shutdownCtx)
+		}
+	}
+
 	// Create worker pools with context (sizes already validated and defaulted in config)
-	mainPool := pond.NewPool(int(cfg.Workers.Main), pond.WithContext(ctx), pond.WithoutPanicRecovery())
+	// Subpools of the main pool are tracked to find the stuck ones if it stalls
+	poolMonitor := common.NewPoolMonitor()
+	mainPool := poolMonitor.Pool("main", pond.NewPool(int(cfg.Workers.Main), pond.WithContext(ctx), pond.WithoutPanicRecovery()))
 	downloadPool := pond.NewResultPool[common.Result](int(cfg.Workers.Download), pond.WithContext(ctx), pond.WithoutPanicRecovery())
 	compressionPool := pond.NewResultPool[common.Result](int(cfg.Workers.Compression), pond.WithContext(ctx), pond.WithoutPanicRecovery())
+	poolMonitor.Track("download", downloadPool)
+	poolMonitor.Track("compression", compressionPool)
+	stopWatchdog := poolMonitor.Watch(ctx, time.Duration(cfg.Workers.StallTimeout)*time.Second, dirs.GetCachePath())
 
 	// Initialize HTTP client with optional configuration
 	httpClient := &http.Client{}
@@ -83,29 +137,69 @@ func New(ctx context.Context, cfg *config.Config) (*Application, error) {
 		httpClient.Timeout = time.Duration(cfg.HTTP.Timeout) * time.Second
 	}
 
-	// Initialize decompressor with compression pool
-	decompressor := common.NewDeCompressor(compressionPool)
+	// Initialize decompressor with compression pool and configured levels
+	decompressor := common.NewDeCompressor(compressionPool, cfg.Generate.Compression.Levels())
 
 	// Initialize downloader with download pool
 	downloader := common.NewDownloader(downloadPool, httpClient, decompressor)
+	downloader.SetMinFree(cfg.Preflight.MinFreeBytes())
+	downloader.SetRateLimit(cfg.HTTP.MaxBytesPerSecond, cfg.HTTP.MaxBytesPerSecondHost)
+
+	// Reuse parsed package control files and contents, feed maps and checksums across composers and runs
+	metadataStore, err := common.OpenMetadataStore(cfg.Storage.Backend, dirs.GetCachePath(), cfg.Storage.GetDatabasePath(dirs.GetCachePath()))
+	if err != nil {
+		stopWatchdog()
+		stopTracing()
+		return nil, fmt.Errorf("failed to open metadata store: %w", err)
+	}
 
 	// Initialize storage (using resolved absolute paths from config)
 	storage := common.NewStorage(downloader, dirs.GetDownloadsPath(), dirs.GetTrustedPath())
+	storage.SetMetadataStore(metadataStore)
 
 	// Initialize GitHub client (if token is configured)
+	// API requests wait for the rate limit instead of failing and release lists are revalidated with ETags
+	githubHTTPClient := &http.Client{
+		Transport: common.NewGitHubTransport(httpClient.Transport, filepath.Join(dirs.GetDownloadsPath(), common.GitHubAPICacheDir)),
+		Timeout:   httpClient.Timeout,
+	}
 	var githubClient *github.Client
 	if cfg.GitHub.Token != "" {
-		githubClient = github.NewClient(httpClient).WithAuthToken(cfg.GitHub.Token)
+		githubClient = github.NewClient(githubHTTPClient).WithAuthToken(cfg.GitHub.Token)
 	} else {
-		githubClient = github.NewClient(httpClient)
+		githubClient = github.NewClient(githubHTTPClient)
 	}
+	// The transport handles rate limits, go-github would fail requests while the quota is exhausted
+	githubClient.DisableRateLimitCheck = true
 
 	// Initialize signer and load public keys
 	signer, publicKeyASCII, publicKeyBinary, preparedPublic, preparedPrivate, cleanup, err := initializeSigner(cfg)
 	if err != nil {
+		stopWatchdog()
+		_ = metadataStore.Close()
+		stopTracing()
 		return nil, err
 	}
 
+	var sigstore *debext.SigstoreSigner
+	if cfg.Signing.Sigstore.Enabled {
+		sigstore = &debext.SigstoreSigner{
+			Cosign:     cfg.Signing.Sigstore.Cosign,
+			Key:        cfg.Signing.Sigstore.GetKeyPath(cfg.ConfigDir),
+			Passphrase: cfg.Signing.Sigstore.Passphrase,
+			FulcioURL:  cfg.Signing.Sigstore.FulcioURL,
+			RekorURL:   cfg.Signing.Sigstore.RekorURL,
+			SkipTlog:   cfg.Signing.Sigstore.SkipTlog,
+		}
+		if err := sigstore.Init(); err != nil {
+			cleanup()
+			stopWatchdog()
+			_ = metadataStore.Close()
+			stopTracing()
+			return nil, err
+		}
+	}
+
 	return &Application{
 		Config:             cfg,
 		MainPool:           mainPool,
@@ -114,6 +208,9 @@ func New(ctx context.Context, cfg *config.Config) (*Application, error) {
 		Downloader:         downloader,
 		DeCompressor:       decompressor,
 		Storage:            storage,
+		MetadataStore:      metadataStore,
+		ControlStore:       common.NewMetadataControlStore(metadataStore),
+		ContentsStore:      common.NewMetadataContentsStore(metadataStore),
 		GitHubClient:       githubClient,
 		HTTPClient:         httpClient,
 		Signer:             signer,
@@ -122,11 +219,27 @@ func New(ctx context.Context, cfg *config.Config) (*Application, error) {
 		PreparedPublicKey:  preparedPublic,
 		PreparedPrivateKey: preparedPrivate,
 		KeyCleanup:         cleanup,
+		TracingShutdown:    tracingShutdown,
+		PoolMonitor:        poolMonitor,
+		StopWatchdog:       stopWatchdog,
+		ConfigHash:         configHash,
+		Sigstore:           sigstore,
 	}, nil
 }
 
 // Shutdown gracefully stops all application components
 func (a *Application) Shutdown() {
+	if a.Downloader != nil {
+		log.CountDownloaded(a.Downloader.Downloaded())
+	}
+	if a.StopWatchdog != nil {
+		a.StopWatchdog()
+	}
+	if a.PoolMonitor != nil {
+		for _, stats := range a.PoolMonitor.Stats() {
+			slog.Debug("Worker pool tasks", "pool", stats.Name, "completed", stats.Completed)
+		}
+	}
 	if a.MainPool != nil {
 		a.MainPool.StopAndWait()
 	}
@@ -136,20 +249,43 @@ func (a *Application) Shutdown() {
 	if a.CompressionPool != nil {
 		a.CompressionPool.StopAndWait()
 	}
+	if a.MetadataStore != nil {
+		if err := a.MetadataStore.Close(); err != nil {
+			slog.Warn("Failed to close metadata store", "error", err)
+		}
+	}
+	if a.TracingShutdown != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), tracingShutdownTimeout)
+		defer cancel()
+		if err := a.TracingShutdown(ctx); err != nil {
+			slog.Warn("Failed to flush traces", "error", err)
+		}
+	}
 }
 
 // initializeVerifier creates a verifier for a repository configuration
-func (a *Application) initializeVerifier(repoCfg *config.RepositoryConfig) (*debext.Verifier, error) {
+// The signing keys of PPA feeds are added to the configured keys
+func (a *Application) initializeVerifier(ctx context.Context, repoCfg *config.RepositoryConfig) (*debext.Verifier, error) {
 	verifier := &pgp.GoVerifier{}
 
-	// Process keyring if specified (keyrings are typically already binary)
-	keyringPath := repoCfg.Verification.GetKeyringPath(a.Config.ConfigDir)
-	if keyringPath != "" {
-		verifier.AddKeyring(keyringPath)
+	// Process keyring and individual key files (binary, ASCII-armored, keybox or directories of keys)
+	keyPaths := repoCfg.Verification.GetKeyPaths(a.Config.ConfigDir)
+	if keyringPath := repoCfg.Verification.GetKeyringPath(a.Config.ConfigDir); keyringPath != "" {
+		keyPaths = append([]string{keyringPath}, keyPaths...)
+	}
+	for _, feedOpts := range repoCfg.Feeds {
+		if feedOpts.Type != feed.FeedTypePPA {
+			continue
+		}
+		keyPath, err := feed.PPASigningKey(ctx, a.HTTPClient, feedOpts, a.Config.Directories.GetCachePath())
+		if err != nil {
+			return nil, err
+		}
+		keyPaths = append(keyPaths, keyPath)
 	}
 
-	// Process individual key files (may be ASCII-armored .asc files)
-	for _, keyPath := range repoCfg.Verification.GetKeyPaths(a.Config.ConfigDir) {
+	var keys openpgp.EntityList
+	for _, keyPath := range keyPaths {
 		keyFile, cleanup, err := prepareKeyFile(keyPath)
 		if err != nil {
 			return nil, err
@@ -157,6 +293,13 @@ func (a *Application) initializeVerifier(repoCfg *config.RepositoryConfig) (*deb
 		defer cleanup()
 
 		verifier.AddKeyring(keyFile)
+
+		// Kept for the expiry checks, aptly's verifier doesn't consider validity times
+		entities, err := debext.ReadKeys(keyPath)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, entities...)
 	}
 
 	// Initialize the keyring (loads keys into memory)
@@ -169,104 +312,162 @@ func (a *Application) initializeVerifier(repoCfg *config.RepositoryConfig) (*deb
 		Verifier:         verifier,
 		AcceptUnsigned:   false, // Reject unsigned files - all files must be signed
 		IgnoreSignatures: false, // Verify all signatures
+		Keys:             keys,
+		ClockSkew:        a.Config.Fetch.ClockSkewDuration(),
 	}, nil
 }
 
 // initializeSigner creates a signer from config and returns public keys in both formats
-// TODO: extend debext with better signer handling.
-// - offer SetKey() if provided whether or not keyring is set
+// Configured keys are signed with by debext.Signer, which selects subkeys and adds a signature per
+// additional key. The published public key then contains all keys, so clients trusting either the old
+// or the new key keep verifying during a key rotation
 func initializeSigner(cfg *config.Config) (pgp.Signer, []byte, []byte, string, string, func(), error) {
-	signer := &pgp.GoSigner{}
-
 	// Check if custom keys are configured
 	privateKeyPath := cfg.Signing.GetPrivateKeyPath(cfg.ConfigDir)
 	publicKeyPath := cfg.Signing.GetPublicKeyPath(cfg.ConfigDir)
 
-	var publicKeyASCII, publicKeyBinary []byte
-	var preparedPublic, preparedPrivate string
-	var cleanupFuncs []func()
-
-	// Combined cleanup function
-	cleanup := func() {
-		for _, fn := range cleanupFuncs {
-			fn()
+	// gpg signs with keys held by gpg-agent, e.g. on a hardware token, and exports their public keys
+	if cfg.Signing.UsesGPGAgent() {
+		signer := &debext.GPGSigner{
+			Keys:       []string{cfg.Signing.Key},
+			GPG:        cfg.Signing.GPG,
+			Home:       cfg.Signing.GetGnuPGHome(cfg.ConfigDir),
+			Passphrase: cfg.Signing.Passphrase,
 		}
-	}
-
-	// If keys are provided, use them; otherwise GoSigner falls back to pubring.gpg/secring.gpg
-	if privateKeyPath != "" && publicKeyPath != "" {
-		// Read and store public key
-		var err error
-		publicKeyASCII, err = os.ReadFile(publicKeyPath)
-		if err != nil {
+		for _, key := range cfg.Signing.Additional {
+			signer.Keys = append(signer.Keys, key.Key)
+		}
+		if err := signer.Init(); err != nil {
 			return nil, nil, nil, "", "", nil, err
 		}
-
-		// Convert ASCII key to binary format
-		publicKeyBinary, err = armorDecode(publicKeyASCII)
+		publicKeyASCII, err := signer.ExportPublicKeys()
 		if err != nil {
 			return nil, nil, nil, "", "", nil, err
 		}
-
-		// Prepare key files (convert to binary if needed)
-		preparedPublic, cleanupPublic, err := prepareKeyFile(publicKeyPath)
+		publicKeyBinary, err := armorDecode(publicKeyASCII)
 		if err != nil {
 			return nil, nil, nil, "", "", nil, err
 		}
-		cleanupFuncs = append(cleanupFuncs, cleanupPublic)
+		return signer, publicKeyASCII, publicKeyBinary, "", "", func() {}, nil
+	}
 
-		preparedPrivate, cleanupPrivate, err := prepareKeyFile(privateKeyPath)
-		if err != nil {
-			cleanup()
+	// Without keys GoSigner falls back to pubring.gpg/secring.gpg
+	if privateKeyPath == "" || publicKeyPath == "" {
+		signer := &pgp.GoSigner{}
+		if cfg.Signing.Key != "" {
+			signer.SetKey(cfg.Signing.Key)
+		}
+		if cfg.Signing.Passphrase != "" {
+			signer.SetPassphrase(cfg.Signing.Passphrase, "")
+		}
+		if err := signer.Init(); err != nil {
 			return nil, nil, nil, "", "", nil, err
 		}
-		cleanupFuncs = append(cleanupFuncs, cleanupPrivate)
+		return signer, nil, nil, "", "", func() {}, nil
+	}
+
+	var cleanupFuncs []func()
+
+	// Combined cleanup function
+	cleanup := func() {
+		for _, fn := range cleanupFuncs {
+			fn()
+		}
+	}
 
-		// Set custom keyring paths
-		signer.SetKeyRing(preparedPublic, preparedPrivate)
+	// Prepare key files (convert to binary if needed)
+	preparedPublic, cleanupPublic, err := prepareKeyFile(publicKeyPath)
+	if err != nil {
+		return nil, nil, nil, "", "", nil, err
 	}
+	cleanupFuncs = append(cleanupFuncs, cleanupPublic)
 
-	// Set passphrase if provided
-	if cfg.Signing.Passphrase != "" {
-		signer.SetPassphrase(cfg.Signing.Passphrase, "")
+	preparedPrivate, cleanupPrivate, err := prepareKeyFile(privateKeyPath)
+	if err != nil {
+		cleanup()
+		return nil, nil, nil, "", "", nil, err
+	}
+	cleanupFuncs = append(cleanupFuncs, cleanupPrivate)
+
+	signer := &debext.Signer{Keys: []debext.SigningKey{{
+		Path:       preparedPrivate,
+		KeyRef:     cfg.Signing.Key,
+		Passphrase: cfg.Signing.Passphrase,
+	}}}
+	publicKeyPaths := []string{publicKeyPath}
+	for _, key := range cfg.Signing.Additional {
+		signer.Keys = append(signer.Keys, debext.SigningKey{
+			Path:       key.GetPrivateKeyPath(cfg.ConfigDir),
+			KeyRef:     key.Key,
+			Passphrase: key.Passphrase,
+		})
+		publicKeyPaths = append(publicKeyPaths, key.GetPublicKeyPath(cfg.ConfigDir))
 	}
 
-	// Initialize the signer (loads keys from keyring files into memory)
+	// Initialize the signer (loads and unlocks the keys)
 	if err := signer.Init(); err != nil {
 		cleanup()
 		return nil, nil, nil, "", "", nil, err
 	}
 
+	publicKeyASCII, publicKeyBinary, err := readPublicKeys(publicKeyPaths)
+	if err != nil {
+		cleanup()
+		return nil, nil, nil, "", "", nil, err
+	}
+
 	return signer, publicKeyASCII, publicKeyBinary, preparedPublic, preparedPrivate, cleanup, nil
 }
 
+// readPublicKeys returns the public signing keys ASCII-armored and binary
+// A single key file is published as-is, several keys are combined into one armored block since
+// gpg --dearmor of the install instructions only reads the first block
+func readPublicKeys(paths []string) ([]byte, []byte, error) {
+	var asciiKeys, binaryKeys []byte
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		// Convert ASCII key to binary format
+		binary, err := armorDecode(data)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read public key %s: %w", path, err)
+		}
+		asciiKeys = data
+		binaryKeys = append(binaryKeys, binary...)
+	}
+	if len(paths) == 1 {
+		return asciiKeys, binaryKeys, nil
+	}
+
+	var buf bytes.Buffer
+	if err := debext.WriteArmored(&buf, openpgp.PublicKeyType, func(w io.Writer) error {
+		_, err := w.Write(binaryKeys)
+		return err
+	}); err != nil {
+		return nil, nil, err
+	}
+	return buf.Bytes(), binaryKeys, nil
+}
+
 // prepareKeyFile ensures a key file is in binary format for aptly's GoVerifier.
-// If the file is ASCII-armored, it converts it to binary in a temp directory.
-// Returns the path to use and an optional cleanup function.
+// Binary keyrings are used as-is. Armored files (also with several concatenated blocks),
+// GnuPG keybox files and directories of key files are converted to a binary keyring in a
+// temp file. Returns the path to use and an optional cleanup function.
 func prepareKeyFile(keyPath string) (string, func(), error) {
-	// Read the file to detect format
-	f, err := os.Open(keyPath)
+	keys, err := debext.ReadKeys(keyPath)
 	if err != nil {
 		return "", nil, err
 	}
-	defer f.Close()
+	slog.Debug("Loaded keys", "path", keyPath, "keys", debext.KeyIDs(keys))
 
-	// Check if it's ASCII-armored by reading the first 5 bytes
-	header := make([]byte, 5)
-	n, _ := f.Read(header)
-	isArmored := n == 5 && bytes.Equal(header, []byte("-----"))
-
-	if !isArmored {
-		// Probably binary format, use as-is (no cleanup needed)
-		return keyPath, func() {}, nil
-	}
-
-	// ASCII-armored, need to convert to binary
-	_, _ = f.Seek(0, 0)
-
-	keys, err := openpgp.ReadArmoredKeyRing(f)
-	if err != nil {
-		return "", nil, fmt.Errorf("failed to read armored keyring: %w", err)
+	// Binary keyrings need no conversion
+	if info, err := os.Stat(keyPath); err == nil && !info.IsDir() {
+		if data, err := os.ReadFile(keyPath); err == nil && debext.DetectKeyFormat(data) == debext.KeyFormatBinary {
+			return keyPath, func() {}, nil
+		}
 	}
 
 	// Create temp file for binary keyring
@@ -288,8 +489,8 @@ func prepareKeyFile(keyPath string) (string, func(), error) {
 	for _, entity := range keys {
 		var serializeErr error
 		if hasPrivateKey && entity.PrivateKey != nil {
-			// Serialize private key
-			serializeErr = entity.SerializePrivate(tmpFile, nil)
+			// Serialize private key, keeping its signatures since encrypted keys can't sign again
+			serializeErr = entity.SerializePrivateWithoutSigning(tmpFile, nil)
 		} else {
 			// Serialize public key
 			serializeErr = entity.Serialize(tmpFile)
@@ -298,7 +499,7 @@ func prepareKeyFile(keyPath string) (string, func(), error) {
 		if serializeErr != nil {
 			_ = tmpFile.Close()
 			_ = os.Remove(tmpFile.Name())
-			return "", nil, fmt.Errorf("failed to serialize key: %w", serializeErr)
+			return "", nil, fmt.Errorf("failed to serialize key %s of %s: %w", entity.PrimaryKey.KeyIdString(), keyPath, serializeErr)
 		}
 	}
 
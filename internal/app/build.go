@@ -0,0 +1,50 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/dionysius/aarg/internal/log"
+)
+
+// BuildOptions configures the builds started by the watch and daemon commands
+type BuildOptions struct {
+	Generate GenerateOptions // Options of the generate phase
+	Publish  bool            // Publish after generating
+}
+
+// Build runs the complete pipeline for the given repositories: fetch, generate and, if publish is set,
+// publish followed by the upload of release assets. Builds into opts.Dir are never published
+func (a *Application) Build(ctx context.Context, repoNames []string, opts GenerateOptions, publish, force bool) error {
+	if err := a.Fetch(ctx, repoNames); err != nil {
+		return fmt.Errorf("fetch phase failed: %w", err)
+	}
+
+	if err := a.Generate(ctx, repoNames, opts); err != nil {
+		return fmt.Errorf("generate phase failed: %w", err)
+	}
+
+	if !publish || opts.Dir != "" {
+		slog.Info("Skipping publish phase")
+		return nil
+	}
+
+	// Publishing covers the whole build, its duration is reported for every repository built
+	start := time.Now()
+	err := a.Publish(ctx, "", "", force)
+	log.RecordTiming("publish", time.Since(start), repoNames...)
+	if err != nil {
+		return fmt.Errorf("publish phase failed: %w", err)
+	}
+
+	// Upload release assets once production is updated, with public staging this happens on promote
+	if a.Config.Directories.PublicStaging == "" {
+		if err := a.Upload(ctx, repoNames); err != nil {
+			return fmt.Errorf("upload phase failed: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,89 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/dionysius/aarg/internal/common"
+)
+
+// ErrNoSchedules is returned when the daemon is started without any repository having a schedule
+var ErrNoSchedules = errors.New("no repository has a schedule, set schedule in the repository configuration")
+
+// Daemon builds every repository with a schedule whenever it's due: fetch, generate and publish
+// Repositories due at the same time are built together, builds run one at a time with the shared
+// worker pools. Runs missed during a long build are caught up once instead of repeatedly.
+// Every build writes its own report and notifies about its outcome.
+func (a *Application) Daemon(ctx context.Context, opts BuildOptions) error {
+	schedules := make(map[string]*common.Schedule)
+	for _, repo := range a.Config.Repositories {
+		if repo.Schedule == "" {
+			continue
+		}
+		schedule, err := common.ParseSchedule(repo.Schedule)
+		if err != nil {
+			return err
+		}
+		schedules[repo.Name] = schedule
+	}
+	if len(schedules) == 0 {
+		return ErrNoSchedules
+	}
+
+	now := time.Now()
+	next := make(map[string]time.Time, len(schedules))
+	for _, name := range slices.Sorted(maps.Keys(schedules)) {
+		next[name] = schedules[name].Next(now)
+		if next[name].IsZero() {
+			slog.Warn("Schedule never matches, repository is not built", "repository", name, "schedule", schedules[name].String())
+			delete(next, name)
+			continue
+		}
+		slog.Info("Scheduled repository", "repository", name, "schedule", schedules[name].String(), "next", common.FormatDisplayTime(next[name]))
+	}
+
+	for len(next) > 0 {
+		due := slices.MinFunc(slices.Collect(maps.Values(next)), time.Time.Compare)
+		timer := time.NewTimer(time.Until(due))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			slog.Info("Daemon stopped")
+			return nil
+		case <-timer.C:
+		}
+
+		// Collect all due repositories and schedule their next run
+		now := time.Now()
+		var repoNames []string
+		for _, name := range slices.Sorted(maps.Keys(next)) {
+			if next[name].After(now) {
+				continue
+			}
+			repoNames = append(repoNames, name)
+			if next[name] = schedules[name].Next(now); next[name].IsZero() {
+				delete(next, name)
+			}
+		}
+
+		slog.Info("Building scheduled repositories", "repositories", repoNames)
+		start := time.Now()
+		err := a.Build(ctx, repoNames, opts.Generate, opts.Publish, false)
+		if err != nil && ctx.Err() != nil {
+			slog.Info("Daemon stopped")
+			return nil
+		}
+		if err != nil {
+			slog.Error("Scheduled build failed", "repositories", repoNames, "error", err)
+		} else {
+			slog.Info("Scheduled build complete", "repositories", repoNames, "duration", time.Since(start).Round(time.Millisecond))
+		}
+		a.ReportBuild(ctx, "daemon", start, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,297 @@
+package app
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Change kinds of packages and index files between two builds
+const (
+	ChangeAdded   = "added"
+	ChangeRemoved = "removed"
+	ChangeChanged = "changed"
+)
+
+// diffPublic names the public build in DiffBuilds
+const diffPublic = "public"
+
+// BuildDiff are the changes of packages and index files from one build to another
+type BuildDiff struct {
+	From     string          // Resolved directory of the older build
+	To       string          // Resolved directory of the newer build
+	Packages []PackageChange // Sorted by repository and package
+	Indices  []FileChange    // Sorted by path
+}
+
+// PackageChange is a package added, removed or published in other versions
+type PackageChange struct {
+	Repository string
+	Package    string   // Keyed as dist/component/arch/name
+	Change     string   // ChangeAdded, ChangeRemoved or ChangeChanged
+	Added      []string // Versions only in the newer build
+	Removed    []string // Versions only in the older build
+}
+
+// FileChange is an index file below dists/ added, removed or with other content
+type FileChange struct {
+	Path   string // Relative to the build directory
+	Change string // ChangeAdded, ChangeRemoved or ChangeChanged
+}
+
+// Count returns the number of package changes of a kind
+func (d *BuildDiff) Count(change string) int {
+	count := 0
+	for _, pkg := range d.Packages {
+		if pkg.Change == change {
+			count++
+		}
+	}
+	return count
+}
+
+// DiffBuilds compares the packages and index files of two builds without modifying them
+// Builds are staging build names or "public". Without to the newest staging build is compared,
+// without from the public build, so no arguments show what publishing the latest build changes
+func (a *Application) DiffBuilds(from, to string) (*BuildDiff, error) {
+	if to == "" {
+		newest, err := a.newestStagingBuild()
+		if err != nil {
+			return nil, err
+		}
+		to = newest
+	}
+	if from == "" {
+		from = diffPublic
+	}
+
+	fromDir, err := a.resolveDiffDir(from)
+	if err != nil {
+		return nil, err
+	}
+	toDir, err := a.resolveDiffDir(to)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &BuildDiff{From: fromDir, To: toDir}
+	if diff.Packages, err = diffPackages(fromDir, toDir); err != nil {
+		return nil, err
+	}
+	if diff.Indices, err = diffIndices(fromDir, toDir); err != nil {
+		return nil, err
+	}
+	return diff, nil
+}
+
+// resolveDiffDir returns the resolved directory of a staging build name or the public build
+func (a *Application) resolveDiffDir(name string) (string, error) {
+	dir := a.Config.Directories.GetPublicPath()
+	if name != diffPublic {
+		var err error
+		if dir, err = a.resolvePublishDir(name); err != nil {
+			return "", err
+		}
+	}
+
+	resolved, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return "", fmt.Errorf("build %s not found: %w", name, err)
+	}
+	return resolved, nil
+}
+
+// newestStagingBuild returns the name of the newest staging build
+func (a *Application) newestStagingBuild() (string, error) {
+	entries, err := os.ReadDir(a.Config.Directories.GetStagingPath())
+	if err != nil {
+		return "", fmt.Errorf("failed to read staging directory: %w", err)
+	}
+
+	newest := ""
+	for _, entry := range entries {
+		if entry.IsDir() && isStagingBuildName(entry.Name()) && entry.Name() > newest {
+			newest = entry.Name()
+		}
+	}
+	if newest == "" {
+		return "", fmt.Errorf("no staging build available, run generate first")
+	}
+	return newest, nil
+}
+
+// diffPackages compares the packages of two builds per repository
+func diffPackages(fromDir, toDir string) ([]PackageChange, error) {
+	before, err := collectPublishedPackages(fromDir)
+	if err != nil {
+		return nil, err
+	}
+	after, err := collectPublishedPackages(toDir)
+	if err != nil {
+		return nil, err
+	}
+
+	repos := slices.Collect(maps.Keys(before))
+	for repo := range after {
+		if _, ok := before[repo]; !ok {
+			repos = append(repos, repo)
+		}
+	}
+	slices.Sort(repos)
+
+	var changes []PackageChange
+	for _, repo := range repos {
+		keys := slices.Collect(maps.Keys(before[repo]))
+		for key := range after[repo] {
+			if _, ok := before[repo][key]; !ok {
+				keys = append(keys, key)
+			}
+		}
+		slices.Sort(keys)
+
+		for _, key := range keys {
+			oldVersions, existed := before[repo][key]
+			newVersions, exists := after[repo][key]
+
+			change := PackageChange{Repository: repo, Package: key}
+			for _, version := range newVersions {
+				if !slices.Contains(oldVersions, version) {
+					change.Added = append(change.Added, version)
+				}
+			}
+			for _, version := range oldVersions {
+				if !slices.Contains(newVersions, version) {
+					change.Removed = append(change.Removed, version)
+				}
+			}
+
+			switch {
+			case !existed:
+				change.Change = ChangeAdded
+			case !exists:
+				change.Change = ChangeRemoved
+			case len(change.Added) > 0 || len(change.Removed) > 0:
+				change.Change = ChangeChanged
+			default:
+				continue
+			}
+			changes = append(changes, change)
+		}
+	}
+
+	return changes, nil
+}
+
+// diffIndices compares the files below dists/ of every repository of two builds
+func diffIndices(fromDir, toDir string) ([]FileChange, error) {
+	before, err := indexFiles(fromDir)
+	if err != nil {
+		return nil, err
+	}
+	after, err := indexFiles(toDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []FileChange
+	for path := range before {
+		if !after[path] {
+			changes = append(changes, FileChange{Path: path, Change: ChangeRemoved})
+		}
+	}
+	for path := range after {
+		if !before[path] {
+			changes = append(changes, FileChange{Path: path, Change: ChangeAdded})
+			continue
+		}
+		same, err := sameContent(filepath.Join(fromDir, path), filepath.Join(toDir, path))
+		if err != nil {
+			return nil, err
+		}
+		if !same {
+			changes = append(changes, FileChange{Path: path, Change: ChangeChanged})
+		}
+	}
+
+	slices.SortFunc(changes, func(a, b FileChange) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+	return changes, nil
+}
+
+// indexFiles returns the files below <repo>/dists/ of a build relative to it
+func indexFiles(buildDir string) (map[string]bool, error) {
+	distsDirs, err := filepath.Glob(filepath.Join(buildDir, "*", "dists"))
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string]bool)
+	for _, distsDir := range distsDirs {
+		err := filepath.WalkDir(distsDir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			rel, err := filepath.Rel(buildDir, path)
+			if err != nil {
+				return err
+			}
+			files[filepath.ToSlash(rel)] = true
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// sameContent reports whether two files have the same content, hardlinked files are not read
+func sameContent(a, b string) (bool, error) {
+	infoA, err := os.Stat(a)
+	if err != nil {
+		return false, err
+	}
+	infoB, err := os.Stat(b)
+	if err != nil {
+		return false, err
+	}
+	if os.SameFile(infoA, infoB) {
+		return true, nil
+	}
+	if infoA.Size() != infoB.Size() {
+		return false, nil
+	}
+
+	hashA, err := fileSHA256(a)
+	if err != nil {
+		return false, err
+	}
+	hashB, err := fileSHA256(b)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(hashA, hashB), nil
+}
+
+// fileSHA256 returns the SHA256 checksum of a file
+func fileSHA256(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
@@ -4,18 +4,31 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"time"
 
 	"github.com/dionysius/aarg/internal/common"
 	"github.com/dionysius/aarg/internal/config"
 	"github.com/dionysius/aarg/internal/feed"
 	"github.com/dionysius/aarg/internal/log"
+	"github.com/dionysius/aarg/internal/telemetry"
 )
 
 // Fetch downloads and verifies packages from configured feeds for specified repositories
-func (a *Application) Fetch(ctx context.Context, repoNames []string) error {
+func (a *Application) Fetch(ctx context.Context, repoNames []string) (err error) {
+	ctx, span := telemetry.Start(ctx, "fetch")
+	defer func() { telemetry.End(span, err) }()
+
+	// Fail early instead of running out of disk space halfway
+	if err := a.preflightFetch(); err != nil {
+		return err
+	}
+
 	// Process all feeds from all repositories in parallel using main worker pool
 	group := a.MainPool.NewGroup()
 
+	// Fetch upstream feeds referenced by several repositories only once
+	shared := feed.NewSharedFetch()
+
 	for _, name := range repoNames {
 		// Find repository by name
 		var repo *config.RepositoryConfig
@@ -28,9 +41,10 @@ func (a *Application) Fetch(ctx context.Context, repoNames []string) error {
 		if repo == nil {
 			return fmt.Errorf("repository not found: %s", name)
 		}
+		log.CountRepositories(repo.Name)
 
 		// Initialize verifier for this repository
-		verifier, err := a.initializeVerifier(repo)
+		verifier, err := a.initializeVerifier(ctx, repo)
 		if err != nil {
 			return fmt.Errorf("failed to initialize verifier for %s: %w", repo.Name, err)
 		}
@@ -43,19 +57,13 @@ func (a *Application) Fetch(ctx context.Context, repoNames []string) error {
 
 			slog.Info("Fetching", "repository", repo.Name, "feed", string(opts.Type)+":"+opts.Name)
 
-			// Expand feed options based on type (OBS/APT with multiple distributions)
-			var expandedFeedOpts []*feed.FeedOptions
-			feedType := feed.FeedType(opts.Type)
-			switch feedType {
-			case feed.FeedTypeOBS:
-				expandedFeedOpts = feed.ExpandOBSFeedOptions(opts)
-			case feed.FeedTypeAPT:
-				expandedFeedOpts = feed.ExpandAptFeedOptions(opts)
-			case feed.FeedTypeGitHub:
-				// GitHub feeds don't expand
-				expandedFeedOpts = []*feed.FeedOptions{opts}
-			default:
-				return fmt.Errorf("unsupported feed type: %s", feedType)
+			// Expand feed options into feed instances (OBS/APT with multiple distributions)
+			expandedFeedOpts, err := feed.Expand(opts)
+			if err != nil {
+				return err
+			}
+			if len(expandedFeedOpts) == 0 && feed.CapabilitiesOf(opts.Type).RequiresDistributionMapping {
+				slog.Warn("Feed has no distributions configured, nothing to fetch", "repository", repo.Name, "feed", string(opts.Type)+":"+opts.Name)
 			}
 
 			// Create and submit a feed task for each expanded feed option
@@ -63,7 +71,22 @@ func (a *Application) Fetch(ctx context.Context, repoNames []string) error {
 				// Capture for closure
 				feedOpt := expandedOpts
 
-				group.SubmitErr(func() error {
+				group.SubmitErr(func() (err error) {
+					ctx, span := telemetry.Start(ctx, "fetch.feed",
+						telemetry.RepositoryKey.String(repo.Name),
+						telemetry.FeedTypeKey.String(string(feedOpt.Type)),
+						telemetry.FeedNameKey.String(feedOpt.Name),
+					)
+					defer func() { telemetry.End(span, err) }()
+
+					start := time.Now()
+					defer func() {
+						log.RecordTiming("fetch", time.Since(start), repo.Name)
+						if err != nil {
+							log.RecordError(repo.Name, err)
+						}
+					}()
+
 					// Create scoped storage for this expanded feed
 					storage := common.NewStorage(
 						a.Downloader,
@@ -71,25 +94,43 @@ func (a *Application) Fetch(ctx context.Context, repoNames []string) error {
 						a.Config.Directories.GetTrustedPath(),
 						feedOpt.RelativePath,
 					)
-
-					// Create feed instance based on type (after expansion, OBS becomes APT)
-					var feedInst feed.Feed
-					var err error
-
-					switch feed.FeedType(feedOpt.Type) {
-					case feed.FeedTypeGitHub:
-						feedInst, err = feed.NewGithub(storage, a.GitHubClient, feedVerifier, feedOpt, &repo.RepositoryOptions, a.MainPool)
-					case feed.FeedTypeAPT:
-						feedInst, err = feed.NewApt(storage, feedVerifier, feedOpt, &repo.RepositoryOptions, a.MainPool)
-					default:
-						return fmt.Errorf("unsupported expanded feed type: %s", feedOpt.Type)
-					}
+					storage.SetMetadataStore(a.MetadataStore)
+
+					// Create feed instance from the registered type (after expansion, OBS becomes APT)
+					feedInst, err := feed.New(feedOpt, feed.Dependencies{
+						Storage:      storage,
+						GitHubClient: a.GitHubClient,
+						HTTPClient:   a.HTTPClient,
+						OBS:          feed.OBSCredentials{Username: a.Config.OBS.Username, Password: a.Config.OBS.Password},
+						Verifier:     feedVerifier,
+						Repository:   &repo.RepositoryOptions,
+						Pool:         a.MainPool,
+						Plugins:      a.Config.Plugins,
+						Shared:       shared,
+						ControlStore: a.ControlStore,
+					})
 					if err != nil {
 						return fmt.Errorf("failed to create feed %s: %w", feedOpt.Name, err)
 					}
 
-					// Run feed download
-					if err := feedInst.Run(ctx); err != nil {
+					// Run feed download, a slow feed is cancelled after its timeout without failing the run
+					timeout := a.feedTimeout(feedOpt)
+					feedCtx := ctx
+					if timeout > 0 {
+						var cancel context.CancelFunc
+						feedCtx, cancel = context.WithTimeout(ctx, timeout)
+						defer cancel()
+					}
+					err = feedInst.Run(feedCtx)
+					if reporter, ok := feedInst.(feed.StatsReporter); ok {
+						log.RecordAssets(repo.Name, feedOpt.Name, reporter.Stats())
+					}
+					if err != nil {
+						if timeout > 0 && ctx.Err() == nil && feedCtx.Err() != nil {
+							slog.Warn("Feed timed out and was cancelled, keeping its previously fetched packages",
+								"repository", repo.Name, "feed", string(feedOpt.Type)+":"+feedOpt.Name, "timeout", timeout, "error", err)
+							return nil
+						}
 						return fmt.Errorf("failed to run feed %s: %w", feedOpt.Name, err)
 					}
 
@@ -104,7 +145,22 @@ func (a *Application) Fetch(ctx context.Context, repoNames []string) error {
 		return err
 	}
 
-	slog.Info("Fetch complete", log.Success())
+	// Keep the downloads cache within its limits
+	if a.Config.GC.Downloads.Policy().IsEnabled() {
+		if err := a.collectDownloads(ctx); err != nil {
+			slog.Warn("Failed to collect downloads cache", "error", err)
+		}
+	}
+
+	slog.Info("Fetch complete", "downloaded", common.FormatSize(uint64(a.Downloader.Downloaded())), log.Success())
 
 	return nil
 }
+
+// feedTimeout returns the time after which fetching a feed is cancelled, 0 = unlimited
+func (a *Application) feedTimeout(feedOpts *feed.FeedOptions) time.Duration {
+	if feedOpts.Timeout > 0 {
+		return time.Duration(feedOpts.Timeout) * time.Second
+	}
+	return time.Duration(a.Config.Fetch.FeedTimeout) * time.Second
+}
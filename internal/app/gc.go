@@ -0,0 +1,49 @@
+package app
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/dionysius/aarg/internal/common"
+	"github.com/dionysius/aarg/internal/log"
+	"github.com/dionysius/aarg/internal/telemetry"
+)
+
+// GC applies the configured cleanup policies
+// downloads evicts files of the downloads cache not hardlinked into trusted storage
+func (a *Application) GC(ctx context.Context, downloads bool) (err error) {
+	ctx, span := telemetry.Start(ctx, "gc")
+	defer func() { telemetry.End(span, err) }()
+
+	if downloads {
+		if !a.Config.GC.Downloads.Policy().IsEnabled() {
+			slog.Info("No downloads cache policy configured, set gc.downloads in config")
+		} else if err := a.collectDownloads(ctx); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// collectDownloads evicts files of the downloads cache according to the configured policy
+func (a *Application) collectDownloads(ctx context.Context) (err error) {
+	_, span := telemetry.Start(ctx, "gc.downloads")
+	defer func() { telemetry.End(span, err) }()
+
+	downloadsDir := a.Config.Directories.GetDownloadsPath()
+	stats, err := common.EvictCache(downloadsDir, a.Config.GC.Downloads.Policy(), time.Now())
+	if err != nil {
+		return err
+	}
+
+	slog.Info("Downloads cache collected",
+		"evicted", stats.Files,
+		"freed", common.FormatSize(stats.Bytes),
+		"cached", stats.Kept,
+		"size", common.FormatSize(stats.KeptBytes),
+		"trusted", stats.Linked,
+		log.Success())
+	return nil
+}
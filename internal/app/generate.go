@@ -6,23 +6,123 @@ import (
 	"log/slog"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"sort"
+	"strings"
+	"sync"
 	"time"
 
-	"github.com/aptly-dev/aptly/pgp"
-	"github.com/dionysius/aarg/debext"
 	"github.com/dionysius/aarg/internal/common"
 	"github.com/dionysius/aarg/internal/compose"
 	"github.com/dionysius/aarg/internal/config"
-	"github.com/dionysius/aarg/internal/feed"
 	"github.com/dionysius/aarg/internal/log"
+	"github.com/dionysius/aarg/internal/telemetry"
 )
 
+// stagingTimestampFormat is the directory name format of staging builds
+const stagingTimestampFormat = "20060102-150405"
+
+// stagingLabelPattern matches labels appended to the timestamp of staging builds
+var stagingLabelPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+// GenerateOptions configures where a build is generated
+type GenerateOptions struct {
+	Label       string // Appended to the timestamp of the staging build, empty = timestamp only
+	Dir         string // One-off build into this directory outside the staging lineage, empty = staging directory
+	Incremental bool   // Reuse unchanged distributions of the previous build, also enabled by generate.incremental
+	VerifyPool  string // Checksum verification of trusted files linked into the pool ("none", "sample", "full"), empty = generate.verify_pool
+
+	Distributions []string // Distributions to generate, the others are hardlinked from the previous build, empty = all
+}
+
+// isStagingBuildName reports whether name matches the staging build directory format
+// Labeled builds have the label appended to the timestamp, separated by a dash
+func isStagingBuildName(name string) bool {
+	if len(name) < len(stagingTimestampFormat) {
+		return false
+	}
+	if _, err := time.Parse(stagingTimestampFormat, name[:len(stagingTimestampFormat)]); err != nil {
+		return false
+	}
+
+	rest := name[len(stagingTimestampFormat):]
+	if rest == "" {
+		return true
+	}
+	label, ok := strings.CutPrefix(rest, "-")
+	return ok && stagingLabelPattern.MatchString(label)
+}
+
+// stagingBuildName returns the staging directory name for a build started at t
+func stagingBuildName(t time.Time, label string) (string, error) {
+	name := t.Format(stagingTimestampFormat)
+	if label == "" {
+		return name, nil
+	}
+	if !stagingLabelPattern.MatchString(label) {
+		return "", fmt.Errorf("invalid staging label %q: use up to 64 letters, digits, dots, dashes and underscores", label)
+	}
+	return name + "-" + label, nil
+}
+
+// oneOffBuildDir resolves the directory of a one-off build
+// It must be outside the staging directory so it never becomes part of the staging lineage
+func (a *Application) oneOffBuildDir(dir string) (string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+
+	stagingBase := a.Config.Directories.GetStagingPath()
+	if rel, err := filepath.Rel(stagingBase, abs); err == nil && !strings.HasPrefix(rel, "..") {
+		return "", fmt.Errorf("staging dir %s is inside the staging directory %s, use a label instead", abs, stagingBase)
+	}
+
+	entries, err := os.ReadDir(abs)
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+	if len(entries) > 0 {
+		return "", fmt.Errorf("staging dir %s is not empty", abs)
+	}
+
+	return abs, nil
+}
+
 // Generate generates APT repository structures and web page for specified repositories
-func (a *Application) Generate(ctx context.Context, repoNames []string) (err error) {
+// One-off builds into opts.Dir are neither linked as public build nor cleaned up or publishable
+func (a *Application) Generate(ctx context.Context, repoNames []string, opts GenerateOptions) (err error) {
+	// Order composers by their dependencies
+	composers, err := compose.Resolve(a.Config.Generate.Compose)
+	if err != nil {
+		return err
+	}
+
 	// Create timestamped staging directory
-	timestamp := time.Now().Format("20060102-150405")
+	timestamp, err := stagingBuildName(time.Now(), opts.Label)
+	if err != nil {
+		return err
+	}
 	stagingPath := filepath.Join(a.Config.Directories.GetStagingPath(), timestamp)
+	if opts.Dir != "" {
+		if stagingPath, err = a.oneOffBuildDir(opts.Dir); err != nil {
+			return err
+		}
+	}
+
+	// Check trusted files before they are signed into a Release file
+	if opts.VerifyPool == "" {
+		opts.VerifyPool = a.Config.Generate.VerifyPool
+	}
+	if err := config.ValidateVerifyPool(opts.VerifyPool); err != nil {
+		return fmt.Errorf("invalid pool verification: %w", err)
+	}
+
+	// Fail early instead of running out of disk space halfway
+	if err := a.preflightGenerate(); err != nil {
+		return err
+	}
 
 	if err := os.MkdirAll(stagingPath, 0755); err != nil {
 		return fmt.Errorf("failed to create staging directory: %w", err)
@@ -38,9 +138,19 @@ func (a *Application) Generate(ctx context.Context, repoNames []string) (err err
 		}
 	}()
 
+	ctx, span := telemetry.Start(ctx, "generate")
+	defer func() { telemetry.End(span, err) }()
+
+	// Unchanged distributions are hardlinked from the previous build instead of regenerated and re-signed
+	opts.Incremental = opts.Incremental || a.Config.Generate.Incremental
+
 	// Process all repositories in parallel
 	group := a.MainPool.NewGroup()
 
+	// Newest package of all repositories for the health file
+	var newest time.Time
+	var newestMu sync.Mutex
+
 	for _, name := range repoNames {
 		// Find repository by name
 		var repo *config.RepositoryConfig
@@ -54,11 +164,24 @@ func (a *Application) Generate(ctx context.Context, repoNames []string) (err err
 			err = fmt.Errorf("repository not found: %s", name)
 			return err
 		}
+		log.CountRepositories(repo.Name)
 
 		// Capture loop variables for goroutine
 		repoToGenerate := repo
 		group.SubmitErr(func() error {
-			return a.generateRepository(ctx, repoToGenerate, stagingPath)
+			results, err := a.generateRepository(ctx, repoToGenerate, composers, stagingPath, opts)
+			if err != nil {
+				return err
+			}
+
+			if results.Apt != nil {
+				newestMu.Lock()
+				if results.Apt.Newest().After(newest) {
+					newest = results.Apt.Newest()
+				}
+				newestMu.Unlock()
+			}
+			return nil
 		})
 	}
 
@@ -67,18 +190,33 @@ func (a *Application) Generate(ctx context.Context, repoNames []string) (err err
 		return err
 	}
 
+	// The build being replaced, for carrying over repositories and the summary
+	previousBuild, previousErr := filepath.EvalSymlinks(a.currentPublicPath())
+	if previousErr != nil {
+		previousBuild = ""
+	}
+
+	// Repositories that weren't selected stay as published, the shared pages are refreshed below
+	repositories := len(repoNames)
+	if previousBuild != "" {
+		carried, carriedNewest, err := a.carryOverRepositories(previousBuild, stagingPath, repoNames)
+		if err != nil {
+			return err
+		}
+		repositories += carried
+		if carriedNewest.After(newest) {
+			newest = carriedNewest
+		}
+	}
+
 	// Call Index on composers that need post-processing
-	for _, composerName := range a.Config.Generate.Compose {
-		switch composerName {
-		case "apt":
-			// APT composer Index copies signing keys to staging directory
-			if err = a.copySigningKeys(stagingPath); err != nil {
-				return err
-			}
-		case "web":
-			if err = a.webIndex(ctx, stagingPath); err != nil {
-				return err
-			}
+	deps := a.composeDependencies(stagingPath)
+	for _, composer := range composers {
+		if composer.Index == nil {
+			continue
+		}
+		if err = composer.Index(ctx, deps); err != nil {
+			return err
 		}
 	}
 
@@ -87,8 +225,28 @@ func (a *Application) Generate(ctx context.Context, repoNames []string) (err err
 		return fmt.Errorf("failed to generate 404.html: %w", err)
 	}
 
-	// Atomically swap staging to public via symlink
-	if err = a.swapPublicSymlink(stagingPath); err != nil {
+	// Let uptime monitors check the freshness of the build
+	health := compose.NewHealth(timestamp, repositories, newest, a.Config.Generate.HealthMaxAgeHours)
+	health.Config = a.ConfigHash
+	if err = compose.GenerateHealth(stagingPath, health); err != nil {
+		return fmt.Errorf("failed to generate %s: %w", compose.HealthFile, err)
+	}
+
+	// Precompress web files last so every generated file has its siblings
+	if err = compose.Precompress(stagingPath, a.Config.Generate.Precompress); err != nil {
+		return err
+	}
+
+	// Compare with the build being replaced for the summary
+	countPackageChanges(previousBuild, stagingPath)
+
+	if opts.Dir != "" {
+		slog.Info("Generate complete, one-off build is not linked or published", "dir", stagingPath, log.Success())
+		return nil
+	}
+
+	// Atomically swap staging to public (or public staging) via symlink
+	if err = common.SwapSymlink(a.currentPublicPath(), stagingPath); err != nil {
 		return err
 	}
 
@@ -97,174 +255,96 @@ func (a *Application) Generate(ctx context.Context, repoNames []string) (err err
 		return err
 	}
 
-	slog.Info("Generate complete", log.Success())
+	slog.Info("Generate complete", "staging", timestamp, log.Success())
 
 	return nil
 }
 
-// generateRepository generates a single repository (APT + web)
-func (a *Application) generateRepository(ctx context.Context, repo *config.RepositoryConfig, stagingPath string) error {
-	slog.Info("Generating repository", "repository", repo.Name)
+// generateRepository generates a single repository by running the composers in dependency order
+// Returns the outputs of the composers
+func (a *Application) generateRepository(ctx context.Context, repo *config.RepositoryConfig, composers []compose.Registration, stagingPath string, opts GenerateOptions) (results *compose.Results, err error) {
+	ctx, span := telemetry.Start(ctx, "generate.repository", telemetry.RepositoryKey.String(repo.Name))
+	defer func() { telemetry.End(span, err) }()
 
-	// Expand OBS feeds into APT feeds for APT composition
-	// Web composition will use the original feed list (repo.Feeds)
-	var expandedFeeds []*feed.FeedOptions
-	for _, feedOpts := range repo.Feeds {
-		switch feed.FeedType(feedOpts.Type) {
-		case feed.FeedTypeOBS:
-			aptFeeds := feed.ExpandOBSFeedOptions(feedOpts)
-			expandedFeeds = append(expandedFeeds, aptFeeds...)
-		case feed.FeedTypeAPT:
-			aptFeeds := feed.ExpandAptFeedOptions(feedOpts)
-			expandedFeeds = append(expandedFeeds, aptFeeds...)
-		default:
-			expandedFeeds = append(expandedFeeds, feedOpts)
+	start := time.Now()
+	defer func() {
+		log.RecordTiming("generate", time.Since(start), repo.Name)
+		if err != nil {
+			log.RecordError(repo.Name, err)
 		}
-	}
-
-	// Build APT compose options
-	aptOptions := &compose.AptComposeOptions{
-		ComposeOptions: compose.ComposeOptions{
-			Target: filepath.Join(stagingPath, repo.Name),
-			Name:   repo.Name,
-			Feeds:  expandedFeeds,
-		},
-		Repository: &repo.RepositoryOptions,
-		Trusted:    a.Config.Directories.GetTrustedPath(),
-		PoolMode:   a.Config.Generate.PoolMode,
-	}
+	}()
 
-	// Create verifier for compose phase - trust files in trusted storage
-	// Files were already verified during fetch, so we accept unsigned and ignore signatures
-	verifier := &debext.Verifier{
-		Verifier:         &pgp.GoVerifier{}, // Empty verifier, won't be used
-		AcceptUnsigned:   true,
-		IgnoreSignatures: true,
-	}
+	slog.Info("Generating repository", "repository", repo.Name)
 
-	// Create APT composer
-	aptComposer := compose.NewApt(aptOptions, verifier, a.Signer, a.DeCompressor, a.MainPool)
+	deps := a.composeDependencies(stagingPath)
+	deps.Repository = repo
+	deps.Incremental = opts.Incremental
+	deps.VerifyPool = opts.VerifyPool
+	deps.Distributions = opts.Distributions
 
-	// Compose APT repository
-	repository, err := aptComposer.Compose(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to compose APT repository for %s: %w", repo.Name, err)
-	}
-
-	// Collect statistics for logging
-	dists := repository.GetDistributions()
-	var totalArchs, totalComps, totalPkgs int
-	archSet := make(map[string]struct{})
-	compSet := make(map[string]struct{})
-	for _, dist := range dists {
-		for _, comp := range repository.GetComponents(dist) {
-			compSet[comp] = struct{}{}
-			for _, arch := range repository.GetArchitectures(dist, comp, false) {
-				archSet[arch] = struct{}{}
-			}
-		}
-		totalPkgs += len(repository.GetPackageNames(common.MainComponent))
-	}
-	totalArchs = len(archSet)
-	totalComps = len(compSet)
-
-	slog.Info("APT repository generated",
-		"repository", repo.Name,
-		"distributions", len(dists),
-		"components", totalComps,
-		"architectures", totalArchs,
-		"packages", totalPkgs)
-
-	// Run composers in configured order
-	for _, composerName := range a.Config.Generate.Compose {
-		switch composerName {
-		case "apt":
-			// Already done above - APT must always be first
+	// Composers pass their outputs to the ones depending on them
+	results = &compose.Results{}
+	for _, composer := range composers {
+		if composer.Compose == nil {
 			continue
-		case "web":
-			if err := a.generateWeb(ctx, repo, repository, stagingPath); err != nil {
-				return err
-			}
-		default:
-			slog.Warn("Unknown composer", "name", composerName, "repository", repo.Name)
+		}
+		if err := composer.Compose(ctx, deps, results); err != nil {
+			return nil, err
 		}
 	}
 
-	return nil
-}
-
-// generateWeb generates web page for a repository
-func (a *Application) generateWeb(ctx context.Context, repo *config.RepositoryConfig, repository *debext.Repository, stagingPath string) error {
-	webOptions := &compose.WebComposeOptions{
-		ComposeOptions: compose.ComposeOptions{
-			Target: stagingPath,
-			Name:   repo.Name,
-			Feeds:  repo.Feeds,
-		},
-		Description:      repo.Description,
-		Repository:       &repo.RepositoryOptions,
-		BaseURL:          a.Config.URL,
-		Downloads:        a.Config.Directories.GetDownloadsPath(),
-		PrimaryPackage:   repo.Packages.Primary,
-		IconURLs:         a.Config.Web.GetIconURLs(),
-		GitHubClient:     a.GitHubClient,
-		TailwindRelease:  a.Config.Web.Tailwind.Release,
-		RepositoryConfig: repo,
-	}
-
-	webComposer, err := compose.NewWeb(webOptions, a.Downloader)
-	if err != nil {
-		return fmt.Errorf("failed to initialize web composer for %s: %w", repo.Name, err)
-	}
-
-	if err := webComposer.Compose(ctx, repository); err != nil {
-		return fmt.Errorf("failed to compose web page for %s: %w", repo.Name, err)
+	if results.Apt != nil {
+		log.RecordRetention(repo.Name, results.Apt.Dropped(), results.Apt.Versions())
 	}
 
-	slog.Info("Web page generated", "repository", repo.Name)
-	return nil
+	return results, nil
 }
 
-// webIndex generates the index.html overview page
-func (a *Application) webIndex(ctx context.Context, stagingPath string) error {
-	webOptions := &compose.WebComposeOptions{
-		ComposeOptions: compose.ComposeOptions{
-			Target: stagingPath,
-		},
-		BaseURL:         a.Config.URL,
-		Downloads:       a.Config.Directories.GetDownloadsPath(),
-		IconURLs:        a.Config.Web.GetIconURLs(),
-		GitHubClient:    a.GitHubClient,
-		TailwindRelease: a.Config.Web.Tailwind.Release,
-	}
-
-	webComposer, err := compose.NewWeb(webOptions, a.Downloader)
+// composeDependencies returns the runtime components available to composers
+// The build the public symlink points to is the previous build
+func (a *Application) composeDependencies(stagingPath string) compose.Dependencies {
+	previousPath, err := filepath.EvalSymlinks(a.currentPublicPath())
 	if err != nil {
-		return err
+		previousPath = ""
 	}
 
-	return webComposer.Index(ctx)
+	return compose.Dependencies{
+		Config:          a.Config,
+		StagingPath:     stagingPath,
+		PreviousPath:    previousPath,
+		ConfigHash:      a.ConfigHash,
+		Signer:          a.Signer,
+		DeCompressor:    a.DeCompressor,
+		Downloader:      a.Downloader,
+		GitHubClient:    a.GitHubClient,
+		Pool:            a.MainPool,
+		PublicKeyASCII:  a.PublicKeyASCII,
+		PublicKeyBinary: a.PublicKeyBinary,
+		Sigstore:        a.Sigstore,
+		Metadata:        a.MetadataStore,
+		ControlStore:    a.ControlStore,
+		ContentsStore:   a.ContentsStore,
+	}
 }
 
-// swapPublicSymlink atomically updates the public symlink to point to the new staging directory
-func (a *Application) swapPublicSymlink(newStagingPath string) error {
-	publicPath := a.Config.Directories.GetPublicPath()
-
-	// Create temporary symlink in staging directory
-	tmpSymlink := newStagingPath + ".symlink"
-
-	// Create symlink to new staging directory
-	if err := os.Symlink(newStagingPath, tmpSymlink); err != nil {
-		return fmt.Errorf("failed to create temporary symlink: %w", err)
+// linkedStagingBuilds returns the names of staging builds still in use by public symlinks or the last publish
+func (a *Application) linkedStagingBuilds() map[string]bool {
+	linked := make(map[string]bool)
+	for _, linkPath := range []string{a.Config.Directories.GetPublicPath(), a.Config.Directories.GetPublicStagingPath()} {
+		if linkPath == "" {
+			continue
+		}
+		if target, err := os.Readlink(linkPath); err == nil {
+			linked[filepath.Base(target)] = true
+		}
 	}
 
-	// Atomically rename temporary symlink to final public path
-	if err := os.Rename(tmpSymlink, publicPath); err != nil {
-		_ = os.Remove(tmpSymlink) // Clean up temp symlink on error
-		return fmt.Errorf("failed to swap symlink: %w", err)
+	// Keep the baseline of the removal guard
+	if previous := a.lastPublishedBuild(); previous != "" {
+		linked[previous] = true
 	}
 
-	return nil
+	return linked
 }
 
 // cleanupOldStaging removes old staging directories beyond keep_last limit
@@ -284,12 +364,8 @@ func (a *Application) cleanupOldStaging() error {
 	// Filter to only directories with timestamp format
 	var stagingDirs []os.DirEntry
 	for _, entry := range entries {
-		if entry.IsDir() {
-			// Basic check for timestamp format (YYYYMMDD-HHMMSS)
-			name := entry.Name()
-			if len(name) == 15 && name[8] == '-' {
-				stagingDirs = append(stagingDirs, entry)
-			}
+		if entry.IsDir() && isStagingBuildName(entry.Name()) {
+			stagingDirs = append(stagingDirs, entry)
 		}
 	}
 
@@ -298,9 +374,17 @@ func (a *Application) cleanupOldStaging() error {
 		return stagingDirs[i].Name() > stagingDirs[j].Name()
 	})
 
-	// Delete directories beyond keep_last
+	// Delete directories beyond keep_last, but never a build that is still linked
 	if len(stagingDirs) > a.Config.Generate.KeepLast {
-		toDelete := stagingDirs[a.Config.Generate.KeepLast:]
+		linked := a.linkedStagingBuilds()
+
+		var toDelete []os.DirEntry
+		for _, dir := range stagingDirs[a.Config.Generate.KeepLast:] {
+			if !linked[dir.Name()] {
+				toDelete = append(toDelete, dir)
+			}
+		}
+
 		for _, dir := range toDelete {
 			dirPath := filepath.Join(stagingBase, dir.Name())
 			if err := os.RemoveAll(dirPath); err != nil {
@@ -315,24 +399,37 @@ func (a *Application) cleanupOldStaging() error {
 	return nil
 }
 
-// copySigningKeys copies both ASCII and binary GPG signing keys to the staging directory
-func (a *Application) copySigningKeys(stagingPath string) error {
-	if len(a.PublicKeyASCII) == 0 {
-		return nil
+// carryOverRepositories hardlinks the configured repositories not in repoNames from the previous build
+// Returns the number of carried over repositories and the newest package of the previous build if any was carried over
+func (a *Application) carryOverRepositories(previousBuild, stagingPath string, repoNames []string) (int, time.Time, error) {
+	var carried []string
+	for _, repo := range a.Config.Repositories {
+		if slices.Contains(repoNames, repo.Name) {
+			continue
+		}
+		ok, err := compose.CarryOverRepository(previousBuild, stagingPath, repo.Name)
+		if err != nil {
+			return 0, time.Time{}, fmt.Errorf("failed to carry over repository %s: %w", repo.Name, err)
+		}
+		if !ok {
+			slog.Warn("Repository not in previous build, generate it to publish it", "repository", repo.Name)
+			continue
+		}
+		carried = append(carried, repo.Name)
 	}
-
-	keysDir := filepath.Join(stagingPath, "keys")
-	if err := os.MkdirAll(keysDir, 0755); err != nil {
-		return err
+	if len(carried) == 0 {
+		return 0, time.Time{}, nil
 	}
 
-	if err := os.WriteFile(filepath.Join(keysDir, "signing-key.asc"), a.PublicKeyASCII, 0644); err != nil {
-		return err
+	if err := compose.CarryOverAssets(previousBuild, stagingPath); err != nil {
+		return 0, time.Time{}, fmt.Errorf("failed to carry over assets: %w", err)
 	}
+	slog.Info("Carried over repositories from previous build", "repositories", carried, "build", filepath.Base(previousBuild))
 
-	if err := os.WriteFile(filepath.Join(keysDir, "signing-key.gpg"), a.PublicKeyBinary, 0644); err != nil {
-		return err
+	// The newest package of the carried over repositories is at most the newest of the previous build
+	var newest time.Time
+	if health, err := compose.LoadHealth(previousBuild); err == nil && health.NewestPackage != nil {
+		newest = *health.NewestPackage
 	}
-
-	return nil
+	return len(carried), newest, nil
 }
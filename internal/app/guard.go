@@ -0,0 +1,204 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/dionysius/aarg/debext"
+	"github.com/dionysius/aarg/internal/log"
+)
+
+// ErrMassRemoval is returned when a build removes more packages than allowed
+var ErrMassRemoval = errors.New("too many packages removed compared to the last published build")
+
+// lastPublishedFile records the staging build last published to production, relative to the staging directory
+const lastPublishedFile = ".last-published"
+
+// checkRemovals compares the package names of a build with the last published build per repository
+// and fails if the configured removal thresholds are exceeded
+func (a *Application) checkRemovals(buildDir string) error {
+	maxCount := a.Config.Publish.MaxRemoved
+	maxPercent := a.Config.Publish.MaxRemovedPercent
+	if maxCount <= 0 && maxPercent <= 0 {
+		return nil // Guard disabled
+	}
+
+	previous := a.lastPublishedBuild()
+	if previous == "" {
+		slog.Debug("No previously published build, skipping removal check")
+		return nil
+	}
+	previousDir := filepath.Join(a.Config.Directories.GetStagingPath(), previous)
+	if _, err := os.Stat(previousDir); err != nil {
+		slog.Warn("Previously published build not found, skipping removal check", "staging", previous)
+		return nil
+	}
+
+	previousPkgs, err := collectPublishedPackages(previousDir)
+	if err != nil {
+		return err
+	}
+	currentPkgs, err := collectPublishedPackages(buildDir)
+	if err != nil {
+		return err
+	}
+
+	var exceeded []string
+	for _, repo := range slices.Sorted(maps.Keys(previousPkgs)) {
+		before := previousPkgs[repo]
+
+		var removed []string
+		for key := range before {
+			if _, ok := currentPkgs[repo][key]; !ok {
+				removed = append(removed, key)
+			}
+		}
+		if len(removed) == 0 {
+			continue
+		}
+
+		percent := float64(len(removed)) * 100 / float64(len(before))
+		if (maxCount > 0 && len(removed) > maxCount) || (maxPercent > 0 && percent > maxPercent) {
+			slices.Sort(removed)
+			for _, key := range removed {
+				slog.Warn("Package would be removed", "repository", repo, "package", key)
+			}
+			exceeded = append(exceeded, fmt.Sprintf("%s: %d of %d (%.1f%%)", repo, len(removed), len(before), percent))
+		}
+	}
+
+	if len(exceeded) > 0 {
+		return fmt.Errorf("%w (use --force to publish anyway): %s", ErrMassRemoval, strings.Join(exceeded, ", "))
+	}
+
+	return nil
+}
+
+// collectPublishedPackages returns per repository the packages in a build with their versions
+// Packages are keyed as dist/component/arch/name, versions are ignored since retention replaces them regularly
+func collectPublishedPackages(buildDir string) (map[string]map[string][]string, error) {
+	resolvedDir, err := filepath.EvalSymlinks(buildDir)
+	if err != nil {
+		return nil, err
+	}
+
+	// Layout is <repo>/dists/<dist>/<component>/binary-<arch>/Packages or .../source/Sources
+	binaryIndices, err := filepath.Glob(filepath.Join(resolvedDir, "*", "dists", "*", "*", "binary-*", "Packages"))
+	if err != nil {
+		return nil, err
+	}
+	sourceIndices, err := filepath.Glob(filepath.Join(resolvedDir, "*", "dists", "*", "*", debext.SourceArchitecture, "Sources"))
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]map[string][]string)
+	for _, indexPath := range append(binaryIndices, sourceIndices...) {
+		relPath, err := filepath.Rel(resolvedDir, indexPath)
+		if err != nil {
+			return nil, err
+		}
+		parts := strings.Split(filepath.ToSlash(relPath), "/")
+		repo, dist, comp := parts[0], parts[2], parts[3]
+
+		isSource := filepath.Base(indexPath) == "Sources"
+		pkgs, err := debext.ParsePackageIndex(indexPath, isSource)
+		if err != nil {
+			return nil, err
+		}
+
+		if result[repo] == nil {
+			result[repo] = make(map[string][]string)
+		}
+		for _, pkg := range pkgs {
+			arch := pkg.Architecture
+			if isSource {
+				arch = debext.SourceArchitecture
+			}
+			key := dist + "/" + comp + "/" + arch + "/" + pkg.Name
+			result[repo][key] = append(result[repo][key], pkg.Version)
+		}
+	}
+
+	return result, nil
+}
+
+// lastPublishedBuild returns the name of the staging build last published to production, or empty if unknown
+func (a *Application) lastPublishedBuild() string {
+	data, err := os.ReadFile(filepath.Join(a.Config.Directories.GetStagingPath(), lastPublishedFile))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// recordPublishedBuild stores the staging build published to production
+func (a *Application) recordPublishedBuild(buildDir string) error {
+	resolvedDir, err := filepath.EvalSymlinks(buildDir)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(a.Config.Directories.GetStagingPath(), lastPublishedFile)
+	return os.WriteFile(path, []byte(filepath.Base(resolvedDir)+"\n"), 0644)
+}
+
+// countPackageChanges records the packages added, kept and removed by a build for the run summary
+// and the run report, which also lists new versions of kept packages as added
+// Without a previous build all packages count as added
+func countPackageChanges(previousDir, buildDir string) {
+	previous := make(map[string]map[string][]string)
+	if previousDir != "" {
+		var err error
+		if previous, err = collectPublishedPackages(previousDir); err != nil {
+			slog.Debug("Failed to read previous build for the summary", "error", err)
+			return
+		}
+	}
+	current, err := collectPublishedPackages(buildDir)
+	if err != nil {
+		slog.Debug("Failed to read build for the summary", "error", err)
+		return
+	}
+
+	var added, kept, removed int
+	for repo, pkgs := range current {
+		var addedVersions []string
+		for key, versions := range pkgs {
+			if _, ok := previous[repo][key]; ok {
+				kept++
+			} else {
+				added++
+			}
+			for _, version := range versions {
+				if !slices.Contains(previous[repo][key], version) {
+					addedVersions = append(addedVersions, key+" "+version)
+				}
+			}
+		}
+
+		var removedKeys []string
+		for key := range previous[repo] {
+			if _, ok := pkgs[key]; !ok {
+				removedKeys = append(removedKeys, key)
+			}
+		}
+		removed += len(removedKeys)
+
+		slices.Sort(addedVersions)
+		slices.Sort(removedKeys)
+		log.RecordChanges(repo, addedVersions, removedKeys)
+	}
+	for repo, pkgs := range previous {
+		if _, ok := current[repo]; !ok {
+			removed += len(pkgs)
+		}
+	}
+
+	log.CountPackages(added, kept, removed)
+}
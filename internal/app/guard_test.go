@@ -0,0 +1,179 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dionysius/aarg/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeGuardBuild creates a staging build with a binary package index listing the given packages of repository repo
+func writeGuardBuild(t *testing.T, stagingPath, name, repo string, packages ...string) string {
+	t.Helper()
+	buildDir := filepath.Join(stagingPath, name)
+	indexDir := filepath.Join(buildDir, repo, "dists", "stable", "main", "binary-amd64")
+	require.NoError(t, os.MkdirAll(indexDir, 0755))
+
+	var stanzas []string
+	for _, pkg := range packages {
+		stanzas = append(stanzas, fmt.Sprintf("Package: %s\nVersion: 1.0\nArchitecture: amd64\nFilename: pool/main/%s_1.0_amd64.deb\nSize: 1\n", pkg, pkg))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(indexDir, "Packages"), []byte(strings.Join(stanzas, "\n")), 0644))
+	return buildDir
+}
+
+// newGuardApplication returns an application with only the directories and removal thresholds configured
+func newGuardApplication(t *testing.T, maxRemoved int, maxRemovedPercent float64) *Application {
+	t.Helper()
+	root := t.TempDir()
+	cfg := &config.Config{
+		Directories: config.DirectoriesConfig{
+			Root:          root,
+			Staging:       "staging",
+			Public:        "public",
+			PublicStaging: "public-staging",
+		},
+		Publish: config.PublishConfig{MaxRemoved: maxRemoved, MaxRemovedPercent: maxRemovedPercent},
+	}
+	require.NoError(t, os.MkdirAll(cfg.Directories.GetStagingPath(), 0755))
+	return &Application{Config: cfg}
+}
+
+func TestApplication_checkRemovals(t *testing.T) {
+	previous := []string{"a", "b", "c", "d"}
+
+	tests := []struct {
+		name              string
+		maxRemoved        int
+		maxRemovedPercent float64
+		lastPublished     string
+		current           []string
+		wantErr           bool
+	}{
+		{
+			name:          "guard disabled",
+			lastPublished: "20250101-120000",
+			current:       nil,
+		},
+		{
+			name:          "nothing published yet",
+			maxRemoved:    1,
+			lastPublished: "",
+			current:       nil,
+		},
+		{
+			name:          "last published build gone",
+			maxRemoved:    1,
+			lastPublished: "20240101-120000",
+			current:       nil,
+		},
+		{
+			name:          "count within limit",
+			maxRemoved:    1,
+			lastPublished: "20250101-120000",
+			current:       []string{"a", "b", "c"},
+		},
+		{
+			name:          "count exceeded",
+			maxRemoved:    1,
+			lastPublished: "20250101-120000",
+			current:       []string{"a", "b"},
+			wantErr:       true,
+		},
+		{
+			name:              "percent within limit",
+			maxRemovedPercent: 50,
+			lastPublished:     "20250101-120000",
+			current:           []string{"a", "b"},
+		},
+		{
+			name:              "percent exceeded",
+			maxRemovedPercent: 50,
+			lastPublished:     "20250101-120000",
+			current:           []string{"a"},
+			wantErr:           true,
+		},
+		{
+			name:          "added packages do not count",
+			maxRemoved:    1,
+			lastPublished: "20250101-120000",
+			current:       []string{"a", "b", "c", "d", "e", "f"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newGuardApplication(t, tt.maxRemoved, tt.maxRemovedPercent)
+			stagingPath := a.Config.Directories.GetStagingPath()
+			writeGuardBuild(t, stagingPath, "20250101-120000", "repo", previous...)
+			buildDir := writeGuardBuild(t, stagingPath, "20250102-120000", "repo", tt.current...)
+			if tt.lastPublished != "" {
+				require.NoError(t, os.WriteFile(filepath.Join(stagingPath, lastPublishedFile), []byte(tt.lastPublished+"\n"), 0644))
+			}
+
+			err := a.checkRemovals(buildDir)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrMassRemoval)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestApplication_recordPublishedBuild(t *testing.T) {
+	a := newGuardApplication(t, 1, 0)
+	stagingPath := a.Config.Directories.GetStagingPath()
+	assert.Empty(t, a.lastPublishedBuild())
+
+	// A symlink to the build records the build it points at
+	buildDir := writeGuardBuild(t, stagingPath, "20250101-120000", "repo", "a")
+	link := filepath.Join(a.Config.Directories.Root, "public")
+	require.NoError(t, os.Symlink(buildDir, link))
+	require.NoError(t, a.recordPublishedBuild(link))
+	assert.Equal(t, "20250101-120000", a.lastPublishedBuild())
+
+	require.NoError(t, a.recordPublishedBuild(writeGuardBuild(t, stagingPath, "20250102-120000", "repo", "a")))
+	assert.Equal(t, "20250102-120000", a.lastPublishedBuild())
+}
+
+func TestApplication_Promote_Guard(t *testing.T) {
+	tests := []struct {
+		name       string
+		force      bool
+		wantErr    error
+		wantPublic string
+	}{
+		{name: "refused", wantErr: ErrMassRemoval, wantPublic: "20250101-120000"},
+		{name: "forced", force: true, wantPublic: "20250102-120000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newGuardApplication(t, 1, 0)
+			stagingPath := a.Config.Directories.GetStagingPath()
+			published := writeGuardBuild(t, stagingPath, "20250101-120000", "repo", "a", "b", "c")
+			writeGuardBuild(t, stagingPath, "20250102-120000", "repo", "a")
+			require.NoError(t, a.recordPublishedBuild(published))
+			require.NoError(t, os.Symlink(published, a.Config.Directories.GetPublicPath()))
+
+			// Without publishing the guard still protects the public symlink
+			err := a.Promote(context.Background(), "20250102-120000", false, tt.force)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+
+			target, err := os.Readlink(a.Config.Directories.GetPublicPath())
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantPublic, filepath.Base(target))
+		})
+	}
+}
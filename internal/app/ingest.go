@@ -0,0 +1,213 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dionysius/aarg/debext"
+	"github.com/dionysius/aarg/internal/common"
+	"github.com/dionysius/aarg/internal/feed"
+	"github.com/dionysius/aarg/internal/log"
+	"github.com/fsnotify/fsnotify"
+)
+
+// ingestDebounce is the quiet period after the last change in a directory before it is ingested
+var ingestDebounce = 2 * time.Second
+
+// ingestFeed is a feed whose download directory is watched for new .changes files
+type ingestFeed struct {
+	downloadDir string
+	repository  string
+	ingester    feed.ChangesIngester
+}
+
+// watchIngest watches the download directories of all GitHub feeds and incrementally
+// verifies and links new .changes files and their referenced files into trusted storage
+// Blocks until ctx is cancelled
+func (a *Application) watchIngest(ctx context.Context) error {
+	feeds, err := a.ingestFeeds(ctx)
+	if err != nil {
+		return err
+	}
+	if len(feeds) == 0 {
+		slog.Warn("Ingest enabled but no GitHub feeds with .changes files configured")
+		return nil
+	}
+
+	return a.watchIngestFeeds(ctx, feeds)
+}
+
+// watchIngestFeeds watches the download directories of feeds and ingests a directory once its changes settled
+// Blocks until ctx is cancelled
+func (a *Application) watchIngestFeeds(ctx context.Context, feeds []*ingestFeed) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create ingest watcher: %w", err)
+	}
+	defer watcher.Close()
+
+	// fsnotify is not recursive, watch every directory below the feed download directories
+	addTree := func(root string) {
+		_ = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || !d.IsDir() {
+				return nil
+			}
+			if err := watcher.Add(path); err != nil {
+				slog.Warn("Failed to watch directory", "dir", path, "error", err)
+			}
+			return nil
+		})
+	}
+
+	for _, f := range feeds {
+		if err := os.MkdirAll(f.downloadDir, 0755); err != nil {
+			return err
+		}
+		addTree(f.downloadDir)
+	}
+
+	slog.Info("Watching downloads for incremental ingestion", "feeds", len(feeds))
+
+	// Debounce per directory, external tools usually write several files at once
+	var mu sync.Mutex
+	timers := make(map[string]*time.Timer)
+	schedule := func(dir string) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if t, ok := timers[dir]; ok {
+			t.Reset(ingestDebounce)
+			return
+		}
+		timers[dir] = time.AfterFunc(ingestDebounce, func() {
+			mu.Lock()
+			delete(timers, dir)
+			mu.Unlock()
+
+			a.ingestDir(ctx, feeds, dir)
+		})
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			mu.Lock()
+			for _, t := range timers {
+				t.Stop()
+			}
+			mu.Unlock()
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if !event.Has(fsnotify.Create) && !event.Has(fsnotify.Write) {
+				continue
+			}
+
+			// New directories (e.g. a new release tag) need to be watched too
+			if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+				addTree(event.Name)
+				schedule(event.Name)
+				continue
+			}
+
+			schedule(filepath.Dir(event.Name))
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			slog.Warn("Ingest watcher error", "error", err)
+		}
+	}
+}
+
+// ingestFeeds creates the GitHub feeds eligible for ingestion
+func (a *Application) ingestFeeds(ctx context.Context) ([]*ingestFeed, error) {
+	var feeds []*ingestFeed
+
+	for _, repo := range a.Config.Repositories {
+		var verifier *debext.Verifier
+		for _, feedOpts := range repo.Feeds {
+			// Only feeds with signed .changes files can be verified without fetching
+			if feed.FeedType(feedOpts.Type) != feed.FeedTypeGitHub || feedOpts.NoChanges {
+				continue
+			}
+
+			if verifier == nil {
+				var err error
+				verifier, err = a.initializeVerifier(ctx, repo)
+				if err != nil {
+					return nil, fmt.Errorf("failed to initialize verifier for %s: %w", repo.Name, err)
+				}
+			}
+
+			storage := common.NewStorage(
+				a.Downloader,
+				a.Config.Directories.GetDownloadsPath(),
+				a.Config.Directories.GetTrustedPath(),
+				feedOpts.RelativePath,
+			)
+			storage.SetMetadataStore(a.MetadataStore)
+
+			feedInst, err := feed.New(feedOpts, feed.Dependencies{
+				Storage:      storage,
+				GitHubClient: a.GitHubClient,
+				Verifier:     verifier,
+				Repository:   &repo.RepositoryOptions,
+				Pool:         a.MainPool,
+				Plugins:      a.Config.Plugins,
+				ControlStore: a.ControlStore,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to create feed %s: %w", feedOpts.Name, err)
+			}
+			ingester, ok := feedInst.(feed.ChangesIngester)
+			if !ok {
+				continue
+			}
+
+			feeds = append(feeds, &ingestFeed{
+				downloadDir: storage.GetDownloadPath(),
+				repository:  repo.Name,
+				ingester:    ingester,
+			})
+		}
+	}
+
+	return feeds, nil
+}
+
+// ingestDir ingests all .changes files in dir using the feed owning the directory
+func (a *Application) ingestDir(ctx context.Context, feeds []*ingestFeed, dir string) {
+	var owner *ingestFeed
+	for _, f := range feeds {
+		if dir == f.downloadDir || strings.HasPrefix(dir, f.downloadDir+string(filepath.Separator)) {
+			owner = f
+			break
+		}
+	}
+	if owner == nil || dir == owner.downloadDir {
+		return // Files directly in the feed directory have no tag
+	}
+
+	changesFiles, err := filepath.Glob(filepath.Join(dir, "*.changes"))
+	if err != nil || len(changesFiles) == 0 {
+		return
+	}
+
+	for _, changesPath := range changesFiles {
+		if err := owner.ingester.IngestChanges(ctx, changesPath); err != nil {
+			slog.Warn("Failed to ingest .changes file", "repository", owner.repository, "file", changesPath, "error", err)
+			continue
+		}
+		slog.Info("Ingested .changes file", "repository", owner.repository, "file", filepath.Base(changesPath), log.Success())
+	}
+}
@@ -0,0 +1,103 @@
+package app
+
+import (
+	"context"
+	"maps"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingIngester records how often every .changes file was ingested
+type countingIngester struct {
+	mu       sync.Mutex
+	ingested map[string]int
+}
+
+func (c *countingIngester) IngestChanges(_ context.Context, changesPath string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ingested[changesPath]++
+	return nil
+}
+
+// counts returns a copy of the ingest counts
+func (c *countingIngester) counts() map[string]int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return maps.Clone(c.ingested)
+}
+
+func TestWatchIngestFeeds(t *testing.T) {
+	debounce := ingestDebounce
+	ingestDebounce = 100 * time.Millisecond
+	t.Cleanup(func() { ingestDebounce = debounce })
+
+	downloadDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(downloadDir, "v0.9.0"), 0755))
+	ingester := &countingIngester{ingested: make(map[string]int)}
+	feeds := []*ingestFeed{{downloadDir: downloadDir, repository: "hello", ingester: ingester}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- (&Application{}).watchIngestFeeds(ctx, feeds) }()
+	t.Cleanup(func() {
+		cancel()
+		require.NoError(t, <-done)
+	})
+
+	write := func(rel string) string {
+		path := filepath.Join(downloadDir, rel)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte("Format: 1.8\n"), 0644))
+		return path
+	}
+
+	// A first upload shows the watcher is running, it is written again until noticed
+	probe := filepath.Join(downloadDir, "v0.9.0", "hello_0.9.0_amd64.changes")
+	require.Eventually(t, func() bool {
+		if ingester.counts()[probe] > 0 {
+			return true
+		}
+		_ = os.WriteFile(probe, []byte("Format: 1.8\n"), 0644)
+		return false
+	}, 5*time.Second, 3*ingestDebounce)
+
+	// Several uploads dropped together, into a watched and a new tag directory
+	var want []string
+	for _, rel := range []string{
+		"v0.9.0/hello_0.9.0_arm64.changes",
+		"v0.9.0/hello_0.9.0_source.changes",
+		"v1.0.0/hello_1.0.0_amd64.changes",
+		"v1.0.0/hello_1.0.0_arm64.changes",
+		"v1.0.0/hello_1.0.0_source.changes",
+	} {
+		want = append(want, write(rel))
+	}
+	write("v1.0.0/hello_1.0.0_amd64.deb")
+	write("hello.changes") // Files without tag directory are not ingested
+
+	require.Eventually(t, func() bool {
+		counts := ingester.counts()
+		for _, path := range want {
+			if counts[path] == 0 {
+				return false
+			}
+		}
+		return true
+	}, 5*time.Second, 10*time.Millisecond)
+	time.Sleep(3 * ingestDebounce)
+
+	// Each directory was ingested once after its files settled
+	counts := ingester.counts()
+	for _, path := range want {
+		assert.Equal(t, 1, counts[path], path)
+	}
+	assert.Equal(t, 2, counts[probe], "the probe directory is ingested again with its new files")
+	assert.NotContains(t, counts, filepath.Join(downloadDir, "hello.changes"))
+}
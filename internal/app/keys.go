@@ -0,0 +1,192 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/dionysius/aarg/debext"
+	"github.com/dionysius/aarg/internal/common"
+	"github.com/dionysius/aarg/internal/config"
+	"github.com/dionysius/aarg/internal/log"
+)
+
+// ErrSampleVerification is returned if the sample file fails verification for a repository
+var ErrSampleVerification = errors.New("sample verification failed")
+
+// CheckKeys lists the verification keys of the repositories and verifies a clearsigned
+// sample file (e.g., InRelease or .changes) against them if samplePath is set
+func (a *Application) CheckKeys(ctx context.Context, repoNames []string, samplePath string) error {
+	var sample []byte
+	if samplePath != "" {
+		var err error
+		if sample, err = os.ReadFile(samplePath); err != nil {
+			return err
+		}
+	}
+
+	var failed []string
+	for _, name := range repoNames {
+		repo := a.findRepository(name)
+		if repo == nil {
+			return fmt.Errorf("repository not found: %s", name)
+		}
+
+		infos, err := a.listKeys(repo)
+		if err != nil {
+			return err
+		}
+
+		if sample == nil {
+			continue
+		}
+		if !a.checkSample(ctx, repo, infos, samplePath, sample) {
+			failed = append(failed, name)
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("%w for %s", ErrSampleVerification, strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// findRepository returns the configured repository by name, nil if not found
+func (a *Application) findRepository(name string) *config.RepositoryConfig {
+	for _, repo := range a.Config.Repositories {
+		if repo.Name == name {
+			return repo
+		}
+	}
+	return nil
+}
+
+// verificationKeyPaths returns the keyring and key files of a repository
+// Without configured keys the verifier falls back to ~/.gnupg/trustedkeys.gpg
+func (a *Application) verificationKeyPaths(repo *config.RepositoryConfig) []string {
+	keyPaths := repo.Verification.GetKeyPaths(a.Config.ConfigDir)
+	if keyringPath := repo.Verification.GetKeyringPath(a.Config.ConfigDir); keyringPath != "" {
+		keyPaths = append([]string{keyringPath}, keyPaths...)
+	}
+	if len(keyPaths) == 0 {
+		if home, err := os.UserHomeDir(); err == nil {
+			keyPaths = []string{filepath.Join(home, ".gnupg", "trustedkeys.gpg")}
+		}
+	}
+	return keyPaths
+}
+
+// listKeys logs the verification keys of a repository and returns their details
+func (a *Application) listKeys(repo *config.RepositoryConfig) ([]debext.KeyInfo, error) {
+	now := time.Now()
+	var infos []debext.KeyInfo
+
+	for _, keyPath := range a.verificationKeyPaths(repo) {
+		keys, err := debext.ReadKeys(keyPath)
+		if err != nil {
+			return nil, fmt.Errorf("repository %s: %w", repo.Name, err)
+		}
+
+		for _, info := range debext.DescribeKeys(keys) {
+			infos = append(infos, info)
+			logKey(repo.Name, keyPath, info, now)
+		}
+	}
+
+	return infos, nil
+}
+
+// logKey logs the details of a key and its subkeys, warning about unusable keys
+func logKey(repository, keyPath string, info debext.KeyInfo, now time.Time) {
+	attrs := []any{
+		"repository", repository,
+		"file", keyPath,
+		"fingerprint", info.Fingerprint,
+		"uids", strings.Join(info.UIDs, "; "),
+		"algorithm", keyAlgorithm(info),
+		"created", common.InDisplayLocation(info.Created).Format(time.DateOnly),
+		"expires", keyExpiry(info),
+	}
+
+	switch {
+	case info.Revoked:
+		slog.Warn("Key is revoked", attrs...)
+	case info.Expired(now):
+		slog.Warn("Key is expired", attrs...)
+	default:
+		slog.Info("Key", attrs...)
+	}
+
+	for _, sub := range info.Subkeys {
+		subAttrs := []any{
+			"fingerprint", sub.Fingerprint,
+			"algorithm", keyAlgorithm(sub),
+			"expires", keyExpiry(sub),
+		}
+		switch {
+		case sub.Revoked:
+			subAttrs = append(subAttrs, "status", "revoked")
+		case sub.Expired(now):
+			subAttrs = append(subAttrs, "status", "expired")
+		}
+		slog.Info("  Subkey", subAttrs...)
+	}
+}
+
+// keyAlgorithm formats the algorithm and size of a key
+func keyAlgorithm(info debext.KeyInfo) string {
+	if info.Bits > 0 {
+		return fmt.Sprintf("%s %d", info.Algorithm, info.Bits)
+	}
+	return info.Algorithm
+}
+
+// keyExpiry formats the expiry date of a key
+func keyExpiry(info debext.KeyInfo) string {
+	if info.Expires == nil {
+		return "never"
+	}
+	return common.InDisplayLocation(*info.Expires).Format(time.DateOnly)
+}
+
+// checkSample verifies the sample with the verifier used by fetch and explains failures
+func (a *Application) checkSample(ctx context.Context, repo *config.RepositoryConfig, infos []debext.KeyInfo, samplePath string, sample []byte) bool {
+	issuers, err := debext.SignatureIssuers(sample)
+	if err != nil {
+		slog.Warn("Sample is not clearsigned", "repository", repo.Name, "file", samplePath, "error", err)
+		return false
+	}
+
+	// Point at the signing keys, known or not
+	for _, issuer := range issuers {
+		if info, ok := debext.FindKey(infos, issuer); ok {
+			slog.Info("Sample signed by loaded key", "repository", repo.Name, "issuer", issuer, "key", info.Fingerprint, "uids", strings.Join(info.UIDs, "; "))
+		} else {
+			slog.Warn("Sample signed by key that is not loaded", "repository", repo.Name, "issuer", issuer)
+		}
+	}
+
+	verifier, err := a.initializeVerifier(ctx, repo)
+	if err != nil {
+		slog.Warn("Failed to initialize verifier", "repository", repo.Name, "error", err)
+		return false
+	}
+
+	rc, _, err := verifier.VerifyAndClear(bytes.NewReader(sample))
+	if err != nil {
+		slog.Warn("Sample verification failed", "repository", repo.Name, "file", samplePath, "error", err)
+		return false
+	}
+	_, _ = io.Copy(io.Discard, rc)
+	_ = rc.Close()
+
+	slog.Info("Sample verified", "repository", repo.Name, "file", samplePath, log.Success())
+	return true
+}
@@ -0,0 +1,121 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/dionysius/aarg/internal/config"
+	"github.com/dionysius/aarg/internal/log"
+)
+
+// notifyTimeout is how long a webhook may take to accept a notification
+const notifyTimeout = 15 * time.Second
+
+// Notification is the JSON body posted to notification webhooks at the end of a run
+type Notification struct {
+	Summary      log.Summary              `json:"summary"`
+	Repositories []RepositoryNotification `json:"repositories"`       // Repositories of the run routed to the webhook
+	Warnings     []log.Warning            `json:"warnings,omitempty"` // Warnings not assigned to a repository, default webhook only
+}
+
+// RepositoryNotification is a repository of the run routed to a webhook by its owners
+type RepositoryNotification struct {
+	Name     string        `json:"name"`
+	Owners   []string      `json:"owners,omitempty"`
+	Warnings []log.Warning `json:"warnings,omitempty"`
+}
+
+// Notify posts the outcome of a run to the webhooks of the owners of the repositories it processed
+// Repositories without an owner webhook, warnings of no repository and failures not tied to a
+// repository go to the default webhook. Runs without warnings are only notified if configured.
+func Notify(ctx context.Context, cfg *config.Config, summary log.Summary) error {
+	notifications := cfg.Notifications
+	if !notifications.IsEnabled() {
+		return nil
+	}
+	if summary.Status == "ok" && summary.Warnings == 0 && !notifications.Always {
+		return nil
+	}
+
+	bodies := make(map[string]*Notification)
+	var order []string
+	body := func(webhook string) *Notification {
+		if bodies[webhook] == nil {
+			bodies[webhook] = &Notification{Summary: summary, Repositories: []RepositoryNotification{}}
+			order = append(order, webhook)
+		}
+		return bodies[webhook]
+	}
+
+	for _, name := range log.Repositories() {
+		var owners []string
+		if i := slices.IndexFunc(cfg.Repositories, func(repo *config.RepositoryConfig) bool { return repo.Name == name }); i >= 0 {
+			owners = cfg.Repositories[i].Owners
+		}
+		warnings := log.RepositoryWarnings(name)
+
+		// Quiet repositories of a failed run are still routed, the failure may be theirs
+		if len(warnings) == 0 && summary.Status == "ok" && !notifications.Always {
+			continue
+		}
+
+		for _, webhook := range notifications.Route(owners) {
+			n := body(webhook)
+			n.Repositories = append(n.Repositories, RepositoryNotification{Name: name, Owners: owners, Warnings: warnings})
+		}
+	}
+
+	// Warnings of the run not assigned to a repository and failures before any repository was processed
+	var unassigned []log.Warning
+	for _, warning := range log.Warnings() {
+		if warning.Attrs[log.RepositoryKey] == "" {
+			unassigned = append(unassigned, warning)
+		}
+	}
+	if notifications.Webhook != "" && (len(unassigned) > 0 || len(order) == 0) {
+		body(notifications.Webhook).Warnings = unassigned
+	}
+
+	var errs []error
+	for _, webhook := range order {
+		if err := postNotification(ctx, webhook, bodies[webhook]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// postNotification posts a notification as JSON to a webhook
+func postNotification(ctx context.Context, webhook string, notification *Notification) error {
+	data, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, notifyTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "aarg/"+CurrentVersion())
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to notify %s: %w", req.URL.Host, err)
+	}
+	_ = resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("failed to notify %s: %s", req.URL.Host, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package app
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/dionysius/aarg/internal/common"
+)
+
+// preflightFetch verifies the downloads filesystem has the configured free space left
+// The sizes of the assets to download are only known once their download starts, the downloader
+// checks their sum against the free space then, see common.Downloader.SetMinFree
+func (a *Application) preflightFetch() error {
+	if a.Config.Preflight.Disabled {
+		return nil
+	}
+
+	slog.Info("Checking disk space for fetch", "reserve", common.FormatSize(a.Config.Preflight.MinFreeBytes()))
+	if err := common.CheckFreeSpace(a.Config.Directories.GetDownloadsPath(), 0, a.Config.Preflight.MinFreeBytes()); err != nil {
+		return fmt.Errorf("fetch preflight: %w", err)
+	}
+
+	return nil
+}
+
+// preflightGenerate verifies the staging filesystem can hold a build the size of the previous one plus margin
+// Files hardlinked from trusted storage are not counted since they need no extra space
+func (a *Application) preflightGenerate() error {
+	if a.Config.Preflight.Disabled {
+		return nil
+	}
+
+	stagingDir := a.Config.Directories.GetStagingPath()
+
+	var estimate uint64
+	if previous := a.latestStagingBuild(); previous != "" {
+		size, err := common.ExclusiveSize(filepath.Join(stagingDir, previous))
+		if err != nil {
+			return fmt.Errorf("generate preflight: failed to measure previous build %s: %w", previous, err)
+		}
+		estimate = a.Config.Preflight.WithMargin(size)
+	}
+
+	slog.Info("Checking disk space for generate", "estimate", common.FormatSize(estimate))
+	if err := common.CheckFreeSpace(stagingDir, estimate, a.Config.Preflight.MinFreeBytes()); err != nil {
+		return fmt.Errorf("generate preflight: %w", err)
+	}
+
+	return nil
+}
+
+// latestStagingBuild returns the name of the newest staging build, empty if there is none
+func (a *Application) latestStagingBuild() string {
+	entries, err := os.ReadDir(a.Config.Directories.GetStagingPath())
+	if err != nil {
+		return ""
+	}
+
+	var builds []string
+	for _, entry := range entries {
+		if entry.IsDir() && isStagingBuildName(entry.Name()) {
+			builds = append(builds, entry.Name())
+		}
+	}
+	if len(builds) == 0 {
+		return ""
+	}
+
+	// Timestamped names sort chronologically
+	return slices.Max(builds)
+}
@@ -0,0 +1,74 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/dionysius/aarg/internal/common"
+	"github.com/dionysius/aarg/internal/log"
+)
+
+// Promote moves a staging build to production by pointing the public symlink at it
+// If staging is empty the build currently linked as public staging is promoted
+// If publish is set the build is also deployed to the provider's production environment
+// force skips the removal guard, which otherwise runs before the public symlink is touched
+func (a *Application) Promote(ctx context.Context, staging string, publish, force bool) error {
+	publicStaging := a.Config.Directories.GetPublicStagingPath()
+	if publicStaging == "" {
+		return fmt.Errorf("promotion requires directories.public_staging to be configured")
+	}
+
+	// Default to the build currently under review
+	if staging == "" {
+		target, err := os.Readlink(publicStaging)
+		if err != nil {
+			return fmt.Errorf("no build to promote, run generate first: %w", err)
+		}
+		staging = filepath.Base(target)
+	}
+
+	stagingDir, err := a.resolvePublishDir(staging)
+	if err != nil {
+		return err
+	}
+
+	// Check removals before touching the public symlink, also when promoting without publishing
+	if !force {
+		if err := a.checkRemovals(stagingDir); err != nil {
+			return err
+		}
+	}
+
+	slog.Info("Promoting build to production", "staging", staging)
+
+	// Assets were already uploaded with the staging deployment, only the manifest is deployed again
+	// Deploy first so a failed canary leaves public at the build already in production
+	if publish {
+		if err := a.publishProduction(ctx, stagingDir, true); err != nil {
+			return err
+		}
+	}
+
+	// Atomically swap public to the promoted build
+	if err := common.SwapSymlink(a.Config.Directories.GetPublicPath(), stagingDir); err != nil {
+		return err
+	}
+
+	// Release assets reference production, upload them only now
+	if publish {
+		repoNames := make([]string, 0, len(a.Config.Repositories))
+		for _, repo := range a.Config.Repositories {
+			repoNames = append(repoNames, repo.Name)
+		}
+		if err := a.Upload(ctx, repoNames); err != nil {
+			return err
+		}
+	}
+
+	slog.Info("Promote complete", "staging", staging, log.Success())
+
+	return nil
+}
@@ -0,0 +1,179 @@
+package app
+
+import (
+	"context"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/dionysius/aarg/debext"
+	"github.com/dionysius/aarg/internal/common"
+	"github.com/dionysius/aarg/internal/compose"
+	"github.com/dionysius/aarg/internal/log"
+	"github.com/dionysius/aarg/internal/telemetry"
+)
+
+// fileID identifies a file independent of its hardlinks
+type fileID struct {
+	dev, ino uint64
+}
+
+// Prune removes files of trusted storage dropped by the retention of every repository
+// Their hardlinks in the downloads cache are removed along with them, dryRun only reports them
+func (a *Application) Prune(ctx context.Context, dryRun bool) (err error) {
+	ctx, span := telemetry.Start(ctx, "prune")
+	defer func() { telemetry.End(span, err) }()
+
+	// Trusted storage is shared, a file is only unused if no repository retains it
+	retained := make(map[string]bool)
+	dropped := make(map[string]bool)
+	retainedSources := make(map[string]bool)
+	droppedSources := make(map[string]bool)
+
+	deps := a.composeDependencies("")
+	for _, repo := range a.Config.Repositories {
+		deps.Repository = repo
+		files, err := compose.CollectTrustedFiles(ctx, deps)
+		if err != nil {
+			return err
+		}
+		for path := range files.Retained {
+			retained[path] = true
+		}
+		for path := range files.Dropped {
+			dropped[path] = true
+		}
+		for source := range files.RetainedSources {
+			retainedSources[source] = true
+		}
+		for source := range files.DroppedSources {
+			droppedSources[source] = true
+		}
+	}
+
+	trustedDir := a.Config.Directories.GetTrustedPath()
+	var prunable []string
+	for path := range dropped {
+		if !retained[path] {
+			prunable = append(prunable, path)
+		}
+	}
+
+	// Build information is kept as long as a package of its source version is retained
+	err = filepath.WalkDir(trustedDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(path, debext.BuildinfoExtension) {
+			return nil
+		}
+		source, version, ok := debext.ParseBuildinfoFilename(path)
+		if !ok || !droppedSources[source+"_"+version] || retainedSources[source+"_"+version] {
+			return nil
+		}
+		rel, err := filepath.Rel(trustedDir, path)
+		if err != nil {
+			return err
+		}
+		prunable = append(prunable, rel)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Remove trusted files and remember them to find their hardlinks in the downloads cache
+	pruned := make(map[fileID]bool)
+	var files int
+	var size uint64
+	for _, rel := range prunable {
+		path := filepath.Join(trustedDir, rel)
+		info, err := os.Stat(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return err
+		}
+		if id, ok := identify(info); ok {
+			pruned[id] = true
+		}
+
+		slog.Debug("Pruning trusted file", "file", rel, "size", common.FormatSize(uint64(info.Size())), "dry_run", dryRun)
+		files++
+		size += uint64(info.Size())
+		if dryRun {
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+	}
+
+	downloads, err := pruneDownloads(a.Config.Directories.GetDownloadsPath(), pruned, dryRun)
+	if err != nil {
+		return err
+	}
+
+	message := "Storage pruned"
+	if dryRun {
+		message = "Storage prune dry run, nothing removed"
+	}
+	slog.Info(message,
+		"trusted", files,
+		"downloads", downloads,
+		"size", common.FormatSize(size),
+		log.Success())
+	return nil
+}
+
+// pruneDownloads removes the hardlinks of pruned trusted files from the downloads cache
+// Returns the number of removed files, their space was already counted with the trusted files
+func pruneDownloads(downloadsDir string, pruned map[fileID]bool, dryRun bool) (int, error) {
+	if len(pruned) == 0 {
+		return 0, nil
+	}
+
+	var removed int
+	err := filepath.WalkDir(downloadsDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if id, ok := identify(info); !ok || !pruned[id] {
+			return nil
+		}
+
+		slog.Debug("Pruning downloaded file", "file", path, "dry_run", dryRun)
+		removed++
+		if dryRun {
+			return nil
+		}
+		return os.Remove(path)
+	})
+	return removed, err
+}
+
+// identify returns the device and inode of a file
+func identify(info fs.FileInfo) (fileID, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fileID{}, false
+	}
+	return fileID{dev: uint64(stat.Dev), ino: stat.Ino}, true
+}
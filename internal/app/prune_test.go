@@ -0,0 +1,110 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alitto/pond/v2"
+	"github.com/dionysius/aarg/debext/testutil"
+	"github.com/dionysius/aarg/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pruneRepository returns the configuration of a repository keeping the given amount of minor versions of the shared feed
+func pruneRepository(amount int) string {
+	return fmt.Sprintf(`retention:
+  - pattern: "*.#.*-*"
+    amount: [%d]
+packages:
+  buildinfo: true
+feeds:
+  - github: example/hello
+`, amount)
+}
+
+// newPruneApplication creates an application of two repositories sharing one feed in trusted storage
+// Repository latest keeps the newest minor version, repository previous the newest two
+func newPruneApplication(t *testing.T) *Application {
+	t.Helper()
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "repos.d"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "repos.d", "latest.yaml"), []byte(pruneRepository(1)), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "repos.d", "previous.yaml"), []byte(pruneRepository(2)), 0644))
+	configPath := filepath.Join(dir, "config.yaml")
+	require.NoError(t, os.WriteFile(configPath, []byte(fmt.Sprintf("directories:\n  root: %q\npreflight:\n  disabled: true\n", dir)), 0644))
+
+	cfg, err := config.Load(configPath)
+	require.NoError(t, err)
+
+	pool := pond.NewPool(10)
+	t.Cleanup(pool.StopAndWait)
+	return &Application{Config: cfg, MainPool: pool}
+}
+
+func TestPrune(t *testing.T) {
+	tests := []struct {
+		name   string
+		dryRun bool
+	}{
+		{name: "prune"},
+		{name: "dry run", dryRun: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			application := newPruneApplication(t)
+			trusted := filepath.Join(application.Config.Directories.GetTrustedPath(), "github.com", "example", "hello", "stable")
+			downloads := filepath.Join(application.Config.Directories.GetDownloadsPath(), "github.com", "example", "hello", "stable")
+			require.NoError(t, os.MkdirAll(downloads, 0755))
+
+			files := make(map[string]string)
+			for _, version := range []string{"1.0.0-1", "1.1.0-1", "1.2.0-1"} {
+				path, err := testutil.WriteDeb(trusted, testutil.Package{Name: "hello", Version: version, Architecture: "amd64"})
+				require.NoError(t, err)
+				files["deb "+version] = path
+
+				buildinfo := filepath.Join(trusted, "hello_"+version+"_amd64.buildinfo")
+				require.NoError(t, os.WriteFile(buildinfo, []byte("Source: hello\nVersion: "+version+"\n"), 0644))
+				files["buildinfo "+version] = buildinfo
+
+				// The downloads cache holds hardlinks of the trusted files
+				download := filepath.Join(downloads, filepath.Base(path))
+				require.NoError(t, os.Link(path, download))
+				files["download "+version] = download
+			}
+
+			// Build information without any package of its source is not decided by retention
+			orphan := filepath.Join(trusted, "other_1.0.0-1_amd64.buildinfo")
+			require.NoError(t, os.WriteFile(orphan, []byte("Source: other\n"), 0644))
+			files["orphan buildinfo"] = orphan
+
+			// A download with the same name but its own inode is not a copy of the trusted file
+			copied := filepath.Join(application.Config.Directories.GetDownloadsPath(), "other", filepath.Base(files["deb 1.0.0-1"]))
+			require.NoError(t, os.MkdirAll(filepath.Dir(copied), 0755))
+			data, err := os.ReadFile(files["deb 1.0.0-1"])
+			require.NoError(t, err)
+			require.NoError(t, os.WriteFile(copied, data, 0644))
+			files["unlinked download"] = copied
+
+			require.NoError(t, application.Prune(context.Background(), tt.dryRun))
+
+			// Only 1.0.0 is dropped by both repositories, 1.1.0 is still retained by previous
+			removed := map[string]bool{
+				"deb 1.0.0-1":       true,
+				"buildinfo 1.0.0-1": true,
+				"download 1.0.0-1":  true,
+			}
+			for name, path := range files {
+				if removed[name] && !tt.dryRun {
+					assert.NoFileExists(t, path, name)
+				} else {
+					assert.FileExists(t, path, name)
+				}
+			}
+		})
+	}
+}
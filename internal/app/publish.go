@@ -2,27 +2,70 @@ package app
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 
+	"github.com/dionysius/aarg/internal/config"
 	"github.com/dionysius/aarg/internal/log"
 	"github.com/dionysius/aarg/internal/provider"
+	"github.com/dionysius/aarg/internal/telemetry"
 )
 
-// Publish uploads generated repository to configured hosting provider
-func (a *Application) Publish(ctx context.Context) error {
-	// Get the configured provider
-	prov, err := a.getProvider()
+// ErrProviderNotEnabled is returned when publishing is limited to a provider that isn't enabled
+var ErrProviderNotEnabled = errors.New("publish provider not enabled")
+
+// Publish uploads generated repository to every enabled hosting provider
+// If staging is empty the current public build is uploaded, otherwise the staging build with that timestamp
+// With a public staging environment configured, the upload goes to the provider's staging environment
+// If branch is set the build is uploaded as preview of that branch instead, e.g. from CI branches
+// force skips the removal guard for production uploads
+func (a *Application) Publish(ctx context.Context, staging, branch string, force bool) (err error) {
+	ctx, span := telemetry.Start(ctx, "publish")
+	defer func() { telemetry.End(span, err) }()
+
+	// Resolve the build directory to upload
+	buildDir, err := a.resolvePublishDir(staging)
 	if err != nil {
-		return fmt.Errorf("failed to get provider: %w", err)
+		return err
+	}
+
+	// Remember the attempt until it succeeded for publish --retry
+	if err := a.recordPublishAttempt(buildDir, branch); err != nil {
+		slog.Warn("Failed to record publish attempt", "error", err)
 	}
 
-	slog.Info("Publishing repository", "provider", fmt.Sprintf("%T", prov))
+	switch {
+	case branch != "":
+		// Previews never replace production, promotion stays explicit
+		if err := a.publishPreview(ctx, buildDir, branch); err != nil {
+			return err
+		}
+	case a.Config.Directories.PublicStaging == "":
+		if err := a.publishProduction(ctx, buildDir, force); err != nil {
+			return err
+		}
+	default:
+		// Get the configured providers for the staging environment
+		provs, err := a.getProviders(a.environmentTarget(false))
+		if err != nil {
+			return fmt.Errorf("failed to get provider: %w", err)
+		}
 
-	// Upload the public directory
-	publicDir := a.Config.Directories.GetPublicPath()
-	if err := prov.Publish(ctx, publicDir); err != nil {
-		return fmt.Errorf("failed to publish: %w", err)
+		// Upload the build directory
+		if err := publishAll(ctx, provs, buildDir, "staging"); err != nil {
+			return err
+		}
+	}
+
+	if err := os.Remove(filepath.Join(a.Config.Directories.GetStagingPath(), publishAttemptFile)); err != nil && !os.IsNotExist(err) {
+		slog.Warn("Failed to clear publish attempt", "error", err)
 	}
 
 	slog.Info("Publish complete", log.Success())
@@ -30,23 +73,410 @@ func (a *Application) Publish(ctx context.Context) error {
 	return nil
 }
 
-// getProvider returns the configured deployment provider
-func (a *Application) getProvider() (provider.Provider, error) {
-	// Check for Cloudflare Pages configuration
-	if a.Config.Cloudflare.APIToken != "" && a.Config.Cloudflare.AccountID != "" && a.Config.Cloudflare.ProjectName != "" {
-		return provider.NewCloudflare(
-			a.Config.Cloudflare.APIToken,
-			a.Config.Cloudflare.AccountID,
-			a.Config.Cloudflare.ProjectName,
-			provider.CloudflareCleanupConfig{
-				OlderThanDays: a.Config.Cloudflare.Cleanup.OlderThanDays,
-				KeepLast:      a.Config.Cloudflare.Cleanup.KeepLast,
-			},
-			a.Config.Repositories,
-			a.Config.Generate.PoolMode,
-		)
-	}
-
-	// No provider configured
-	return nil, fmt.Errorf("no deployment provider configured (check cloudflare settings in config)")
+// RetryPublish publishes the staging build of the last failed publish again, to the same branch
+// Assets the failed attempt already uploaded are not uploaded again
+func (a *Application) RetryPublish(ctx context.Context, force bool) error {
+	data, err := os.ReadFile(filepath.Join(a.Config.Directories.GetStagingPath(), publishAttemptFile))
+	if os.IsNotExist(err) {
+		return fmt.Errorf("no failed publish to retry")
+	}
+	if err != nil {
+		return err
+	}
+
+	var attempt publishAttempt
+	if err := json.Unmarshal(data, &attempt); err != nil {
+		return fmt.Errorf("failed to read publish attempt: %w", err)
+	}
+
+	slog.Info("Retrying publish", "staging", attempt.Build, "branch", attempt.Branch)
+	return a.Publish(ctx, attempt.Build, attempt.Branch, force)
+}
+
+// publishAttemptFile records the publish in progress until it succeeded, relative to the staging directory
+const publishAttemptFile = ".publish-attempt"
+
+// publishAttempt is the staging build and preview branch of a publish
+type publishAttempt struct {
+	Build  string `json:"build"`
+	Branch string `json:"branch,omitempty"`
+}
+
+// recordPublishAttempt stores the staging build and branch of a publish in progress
+func (a *Application) recordPublishAttempt(buildDir, branch string) error {
+	resolvedDir, err := filepath.EvalSymlinks(buildDir)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(publishAttempt{Build: filepath.Base(resolvedDir), Branch: branch})
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(a.Config.Directories.GetStagingPath(), publishAttemptFile), data, 0644)
+}
+
+// publishAll uploads the build directory with every provider of an environment in turn
+func publishAll(ctx context.Context, provs []provider.Provider, buildDir, environment string) error {
+	for _, prov := range provs {
+		slog.Info("Publishing repository", "provider", prov.Name(), "dir", buildDir)
+
+		if err := publishTo(ctx, prov, buildDir, environment); err != nil {
+			return fmt.Errorf("failed to publish to %s: %w", prov.Name(), err)
+		}
+	}
+	return nil
+}
+
+// publishTo uploads the build directory with the provider, traced per environment
+func publishTo(ctx context.Context, prov provider.Provider, buildDir, environment string) (err error) {
+	ctx, span := telemetry.Start(ctx, "provider.publish",
+		telemetry.ProviderKey.String(prov.Name()),
+		telemetry.EnvironmentKey.String(environment),
+	)
+	defer func() { telemetry.End(span, err) }()
+
+	return prov.Publish(ctx, buildDir)
+}
+
+// resolvePublishDir returns the directory to publish for the given staging timestamp
+func (a *Application) resolvePublishDir(staging string) (string, error) {
+	if staging == "" {
+		publicDir := a.currentPublicPath()
+		if _, err := os.Stat(publicDir); err != nil {
+			return "", fmt.Errorf("no public build available, run generate first: %w", err)
+		}
+		return publicDir, nil
+	}
+
+	if !isStagingBuildName(staging) {
+		return "", fmt.Errorf("invalid staging build %q: expected format YYYYMMDD-HHMMSS with optional -label", staging)
+	}
+
+	stagingDir := filepath.Join(a.Config.Directories.GetStagingPath(), staging)
+	info, err := os.Stat(stagingDir)
+	if err != nil {
+		return "", fmt.Errorf("staging build %s not found: %w", staging, err)
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("staging build %s is not a directory", staging)
+	}
+
+	return stagingDir, nil
+}
+
+// currentPublicPath returns the symlink updated by generate
+// This is the public staging symlink if configured, otherwise the public symlink
+func (a *Application) currentPublicPath() string {
+	if publicStaging := a.Config.Directories.GetPublicStagingPath(); publicStaging != "" {
+		return publicStaging
+	}
+	return a.Config.Directories.GetPublicPath()
+}
+
+// publishProduction uploads a build to the production environment
+// If a canary is configured the build is published and verified there first
+func (a *Application) publishProduction(ctx context.Context, buildDir string, force bool) error {
+	// Refuse to publish builds removing too many packages
+	if !force {
+		if err := a.checkRemovals(buildDir); err != nil {
+			return err
+		}
+	}
+
+	canary, err := a.getCanaryProvider()
+	if err != nil {
+		return fmt.Errorf("failed to get canary provider: %w", err)
+	}
+
+	if canary != nil {
+		slog.Info("Publishing repository to canary", "provider", canary.Name(), "dir", buildDir)
+
+		if err := publishTo(ctx, canary, buildDir, "canary"); err != nil {
+			return fmt.Errorf("failed to publish canary: %w", err)
+		}
+
+		if err := a.verifyDeployment(ctx, canary.GetURL(), buildDir); err != nil {
+			return fmt.Errorf("canary verification failed, production left untouched: %w", err)
+		}
+	}
+
+	// Get the configured providers for the production environment
+	provs, err := a.getProviders(a.environmentTarget(true))
+	if err != nil {
+		return fmt.Errorf("failed to get provider: %w", err)
+	}
+
+	// Upload the build directory
+	if err := publishAll(ctx, provs, buildDir, "production"); err != nil {
+		return err
+	}
+
+	// Remember the build as baseline for the next removal check
+	if err := a.recordPublishedBuild(buildDir); err != nil {
+		slog.Warn("Failed to record published build", "error", err)
+	}
+
+	if a.Config.Cloudflare.ManageDomain {
+		a.checkDomain(ctx)
+	}
+
+	return nil
+}
+
+// checkDomain ensures the host of the configured URL is a custom domain of the project
+// and warns if it doesn't serve the project, since install.sh and sources lists point at it
+// The deployment itself succeeded at this point, so problems are only reported
+func (a *Application) checkDomain(ctx context.Context) {
+	u, err := url.Parse(a.Config.URL)
+	if err != nil {
+		slog.Warn("Failed to parse URL for custom domain", "url", a.Config.URL, "error", err)
+		return
+	}
+	domain := u.Hostname()
+
+	names, err := a.publishNames()
+	if err != nil {
+		slog.Warn("Failed to get provider for custom domain", "domain", domain, "error", err)
+		return
+	}
+	// Custom domains are managed with Cloudflare Pages, other providers report them unsupported
+	name := names[0]
+	if slices.Contains(names, config.ProviderCloudflare) {
+		name = config.ProviderCloudflare
+	}
+
+	prov, err := a.newProvider(name, a.Config.Cloudflare.ProjectName, a.environmentTarget(true))
+	if err != nil {
+		slog.Warn("Failed to get provider for custom domain", "domain", domain, "error", err)
+		return
+	}
+	manager, ok := prov.(provider.DomainManager)
+	if !ok {
+		slog.Warn("Provider does not support custom domains", "provider", prov.Name(), "domain", domain)
+		return
+	}
+
+	status, err := manager.EnsureDomain(ctx, domain)
+	if err != nil {
+		slog.Warn("Failed to manage custom domain", "domain", domain, "error", err)
+		return
+	}
+	if status.Created {
+		slog.Info("Custom domain added", "domain", domain, "target", status.Target)
+	}
+	if !status.Active() {
+		slog.Warn("Custom domain is not active yet", "domain", domain, "status", status.Status, "message", status.Message)
+	}
+
+	if err := provider.CheckDomainDNS(ctx, domain, status.Target); err != nil {
+		slog.Warn("Custom domain does not point at the project, check the DNS records", "domain", domain, "error", err)
+		return
+	}
+
+	if status.Active() {
+		slog.Info("Custom domain verified", "domain", domain, log.Success())
+	}
+}
+
+// publishPreview uploads a build as preview deployment of a branch
+// Previews only exist on Cloudflare Pages, other providers are left untouched
+func (a *Application) publishPreview(ctx context.Context, buildDir, branch string) error {
+	names, err := a.publishNames()
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(names, config.ProviderCloudflare) {
+		return fmt.Errorf("preview branches are only supported with Cloudflare Pages")
+	}
+	if branch == a.Config.Cloudflare.ProductionBranch {
+		return fmt.Errorf("branch %q is the production branch, publish without --branch or promote instead", branch)
+	}
+
+	provs, err := a.cloudflareProviders(provider.CloudflareTarget{Branch: branch})
+	if err != nil {
+		return fmt.Errorf("failed to get provider: %w", err)
+	}
+
+	if err := publishAll(ctx, provs, buildDir, "preview"); err != nil {
+		return err
+	}
+
+	for _, prov := range provs {
+		slog.Info("Preview published", "branch", branch, "url", prov.GetURL())
+	}
+	return nil
+}
+
+// environmentTarget returns the deployment branch of the production or staging environment
+func (a *Application) environmentTarget(production bool) provider.CloudflareTarget {
+	if production {
+		return provider.CloudflareTarget{Branch: a.Config.Cloudflare.ProductionBranch, Production: true}
+	}
+	return provider.CloudflareTarget{Branch: a.Config.Cloudflare.StagingBranch}
+}
+
+// publishNames returns the names of the enabled providers in publish order, limited to PublishOnly if set
+func (a *Application) publishNames() ([]string, error) {
+	names := a.Config.Publish.ProviderNames()
+	if len(a.PublishOnly) == 0 {
+		return names, nil
+	}
+
+	for _, name := range a.PublishOnly {
+		if !slices.Contains(names, name) {
+			return nil, fmt.Errorf("%w: %s (enabled: %s)", ErrProviderNotEnabled, name, strings.Join(names, ", "))
+		}
+	}
+	return slices.DeleteFunc(slices.Clone(names), func(name string) bool {
+		return !slices.Contains(a.PublishOnly, name)
+	}), nil
+}
+
+// getProviders returns the deployment providers for the target, in the order they publish
+func (a *Application) getProviders(target provider.CloudflareTarget) ([]provider.Provider, error) {
+	names, err := a.publishNames()
+	if err != nil {
+		return nil, err
+	}
+
+	var provs []provider.Provider
+	for _, name := range names {
+		if name == config.ProviderCloudflare {
+			pages, err := a.cloudflareProviders(target)
+			if err != nil {
+				return nil, err
+			}
+			provs = append(provs, pages...)
+			continue
+		}
+
+		prov, err := a.newProvider(name, a.Config.Cloudflare.ProjectName, target)
+		if err != nil {
+			return nil, err
+		}
+		provs = append(provs, prov)
+	}
+	return provs, nil
+}
+
+// cloudflareProviders returns the Cloudflare Pages providers for the target
+// With a repository project configured every repository is uploaded to its own Pages project
+// together with the shared files, the shared project only receives the shared files
+func (a *Application) cloudflareProviders(target provider.CloudflareTarget) ([]provider.Provider, error) {
+	if a.Config.Cloudflare.RepositoryProject == "" {
+		prov, err := a.newCloudflare(a.Config.Cloudflare.ProjectName, target, a.Config.Repositories)
+		if err != nil {
+			return nil, err
+		}
+		return []provider.Provider{prov}, nil
+	}
+
+	// Shared files are all files outside of repository directories (index page, assets, keys)
+	repoDirs := make(map[string]bool, len(a.Config.Repositories))
+	for _, repo := range a.Config.Repositories {
+		repoDirs[repo.Name] = true
+	}
+	isShared := func(relPath string) bool {
+		dir, _, found := strings.Cut(relPath, "/")
+		return !found || !repoDirs[dir]
+	}
+
+	var provs []provider.Provider
+	if a.Config.Cloudflare.ProjectName != "" {
+		prov, err := a.newCloudflare(a.Config.Cloudflare.ProjectName, target, nil)
+		if err != nil {
+			return nil, err
+		}
+		prov.SetFilter(isShared)
+		provs = append(provs, prov)
+	}
+
+	for _, repo := range a.Config.Repositories {
+		project, err := a.Config.Cloudflare.RepositoryProjectName(repo.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		prov, err := a.newCloudflare(project, target, []*config.RepositoryConfig{repo})
+		if err != nil {
+			return nil, err
+		}
+		prefix := repo.Name + "/"
+		prov.SetFilter(func(relPath string) bool {
+			return strings.HasPrefix(relPath, prefix) || isShared(relPath)
+		})
+		provs = append(provs, prov)
+	}
+
+	return provs, nil
+}
+
+// getCanaryProvider returns the canary deployment provider, or nil if no canary is configured
+// The canary always receives the complete build
+func (a *Application) getCanaryProvider() (provider.Provider, error) {
+	if a.Config.Cloudflare.CanaryProject == "" {
+		return nil, nil
+	}
+
+	names, err := a.publishNames()
+	if err != nil {
+		return nil, err
+	}
+	name := names[0]
+	if len(a.Config.Publish.Providers) > 0 {
+		// With several providers the canary is a Pages project, skipped if Cloudflare isn't published to
+		if !slices.Contains(names, config.ProviderCloudflare) {
+			return nil, nil
+		}
+		name = config.ProviderCloudflare
+	}
+	return a.newProvider(name, a.Config.Cloudflare.CanaryProject, a.environmentTarget(true))
+}
+
+// newProvider creates the named deployment provider for the given project and target
+func (a *Application) newProvider(name, projectName string, target provider.CloudflareTarget) (provider.Provider, error) {
+	switch name {
+	case config.ProviderCloudflare:
+		return a.newCloudflare(projectName, target, a.Config.Repositories)
+	case config.ProviderDirectory:
+		dir := a.Config.Publish.Directory
+		return provider.NewDirectory(dir.GetPath(a.Config.Directories.Root, target.Production), dir.Mode == "hardlink", dir.Keep)
+	default:
+		// Any other provider is a provider plugin
+		return provider.NewPlugin(name, a.Config.Plugins[name], projectName, target.Production)
+	}
+}
+
+// newCloudflare creates the Cloudflare Pages provider for the given project and target
+// repositories are the repositories served by the project, used for the pool redirects
+func (a *Application) newCloudflare(projectName string, target provider.CloudflareTarget, repositories []*config.RepositoryConfig) (*provider.PagesProvider, error) {
+	if a.Config.Cloudflare.APIToken == "" || a.Config.Cloudflare.AccountID == "" || projectName == "" {
+		// No provider configured
+		return nil, fmt.Errorf("no deployment provider configured (check cloudflare, publish plugin or publish directory settings in config)")
+	}
+
+	prov, err := provider.NewCloudflare(
+		a.Config.Cloudflare.APIToken,
+		a.Config.Cloudflare.AccountID,
+		projectName,
+		target,
+		provider.CloudflareCleanupConfig{
+			OlderThanDays: a.Config.Cloudflare.Cleanup.OlderThanDays,
+			KeepLast:      a.Config.Cloudflare.Cleanup.KeepLast,
+		},
+		provider.CloudflareLimits{
+			Concurrency:       a.Config.Cloudflare.Concurrency,
+			RequestsPerMinute: a.Config.Cloudflare.RequestsPerMinute,
+		},
+		repositories,
+		a.Config.Generate.PoolMode,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// Failed uploads resume with the assets not uploaded yet
+	prov.SetStateStore(a.MetadataStore)
+	return prov, nil
 }
@@ -0,0 +1,46 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dionysius/aarg/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryPublish(t *testing.T) {
+	dir := t.TempDir()
+	www := filepath.Join(dir, "www")
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "repos.d"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "repos.d", "hello.yaml"), []byte("feeds:\n  - github: example/hello\n"), 0644))
+	configPath := filepath.Join(dir, "config.yaml")
+	require.NoError(t, os.WriteFile(configPath, []byte(fmt.Sprintf("directories:\n  root: %q\npublish:\n  directory:\n    path: %q\npreflight:\n  disabled: true\n", dir, www)), 0644))
+	cfg, err := config.Load(configPath)
+	require.NoError(t, err)
+	application := &Application{Config: cfg}
+
+	err = application.RetryPublish(context.Background(), false)
+	assert.ErrorContains(t, err, "no failed publish to retry")
+
+	// Two builds, the failed publish attempt was the older one
+	staging := cfg.Directories.GetStagingPath()
+	for _, build := range []string{"20250101-120000", "20250102-120000"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(staging, build), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(staging, build, "index.html"), []byte(build), 0644))
+	}
+	require.NoError(t, application.recordPublishAttempt(filepath.Join(staging, "20250101-120000"), ""))
+
+	require.NoError(t, application.RetryPublish(context.Background(), false))
+	data, err := os.ReadFile(filepath.Join(www, "index.html"))
+	require.NoError(t, err)
+	assert.Equal(t, "20250101-120000", string(data))
+
+	// The attempt is cleared once published
+	assert.NoFileExists(t, filepath.Join(staging, publishAttemptFile))
+	err = application.RetryPublish(context.Background(), false)
+	assert.ErrorContains(t, err, "no failed publish to retry")
+}
@@ -0,0 +1,36 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/aptly-dev/aptly/query"
+	"github.com/dionysius/aarg/internal/compose"
+)
+
+// Query evaluates an aptly package query against a repository as generated into the current build
+// With trusted the packages in trusted storage are queried instead, including the ones retention drops
+func (a *Application) Query(ctx context.Context, repoName, expression string, trusted bool) ([]compose.QueryResult, error) {
+	repo := a.findRepository(repoName)
+	if repo == nil {
+		return nil, fmt.Errorf("repository not found: %s", repoName)
+	}
+
+	q, err := query.Parse(expression)
+	if err != nil {
+		return nil, fmt.Errorf("invalid package query %q: %w", expression, err)
+	}
+
+	if trusted {
+		deps := a.composeDependencies("")
+		deps.Repository = repo
+		return compose.QueryTrusted(ctx, deps, q)
+	}
+
+	buildDir, err := filepath.EvalSymlinks(a.currentPublicPath())
+	if err != nil {
+		return nil, fmt.Errorf("no generated build to query, run generate first or use --trusted: %w", err)
+	}
+	return compose.QueryRepository(buildDir, repo.Name, q)
+}
@@ -0,0 +1,76 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/dionysius/aarg/internal/compose"
+	"github.com/dionysius/aarg/internal/log"
+	"github.com/dionysius/aarg/internal/telemetry"
+)
+
+// RegenerateWeb rebuilds the web pages of an existing build from its saved repository state
+// If staging is empty the current public build is regenerated, otherwise the staging build with that timestamp
+// Apt indices and pool files of the build are left untouched
+func (a *Application) RegenerateWeb(ctx context.Context, staging string) (err error) {
+	ctx, span := telemetry.Start(ctx, "regenerate-web")
+	defer func() { telemetry.End(span, err) }()
+
+	buildDir, err := a.resolvePublishDir(staging)
+	if err != nil {
+		return err
+	}
+
+	// Compose into the build the public symlink points to
+	if resolved, err := filepath.EvalSymlinks(buildDir); err == nil {
+		buildDir = resolved
+	}
+
+	web, ok := compose.Lookup(compose.ComposerWeb)
+	if !ok {
+		return fmt.Errorf("unknown composer %q", compose.ComposerWeb)
+	}
+
+	deps := a.composeDependencies(buildDir)
+	deps.PreviousPath = buildDir // Keep the layout of the regenerated build
+
+	var regenerated int
+	for _, repo := range a.Config.Repositories {
+		repository, err := compose.LoadRepositoryState(buildDir, repo.Name)
+		if errors.Is(err, os.ErrNotExist) {
+			slog.Warn("Repository state missing in build, run generate first", "repository", repo.Name, "build", filepath.Base(buildDir))
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		repoDeps := deps
+		repoDeps.Repository = repo
+		if err := web.Compose(ctx, repoDeps, &compose.Results{Repository: repository}); err != nil {
+			return err
+		}
+		log.CountRepositories(repo.Name)
+		regenerated++
+	}
+
+	if regenerated == 0 {
+		return fmt.Errorf("no repository state found in build %s", filepath.Base(buildDir))
+	}
+
+	if err := web.Index(ctx, deps); err != nil {
+		return err
+	}
+
+	// Siblings of regenerated files are stale
+	if err := compose.Precompress(buildDir, a.Config.Generate.Precompress); err != nil {
+		return err
+	}
+
+	slog.Info("Web regenerate complete", "build", filepath.Base(buildDir), "repositories", regenerated, log.Success())
+	return nil
+}
@@ -0,0 +1,109 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/dionysius/aarg/internal/common"
+	"github.com/dionysius/aarg/internal/config"
+	"github.com/dionysius/aarg/internal/log"
+)
+
+// ReportFile is the run report written to the staging directory at the end of every run processing repositories
+const ReportFile = "report.json"
+
+// Report is the machine-readable outcome of a run for CI pipelines
+type Report struct {
+	Summary      log.Summary        `json:"summary"`
+	Repositories []RepositoryReport `json:"repositories"`
+}
+
+// RepositoryReport is what happened to a repository during the run
+type RepositoryReport struct {
+	Name     string                                  `json:"name"`
+	Owners   []string                                `json:"owners,omitempty"`
+	Added    []string                                `json:"added"`    // Packages and versions not in the previous build, as dist/component/arch/name version
+	Removed  []string                                `json:"removed"`  // Packages not in the build anymore, as dist/component/arch/name
+	Dropped  []string                                `json:"dropped"`  // Packages in trusted storage dropped by retention
+	Upstream map[string][]string                     `json:"upstream"` // Versions in trusted storage per source package, oldest first
+	Errors   []string                                `json:"errors"`
+	Warnings []log.Warning                           `json:"warnings"`
+	Timings  map[string]float64                      `json:"timings"`          // Duration of the fetch, generate and publish phases in seconds
+	Assets   map[string]common.DownloadStatsSnapshot `json:"assets,omitempty"` // Release assets cached and downloaded per feed
+}
+
+// NewReport returns the report of the repositories processed by the run
+func NewReport(cfg *config.Config, summary log.Summary) Report {
+	report := Report{Summary: summary, Repositories: []RepositoryReport{}}
+	for _, name := range log.Repositories() {
+		var owners []string
+		if i := slices.IndexFunc(cfg.Repositories, func(repo *config.RepositoryConfig) bool { return repo.Name == name }); i >= 0 {
+			owners = cfg.Repositories[i].Owners
+		}
+
+		record := log.Record(name)
+		report.Repositories = append(report.Repositories, RepositoryReport{
+			Name:     name,
+			Owners:   owners,
+			Added:    append([]string{}, record.Added...),
+			Removed:  append([]string{}, record.Removed...),
+			Dropped:  append([]string{}, record.Dropped...),
+			Upstream: record.Upstream,
+			Errors:   append([]string{}, record.Errors...),
+			Warnings: append([]log.Warning{}, log.RepositoryWarnings(name)...),
+			Timings:  record.Timings,
+			Assets:   record.Assets,
+		})
+	}
+	return report
+}
+
+// WriteReport writes the report to the staging directory, replacing the one of the previous run
+// It's kept out of the builds so it is never published
+func WriteReport(cfg *config.Config, report Report) error {
+	stagingDir := cfg.Directories.GetStagingPath()
+	if err := os.MkdirAll(stagingDir, 0755); err != nil {
+		return fmt.Errorf("failed to create staging directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file and rename, so readers never see a partial report
+	path := filepath.Join(stagingDir, ReportFile)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// ReportBuild writes the report of a build started at start and notifies about it, then resets the state of the run
+// Long-lived commands like daemon and watch report every build on its own, the next build starts without the
+// warnings, records and counters of this one
+func (a *Application) ReportBuild(ctx context.Context, command string, start time.Time, err error) {
+	defer log.ResetRun()
+
+	summary := log.NewSummary(command, start, err)
+	summary.Config = a.ConfigHash
+	if len(log.Repositories()) > 0 {
+		if err := WriteReport(a.Config, NewReport(a.Config, summary)); err != nil {
+			slog.Error("Failed to write report", "error", err)
+		}
+	}
+	if err := Notify(ctx, a.Config, summary); err != nil {
+		slog.Error("Failed to send notifications", "error", err)
+	}
+}
@@ -0,0 +1,21 @@
+package app
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dionysius/aarg/internal/compose"
+)
+
+// PreviewRetention returns which package versions of a repository in trusted storage its retention keeps or drops
+// Packages are parsed and filtered like during generate, nothing is generated or removed
+func (a *Application) PreviewRetention(ctx context.Context, repoName string) ([]compose.RetentionDecision, error) {
+	repo := a.findRepository(repoName)
+	if repo == nil {
+		return nil, fmt.Errorf("repository not found: %s", repoName)
+	}
+
+	deps := a.composeDependencies("")
+	deps.Repository = repo
+	return compose.PreviewRetention(ctx, deps)
+}
@@ -0,0 +1,262 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/dionysius/aarg/debext"
+	"github.com/dionysius/aarg/internal/common"
+	"github.com/dionysius/aarg/internal/config"
+	"github.com/dionysius/aarg/internal/log"
+)
+
+// ErrRotateNoSigningKey is returned when a key rotation is started without configured signing keys
+var ErrRotateNoSigningKey = errors.New("key rotation requires signing.private_key and signing.public_key")
+
+// rotateKeyBits is the RSA key size of generated signing keys
+const rotateKeyBits = 4096
+
+// RotateKeyOptions configures a signing key rotation
+type RotateKeyOptions struct {
+	Import     string // Private key file of the new key, empty generates a new key
+	Passphrase string // Passphrase of the imported key or to protect the generated key with
+	Name       string // Name of the generated key, empty = the one of the current key
+	Email      string // Email of the generated key, empty = the one of the current key
+	OutputDir  string // Directory the key files are written to, empty = directory of the current private key
+}
+
+// RotatedKeys are the key files written by a key rotation
+type RotatedKeys struct {
+	PrivateKey     string // Private key of the new key
+	PublicKey      string // Public key of the new key, certified by the current key
+	Fingerprint    string // Fingerprint of the new key
+	PreviousPublic string // Public key of the current key, certified by the new key
+}
+
+// RotateKey starts a rotation of the signing key
+// The new key and the current key certify each other. Release files of the published tree are signed
+// again by both keys and keys/ carries both public keys, so clients trusting either key keep verifying
+// and new installs trust both. The configuration is left to the operator, see the returned key files
+func RotateKey(cfg *config.Config, opts RotateKeyOptions) (*RotatedKeys, error) {
+	privateKeyPath := cfg.Signing.GetPrivateKeyPath(cfg.ConfigDir)
+	publicKeyPath := cfg.Signing.GetPublicKeyPath(cfg.ConfigDir)
+	if privateKeyPath == "" || publicKeyPath == "" {
+		return nil, ErrRotateNoSigningKey
+	}
+
+	current, err := readPrivateKey(privateKeyPath, cfg.Signing.Passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	var next *openpgp.Entity
+	if opts.Import != "" {
+		if next, err = readPrivateKey(opts.Import, opts.Passphrase); err != nil {
+			return nil, err
+		}
+	} else {
+		if next, err = generateKey(current, opts); err != nil {
+			return nil, err
+		}
+	}
+	if next.PrimaryKey.KeyId == current.PrimaryKey.KeyId {
+		return nil, fmt.Errorf("new key %s is the current signing key", next.PrimaryKey.KeyIdString())
+	}
+
+	if err := debext.CrossSign(current, next); err != nil {
+		return nil, err
+	}
+
+	outputDir := opts.OutputDir
+	if outputDir == "" {
+		outputDir = filepath.Dir(privateKeyPath)
+	}
+	rotated, err := writeRotatedKeys(outputDir, current, next, opts.Passphrase)
+	if err != nil {
+		return nil, err
+	}
+	slog.Info("Wrote cross-signed keys", "key", rotated.Fingerprint, "private_key", rotated.PrivateKey, "public_key", rotated.PublicKey, "previous_public_key", rotated.PreviousPublic)
+
+	// The new key signs first, followed by the current key and further keys of an ongoing rotation
+	signer := &debext.Signer{Keys: []debext.SigningKey{
+		{Path: rotated.PrivateKey, Passphrase: opts.Passphrase},
+		{Path: privateKeyPath, KeyRef: cfg.Signing.Key, Passphrase: cfg.Signing.Passphrase},
+	}}
+	publicKeyPaths := []string{rotated.PublicKey, rotated.PreviousPublic}
+	for _, key := range cfg.Signing.Additional {
+		signer.Keys = append(signer.Keys, debext.SigningKey{Path: key.GetPrivateKeyPath(cfg.ConfigDir), KeyRef: key.Key, Passphrase: key.Passphrase})
+		publicKeyPaths = append(publicKeyPaths, key.GetPublicKeyPath(cfg.ConfigDir))
+	}
+	if err := signer.Init(); err != nil {
+		return nil, err
+	}
+
+	if err := resignPublished(cfg, signer, publicKeyPaths); err != nil {
+		return nil, err
+	}
+	return rotated, nil
+}
+
+// readPrivateKey reads the first private key of a key file and decrypts it
+func readPrivateKey(path, passphrase string) (*openpgp.Entity, error) {
+	keys, err := debext.ReadKeys(path)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entity := range keys {
+		if entity.PrivateKey == nil {
+			continue
+		}
+		if entity.PrivateKey.Encrypted {
+			if passphrase == "" {
+				return nil, fmt.Errorf("%w: key %s of %s requires a passphrase", debext.ErrSigningKeyLocked, entity.PrimaryKey.KeyIdString(), path)
+			}
+			if err := entity.DecryptPrivateKeys([]byte(passphrase)); err != nil {
+				return nil, fmt.Errorf("%w: key %s of %s: %w", debext.ErrSigningKeyLocked, entity.PrimaryKey.KeyIdString(), path, err)
+			}
+		}
+		return entity, nil
+	}
+	return nil, fmt.Errorf("%w: no private key in %s", debext.ErrSigningKeyNotFound, path)
+}
+
+// generateKey creates a new signing key with the user ID of the current key unless set otherwise
+func generateKey(current *openpgp.Entity, opts RotateKeyOptions) (*openpgp.Entity, error) {
+	name, email := opts.Name, opts.Email
+	if identity := current.PrimaryIdentity(); identity != nil && identity.UserId != nil {
+		if name == "" {
+			name = identity.UserId.Name
+		}
+		if email == "" {
+			email = identity.UserId.Email
+		}
+	}
+
+	slog.Info("Generating signing key", "name", name, "email", email, "bits", rotateKeyBits)
+	return openpgp.NewEntity(name, "", email, &packet.Config{Algorithm: packet.PubKeyAlgoRSA, RSABits: rotateKeyBits})
+}
+
+// writeRotatedKeys writes the new key pair and the cross-signed public key of the current key to dir
+// Existing files are never overwritten
+func writeRotatedKeys(dir string, current, next *openpgp.Entity, passphrase string) (*RotatedKeys, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	rotated := &RotatedKeys{
+		PrivateKey:     filepath.Join(dir, "signing-private-"+next.PrimaryKey.KeyIdString()+".asc"),
+		PublicKey:      filepath.Join(dir, "signing-public-"+next.PrimaryKey.KeyIdString()+".asc"),
+		Fingerprint:    debext.DescribeKeys(openpgp.EntityList{next})[0].Fingerprint,
+		PreviousPublic: filepath.Join(dir, "signing-public-"+current.PrimaryKey.KeyIdString()+".asc"),
+	}
+
+	if passphrase != "" {
+		if err := next.EncryptPrivateKeys([]byte(passphrase), nil); err != nil {
+			return nil, fmt.Errorf("failed to encrypt new key: %w", err)
+		}
+	}
+
+	files := []struct {
+		path      string
+		mode      os.FileMode
+		blockType string
+		serialize func(io.Writer) error
+	}{
+		{rotated.PrivateKey, 0600, openpgp.PrivateKeyType, func(w io.Writer) error { return next.SerializePrivateWithoutSigning(w, nil) }},
+		{rotated.PublicKey, 0644, openpgp.PublicKeyType, next.Serialize},
+		{rotated.PreviousPublic, 0644, openpgp.PublicKeyType, current.Serialize},
+	}
+	for _, file := range files {
+		if _, err := os.Stat(file.path); err == nil {
+			return nil, fmt.Errorf("%s already exists", file.path)
+		}
+	}
+	for _, file := range files {
+		f, err := os.OpenFile(file.path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, file.mode)
+		if err != nil {
+			return nil, err
+		}
+		if err := debext.WriteArmored(f, file.blockType, file.serialize); err != nil {
+			_ = f.Close()
+			return nil, fmt.Errorf("failed to write %s: %w", file.path, err)
+		}
+		if err := f.Close(); err != nil {
+			return nil, err
+		}
+	}
+	return rotated, nil
+}
+
+// resignPublished signs the Release files of the published tree again and replaces its public keys
+// Files are replaced by rename, hardlinked copies in previous builds and snapshots keep their signatures
+func resignPublished(cfg *config.Config, signer *debext.Signer, publicKeyPaths []string) error {
+	publicPath := cfg.Directories.GetPublicPath()
+	if _, err := os.Stat(publicPath); errors.Is(err, os.ErrNotExist) {
+		slog.Warn("No published tree to sign again, the next build signs with all keys", "path", publicPath)
+		return nil
+	}
+
+	publicKeyASCII, publicKeyBinary, err := readPublicKeys(publicKeyPaths)
+	if err != nil {
+		return err
+	}
+	keysDir := filepath.Join(publicPath, "keys")
+	if err := common.WriteFileAtomic(filepath.Join(keysDir, "signing-key.asc"), publicKeyASCII, 0644); err != nil {
+		return err
+	}
+	if err := common.WriteFileAtomic(filepath.Join(keysDir, "signing-key.gpg"), publicKeyBinary, 0644); err != nil {
+		return err
+	}
+
+	signed := 0
+	for _, repo := range cfg.Repositories {
+		releases, err := filepath.Glob(filepath.Join(publicPath, repo.Name, "dists", "*", "Release"))
+		if err != nil {
+			return err
+		}
+		for _, release := range releases {
+			if err := resignRelease(signer, release); err != nil {
+				return fmt.Errorf("repository %s: %w", repo.Name, err)
+			}
+			signed++
+		}
+	}
+
+	slog.Info("Signed published Release files with all keys", "path", publicPath, "releases", signed, log.Success())
+	return nil
+}
+
+// resignRelease writes InRelease and Release.gpg of a Release file signed by all keys of signer
+func resignRelease(signer *debext.Signer, release string) error {
+	dir := filepath.Dir(release)
+	signatures := []struct {
+		name string
+		sign func(source, destination string) error
+	}{
+		{"InRelease", signer.ClearSign},
+		{"Release.gpg", signer.DetachedSign},
+	}
+	for _, signature := range signatures {
+		tmp := filepath.Join(dir, "."+signature.name+".tmp")
+		if err := signature.sign(release, tmp); err != nil {
+			_ = os.Remove(tmp)
+			return err
+		}
+		if err := os.Chmod(tmp, 0644); err != nil {
+			_ = os.Remove(tmp)
+			return err
+		}
+		if err := os.Rename(tmp, filepath.Join(dir, signature.name)); err != nil {
+			_ = os.Remove(tmp)
+			return err
+		}
+	}
+	return nil
+}
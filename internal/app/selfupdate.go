@@ -0,0 +1,308 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strings"
+
+	"github.com/aptly-dev/aptly/pgp"
+	"github.com/dionysius/aarg/debext"
+	"github.com/dionysius/aarg/internal/feed"
+	"github.com/dionysius/aarg/internal/log"
+	"github.com/google/go-github/v80/github"
+)
+
+const (
+	// selfUpdateOwner and selfUpdateRepo are the GitHub repository aarg releases are published in
+	selfUpdateOwner = "dionysius"
+	selfUpdateRepo  = "aarg"
+	// selfUpdateChecksums is the checksums asset of a release, optionally with a detached .asc signature
+	selfUpdateChecksums = "SHA256SUMS"
+)
+
+// selfUpdateKeys holds the release signing keys the checksums signature is verified against by default
+//
+//go:embed selfupdate-keys.asc
+var selfUpdateKeys []byte
+
+var (
+	// ErrSelfUpdateAsset is returned when a release has no binary for the running platform
+	ErrSelfUpdateAsset = errors.New("no release binary for this platform")
+	// ErrSelfUpdateUnverified is returned when the downloaded binary cannot be verified
+	ErrSelfUpdateUnverified = errors.New("release binary cannot be verified")
+)
+
+// SelfUpdateOptions configures a self update
+type SelfUpdateOptions struct {
+	Release   string   // Release tag to install, empty = latest
+	KeyPaths  []string // Keys the checksums signature must verify against, empty = embedded release keys
+	Insecure  bool     // Accept unsigned checksums if no keys are given, instead of the embedded release keys
+	CheckOnly bool     // Only report whether an update is available
+	Force     bool     // Install even if the release is already running or the running version is unknown
+	Token     string   // GitHub token, empty = unauthenticated
+}
+
+// CurrentVersion returns the version of the running binary, "(devel)" for builds from a working tree
+func CurrentVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok || info.Main.Version == "" {
+		return "(devel)"
+	}
+	return info.Main.Version
+}
+
+// SelfUpdateAsset returns the release asset name of the binary for the running platform
+func SelfUpdateAsset() string {
+	return "aarg-" + runtime.GOOS + "-" + runtime.GOARCH
+}
+
+// SelfUpdate replaces the running binary with a release binary from GitHub
+// The binary is verified against the release checksums and GitHub's digest, the checksums also
+// against their signature made by the embedded release keys or the given keys unless insecure,
+// and replaces the running binary atomically
+func SelfUpdate(ctx context.Context, opts SelfUpdateOptions) error {
+	client := github.NewClient(http.DefaultClient)
+	if opts.Token != "" {
+		client = client.WithAuthToken(opts.Token)
+	}
+
+	var release *github.RepositoryRelease
+	var err error
+	if opts.Release != "" {
+		release, _, err = client.Repositories.GetReleaseByTag(ctx, selfUpdateOwner, selfUpdateRepo, opts.Release)
+	} else {
+		release, _, err = client.Repositories.GetLatestRelease(ctx, selfUpdateOwner, selfUpdateRepo)
+	}
+	if err != nil {
+		return fmt.Errorf("could not get release: %w", err)
+	}
+
+	current := CurrentVersion()
+	latest := release.GetTagName()
+	if current == latest && !opts.Force {
+		slog.Info("Already up to date", "version", current)
+		return nil
+	}
+	if opts.CheckOnly {
+		slog.Info("Update available", "current", current, "release", latest)
+		return nil
+	}
+	if current == "(devel)" && !opts.Force {
+		return fmt.Errorf("running version is unknown, use --force to replace it with %s", latest)
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	if executable, err = filepath.EvalSymlinks(executable); err != nil {
+		return err
+	}
+
+	assets := make(map[string]*github.ReleaseAsset, len(release.Assets))
+	for _, asset := range release.Assets {
+		assets[asset.GetName()] = asset
+	}
+	asset, ok := assets[SelfUpdateAsset()]
+	if !ok {
+		return fmt.Errorf("%w: %s in release %s", ErrSelfUpdateAsset, SelfUpdateAsset(), latest)
+	}
+
+	// Downloads go next to the binary, so the final rename stays on the same filesystem
+	dir, err := os.MkdirTemp(filepath.Dir(executable), ".aarg-update-")
+	if err != nil {
+		return fmt.Errorf("could not create download directory next to %s: %w", executable, err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	keyPaths, err := selfUpdateKeyPaths(dir, opts)
+	if err != nil {
+		return err
+	}
+	checksum, err := selfUpdateChecksum(ctx, dir, assets, asset, keyPaths)
+	if err != nil {
+		return err
+	}
+
+	slog.Info("Downloading release", "version", latest, "asset", asset.GetName(), "size", asset.GetSize())
+	binaryPath := filepath.Join(dir, asset.GetName())
+	if err := selfUpdateDownload(ctx, asset.GetBrowserDownloadURL(), binaryPath, checksum); err != nil {
+		return err
+	}
+
+	// Keep the permissions of the replaced binary
+	stat, err := os.Stat(executable)
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(binaryPath, stat.Mode().Perm()|0111); err != nil {
+		return err
+	}
+	if err := os.Rename(binaryPath, executable); err != nil {
+		return fmt.Errorf("could not replace %s: %w", executable, err)
+	}
+
+	slog.Info("Updated aarg", "from", current, "to", latest, "path", executable, log.Success())
+	return nil
+}
+
+// selfUpdateKeyPaths returns the keys the checksums signature must verify against
+// Without given keys the embedded release keys are written to dir, none are returned only if insecure
+func selfUpdateKeyPaths(dir string, opts SelfUpdateOptions) ([]string, error) {
+	if len(opts.KeyPaths) > 0 {
+		return opts.KeyPaths, nil
+	}
+	if opts.Insecure {
+		return nil, nil
+	}
+	if !bytes.Contains(selfUpdateKeys, []byte("-----BEGIN PGP PUBLIC KEY BLOCK-----")) {
+		return nil, fmt.Errorf("%w: this build embeds no release signing key, use --key or --insecure", ErrSelfUpdateUnverified)
+	}
+	keyPath := filepath.Join(dir, "release-keys.asc")
+	if err := os.WriteFile(keyPath, selfUpdateKeys, 0644); err != nil {
+		return nil, err
+	}
+	return []string{keyPath}, nil
+}
+
+// selfUpdateChecksum returns the SHA256 checksum the binary asset must match
+// The release checksums are preferred, their signature is verified if keys are given
+// Without keys (--insecure) unsigned checksums or GitHub's digest alone are accepted
+func selfUpdateChecksum(ctx context.Context, dir string, assets map[string]*github.ReleaseAsset, binary *github.ReleaseAsset, keyPaths []string) (string, error) {
+	_, digest := feed.ParseGitHubDigest(binary.GetDigest())
+
+	checksumsAsset, ok := assets[selfUpdateChecksums]
+	if !ok {
+		if len(keyPaths) > 0 {
+			return "", fmt.Errorf("%w: release has no %s to verify the signature of", ErrSelfUpdateUnverified, selfUpdateChecksums)
+		}
+		if digest == "" {
+			return "", fmt.Errorf("%w: release has neither %s nor a digest", ErrSelfUpdateUnverified, selfUpdateChecksums)
+		}
+		slog.Warn("Release has no checksums, verifying against GitHub digest only", "asset", binary.GetName())
+		return digest, nil
+	}
+
+	_, checksumsDigest := feed.ParseGitHubDigest(checksumsAsset.GetDigest())
+	checksumsPath := filepath.Join(dir, selfUpdateChecksums)
+	if err := selfUpdateDownload(ctx, checksumsAsset.GetBrowserDownloadURL(), checksumsPath, checksumsDigest); err != nil {
+		return "", err
+	}
+
+	var signaturePath string
+	if len(keyPaths) > 0 {
+		signatureAsset, ok := assets[selfUpdateChecksums+".asc"]
+		if !ok {
+			return "", fmt.Errorf("%w: release has no %s.asc", ErrSelfUpdateUnverified, selfUpdateChecksums)
+		}
+		signaturePath = checksumsPath + ".asc"
+		if err := selfUpdateDownload(ctx, signatureAsset.GetBrowserDownloadURL(), signaturePath, ""); err != nil {
+			return "", err
+		}
+	}
+
+	verifier, cleanup, err := selfUpdateVerifier(keyPaths)
+	if err != nil {
+		return "", err
+	}
+	defer cleanup()
+
+	sums, signed, err := debext.ParseChecksumsFile(checksumsPath, signaturePath, verifier)
+	if err != nil {
+		return "", err
+	}
+	if !signed {
+		slog.Warn("Release checksums are not verified by a signature (--insecure)", "asset", binary.GetName())
+	}
+
+	checksum, ok := sums[binary.GetName()]
+	if !ok {
+		return "", fmt.Errorf("%w: %s is not listed in %s", ErrSelfUpdateUnverified, binary.GetName(), selfUpdateChecksums)
+	}
+	if digest != "" && digest != checksum {
+		return "", fmt.Errorf("%w: %s checksum differs from GitHub digest", debext.ErrChecksumMismatch, binary.GetName())
+	}
+	return checksum, nil
+}
+
+// selfUpdateVerifier creates a verifier for the checksums signature, unsigned checksums are only accepted without keys
+func selfUpdateVerifier(keyPaths []string) (*debext.Verifier, func(), error) {
+	verifier := &pgp.GoVerifier{}
+	var cleanups []func()
+	cleanup := func() {
+		for _, fn := range cleanups {
+			fn()
+		}
+	}
+
+	for _, keyPath := range keyPaths {
+		keyFile, keyCleanup, err := prepareKeyFile(keyPath)
+		if err != nil {
+			cleanup()
+			return nil, nil, err
+		}
+		cleanups = append(cleanups, keyCleanup)
+		verifier.AddKeyring(keyFile)
+	}
+
+	// Without keys the default keyring is not loaded, nothing is verified against it
+	if len(keyPaths) > 0 {
+		if err := verifier.InitKeyring(false); err != nil {
+			cleanup()
+			return nil, nil, err
+		}
+	}
+
+	return &debext.Verifier{
+		Verifier:         verifier,
+		AcceptUnsigned:   len(keyPaths) == 0,
+		IgnoreSignatures: len(keyPaths) == 0,
+	}, cleanup, nil
+}
+
+// selfUpdateDownload downloads url to path and verifies its SHA256 checksum if given
+func selfUpdateDownload(ctx context.Context, url, path, checksum string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("download %s: %s", url, resp.Status)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	hasher := sha256.New()
+	n, err := io.Copy(io.MultiWriter(file, hasher), resp.Body)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("download %s: %w", url, err)
+	}
+	log.CountDownloaded(n)
+
+	if checksum != "" && !strings.EqualFold(hex.EncodeToString(hasher.Sum(nil)), checksum) {
+		return fmt.Errorf("%w: %s", debext.ErrChecksumMismatch, filepath.Base(path))
+	}
+	return nil
+}
@@ -0,0 +1,226 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/dionysius/aarg/debext"
+	"github.com/google/go-github/v80/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newReleaseKey generates a release signing key and writes its armored public key to dir
+func newReleaseKey(t *testing.T, dir, name string) (*openpgp.Entity, string) {
+	t.Helper()
+	entity, err := openpgp.NewEntity(name, "", name+"@example.com", nil)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, debext.WriteArmored(&buf, openpgp.PublicKeyType, entity.Serialize))
+	path := filepath.Join(dir, name+".asc")
+	require.NoError(t, os.WriteFile(path, buf.Bytes(), 0644))
+	return entity, path
+}
+
+// detachSign returns the armored detached signature of data
+func detachSign(t *testing.T, signer *openpgp.Entity, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	require.NoError(t, openpgp.ArmoredDetachSign(&buf, signer, bytes.NewReader(data), nil))
+	return buf.Bytes()
+}
+
+// sha256Hex returns the hex encoded SHA256 of data
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// serveReleaseAssets serves files like the download URLs of GitHub release assets
+// The returned assets carry the GitHub digest of their content unless noDigest
+func serveReleaseAssets(t *testing.T, files map[string][]byte, noDigest bool) map[string]*github.ReleaseAsset {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, ok := files[r.URL.Path[1:]]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write(data)
+	}))
+	t.Cleanup(server.Close)
+
+	assets := make(map[string]*github.ReleaseAsset, len(files))
+	for name, data := range files {
+		asset := &github.ReleaseAsset{
+			Name:               github.Ptr(name),
+			BrowserDownloadURL: github.Ptr(server.URL + "/" + name),
+		}
+		if !noDigest {
+			asset.Digest = github.Ptr("sha256:" + sha256Hex(data))
+		}
+		assets[name] = asset
+	}
+	return assets
+}
+
+func TestSelfUpdateChecksum(t *testing.T) {
+	keyDir := t.TempDir()
+	release, releaseKey := newReleaseKey(t, keyDir, "release")
+	other, _ := newReleaseKey(t, keyDir, "other")
+
+	binary := []byte("new aarg binary")
+	const name = "aarg-linux-amd64"
+	sums := []byte(fmt.Sprintf("%s  %s\n%s  aarg-darwin-arm64\n", sha256Hex(binary), name, sha256Hex([]byte("other"))))
+	otherSums := []byte(sha256Hex([]byte("other")) + "  aarg-darwin-arm64\n")
+
+	tests := []struct {
+		name     string
+		files    map[string][]byte
+		noDigest bool
+		keys     []string
+		// tamper changes the assets after their digests were taken
+		tamper  func(assets map[string]*github.ReleaseAsset)
+		want    string
+		wantErr error
+	}{
+		{
+			name:  "signed checksums",
+			files: map[string][]byte{name: binary, "SHA256SUMS": sums, "SHA256SUMS.asc": detachSign(t, release, sums)},
+			keys:  []string{releaseKey},
+			want:  sha256Hex(binary),
+		},
+		{
+			name:    "signed by another key",
+			files:   map[string][]byte{name: binary, "SHA256SUMS": sums, "SHA256SUMS.asc": detachSign(t, other, sums)},
+			keys:    []string{releaseKey},
+			wantErr: debext.ErrSignatureVerificationFailed,
+		},
+		{
+			name:    "missing signature",
+			files:   map[string][]byte{name: binary, "SHA256SUMS": sums},
+			keys:    []string{releaseKey},
+			wantErr: ErrSelfUpdateUnverified,
+		},
+		{
+			name:    "missing checksums",
+			files:   map[string][]byte{name: binary},
+			keys:    []string{releaseKey},
+			wantErr: ErrSelfUpdateUnverified,
+		},
+		{
+			name:    "binary not listed",
+			files:   map[string][]byte{name: binary, "SHA256SUMS": otherSums, "SHA256SUMS.asc": detachSign(t, release, otherSums)},
+			keys:    []string{releaseKey},
+			wantErr: ErrSelfUpdateUnverified,
+		},
+		{
+			name:  "checksums differ from GitHub digest",
+			files: map[string][]byte{name: binary, "SHA256SUMS": sums, "SHA256SUMS.asc": detachSign(t, release, sums)},
+			keys:  []string{releaseKey},
+			tamper: func(assets map[string]*github.ReleaseAsset) {
+				assets[name].Digest = github.Ptr("sha256:" + sha256Hex([]byte("replaced binary")))
+			},
+			wantErr: debext.ErrChecksumMismatch,
+		},
+		{
+			name:  "checksums download differs from its digest",
+			files: map[string][]byte{name: binary, "SHA256SUMS": sums, "SHA256SUMS.asc": detachSign(t, release, sums)},
+			keys:  []string{releaseKey},
+			tamper: func(assets map[string]*github.ReleaseAsset) {
+				assets["SHA256SUMS"].Digest = github.Ptr("sha256:" + sha256Hex([]byte("other checksums")))
+			},
+			wantErr: debext.ErrChecksumMismatch,
+		},
+		{
+			name:  "insecure unsigned checksums",
+			files: map[string][]byte{name: binary, "SHA256SUMS": sums},
+			want:  sha256Hex(binary),
+		},
+		{
+			name:  "insecure GitHub digest only",
+			files: map[string][]byte{name: binary},
+			want:  sha256Hex(binary),
+		},
+		{
+			name:     "insecure without checksums and digest",
+			files:    map[string][]byte{name: binary},
+			noDigest: true,
+			wantErr:  ErrSelfUpdateUnverified,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assets := serveReleaseAssets(t, tt.files, tt.noDigest)
+			if tt.tamper != nil {
+				tt.tamper(assets)
+			}
+			checksum, err := selfUpdateChecksum(context.Background(), t.TempDir(), assets, assets[name], tt.keys)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, checksum)
+		})
+	}
+}
+
+func TestSelfUpdateDownload(t *testing.T) {
+	binary := []byte("new aarg binary")
+	assets := serveReleaseAssets(t, map[string][]byte{"aarg-linux-amd64": binary}, false)
+	url := assets["aarg-linux-amd64"].GetBrowserDownloadURL()
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "matching")
+	require.NoError(t, selfUpdateDownload(context.Background(), url, path, sha256Hex(binary)))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, binary, data)
+
+	err = selfUpdateDownload(context.Background(), url, filepath.Join(dir, "mismatch"), sha256Hex([]byte("other")))
+	assert.ErrorIs(t, err, debext.ErrChecksumMismatch)
+
+	err = selfUpdateDownload(context.Background(), url+"-missing", filepath.Join(dir, "missing"), "")
+	assert.Error(t, err)
+}
+
+func TestSelfUpdateKeyPaths(t *testing.T) {
+	dir := t.TempDir()
+
+	keys, err := selfUpdateKeyPaths(dir, SelfUpdateOptions{KeyPaths: []string{"release.asc"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"release.asc"}, keys)
+
+	keys, err = selfUpdateKeyPaths(dir, SelfUpdateOptions{Insecure: true})
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+
+	// The embedded key file holds no key block until releases are signed
+	_, err = selfUpdateKeyPaths(dir, SelfUpdateOptions{})
+	assert.ErrorIs(t, err, ErrSelfUpdateUnverified)
+
+	embedded := selfUpdateKeys
+	t.Cleanup(func() { selfUpdateKeys = embedded })
+	_, releaseKey := newReleaseKey(t, dir, "release")
+	selfUpdateKeys, err = os.ReadFile(releaseKey)
+	require.NoError(t, err)
+
+	keys, err = selfUpdateKeyPaths(dir, SelfUpdateOptions{})
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	data, err := os.ReadFile(keys[0])
+	require.NoError(t, err)
+	assert.Equal(t, selfUpdateKeys, data)
+}
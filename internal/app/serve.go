@@ -1,18 +1,42 @@
 package app
 
 import (
+	"bytes"
+	"cmp"
 	"context"
+	"crypto/subtle"
+	"encoding/json"
 	"fmt"
+	"io"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/dionysius/aarg/internal/common"
+	"github.com/dionysius/aarg/internal/compose"
+	"github.com/dionysius/aarg/internal/config"
+	"github.com/dionysius/aarg/internal/provider"
 	"github.com/fsnotify/fsnotify"
 )
 
+// Download statistics of serve mode
+const (
+	accessStatsFile     = "access-stats.json" // Daily download counts in the cache directory
+	accessStatsDays     = 30                  // Days kept and shown on the statistics page
+	accessStatsTop      = 50                  // Packages shown on the statistics page
+	accessStatsInterval = 5 * time.Minute     // How often the counts are saved while serving
+)
+
+// indexContentType is the content type of uncompressed APT index files
+const indexContentType = "text/plain; charset=utf-8"
+
 // Serve starts an HTTP server to serve the public directory
 func (a *Application) Serve(ctx context.Context) error {
 	// Get host and port from config with defaults
@@ -67,24 +91,82 @@ func (a *Application) Serve(ctx context.Context) error {
 	}
 	currentTarget = target
 
+	// Count package downloads if enabled, only daily counts are kept
+	var stats *common.AccessStats
+	statsPath := filepath.Join(a.Config.Directories.GetCachePath(), accessStatsFile)
+	if a.Config.Serve.Stats {
+		stats, err = common.LoadAccessStats(statsPath, accessStatsDays)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Pool files of pool mode redirect only exist upstream, resolve them like the published build
+	var redirects []provider.RedirectRule
+	if a.Config.Generate.PoolMode == "redirect" {
+		redirects = provider.RedirectRules(a.Config.Repositories)
+		slog.Info("Emulating pool redirects", "rules", len(redirects), "mode", cmp.Or(a.Config.Serve.Redirects, config.ServeRedirectsRedirect))
+	}
+
 	// Dynamic handler that resolves symlink on each request
 	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		mu.RLock()
 		target := currentTarget
 		mu.RUnlock()
 
+		// Report the current age of the build, the generated file only knows when it was built
+		if r.URL.Path == "/"+compose.HealthFile && serveHealth(w, r, target) {
+			return
+		}
+
+		if stats != nil {
+			if r.URL.Path == strings.TrimSuffix(compose.StatsPath, "/") || r.URL.Path == compose.StatsPath {
+				serveStats(w, r, stats)
+				return
+			}
+			if pkg, ok := accessedPackage(r); ok {
+				recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+				defer func() {
+					if recorder.status == http.StatusOK {
+						stats.Record(pkg, clientAddress(r), r.UserAgent(), time.Now())
+					}
+				}()
+				w = recorder
+			}
+		}
+
+		if serveRedirect(w, r, redirects, a.Config.Serve.Redirects, a.HTTPClient) {
+			return
+		}
+
+		// Prefer precompressed siblings of generated files if the client accepts them
+		if servePrecompressed(w, r, target) {
+			return
+		}
+
+		// Conditional requests are answered with the ETag, Last-Modified and Range are handled by the file server
+		name := filepath.Join(target, filepath.FromSlash(path.Clean("/"+r.URL.Path)))
+		if info, err := os.Stat(name); err == nil && info.Mode().IsRegular() {
+			setETag(w, info)
+		}
+
 		// Serve from the resolved target directory
 		fs := http.FileServer(http.Dir(target))
 		fs.ServeHTTP(w, r)
 	})
 
 	mux := http.NewServeMux()
-	mux.Handle("/", handler)
+	mux.Handle("/", withBasicAuth(handler, a.Config.Serve.Auth))
+
+	var root http.Handler = mux
+	if a.Config.Serve.AccessLog {
+		root = withAccessLog(root)
+	}
 
 	// Create server with configured address and handler
 	server := &http.Server{
 		Addr:    addr,
-		Handler: mux,
+		Handler: root,
 	}
 
 	// Channel to capture server errors
@@ -151,6 +233,33 @@ func (a *Application) Serve(ctx context.Context) error {
 		}
 	}()
 
+	// Start incremental ingestion of externally populated downloads
+	if a.Config.Serve.Ingest {
+		go func() {
+			if err := a.watchIngest(ctx); err != nil {
+				slog.Error("Ingest watcher stopped", "error", err)
+			}
+		}()
+	}
+
+	// Save the download counts periodically, so a crash loses little
+	if stats != nil {
+		go func() {
+			ticker := time.NewTicker(accessStatsInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					if err := stats.Save(statsPath); err != nil {
+						slog.Warn("Failed to save download statistics", "error", err)
+					}
+				}
+			}
+		}()
+	}
+
 	// Start server in goroutine
 	go func() {
 		slog.Info("Server is ready", "url", fmt.Sprintf("http://%s", addr))
@@ -177,8 +286,288 @@ func (a *Application) Serve(ctx context.Context) error {
 		if err := server.Shutdown(shutdownCtx); err != nil {
 			return fmt.Errorf("server shutdown error: %w", err)
 		}
+		if stats != nil {
+			if err := stats.Save(statsPath); err != nil {
+				slog.Warn("Failed to save download statistics", "error", err)
+			}
+		}
 		slog.Info("Server stopped gracefully")
 	}
 
 	return nil
 }
+
+// serveHealth serves healthz.json with the age of the build and whether it's stale at the time of the request
+// Returns false if the health file can't be read, it's then served as is
+func serveHealth(w http.ResponseWriter, r *http.Request, root string) bool {
+	data, err := os.ReadFile(filepath.Join(root, compose.HealthFile))
+	if err != nil {
+		return false
+	}
+	var health compose.Health
+	if err := json.Unmarshal(data, &health); err != nil {
+		return false
+	}
+
+	data, err = json.MarshalIndent(health.At(time.Now()), "", "  ")
+	if err != nil {
+		return false
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if r.Method != http.MethodHead {
+		_, _ = w.Write(data)
+	}
+	return true
+}
+
+// serveStats renders the download statistics page
+func serveStats(w http.ResponseWriter, r *http.Request, stats *common.AccessStats) {
+	if r.URL.Path != compose.StatsPath {
+		http.Redirect(w, r, compose.StatsPath, http.StatusMovedPermanently)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := compose.RenderStats(&buf, stats.Top(accessStatsDays, accessStatsTop, time.Now()), accessStatsDays); err != nil {
+		slog.Error("Failed to render download statistics", "error", err)
+		http.Error(w, "failed to render download statistics", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	if r.Method != http.MethodHead {
+		_, _ = w.Write(buf.Bytes())
+	}
+}
+
+// accessedPackage returns the package a request downloads as repository/name
+// Only full downloads of binary packages are counted, index and source files are not of interest
+func accessedPackage(r *http.Request) (string, bool) {
+	if r.Method != http.MethodGet || r.Header.Get("Range") != "" {
+		return "", false
+	}
+
+	name := path.Clean("/" + r.URL.Path)
+	switch path.Ext(name) {
+	case ".deb", ".udeb", ".ddeb":
+	default:
+		return "", false
+	}
+
+	repo, _, found := strings.Cut(strings.TrimPrefix(name, "/"), "/")
+	if !found {
+		return "", false
+	}
+	pkg, _, _ := strings.Cut(path.Base(name), "_")
+	return repo + "/" + pkg, true
+}
+
+// clientAddress returns the address of the client without port
+func clientAddress(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// statusRecorder remembers the status code and size written to a response
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+// WriteHeader records the status code and writes it to the response
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Write counts the written bytes and writes them to the response
+func (r *statusRecorder) Write(p []byte) (int, error) {
+	n, err := r.ResponseWriter.Write(p)
+	r.bytes += int64(n)
+	return n, err
+}
+
+// withBasicAuth requires the configured credentials for every request but the health file
+// Uptime monitors keep checking the build without credentials
+func withBasicAuth(next http.Handler, auth config.ServeAuthConfig) http.Handler {
+	if auth.Username == "" {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/"+compose.HealthFile {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		username, password, ok := r.BasicAuth()
+		if !ok || subtle.ConstantTimeCompare([]byte(username), []byte(auth.Username)) != 1 ||
+			subtle.ConstantTimeCompare([]byte(password), []byte(auth.Password)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="aarg", charset="UTF-8"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// withAccessLog logs every request with its status, response size and duration
+func withAccessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+
+		slog.Info("Request",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", recorder.status,
+			"bytes", recorder.bytes,
+			"duration", time.Since(start),
+			"client", clientAddress(r),
+			"user_agent", r.UserAgent(),
+		)
+	})
+}
+
+// serveRedirect answers requests matching a pool redirect rule with a redirect to its target or proxies it
+// Upstream files are proxied with client, returns false if no rule matches
+func serveRedirect(w http.ResponseWriter, r *http.Request, rules []provider.RedirectRule, mode string, client *http.Client) bool {
+	for _, rule := range rules {
+		target, ok := rule.Resolve(r.URL.Path)
+		if !ok {
+			continue
+		}
+
+		// Relative targets like the corrected .dsc files are part of the build
+		if mode == config.ServeRedirectsProxy && !strings.HasPrefix(target, "/") {
+			proxyRedirect(w, r, client, target)
+			return true
+		}
+		http.Redirect(w, r, target, rule.Status)
+		return true
+	}
+	return false
+}
+
+// proxyRedirect downloads the target of a pool redirect and passes it through
+// Range and conditional requests are forwarded, so apt can resume downloads
+func proxyRedirect(w http.ResponseWriter, r *http.Request, client *http.Client, target string) {
+	req, err := http.NewRequestWithContext(r.Context(), r.Method, target, nil)
+	if err != nil {
+		http.Error(w, "invalid redirect target", http.StatusBadGateway)
+		return
+	}
+	for _, header := range []string{"Range", "If-Range", "If-None-Match", "If-Modified-Since"} {
+		if value := r.Header.Get(header); value != "" {
+			req.Header.Set(header, value)
+		}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		slog.Warn("Failed to proxy pool file", "url", target, "error", err)
+		http.Error(w, "failed to fetch upstream file", http.StatusBadGateway)
+		return
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	for _, header := range []string{"Content-Type", "Content-Length", "Content-Range", "Accept-Ranges", "ETag", "Last-Modified"} {
+		if value := resp.Header.Get(header); value != "" {
+			w.Header().Set(header, value)
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+	_, _ = io.Copy(w, resp.Body)
+}
+
+// setETag sets an ETag derived from the modification time and size of the served file
+// http.ServeContent answers If-None-Match and If-Range with it
+func setETag(w http.ResponseWriter, info os.FileInfo) {
+	w.Header().Set("ETag", fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size()))
+}
+
+// isIndexFile reports whether a path is an uncompressed APT index file, these have gzipped siblings
+func isIndexFile(name string) bool {
+	if !strings.Contains(name, "/dists/") {
+		return false
+	}
+	base := path.Base(name)
+	return base == "Packages" || base == "Sources" || strings.HasPrefix(base, "Translation-")
+}
+
+// servePrecompressed serves the precompressed sibling of the requested file in the encoding preferred by the client
+// Returns false if the request is not answered, e.g. without siblings or accepted encodings
+func servePrecompressed(w http.ResponseWriter, r *http.Request, root string) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+
+	name := path.Clean("/" + r.URL.Path)
+	if strings.HasSuffix(r.URL.Path, "/") {
+		name = path.Join(name, "index.html")
+	}
+	contentType := compose.PrecompressedType(name)
+	index := contentType == "" && isIndexFile(name)
+	if index {
+		contentType = indexContentType
+	}
+	if contentType == "" {
+		return false
+	}
+
+	for _, encoding := range compose.Encodings {
+		// Index files are only published gzipped besides xz and bzip2, which browsers don't decode
+		if index && encoding.ContentEncoding != "gzip" {
+			continue
+		}
+		if !acceptsEncoding(r.Header.Get("Accept-Encoding"), encoding.ContentEncoding) {
+			continue
+		}
+
+		f, err := http.Dir(root).Open(name + encoding.Extension)
+		if err != nil {
+			continue
+		}
+		info, err := f.Stat()
+		if err != nil || info.IsDir() {
+			_ = f.Close()
+			continue
+		}
+
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Encoding", encoding.ContentEncoding)
+		w.Header().Add("Vary", "Accept-Encoding")
+		setETag(w, info)
+		http.ServeContent(w, r, name, info.ModTime(), f)
+		_ = f.Close()
+		return true
+	}
+
+	return false
+}
+
+// acceptsEncoding reports whether an Accept-Encoding header accepts the content encoding
+func acceptsEncoding(header, encoding string) bool {
+	for part := range strings.SplitSeq(header, ",") {
+		token, params, _ := strings.Cut(part, ";")
+		if !strings.EqualFold(strings.TrimSpace(token), encoding) {
+			continue
+		}
+		// An encoding listed with q=0 is explicitly refused
+		if q, found := strings.CutPrefix(strings.TrimSpace(params), "q="); found {
+			if value, err := strconv.ParseFloat(q, 64); err == nil && value == 0 {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
@@ -0,0 +1,158 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aptly-dev/aptly/utils"
+	"github.com/dionysius/aarg/internal/config"
+	"github.com/dionysius/aarg/internal/feed"
+	"github.com/dionysius/aarg/internal/log"
+	"github.com/dionysius/aarg/internal/telemetry"
+	"github.com/google/go-github/v80/github"
+)
+
+// releaseUpload is a file of the public build uploaded as release asset
+type releaseUpload struct {
+	path string // Absolute path in the public build
+	name string // Asset name in the release
+}
+
+// Upload uploads install.sh, signing keys and sources files of the production build
+// as assets to the GitHub release configured per repository
+// Repositories without upload configuration are skipped
+func (a *Application) Upload(ctx context.Context, repoNames []string) error {
+	publicDir := a.Config.Directories.GetPublicPath()
+	if _, err := os.Stat(publicDir); err != nil {
+		return fmt.Errorf("no public build available, run generate first: %w", err)
+	}
+
+	for _, name := range repoNames {
+		var repo *config.RepositoryConfig
+		for _, r := range a.Config.Repositories {
+			if r.Name == name {
+				repo = r
+				break
+			}
+		}
+		if repo == nil {
+			return fmt.Errorf("repository not found: %s", name)
+		}
+		if !repo.Upload.IsEnabled() {
+			continue
+		}
+		log.CountRepositories(repo.Name)
+
+		if err := a.uploadRepository(ctx, repo, publicDir); err != nil {
+			return fmt.Errorf("failed to upload assets for %s: %w", repo.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// uploadRepository uploads the install files of a repository to its release
+func (a *Application) uploadRepository(ctx context.Context, repo *config.RepositoryConfig, publicDir string) (err error) {
+	ctx, span := telemetry.Start(ctx, "upload.repository", telemetry.RepositoryKey.String(repo.Name))
+	defer func() { telemetry.End(span, err) }()
+
+	owner, ghRepo, _ := strings.Cut(repo.Upload.GitHub, "/")
+
+	uploads, err := collectReleaseUploads(repo.Name, publicDir)
+	if err != nil {
+		return err
+	}
+
+	release, _, err := a.GitHubClient.Repositories.GetReleaseByTag(ctx, owner, ghRepo, repo.Upload.Tag)
+	if err != nil {
+		return fmt.Errorf("failed to get release %s of %s: %w", repo.Upload.Tag, repo.Upload.GitHub, err)
+	}
+
+	existing := make(map[string]*github.ReleaseAsset, len(release.Assets))
+	for _, asset := range release.Assets {
+		existing[asset.GetName()] = asset
+	}
+
+	var uploaded int
+	for _, upload := range uploads {
+		checksums, err := utils.ChecksumsForFile(upload.path)
+		if err != nil {
+			return err
+		}
+
+		// Assets cannot be overwritten, replace changed ones and keep unchanged ones
+		if asset, ok := existing[upload.name]; ok {
+			if _, digest := feed.ParseGitHubDigest(asset.GetDigest()); strings.EqualFold(digest, checksums.SHA256) {
+				slog.Debug("Release asset unchanged", "repository", repo.Name, "asset", upload.name)
+				continue
+			}
+			if _, err := a.GitHubClient.Repositories.DeleteReleaseAsset(ctx, owner, ghRepo, asset.GetID()); err != nil {
+				return fmt.Errorf("failed to delete outdated asset %s: %w", upload.name, err)
+			}
+		}
+
+		if err := a.uploadReleaseAsset(ctx, owner, ghRepo, release.GetID(), upload); err != nil {
+			return err
+		}
+		log.CountUploaded(checksums.Size)
+		uploaded++
+	}
+
+	slog.Info("Release assets uploaded", "repository", repo.Name, "release", repo.Upload.GitHub+"@"+repo.Upload.Tag,
+		"uploaded", uploaded, "unchanged", len(uploads)-uploaded, log.Success())
+
+	return nil
+}
+
+// uploadReleaseAsset uploads a single file as release asset
+func (a *Application) uploadReleaseAsset(ctx context.Context, owner, repo string, releaseID int64, upload releaseUpload) error {
+	f, err := os.Open(upload.path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+
+	if _, _, err := a.GitHubClient.Repositories.UploadReleaseAsset(ctx, owner, repo, releaseID,
+		&github.UploadOptions{Name: upload.name}, f); err != nil {
+		return fmt.Errorf("failed to upload asset %s: %w", upload.name, err)
+	}
+
+	return nil
+}
+
+// collectReleaseUploads returns the files of a repository to upload with their asset names
+// Asset names are prefixed with the repository name so multiple repositories can share a release
+func collectReleaseUploads(repoName, publicDir string) ([]releaseUpload, error) {
+	repoDir := filepath.Join(publicDir, repoName)
+
+	uploads := []releaseUpload{
+		{path: filepath.Join(repoDir, "install.sh"), name: repoName + "-install.sh"},
+	}
+
+	// Signing keys are shared by all repositories
+	for _, key := range []string{"signing-key.asc", "signing-key.gpg"} {
+		keyPath := filepath.Join(publicDir, "keys", key)
+		if _, err := os.Stat(keyPath); err == nil {
+			uploads = append(uploads, releaseUpload{path: keyPath, name: key})
+		}
+	}
+
+	sources, err := filepath.Glob(filepath.Join(repoDir, "sources", "*.sources"))
+	if err != nil {
+		return nil, err
+	}
+	for _, sourcesPath := range sources {
+		uploads = append(uploads, releaseUpload{path: sourcesPath, name: repoName + "-" + filepath.Base(sourcesPath)})
+	}
+
+	// install.sh is only generated by the web composer
+	if _, err := os.Stat(uploads[0].path); err != nil {
+		return nil, fmt.Errorf("install.sh not found, is the web composer enabled: %w", err)
+	}
+
+	return uploads, nil
+}
@@ -0,0 +1,112 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/dionysius/aarg/internal/log"
+)
+
+// verifyAttempts is the number of tries per file, deployments may need a moment to propagate
+const verifyAttempts = 5
+
+// verifyDeployment checks that the signed release files of all distributions in buildDir
+// are served unchanged at baseURL
+func (a *Application) verifyDeployment(ctx context.Context, baseURL, buildDir string) error {
+	resolvedDir, err := filepath.EvalSymlinks(buildDir)
+	if err != nil {
+		return fmt.Errorf("failed to resolve build directory: %w", err)
+	}
+
+	// Layout is <repo>/dists/<dist>/InRelease
+	releaseFiles, err := filepath.Glob(filepath.Join(resolvedDir, "*", "dists", "*", "InRelease"))
+	if err != nil {
+		return err
+	}
+	if len(releaseFiles) == 0 {
+		return fmt.Errorf("no release files found in %s", buildDir)
+	}
+
+	slog.Info("Verifying deployment", "url", baseURL, "files", len(releaseFiles))
+
+	for _, releaseFile := range releaseFiles {
+		relPath, err := filepath.Rel(resolvedDir, releaseFile)
+		if err != nil {
+			return err
+		}
+
+		expected, err := os.ReadFile(releaseFile)
+		if err != nil {
+			return err
+		}
+
+		fileURL := strings.TrimSuffix(baseURL, "/") + "/" + filepath.ToSlash(relPath)
+		if err := a.verifyRemoteFile(ctx, fileURL, expected); err != nil {
+			return err
+		}
+
+		slog.Debug("Verified release file", "url", fileURL)
+	}
+
+	slog.Info("Deployment verified", "url", baseURL, log.Success())
+
+	return nil
+}
+
+// verifyRemoteFile fetches url and compares its content with expected, retrying on mismatch
+func (a *Application) verifyRemoteFile(ctx context.Context, url string, expected []byte) error {
+	var lastErr error
+
+	for attempt := range verifyAttempts {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Duration(attempt) * 2 * time.Second):
+			}
+		}
+
+		actual, err := a.fetchRemoteFile(ctx, url)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		if !bytes.Equal(actual, expected) {
+			lastErr = fmt.Errorf("content of %s differs from build", url)
+			continue
+		}
+
+		return nil
+	}
+
+	return lastErr
+}
+
+// fetchRemoteFile downloads url into memory
+func (a *Application) fetchRemoteFile(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := a.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status fetching %s: %s", url, resp.Status)
+	}
+
+	return io.ReadAll(resp.Body)
+}
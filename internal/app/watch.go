@@ -0,0 +1,284 @@
+package app
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dionysius/aarg/internal/config"
+	"github.com/dionysius/aarg/internal/feed"
+)
+
+// ErrWatchSecretRequired is returned when watch is started without a secret to authenticate webhooks
+var ErrWatchSecretRequired = errors.New("watch.secret or AARG_WATCH_SECRET is required to authenticate webhooks")
+
+// maxWebhookSize limits the accepted webhook payload, GitHub caps deliveries at 25 MB
+const maxWebhookSize = 25 << 20
+
+// buildQueue collects the repositories to build until the next build picks them up
+type buildQueue struct {
+	mu      sync.Mutex
+	pending map[string]bool
+	wake    chan struct{}
+}
+
+func newBuildQueue() *buildQueue {
+	return &buildQueue{pending: make(map[string]bool), wake: make(chan struct{}, 1)}
+}
+
+// add queues repositories and wakes up the build loop
+func (q *buildQueue) add(names ...string) {
+	q.mu.Lock()
+	for _, name := range names {
+		q.pending[name] = true
+	}
+	q.mu.Unlock()
+
+	select {
+	case q.wake <- struct{}{}:
+	default: // Already woken up
+	}
+}
+
+// take returns the queued repositories sorted by name and empties the queue
+func (q *buildQueue) take() []string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	names := slices.Sorted(maps.Keys(q.pending))
+	clear(q.pending)
+	return names
+}
+
+// Watch listens for webhooks and builds only the affected repositories: fetch, generate and publish
+// GitHub release webhooks are accepted on /github, any other system can trigger a repository with
+// POST /trigger/{repository}. Builds run one at a time, webhooks arriving meanwhile are queued.
+func (a *Application) Watch(ctx context.Context, opts BuildOptions) error {
+	secret := a.Config.Watch.Secret
+	if secret == "" {
+		return ErrWatchSecretRequired
+	}
+
+	host := a.Config.Watch.Host
+	if host == "" {
+		host = "localhost"
+	}
+
+	port := a.Config.Watch.Port
+	if port == 0 {
+		port = 8090
+	}
+
+	debounce := time.Duration(a.Config.Watch.DebounceSeconds) * time.Second
+	if a.Config.Watch.DebounceSeconds == 0 {
+		debounce = 30 * time.Second
+	}
+
+	queue := newBuildQueue()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /github", func(w http.ResponseWriter, r *http.Request) {
+		a.handleGitHubWebhook(w, r, secret, queue)
+	})
+	mux.HandleFunc("POST /trigger/{repository}", func(w http.ResponseWriter, r *http.Request) {
+		a.handleTrigger(w, r, secret, queue)
+	})
+
+	addr := fmt.Sprintf("%s:%d", host, port)
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	// Build loop, stopped with ctx
+	buildDone := make(chan struct{})
+	go func() {
+		defer close(buildDone)
+		a.watchBuilds(ctx, queue, debounce, opts)
+	}()
+
+	serverErr := make(chan error, 1)
+	go func() {
+		slog.Info("Watching for webhooks", "url", fmt.Sprintf("http://%s", addr), "debounce", debounce)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			serverErr <- fmt.Errorf("failed to start server: %w", err)
+		}
+		close(serverErr)
+	}()
+
+	select {
+	case err := <-serverErr:
+		if err != nil {
+			return err
+		}
+	case <-ctx.Done():
+		slog.Info("Shutting down webhook listener...")
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			return fmt.Errorf("server shutdown error: %w", err)
+		}
+
+		// A running build is cancelled with ctx
+		<-buildDone
+		slog.Info("Webhook listener stopped gracefully")
+	}
+
+	return nil
+}
+
+// watchBuilds builds the queued repositories until ctx is done
+// After the first webhook it waits for debounce, so further webhooks are built together
+// Every build writes its own report and notifies about its outcome
+func (a *Application) watchBuilds(ctx context.Context, queue *buildQueue, debounce time.Duration, opts BuildOptions) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-queue.wake:
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(debounce):
+		}
+
+		repoNames := queue.take()
+		if len(repoNames) == 0 {
+			continue
+		}
+
+		slog.Info("Building repositories triggered by webhook", "repositories", repoNames)
+		start := time.Now()
+		err := a.Build(ctx, repoNames, opts.Generate, opts.Publish, false)
+		if err != nil && ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			slog.Error("Triggered build failed", "repositories", repoNames, "error", err)
+		} else {
+			slog.Info("Triggered build complete", "repositories", repoNames, "duration", time.Since(start).Round(time.Millisecond))
+		}
+		a.ReportBuild(ctx, "watch", start, err)
+	}
+}
+
+// handleGitHubWebhook queues the repositories with a GitHub feed of the repository a release event is for
+func (a *Application) handleGitHubWebhook(w http.ResponseWriter, r *http.Request, secret string, queue *buildQueue) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookSize))
+	if err != nil {
+		http.Error(w, "failed to read payload", http.StatusBadRequest)
+		return
+	}
+
+	if !validGitHubSignature(body, r.Header.Get("X-Hub-Signature-256"), secret) {
+		slog.Warn("Rejected GitHub webhook with invalid signature", "remote", r.RemoteAddr)
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	switch event := r.Header.Get("X-GitHub-Event"); event {
+	case "ping":
+		_, _ = io.WriteString(w, "pong\n")
+		return
+	case "release":
+	default:
+		slog.Debug("Ignoring GitHub webhook", "event", event)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	var payload struct {
+		Action     string `json:"action"`
+		Repository struct {
+			FullName string `json:"full_name"`
+		} `json:"repository"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return
+	}
+
+	repoNames := a.repositoriesWithGitHubFeed(payload.Repository.FullName)
+	if len(repoNames) == 0 {
+		slog.Info("No repository uses the GitHub repository of the release", "github", payload.Repository.FullName, "action", payload.Action)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	slog.Info("Queued build for GitHub release", "github", payload.Repository.FullName, "action", payload.Action, "repositories", repoNames)
+	queue.add(repoNames...)
+	writeQueued(w, repoNames)
+}
+
+// handleTrigger queues the repository named in the path, authenticated with the secret as bearer token
+func (a *Application) handleTrigger(w http.ResponseWriter, r *http.Request, secret string, queue *buildQueue) {
+	token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(secret)) != 1 {
+		slog.Warn("Rejected trigger with invalid token", "remote", r.RemoteAddr)
+		http.Error(w, "invalid token", http.StatusUnauthorized)
+		return
+	}
+
+	name := r.PathValue("repository")
+	if !slices.ContainsFunc(a.Config.Repositories, func(repo *config.RepositoryConfig) bool { return repo.Name == name }) {
+		http.Error(w, "unknown repository", http.StatusNotFound)
+		return
+	}
+
+	slog.Info("Queued build for trigger", "repository", name)
+	queue.add(name)
+	writeQueued(w, []string{name})
+}
+
+// repositoriesWithGitHubFeed returns the repositories with a GitHub feed of the owner/repo name
+func (a *Application) repositoriesWithGitHubFeed(fullName string) []string {
+	var repoNames []string
+	for _, repo := range a.Config.Repositories {
+		if slices.ContainsFunc(repo.Feeds, func(feedOpts *feed.FeedOptions) bool {
+			return feedOpts.Type == feed.FeedTypeGitHub && strings.EqualFold(feedOpts.Name, fullName)
+		}) {
+			repoNames = append(repoNames, repo.Name)
+		}
+	}
+	return repoNames
+}
+
+// validGitHubSignature checks the HMAC-SHA256 signature GitHub sends as "sha256=<hex>"
+func validGitHubSignature(body []byte, signature, secret string) bool {
+	sum, ok := strings.CutPrefix(signature, "sha256=")
+	if !ok {
+		return false
+	}
+	expected, err := hex.DecodeString(sum)
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+// writeQueued answers a webhook with the repositories queued for the next build
+func writeQueued(w http.ResponseWriter, repoNames []string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(map[string][]string{"queued": repoNames})
+}
@@ -0,0 +1,79 @@
+package app
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidGitHubSignature(t *testing.T) {
+	body := []byte(`{"action":"published"}`)
+	sign := func(secret string, body []byte) string {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+		return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	}
+
+	tests := []struct {
+		name      string
+		body      []byte
+		signature string
+		want      bool
+	}{
+		{name: "valid", body: body, signature: sign("secret", body), want: true},
+		{name: "wrong secret", body: body, signature: sign("other", body)},
+		{name: "modified body", body: []byte(`{"action":"deleted"}`), signature: sign("secret", body)},
+		{name: "empty", body: body, signature: ""},
+		{name: "sha1 prefix", body: body, signature: "sha1=" + sign("secret", body)[len("sha256="):]},
+		{name: "missing prefix", body: body, signature: sign("secret", body)[len("sha256="):]},
+		{name: "invalid hex", body: body, signature: "sha256=zz"},
+		{name: "truncated", body: body, signature: sign("secret", body)[:20]},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, validGitHubSignature(tt.body, tt.signature, "secret"))
+		})
+	}
+}
+
+func TestBuildQueue(t *testing.T) {
+	tests := []struct {
+		name  string
+		adds  [][]string
+		want  []string
+		woken bool
+	}{
+		{name: "empty"},
+		{name: "single", adds: [][]string{{"hello"}}, want: []string{"hello"}, woken: true},
+		{name: "sorted and deduplicated", adds: [][]string{{"world", "hello"}, {"hello"}}, want: []string{"hello", "world"}, woken: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queue := newBuildQueue()
+			for _, names := range tt.adds {
+				queue.add(names...)
+			}
+
+			// Several adds wake up the build loop once
+			select {
+			case <-queue.wake:
+				assert.True(t, tt.woken)
+			default:
+				assert.False(t, tt.woken)
+			}
+			select {
+			case <-queue.wake:
+				t.Fatal("woken up twice")
+			default:
+			}
+
+			assert.Equal(t, tt.want, queue.take())
+			assert.Empty(t, queue.take(), "take empties the queue")
+		})
+	}
+}
@@ -8,7 +8,13 @@ import (
 	"github.com/spf13/cobra"
 )
 
-var allRepos bool
+var (
+	allRepos   bool
+	noPublish  bool
+	buildForce bool
+
+	buildOptions app.GenerateOptions
+)
 
 // buildCmd represents the build command
 var buildCmd = &cobra.Command{
@@ -16,18 +22,26 @@ var buildCmd = &cobra.Command{
 	Short: "Complete build: download, generate, and publish",
 	Long: `Execute the complete build pipeline: download packages, generate repositories, and publish.
 
-This is equivalent to running download, generate, and publish commands in sequence.
-It's the most common workflow for updating repositories.
+This is equivalent to running download, generate, and publish commands in sequence,
+followed by upload for repositories with release asset upload configured.
+It's the most common workflow for updating repositories. Use --no-publish to stop
+after generate, e.g. to review the build before running "aarg publish". Builds into
+--staging-dir are never published.
 
 Examples:
   aarg build vaultwarden              # Build vaultwarden repository
   aarg build example vaultwarden      # Build multiple repositories
-  aarg build --all                    # Build all repositories`,
+  aarg build --all                    # Build all repositories
+  aarg build --all --no-publish       # Build all repositories without publishing
+  aarg build --all --label rc1        # Name the staging build 20250101-120000-rc1`,
 	RunE: runBuild,
 }
 
 func init() {
 	addAllReposFlag(buildCmd, &allRepos)
+	addForceFlag(buildCmd, &buildForce)
+	addStagingFlags(buildCmd, &buildOptions)
+	buildCmd.Flags().BoolVar(&noPublish, "no-publish", false, "stop after generate without publishing")
 }
 
 func runBuild(cmd *cobra.Command, args []string) error {
@@ -57,20 +71,6 @@ func runBuild(cmd *cobra.Command, args []string) error {
 	}
 	defer application.Shutdown()
 
-	// Execute fetch phase
-	if err := application.Fetch(ctx, repoNames); err != nil {
-		return fmt.Errorf("fetch phase failed: %w", err)
-	}
-
-	// Execute generate phase
-	if err := application.Generate(ctx, repoNames); err != nil {
-		return fmt.Errorf("generate phase failed: %w", err)
-	}
-
-	// Execute publish phase
-	if err := application.Publish(ctx); err != nil {
-		return fmt.Errorf("publish phase failed: %w", err)
-	}
-
-	return nil
+	// Execute fetch, generate and publish phases
+	return application.Build(ctx, repoNames, buildOptions, !noPublish, buildForce)
 }
@@ -26,7 +26,8 @@ definitions from the configured repositories directory.
 
 Examples:
   aarg config show              # Show parsed configuration in YAML format`,
-	RunE: runConfigShow,
+	RunE:        runConfigShow,
+	Annotations: map[string]string{noSummaryAnnotation: "true"},
 }
 
 func init() {
@@ -44,12 +45,33 @@ func runConfigShow(cmd *cobra.Command, args []string) error {
 	if cfg.Signing.Passphrase != "" {
 		cfg.Signing.Passphrase = "***REDACTED***"
 	}
+	for i := range cfg.Signing.Additional {
+		if cfg.Signing.Additional[i].Passphrase != "" {
+			cfg.Signing.Additional[i].Passphrase = "***REDACTED***"
+		}
+	}
+	if cfg.Signing.Sigstore.Passphrase != "" {
+		cfg.Signing.Sigstore.Passphrase = "***REDACTED***"
+	}
 	if cfg.GitHub.Token != "" {
 		cfg.GitHub.Token = "***REDACTED***"
 	}
 	if cfg.Cloudflare.APIToken != "" {
 		cfg.Cloudflare.APIToken = "***REDACTED***"
 	}
+	if cfg.Watch.Secret != "" {
+		cfg.Watch.Secret = "***REDACTED***"
+	}
+	if cfg.Serve.Auth.Password != "" {
+		cfg.Serve.Auth.Password = "***REDACTED***"
+	}
+	// Webhook URLs usually carry their credentials
+	if cfg.Notifications.Webhook != "" {
+		cfg.Notifications.Webhook = "***REDACTED***"
+	}
+	for owner := range cfg.Notifications.Owners {
+		cfg.Notifications.Owners[owner] = "***REDACTED***"
+	}
 
 	// Format output
 	var output []byte
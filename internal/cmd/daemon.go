@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/dionysius/aarg/internal/app"
+	"github.com/dionysius/aarg/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var daemonOptions app.BuildOptions
+
+// daemonCmd represents the daemon command
+var daemonCmd = &cobra.Command{
+	Use:   "daemon",
+	Short: "Build repositories on their schedules",
+	Long: `Run a long-lived process building every repository on its own schedule.
+
+Each repository with a "schedule" cron expression in its repos.d file is fetched,
+generated and published whenever it's due, like "aarg build" would. Fast-moving
+upstreams can be refreshed hourly while stable ones are only built daily, all from
+one process with the shared worker pools. Repositories without a schedule are not built.
+
+Schedules use the 5 cron fields minute, hour, day of month, month and day of week
+in the local time zone, or a macro like @hourly, @daily or @weekly. Repositories due
+at the same time are built together, builds run one at a time. Every build writes
+its own report.json to the staging directory and sends its own notifications.
+
+Examples:
+  aarg daemon                   # Build repositories on their schedules
+  aarg daemon --no-publish      # Only fetch and generate on schedule`,
+	RunE: runDaemon,
+}
+
+func init() {
+	rootCmd.AddCommand(daemonCmd)
+	daemonCmd.Flags().BoolVar(&noPublish, "no-publish", false, "stop after generate without publishing")
+}
+
+func runDaemon(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+
+	// Load configuration
+	cfg, err := config.Load(cfgFile)
+	if err != nil {
+		return err
+	}
+
+	// Initialize application
+	application, err := app.New(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to initialize application: %w", err)
+	}
+	defer application.Shutdown()
+
+	// Execute daemon
+	daemonOptions.Publish = !noPublish
+	return application.Daemon(ctx, daemonOptions)
+}
@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/dionysius/aarg/internal/app"
+	"github.com/dionysius/aarg/internal/config"
+	"github.com/dionysius/aarg/internal/demo"
+	"github.com/dionysius/aarg/internal/log"
+	"github.com/spf13/cobra"
+)
+
+var (
+	demoPort  int
+	demoWeb   bool
+	demoServe bool
+)
+
+// demoCmd represents the demo command
+var demoCmd = &cobra.Command{
+	Use:   "demo [dir]",
+	Short: "Create and build a self-contained demo repository",
+	Long: `Create a self-contained demo installation and run the complete pipeline on it.
+
+The demo directory (default: aarg-demo) receives generated fixture packages, a
+throwaway signing key and a configuration using aarg itself as feed plugin. The
+packages are fetched from the fixtures, composed into a signed repository and
+published to the published/ symlink without network access.
+
+The configuration stays in place, so every other command can be tried on the demo
+with --config <dir>/config.yaml. Running demo again recreates fixtures and key.
+
+Examples:
+  aarg demo                    # Build the demo in ./aarg-demo
+  aarg demo /tmp/demo --serve  # Build the demo and serve it on localhost
+  aarg demo --web              # Include the web page, downloads Tailwind CSS once`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runDemo,
+}
+
+// demoPluginCmd serves the demo plugin, started by aarg itself during the demo
+var demoPluginCmd = &cobra.Command{
+	Use:    "plugin",
+	Short:  "Serve the demo feed and provider plugin on stdin/stdout",
+	Hidden: true,
+	Args:   cobra.NoArgs,
+	// Stdout carries the plugin protocol, skip the logging setup and summary of the root command
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error { return nil },
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return demo.Serve(cmd.Context(), os.Stdin, os.Stdout)
+	},
+}
+
+func init() {
+	demoCmd.Flags().IntVar(&demoPort, "port", 8080, "port of the local server and the repository URL")
+	demoCmd.Flags().BoolVar(&demoWeb, "web", false, "compose the web page, downloads Tailwind CSS on first use")
+	demoCmd.Flags().BoolVar(&demoServe, "serve", false, "serve the demo after building it")
+	demoCmd.AddCommand(demoPluginCmd)
+}
+
+func runDemo(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+
+	dir := "aarg-demo"
+	if len(args) > 0 {
+		dir = args[0]
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	configPath, err := demo.Setup(demo.Options{
+		Dir:        dir,
+		Executable: executable,
+		PluginArgs: []string{"demo", "plugin"},
+		Port:       demoPort,
+		Web:        demoWeb,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create demo: %w", err)
+	}
+	slog.Info("Demo created", "config", configPath)
+
+	// Load configuration
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		return err
+	}
+
+	// Select repositories
+	repoNames, err := selectRepositories(cfg, nil, true)
+	if err != nil {
+		return err
+	}
+
+	// Initialize application
+	application, err := app.New(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to initialize application: %w", err)
+	}
+	defer application.Shutdown()
+
+	if err := application.Fetch(ctx, repoNames); err != nil {
+		return fmt.Errorf("fetch phase failed: %w", err)
+	}
+	if err := application.Generate(ctx, repoNames, app.GenerateOptions{}); err != nil {
+		return fmt.Errorf("generate phase failed: %w", err)
+	}
+	if err := application.Publish(ctx, "", "", false); err != nil {
+		return fmt.Errorf("publish phase failed: %w", err)
+	}
+
+	slog.Info("Demo built", "published", filepath.Join(filepath.Dir(configPath), demo.PublishedDir), "config", configPath, log.Success())
+
+	if demoServe {
+		return application.Serve(ctx)
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dionysius/aarg/internal/app"
+	"github.com/dionysius/aarg/internal/config"
+	"github.com/spf13/cobra"
+)
+
+// diffCmd represents the diff command
+var diffCmd = &cobra.Command{
+	Use:   "diff [from] [to]",
+	Short: "Show package and index changes between two builds",
+	Long: `Compare two builds and report added, removed and changed packages and index files
+below dists/, e.g. to validate what a publish or promote actually changes.
+
+Builds are staging build names (YYYYMMDD-HHMMSS with optional -label) or "public" for
+the build the public directory points to. Without arguments the public build is compared
+with the newest staging build, with a single argument the public build with that build.
+Nothing is modified.
+
+Examples:
+  aarg diff                                  # Public build vs newest staging build
+  aarg diff 20250101-120000                  # Public build vs a staging build
+  aarg diff 20250101-120000 20250102-120000  # Two staging builds`,
+	Args: cobra.MaximumNArgs(2),
+	RunE: runDiff,
+}
+
+func runDiff(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+
+	// Load configuration
+	cfg, err := config.Load(cfgFile)
+	if err != nil {
+		return err
+	}
+
+	// Initialize application
+	application, err := app.New(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to initialize application: %w", err)
+	}
+	defer application.Shutdown()
+
+	// A single build is compared with the public build
+	var from, to string
+	switch len(args) {
+	case 1:
+		to = args[0]
+	case 2:
+		from, to = args[0], args[1]
+	}
+
+	diff, err := application.DiffBuilds(from, to)
+	if err != nil {
+		return err
+	}
+
+	writeDiff(cmd, diff)
+	return nil
+}
+
+// writeDiff prints the package and index file changes of a build diff
+func writeDiff(cmd *cobra.Command, diff *app.BuildDiff) {
+	w := cmd.OutOrStdout()
+	fmt.Fprintf(w, "--- %s\n+++ %s\n", diff.From, diff.To)
+
+	marks := map[string]string{app.ChangeAdded: "+", app.ChangeRemoved: "-", app.ChangeChanged: "~"}
+
+	fmt.Fprintln(w, "\nPackages:")
+	for _, pkg := range diff.Packages {
+		var versions []string
+		for _, version := range pkg.Removed {
+			versions = append(versions, "-"+version)
+		}
+		for _, version := range pkg.Added {
+			versions = append(versions, "+"+version)
+		}
+		fmt.Fprintf(w, "  %s %s %s %s\n", marks[pkg.Change], pkg.Repository, pkg.Package, strings.Join(versions, " "))
+	}
+	if len(diff.Packages) == 0 {
+		fmt.Fprintln(w, "  no changes")
+	}
+
+	fmt.Fprintln(w, "\nIndex files:")
+	for _, file := range diff.Indices {
+		fmt.Fprintf(w, "  %s %s\n", marks[file.Change], file.Path)
+	}
+	if len(diff.Indices) == 0 {
+		fmt.Fprintln(w, "  no changes")
+	}
+
+	fmt.Fprintf(w, "\n%d packages added, %d removed, %d changed, %d index files changed\n",
+		diff.Count(app.ChangeAdded), diff.Count(app.ChangeRemoved), diff.Count(app.ChangeChanged), len(diff.Indices))
+}
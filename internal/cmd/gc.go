@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/dionysius/aarg/internal/app"
+	"github.com/dionysius/aarg/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var gcDownloads bool
+
+// gcCmd represents the gc command
+var gcCmd = &cobra.Command{
+	Use:   "gc",
+	Short: "Clean up caches according to the configured policies",
+	Long: `Apply the cleanup policies configured under gc in config.yaml.
+
+The downloads cache keeps every fetched file. With a policy configured, files not
+used for longer than max_age_days are evicted, then the least recently used files
+until the cache fits max_size_mb. Files hardlinked into trusted storage are never
+evicted. The policy is also applied after every fetch.
+
+gc:
+  downloads:
+    max_size_mb: 10240
+    max_age_days: 30
+
+Examples:
+  aarg gc                                # Apply all policies
+  aarg gc --downloads                    # Only clean up the downloads cache`,
+	Args: cobra.NoArgs,
+	RunE: runGC,
+}
+
+func init() {
+	gcCmd.Flags().BoolVar(&gcDownloads, "downloads", false, "evict files of the downloads cache")
+}
+
+func runGC(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+
+	// Without a selection all policies are applied
+	downloads := gcDownloads || !cmd.Flags().Changed("downloads")
+
+	// Load configuration
+	cfg, err := config.Load(cfgFile)
+	if err != nil {
+		return err
+	}
+
+	// Initialize application
+	application, err := app.New(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to initialize application: %w", err)
+	}
+	defer application.Shutdown()
+
+	// Execute gc
+	return application.GC(ctx, downloads)
+}
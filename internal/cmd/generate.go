@@ -8,6 +8,8 @@ import (
 	"github.com/spf13/cobra"
 )
 
+var generateOptions app.GenerateOptions
+
 // generateCmd represents the generate command
 var generateCmd = &cobra.Command{
 	Use:   "generate [repos...]",
@@ -18,15 +20,27 @@ This command reads verified packages from the trusted directory, applies retenti
 policies, generates APT repository structure (Packages, Sources, Release files),
 and optionally creates static HTML pages for browsing.
 
+Generating only some repositories merges them with the current build: the other
+repositories are hardlinked unchanged from it, while the shared pages (root index,
+keys, stylesheet, health file) are refreshed for the merged build. Likewise --dist
+only recomposes the selected distributions, the other distributions of the generated
+repositories are hardlinked as published in the current build.
+
 Examples:
   aarg generate vaultwarden              # Generate vaultwarden repository
   aarg generate example vaultwarden      # Generate multiple repositories
-  aarg generate --all                    # Generate all repositories`,
+  aarg generate --all                    # Generate all repositories
+  aarg generate --all --label test       # Name the staging build 20250101-120000-test
+  aarg generate --all --staging-dir /tmp/experiment  # One-off build outside the staging lineage
+  aarg generate --all --incremental      # Reuse unchanged distributions of the previous build
+  aarg generate example --dist noble     # Only regenerate the noble distribution`,
 	RunE: runGenerate,
 }
 
 func init() {
 	addAllReposFlag(generateCmd, &allRepos)
+	addStagingFlags(generateCmd, &generateOptions)
+	generateCmd.Flags().StringSliceVar(&generateOptions.Distributions, "dist", nil, "only generate these distributions, the others are kept from the current build (repeatable)")
 }
 
 func runGenerate(cmd *cobra.Command, args []string) error {
@@ -57,5 +71,5 @@ func runGenerate(cmd *cobra.Command, args []string) error {
 	defer application.Shutdown()
 
 	// Execute generate
-	return application.Generate(ctx, repoNames)
+	return application.Generate(ctx, repoNames, generateOptions)
 }
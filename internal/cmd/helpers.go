@@ -3,6 +3,7 @@ package cmd
 import (
 	"fmt"
 
+	"github.com/dionysius/aarg/internal/app"
 	"github.com/dionysius/aarg/internal/config"
 	"github.com/spf13/cobra"
 )
@@ -11,13 +12,28 @@ const (
 	// Flag names and descriptions for consistent usage across commands
 	allReposFlagName = "all"
 	allReposFlagDesc = "operate on all repositories"
+	forceFlagName    = "force"
+	forceFlagDesc    = "publish even if the removal guard is exceeded"
 )
 
+// addStagingFlags adds the --label, --staging-dir, --incremental and --verify-pool flags to a command that generates
+func addStagingFlags(cmd *cobra.Command, opts *app.GenerateOptions) {
+	cmd.Flags().StringVar(&opts.Label, "label", "", "name appended to the timestamp of the staging build")
+	cmd.Flags().StringVar(&opts.Dir, "staging-dir", "", "generate a one-off build into this directory, it is not linked, cleaned up or published")
+	cmd.Flags().BoolVar(&opts.Incremental, "incremental", false, "reuse the dists of the previous build for unchanged distributions (also generate.incremental)")
+	cmd.Flags().StringVar(&opts.VerifyPool, "verify-pool", "", "re-check checksums of trusted files linked into the pool: none, sample or full (default generate.verify_pool)")
+}
+
 // addAllReposFlag adds the --all flag to a command
 func addAllReposFlag(cmd *cobra.Command, target *bool) {
 	cmd.Flags().BoolVar(target, allReposFlagName, false, allReposFlagDesc)
 }
 
+// addForceFlag adds the --force flag to a command that publishes
+func addForceFlag(cmd *cobra.Command, target *bool) {
+	cmd.Flags().BoolVar(target, forceFlagName, false, forceFlagDesc)
+}
+
 // validateRepoArgs validates repository arguments and --all flag usage
 func validateRepoArgs(args []string, all bool) error {
 	if !all && len(args) == 0 {
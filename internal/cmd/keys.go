@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/dionysius/aarg/internal/app"
+	"github.com/dionysius/aarg/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var (
+	keysCheckFile  string
+	keysRotateOpts app.RotateKeyOptions
+)
+
+// keysCmd represents the keys command
+var keysCmd = &cobra.Command{
+	Use:     "keys",
+	Aliases: []string{"key"},
+	Short:   "Verification and signing key commands",
+	Long:    `Commands for inspecting the keys used to verify feeds and rotating the signing key.`,
+}
+
+// keysCheckCmd lists and tests the verification keys
+var keysCheckCmd = &cobra.Command{
+	Use:   "check [repos...]",
+	Short: "List verification keys and test them against a sample file",
+	Long: `List the verification keys loaded for repositories with fingerprints, user IDs,
+algorithms and expiry, warning about expired and revoked keys.
+
+With --file a clearsigned sample (e.g., an InRelease or .changes file of a feed) is
+verified like fetch would. The issuers of its signatures are matched against the
+loaded keys to show whether the signing key is missing, expired or revoked.
+
+Examples:
+  aarg keys check --all                             # List keys of all repositories
+  aarg keys check vaultwarden --file InRelease      # Test why a feed fails verification`,
+	RunE: runKeysCheck,
+}
+
+func init() {
+	addAllReposFlag(keysCheckCmd, &allRepos)
+	keysCheckCmd.Flags().StringVar(&keysCheckFile, "file", "", "clearsigned sample file to verify (InRelease, .changes, .dsc)")
+	keysCmd.AddCommand(keysCheckCmd)
+
+	keysRotateCmd.Flags().StringVar(&keysRotateOpts.Import, "import", "", "private key file of the new key instead of generating one")
+	keysRotateCmd.Flags().StringVar(&keysRotateOpts.Passphrase, "passphrase", "", "passphrase of the imported key or to protect the generated key with")
+	keysRotateCmd.Flags().StringVar(&keysRotateOpts.Name, "name", "", "name of the generated key (default: name of the current key)")
+	keysRotateCmd.Flags().StringVar(&keysRotateOpts.Email, "email", "", "email of the generated key (default: email of the current key)")
+	keysRotateCmd.Flags().StringVar(&keysRotateOpts.OutputDir, "output-dir", "", "directory to write the key files to (default: directory of the current private key)")
+	keysCmd.AddCommand(keysRotateCmd)
+}
+
+func runKeysCheck(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+
+	// Validate arguments
+	if err := validateRepoArgs(args, allRepos); err != nil {
+		return err
+	}
+
+	// Load configuration
+	cfg, err := config.Load(cfgFile)
+	if err != nil {
+		return err
+	}
+
+	// Select repositories
+	repoNames, err := selectRepositories(cfg, args, allRepos)
+	if err != nil {
+		return err
+	}
+
+	// Initialize application
+	application, err := app.New(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to initialize application: %w", err)
+	}
+	defer application.Shutdown()
+
+	// Execute keys check
+	return application.CheckKeys(ctx, repoNames, keysCheckFile)
+}
+
+// keysRotateCmd starts a rotation of the signing key
+var keysRotateCmd = &cobra.Command{
+	Use:   "rotate",
+	Short: "Rotate the signing key",
+	Long: `Start a rotation of the signing key.
+
+A new key pair is generated (or imported with --import) and cross-signed with the
+current signing key. The Release files of the published repositories are signed again
+with both keys and keys/ publishes both public keys, so clients trusting the current key
+keep working and new installs via install.sh trust both keys.
+
+The configuration is not modified. Make the new key the signing key and keep the current
+key in signing.additional as printed, publish, and remove the current key from
+signing.additional once clients picked up the new key.
+
+Examples:
+  aarg keys rotate                                  # Generate a new key with the current user ID
+  aarg keys rotate --import new-private.asc         # Rotate to an existing key`,
+	Args: cobra.NoArgs,
+	RunE: runKeysRotate,
+}
+
+func runKeysRotate(cmd *cobra.Command, args []string) error {
+	// Load configuration
+	cfg, err := config.Load(cfgFile)
+	if err != nil {
+		return err
+	}
+
+	rotated, err := app.RotateKey(cfg, keysRotateOpts)
+	if err != nil {
+		return err
+	}
+
+	w := cmd.OutOrStdout()
+	fmt.Fprintf(w, "Rotated to key %s. Update the signing configuration and publish:\n\n", rotated.Fingerprint)
+	fmt.Fprintln(w, "signing:")
+	fmt.Fprintf(w, "  private_key: %s\n", rotated.PrivateKey)
+	fmt.Fprintf(w, "  public_key: %s\n", rotated.PublicKey)
+	if keysRotateOpts.Passphrase != "" {
+		fmt.Fprintln(w, "  passphrase: <passphrase of the new key>")
+	}
+	fmt.Fprintln(w, "  additional:")
+	fmt.Fprintf(w, "    - private_key: %s\n", cfg.Signing.PrivateKey)
+	fmt.Fprintf(w, "      public_key: %s\n", rotated.PreviousPublic)
+	if cfg.Signing.Key != "" {
+		fmt.Fprintf(w, "      key: %s\n", cfg.Signing.Key)
+	}
+	if cfg.Signing.Passphrase != "" {
+		fmt.Fprintln(w, "      passphrase: <passphrase of the current key>")
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/dionysius/aarg/internal/app"
+	"github.com/dionysius/aarg/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var (
+	promoteNoPublish bool
+	promoteForce     bool
+)
+
+// promoteCmd represents the promote command
+var promoteCmd = &cobra.Command{
+	Use:   "promote [timestamp]",
+	Short: "Promote a reviewed staging build to production",
+	Long: `Promote a previously generated and reviewed build to production.
+
+Requires directories.public_staging to be configured. Generate then updates the
+public staging symlink only, and promote points the public symlink to the given
+build and deploys it to the provider's production environment.
+
+Without a timestamp the build currently linked as public staging is promoted.
+
+Examples:
+  aarg promote                           # Promote the build under review
+  aarg promote 20250101-120000           # Promote a specific staging build
+  aarg promote --no-publish              # Only swap the local public symlink`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runPromote,
+}
+
+func init() {
+	addForceFlag(promoteCmd, &promoteForce)
+	promoteCmd.Flags().BoolVar(&promoteNoPublish, "no-publish", false, "only update the public symlink without deploying to the provider")
+}
+
+func runPromote(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+
+	var staging string
+	if len(args) > 0 {
+		staging = args[0]
+	}
+
+	// Load configuration
+	cfg, err := config.Load(cfgFile)
+	if err != nil {
+		return err
+	}
+
+	// Initialize application
+	application, err := app.New(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to initialize application: %w", err)
+	}
+	defer application.Shutdown()
+
+	// Execute promote
+	return application.Promote(ctx, staging, !promoteNoPublish, promoteForce)
+}
@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/dionysius/aarg/internal/app"
+	"github.com/dionysius/aarg/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var pruneDryRun bool
+
+// pruneCmd represents the prune command
+var pruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove stored packages no longer retained by any repository",
+	Long: `Remove files of trusted storage belonging to packages the retention of every
+repository drops, along with their hardlinks in the downloads cache.
+
+Package files are parsed and filtered like during generate, so only files of
+packages dropped by retention are removed. Files still used by a retained package
+of any repository, like an orig tarball shared by several revisions, are kept.
+Build information is kept while a package of its source version is retained.
+Published builds hardlink their pool and keep working.
+
+Examples:
+  aarg prune --dry-run                   # Report what would be removed
+  aarg prune                             # Remove unused files`,
+	Args: cobra.NoArgs,
+	RunE: runPrune,
+}
+
+func init() {
+	pruneCmd.Flags().BoolVar(&pruneDryRun, "dry-run", false, "only report the files that would be removed")
+}
+
+func runPrune(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+
+	// Load configuration
+	cfg, err := config.Load(cfgFile)
+	if err != nil {
+		return err
+	}
+
+	// Initialize application
+	application, err := app.New(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to initialize application: %w", err)
+	}
+	defer application.Shutdown()
+
+	// Execute prune
+	return application.Prune(ctx, pruneDryRun)
+}
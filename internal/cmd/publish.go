@@ -8,14 +8,27 @@ import (
 	"github.com/spf13/cobra"
 )
 
+var (
+	publishStaging string
+	publishBranch  string
+	publishForce   bool
+	publishRetry   bool
+	publishOnly    []string
+)
+
 // publishCmd represents the publish command
 var publishCmd = &cobra.Command{
-	Use:   "publish [repos...]",
-	Short: "Upload repository to configured provider",
-	Long: `Upload the generated repository to a configured provider such as Cloudflare Pages.
+	Use:   "publish",
+	Short: "Upload repository to configured providers",
+	Long: `Upload the generated repository to the configured providers such as Cloudflare Pages.
 
-The public directory will be uploaded to the configured provider (currently supports
-Cloudflare Pages). Configure the provider in config.yaml:
+The public directory will be uploaded to every enabled provider in turn: Cloudflare Pages,
+a local directory or provider plugins, see publish.providers. Use --only to publish to some
+of them, e.g. after one of them failed. Use --staging to re-publish a previously generated staging build
+without composing it again, e.g. after fixing provider credentials. Use --branch to
+upload a preview deployment of a branch, e.g. from CI, production is never touched by
+previews and has to be published or promoted explicitly. Use --retry after a failed
+publish to publish its staging build again, assets it already uploaded are skipped. Configure the provider in config.yaml:
 
 cloudflare:
   api_token: "your-cloudflare-api-token"
@@ -26,10 +39,25 @@ cloudflare:
     keep_last: 10        # Keep only the last 10 deployments
 
 Examples:
-  aarg publish                           # Publish all generated content`,
+  aarg publish                           # Publish the current public build
+  aarg publish --staging 20250101-120000 # Publish a specific staging build
+  aarg publish --branch feature-x        # Publish a preview of branch feature-x
+  aarg publish --retry                   # Resume the last failed publish
+  aarg publish --only cloudflare         # Publish to Cloudflare Pages only`,
+	Args: cobra.NoArgs,
 	RunE: runPublish,
 }
 
+func init() {
+	addForceFlag(publishCmd, &publishForce)
+	publishCmd.Flags().StringVar(&publishStaging, "staging", "", "publish the staging build with this timestamp instead of the public build")
+	publishCmd.Flags().StringVar(&publishBranch, "branch", "", "publish as preview deployment of this branch instead of production or staging")
+	publishCmd.Flags().BoolVar(&publishRetry, "retry", false, "publish the staging build of the last failed publish again, to the same branch")
+	publishCmd.Flags().StringSliceVar(&publishOnly, "only", nil, "publish to these providers only, e.g. cloudflare, directory or a plugin name")
+	publishCmd.MarkFlagsMutuallyExclusive("retry", "staging")
+	publishCmd.MarkFlagsMutuallyExclusive("retry", "branch")
+}
+
 func runPublish(cmd *cobra.Command, args []string) error {
 	ctx := cmd.Context()
 
@@ -45,7 +73,11 @@ func runPublish(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to initialize application: %w", err)
 	}
 	defer application.Shutdown()
+	application.PublishOnly = publishOnly
 
 	// Execute publish
-	return application.Publish(ctx)
+	if publishRetry {
+		return application.RetryPublish(ctx, publishForce)
+	}
+	return application.Publish(ctx, publishStaging, publishBranch, publishForce)
 }
@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/dionysius/aarg/internal/app"
+	"github.com/dionysius/aarg/internal/compose"
+	"github.com/dionysius/aarg/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var (
+	queryTrusted bool
+	queryFormat  string
+)
+
+// queryCmd represents the query command
+var queryCmd = &cobra.Command{
+	Use:   "query <repo> <package-query>",
+	Short: "List packages of a repository matching an aptly package query",
+	Long: `Evaluate an aptly package query against a repository and list the matching packages
+per distribution and component.
+
+Queries use the aptly syntax: package names with optional version relations, field
+conditions like $Architecture (amd64) or Priority (optional), combined with | and , and
+negated with !. By default the repository as generated into the current build is queried,
+--trusted queries all packages in trusted storage including the ones retention drops.
+
+Examples:
+  aarg query vaultwarden 'vaultwarden (>= 1.33)'                    # Versions from 1.33 on
+  aarg query vaultwarden 'vaultwarden, $Architecture (arm64)'        # Only arm64 packages
+  aarg query vaultwarden 'Name (~ ^vaultwarden)' --format json       # As JSON for scripts
+  aarg query vaultwarden 'vaultwarden' --trusted                     # Everything fetched`,
+	Args:        cobra.ExactArgs(2),
+	RunE:        runQuery,
+	Annotations: map[string]string{noSummaryAnnotation: "true"},
+}
+
+func init() {
+	queryCmd.Flags().BoolVar(&queryTrusted, "trusted", false, "query trusted storage instead of the current build")
+	queryCmd.Flags().StringVar(&queryFormat, "format", "table", "format of the matching packages (table, json)")
+}
+
+func runQuery(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+
+	if queryFormat != "table" && queryFormat != "json" {
+		return fmt.Errorf("invalid format %q: use table or json", queryFormat)
+	}
+
+	// Load configuration
+	cfg, err := config.Load(cfgFile)
+	if err != nil {
+		return err
+	}
+
+	// Initialize application
+	application, err := app.New(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to initialize application: %w", err)
+	}
+	defer application.Shutdown()
+
+	results, err := application.Query(ctx, args[0], args[1], queryTrusted)
+	if err != nil {
+		return err
+	}
+
+	if queryFormat == "json" {
+		// Scripts get an empty list instead of null without matches
+		if results == nil {
+			results = []compose.QueryResult{}
+		}
+		encoder := json.NewEncoder(cmd.OutOrStdout())
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(results)
+	}
+
+	writeQueryResults(cmd, results)
+	return nil
+}
+
+// writeQueryResults prints one row per matching package
+func writeQueryResults(cmd *cobra.Command, results []compose.QueryResult) {
+	tw := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "DISTRIBUTION\tCOMPONENT\tPACKAGE\tVERSION\tARCH\tSOURCE")
+	for _, result := range results {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", result.Distribution, result.Component, result.Package, result.Version, result.Architecture, result.Source)
+	}
+	_ = tw.Flush()
+
+	fmt.Fprintf(cmd.OutOrStdout(), "\n%d packages matched\n", len(results))
+}
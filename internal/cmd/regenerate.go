@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/dionysius/aarg/internal/app"
+	"github.com/dionysius/aarg/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var regenerateStaging string
+
+// regenerateWebCmd represents the regenerate-web command
+var regenerateWebCmd = &cobra.Command{
+	Use:   "regenerate-web",
+	Short: "Rebuild the web pages of an existing build",
+	Long: `Rebuild only the web pages of an existing build from the repository state saved by generate.
+
+Apt indices, Release files and pool files are not touched, which makes iterating on
+templates and themes fast. The build is modified in place, use --staging to select a
+staging build instead of the current public build.
+
+Examples:
+  aarg regenerate-web                           # Regenerate the current public build
+  aarg regenerate-web --staging 20250101-120000 # Regenerate a specific staging build`,
+	Args: cobra.NoArgs,
+	RunE: runRegenerateWeb,
+}
+
+func init() {
+	regenerateWebCmd.Flags().StringVar(&regenerateStaging, "staging", "", "regenerate the staging build with this timestamp instead of the public build")
+}
+
+func runRegenerateWeb(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+
+	// Load configuration
+	cfg, err := config.Load(cfgFile)
+	if err != nil {
+		return err
+	}
+
+	// Initialize application
+	application, err := app.New(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to initialize application: %w", err)
+	}
+	defer application.Shutdown()
+
+	// Execute regenerate
+	return application.RegenerateWeb(ctx, regenerateStaging)
+}
@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/dionysius/aarg/internal/app"
+	"github.com/dionysius/aarg/internal/compose"
+	"github.com/dionysius/aarg/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var retentionPreviewDropped bool
+
+// retentionCmd represents the retention command
+var retentionCmd = &cobra.Command{
+	Use:   "retention",
+	Short: "Retention policy commands",
+	Long:  `Commands for inspecting the effect of the retention policies of repositories.`,
+}
+
+// retentionPreviewCmd lists the versions the retention of a repository keeps and drops
+var retentionPreviewCmd = &cobra.Command{
+	Use:   "preview <repo>",
+	Short: "Preview which versions retention keeps and drops",
+	Long: `Print per source, package, architecture and distribution which versions in trusted
+storage the configured retention policies and pinned versions keep and which they drop.
+
+Package files are parsed and filtered like during generate, but nothing is generated,
+published or removed. Use it to tune retention patterns before a real run.
+
+Examples:
+  aarg retention preview vaultwarden                # List kept and dropped versions
+  aarg retention preview vaultwarden --dropped      # Only list packages losing versions`,
+	Args: cobra.ExactArgs(1),
+	RunE: runRetentionPreview,
+}
+
+func init() {
+	retentionPreviewCmd.Flags().BoolVar(&retentionPreviewDropped, "dropped", false, "only list packages with dropped versions")
+	retentionCmd.AddCommand(retentionPreviewCmd)
+}
+
+func runRetentionPreview(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+
+	// Load configuration
+	cfg, err := config.Load(cfgFile)
+	if err != nil {
+		return err
+	}
+
+	// Initialize application
+	application, err := app.New(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to initialize application: %w", err)
+	}
+	defer application.Shutdown()
+
+	decisions, err := application.PreviewRetention(ctx, args[0])
+	if err != nil {
+		return err
+	}
+
+	writeRetentionPreview(cmd, decisions)
+	return nil
+}
+
+// writeRetentionPreview prints one row per source, package, architecture and distribution with its kept and dropped versions
+func writeRetentionPreview(cmd *cobra.Command, decisions []compose.RetentionDecision) {
+	tw := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SOURCE\tPACKAGE\tARCH\tDISTRIBUTION\tKEPT\tDROPPED")
+
+	var keptTotal, droppedTotal int
+	for start := 0; start < len(decisions); {
+		// Decisions are sorted, so a row covers consecutive decisions
+		first := decisions[start]
+		end := start
+		var kept, dropped []string
+		for ; end < len(decisions) && sameRetentionRow(first, decisions[end]); end++ {
+			decision := decisions[end]
+			switch {
+			case decision.Pinned:
+				kept = append(kept, decision.Version+" (pinned)")
+			case decision.Kept:
+				kept = append(kept, decision.Version)
+			default:
+				dropped = append(dropped, decision.Version)
+			}
+		}
+		start = end
+
+		keptTotal += len(kept)
+		droppedTotal += len(dropped)
+		if retentionPreviewDropped && len(dropped) == 0 {
+			continue
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", first.Source, first.Package, first.Architecture, first.Distribution,
+			strings.Join(kept, ", "), strings.Join(dropped, ", "))
+	}
+	_ = tw.Flush()
+
+	fmt.Fprintf(cmd.OutOrStdout(), "\n%d versions kept, %d dropped\n", keptTotal, droppedTotal)
+}
+
+// sameRetentionRow reports whether two decisions are printed in the same row
+func sameRetentionRow(a, b compose.RetentionDecision) bool {
+	return a.Source == b.Source && a.Package == b.Package && a.Architecture == b.Architecture &&
+		a.Distribution == b.Distribution && a.Component == b.Component
+}
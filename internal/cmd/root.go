@@ -2,19 +2,33 @@ package cmd
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"log/slog"
 	"os"
+	"slices"
+	"time"
 
+	"github.com/dionysius/aarg/internal/app"
+	"github.com/dionysius/aarg/internal/config"
 	"github.com/dionysius/aarg/internal/log"
 	"github.com/spf13/cobra"
 )
 
 var (
-	cfgFile    string
-	verbose    bool
-	realStdout *os.File // Real stdout saved before redirection
+	cfgFile          string
+	verbose          bool
+	warningsAsErrors bool
+	outputFormat     string
+	reportFormat     string
+	realStdout       *os.File              // Real stdout saved before redirection
+	warningCollector *log.WarningCollector // Records warnings for the summary at the end of the run
+	summaryCommand   string                // Command the summary is printed for, empty = no summary
 )
 
+// noSummaryAnnotation marks commands printing data to stdout, no summary is printed after them
+const noSummaryAnnotation = "aarg/no-summary"
+
 // rootCmd represents the base command
 var rootCmd = &cobra.Command{
 	Use:   "aarg",
@@ -24,9 +38,17 @@ var rootCmd = &cobra.Command{
 It downloads packages from GitHub releases, APT repositories, and OBS builds,
 verifies signatures, applies retention policies, and generates APT repository
 structures with optional static web page for browsing.`,
+	Version:       app.CurrentVersion(),
 	SilenceUsage:  true,
 	SilenceErrors: true,
-	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if !slices.Contains(log.SummaryFormats, outputFormat) {
+			return fmt.Errorf("invalid output format %q, valid formats: %v", outputFormat, log.SummaryFormats)
+		}
+		if !slices.Contains(log.ReportFormats, reportFormat) {
+			return fmt.Errorf("invalid report format %q, valid formats: %v", reportFormat, log.ReportFormats[1:])
+		}
+
 		// Save the real stdout before redirecting
 		realStdout = os.Stdout
 
@@ -39,29 +61,120 @@ structures with optional static web page for browsing.`,
 			level = slog.LevelDebug
 		}
 
-		handler := log.NewHandler(realStdout, level)
-		slog.SetDefault(slog.New(handler))
+		// Keep stdout to the JSON summary or report
+		logOutput := realStdout
+		if outputFormat == log.SummaryJSON || reportFormat == log.ReportJSON {
+			logOutput = os.Stderr
+		}
+
+		handler := log.NewHandler(logOutput, level)
+		warningCollector = log.NewWarningCollector(handler)
+		slog.SetDefault(slog.New(warningCollector))
 
 		// Set Cobra's output to real stdout (not redirected)
 		cmd.SetOut(realStdout)
-		cmd.SetErr(realStdout)
+		cmd.SetErr(logOutput)
+
+		if cmd.Annotations[noSummaryAnnotation] == "" {
+			summaryCommand = cmd.CommandPath()
+		}
+		return nil
 	},
 }
 
 // ExecuteContext runs the root command with context
+// Warnings of the run are summarized at the end, failing the run with --warnings-as-errors
+// A summary of the run is printed last, as table or as JSON with --output json
+// Runs processing repositories write a report to the staging directory, printed instead of the summary with --report json
 func ExecuteContext(ctx context.Context) error {
-	return rootCmd.ExecuteContext(ctx)
+	start := time.Now()
+	err := rootCmd.ExecuteContext(ctx)
+
+	if warningCollector != nil {
+		warningCollector.PrintWarnings(ctx)
+	}
+	if err == nil && warningsAsErrors {
+		if count := len(log.Warnings()); count > 0 {
+			err = fmt.Errorf("%d warnings treated as errors", count)
+		}
+	}
+
+	if summaryCommand != "" {
+		summary := log.NewSummary(summaryCommand, start, err)
+		// Commands load the configuration themselves, so it is loaded again at the end of the run
+		cfg, cfgErr := config.Load(cfgFile)
+		if cfgErr == nil {
+			summary.Config, _ = cfg.Fingerprint()
+		}
+		if reportFormat != log.ReportJSON {
+			if writeErr := summary.Write(realStdout, outputFormat); writeErr != nil {
+				slog.Error("Failed to print summary", "error", writeErr)
+			}
+		}
+		if cfgErr == nil {
+			report(cfg, summary)
+		}
+		if cfgErr == nil {
+			notify(ctx, cfg, summary)
+		}
+	}
+
+	return err
+}
+
+// report writes the report of the run to the staging directory and to stdout with --report json
+// Runs not processing any repository, like config or gc, have nothing to report
+func report(cfg *config.Config, summary log.Summary) {
+	if len(log.Repositories()) == 0 {
+		return
+	}
+
+	runReport := app.NewReport(cfg, summary)
+	if err := app.WriteReport(cfg, runReport); err != nil {
+		slog.Error("Failed to write report", "error", err)
+	}
+	if reportFormat == log.ReportJSON {
+		encoder := json.NewEncoder(realStdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(runReport); err != nil {
+			slog.Error("Failed to print report", "error", err)
+		}
+	}
+}
+
+// notify sends the summary to the webhooks of the configuration, if enabled
+func notify(ctx context.Context, cfg *config.Config, summary log.Summary) {
+	if !cfg.Notifications.IsEnabled() {
+		return
+	}
+	if err := app.Notify(ctx, cfg, summary); err != nil {
+		slog.Error("Failed to send notifications", "error", err)
+	}
 }
 
 func init() {
 	// Global flags
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default: ~/.config/aarg/config.yaml or /etc/aarg/config.yaml)")
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "", "v", false, "enable debug logging")
+	rootCmd.PersistentFlags().BoolVar(&warningsAsErrors, "warnings-as-errors", false, "fail the run if any warnings were logged")
+	rootCmd.PersistentFlags().StringVar(&outputFormat, "output", log.SummaryText, "format of the summary at the end of the run (text, json), logs go to stderr with json")
+	rootCmd.PersistentFlags().StringVar(&reportFormat, "report", log.ReportNone, "print the run report instead of the summary (json), logs go to stderr")
 
 	// Add subcommands
 	rootCmd.AddCommand(fetchCmd)
 	rootCmd.AddCommand(generateCmd)
+	rootCmd.AddCommand(regenerateWebCmd)
 	rootCmd.AddCommand(publishCmd)
+	rootCmd.AddCommand(promoteCmd)
+	rootCmd.AddCommand(diffCmd)
+	rootCmd.AddCommand(uploadCmd)
 	rootCmd.AddCommand(buildCmd)
 	rootCmd.AddCommand(configCmd)
+	rootCmd.AddCommand(gcCmd)
+	rootCmd.AddCommand(pruneCmd)
+	rootCmd.AddCommand(retentionCmd)
+	rootCmd.AddCommand(queryCmd)
+	rootCmd.AddCommand(keysCmd)
+	rootCmd.AddCommand(selfUpdateCmd)
+	rootCmd.AddCommand(demoCmd)
 }
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/dionysius/aarg/internal/app"
+	"github.com/spf13/cobra"
+)
+
+var (
+	selfUpdateRelease  string
+	selfUpdateKeys     []string
+	selfUpdateCheck    bool
+	selfUpdateForce    bool
+	selfUpdateInsecure bool
+)
+
+// selfUpdateCmd represents the self-update command
+// Hidden until releases publish signed aarg-<os>-<arch> binaries and the release key is embedded
+var selfUpdateCmd = &cobra.Command{
+	Use:    "self-update",
+	Hidden: true,
+	Short:  "Replace this binary with a release from GitHub",
+	Long: `Download the aarg binary for this platform from the project's GitHub releases and
+replace the running binary with it.
+
+The binary must match the SHA256SUMS of the release and GitHub's digest, and the detached
+SHA256SUMS.asc signature must verify against the release signing key embedded in this
+binary, or against the keys given with --key. An update without a valid signature is
+refused unless --insecure is given. The new binary is
+written next to the running one and renamed over it, so an interrupted update leaves the
+old binary in place. No configuration file is needed, GITHUB_TOKEN is used if set.
+
+Examples:
+  aarg self-update                         # Install the latest release
+  aarg self-update --check                 # Only report whether an update is available
+  aarg self-update --release v0.3.0        # Install a specific release
+  aarg self-update --key release-key.asc   # Verify against another key than the embedded one
+  aarg self-update --insecure              # Accept unsigned checksums`,
+	Args: cobra.NoArgs,
+	RunE: runSelfUpdate,
+}
+
+func init() {
+	selfUpdateCmd.Flags().StringVar(&selfUpdateRelease, "release", "", "release tag to install (default: latest)")
+	selfUpdateCmd.Flags().StringArrayVar(&selfUpdateKeys, "key", nil, "key file or directory the checksums signature must verify against (repeatable)")
+	selfUpdateCmd.Flags().BoolVar(&selfUpdateCheck, "check", false, "only check whether an update is available")
+	selfUpdateCmd.Flags().BoolVar(&selfUpdateForce, "force", false, "install even if the release is already running or the running version is unknown")
+	selfUpdateCmd.Flags().BoolVar(&selfUpdateInsecure, "insecure", false, "accept release checksums without a valid signature")
+}
+
+func runSelfUpdate(cmd *cobra.Command, args []string) error {
+	return app.SelfUpdate(cmd.Context(), app.SelfUpdateOptions{
+		Release:   selfUpdateRelease,
+		KeyPaths:  selfUpdateKeys,
+		CheckOnly: selfUpdateCheck,
+		Force:     selfUpdateForce,
+		Insecure:  selfUpdateInsecure,
+		Token:     os.Getenv("GITHUB_TOKEN"),
+	})
+}
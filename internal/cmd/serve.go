@@ -16,7 +16,10 @@ var serveCmd = &cobra.Command{
 
 The server will serve the contents of the public directory, making the
 repository accessible via a web browser. This is useful for testing the
-generated repository pages before deploying to production.`,
+generated repository pages before deploying to production.
+
+With serve.ingest enabled, new signed .changes files appearing in the download
+directories of GitHub feeds are verified and linked into trusted storage.`,
 	RunE: runServe,
 }
 
@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/dionysius/aarg/internal/app"
+	"github.com/dionysius/aarg/internal/config"
+	"github.com/spf13/cobra"
+)
+
+// uploadCmd represents the upload command
+var uploadCmd = &cobra.Command{
+	Use:   "upload [repos...]",
+	Short: "Upload install files as GitHub release assets",
+	Long: `Upload install.sh, the signing keys and a sources file per distribution of the
+production build as assets to a GitHub release, so documentation can reference
+stable asset URLs independent of the web host.
+
+Repositories without upload configuration are skipped. Unchanged assets are kept.
+The release must exist and the github token needs write access. Configure it per repository:
+
+upload:
+  github: "owner/repo"
+  tag: "apt-repository"
+
+Examples:
+  aarg upload vaultwarden              # Upload assets of vaultwarden repository
+  aarg upload --all                    # Upload assets of all configured repositories`,
+	RunE: runUpload,
+}
+
+func init() {
+	addAllReposFlag(uploadCmd, &allRepos)
+}
+
+func runUpload(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+
+	// Validate arguments
+	if err := validateRepoArgs(args, allRepos); err != nil {
+		return err
+	}
+
+	// Load configuration
+	cfg, err := config.Load(cfgFile)
+	if err != nil {
+		return err
+	}
+
+	// Select repositories
+	repoNames, err := selectRepositories(cfg, args, allRepos)
+	if err != nil {
+		return err
+	}
+
+	// Initialize application
+	application, err := app.New(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to initialize application: %w", err)
+	}
+	defer application.Shutdown()
+
+	// Execute upload
+	return application.Upload(ctx, repoNames)
+}
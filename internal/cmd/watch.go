@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/dionysius/aarg/internal/app"
+	"github.com/dionysius/aarg/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var watchOptions app.BuildOptions
+
+// watchCmd represents the watch command
+var watchCmd = &cobra.Command{
+	Use:   "watch",
+	Short: "Build repositories when webhooks arrive",
+	Long: `Run a long-lived HTTP listener that builds repositories when webhooks arrive.
+
+Instead of building everything on a schedule, only the repositories affected by a
+webhook are fetched, generated and published, like "aarg build" would.
+
+Endpoints:
+  POST /github                  GitHub webhook, release events build every repository
+                                with a GitHub feed of the releasing GitHub repository
+  POST /trigger/{repository}    Build the repository, for any other system
+
+GitHub webhooks are verified with the signature of watch.secret, generic triggers must
+send it as "Authorization: Bearer <secret>". Webhooks arriving within watch.debounce_seconds
+are built together, further webhooks during a build are queued for the next one. Every
+build writes its own report.json to the staging directory and sends its own notifications.
+
+Examples:
+  aarg watch                    # Listen on localhost:8090
+  aarg watch --no-publish       # Only fetch and generate on webhooks
+  curl -X POST -H "Authorization: Bearer $AARG_WATCH_SECRET" http://localhost:8090/trigger/vaultwarden`,
+	RunE: runWatch,
+}
+
+func init() {
+	rootCmd.AddCommand(watchCmd)
+	watchCmd.Flags().BoolVar(&noPublish, "no-publish", false, "stop after generate without publishing")
+}
+
+func runWatch(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+
+	// Load configuration
+	cfg, err := config.Load(cfgFile)
+	if err != nil {
+		return err
+	}
+
+	// Initialize application
+	application, err := app.New(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to initialize application: %w", err)
+	}
+	defer application.Shutdown()
+
+	// Execute watch
+	watchOptions.Publish = !noPublish
+	return application.Watch(ctx, watchOptions)
+}
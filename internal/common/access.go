@@ -0,0 +1,210 @@
+package common
+
+import (
+	"cmp"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// accessDayFormat is the key of a day in the access statistics
+const accessDayFormat = "2006-01-02"
+
+// AccessCount is the number of downloads of a package on a day
+type AccessCount struct {
+	Downloads int64 `json:"downloads"`
+	Clients   int64 `json:"clients"` // Distinct clients of the day
+}
+
+// PackageAccess is the number of downloads of a package over several days
+type PackageAccess struct {
+	Package   string
+	Downloads int64
+	Clients   int64 // Sum of the distinct clients per day, a client downloading on two days counts twice
+}
+
+// AccessStats counts package downloads per day without keeping anything that identifies a client
+// Clients are only told apart by a hash of address and user agent with a random salt. The salt and the
+// hashes stay in memory and are dropped when the day changes, only the daily counts are persisted
+// Thread-safe for concurrent Record() calls
+type AccessStats struct {
+	mu        sync.Mutex
+	days      map[string]map[string]*AccessCount // Day -> package -> counts
+	day       string                             // Day of salt and clients
+	salt      []byte
+	clients   map[string]map[string]struct{} // Package -> hashed clients of day
+	retention int                            // Days kept, older days are dropped
+}
+
+// NewAccessStats creates empty access statistics keeping the given number of days
+func NewAccessStats(retention int) *AccessStats {
+	return &AccessStats{
+		days:      make(map[string]map[string]*AccessCount),
+		clients:   make(map[string]map[string]struct{}),
+		retention: retention,
+	}
+}
+
+// LoadAccessStats reads the access statistics saved at path, a missing file starts empty
+// Distinct clients of the current day are not persisted, clients returning after a restart are counted again
+func LoadAccessStats(path string, retention int) (*AccessStats, error) {
+	stats := NewAccessStats(retention)
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return stats, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read access statistics: %w", err)
+	}
+
+	var days map[string]map[string]*AccessCount
+	if err := json.Unmarshal(data, &days); err != nil {
+		return nil, fmt.Errorf("failed to parse access statistics %s: %w", path, err)
+	}
+	for day, packages := range days {
+		if packages != nil {
+			stats.days[day] = packages
+		}
+	}
+	return stats, nil
+}
+
+// Record counts a download of pkg by the client with the given address and user agent at the given time
+func (s *AccessStats) Record(pkg, addr, userAgent string, at time.Time) {
+	day := at.UTC().Format(accessDayFormat)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if day != s.day {
+		s.rotate(day)
+	}
+
+	packages := s.days[day]
+	if packages == nil {
+		packages = make(map[string]*AccessCount)
+		s.days[day] = packages
+	}
+	count := packages[pkg]
+	if count == nil {
+		count = &AccessCount{}
+		packages[pkg] = count
+	}
+	count.Downloads++
+
+	clients := s.clients[pkg]
+	if clients == nil {
+		clients = make(map[string]struct{})
+		s.clients[pkg] = clients
+	}
+	client := s.clientID(addr, userAgent)
+	if _, seen := clients[client]; !seen {
+		clients[client] = struct{}{}
+		count.Clients++
+	}
+}
+
+// rotate starts a new day with a fresh salt, forgets the clients of the previous day and drops expired days
+func (s *AccessStats) rotate(day string) {
+	s.day = day
+	s.salt = make([]byte, 32)
+	_, _ = rand.Read(s.salt)
+	s.clients = make(map[string]map[string]struct{})
+
+	if s.retention <= 0 {
+		return
+	}
+	start, err := time.Parse(accessDayFormat, day)
+	if err != nil {
+		return
+	}
+	oldest := start.AddDate(0, 0, -s.retention+1).Format(accessDayFormat)
+	for d := range s.days {
+		if d < oldest {
+			delete(s.days, d)
+		}
+	}
+}
+
+// clientID returns the salted hash identifying a client for the current day
+func (s *AccessStats) clientID(addr, userAgent string) string {
+	h := sha256.New()
+	h.Write(s.salt)
+	h.Write([]byte(addr))
+	h.Write([]byte{0})
+	h.Write([]byte(userAgent))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Top returns the limit most downloaded packages over the given number of days up to now, most downloads first
+// A limit of 0 or less returns all packages
+func (s *AccessStats) Top(days, limit int, now time.Time) []PackageAccess {
+	oldest := now.UTC().AddDate(0, 0, -days+1).Format(accessDayFormat)
+
+	s.mu.Lock()
+	totals := make(map[string]*PackageAccess)
+	for day, packages := range s.days {
+		if day < oldest {
+			continue
+		}
+		for pkg, count := range packages {
+			total := totals[pkg]
+			if total == nil {
+				total = &PackageAccess{Package: pkg}
+				totals[pkg] = total
+			}
+			total.Downloads += count.Downloads
+			total.Clients += count.Clients
+		}
+	}
+	s.mu.Unlock()
+
+	result := make([]PackageAccess, 0, len(totals))
+	for _, total := range totals {
+		result = append(result, *total)
+	}
+	slices.SortFunc(result, func(a, b PackageAccess) int {
+		if c := cmp.Compare(b.Downloads, a.Downloads); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Package, b.Package)
+	})
+	if limit > 0 && len(result) > limit {
+		result = result[:limit]
+	}
+	return result
+}
+
+// Save writes the daily counts to path, replacing the previous file
+func (s *AccessStats) Save(path string) error {
+	s.mu.Lock()
+	data, err := json.Marshal(s.days)
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create access statistics directory: %w", err)
+	}
+
+	// Write to a temporary file and rename to avoid a partially written file
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write access statistics: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("failed to write access statistics: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package common
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccessStats_Record(t *testing.T) {
+	day := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		requests [][3]string // Package, address and user agent
+		want     []PackageAccess
+	}{
+		{
+			name:     "single download",
+			requests: [][3]string{{"main/hello", "192.0.2.1", "apt"}},
+			want:     []PackageAccess{{Package: "main/hello", Downloads: 1, Clients: 1}},
+		},
+		{
+			name: "repeated client counted once",
+			requests: [][3]string{
+				{"main/hello", "192.0.2.1", "apt"},
+				{"main/hello", "192.0.2.1", "apt"},
+				{"main/hello", "192.0.2.2", "apt"},
+				{"main/hello", "192.0.2.2", "curl"},
+			},
+			want: []PackageAccess{{Package: "main/hello", Downloads: 4, Clients: 3}},
+		},
+		{
+			name: "ordered by downloads then name",
+			requests: [][3]string{
+				{"main/b", "192.0.2.1", "apt"},
+				{"main/a", "192.0.2.1", "apt"},
+				{"main/c", "192.0.2.1", "apt"},
+				{"main/c", "192.0.2.2", "apt"},
+			},
+			want: []PackageAccess{
+				{Package: "main/c", Downloads: 2, Clients: 2},
+				{Package: "main/a", Downloads: 1, Clients: 1},
+				{Package: "main/b", Downloads: 1, Clients: 1},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stats := NewAccessStats(30)
+			for _, r := range tt.requests {
+				stats.Record(r[0], r[1], r[2], day)
+			}
+			assert.Equal(t, tt.want, stats.Top(1, 0, day))
+		})
+	}
+}
+
+func TestAccessStats_Days(t *testing.T) {
+	stats := NewAccessStats(3)
+	day := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	// The same client on the next day is counted again, its identifier changed with the salt
+	stats.Record("main/hello", "192.0.2.1", "apt", day)
+	stats.Record("main/hello", "192.0.2.1", "apt", day.AddDate(0, 0, 1))
+	assert.Equal(t, []PackageAccess{{Package: "main/hello", Downloads: 2, Clients: 2}}, stats.Top(7, 0, day.AddDate(0, 0, 1)))
+	assert.Equal(t, []PackageAccess{{Package: "main/hello", Downloads: 1, Clients: 1}}, stats.Top(1, 0, day.AddDate(0, 0, 1)))
+
+	// Days beyond the retention are dropped once a new day starts
+	stats.Record("main/other", "192.0.2.1", "apt", day.AddDate(0, 0, 3))
+	assert.Equal(t, []PackageAccess{
+		{Package: "main/hello", Downloads: 1, Clients: 1},
+		{Package: "main/other", Downloads: 1, Clients: 1},
+	}, stats.Top(30, 0, day.AddDate(0, 0, 3)))
+
+	assert.Len(t, stats.Top(30, 1, day.AddDate(0, 0, 3)), 1)
+}
+
+func TestAccessStats_SaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.json")
+	day := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	stats, err := LoadAccessStats(path, 30)
+	require.NoError(t, err)
+	assert.Empty(t, stats.Top(30, 0, day))
+
+	stats.Record("main/hello", "192.0.2.1", "apt/2.6", day)
+	require.NoError(t, stats.Save(path))
+
+	// Only counts are persisted, neither addresses, user agents nor their hashes
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"2026-03-10":{"main/hello":{"downloads":1,"clients":1}}}`, string(data))
+	assert.False(t, strings.Contains(string(data), "192.0.2.1"))
+
+	loaded, err := LoadAccessStats(path, 30)
+	require.NoError(t, err)
+	assert.Equal(t, stats.Top(30, 0, day), loaded.Top(30, 0, day))
+
+	require.NoError(t, os.WriteFile(path, []byte("not json"), 0644))
+	_, err = LoadAccessStats(path, 30)
+	assert.Error(t, err)
+}
@@ -0,0 +1,53 @@
+package common
+
+import (
+	"runtime/debug"
+	"strings"
+)
+
+// BuildInfo identifies the aarg binary an output was produced with
+type BuildInfo struct {
+	Version string `json:"version"`          // Module version, "(devel)" for builds from a working tree
+	Commit  string `json:"commit,omitempty"` // VCS revision, suffixed with "-dirty" if the tree was modified
+}
+
+// CurrentBuild returns the build information of the running binary
+func CurrentBuild() BuildInfo {
+	build := BuildInfo{Version: "(devel)"}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return build
+	}
+	if info.Main.Version != "" {
+		build.Version = info.Main.Version
+	}
+
+	var modified bool
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			build.Commit = setting.Value
+		case "vcs.modified":
+			modified = setting.Value == "true"
+		}
+	}
+	if build.Commit != "" && modified {
+		build.Commit += "-dirty"
+	}
+	return build
+}
+
+// String returns the version with the abbreviated commit, e.g. "v1.2.0 (0123456789ab)"
+func (b BuildInfo) String() string {
+	if b.Commit == "" {
+		return b.Version
+	}
+	commit, dirty := strings.CutSuffix(b.Commit, "-dirty")
+	if len(commit) > 12 {
+		commit = commit[:12]
+	}
+	if dirty {
+		commit += "-dirty"
+	}
+	return b.Version + " (" + commit + ")"
+}
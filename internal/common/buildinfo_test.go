@@ -0,0 +1,25 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildInfo_String(t *testing.T) {
+	tests := []struct {
+		name  string
+		build BuildInfo
+		want  string
+	}{
+		{name: "without commit", build: BuildInfo{Version: "v1.2.0"}, want: "v1.2.0"},
+		{name: "abbreviated commit", build: BuildInfo{Version: "v1.2.0", Commit: "0123456789abcdef0123456789abcdef01234567"}, want: "v1.2.0 (0123456789ab)"},
+		{name: "dirty tree", build: BuildInfo{Version: "(devel)", Commit: "0123456789abcdef0123456789abcdef01234567-dirty"}, want: "(devel) (0123456789ab-dirty)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.build.String())
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package common
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// CachePolicy limits the files kept in a cache directory
+type CachePolicy struct {
+	MaxSize uint64        // Max bytes of evictable files, least recently used are evicted first (0 = unlimited)
+	MaxAge  time.Duration // Evict files not used for this long (0 = unlimited)
+}
+
+// IsEnabled reports whether the policy limits anything
+func (p CachePolicy) IsEnabled() bool {
+	return p.MaxSize > 0 || p.MaxAge > 0
+}
+
+// EvictionStats summarizes an eviction run
+type EvictionStats struct {
+	Files     int    // Evicted files
+	Bytes     uint64 // Evicted bytes
+	Kept      int    // Remaining evictable files
+	KeptBytes uint64 // Remaining evictable bytes
+	Linked    int    // Files skipped because they are hardlinked elsewhere
+}
+
+// cacheFile is an evictable file of a cache directory
+type cacheFile struct {
+	path     string
+	size     uint64
+	lastUsed time.Time
+}
+
+// EvictCache removes files below dir according to policy
+// Files hardlinked elsewhere (e.g., into trusted storage) and dotfiles are never evicted
+// Last use is the later of access and modification time, as filesystems mounted with
+// noatime or relatime don't update the access time on every read
+func EvictCache(dir string, policy CachePolicy, now time.Time) (EvictionStats, error) {
+	var stats EvictionStats
+	var files []cacheFile
+
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == dir {
+				return fs.SkipDir
+			}
+			return err
+		}
+		if strings.HasPrefix(d.Name(), ".") && path != dir {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if linkCount(info) > 1 {
+			stats.Linked++
+			return nil
+		}
+
+		files = append(files, cacheFile{path: path, size: uint64(info.Size()), lastUsed: lastUsed(info)})
+		return nil
+	})
+	if err != nil {
+		return stats, err
+	}
+
+	// Least recently used first
+	slices.SortFunc(files, func(a, b cacheFile) int {
+		return a.lastUsed.Compare(b.lastUsed)
+	})
+
+	var total uint64
+	for _, file := range files {
+		total += file.size
+	}
+
+	for _, file := range files {
+		expired := policy.MaxAge > 0 && now.Sub(file.lastUsed) > policy.MaxAge
+		oversized := policy.MaxSize > 0 && total > policy.MaxSize
+		if !expired && !oversized {
+			stats.Kept++
+			stats.KeptBytes += file.size
+			continue
+		}
+
+		if err := os.Remove(file.path); err != nil {
+			return stats, err
+		}
+		total -= file.size
+		stats.Files++
+		stats.Bytes += file.size
+	}
+
+	return stats, removeEmptyDirs(dir)
+}
+
+// removeEmptyDirs removes empty directories below dir, keeping dir itself
+func removeEmptyDirs(dir string) error {
+	var dirs []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == dir {
+				return fs.SkipDir
+			}
+			return err
+		}
+		if d.IsDir() && path != dir {
+			dirs = append(dirs, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Deepest first so parents become empty
+	for _, path := range slices.Backward(dirs) {
+		entries, err := os.ReadDir(path)
+		if err == nil && len(entries) == 0 {
+			_ = os.Remove(path)
+		}
+	}
+	return nil
+}
+
+// lastUsed returns the later of access and modification time of a file
+func lastUsed(info fs.FileInfo) time.Time {
+	used := info.ModTime()
+	if atime, ok := accessTime(info); ok && atime.After(used) {
+		used = atime
+	}
+	return used
+}
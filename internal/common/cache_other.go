@@ -0,0 +1,13 @@
+//go:build !linux && !darwin
+
+package common
+
+import (
+	"io/fs"
+	"time"
+)
+
+// accessTime reports no access time, callers fall back to the modification time
+func accessTime(fs.FileInfo) (time.Time, bool) {
+	return time.Time{}, false
+}
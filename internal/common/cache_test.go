@@ -0,0 +1,93 @@
+package common
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvictCache(t *testing.T) {
+	now := time.Now()
+
+	// writeFile creates a file of size bytes last used age ago
+	writeFile := func(t *testing.T, path string, size int, age time.Duration) {
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, make([]byte, size), 0644))
+		used := now.Add(-age)
+		require.NoError(t, os.Chtimes(path, used, used))
+	}
+
+	setup := func(t *testing.T) string {
+		dir := t.TempDir()
+		writeFile(t, filepath.Join(dir, "feed", "old.deb"), 100, 48*time.Hour)
+		writeFile(t, filepath.Join(dir, "feed", "mid.deb"), 100, 24*time.Hour)
+		writeFile(t, filepath.Join(dir, "other", "new.deb"), 100, time.Hour)
+		writeFile(t, filepath.Join(dir, ".fetch-history"), 10, 100*time.Hour)
+
+		// Hardlinked into trusted storage
+		trusted := filepath.Join(t.TempDir(), "linked.deb")
+		writeFile(t, trusted, 1000, 100*time.Hour)
+		require.NoError(t, os.Link(trusted, filepath.Join(dir, "feed", "linked.deb")))
+		return dir
+	}
+
+	tests := []struct {
+		name    string
+		policy  CachePolicy
+		evicted []string
+		stats   EvictionStats
+	}{
+		{
+			name:   "no limits",
+			policy: CachePolicy{},
+			stats:  EvictionStats{Kept: 3, KeptBytes: 300, Linked: 1},
+		},
+		{
+			name:    "max age",
+			policy:  CachePolicy{MaxAge: 36 * time.Hour},
+			evicted: []string{"feed/old.deb"},
+			stats:   EvictionStats{Files: 1, Bytes: 100, Kept: 2, KeptBytes: 200, Linked: 1},
+		},
+		{
+			name:    "max size evicts least recently used",
+			policy:  CachePolicy{MaxSize: 150},
+			evicted: []string{"feed/old.deb", "feed/mid.deb"},
+			stats:   EvictionStats{Files: 2, Bytes: 200, Kept: 1, KeptBytes: 100, Linked: 1},
+		},
+		{
+			name:    "max size removes empty directories",
+			policy:  CachePolicy{MaxSize: 1},
+			evicted: []string{"feed/old.deb", "feed/mid.deb", "other/new.deb", "other"},
+			stats:   EvictionStats{Files: 3, Bytes: 300, Linked: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := setup(t)
+
+			stats, err := EvictCache(dir, tt.policy, now)
+			require.NoError(t, err)
+			assert.Equal(t, tt.stats, stats)
+
+			for _, path := range tt.evicted {
+				assert.NoFileExists(t, filepath.Join(dir, path))
+				assert.NoDirExists(t, filepath.Join(dir, path))
+			}
+
+			// Linked files and dotfiles are never evicted
+			assert.FileExists(t, filepath.Join(dir, "feed", "linked.deb"))
+			assert.FileExists(t, filepath.Join(dir, ".fetch-history"))
+		})
+	}
+
+	t.Run("missing directory", func(t *testing.T) {
+		stats, err := EvictCache(filepath.Join(t.TempDir(), "missing"), CachePolicy{MaxSize: 1}, now)
+		require.NoError(t, err)
+		assert.Equal(t, EvictionStats{}, stats)
+	})
+}
@@ -16,6 +16,9 @@ type GenericRetentionCollector[T any] struct {
 	// Retention policies to apply
 	retentionPolicies []RetentionPolicy
 
+	// Versions kept regardless of the retention policies
+	pins []PinnedVersion
+
 	// Function to extract metadata from items
 	// Returns: sourceName, packageName, arch, version
 	getMetadata func(T) (string, string, string, string)
@@ -24,16 +27,18 @@ type GenericRetentionCollector[T any] struct {
 	mu sync.RWMutex
 }
 
-// NewGenericRetentionCollector creates a new collector with retention policies
+// NewGenericRetentionCollector creates a new collector with retention policies and pinned versions
 // getMetadata should return: sourceName, packageName, arch, version
 // Always uses NoMatchKeep behavior for items that don't match any retention pattern
 func NewGenericRetentionCollector[T any](
 	retentionPolicies []RetentionPolicy,
+	pins []PinnedVersion,
 	getMetadata func(T) (string, string, string, string),
 ) *GenericRetentionCollector[T] {
 	return &GenericRetentionCollector[T]{
 		filters:           make(map[string]map[string]map[string]map[string]*RetentionFilter[T]),
 		retentionPolicies: retentionPolicies,
+		pins:              pins,
 		getMetadata:       getMetadata,
 	}
 }
@@ -65,7 +70,8 @@ func (c *GenericRetentionCollector[T]) Add(dist, component string, item T) error
 	filter, exists := c.filters[dist][component][packageName][arch]
 	if !exists {
 		var err error
-		retentionRules := FilterBySource(c.retentionPolicies, sourceName)
+		// Policies are selected per distribution and source package
+		retentionRules := FilterBySource(FilterByDistribution(c.retentionPolicies, dist), sourceName)
 		filter, err = NewRetentionFilter(retentionRules, func(item T) string {
 			_, _, _, v := c.getMetadata(item)
 			return v
@@ -73,6 +79,7 @@ func (c *GenericRetentionCollector[T]) Add(dist, component string, item T) error
 		if err != nil {
 			return err
 		}
+		filter.pinned = PinnedVersions(c.pins, sourceName)
 		c.filters[dist][component][packageName][arch] = filter
 	}
 
@@ -146,14 +153,50 @@ func (c *GenericRetentionCollector[T]) ForEachKept(fn func(dist, component, pack
 	return nil
 }
 
+// ForEachItem iterates through all added items with whether they passed the retention policies
+// The callback receives: dist, component, packageName, arch, the item and whether it is kept
+// Thread-safe for concurrent access
+func (c *GenericRetentionCollector[T]) ForEachItem(fn func(dist, component, packageName, arch string, item T, kept bool) error) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for dist, components := range c.filters {
+		for component, packages := range components {
+			for packageName, archFilters := range packages {
+				for arch, filter := range archFilters {
+					// Retention keeps versions, so the kept versions decide for every item
+					keptItems, err := filter.Kept()
+					if err != nil {
+						return err
+					}
+					keptVersions := make(map[string]bool, len(keptItems))
+					for _, item := range keptItems {
+						keptVersions[filter.getVersion(item)] = true
+					}
+
+					for _, item := range filter.Items() {
+						if err := fn(dist, component, packageName, arch, item, keptVersions[filter.getVersion(item)]); err != nil {
+							return err
+						}
+					}
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
 // NewPackageRetentionCollector creates a collector for *deb.Package items
 // Package grouping: by package name and architecture
 // Always uses NoMatchKeep behavior
 func NewPackageRetentionCollector(
 	retentionPolicies []RetentionPolicy,
+	pins []PinnedVersion,
 ) *GenericRetentionCollector[*deb.Package] {
 	return NewGenericRetentionCollector(
 		retentionPolicies,
+		pins,
 		func(pkg *deb.Package) (string, string, string, string) {
 			return pkg.Source, pkg.Name, pkg.Architecture, pkg.Version
 		},
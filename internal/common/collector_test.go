@@ -18,9 +18,10 @@ type item struct {
 }
 
 // newTestCollector creates a collector with standard accessor functions for item type
-func newTestCollector(policies []RetentionPolicy) *GenericRetentionCollector[item] {
+func newTestCollector(policies []RetentionPolicy, pins ...PinnedVersion) *GenericRetentionCollector[item] {
 	return NewGenericRetentionCollector(
 		policies,
+		pins,
 		func(i item) (string, string, string, string) {
 			return i.source, i.pkg, i.arch, i.version
 		},
@@ -110,6 +111,87 @@ func TestGenericRetentionCollector(t *testing.T) {
 		})
 	})
 
+	t.Run("distribution_specific_retention", func(t *testing.T) {
+		collector := newTestCollector(
+			[]RetentionPolicy{
+				{
+					RetentionRule: RetentionRule{Pattern: "*.*.*-#", Amount: []int{3}},
+					Distributions: []string{"noble"},
+				},
+				{
+					RetentionRule: RetentionRule{Pattern: "*.*.*-#", Amount: []int{1}},
+					Distributions: []string{"trixie"},
+					FromSources:   []string{"nginx"},
+				},
+			},
+		)
+
+		for _, dist := range []string{"noble", "trixie", "bookworm"} {
+			require.NoError(t, collector.Add(dist, "main", item{"nginx", "nginx", "amd64", "1.24.0-4"}))
+			require.NoError(t, collector.Add(dist, "main", item{"nginx", "nginx", "amd64", "1.24.0-3"}))
+			require.NoError(t, collector.Add(dist, "main", item{"nginx", "nginx", "amd64", "1.24.0-2"}))
+			require.NoError(t, collector.Add(dist, "main", item{"nginx", "nginx", "amd64", "1.24.0-1"}))
+		}
+		require.NoError(t, collector.Add("trixie", "main", item{"php", "php8.3", "amd64", "8.3.14-2"}))
+		require.NoError(t, collector.Add("trixie", "main", item{"php", "php8.3", "amd64", "8.3.14-1"}))
+
+		counts := make(map[string]int)
+		require.NoError(t, collector.ForEachKept(func(dist, _, packageName, _ string, _ item) error {
+			counts[dist+"/"+packageName]++
+			return nil
+		}))
+		assert.Equal(t, 3, counts["noble/nginx"], "noble keeps last 3 revisions")
+		assert.Equal(t, 1, counts["trixie/nginx"], "trixie keeps last revision of nginx")
+		assert.Equal(t, 2, counts["trixie/php8.3"], "trixie policy only applies to nginx")
+		assert.Equal(t, 4, counts["bookworm/nginx"], "no policy for bookworm keeps all")
+	})
+
+	t.Run("pinned_versions", func(t *testing.T) {
+		collector := newTestCollector(
+			[]RetentionPolicy{
+				{RetentionRule: RetentionRule{Pattern: "*.*.*-#", Amount: []int{1}}},
+			},
+			PinnedVersion{Versions: []string{"1.24.0-1", "9.9"}, FromSources: []string{"nginx"}},
+		)
+
+		require.NoError(t, collector.Add("noble", "main", item{"nginx", "nginx", "amd64", "1.24.0-3"}))
+		require.NoError(t, collector.Add("noble", "main", item{"nginx", "nginx", "amd64", "1.24.0-2"}))
+		require.NoError(t, collector.Add("noble", "main", item{"nginx", "nginx", "amd64", "1.24.0-1"}))
+		require.NoError(t, collector.Add("noble", "main", item{"php", "php8.3", "amd64", "1.24.0-3"}))
+		require.NoError(t, collector.Add("noble", "main", item{"php", "php8.3", "amd64", "1.24.0-1"}))
+
+		var versions []string
+		require.NoError(t, collector.ForEachKept(func(_, _, packageName, _ string, i item) error {
+			versions = append(versions, packageName+"="+i.version)
+			return nil
+		}))
+		assert.ElementsMatch(t, []string{"nginx=1.24.0-3", "nginx=1.24.0-1", "php8.3=1.24.0-3"}, versions,
+			"pinned nginx version is kept on top of the newest, pins of other sources don't apply")
+	})
+
+	t.Run("for_each_item", func(t *testing.T) {
+		collector := newTestCollector(
+			[]RetentionPolicy{
+				{RetentionRule: RetentionRule{Pattern: "*.*.*-#", Amount: []int{1}}},
+			},
+		)
+
+		require.NoError(t, collector.Add("noble", "main", item{"nginx", "nginx", "amd64", "1.24.0-2"}))
+		require.NoError(t, collector.Add("noble", "main", item{"nginx", "nginx", "amd64", "1.24.0-1"}))
+		require.NoError(t, collector.Add("noble", "main", item{"nginx", "nginx", "amd64", "unmatched"}))
+
+		decisions := make(map[string]bool)
+		require.NoError(t, collector.ForEachItem(func(dist, _, _, _ string, i item, kept bool) error {
+			decisions[dist+"/"+i.version] = kept
+			return nil
+		}))
+		assert.Equal(t, map[string]bool{
+			"noble/1.24.0-2":  true,
+			"noble/1.24.0-1":  false,
+			"noble/unmatched": true,
+		}, decisions, "all items are reported, unmatched versions are kept")
+	})
+
 	t.Run("grouping_independence", func(t *testing.T) {
 		t.Run("per_distribution", func(t *testing.T) {
 			collector := newTestCollector(
@@ -223,6 +305,7 @@ func TestSpecializedConstructors(t *testing.T) {
 			[]RetentionPolicy{
 				{RetentionRule: RetentionRule{Pattern: "*.#", Amount: []int{2}}},
 			},
+			[]PinnedVersion{{Versions: []string{"1.0"}, FromSources: []string{"other-src"}}},
 		)
 
 		pkg1 := &deb.Package{Name: "test-pkg", Source: "test-src", Version: "1.0", Architecture: "amd64"}
@@ -8,6 +8,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 
 	"github.com/alitto/pond/v2"
 	"github.com/dsnet/compress/bzip2"
@@ -44,16 +45,59 @@ func (f CompressionFormat) Extension() string {
 	return "." + string(f)
 }
 
+// CompressionLevels maps a compression format to its configured level.
+// A missing entry or level 0 uses the format's default level.
+type CompressionLevels map[CompressionFormat]int
+
+// xzDictCaps maps xz preset levels (1-9) to dictionary sizes, following xz-utils presets
+var xzDictCaps = [...]int{
+	1: 1 << 20,
+	2 << 20,
+	4 << 20,
+	4 << 20,
+	8 << 20,
+	8 << 20, // xz-utils default
+	16 << 20,
+	32 << 20,
+	64 << 20,
+}
+
+// ValidateCompressionLevel checks whether level is supported by the given format (0 = default)
+func ValidateCompressionLevel(format CompressionFormat, level int) error {
+	if level == 0 {
+		return nil
+	}
+
+	switch format {
+	case CompressionGzip, CompressionBzip2, CompressionXZ:
+		if level < 1 || level > 9 {
+			return fmt.Errorf("%s compression level must be between 1 and 9, got %d", format, level)
+		}
+	default:
+		return fmt.Errorf("unsupported compression format: %s", format)
+	}
+
+	return nil
+}
+
 // NewDeCompressor creates and initializes a new decompressor with the provided worker pool
-func NewDeCompressor(pool pond.ResultPool[Result]) *DeCompressor {
+// levels optionally configures the compression level per format (nil = defaults)
+func NewDeCompressor(pool pond.ResultPool[Result], levels CompressionLevels) *DeCompressor {
 	return &DeCompressor{
-		pool: pool,
+		pool:   pool,
+		levels: levels,
 	}
 }
 
 // DeCompressor handles parallel compression/decompression operations
+// Encoders are pooled per format and reused across files to avoid reallocating
+// their internal buffers and dictionaries for every index file.
 type DeCompressor struct {
-	pool pond.ResultPool[Result]
+	pool   pond.ResultPool[Result]
+	levels CompressionLevels
+
+	gzipWriters  sync.Pool // *gzip.Writer
+	bzip2Writers sync.Pool // *bzip2.Writer
 }
 
 // DeCompressResult contains the outcome of a single download job
@@ -106,7 +150,8 @@ func (d *DeCompressor) decompressSingle(sourcePath string) (*DeCompressResult, e
 	return &result, nil
 }
 
-func (d *DeCompressor) compressSingle(sourcePath string, format CompressionFormat) (*DeCompressResult, error) {
+// compressSingle compresses a file next to it, a partially written file is removed on failure
+func (d *DeCompressor) compressSingle(sourcePath string, format CompressionFormat) (_ *DeCompressResult, err error) {
 	if format == CompressionNone {
 		return nil, fmt.Errorf("compression format required")
 	}
@@ -130,25 +175,29 @@ func (d *DeCompressor) compressSingle(sourcePath string, format CompressionForma
 		if cerr := compressedFile.Close(); cerr != nil && err == nil {
 			err = cerr
 		}
+		if err != nil {
+			_ = os.Remove(destPath)
+		}
 	}()
 
-	// Get compressed writer based on format
-	writer, err := getCompressor(format, compressedFile)
+	// Get compressed writer based on format, reusing a pooled encoder where possible
+	writer, release, err := d.getCompressor(format, compressedFile)
 	if err != nil {
 		return nil, err
 	}
-	defer func() {
-		if cerr := writer.Close(); cerr != nil && err == nil {
-			err = cerr
-		}
-	}()
 
-	// Copy data with compression
-	_, err = io.Copy(writer, sourceFile)
-	if err != nil {
+	// Copy data with compression, a failed encoder is closed to free its resources but not reused
+	if _, err = io.Copy(writer, sourceFile); err != nil {
+		_ = writer.Close()
 		return nil, err
 	}
 
+	// Close flushes the remaining data, only then the encoder can be reused
+	if err = writer.Close(); err != nil {
+		return nil, err
+	}
+	release()
+
 	result := DeCompressResult(destPath)
 	return &result, nil
 }
@@ -174,12 +223,21 @@ func (d *DeCompressor) Decompress(ctx context.Context, sourcePaths ...string) po
 // Returns a task group that can be waited on. Call Wait() to get results and any error.
 // This method is thread-safe and can be called concurrently.
 func (d *DeCompressor) Compress(ctx context.Context, sourcePath string, formats ...CompressionFormat) pond.ResultTaskGroup[Result] {
+	return d.CompressFiles(ctx, []string{sourcePath}, formats...)
+}
+
+// CompressFiles compresses multiple files into multiple formats in a single task group
+// Batching all files of a distribution lets the pooled encoders be reused across them.
+// Results are ordered by source path first, then by format.
+func (d *DeCompressor) CompressFiles(ctx context.Context, sourcePaths []string, formats ...CompressionFormat) pond.ResultTaskGroup[Result] {
 	group := d.pool.NewGroupContext(ctx)
 
-	for _, format := range formats {
-		group.SubmitErr(func() (Result, error) {
-			return d.compressSingle(sourcePath, format)
-		})
+	for _, sourcePath := range sourcePaths {
+		for _, format := range formats {
+			group.SubmitErr(func() (Result, error) {
+				return d.compressSingle(sourcePath, format)
+			})
+		}
 	}
 
 	return group
@@ -199,16 +257,53 @@ func getDecompressor(format CompressionFormat, r io.Reader) (io.Reader, error) {
 	}
 }
 
-// getCompressor returns a WriteCloser for the given compression format
-func getCompressor(format CompressionFormat, w io.Writer) (io.WriteCloser, error) {
+// getCompressor returns a WriteCloser for the given compression format with the configured level.
+// The returned release function hands the encoder back for reuse and must only be called after
+// a successful Close.
+func (d *DeCompressor) getCompressor(format CompressionFormat, w io.Writer) (io.WriteCloser, func(), error) {
+	level := d.levels[format]
+	if err := ValidateCompressionLevel(format, level); err != nil {
+		return nil, nil, err
+	}
+
 	switch format {
 	case CompressionGzip:
-		return gzip.NewWriter(w), nil
+		if zw, ok := d.gzipWriters.Get().(*gzip.Writer); ok {
+			zw.Reset(w)
+			return zw, func() { d.gzipWriters.Put(zw) }, nil
+		}
+		if level == 0 {
+			level = gzip.DefaultCompression
+		}
+		zw, err := gzip.NewWriterLevel(w, level)
+		if err != nil {
+			return nil, nil, err
+		}
+		return zw, func() { d.gzipWriters.Put(zw) }, nil
 	case CompressionBzip2:
-		return bzip2.NewWriter(w, nil)
+		if zw, ok := d.bzip2Writers.Get().(*bzip2.Writer); ok {
+			if err := zw.Reset(w); err != nil {
+				return nil, nil, err
+			}
+			return zw, func() { d.bzip2Writers.Put(zw) }, nil
+		}
+		zw, err := bzip2.NewWriter(w, &bzip2.WriterConfig{Level: level})
+		if err != nil {
+			return nil, nil, err
+		}
+		return zw, func() { d.bzip2Writers.Put(zw) }, nil
 	case CompressionXZ:
-		return xz.NewWriter(w)
+		// xz writers can't be reset, but the dictionary capacity follows the configured preset
+		cfg := xz.WriterConfig{}
+		if level > 0 {
+			cfg.DictCap = xzDictCaps[level]
+		}
+		zw, err := cfg.NewWriter(w)
+		if err != nil {
+			return nil, nil, err
+		}
+		return zw, func() {}, nil
 	default:
-		return nil, fmt.Errorf("unsupported compression format: %s", format)
+		return nil, nil, fmt.Errorf("unsupported compression format: %s", format)
 	}
 }
@@ -1,9 +1,16 @@
 package common
 
 import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/alitto/pond/v2"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestDetectCompressionFormat(t *testing.T) {
@@ -102,3 +109,118 @@ func TestCompressionFormat_Extension(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateCompressionLevel(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  CompressionFormat
+		level   int
+		wantErr bool
+	}{
+		{name: "default level", format: CompressionGzip, level: 0},
+		{name: "gzip min", format: CompressionGzip, level: 1},
+		{name: "bzip2 max", format: CompressionBzip2, level: 9},
+		{name: "xz mid", format: CompressionXZ, level: 6},
+		{name: "too high", format: CompressionGzip, level: 10, wantErr: true},
+		{name: "negative", format: CompressionXZ, level: -1, wantErr: true},
+		{name: "unsupported format", format: CompressionNone, level: 5, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateCompressionLevel(tt.format, tt.level)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestDeCompressor_CompressFiles(t *testing.T) {
+	pool := pond.NewResultPool[Result](4)
+	defer pool.StopAndWait()
+
+	levels := CompressionLevels{
+		CompressionGzip:  9,
+		CompressionBzip2: 1,
+		CompressionXZ:    1,
+	}
+	d := NewDeCompressor(pool, levels)
+
+	// Several files so pooled encoders get reused across tasks
+	dir := t.TempDir()
+	contents := make(map[string][]byte)
+	var paths []string
+	for i := range 5 {
+		path := filepath.Join(dir, fmt.Sprintf("Packages-%d", i))
+		data := []byte(strings.Repeat(fmt.Sprintf("Package: test-%d\nVersion: 1.0\n\n", i), 100))
+		require.NoError(t, os.WriteFile(path, data, 0644))
+		contents[path] = data
+		paths = append(paths, path)
+	}
+
+	formats := []CompressionFormat{CompressionGzip, CompressionBzip2, CompressionXZ}
+	results, err := d.CompressFiles(context.Background(), paths, formats...).Wait()
+	require.NoError(t, err)
+	assert.Len(t, results, len(paths)*len(formats))
+
+	// Round-trip every compressed file and compare against the original
+	for _, path := range paths {
+		for _, format := range formats {
+			compressed := path + format.Extension()
+			require.FileExists(t, compressed)
+
+			decompressedPath := filepath.Join(t.TempDir(), filepath.Base(path))
+			require.NoError(t, os.Rename(compressed, decompressedPath+format.Extension()))
+
+			_, err := d.Decompress(context.Background(), decompressedPath+format.Extension()).Wait()
+			require.NoError(t, err)
+
+			got, err := os.ReadFile(decompressedPath)
+			require.NoError(t, err)
+			assert.Equal(t, contents[path], got, "round-trip of %s", compressed)
+		}
+	}
+}
+
+func TestDeCompressor_CompressInvalidLevel(t *testing.T) {
+	pool := pond.NewResultPool[Result](1)
+	defer pool.StopAndWait()
+
+	d := NewDeCompressor(pool, CompressionLevels{CompressionGzip: 42})
+
+	path := filepath.Join(t.TempDir(), "Packages")
+	require.NoError(t, os.WriteFile(path, []byte("Package: test\n"), 0644))
+
+	_, err := d.Compress(context.Background(), path, CompressionGzip).Wait()
+	assert.Error(t, err)
+	assert.NoFileExists(t, path+CompressionGzip.Extension())
+}
+
+func TestDeCompressor_CompressReadError(t *testing.T) {
+	pool := pond.NewResultPool[Result](1)
+	defer pool.StopAndWait()
+
+	d := NewDeCompressor(pool, CompressionLevels{})
+	formats := []CompressionFormat{CompressionGzip, CompressionBzip2, CompressionXZ}
+
+	// A directory opens fine but fails reading, no partial file is left behind
+	dir := filepath.Join(t.TempDir(), "Packages")
+	require.NoError(t, os.Mkdir(dir, 0755))
+	for _, format := range formats {
+		_, err := d.Compress(context.Background(), dir, format).Wait()
+		assert.Error(t, err)
+		assert.NoFileExists(t, dir+format.Extension())
+	}
+
+	// The failed encoders are not handed out again
+	path := filepath.Join(t.TempDir(), "Packages")
+	require.NoError(t, os.WriteFile(path, []byte("Package: test\n"), 0644))
+	_, err := d.Compress(context.Background(), path, formats...).Wait()
+	require.NoError(t, err)
+	for _, format := range formats {
+		assert.FileExists(t, path+format.Extension())
+	}
+}
@@ -0,0 +1,99 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// ErrInsufficientSpace is returned if a filesystem lacks the space required for an operation
+var ErrInsufficientSpace = errors.New("insufficient disk space")
+
+// CheckFreeSpace verifies that the filesystem of path has required bytes available on top of reserve
+// path does not need to exist yet, the nearest existing parent is checked
+func CheckFreeSpace(path string, required, reserve uint64) error {
+	existing := nearestExistingPath(path)
+
+	free, err := freeSpace(existing)
+	if err != nil {
+		return fmt.Errorf("failed to determine free space of %s: %w", existing, err)
+	}
+
+	if free < required+reserve {
+		return fmt.Errorf("%w on %s: %s required plus %s reserve, %s available",
+			ErrInsufficientSpace, existing, FormatSize(required), FormatSize(reserve), FormatSize(free))
+	}
+
+	return nil
+}
+
+// ExclusiveSize sums the sizes of regular files below dir that are not hardlinked elsewhere
+// Hardlinked files share their data with other paths (e.g., trusted storage) and need no extra space
+func ExclusiveSize(dir string) (uint64, error) {
+	var total uint64
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if linkCount(info) <= 1 {
+			total += uint64(info.Size())
+		}
+		return nil
+	})
+	return total, err
+}
+
+// FormatSize formats a size in bytes to a human-readable string
+func FormatSize(bytes uint64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := uint64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
+
+// nearestExistingPath returns path or its nearest existing parent
+func nearestExistingPath(path string) string {
+	for {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return path
+		}
+		path = parent
+	}
+}
+
+// freeSpace returns the bytes available to unprivileged users on the filesystem of path
+func freeSpace(path string) (uint64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, err
+	}
+	return uint64(stat.Bavail) * uint64(stat.Bsize), nil
+}
+
+// linkCount returns the number of hardlinks of a file
+func linkCount(info fs.FileInfo) uint64 {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint64(stat.Nlink)
+	}
+	return 1
+}
@@ -0,0 +1,56 @@
+package common
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckFreeSpace(t *testing.T) {
+	dir := t.TempDir()
+
+	// Missing paths are checked on their nearest existing parent
+	assert.NoError(t, CheckFreeSpace(filepath.Join(dir, "missing", "sub"), 1, 0))
+
+	err := CheckFreeSpace(dir, math.MaxUint64/2, math.MaxUint64/4)
+	assert.ErrorIs(t, err, ErrInsufficientSpace)
+}
+
+func TestExclusiveSize(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "sub"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a"), make([]byte, 100), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "sub", "b"), make([]byte, 50), 0644))
+
+	// Hardlinked files share their data and are not counted
+	external := filepath.Join(t.TempDir(), "linked")
+	require.NoError(t, os.WriteFile(external, make([]byte, 1000), 0644))
+	require.NoError(t, os.Link(external, filepath.Join(dir, "sub", "linked")))
+
+	size, err := ExclusiveSize(dir)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(150), size)
+}
+
+func TestFormatSize(t *testing.T) {
+	tests := []struct {
+		bytes uint64
+		want  string
+	}{
+		{0, "0 B"},
+		{1023, "1023 B"},
+		{1024, "1.0 KiB"},
+		{1536 * 1024, "1.5 MiB"},
+		{5 * 1024 * 1024 * 1024, "5.0 GiB"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			assert.Equal(t, tt.want, FormatSize(tt.bytes))
+		})
+	}
+}
@@ -4,15 +4,18 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
 	"path/filepath"
 	"sync"
+	"sync/atomic"
 
 	"github.com/alitto/pond/v2"
 	"github.com/cavaliergopher/grab/v3"
+	"golang.org/x/time/rate"
 )
 
 // NewDownloader creates and initializes a new download manager with the provided worker pool
@@ -37,6 +40,93 @@ type Downloader struct {
 
 	// Download deduplication: tracks in-flight downloads by destination path
 	inflight sync.Map // map[string]*downloadWaiter for concurrent access
+
+	minFree    uint64       // Bytes to keep free on the target filesystem, 0 = unchecked
+	pending    atomic.Int64 // Bytes of the downloads in progress, reserved before they are written
+	downloaded atomic.Int64 // Bytes downloaded since creation
+
+	limiter      *rate.Limiter // Bandwidth limit across all downloads, nil = unlimited
+	hostLimit    int64         // Bandwidth limit per host in bytes per second, 0 = unlimited
+	hostLimiters sync.Map      // Bandwidth limiters per host (string -> *rate.Limiter)
+}
+
+// downloadBufferSize is the size of the chunks downloads are written and rate limited in
+const downloadBufferSize = 32 * 1024
+
+// partialSuffix is appended to the hidden file a download is written to until it's complete
+// Interrupted downloads with a checksum resume from it, the destination is only replaced once complete
+const partialSuffix = ".part"
+
+// SetMinFree makes downloads fail before writing if the target filesystem would have less than bytes left
+// once all downloads in progress are complete
+func (m *Downloader) SetMinFree(bytes uint64) {
+	m.minFree = bytes
+}
+
+// reserve adds size to the bytes of the downloads in progress and checks the filesystem of dir fits them all
+// The returned function releases the reservation once the download is written or failed
+// Parts already written by the downloads in progress are counted twice, erring on the safe side
+func (m *Downloader) reserve(dir string, size int64) (func(), error) {
+	total := m.pending.Add(size)
+	release := func() { m.pending.Add(-size) }
+	if err := CheckFreeSpace(dir, uint64(total), m.minFree); err != nil {
+		release()
+		return nil, err
+	}
+	return release, nil
+}
+
+// SetRateLimit limits the bandwidth of all downloads together and of the downloads from each host in bytes per second, 0 = unlimited
+func (m *Downloader) SetRateLimit(total, perHost int64) {
+	m.limiter = nil
+	if total > 0 {
+		m.limiter = newByteLimiter(total)
+	}
+	m.hostLimit = max(perHost, 0)
+}
+
+// newByteLimiter returns a limiter of bytes per second allowing at least one buffer at once
+func newByteLimiter(bytesPerSecond int64) *rate.Limiter {
+	return rate.NewLimiter(rate.Limit(bytesPerSecond), int(max(bytesPerSecond, downloadBufferSize)))
+}
+
+// rateLimiter returns the limiter of a download from host, nil if unlimited
+func (m *Downloader) rateLimiter(host string) grab.RateLimiter {
+	var limiters chainedLimiter
+	if m.limiter != nil {
+		limiters = append(limiters, m.limiter)
+	}
+	if m.hostLimit > 0 {
+		limiter, _ := m.hostLimiters.LoadOrStore(host, newByteLimiter(m.hostLimit))
+		limiters = append(limiters, limiter.(*rate.Limiter))
+	}
+	if len(limiters) == 0 {
+		return nil
+	}
+	return limiters
+}
+
+// chainedLimiter waits for all of its limiters
+type chainedLimiter []*rate.Limiter
+
+// WaitN blocks until all limiters allow n bytes
+func (c chainedLimiter) WaitN(ctx context.Context, n int) error {
+	for _, limiter := range c {
+		if err := limiter.WaitN(ctx, n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// partialPath returns the path a download to destination is written to until it's complete
+func partialPath(destination string) string {
+	return filepath.Join(filepath.Dir(destination), "."+filepath.Base(destination)+partialSuffix)
+}
+
+// Downloaded returns the number of bytes downloaded since creation
+func (m *Downloader) Downloaded() int64 {
+	return m.downloaded.Load()
 }
 
 // downloadWaiter allows multiple goroutines to wait for the same download
@@ -65,15 +155,50 @@ func (d *DownloadResult) Destination() string {
 	return d.DownloadRequest.Destination
 }
 
+// download downloads a request into a partial file renamed to the destination once complete
+// A partial file left by an interrupted download is resumed with an HTTP range request if the
+// download has a checksum to verify the result, otherwise the download starts over
+// Replacing the destination by rename also never modifies a previous file hardlinked into trusted storage
 func (m *Downloader) download(ctx context.Context, req *DownloadRequest) (*DownloadResult, error) {
+	resume := req.Checksum != ""
+	if _, err := os.Stat(partialPath(req.Destination)); err != nil {
+		resume = false
+	}
+
+	result, err := m.downloadPartial(ctx, req, resume)
+	if resume && (errors.Is(err, grab.ErrBadLength) || errors.Is(err, grab.ErrBadChecksum)) {
+		// The partial file is from another version of the file
+		slog.Debug("Resumed download doesn't match, restarting", "file", filepath.Base(req.Destination), "error", err)
+		result, err = m.downloadPartial(ctx, req, false)
+	}
+	return result, err
+}
+
+// downloadPartial downloads a request into its partial file, resuming it if resume is set
+func (m *Downloader) downloadPartial(ctx context.Context, req *DownloadRequest, resume bool) (*DownloadResult, error) {
+	partial := partialPath(req.Destination)
+
+	// Bytes already downloaded by a previous attempt, a partial file not resumed is never taken as complete
+	var resumed int64
+	if resume {
+		if info, err := os.Stat(partial); err == nil {
+			resumed = info.Size()
+		}
+	} else if err := os.Remove(partial); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
 	// Create grab request
-	grabReq, err := grab.NewRequest(req.Destination, req.URL)
+	grabReq, err := grab.NewRequest(partial, req.URL)
 	if err != nil {
 		return nil, err
 	}
 
 	// Apply context to grab request
 	grabReq = grabReq.WithContext(ctx)
+	grabReq.NoResume = !resume
+	grabReq.BufferSize = downloadBufferSize
+	grabReq.RateLimiter = m.rateLimiter(grabReq.URL().Host)
 
 	// Configure checksum verification if provided
 	if req.Checksum != "" {
@@ -86,18 +211,48 @@ func (m *Downloader) download(ctx context.Context, req *DownloadRequest) (*Downl
 		grabReq.SetChecksum(sha256.New(), expectedSum, true)
 	}
 
+	// Fail early if the file does not fit next to the other downloads in progress instead of running out of space halfway
+	var release func()
+	if m.minFree > 0 {
+		grabReq.BeforeCopy = func(resp *grab.Response) error {
+			remaining := resp.Size()
+			if resp.DidResume {
+				remaining -= resumed
+			}
+			if remaining <= 0 {
+				return nil
+			}
+			var err error
+			release, err = m.reserve(filepath.Dir(req.Destination), remaining)
+			return err
+		}
+	}
+
 	// Start download
 	resp := m.client.Do(grabReq)
 
 	// Wait for completion
 	<-resp.Done
+	if release != nil {
+		release()
+	}
 
 	if resp.Err() != nil {
 		return nil, fmt.Errorf("%s: %w", filepath.Base(req.Destination), resp.Err())
 	}
 
+	if err := os.Rename(partial, req.Destination); err != nil {
+		return nil, fmt.Errorf("failed to complete download of %s: %w", filepath.Base(req.Destination), err)
+	}
+
 	// Log successful download
+	downloaded := resp.BytesComplete()
+	if resp.DidResume {
+		downloaded -= resumed
+		slog.Debug("Resumed download", "file", filepath.Base(req.Destination), "resumed", resumed)
+	}
 	slog.Debug("Downloaded", "file", filepath.Base(req.Destination), "bytes", resp.Size())
+	m.downloaded.Add(downloaded)
 
 	return &DownloadResult{
 		DownloadRequest: req,
@@ -0,0 +1,158 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alitto/pond/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDownloader_Resume(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 10000)
+	sum := sha256.Sum256(content)
+	checksum := hex.EncodeToString(sum[:])
+
+	// Serves content with range support and counts the bytes sent
+	var served atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			served.Add(int64(len(content)))
+			if start, err := strconv.ParseInt(strings.TrimSuffix(strings.TrimPrefix(r.Header.Get("Range"), "bytes="), "-"), 10, 64); err == nil {
+				served.Add(-start)
+			}
+		}
+		http.ServeContent(w, r, "file.deb", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name       string
+		partial    []byte // Content of the partial file left by a previous attempt, nil = none
+		checksum   string
+		wantServed int64
+	}{
+		{name: "no partial", checksum: checksum, wantServed: int64(len(content))},
+		{name: "resumes partial", partial: content[:40000], checksum: checksum, wantServed: int64(len(content)) - 40000},
+		{name: "restarts mismatching partial", partial: bytes.Repeat([]byte("x"), 40000), checksum: checksum, wantServed: int64(len(content)) + int64(len(content)) - 40000},
+		{name: "restarts without checksum", partial: content[:40000], wantServed: int64(len(content))},
+		{name: "restarts too large partial", partial: append(bytes.Clone(content), 'x'), checksum: checksum, wantServed: int64(len(content))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool := pond.NewResultPool[Result](2)
+			defer pool.StopAndWait()
+			downloader := NewDownloader(pool, http.DefaultClient, nil)
+
+			destination := filepath.Join(t.TempDir(), "file.deb")
+			if tt.partial != nil {
+				require.NoError(t, os.WriteFile(partialPath(destination), tt.partial, 0644))
+			}
+
+			served.Store(0)
+			_, err := downloader.Download(context.Background(), &DownloadRequest{
+				URL:         server.URL + "/file.deb",
+				Destination: destination,
+				Checksum:    tt.checksum,
+			}).Wait()
+			require.NoError(t, err)
+
+			data, err := os.ReadFile(destination)
+			require.NoError(t, err)
+			assert.Equal(t, content, data)
+			assert.NoFileExists(t, partialPath(destination))
+			assert.Equal(t, tt.wantServed, served.Load())
+		})
+	}
+}
+
+func TestDownloader_ReplacesHardlinkedDestination(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("new content"))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	destination := filepath.Join(dir, "Release")
+	trusted := filepath.Join(dir, "trusted-Release")
+	require.NoError(t, os.WriteFile(trusted, []byte("old"), 0644))
+	require.NoError(t, os.Link(trusted, destination))
+
+	pool := pond.NewResultPool[Result](1)
+	defer pool.StopAndWait()
+	_, err := NewDownloader(pool, http.DefaultClient, nil).Download(context.Background(), &DownloadRequest{
+		URL:         server.URL + "/Release",
+		Destination: destination,
+	}).Wait()
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(destination)
+	require.NoError(t, err)
+	assert.Equal(t, "new content", string(data))
+
+	// The file hardlinked into trusted storage is untouched
+	data, err = os.ReadFile(trusted)
+	require.NoError(t, err)
+	assert.Equal(t, "old", string(data))
+}
+
+func TestDownloader_RateLimit(t *testing.T) {
+	content := bytes.Repeat([]byte("x"), 3*downloadBufferSize)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "file", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	pool := pond.NewResultPool[Result](1)
+	defer pool.StopAndWait()
+	downloader := NewDownloader(pool, http.DefaultClient, nil)
+	downloader.SetRateLimit(0, 2*downloadBufferSize)
+
+	// The burst covers the first two buffers, the third waits for half a second
+	start := time.Now()
+	_, err := downloader.Download(context.Background(), &DownloadRequest{
+		URL:         server.URL + "/file",
+		Destination: filepath.Join(t.TempDir(), "file"),
+	}).Wait()
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, time.Since(start), 400*time.Millisecond)
+}
+
+func TestDownloader_Reserve(t *testing.T) {
+	dir := t.TempDir()
+	free, err := freeSpace(dir)
+	require.NoError(t, err)
+	require.Greater(t, free, uint64(1<<20))
+
+	downloader := NewDownloader(nil, http.DefaultClient, nil)
+	downloader.SetMinFree(4096)
+
+	// A little more than half of the free space fits once, not for a second download in progress
+	// The check leaves room for the free space to change a little while testing
+	half := int64(free/2) + 1<<18
+	release, err := downloader.reserve(dir, half)
+	require.NoError(t, err)
+	_, err = downloader.reserve(dir, half)
+	assert.ErrorIs(t, err, ErrInsufficientSpace)
+	assert.Equal(t, half, downloader.pending.Load())
+
+	// Once the first download is written its reservation is released
+	release()
+	assert.Zero(t, downloader.pending.Load())
+	release, err = downloader.reserve(dir, half)
+	require.NoError(t, err)
+	release()
+}
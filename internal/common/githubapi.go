@@ -0,0 +1,275 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// GitHubAPICacheDir is the directory in the downloads directory caching GitHub API responses
+const GitHubAPICacheDir = "github-api"
+
+const (
+	// maxGitHubRetries is how often a request rejected by a rate limit is retried
+	maxGitHubRetries = 3
+	// defaultGitHubRetryAfter is the wait after a secondary rate limit without Retry-After header
+	defaultGitHubRetryAfter = time.Minute
+	// gitHubResetBuffer is waited beyond the announced reset, clocks of client and GitHub differ slightly
+	gitHubResetBuffer = time.Second
+)
+
+// cachedGitHubPaths matches the API paths of which responses are cached, the release lists of repositories
+var cachedGitHubPaths = regexp.MustCompile(`/repos/[^/]+/[^/]+/releases$`)
+
+// cachedGitHubHeaders are the response headers kept in the cache, Link carries the pagination
+var cachedGitHubHeaders = []string{"Content-Type", "Link", "ETag"}
+
+// gitHubCacheEntry is a cached API response
+type gitHubCacheEntry struct {
+	Header http.Header `json:"header"`
+	Body   []byte      `json:"body"`
+}
+
+// GitHubTransport wraps an http.RoundTripper for the GitHub API to stay within its rate limits
+// Requests wait for the reset once the quota is used up and rate limited requests are retried after
+// the announced time, instead of failing. Release lists are cached with their ETag and revalidated with
+// conditional requests, which GitHub doesn't count against the quota if they are answered with 304
+type GitHubTransport struct {
+	Base     http.RoundTripper
+	CacheDir string // Directory of cached responses, empty disables caching
+
+	mu        sync.Mutex
+	remaining int // Requests left in the current window, -1 if unknown
+	reset     time.Time
+}
+
+// NewGitHubTransport creates a transport sending requests with base and caching responses in cacheDir
+func NewGitHubTransport(base http.RoundTripper, cacheDir string) *GitHubTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &GitHubTransport{Base: base, CacheDir: cacheDir, remaining: -1}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *GitHubTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	cachePath := t.cachePath(req)
+	var cached *gitHubCacheEntry
+	if cachePath != "" {
+		cached = loadGitHubCacheEntry(cachePath)
+		if etag := cached.etag(); etag != "" {
+			req = req.Clone(req.Context())
+			req.Header.Set("If-None-Match", etag)
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		if err := t.waitForReset(req.Context()); err != nil {
+			return nil, err
+		}
+
+		resp, err := t.Base.RoundTrip(req)
+		if err != nil {
+			return nil, err
+		}
+		t.update(resp.Header)
+
+		wait, limited := rateLimited(resp)
+		if !limited || attempt >= maxGitHubRetries || (req.Body != nil && req.GetBody == nil) {
+			if cachePath != "" {
+				return t.cache(cachePath, cached, resp)
+			}
+			return resp, nil
+		}
+		_ = resp.Body.Close()
+
+		slog.Warn("GitHub API rate limit reached, retrying", "url", req.URL.Path, "wait", wait.Round(time.Second), "attempt", attempt+1)
+		if err := sleepUntil(req.Context(), time.Now().Add(wait)); err != nil {
+			return nil, err
+		}
+
+		// Replay the request body for the next attempt
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+}
+
+// waitForReset waits until the rate limit resets if no requests are left
+func (t *GitHubTransport) waitForReset(ctx context.Context) error {
+	t.mu.Lock()
+	exhausted := t.remaining == 0 && time.Now().Before(t.reset)
+	reset := t.reset
+	t.mu.Unlock()
+
+	if !exhausted {
+		return nil
+	}
+	slog.Warn("GitHub API rate limit exhausted, waiting for reset", "reset", FormatDisplayTime(reset))
+	return sleepUntil(ctx, reset.Add(gitHubResetBuffer))
+}
+
+// update remembers the rate limit state of the X-RateLimit headers of a response
+func (t *GitHubTransport) update(header http.Header) {
+	remaining, err := strconv.Atoi(header.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		return
+	}
+	reset, err := strconv.ParseInt(header.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return
+	}
+
+	t.mu.Lock()
+	t.remaining = remaining
+	t.reset = time.Unix(reset, 0)
+	t.mu.Unlock()
+}
+
+// rateLimited returns how long to wait if the response rejected the request because of a rate limit
+func rateLimited(resp *http.Response) (time.Duration, bool) {
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return 0, false
+	}
+
+	// Secondary rate limits announce when to retry
+	if header := resp.Header.Get("Retry-After"); header != "" {
+		if seconds, err := strconv.Atoi(header); err == nil && seconds >= 0 {
+			return time.Duration(seconds) * time.Second, true
+		}
+		return defaultGitHubRetryAfter, true
+	}
+
+	// Primary rate limit, wait for the reset of the window
+	if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+		reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+		if err != nil {
+			return defaultGitHubRetryAfter, true
+		}
+		return max(0, time.Until(time.Unix(reset, 0))) + gitHubResetBuffer, true
+	}
+
+	// Forbidden for other reasons, like missing permissions
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return defaultGitHubRetryAfter, true
+	}
+	return 0, false
+}
+
+// cachePath returns the cache file of a request, empty if its response is not cached
+func (t *GitHubTransport) cachePath(req *http.Request) string {
+	if t.CacheDir == "" || req.Method != http.MethodGet || !cachedGitHubPaths.MatchString(req.URL.Path) {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(req.URL.String()))
+	key := hex.EncodeToString(sum[:])
+	return filepath.Join(t.CacheDir, key[:2], key)
+}
+
+// cache answers a 304 response with the cached response and stores successful responses with an ETag
+func (t *GitHubTransport) cache(path string, cached *gitHubCacheEntry, resp *http.Response) (*http.Response, error) {
+	switch {
+	case resp.StatusCode == http.StatusNotModified && cached != nil:
+		_ = resp.Body.Close()
+
+		header := cached.Header.Clone()
+		for name, values := range resp.Header {
+			if _, ok := header[name]; !ok {
+				header[name] = values
+			}
+		}
+		// go-github ignores the rate limit of responses marked as cached
+		header.Set("X-From-Cache", "1")
+
+		return &http.Response{
+			Status:        "200 OK",
+			StatusCode:    http.StatusOK,
+			Proto:         resp.Proto,
+			ProtoMajor:    resp.ProtoMajor,
+			ProtoMinor:    resp.ProtoMinor,
+			Header:        header,
+			Body:          io.NopCloser(bytes.NewReader(cached.Body)),
+			ContentLength: int64(len(cached.Body)),
+			Request:       resp.Request,
+		}, nil
+
+	case resp.StatusCode == http.StatusOK && resp.Header.Get("ETag") != "":
+		body, err := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+
+		entry := &gitHubCacheEntry{Header: make(http.Header), Body: body}
+		for _, name := range cachedGitHubHeaders {
+			if value := resp.Header.Get(name); value != "" {
+				entry.Header.Set(name, value)
+			}
+		}
+		if err := entry.save(path); err != nil {
+			slog.Debug("Failed to cache GitHub API response", "url", resp.Request.URL.Path, "error", err)
+		}
+	}
+	return resp, nil
+}
+
+// loadGitHubCacheEntry reads a cached response, nil if there is none
+func loadGitHubCacheEntry(path string) *gitHubCacheEntry {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var entry gitHubCacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil
+	}
+	return &entry
+}
+
+// etag returns the ETag of the cached response, empty without entry
+func (e *gitHubCacheEntry) etag() string {
+	if e == nil {
+		return ""
+	}
+	return e.Header.Get("ETag")
+}
+
+// save writes the entry to path
+func (e *gitHubCacheEntry) save(path string) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	// Concurrent runs may refresh the same entry
+	return WriteFileAtomic(path, data, 0644)
+}
+
+// sleepUntil waits until the given time or until the context is done
+func sleepUntil(ctx context.Context, until time.Time) error {
+	timer := time.NewTimer(time.Until(until))
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
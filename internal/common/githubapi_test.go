@@ -0,0 +1,142 @@
+package common
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGitHubTransport_Cache(t *testing.T) {
+	var requests, notModified atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Link", `<https://api.github.com/repositories/1/releases?page=2>; rel="next"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		_, _ = w.Write([]byte(`[{"tag_name":"v1.0.0"}]`))
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: NewGitHubTransport(nil, t.TempDir())}
+
+	tests := []struct {
+		name            string
+		path            string
+		wantNotModified int32
+	}{
+		{name: "first request fills cache", path: "/repos/owner/repo/releases", wantNotModified: 0},
+		{name: "second request revalidates", path: "/repos/owner/repo/releases", wantNotModified: 1},
+		{name: "other endpoints are not cached", path: "/repos/owner/repo/releases/tags/v1.0.0", wantNotModified: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := client.Get(server.URL + tt.path)
+			require.NoError(t, err)
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			_ = resp.Body.Close()
+
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Equal(t, `[{"tag_name":"v1.0.0"}]`, string(body))
+			assert.Contains(t, resp.Header.Get("Link"), `rel="next"`)
+			assert.Equal(t, tt.wantNotModified, notModified.Load())
+		})
+	}
+	assert.Equal(t, int32(3), requests.Load())
+}
+
+func TestGitHubTransport_RateLimit(t *testing.T) {
+	tests := []struct {
+		name         string
+		limited      func(w http.ResponseWriter)
+		wantStatus   int
+		wantRequests int32
+	}{
+		{
+			name: "secondary rate limit",
+			limited: func(w http.ResponseWriter) {
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusForbidden)
+			},
+			wantStatus:   http.StatusOK,
+			wantRequests: 2,
+		},
+		{
+			name: "primary rate limit",
+			limited: func(w http.ResponseWriter) {
+				w.Header().Set("X-RateLimit-Remaining", "0")
+				w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Unix(), 10))
+				w.WriteHeader(http.StatusForbidden)
+			},
+			wantStatus:   http.StatusOK,
+			wantRequests: 2,
+		},
+		{
+			name: "forbidden without rate limit",
+			limited: func(w http.ResponseWriter) {
+				w.WriteHeader(http.StatusForbidden)
+			},
+			wantStatus:   http.StatusForbidden,
+			wantRequests: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if requests.Add(1) == 1 {
+					tt.limited(w)
+					return
+				}
+				_, _ = w.Write([]byte("[]"))
+			}))
+			defer server.Close()
+
+			client := &http.Client{Transport: NewGitHubTransport(nil, "")}
+			resp, err := client.Get(server.URL + "/repos/owner/repo/releases")
+			require.NoError(t, err)
+			_ = resp.Body.Close()
+
+			assert.Equal(t, tt.wantStatus, resp.StatusCode)
+			assert.Equal(t, tt.wantRequests, requests.Load())
+		})
+	}
+}
+
+func TestGitHubTransport_WaitsForReset(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		_, _ = w.Write([]byte("[]"))
+	}))
+	defer server.Close()
+
+	transport := NewGitHubTransport(nil, "")
+	transport.update(http.Header{
+		"X-Ratelimit-Remaining": []string{"0"},
+		"X-Ratelimit-Reset":     []string{strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)},
+	})
+
+	// The quota is used up, the request waits until the context ends without reaching the server
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/repos/owner/repo/releases", nil)
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = (&http.Client{Transport: transport}).Do(req.WithContext(ctx))
+	assert.Error(t, err)
+	assert.Equal(t, int32(0), requests.Load())
+}
@@ -0,0 +1,367 @@
+package common
+
+import (
+	"bufio"
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/dionysius/aarg/debext"
+	"gopkg.in/yaml.v3"
+	_ "modernc.org/sqlite" // Registers the pure Go "sqlite" driver of the SQLite backend
+)
+
+// Metadata store backends
+const (
+	MetadataBackendFiles  = "files"
+	MetadataBackendSQLite = "sqlite"
+)
+
+// MetadataBackends are the supported backends of the metadata store
+var MetadataBackends = []string{MetadataBackendFiles, MetadataBackendSQLite}
+
+// sqliteDriver is the database/sql driver name the SQLite backend opens its database with
+const sqliteDriver = "sqlite"
+
+// ErrMetadataBackendUnavailable is returned if the selected backend is not built into the binary
+var ErrMetadataBackendUnavailable = errors.New("metadata store backend not available in this build")
+
+// MetadataStore persists metadata across runs: parsed control files and contents of packages, the redirect and
+// signer maps of feeds, the checksums of downloaded files and the upload state of publishes
+// Values are grouped in buckets, keys are unique within a bucket. Implementations must be safe for concurrent use
+// The composed repository state stays in its build, it is published and pruned with it
+type MetadataStore interface {
+	// Load returns the value of key in bucket, if present. A missing key is not an error
+	Load(bucket, key string) ([]byte, bool, error)
+	// Store saves the value of key in bucket, replacing a previous value
+	Store(bucket, key string, value []byte) error
+	// Delete removes key from bucket, a missing key is not an error
+	Delete(bucket, key string) error
+	// Close releases the resources of the store
+	Close() error
+}
+
+// OpenMetadataStore opens the metadata store of backend
+// The files backend stores below dir, the SQLite backend in the database at path
+func OpenMetadataStore(backend, dir, path string) (MetadataStore, error) {
+	switch backend {
+	case "", MetadataBackendFiles:
+		return NewFileMetadataStore(dir), nil
+	case MetadataBackendSQLite:
+		return OpenSQLMetadataStore(sqliteDriver, path)
+	default:
+		return nil, fmt.Errorf("unknown metadata store backend %q, valid backends: %v", backend, MetadataBackends)
+	}
+}
+
+// FileMetadataStore stores every value in its own file
+// Layout: {dir}/{bucket}/{key[:2]}/{key}
+type FileMetadataStore struct {
+	dir string
+}
+
+// NewFileMetadataStore creates a store persisting values below dir
+func NewFileMetadataStore(dir string) *FileMetadataStore {
+	return &FileMetadataStore{dir: dir}
+}
+
+// path returns the file path of key in bucket
+func (s *FileMetadataStore) path(bucket, key string) string {
+	prefix := key
+	if len(prefix) > 2 {
+		prefix = prefix[:2]
+	}
+	return filepath.Join(s.dir, bucket, prefix, key)
+}
+
+// Load returns the value of key in bucket from disk
+func (s *FileMetadataStore) Load(bucket, key string) ([]byte, bool, error) {
+	if !validMetadataKey(key) {
+		return nil, false, nil
+	}
+	data, err := os.ReadFile(s.path(bucket, key))
+	if os.IsNotExist(err) {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, fmt.Errorf("failed to read metadata file: %w", err)
+	}
+	return data, true, nil
+}
+
+// Store saves the value of key in bucket on disk
+func (s *FileMetadataStore) Store(bucket, key string, value []byte) error {
+	if !validMetadataKey(key) {
+		return fmt.Errorf("invalid metadata key %q", key)
+	}
+
+	// Written through a temporary file to avoid partially written entries
+	if err := WriteFileAtomic(s.path(bucket, key), value, 0644); err != nil {
+		return fmt.Errorf("failed to store metadata file: %w", err)
+	}
+	return nil
+}
+
+// Delete removes the file of key in bucket
+func (s *FileMetadataStore) Delete(bucket, key string) error {
+	if !validMetadataKey(key) {
+		return fmt.Errorf("invalid metadata key %q", key)
+	}
+	if err := os.Remove(s.path(bucket, key)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete metadata file: %w", err)
+	}
+	return nil
+}
+
+// Close does nothing, files are closed after every operation
+func (s *FileMetadataStore) Close() error {
+	return nil
+}
+
+// validMetadataKey reports whether key can be used as filename
+func validMetadataKey(key string) bool {
+	return key != "" && key != "." && key != ".." && !strings.ContainsAny(key, `/\`)
+}
+
+// SQLMetadataStore stores all values in a single table of a database
+// Large installations avoid millions of small files this way
+type SQLMetadataStore struct {
+	db *sql.DB
+}
+
+// sqlitePragmas are applied to every SQLite connection, writers wait for the lock instead of failing with SQLITE_BUSY
+// and readers don't block the writer
+const sqlitePragmas = "?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"
+
+// OpenSQLMetadataStore opens the database at path with driver and creates its table if needed
+// The driver must be registered with database/sql, the pure Go "sqlite" driver is linked into the binary
+// The store is used concurrently by the worker pools, all statements share a single connection
+func OpenSQLMetadataStore(driver, path string) (*SQLMetadataStore, error) {
+	if !slices.Contains(sql.Drivers(), driver) {
+		return nil, fmt.Errorf("%w: no %s database driver linked", ErrMetadataBackendUnavailable, driver)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create metadata database directory: %w", err)
+	}
+
+	dsn := path
+	if driver == sqliteDriver {
+		dsn += sqlitePragmas
+	}
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open metadata database %s: %w", path, err)
+	}
+	db.SetMaxOpenConns(1)
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS metadata (
+		bucket TEXT NOT NULL,
+		key TEXT NOT NULL,
+		value BLOB NOT NULL,
+		PRIMARY KEY (bucket, key)
+	)`)
+	if err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("failed to create metadata table in %s: %w", path, err)
+	}
+	return &SQLMetadataStore{db: db}, nil
+}
+
+// Load returns the value of key in bucket from the database
+func (s *SQLMetadataStore) Load(bucket, key string) ([]byte, bool, error) {
+	var value []byte
+	err := s.db.QueryRow(`SELECT value FROM metadata WHERE bucket = ? AND key = ?`, bucket, key).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, fmt.Errorf("failed to load metadata %s/%s: %w", bucket, key, err)
+	}
+	return value, true, nil
+}
+
+// Store saves the value of key in bucket in the database
+func (s *SQLMetadataStore) Store(bucket, key string, value []byte) error {
+	_, err := s.db.Exec(`INSERT INTO metadata (bucket, key, value) VALUES (?, ?, ?)
+		ON CONFLICT (bucket, key) DO UPDATE SET value = excluded.value`, bucket, key, value)
+	if err != nil {
+		return fmt.Errorf("failed to store metadata %s/%s: %w", bucket, key, err)
+	}
+	return nil
+}
+
+// Delete removes key in bucket from the database
+func (s *SQLMetadataStore) Delete(bucket, key string) error {
+	if _, err := s.db.Exec(`DELETE FROM metadata WHERE bucket = ? AND key = ?`, bucket, key); err != nil {
+		return fmt.Errorf("failed to delete metadata %s/%s: %w", bucket, key, err)
+	}
+	return nil
+}
+
+// Close closes the database
+func (s *SQLMetadataStore) Close() error {
+	return s.db.Close()
+}
+
+// Buckets of the metadata store
+const (
+	ControlBucket   = "control"   // Parsed control files of binary packages
+	ContentsBucket  = "contents"  // File lists of binary packages
+	RedirectsBucket = "redirects" // Redirect maps of feeds, see RedirectsFile
+	SignersBucket   = "signers"   // Signer maps of feeds, see SignersFile
+	ChecksumsBucket = "checksums" // SHA256 of downloaded files by path, size and modification time
+	UploadsBucket   = "uploads"   // Upload state of publishes resuming after a failure
+)
+
+// MetadataKey returns the key of a path like the relative path of a feed, usable with every backend
+func MetadataKey(path string) string {
+	return url.QueryEscape(filepath.ToSlash(path))
+}
+
+// LoadMetadataMap reads the YAML map of key in bucket into target, reporting whether it exists
+// Maps written before the metadata store are read from legacyFile if the store has none
+// A failing store is an error, the map must not be rebuilt from an empty or outdated base
+func LoadMetadataMap(store MetadataStore, bucket, key, legacyFile string, target any) (bool, error) {
+	if store != nil {
+		data, ok, err := store.Load(bucket, key)
+		if err != nil {
+			return false, err
+		}
+		if ok {
+			return true, yaml.Unmarshal(data, target)
+		}
+	}
+
+	data, err := os.ReadFile(legacyFile)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, yaml.Unmarshal(data, target)
+}
+
+// mergeMetadataMap merges entries into the YAML map of key in bucket, or into legacyFile without store
+// The mutex protects the read-modify-write against concurrent updates
+func mergeMetadataMap[V any](mu *sync.Mutex, store MetadataStore, bucket, key, legacyFile string, entries map[string]V) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	existing := make(map[string]V)
+	if _, err := LoadMetadataMap(store, bucket, key, legacyFile, &existing); err != nil {
+		return fmt.Errorf("failed to read existing map %s/%s: %w", bucket, key, err)
+	}
+	maps.Copy(existing, entries)
+
+	data, err := yaml.Marshal(existing)
+	if err != nil {
+		return fmt.Errorf("failed to marshal map %s/%s: %w", bucket, key, err)
+	}
+
+	if store != nil {
+		return store.Store(bucket, key, data)
+	}
+	if err := os.WriteFile(legacyFile, data, 0644); err != nil {
+		return fmt.Errorf("failed to write map %s: %w", legacyFile, err)
+	}
+	return nil
+}
+
+// MetadataControlStore stores parsed control stanzas in a metadata store, lookups are served from memory after the first read
+type MetadataControlStore struct {
+	store  MetadataStore
+	memory *debext.MemoryControlStore
+}
+
+// NewMetadataControlStore creates a control store backed by store
+func NewMetadataControlStore(store MetadataStore) *MetadataControlStore {
+	return &MetadataControlStore{store: store, memory: debext.NewMemoryControlStore()}
+}
+
+// Load returns the stanza for the given SHA256 from memory or the metadata store
+func (s *MetadataControlStore) Load(sha256 string) (deb.Stanza, bool) {
+	if stanza, ok := s.memory.Load(sha256); ok {
+		return stanza, true
+	}
+
+	data, ok, err := s.store.Load(ControlBucket, sha256)
+	if err != nil {
+		slog.Warn("Failed to load cached control file", "sha256", sha256, "error", err)
+		return nil, false
+	}
+	if !ok {
+		return nil, false
+	}
+	stanza, err := deb.NewControlFileReader(bytes.NewReader(data), false, false).ReadStanza()
+	if err != nil || stanza == nil {
+		return nil, false
+	}
+
+	_ = s.memory.Store(sha256, stanza)
+	return stanza, true
+}
+
+// Store saves the stanza for the given SHA256 in memory and the metadata store
+func (s *MetadataControlStore) Store(sha256 string, stanza deb.Stanza) error {
+	if err := s.memory.Store(sha256, stanza); err != nil {
+		return err
+	}
+
+	// WriteTo consumes the stanza, write a copy
+	var buf bytes.Buffer
+	w := bufio.NewWriter(&buf)
+	if err := stanza.Copy().WriteTo(w, false, false, false); err != nil {
+		return fmt.Errorf("failed to serialize control stanza: %w", err)
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to serialize control stanza: %w", err)
+	}
+	return s.store.Store(ControlBucket, sha256, buf.Bytes())
+}
+
+// MetadataContentsStore stores the file lists of packages in a metadata store with one path per line
+type MetadataContentsStore struct {
+	store MetadataStore
+}
+
+// NewMetadataContentsStore creates a contents store backed by store
+func NewMetadataContentsStore(store MetadataStore) *MetadataContentsStore {
+	return &MetadataContentsStore{store: store}
+}
+
+// Load returns the file list for the given SHA256 from the metadata store
+func (s *MetadataContentsStore) Load(sha256 string) ([]string, bool) {
+	data, ok, err := s.store.Load(ContentsBucket, sha256)
+	if err != nil {
+		slog.Warn("Failed to load cached contents", "sha256", sha256, "error", err)
+		return nil, false
+	}
+	if !ok {
+		return nil, false
+	}
+
+	files := []string{}
+	for line := range strings.Lines(string(data)) {
+		if line = strings.TrimSuffix(line, "\n"); line != "" {
+			files = append(files, line)
+		}
+	}
+	return files, true
+}
+
+// Store saves the file list for the given SHA256 in the metadata store
+func (s *MetadataContentsStore) Store(sha256 string, files []string) error {
+	var buf strings.Builder
+	for _, file := range files {
+		buf.WriteString(file)
+		buf.WriteByte('\n')
+	}
+	return s.store.Store(ContentsBucket, sha256, []byte(buf.String()))
+}
@@ -0,0 +1,247 @@
+package common
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// loadMetadata loads key in bucket from store and fails the test on errors
+func loadMetadata(t *testing.T, store MetadataStore, bucket, key string) ([]byte, bool) {
+	t.Helper()
+	value, ok, err := store.Load(bucket, key)
+	require.NoError(t, err)
+	return value, ok
+}
+
+func TestFileMetadataStore(t *testing.T) {
+	dir := t.TempDir()
+	store := NewFileMetadataStore(dir)
+
+	_, ok := loadMetadata(t, store, ControlBucket, "abcdef")
+	assert.False(t, ok)
+
+	require.NoError(t, store.Store(ControlBucket, "abcdef", []byte("first")))
+	require.NoError(t, store.Store(ControlBucket, "abcdef", []byte("second")))
+	value, ok := loadMetadata(t, store, ControlBucket, "abcdef")
+	assert.True(t, ok)
+	assert.Equal(t, "second", string(value))
+
+	// Same layout as the caches written before the metadata store
+	data, err := os.ReadFile(filepath.Join(dir, ControlBucket, "ab", "abcdef"))
+	require.NoError(t, err)
+	assert.Equal(t, "second", string(data))
+
+	// Buckets are separate
+	_, ok = loadMetadata(t, store, ContentsBucket, "abcdef")
+	assert.False(t, ok)
+
+	tests := []struct {
+		name string
+		key  string
+	}{
+		{name: "empty", key: ""},
+		{name: "parent", key: ".."},
+		{name: "path", key: "../escape"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Error(t, store.Store(ControlBucket, tt.key, []byte("value")))
+			_, ok := loadMetadata(t, store, ControlBucket, tt.key)
+			assert.False(t, ok)
+		})
+	}
+}
+
+func TestOpenMetadataStore(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name    string
+		backend string
+		want    MetadataStore
+		wantErr bool
+	}{
+		{name: "default", backend: "", want: &FileMetadataStore{}},
+		{name: "files", backend: MetadataBackendFiles, want: &FileMetadataStore{}},
+		{name: "sqlite", backend: MetadataBackendSQLite, want: &SQLMetadataStore{}},
+		{name: "unknown", backend: "postgres", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, err := OpenMetadataStore(tt.backend, dir, filepath.Join(dir, "metadata.db"))
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.IsType(t, tt.want, store)
+			assert.NoError(t, store.Close())
+		})
+	}
+
+	_, err := OpenSQLMetadataStore("missing", filepath.Join(dir, "other.db"))
+	assert.ErrorIs(t, err, ErrMetadataBackendUnavailable)
+}
+
+func TestMetadataStore_Backends(t *testing.T) {
+	backends := map[string]func(t *testing.T, dir string) MetadataStore{
+		MetadataBackendFiles: func(t *testing.T, dir string) MetadataStore {
+			return NewFileMetadataStore(dir)
+		},
+		MetadataBackendSQLite: func(t *testing.T, dir string) MetadataStore {
+			store, err := OpenSQLMetadataStore(sqliteDriver, filepath.Join(dir, "metadata.db"))
+			require.NoError(t, err)
+			return store
+		},
+	}
+
+	for name, open := range backends {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			store := open(t, dir)
+
+			_, ok := loadMetadata(t, store, RedirectsBucket, MetadataKey("github/owner/repo"))
+			assert.False(t, ok)
+
+			require.NoError(t, store.Store(RedirectsBucket, MetadataKey("github/owner/repo"), []byte("first")))
+			require.NoError(t, store.Store(RedirectsBucket, MetadataKey("github/owner/repo"), []byte("second")))
+			require.NoError(t, store.Store(SignersBucket, MetadataKey("github/owner/repo"), []byte("signers")))
+			require.NoError(t, store.Close())
+
+			// Values survive reopening the store
+			store = open(t, dir)
+			t.Cleanup(func() { _ = store.Close() })
+			value, ok := loadMetadata(t, store, RedirectsBucket, MetadataKey("github/owner/repo"))
+			assert.True(t, ok)
+			assert.Equal(t, "second", string(value))
+
+			require.NoError(t, store.Delete(RedirectsBucket, MetadataKey("github/owner/repo")))
+			require.NoError(t, store.Delete(RedirectsBucket, "missing"))
+			_, ok = loadMetadata(t, store, RedirectsBucket, MetadataKey("github/owner/repo"))
+			assert.False(t, ok)
+
+			// Buckets are separate
+			value, ok = loadMetadata(t, store, SignersBucket, MetadataKey("github/owner/repo"))
+			assert.True(t, ok)
+			assert.Equal(t, "signers", string(value))
+		})
+	}
+}
+
+func TestSQLMetadataStore_Concurrent(t *testing.T) {
+	store, err := OpenSQLMetadataStore(sqliteDriver, filepath.Join(t.TempDir(), "metadata.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.Close() })
+
+	// Like the fetch, compose and upload pools storing and loading at the same time
+	var wg sync.WaitGroup
+	errs := make(chan error, 32*100*2)
+	for worker := range 32 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 100 {
+				key := fmt.Sprintf("key-%d", i%10)
+				if err := store.Store(ChecksumsBucket, key, []byte(fmt.Sprintf("%d-%d", worker, i))); err != nil {
+					errs <- err
+				}
+				if _, ok, err := store.Load(ChecksumsBucket, key); err != nil {
+					errs <- err
+				} else if !ok {
+					errs <- fmt.Errorf("stored key %s not found", key)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+}
+
+func TestLoadMetadataMap_StoreError(t *testing.T) {
+	store, err := OpenSQLMetadataStore(sqliteDriver, filepath.Join(t.TempDir(), "metadata.db"))
+	require.NoError(t, err)
+	require.NoError(t, store.Close())
+
+	// A failing store is not taken as a missing map
+	legacy := filepath.Join(t.TempDir(), "redirects.yaml")
+	require.NoError(t, os.WriteFile(legacy, []byte("a: b\n"), 0644))
+	target := make(map[string]string)
+	_, err = LoadMetadataMap(store, RedirectsBucket, "key", legacy, &target)
+	assert.Error(t, err)
+	assert.Empty(t, target)
+	assert.Error(t, mergeMetadataMap(&sync.Mutex{}, store, RedirectsBucket, "key", legacy, map[string]string{"c": "d"}))
+}
+
+func TestMetadataMap(t *testing.T) {
+	dir := t.TempDir()
+	legacy := filepath.Join(dir, RedirectsFile)
+	require.NoError(t, os.WriteFile(legacy, []byte("a.deb: v1/a.deb\n"), 0644))
+
+	var mu sync.Mutex
+	store := NewFileMetadataStore(filepath.Join(dir, "store"))
+	key := MetadataKey("github/owner/repo")
+
+	// Maps written before the store are read from the legacy file
+	loaded := map[string]string{}
+	found, err := LoadMetadataMap(store, RedirectsBucket, key, legacy, &loaded)
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, map[string]string{"a.deb": "v1/a.deb"}, loaded)
+
+	// Merging keeps the legacy entries and moves the map into the store
+	require.NoError(t, mergeMetadataMap(&mu, store, RedirectsBucket, key, legacy, map[string]string{"b.deb": "v2/b.deb"}))
+	require.NoError(t, os.Remove(legacy))
+	loaded = map[string]string{}
+	found, err = LoadMetadataMap(store, RedirectsBucket, key, legacy, &loaded)
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, map[string]string{"a.deb": "v1/a.deb", "b.deb": "v2/b.deb"}, loaded)
+
+	found, err = LoadMetadataMap(store, RedirectsBucket, MetadataKey("other"), legacy, &loaded)
+	require.NoError(t, err)
+	assert.False(t, found)
+}
+
+func TestMetadataControlStore(t *testing.T) {
+	dir := t.TempDir()
+	stanza := deb.Stanza{"Package": "hello", "Version": "1.0-1", "Architecture": "amd64"}
+
+	require.NoError(t, NewMetadataControlStore(NewFileMetadataStore(dir)).Store("abcdef", stanza))
+
+	// A new store reads the stanza from disk
+	loaded, ok := NewMetadataControlStore(NewFileMetadataStore(dir)).Load("abcdef")
+	require.True(t, ok)
+	assert.Equal(t, stanza, loaded)
+
+	_, ok = NewMetadataControlStore(NewFileMetadataStore(dir)).Load("missing")
+	assert.False(t, ok)
+}
+
+func TestMetadataContentsStore(t *testing.T) {
+	store := NewMetadataContentsStore(NewFileMetadataStore(t.TempDir()))
+
+	files := []string{"usr/bin/hello", "usr/share/doc/hello/copyright"}
+	require.NoError(t, store.Store("abcdef", files))
+
+	loaded, ok := store.Load("abcdef")
+	require.True(t, ok)
+	assert.Equal(t, files, loaded)
+
+	// Packages without files are stored too
+	require.NoError(t, store.Store("empty", []string{}))
+	loaded, ok = store.Load("empty")
+	require.True(t, ok)
+	assert.Empty(t, loaded)
+}
@@ -0,0 +1,264 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alitto/pond/v2"
+)
+
+// PoolStats is a snapshot of the counters of a worker pool
+type PoolStats struct {
+	Name           string // Name of the pool, subpools are named after their parent and the function creating them
+	Depth          int    // Nesting level, 0 for root pools
+	Instances      int    // Pools of this name, subpools created by the same function are summed up
+	MaxConcurrency int    // Maximum number of running tasks
+	Running        int64  // Tasks currently running
+	Waiting        uint64 // Tasks queued until a worker is free
+	Completed      uint64 // Tasks completed since the pool was created
+}
+
+// Saturated reports whether all workers of the pool are busy and more tasks are queued
+func (s PoolStats) Saturated() bool {
+	return s.Running >= int64(s.MaxConcurrency) && s.Waiting > 0
+}
+
+// LogValue groups the counters of a pool in log output
+func (s PoolStats) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.Int("pools", s.Instances),
+		slog.Int64("running", s.Running),
+		slog.Int("max", s.MaxConcurrency),
+		slog.Uint64("waiting", s.Waiting),
+		slog.Uint64("completed", s.Completed),
+	)
+}
+
+// poolCounters are the counters shared by all pond pool types
+type poolCounters interface {
+	MaxConcurrency() int
+	RunningWorkers() int64
+	WaitingTasks() uint64
+	CompletedTasks() uint64
+}
+
+// trackedPool is a pool registered with a PoolMonitor
+type trackedPool struct {
+	name  string
+	depth int
+	pool  poolCounters
+}
+
+// PoolMonitor tracks worker pools and the subpools created from them
+// Thread-safe, subpools register and unregister themselves concurrently
+type PoolMonitor struct {
+	pools []*trackedPool
+	mu    sync.Mutex
+}
+
+// NewPoolMonitor creates a monitor without pools
+func NewPoolMonitor() *PoolMonitor {
+	return &PoolMonitor{}
+}
+
+// Pool tracks pool under name and returns it wrapped, so subpools created from it are tracked until stopped
+func (m *PoolMonitor) Pool(name string, pool pond.Pool) pond.Pool {
+	return m.wrap(name, 0, pool)
+}
+
+// Track tracks the counters of a pool whose subpools are not tracked, e.g. a result pool
+func (m *PoolMonitor) Track(name string, pool poolCounters) {
+	m.add(&trackedPool{name: name, pool: pool})
+}
+
+// Stats returns a snapshot of all tracked pools ordered by name
+func (m *PoolMonitor) Stats() []PoolStats {
+	m.mu.Lock()
+	pools := slices.Clone(m.pools)
+	m.mu.Unlock()
+
+	byName := make(map[string]*PoolStats)
+	for _, p := range pools {
+		s, ok := byName[p.name]
+		if !ok {
+			s = &PoolStats{Name: p.name, Depth: p.depth}
+			byName[p.name] = s
+		}
+		s.Instances++
+		s.MaxConcurrency += p.pool.MaxConcurrency()
+		s.Running += p.pool.RunningWorkers()
+		s.Waiting += p.pool.WaitingTasks()
+		s.Completed += p.pool.CompletedTasks()
+	}
+
+	stats := make([]PoolStats, 0, len(byName))
+	for _, s := range byName {
+		stats = append(stats, *s)
+	}
+	slices.SortFunc(stats, func(a, b PoolStats) int { return strings.Compare(a.Name, b.Name) })
+	return stats
+}
+
+// Watch checks the root pools every interval until ctx is done or the returned function is called
+// A root pool that is saturated without completing any task for stall is reported once as stalled,
+// with the stacks of all goroutines written to a file in dumpDir
+func (m *PoolMonitor) Watch(ctx context.Context, stall time.Duration, dumpDir string) func() {
+	if stall <= 0 {
+		return func() {}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+
+	interval := stall / 4
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		// When each root pool was first seen saturated, with its completed tasks at the last check
+		since := make(map[string]time.Time)
+		completed := make(map[string]uint64)
+		reported := make(map[string]bool)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				stats := m.Stats()
+				m.logActive(stats)
+
+				for _, s := range stats {
+					if s.Depth > 0 {
+						continue
+					}
+					if !s.Saturated() || s.Completed != completed[s.Name] {
+						delete(since, s.Name)
+						delete(reported, s.Name)
+						completed[s.Name] = s.Completed
+						continue
+					}
+					if _, ok := since[s.Name]; !ok {
+						since[s.Name] = now
+					}
+					if !reported[s.Name] && now.Sub(since[s.Name]) >= stall {
+						reported[s.Name] = true
+						m.reportStall(s, now.Sub(since[s.Name]), stats, dumpDir)
+					}
+				}
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// logActive logs the counters of pools with running or waiting tasks
+func (m *PoolMonitor) logActive(stats []PoolStats) {
+	var attrs []any
+	for _, s := range stats {
+		if s.Running > 0 || s.Waiting > 0 {
+			attrs = append(attrs, slog.Any(s.Name, s))
+		}
+	}
+	if len(attrs) > 0 {
+		slog.Debug("Worker pools", attrs...)
+	}
+}
+
+// reportStall logs a stalled pool with all active pools and dumps the goroutine stacks
+func (m *PoolMonitor) reportStall(stalled PoolStats, duration time.Duration, stats []PoolStats, dumpDir string) {
+	attrs := []any{"pool", stalled.Name, "workers", stalled.MaxConcurrency, "waiting", stalled.Waiting, "for", duration.Round(time.Second)}
+	for _, s := range stats {
+		if s.Depth > 0 && (s.Running > 0 || s.Waiting > 0) {
+			attrs = append(attrs, slog.Any(s.Name, s))
+		}
+	}
+
+	path, err := dumpGoroutines(dumpDir)
+	if err != nil {
+		attrs = append(attrs, "dump_error", err)
+	} else {
+		attrs = append(attrs, "stacks", path)
+	}
+
+	slog.Error("Worker pool stalled: all workers are busy and no task completed. "+
+		"Tasks waiting on nested subpools can occupy every worker, raise workers.main if this persists", attrs...)
+}
+
+// dumpGoroutines writes the stacks of all goroutines to a new file in dir and returns its path
+func dumpGoroutines(dir string) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	f, err := os.Create(filepath.Join(dir, fmt.Sprintf("goroutines-%s.txt", time.Now().Format("20060102-150405"))))
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = f.Close() }()
+
+	if err := pprof.Lookup("goroutine").WriteTo(f, 2); err != nil {
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// wrap tracks pool and returns it wrapped in a monitoredPool
+func (m *PoolMonitor) wrap(name string, depth int, pool pond.Pool) pond.Pool {
+	tracked := &trackedPool{name: name, depth: depth, pool: pool}
+	m.add(tracked)
+	return &monitoredPool{Pool: pool, monitor: m, tracked: tracked}
+}
+
+func (m *PoolMonitor) add(p *trackedPool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pools = append(m.pools, p)
+}
+
+func (m *PoolMonitor) remove(p *trackedPool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pools = slices.DeleteFunc(m.pools, func(t *trackedPool) bool { return t == p })
+}
+
+// monitoredPool tracks the subpools created from a pool
+type monitoredPool struct {
+	pond.Pool
+	monitor *PoolMonitor
+	tracked *trackedPool
+}
+
+// NewSubpool creates a tracked subpool named after the calling function
+func (p *monitoredPool) NewSubpool(maxConcurrency int, options ...pond.Option) pond.Pool {
+	name := "subpool"
+	if pc, _, _, ok := runtime.Caller(1); ok {
+		if fn := runtime.FuncForPC(pc); fn != nil {
+			// Package path is left out, e.g. compose.(*Apt).collect
+			name = fn.Name()[strings.LastIndex(fn.Name(), "/")+1:]
+		}
+	}
+
+	sub := p.Pool.NewSubpool(maxConcurrency, options...)
+	return p.monitor.wrap(p.tracked.name+"/"+name, p.tracked.depth+1, sub)
+}
+
+// StopAndWait stops the pool and stops tracking it
+func (p *monitoredPool) StopAndWait() {
+	p.Pool.StopAndWait()
+	p.monitor.remove(p.tracked)
+}
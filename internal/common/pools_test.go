@@ -0,0 +1,90 @@
+package common
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alitto/pond/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// createSubpool creates a subpool from another function to test its naming
+func createSubpool(pool pond.Pool) pond.Pool {
+	return pool.NewSubpool(2)
+}
+
+func TestPoolMonitor_Stats(t *testing.T) {
+	monitor := NewPoolMonitor()
+	pool := monitor.Pool("main", pond.NewPool(4))
+	defer pool.StopAndWait()
+	monitor.Track("download", pond.NewResultPool[Result](2))
+
+	sub1 := createSubpool(pool)
+	sub2 := createSubpool(pool)
+
+	release := make(chan struct{})
+	sub1.Submit(func() { <-release })
+	sub2.Submit(func() { <-release })
+	require.Eventually(t, func() bool { return pool.RunningWorkers() == 2 }, time.Second, time.Millisecond)
+
+	stats := monitor.Stats()
+	require.Len(t, stats, 3)
+	assert.Equal(t, "download", stats[0].Name)
+	assert.Equal(t, "main", stats[1].Name)
+	assert.Equal(t, "main/common.createSubpool", stats[2].Name)
+	assert.Equal(t, 1, stats[2].Depth)
+	assert.Equal(t, 2, stats[2].Instances)
+	assert.Equal(t, 4, stats[2].MaxConcurrency)
+	assert.Equal(t, int64(2), stats[2].Running)
+
+	close(release)
+	sub1.StopAndWait()
+	sub2.StopAndWait()
+
+	stats = monitor.Stats()
+	require.Len(t, stats, 2)
+	assert.Equal(t, uint64(2), stats[1].Completed)
+}
+
+func TestPoolMonitor_Watch(t *testing.T) {
+	tests := []struct {
+		name    string
+		blocked bool
+		dumped  bool
+	}{
+		{name: "stalled pool dumps stacks", blocked: true, dumped: true},
+		{name: "progressing pool", blocked: false, dumped: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			monitor := NewPoolMonitor()
+			pool := monitor.Pool("main", pond.NewPool(1))
+
+			release := make(chan struct{})
+			if tt.blocked {
+				// The only worker waits on a task queued behind it
+				pool.Submit(func() { <-release })
+				pool.Submit(func() {})
+			}
+
+			stop := monitor.Watch(context.Background(), 40*time.Millisecond, dir)
+			time.Sleep(150 * time.Millisecond)
+			stop()
+			close(release)
+			pool.StopAndWait()
+
+			dumps, err := filepath.Glob(filepath.Join(dir, "goroutines-*.txt"))
+			require.NoError(t, err)
+			if tt.dumped {
+				assert.Len(t, dumps, 1)
+			} else {
+				assert.Empty(t, dumps)
+			}
+		})
+	}
+}
@@ -34,10 +34,23 @@ type RetentionRule struct {
 	Amount  []int  `yaml:"amount"`
 }
 
-// RetentionPolicy defines retention rules with optional source filtering
+// RetentionPolicy defines retention rules with optional source and distribution filtering
 type RetentionPolicy struct {
 	RetentionRule `yaml:",inline"`
-	FromSources   []string `yaml:"from_sources,omitempty"` // Optional: source name patterns (supports glob), empty = applies to all sources
+	FromSources   []string `yaml:"from_sources,omitempty"`  // Optional: source name patterns (supports glob), empty = applies to all sources
+	Distributions []string `yaml:"distributions,omitempty"` // Optional: distribution patterns (supports glob), empty = applies to all distributions
+}
+
+// FilterByDistribution returns policies matching dist. Empty Distributions matches all.
+// Supports the same glob patterns and negations as FilterBySource.
+func FilterByDistribution(policies []RetentionPolicy, dist string) []RetentionPolicy {
+	var matching []RetentionPolicy
+	for _, policy := range policies {
+		if MatchesGlobPatterns(policy.Distributions, dist) {
+			matching = append(matching, policy)
+		}
+	}
+	return matching
 }
 
 // FilterBySource returns rules matching sourceName. Empty FromSources matches all.
@@ -52,6 +65,28 @@ func FilterBySource(policies []RetentionPolicy, sourceName string) []RetentionRu
 	return rules
 }
 
+// PinnedVersion names exact versions that retention never removes
+type PinnedVersion struct {
+	Versions    []string `yaml:"versions"`               // Exact versions to keep
+	FromSources []string `yaml:"from_sources,omitempty"` // Optional: source name patterns (supports glob), empty = applies to all sources
+}
+
+// PinnedVersions returns the versions pinned for sourceName, same matching as FilterBySource
+func PinnedVersions(pins []PinnedVersion, sourceName string) []string {
+	var versions []string
+	for _, pin := range pins {
+		if MatchesGlobPatterns(pin.FromSources, sourceName) {
+			versions = append(versions, pin.Versions...)
+		}
+	}
+	return versions
+}
+
+// IsPinned reports whether version of sourceName is pinned
+func IsPinned(pins []PinnedVersion, sourceName, version string) bool {
+	return slices.Contains(PinnedVersions(pins, sourceName), version)
+}
+
 // RetentionFilter applies retention rules to items. Thread-safe for concurrent Add() and Kept().
 type RetentionFilter[T any] struct {
 	rules           []RetentionRule
@@ -59,6 +94,7 @@ type RetentionFilter[T any] struct {
 	getVersion      func(T) string
 	items           []T
 	noMatchBehavior NoMatchBehavior
+	pinned          []string   // Versions always kept regardless of the rules
 	mu              sync.Mutex // protects items slice
 }
 
@@ -109,6 +145,10 @@ func (f *RetentionFilter[T]) Filter(items []T) ([]T, error) {
 		applicableRules := f.findApplicableRules(versionStr)
 
 		if len(applicableRules) == 0 {
+			if slices.Contains(f.pinned, versionStr) {
+				// Kept as pinned version below
+				continue
+			}
 			// No patterns match this version
 			switch f.noMatchBehavior {
 			case NoMatchKeep:
@@ -154,6 +194,13 @@ func (f *RetentionFilter[T]) Filter(items []T) ([]T, error) {
 		keepSet[f.getVersion(item)] = true
 	}
 
+	// Pinned versions are kept on top of the rules and don't take their places
+	for _, item := range items {
+		if versionStr := f.getVersion(item); slices.Contains(f.pinned, versionStr) {
+			keepSet[versionStr] = true
+		}
+	}
+
 	result := make([]T, 0, len(keepSet))
 	for _, item := range items {
 		if keepSet[f.getVersion(item)] {
@@ -195,8 +242,8 @@ func (f *RetentionFilter[T]) Add(item T) error {
 	f.mu.Lock()
 	defer f.mu.Unlock()
 
-	// Handle NoMatchBehavior validation
-	if f.noMatchBehavior == NoMatchError || f.noMatchBehavior == NoMatchIgnore {
+	// Handle NoMatchBehavior validation, pinned versions are always kept
+	if (f.noMatchBehavior == NoMatchError || f.noMatchBehavior == NoMatchIgnore) && !slices.Contains(f.pinned, f.getVersion(item)) {
 		versionStr := f.getVersion(item)
 		applicableRules := f.findApplicableRules(versionStr)
 		if len(applicableRules) == 0 {
@@ -220,6 +267,14 @@ func (f *RetentionFilter[T]) Kept() ([]T, error) {
 	return f.Filter(f.items)
 }
 
+// Items returns all items from Add() calls, kept or not. Thread-safe.
+func (f *RetentionFilter[T]) Items() []T {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return slices.Clone(f.items)
+}
+
 // applyRetention applies hierarchical filtering
 func (f *RetentionFilter[T]) applyRetention(versions []version, trackedIndices, amounts []int) []string {
 	if len(versions) == 0 {
@@ -137,6 +137,90 @@ func TestFilterBySource(t *testing.T) {
 	}
 }
 
+func TestFilterByDistribution(t *testing.T) {
+	policies := []RetentionPolicy{
+		{RetentionRule: RetentionRule{Pattern: "*.#", Amount: []int{5}}, Distributions: []string{"noble"}},
+		{RetentionRule: RetentionRule{Pattern: "*.#", Amount: []int{2}}, Distributions: []string{"trixie*"}},
+		{RetentionRule: RetentionRule{Pattern: "*.#-*", Amount: []int{1}}},
+		{RetentionRule: RetentionRule{Pattern: "#.*", Amount: []int{3}}, Distributions: []string{"*", "!noble"}},
+	}
+
+	tests := []struct {
+		name        string
+		dist        string
+		wantAmounts [][]int
+	}{
+		{"exact match and unrestricted", "noble", [][]int{{5}, {1}}},
+		{"glob match", "trixie-prerelease", [][]int{{2}, {1}, {3}}},
+		{"negation", "bookworm", [][]int{{1}, {3}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var amounts [][]int
+			for _, policy := range FilterByDistribution(policies, tt.dist) {
+				amounts = append(amounts, policy.Amount)
+			}
+			assert.Equal(t, tt.wantAmounts, amounts)
+		})
+	}
+}
+
+func TestPinnedVersions(t *testing.T) {
+	pins := []PinnedVersion{
+		{Versions: []string{"1.0-1"}, FromSources: []string{"nginx"}},
+		{Versions: []string{"2.0-1", "2.1-1"}, FromSources: []string{"php*"}},
+		{Versions: []string{"3.0-1"}},
+	}
+
+	tests := []struct {
+		name       string
+		sourceName string
+		want       []string
+	}{
+		{"exact source", "nginx", []string{"1.0-1", "3.0-1"}},
+		{"glob source", "php8.3", []string{"2.0-1", "2.1-1", "3.0-1"}},
+		{"only unrestricted pins", "apache2", []string{"3.0-1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, PinnedVersions(pins, tt.sourceName))
+		})
+	}
+
+	assert.True(t, IsPinned(pins, "nginx", "1.0-1"))
+	assert.False(t, IsPinned(pins, "apache2", "1.0-1"))
+}
+
+func TestRetentionFilter_Pinned(t *testing.T) {
+	tests := []struct {
+		name     string
+		behavior NoMatchBehavior
+		versions []string
+		want     []string
+	}{
+		{"pinned kept beyond amount", NoMatchKeep, []string{"1.3", "1.2", "1.1"}, []string{"1.3", "1.1"}},
+		{"pinned kept without matching rule", NoMatchIgnore, []string{"1.3", "1.1", "frozen", "other"}, []string{"1.3", "1.1", "frozen"}},
+		{"pinned without matching rule is no error", NoMatchError, []string{"1.3", "frozen"}, []string{"1.3", "frozen"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := NewRetentionFilter([]RetentionRule{{Pattern: "*.#", Amount: []int{1}}}, func(v string) string { return v }, tt.behavior)
+			require.NoError(t, err)
+			filter.pinned = []string{"1.1", "frozen"}
+
+			for _, v := range tt.versions {
+				require.NoError(t, filter.Add(v))
+			}
+			kept, err := filter.Kept()
+			require.NoError(t, err)
+			assert.ElementsMatch(t, tt.want, kept)
+		})
+	}
+}
+
 func TestRetentionFilter_Filter(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -0,0 +1,184 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrScheduleInvalid is returned for cron expressions that can't be parsed
+var ErrScheduleInvalid = errors.New("invalid schedule")
+
+// scheduleMacros are the supported shorthands of cron expressions
+var scheduleMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// scheduleField describes the range and names of a cron field
+type scheduleField struct {
+	name     string
+	min, max int
+	names    []string // Names of the values starting at min, e.g. jan for 1
+}
+
+var scheduleFields = []scheduleField{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12, names: []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}},
+	{name: "day of week", min: 0, max: 7, names: []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}},
+}
+
+// Schedule is a parsed cron expression with the fields minute, hour, day of month, month and day of week
+// Fields support *, values, names of months and weekdays, ranges (1-5), steps (*/15, 1-10/2) and lists (1,15)
+type Schedule struct {
+	expr                                string
+	minutes, hours, days, months, weeks uint64 // Bit sets of the matching values
+	daysRestricted, weeksRestricted     bool   // Whether day of month and day of week don't start with *
+}
+
+// ParseSchedule parses a cron expression with 5 fields or a macro like @hourly or @daily
+func ParseSchedule(expr string) (*Schedule, error) {
+	fields := strings.Fields(strings.ToLower(expr))
+	if len(fields) == 1 {
+		if macro, ok := scheduleMacros[fields[0]]; ok {
+			fields = strings.Fields(macro)
+		}
+	}
+	if len(fields) != len(scheduleFields) {
+		return nil, fmt.Errorf("%w: %q must have %d fields: minute hour day-of-month month day-of-week", ErrScheduleInvalid, expr, len(scheduleFields))
+	}
+
+	sets := make([]uint64, len(fields))
+	for i, field := range fields {
+		set, err := scheduleFields[i].parse(field)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q: %w", ErrScheduleInvalid, expr, err)
+		}
+		sets[i] = set
+	}
+
+	// Sunday is 0 and 7
+	if sets[4]&(1<<7) != 0 {
+		sets[4] |= 1
+	}
+
+	return &Schedule{
+		expr:            expr,
+		minutes:         sets[0],
+		hours:           sets[1],
+		days:            sets[2],
+		months:          sets[3],
+		weeks:           sets[4],
+		daysRestricted:  !strings.HasPrefix(fields[2], "*"),
+		weeksRestricted: !strings.HasPrefix(fields[4], "*"),
+	}, nil
+}
+
+// String returns the cron expression the schedule was parsed from
+func (s *Schedule) String() string {
+	return s.expr
+}
+
+// Next returns the first time after t matching the schedule, in the location of t
+// Returns the zero time if nothing matches within the next 5 years, e.g. for February 30
+func (s *Schedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if s.months&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.matchesDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.hours&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.minutes&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+
+	return time.Time{}
+}
+
+// matchesDay reports whether the day of t matches, like cron either field matches if both are restricted
+func (s *Schedule) matchesDay(t time.Time) bool {
+	day := s.days&(1<<uint(t.Day())) != 0
+	week := s.weeks&(1<<uint(t.Weekday())) != 0
+	if s.daysRestricted && s.weeksRestricted {
+		return day || week
+	}
+	return day && week
+}
+
+// parse returns the bit set of the values matching a field
+func (f scheduleField) parse(field string) (uint64, error) {
+	var set uint64
+	for part := range strings.SplitSeq(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepPart); err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step %q in %s", stepPart, f.name)
+			}
+		}
+
+		low, high := f.min, f.max
+		if rangePart != "*" {
+			lowPart, highPart, isRange := strings.Cut(rangePart, "-")
+			var err error
+			if low, err = f.value(lowPart); err != nil {
+				return 0, err
+			}
+			high = low
+			if isRange {
+				if high, err = f.value(highPart); err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				high = f.max // E.g. 5/15 runs at 5, 20, 35 and 50
+			}
+			if high < low {
+				return 0, fmt.Errorf("invalid range %q in %s", rangePart, f.name)
+			}
+		}
+
+		for v := low; v <= high; v += step {
+			set |= 1 << uint(v)
+		}
+	}
+	return set, nil
+}
+
+// value parses a number or name of the field
+func (f scheduleField) value(s string) (int, error) {
+	for i, name := range f.names {
+		if s == name {
+			return f.min + i, nil
+		}
+	}
+
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("invalid %s %q, must be %d-%d", f.name, s, f.min, f.max)
+	}
+	return v, nil
+}
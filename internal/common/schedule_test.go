@@ -0,0 +1,62 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSchedule_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+	}{
+		{name: "empty", expr: ""},
+		{name: "too few fields", expr: "0 * * *"},
+		{name: "unknown macro", expr: "@often"},
+		{name: "out of range", expr: "60 * * * *"},
+		{name: "inverted range", expr: "* 5-1 * * *"},
+		{name: "invalid step", expr: "*/0 * * * *"},
+		{name: "unknown name", expr: "* * * foo *"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseSchedule(tt.expr)
+			assert.ErrorIs(t, err, ErrScheduleInvalid)
+		})
+	}
+}
+
+func TestSchedule_Next(t *testing.T) {
+	// Wednesday
+	from := time.Date(2025, 1, 15, 10, 7, 30, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		expr string
+		want time.Time
+	}{
+		{name: "every minute", expr: "* * * * *", want: time.Date(2025, 1, 15, 10, 8, 0, 0, time.UTC)},
+		{name: "hourly", expr: "@hourly", want: time.Date(2025, 1, 15, 11, 0, 0, 0, time.UTC)},
+		{name: "daily", expr: "@daily", want: time.Date(2025, 1, 16, 0, 0, 0, 0, time.UTC)},
+		{name: "step", expr: "*/15 * * * *", want: time.Date(2025, 1, 15, 10, 15, 0, 0, time.UTC)},
+		{name: "step from value", expr: "5/20 * * * *", want: time.Date(2025, 1, 15, 10, 25, 0, 0, time.UTC)},
+		{name: "list and range", expr: "30 2,20-22 * * *", want: time.Date(2025, 1, 15, 20, 30, 0, 0, time.UTC)},
+		{name: "weekday name", expr: "0 6 * * mon", want: time.Date(2025, 1, 20, 6, 0, 0, 0, time.UTC)},
+		{name: "sunday as 7", expr: "0 0 * * 7", want: time.Date(2025, 1, 19, 0, 0, 0, 0, time.UTC)},
+		{name: "month name", expr: "0 0 1 mar *", want: time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{name: "day of month or week", expr: "0 0 1 * fri", want: time.Date(2025, 1, 17, 0, 0, 0, 0, time.UTC)},
+		{name: "never", expr: "0 0 30 feb *", want: time.Time{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := ParseSchedule(tt.expr)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, schedule.Next(from))
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package common
+
+import "sync/atomic"
+
+// DownloadStats counts files served from the downloads cache versus newly downloaded
+// Thread-safe for concurrent Record() calls
+type DownloadStats struct {
+	cached          atomic.Int64
+	downloaded      atomic.Int64
+	bytesSaved      atomic.Int64
+	bytesDownloaded atomic.Int64
+}
+
+// DownloadStatsSnapshot is a point-in-time copy of DownloadStats
+type DownloadStatsSnapshot struct {
+	Cached          int64 `json:"cached"`
+	Downloaded      int64 `json:"downloaded"`
+	BytesSaved      int64 `json:"bytes_saved"`
+	BytesDownloaded int64 `json:"bytes_downloaded"`
+}
+
+// Record counts a file of the given size as cached (digest matched) or downloaded
+func (s *DownloadStats) Record(cached bool, size int64) {
+	if cached {
+		s.cached.Add(1)
+		s.bytesSaved.Add(size)
+	} else {
+		s.downloaded.Add(1)
+		s.bytesDownloaded.Add(size)
+	}
+}
+
+// Snapshot returns the current counters
+func (s *DownloadStats) Snapshot() DownloadStatsSnapshot {
+	return DownloadStatsSnapshot{
+		Cached:          s.cached.Load(),
+		Downloaded:      s.downloaded.Load(),
+		BytesSaved:      s.bytesSaved.Load(),
+		BytesDownloaded: s.bytesDownloaded.Load(),
+	}
+}
+
+// Add returns the sum of two snapshots
+func (s DownloadStatsSnapshot) Add(other DownloadStatsSnapshot) DownloadStatsSnapshot {
+	return DownloadStatsSnapshot{
+		Cached:          s.Cached + other.Cached,
+		Downloaded:      s.Downloaded + other.Downloaded,
+		BytesSaved:      s.BytesSaved + other.BytesSaved,
+		BytesDownloaded: s.BytesDownloaded + other.BytesDownloaded,
+	}
+}
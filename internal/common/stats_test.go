@@ -0,0 +1,40 @@
+package common
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDownloadStats_Record(t *testing.T) {
+	var stats DownloadStats
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			stats.Record(true, 100)
+		}()
+		go func() {
+			defer wg.Done()
+			stats.Record(false, 50)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, DownloadStatsSnapshot{
+		Cached:          10,
+		Downloaded:      10,
+		BytesSaved:      1000,
+		BytesDownloaded: 500,
+	}, stats.Snapshot())
+}
+
+func TestDownloadStatsSnapshot_Add(t *testing.T) {
+	a := DownloadStatsSnapshot{Cached: 1, Downloaded: 2, BytesSaved: 3, BytesDownloaded: 4}
+	b := DownloadStatsSnapshot{Cached: 10, Downloaded: 20, BytesSaved: 30, BytesDownloaded: 40}
+
+	assert.Equal(t, DownloadStatsSnapshot{Cached: 11, Downloaded: 22, BytesSaved: 33, BytesDownloaded: 44}, a.Add(b))
+}
@@ -4,34 +4,43 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io"
 	"log/slog"
-	"maps"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/alitto/pond/v2"
-	"gopkg.in/yaml.v3"
+)
+
+// Map files kept at the feed scope of trusted storage without metadata store, and read as fallback with one
+const (
+	RedirectsFile = "redirects.yaml" // Relative path -> redirect target relative to the feed base URL
+	SignersFile   = "signers.yaml"   // Relative path -> key IDs the file was verified with
 )
 
 // FileForTrust represents a file to be moved to trusted storage with its metadata
 type FileForTrust struct {
 	Path         string
 	Distribution string
-	Hash         string // SHA256 hash
-	Source       string // Source package name for grouping
-	Redirect     string // Relative redirect suffix (original file source) relative to the feed base URL
+	Hash         string   // SHA256 hash
+	Source       string   // Source package name for grouping
+	Redirect     string   // Relative redirect suffix (original file source) relative to the feed base URL
+	Signers      []string // Key IDs of the signatures the file was verified with
 }
 
 // Storage handles file storage and downloads in downloads/, trusted/, and public/ directories
 type Storage struct {
 	downloadDir   string
 	trustedDir    string
+	scope         string // Path parts the storage is scoped to, the feed scope of its maps
 	downloader    *Downloader
-	redirectMapMu sync.Mutex // Protects redirects.yaml read-modify-write operations
+	metadata      MetadataStore // Store of the redirect, signer and checksum metadata, nil = map files, no checksum cache
+	redirectMapMu sync.Mutex    // Protects redirect and signer map read-modify-write operations
 }
 
 // NewStorage creates a new storage manager
@@ -44,17 +53,25 @@ func NewStorage(downloader *Downloader, downloadDir, trustedDir string, pathPart
 	return &Storage{
 		downloadDir: scopedDownloadDir,
 		trustedDir:  scopedTrustedDir,
+		scope:       filepath.Join(pathParts...),
 		downloader:  downloader,
 	}
 }
 
+// SetMetadataStore keeps the redirect and signer maps and the checksums of downloaded files in store
+func (m *Storage) SetMetadataStore(store MetadataStore) {
+	m.metadata = store
+}
+
 // Scope creates a new Storage instance scoped to additional path parts
 func (m *Storage) Scope(pathParts ...string) *Storage {
 	// Append path parts to current directories
 	return &Storage{
 		downloadDir: filepath.Join(append([]string{m.downloadDir}, pathParts...)...),
 		trustedDir:  filepath.Join(append([]string{m.trustedDir}, pathParts...)...),
+		scope:       filepath.Join(append([]string{m.scope}, pathParts...)...),
 		downloader:  m.downloader,
+		metadata:    m.metadata,
 	}
 }
 
@@ -83,6 +100,7 @@ func (m *Storage) ensureTrustedDir(pathParts ...string) error {
 func (m *Storage) LinkFilesToTrusted(ctx context.Context, files []*FileForTrust) error {
 	seen := make(map[string]string)      // filepath -> hash for deduplication
 	redirects := make(map[string]string) // relative path -> redirect suffix
+	signers := make(map[string][]string) // relative path -> signing key IDs
 
 	for _, file := range files {
 		// Build destination path in trusted
@@ -112,6 +130,9 @@ func (m *Storage) LinkFilesToTrusted(ctx context.Context, files []*FileForTrust)
 
 		// Collect redirect suffix with relative path as key
 		redirects[relPath] = file.Redirect
+		if len(file.Signers) > 0 {
+			signers[relPath] = file.Signers
+		}
 	}
 
 	if len(redirects) > 0 {
@@ -121,11 +142,17 @@ func (m *Storage) LinkFilesToTrusted(ctx context.Context, files []*FileForTrust)
 		}
 	}
 
+	if len(signers) > 0 {
+		if err := mergeMetadataMap(&m.redirectMapMu, m.metadata, SignersBucket, MetadataKey(m.scope), filepath.Join(m.trustedDir, SignersFile), signers); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
 // fileExistsWithHash checks if a file exists with the expected hash (hashMethod: "sha256")
-func fileExistsWithHash(path, hashMethod, expectedHash string) bool {
+func (m *Storage) fileExistsWithHash(path, hashMethod, expectedHash string) bool {
 	if expectedHash == "" {
 		return false
 	}
@@ -134,25 +161,70 @@ func fileExistsWithHash(path, hashMethod, expectedHash string) bool {
 		return false
 	}
 
-	f, err := os.Open(path)
+	actualHash, err := m.fileHash(path)
 	if err != nil {
 		return false
 	}
+
+	return strings.EqualFold(actualHash, expectedHash)
+}
+
+// fileChecksum is the SHA256 of a file as long as its size and modification time are unchanged
+type fileChecksum struct {
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	SHA256  string    `json:"sha256"`
+}
+
+// fileHash returns the SHA256 of a file, reused from the metadata store while the file is unchanged
+func (m *Storage) fileHash(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+
+	var key string
+	if m.metadata != nil {
+		sum := sha256.Sum256([]byte(path))
+		key = hex.EncodeToString(sum[:])
+		data, ok, err := m.metadata.Load(ChecksumsBucket, key)
+		if err != nil {
+			slog.Warn("Failed to load cached checksum", "file", path, "error", err)
+		}
+		if ok {
+			var cached fileChecksum
+			if json.Unmarshal(data, &cached) == nil && cached.Size == info.Size() && cached.ModTime.Equal(info.ModTime()) {
+				return cached.SHA256, nil
+			}
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
 	defer func() { _ = f.Close() }()
 
 	h := sha256.New()
 	if _, err := io.Copy(h, f); err != nil {
-		return false
+		return "", err
 	}
+	hash := hex.EncodeToString(h.Sum(nil))
 
-	actualHash := hex.EncodeToString(h.Sum(nil))
-
-	return strings.EqualFold(actualHash, expectedHash)
+	// A failing store only costs hashing the file again next time
+	if m.metadata != nil {
+		if data, err := json.Marshal(fileChecksum{Size: info.Size(), ModTime: info.ModTime(), SHA256: hash}); err == nil {
+			if err := m.metadata.Store(ChecksumsBucket, key, data); err != nil {
+				slog.Warn("Failed to cache checksum", "file", path, "error", err)
+			}
+		}
+	}
+	return hash, nil
 }
 
 // downloadFileExistsWithHash checks if a file exists in downloads folder with expected hash
 func (m *Storage) downloadFileExistsWithHash(hashMethod, expectedHash string, pathParts ...string) bool {
-	exists := fileExistsWithHash(m.GetDownloadPath(pathParts...), hashMethod, expectedHash)
+	exists := m.fileExistsWithHash(m.GetDownloadPath(pathParts...), hashMethod, expectedHash)
 
 	if exists {
 		slog.Debug("Match exists, download skipped", "file", filepath.Join(pathParts...), "sha256", expectedHash)
@@ -163,6 +235,11 @@ func (m *Storage) downloadFileExistsWithHash(hashMethod, expectedHash string, pa
 	return exists
 }
 
+// DownloadFileExistsWithHash reports whether a file exists in the downloads folder with the expected hash
+func (m *Storage) DownloadFileExistsWithHash(hashMethod, expectedHash string, pathParts ...string) bool {
+	return m.downloadFileExistsWithHash(hashMethod, expectedHash, pathParts...)
+}
+
 // Download downloads files to the downloads directory (destinations are relative paths)
 func (m *Storage) Download(ctx context.Context, requests ...*DownloadRequest) pond.ResultTaskGroup[Result] {
 	// Convert relative destinations to absolute paths
@@ -238,41 +315,10 @@ func (m *Storage) UncompressedFileExistsOrDownloadAndDecompress(ctx context.Cont
 	return results[0].Destination(), nil
 }
 
-// writeRedirectMap writes the redirects.yaml file at the feed scope
+// writeRedirectMap merges redirects into the redirect map of the feed scope
 // The map uses relative paths from the feed's trusted directory as keys,
 // and redirect targets relative to the feed's base URL as values.
 // Merges with existing redirects to support incremental updates.
 func (m *Storage) writeRedirectMap(redirects map[string]string) error {
-	// Protect read-modify-write with mutex to prevent concurrent updates
-	m.redirectMapMu.Lock()
-	defer m.redirectMapMu.Unlock()
-
-	mapFile := filepath.Join(m.trustedDir, "redirects.yaml")
-
-	// Load existing redirects if file exists
-	existingRedirects := make(map[string]string)
-	if data, err := os.ReadFile(mapFile); err == nil {
-		// File exists, unmarshal it
-		if err := yaml.Unmarshal(data, &existingRedirects); err != nil {
-			return fmt.Errorf("failed to unmarshal existing redirect map: %w", err)
-		}
-	} else if !os.IsNotExist(err) {
-		// Error other than file not existing
-		return fmt.Errorf("failed to read existing redirect map: %w", err)
-	}
-
-	// Merge new redirects into existing ones
-	maps.Copy(existingRedirects, redirects)
-
-	// Write back merged redirects
-	data, err := yaml.Marshal(existingRedirects)
-	if err != nil {
-		return fmt.Errorf("failed to marshal redirect map: %w", err)
-	}
-
-	if err := os.WriteFile(mapFile, data, 0644); err != nil {
-		return fmt.Errorf("failed to write redirect map %s: %w", mapFile, err)
-	}
-
-	return nil
+	return mergeMetadataMap(&m.redirectMapMu, m.metadata, RedirectsBucket, MetadataKey(m.scope), filepath.Join(m.trustedDir, RedirectsFile), redirects)
 }
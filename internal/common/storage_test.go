@@ -1,10 +1,17 @@
 package common
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
 	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
 )
 
 func TestStorage_Scope(t *testing.T) {
@@ -84,3 +91,105 @@ func TestNewStorage(t *testing.T) {
 		assert.Equal(t, filepath.Join("/trusted", "feed1", "repo1"), storage.trustedDir)
 	})
 }
+
+func TestStorage_LinkFilesToTrusted_Signers(t *testing.T) {
+	dir := t.TempDir()
+	storage := NewStorage(nil, filepath.Join(dir, "downloads"), filepath.Join(dir, "trusted"), "feed")
+
+	write := func(name string) string {
+		path := storage.GetDownloadPath(name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(name), 0644))
+		return path
+	}
+
+	readSigners := func() map[string][]string {
+		data, err := os.ReadFile(storage.GetTrustedPath(SignersFile))
+		require.NoError(t, err)
+		signers := make(map[string][]string)
+		require.NoError(t, yaml.Unmarshal(data, &signers))
+		return signers
+	}
+
+	err := storage.LinkFilesToTrusted(context.Background(), []*FileForTrust{
+		{Path: write("a_1.0_amd64.deb"), Distribution: "stable", Source: "a", Signers: []string{"0123456789ABCDEF"}},
+		{Path: write("b_1.0_amd64.deb"), Distribution: "stable", Source: "b"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]string{
+		filepath.Join("stable", "a", "a_1.0_amd64.deb"): {"0123456789ABCDEF"},
+	}, readSigners())
+
+	// Later batches are merged into the existing map
+	err = storage.LinkFilesToTrusted(context.Background(), []*FileForTrust{
+		{Path: write("c_1.0_amd64.deb"), Distribution: "stable", Source: "c", Signers: []string{"FEDCBA9876543210"}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]string{
+		filepath.Join("stable", "a", "a_1.0_amd64.deb"): {"0123456789ABCDEF"},
+		filepath.Join("stable", "c", "c_1.0_amd64.deb"): {"FEDCBA9876543210"},
+	}, readSigners())
+}
+
+func TestStorage_FileHash(t *testing.T) {
+	dir := t.TempDir()
+	storage := NewStorage(nil, filepath.Join(dir, "downloads"), filepath.Join(dir, "trusted"))
+	store := NewFileMetadataStore(filepath.Join(dir, "metadata"))
+	storage.SetMetadataStore(store)
+
+	path := filepath.Join(dir, "file")
+	require.NoError(t, os.WriteFile(path, []byte("content"), 0644))
+	const sum = "ed7002b439e9ac845f22357d822bac1444730fbdb6016d3ec9432297b9ec9f73"
+
+	hash, err := storage.fileHash(path)
+	require.NoError(t, err)
+	assert.Equal(t, sum, hash)
+
+	// Unchanged files are served from the store
+	key := sha256.Sum256([]byte(path))
+	data, ok := loadMetadata(t, store, ChecksumsBucket, hex.EncodeToString(key[:]))
+	require.True(t, ok)
+	var cached fileChecksum
+	require.NoError(t, json.Unmarshal(data, &cached))
+	cached.SHA256 = "cached"
+	data, err = json.Marshal(cached)
+	require.NoError(t, err)
+	require.NoError(t, store.Store(ChecksumsBucket, hex.EncodeToString(key[:]), data))
+
+	hash, err = storage.fileHash(path)
+	require.NoError(t, err)
+	assert.Equal(t, "cached", hash)
+
+	// Changed files are hashed again
+	require.NoError(t, os.WriteFile(path, []byte("changed content"), 0644))
+	hash, err = storage.fileHash(path)
+	require.NoError(t, err)
+	assert.NotEqual(t, "cached", hash)
+	assert.True(t, storage.fileExistsWithHash(path, "sha256", hash))
+}
+
+func TestStorage_LinkFilesToTrusted_MetadataStore(t *testing.T) {
+	dir := t.TempDir()
+	storage := NewStorage(nil, filepath.Join(dir, "downloads"), filepath.Join(dir, "trusted"), "github", "owner")
+	store := NewFileMetadataStore(filepath.Join(dir, "metadata"))
+	storage.SetMetadataStore(store)
+	scoped := storage.Scope("repo")
+
+	path := scoped.GetDownloadPath("a_1.0_amd64.deb")
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, os.WriteFile(path, []byte("a"), 0644))
+
+	err := scoped.LinkFilesToTrusted(context.Background(), []*FileForTrust{
+		{Path: path, Distribution: "stable", Source: "a", Redirect: "v1/a_1.0_amd64.deb", Signers: []string{"0123456789ABCDEF"}},
+	})
+	require.NoError(t, err)
+
+	// Maps are stored under the feed scope and not as files in trusted storage
+	redirects := map[string]string{}
+	found, err := LoadMetadataMap(store, RedirectsBucket, MetadataKey(filepath.Join("github", "owner", "repo")), scoped.GetTrustedPath(RedirectsFile), &redirects)
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, map[string]string{filepath.Join("stable", "a", "a_1.0_amd64.deb"): "v1/a_1.0_amd64.deb"}, redirects)
+	assert.NoFileExists(t, scoped.GetTrustedPath(RedirectsFile))
+	assert.NoFileExists(t, scoped.GetTrustedPath(SignersFile))
+}
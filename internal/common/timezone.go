@@ -0,0 +1,36 @@
+package common
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// DisplayTimeFormat is the format of timestamps shown to humans, ISO 8601 date and time with the zone abbreviation
+// Machine-readable outputs use time.RFC3339
+const DisplayTimeFormat = "2006-01-02 15:04:05 MST"
+
+// displayLocation is the time zone timestamps are shown in, nil = UTC
+var displayLocation atomic.Pointer[time.Location]
+
+// SetDisplayLocation sets the time zone of timestamps in web pages, reports and logs, nil resets to UTC
+func SetDisplayLocation(loc *time.Location) {
+	displayLocation.Store(loc)
+}
+
+// DisplayLocation returns the time zone timestamps are shown in, UTC unless configured
+func DisplayLocation() *time.Location {
+	if loc := displayLocation.Load(); loc != nil {
+		return loc
+	}
+	return time.UTC
+}
+
+// InDisplayLocation returns t in the display time zone
+func InDisplayLocation(t time.Time) time.Time {
+	return t.In(DisplayLocation())
+}
+
+// FormatDisplayTime formats t in the display time zone for humans
+func FormatDisplayTime(t time.Time) string {
+	return InDisplayLocation(t).Format(DisplayTimeFormat)
+}
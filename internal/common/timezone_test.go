@@ -0,0 +1,30 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatDisplayTime(t *testing.T) {
+	t.Cleanup(func() { SetDisplayLocation(nil) })
+
+	at := time.Date(2026, 3, 10, 23, 30, 0, 0, time.FixedZone("CET", 3600))
+	tests := []struct {
+		name string
+		loc  *time.Location
+		want string
+	}{
+		{name: "defaults to UTC", loc: nil, want: "2026-03-10 22:30:00 UTC"},
+		{name: "configured zone", loc: time.FixedZone("JST", 9*3600), want: "2026-03-11 07:30:00 JST"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetDisplayLocation(tt.loc)
+			assert.Equal(t, tt.want, FormatDisplayTime(at))
+			assert.True(t, at.Equal(InDisplayLocation(at)))
+		})
+	}
+}
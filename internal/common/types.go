@@ -1,5 +1,11 @@
 package common
 
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+)
+
 const (
 	MainComponent  = "main"
 	DebugComponent = "debug"
@@ -13,6 +19,189 @@ type PackageOptions struct {
 	Debug bool `yaml:"debug"`
 	// Source indicates whether to include source packages
 	Source bool `yaml:"source"`
+	// SourceOnly publishes only source packages, binary packages are neither fetched nor indexed (requires Source)
+	SourceOnly bool `yaml:"source_only,omitempty"`
+	// AlwaysEmitDebug publishes the debug component of distributions without debug packages (requires Debug)
+	AlwaysEmitDebug bool `yaml:"always_emit_debug,omitempty"`
+	// Debuginfod publishes the debug files of debug packages for debuginfod clients (requires Debug)
+	Debuginfod bool `yaml:"debuginfod,omitempty"`
+	// Buildinfo indicates whether to include .buildinfo files referenced by .changes files
+	Buildinfo bool `yaml:"buildinfo"`
+}
+
+// LinkOptions contains user-facing URLs of a repository
+type LinkOptions struct {
+	// Homepage of the packaged project
+	Homepage string `yaml:"homepage,omitempty"`
+	// Documentation for using the repository or packages
+	Documentation string `yaml:"documentation,omitempty"`
+	// Support channel for questions
+	Support string `yaml:"support,omitempty"`
+	// Bugs is the bug tracker for packaging issues
+	Bugs string `yaml:"bugs,omitempty"`
+}
+
+// ReleaseFields returns the configured links as custom Release file fields
+func (l LinkOptions) ReleaseFields() map[string]string {
+	fields := make(map[string]string)
+	if l.Homepage != "" {
+		fields["X-Homepage"] = l.Homepage
+	}
+	if l.Documentation != "" {
+		fields["X-Documentation"] = l.Documentation
+	}
+	if l.Support != "" {
+		fields["X-Support"] = l.Support
+	}
+	if l.Bugs != "" {
+		fields["X-Bugs"] = l.Bugs
+	}
+	return fields
+}
+
+// IsEmpty reports whether no link is configured
+func (l LinkOptions) IsEmpty() bool {
+	return l == LinkOptions{}
+}
+
+// PolicyAction decides what happens to packages violating a policy
+type PolicyAction string
+
+// Policy actions
+const (
+	PolicyActionWarn PolicyAction = "warn" // Log and keep the package
+	PolicyActionSkip PolicyAction = "skip" // Log and leave the package out of the repository
+	PolicyActionFail PolicyAction = "fail" // Abort generating the repository
+)
+
+// ConflictAction decides what happens to a package version provided with different content by several feeds
+type ConflictAction string
+
+// Conflict actions
+const (
+	ConflictActionPrefer ConflictAction = "prefer" // Log and keep the package of the preferred feed
+	ConflictActionFail   ConflictAction = "fail"   // Abort generating the repository
+)
+
+// PolicyOptions limits the content of a repository to protect hosting limits
+type PolicyOptions struct {
+	// MaxFileSizeMB is the maximum size of a single pool file in MiB, 0 = unlimited
+	MaxFileSizeMB int64 `yaml:"max_file_size_mb,omitempty"`
+	// MaxPackagesPerSource is the maximum number of binary packages built from one source per distribution, 0 = unlimited
+	MaxPackagesPerSource int `yaml:"max_packages_per_source,omitempty"`
+	// Action on violations, empty = warn
+	Action PolicyAction `yaml:"action,omitempty"`
+	// Conflicts decides about a package version provided with different content by several feeds, empty = prefer
+	Conflicts ConflictAction `yaml:"conflicts,omitempty"`
+	// Regressions decides about a latest version lower than the one of the previous build, empty = warn
+	// Only warn and fail are supported, the previous version is not carried over
+	Regressions PolicyAction `yaml:"regressions,omitempty"`
+}
+
+// SnapshotOptions configures immutable point-in-time snapshots of a repository
+type SnapshotOptions struct {
+	// Keep is the number of snapshots kept, 0 = disabled
+	// A snapshot is taken by builds changing the package indexes
+	Keep int `yaml:"keep,omitempty"`
+}
+
+// GetConflicts returns the configured conflict action, prefer if not set
+func (p PolicyOptions) GetConflicts() ConflictAction {
+	if p.Conflicts == "" {
+		return ConflictActionPrefer
+	}
+	return p.Conflicts
+}
+
+// GetRegressions returns the configured version regression action, warn if not set
+func (p PolicyOptions) GetRegressions() PolicyAction {
+	if p.Regressions == "" {
+		return PolicyActionWarn
+	}
+	return p.Regressions
+}
+
+// IsEnabled reports whether any limit is configured
+func (p PolicyOptions) IsEnabled() bool {
+	return p.MaxFileSizeMB > 0 || p.MaxPackagesPerSource > 0
+}
+
+// GetAction returns the configured action, warn if not set
+func (p PolicyOptions) GetAction() PolicyAction {
+	if p.Action == "" {
+		return PolicyActionWarn
+	}
+	return p.Action
+}
+
+// ReleaseMetadata contains the identifying fields of a Release file
+// Values are Go templates with {{.Repository}} and {{.Distribution}} available
+type ReleaseMetadata struct {
+	// Origin of the repository, matched by apt pinning with o=
+	Origin string `yaml:"origin,omitempty"`
+	// Label of the repository, matched by apt pinning with l=
+	Label string `yaml:"label,omitempty"`
+	// Suite of the distribution, matched by apt pinning with a=
+	Suite string `yaml:"suite,omitempty"`
+}
+
+// ReleaseOptions configures the Release file metadata of a repository
+// Distributions override the repository-wide values per distribution
+type ReleaseOptions struct {
+	ReleaseMetadata `yaml:",inline"`
+	Distributions   map[string]ReleaseMetadata `yaml:"distributions,omitempty"`
+}
+
+// defaultReleaseMetadata keeps the historical "name dist" origin and label
+var defaultReleaseMetadata = ReleaseMetadata{
+	Origin: "{{.Repository}} {{.Distribution}}",
+	Label:  "{{.Repository}} {{.Distribution}}",
+	Suite:  "{{.Distribution}}",
+}
+
+// Resolve returns the rendered Release metadata for a repository distribution
+func (r ReleaseOptions) Resolve(repository, dist string) (ReleaseMetadata, error) {
+	metadata := defaultReleaseMetadata
+	for _, override := range []ReleaseMetadata{r.ReleaseMetadata, r.Distributions[dist]} {
+		if override.Origin != "" {
+			metadata.Origin = override.Origin
+		}
+		if override.Label != "" {
+			metadata.Label = override.Label
+		}
+		if override.Suite != "" {
+			metadata.Suite = override.Suite
+		}
+	}
+
+	data := struct {
+		Repository   string
+		Distribution string
+	}{repository, dist}
+
+	var err error
+	for _, field := range []*string{&metadata.Origin, &metadata.Label, &metadata.Suite} {
+		if *field, err = renderReleaseTemplate(*field, data); err != nil {
+			return ReleaseMetadata{}, err
+		}
+	}
+
+	return metadata, nil
+}
+
+// renderReleaseTemplate executes a release metadata template
+func renderReleaseTemplate(text string, data any) (string, error) {
+	tmpl, err := template.New("release").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid template %q: %w", text, err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("invalid template %q: %w", text, err)
+	}
+
+	return buf.String(), nil
 }
 
 // RepositoryConfig options which can be relevant for feeds to download only requested packages
@@ -25,4 +214,16 @@ type RepositoryOptions struct {
 	Architectures []string `yaml:"architectures,omitempty"`
 	// Retention policies for version filtering - which versions to keep
 	Retention []RetentionPolicy `yaml:"retention,omitempty"`
+	// Pin lists exact versions retention never removes, e.g. referenced by compliance builds
+	Pin []PinnedVersion `yaml:"pin,omitempty"`
+	// Links are embedded in Release files, the web page and the install script
+	Links LinkOptions `yaml:"links,omitempty"`
+	// Release configures Origin, Label and Suite of the Release files
+	Release ReleaseOptions `yaml:"release,omitempty"`
+	// Policy limits file sizes and package counts of the composed repository
+	Policy PolicyOptions `yaml:"policy,omitempty"`
+	// ComponentPerFeed publishes the packages of each feed in its own component instead of main
+	ComponentPerFeed bool `yaml:"component_per_feed,omitempty"`
+	// Snapshots publishes earlier states of the repository under snapshots/<timestamp>/ to pin to
+	Snapshots SnapshotOptions `yaml:"snapshots,omitempty"`
 }
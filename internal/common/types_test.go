@@ -0,0 +1,85 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReleaseOptions_Resolve(t *testing.T) {
+	tests := []struct {
+		name    string
+		options ReleaseOptions
+		dist    string
+		want    ReleaseMetadata
+		wantErr bool
+	}{
+		{
+			name: "defaults",
+			dist: "noble",
+			want: ReleaseMetadata{Origin: "myrepo noble", Label: "myrepo noble", Suite: "noble"},
+		},
+		{
+			name: "repository values",
+			options: ReleaseOptions{
+				ReleaseMetadata: ReleaseMetadata{Origin: "example.com", Label: "{{.Repository}}"},
+			},
+			dist: "noble",
+			want: ReleaseMetadata{Origin: "example.com", Label: "myrepo", Suite: "noble"},
+		},
+		{
+			name: "distribution override",
+			options: ReleaseOptions{
+				ReleaseMetadata: ReleaseMetadata{Origin: "example.com"},
+				Distributions: map[string]ReleaseMetadata{
+					"noble": {Label: "{{.Repository}}-{{.Distribution}}", Suite: "stable"},
+				},
+			},
+			dist: "noble",
+			want: ReleaseMetadata{Origin: "example.com", Label: "myrepo-noble", Suite: "stable"},
+		},
+		{
+			name: "override of other distribution ignored",
+			options: ReleaseOptions{
+				Distributions: map[string]ReleaseMetadata{
+					"jammy": {Suite: "oldstable"},
+				},
+			},
+			dist: "noble",
+			want: ReleaseMetadata{Origin: "myrepo noble", Label: "myrepo noble", Suite: "noble"},
+		},
+		{
+			name: "unknown template field",
+			options: ReleaseOptions{
+				ReleaseMetadata: ReleaseMetadata{Origin: "{{.Name}}"},
+			},
+			dist:    "noble",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.options.Resolve("myrepo", tt.dist)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestPolicyOptions(t *testing.T) {
+	assert.False(t, PolicyOptions{}.IsEnabled())
+	assert.True(t, PolicyOptions{MaxFileSizeMB: 1}.IsEnabled())
+	assert.True(t, PolicyOptions{MaxPackagesPerSource: 1}.IsEnabled())
+
+	assert.Equal(t, PolicyActionWarn, PolicyOptions{}.GetAction())
+	assert.Equal(t, PolicyActionFail, PolicyOptions{Action: PolicyActionFail}.GetAction())
+
+	assert.Equal(t, PolicyActionWarn, PolicyOptions{}.GetRegressions())
+	assert.Equal(t, PolicyActionFail, PolicyOptions{Regressions: PolicyActionFail}.GetRegressions())
+}
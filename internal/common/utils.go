@@ -1,6 +1,7 @@
 package common
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
@@ -40,6 +41,48 @@ func EnsureHardlink(src, dst string) error {
 	return os.Link(src, dst)
 }
 
+// WriteFileAtomic writes data to path through a temporary file and rename
+// Readers never see a partially written file and hardlinked copies of the previous file stay intact
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-"+filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// SwapSymlink atomically points the symlink at linkPath to target
+// A temporary symlink next to linkPath is renamed over it, so readers see either the old or the new target
+func SwapSymlink(linkPath, target string) error {
+	tmp := linkPath + ".tmp"
+	_ = os.Remove(tmp)
+	if err := os.Symlink(target, tmp); err != nil {
+		return fmt.Errorf("failed to create temporary symlink: %w", err)
+	}
+	if err := os.Rename(tmp, linkPath); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("failed to swap symlink: %w", err)
+	}
+	return nil
+}
+
 // MatchesGlobPatterns checks if a value matches the given glob patterns.
 // Empty patterns list means match all.
 // Patterns support wildcards (* and ?).
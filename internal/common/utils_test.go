@@ -226,3 +226,61 @@ func TestEnsureHardlink(t *testing.T) {
 		assert.True(t, os.SameFile(srcInfo, dstInfo))
 	})
 }
+
+func TestSwapSymlink(t *testing.T) {
+	dir := t.TempDir()
+	link := filepath.Join(dir, "current")
+	first := filepath.Join(dir, "first")
+	second := filepath.Join(dir, "second")
+
+	// Creates the symlink if missing
+	require.NoError(t, SwapSymlink(link, first))
+	target, err := os.Readlink(link)
+	require.NoError(t, err)
+	assert.Equal(t, first, target)
+
+	// Replaces an existing one and leaves no temporary symlink behind
+	require.NoError(t, os.Symlink(first, link+".tmp"))
+	require.NoError(t, SwapSymlink(link, second))
+	target, err = os.Readlink(link)
+	require.NoError(t, err)
+	assert.Equal(t, second, target)
+	assert.NoFileExists(t, link+".tmp")
+
+	// A directory in place is not replaced
+	dirLink := filepath.Join(dir, "www")
+	require.NoError(t, os.Mkdir(dirLink, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dirLink, "index.html"), nil, 0644))
+	assert.Error(t, SwapSymlink(dirLink, first))
+	assert.FileExists(t, filepath.Join(dirLink, "index.html"))
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "keys", "signing-key.asc")
+
+	// Missing directories are created
+	require.NoError(t, WriteFileAtomic(path, []byte("first"), 0600))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "first", string(data))
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// A hardlinked copy keeps the previous content
+	copied := filepath.Join(dir, "copy.asc")
+	require.NoError(t, os.Link(path, copied))
+	require.NoError(t, WriteFileAtomic(path, []byte("second"), 0644))
+	data, err = os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "second", string(data))
+	data, err = os.ReadFile(copied)
+	require.NoError(t, err)
+	assert.Equal(t, "first", string(data))
+
+	// No temporary files are left behind
+	entries, err := os.ReadDir(filepath.Dir(path))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
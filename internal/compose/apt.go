@@ -2,14 +2,18 @@ package compose
 
 import (
 	"context"
+	"crypto/sha256"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"maps"
 	"os"
 	"path/filepath"
 	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/alitto/pond/v2"
@@ -19,7 +23,7 @@ import (
 	"github.com/dionysius/aarg/debext"
 	"github.com/dionysius/aarg/internal/common"
 	"github.com/dionysius/aarg/internal/feed"
-	"gopkg.in/yaml.v3"
+	"github.com/dionysius/aarg/internal/telemetry"
 )
 
 // Apt composes Debian repository structure from trusted files
@@ -31,31 +35,142 @@ type Apt struct {
 	decompressor *common.DeCompressor                            // Decompressor for package files
 	pool         pond.Pool                                       // Coordination pool for parallel operations
 	redirectMaps map[string]map[string]string                    // Redirect maps per feed (feedRelPath -> map[relPath]redirect), immutable after loading
+	signerMaps   map[string]map[string][]string                  // Signer maps per feed (feedRelPath -> map[relPath]keyIDs), immutable after loading
+	origins      sync.Map                                        // Feed a package was read from (*deb.Package -> *feed.FeedOptions)
+	fetched      sync.Map                                        // Modification time of the trusted package file (*deb.Package -> time.Time)
+	files        sync.Map                                        // Trusted files of a package relative to TrustedDir (*deb.Package -> []string)
+	newest       time.Time                                       // Modification time of the newest trusted package file of the composed repository
+	conflicts    []PackageConflict                               // Conflicting package versions resolved by feed precedence
+	violations   []PolicyViolation                               // Packages violating the repository policy
+	regressions  []VersionRegression                             // Packages whose latest version went backwards since the previous build
+	buildinfos   map[string][]string                             // Buildinfo files per target distribution (dist -> relPaths)
+	buildinfoMu  sync.Mutex                                      // Protects buildinfos during parallel feed processing
+
+	publishedBuildinfo map[string][]string // Published buildinfo paths per source version
+
+	previousFingerprints map[string]string // Distribution fingerprints of the previous build, incremental only
+	fingerprints         map[string]string // Distribution fingerprints of this build, incremental only
+	fingerprintMu        sync.Mutex        // Protects fingerprints and reused during parallel distribution generation
+	reused               int               // Distributions hardlinked from the previous build
+
+	previousState *debext.Repository // Repository of the previous build, partial generation only
+	keptDists     map[string]bool    // Distributions kept from the previous build, partial generation only
+
+	previousChecksums map[string]utils.ChecksumInfo // Checksums of the trusted files of the previous build, pool verification only
+	checked           sync.Map                      // Trusted files decided on by pool verification (path -> struct{})
+	verified          atomic.Int64                  // Trusted files whose checksums were verified while linking
+}
+
+// keptPackage is a retained package with its location and origin feed
+type keptPackage struct {
+	dist, component string
+	pkg             *deb.Package
+	feed            *feed.FeedOptions
+}
+
+// ErrPackageConflict is returned if feeds provide a package version with different content and conflicts fail
+var ErrPackageConflict = errors.New("package provided with different content by several feeds")
+
+// PackageConflict describes a package version provided with different content by several feeds
+type PackageConflict struct {
+	Distribution  string `json:"distribution"`   // Target distribution
+	Component     string `json:"component"`      // Target component of the discarded package
+	KeptComponent string `json:"kept_component"` // Target component of the kept package
+	Package       string `json:"package"`        // Package name, version and architecture
+	Kept          string `json:"kept"`           // Feed whose package was kept
+	Discarded     string `json:"discarded"`      // Feed whose package was discarded
 }
 
 // NewApt creates a new Apt composer
 func NewApt(options *AptComposeOptions, verifier *debext.Verifier, signer pgp.Signer, decompressor *common.DeCompressor, pool pond.Pool) *Apt {
 	return &Apt{
 		options:      options,
-		collector:    common.NewPackageRetentionCollector(options.Repository.Retention),
+		collector:    common.NewPackageRetentionCollector(options.Repository.Retention, options.Repository.Pin),
 		verifier:     verifier,
 		signer:       signer,
 		decompressor: decompressor,
 		pool:         pool,
 		redirectMaps: make(map[string]map[string]string),
+		signerMaps:   make(map[string]map[string][]string),
+		buildinfos:   make(map[string][]string),
+
+		publishedBuildinfo: make(map[string][]string),
 	}
 }
 
 // Compose generates the apt repository structure and returns the repository object
 func (a *Apt) Compose(ctx context.Context) (*debext.Repository, error) {
-	// Load redirect maps if in redirect mode
-	if a.options.PoolMode == "redirect" {
-		if err := a.loadRedirectMaps(); err != nil {
+	ctx, span := telemetry.Start(ctx, "compose.apt")
+	defer span.End()
+
+	if err := a.collect(ctx); err != nil {
+		return nil, err
+	}
+
+	// Distributions not selected for generation stay as published in the previous build
+	if len(a.options.Distributions) > 0 {
+		a.loadKeptDistributions()
+	}
+
+	repo, err := a.buildRepository()
+	if err != nil {
+		return nil, err
+	}
+
+	if a.options.VerifyPool == VerifyPoolSample || a.options.VerifyPool == VerifyPoolFull {
+		a.previousChecksums = loadPoolChecksums(a.options.Previous, a.options.Name)
+	}
+
+	if a.options.Incremental {
+		if a.options.Previous != "" {
+			a.previousFingerprints = loadDistFingerprints(filepath.Join(a.options.Previous, a.options.Name))
+		}
+		a.fingerprints = make(map[string]string)
+	}
+
+	if err := a.generateRepository(ctx, repo); err != nil {
+		return nil, err
+	}
+
+	// The next incremental build compares against the fingerprints of this one
+	if a.options.Incremental {
+		if err := writeJSON(filepath.Join(a.options.Target, DistFingerprintsFile), a.fingerprints); err != nil {
+			return nil, err
+		}
+	}
+
+	if a.options.Repository.Packages.Buildinfo {
+		published, err := a.publishBuildinfo(repo)
+		if err != nil {
+			return nil, err
+		}
+		slog.Debug("Published build information", "repository", a.options.Name, "files", published)
+	}
+
+	if a.options.Repository.Packages.Debuginfod {
+		published, err := a.publishDebuginfod(repo)
+		if err != nil {
+			return nil, err
+		}
+		slog.Debug("Published debug files for debuginfod", "repository", a.options.Name, "files", published)
+	}
+
+	if a.options.Repository.Snapshots.Keep > 0 {
+		if err := a.composeSnapshots(); err != nil {
 			return nil, err
 		}
 	}
 
-	// Process all feeds in parallel
+	return repo, nil
+}
+
+// collect processes all feeds in parallel and adds their packages to the retention collector
+func (a *Apt) collect(ctx context.Context) error {
+	// Load redirect and signer maps, the upstream location of files is recorded in any pool mode
+	if err := a.loadFeedMaps(); err != nil {
+		return err
+	}
+
 	// Create subpool for feed processing
 	feedPool := a.pool.NewSubpool(10)
 	defer feedPool.StopAndWait()
@@ -63,20 +178,17 @@ func (a *Apt) Compose(ctx context.Context) (*debext.Repository, error) {
 	group := feedPool.NewGroup()
 	for _, feed := range a.options.Feeds {
 		group.SubmitErr(func() error {
-			return a.processFeed(feed)
+			_, span := telemetry.Start(ctx, "compose.apt.feed",
+				telemetry.FeedTypeKey.String(string(feed.Type)),
+				telemetry.FeedNameKey.String(feed.Name),
+			)
+			err := a.processFeed(feed)
+			telemetry.End(span, err)
+			return err
 		})
 	}
 
-	if err := group.Wait(); err != nil {
-		return nil, err
-	}
-
-	repo := a.buildRepository()
-	if err := a.generateRepository(ctx, repo); err != nil {
-		return nil, err
-	}
-
-	return repo, nil
+	return group.Wait()
 }
 
 func (a *Apt) generateRepository(ctx context.Context, repository *debext.Repository) error {
@@ -89,7 +201,10 @@ func (a *Apt) generateRepository(ctx context.Context, repository *debext.Reposit
 
 	for _, dist := range repository.GetDistributions() {
 		group.SubmitErr(func() error {
-			return a.generateDistribution(ctx, repository, dist)
+			ctx, span := telemetry.Start(ctx, "compose.apt.distribution", telemetry.DistributionKey.String(dist))
+			err := a.generateDistribution(ctx, repository, dist)
+			telemetry.End(span, err)
+			return err
 		})
 	}
 
@@ -98,15 +213,35 @@ func (a *Apt) generateRepository(ctx context.Context, repository *debext.Reposit
 
 // generateDistribution generates repository structure for a single distribution
 func (a *Apt) generateDistribution(ctx context.Context, repo *debext.Repository, dist string) error {
-	comps := []string{common.MainComponent}
+	if a.keptDists[dist] {
+		return a.keepDistribution(repo, dist)
+	}
 
-	// Include debug component if enabled
-	if a.options.Repository.Packages.Debug {
-		comps = append(comps, common.DebugComponent)
+	comps := a.components(repo, dist)
+
+	// Unchanged distributions are taken from the previous build, only their pool files are linked
+	if a.options.Incremental {
+		fingerprint, err := a.distFingerprint(repo, dist, comps)
+		if err != nil {
+			return err
+		}
+
+		reused := a.reuseDistribution(dist, fingerprint)
+		a.recordFingerprint(dist, fingerprint, reused)
+		if reused {
+			for _, comp := range comps {
+				for _, arch := range repo.GetArchitectures(dist, comp, a.options.Repository.Packages.Source) {
+					if err := a.linkPackagesToPool(repo, dist, comp, arch); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		}
 	}
 
 	// Collect index files from all components
-	var allIndexFiles sync.Map
+	var allIndexFiles, contentsFiles sync.Map
 
 	// Parallelize components since each component has its own PackageList
 	// Create subpool for component processing
@@ -118,16 +253,30 @@ func (a *Apt) generateDistribution(ctx context.Context, repo *debext.Repository,
 	for _, comp := range comps {
 		group.SubmitErr(func() error {
 			arches := repo.GetArchitectures(dist, comp, a.options.Repository.Packages.Source)
+			if len(arches) == 0 {
+				// Components without packages in this distribution get empty indexes for the architectures of the others
+				arches = a.packageArchitectures(repo, dist)
+			}
 
 			// Process architectures sequentially - PackageList is not thread-safe
 			for _, arch := range arches {
-				files, err := a.generatePackageIndex(ctx, repo, dist, comp, arch)
+				relPath, checksums, err := a.generatePackageIndex(repo, dist, comp, arch)
 				if err != nil {
 					return err
 				}
 
-				for k, v := range files {
-					allIndexFiles.Store(k, v)
+				if relPath != "" {
+					allIndexFiles.Store(relPath, checksums)
+				}
+
+				if a.options.Contents && arch != debext.SourceArchitecture {
+					relPath, err := a.generateContentsIndex(repo, dist, comp, arch)
+					if err != nil {
+						return err
+					}
+					if relPath != "" {
+						contentsFiles.Store(relPath, struct{}{})
+					}
 				}
 
 				if err := a.linkPackagesToPool(repo, dist, comp, arch); err != nil {
@@ -135,6 +284,17 @@ func (a *Apt) generateDistribution(ctx context.Context, repo *debext.Repository,
 				}
 			}
 
+			// Translations are compressed and referenced like the Packages indexes
+			if a.options.Translations {
+				relPath, checksums, err := a.generateTranslationIndex(repo, dist, comp)
+				if err != nil {
+					return err
+				}
+				if relPath != "" {
+					allIndexFiles.Store(relPath, checksums)
+				}
+			}
+
 			return nil
 		})
 	}
@@ -150,6 +310,28 @@ func (a *Apt) generateDistribution(ctx context.Context, repo *debext.Repository,
 		return true
 	})
 
+	// Patches from the indexes of the previous build, before compressed variants are added
+	pdiffs, err := a.generatePDiffs(dist, indexFilesMap)
+	if err != nil {
+		return err
+	}
+
+	// Compress all index files of this distribution in one batch
+	if err := a.compressIndexFiles(ctx, dist, indexFilesMap); err != nil {
+		return err
+	}
+	maps.Copy(indexFilesMap, pdiffs)
+
+	// Contents indexes are only published gzipped like in the Debian archive
+	var contentsPaths []string
+	contentsFiles.Range(func(key, _ any) bool {
+		contentsPaths = append(contentsPaths, key.(string))
+		return true
+	})
+	if err := a.compressContentsIndexes(ctx, dist, contentsPaths, indexFilesMap); err != nil {
+		return err
+	}
+
 	// Generate distribution-level Release file if there are any index files
 	if len(indexFilesMap) > 0 {
 		if err := a.generateRelease(repo, dist, indexFilesMap); err != nil {
@@ -160,9 +342,78 @@ func (a *Apt) generateDistribution(ctx context.Context, repo *debext.Repository,
 	return nil
 }
 
-func (a *Apt) generatePackageIndex(ctx context.Context, repo *debext.Repository, dist, comp, arch string) (map[string]utils.ChecksumInfo, error) {
-	indexFiles := make(map[string]utils.ChecksumInfo)
+// components returns the components published for a distribution
+// Every distribution gets all package components of the repository, so the same sources work for all of them
+// The debug component is left out of distributions without debug packages unless always_emit_debug is set
+func (a *Apt) components(repo *debext.Repository, dist string) []string {
+	comps := PackageComponents(repo)
+	if EmitsDebugComponent(a.options.Repository.Packages, repo, dist) {
+		comps = append(comps, common.DebugComponent)
+	}
+	return comps
+}
+
+// PackageComponents returns the components of a repository holding packages, all but the debug component
+// Repositories without component_per_feed publish all packages in main
+func PackageComponents(repo *debext.Repository) []string {
+	comps := make(map[string]struct{})
+	for _, dist := range repo.GetDistributions() {
+		for _, comp := range repo.GetComponents(dist) {
+			if comp != common.DebugComponent {
+				comps[comp] = struct{}{}
+			}
+		}
+	}
+	if len(comps) == 0 {
+		return []string{common.MainComponent}
+	}
+	return slices.Sorted(maps.Keys(comps))
+}
+
+// packageArchitectures returns the architectures of all package components of a distribution
+func (a *Apt) packageArchitectures(repo *debext.Repository, dist string) []string {
+	arches := make(map[string]struct{})
+	for _, comp := range PackageComponents(repo) {
+		for _, arch := range repo.GetArchitectures(dist, comp, a.options.Repository.Packages.Source) {
+			arches[arch] = struct{}{}
+		}
+	}
+	return slices.Sorted(maps.Keys(arches))
+}
+
+// feedComponent returns the component the packages of a feed are published in
+func (a *Apt) feedComponent(feedOpts *feed.FeedOptions) string {
+	if !a.options.Repository.ComponentPerFeed {
+		return common.MainComponent
+	}
+	if feedOpts.TargetComponent != "" {
+		return feedOpts.TargetComponent
+	}
+	return feedOpts.DefaultComponent()
+}
+
+// EmitsDebugComponent reports whether the debug component of a distribution is published
+func EmitsDebugComponent(packages common.PackageOptions, repo *debext.Repository, dist string) bool {
+	if !packages.Debug {
+		return false
+	}
+	if packages.AlwaysEmitDebug {
+		return true
+	}
+	pkgList := repo.GetPackageList(dist, common.DebugComponent)
+	return pkgList != nil && pkgList.Len() > 0
+}
+
+// releaseComponents returns the components announced in the Release file of a distribution
+func (a *Apt) releaseComponents(repo *debext.Repository, dist string) []string {
+	comps := a.components(repo, dist)
+	slices.Sort(comps)
+	return comps
+}
 
+// generatePackageIndex writes the uncompressed Packages or Sources index for a single architecture
+// Returns the index path relative to the distribution directory and its checksums, or an empty path if no index was written
+func (a *Apt) generatePackageIndex(repo *debext.Repository, dist, comp, arch string) (string, utils.ChecksumInfo, error) {
 	isSource := arch == debext.SourceArchitecture
 	archDirname := "binary-" + arch
 	if isSource {
@@ -174,6 +425,10 @@ func (a *Apt) generatePackageIndex(ctx context.Context, repo *debext.Repository,
 
 	// Get the full package list for this distribution and component
 	allPackages := repo.GetPackageList(dist, comp)
+	if allPackages == nil {
+		// Component without packages in this distribution
+		allPackages = deb.NewPackageList()
+	}
 	allPackages.PrepareIndex()
 
 	// Filter packages by architecture using aptly's query
@@ -181,74 +436,102 @@ func (a *Apt) generatePackageIndex(ctx context.Context, repo *debext.Repository,
 		Queries: []deb.PackageQuery{&deb.FieldQuery{Field: "$Architecture", Relation: deb.VersionEqual, Value: arch}},
 	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to filter packages for architecture %s: %w", arch, err)
+		return "", utils.ChecksumInfo{}, fmt.Errorf("failed to filter packages for architecture %s: %w", arch, err)
 	}
 
 	if pkgList == nil && isSource && a.options.Repository.Packages.Source {
 		// Special case: create empty Sources index if source packages are enabled
-		// Debug component typically has no sources, so no warning needed
-		if comp == common.MainComponent {
+		// Debug component typically has no sources and empty components have no packages at all, so no warning needed
+		if comp != common.DebugComponent && allPackages.Len() > 0 {
 			slog.Warn("empty source package list but sources are enabled", "dist", dist, "component", comp)
 		}
 
 		pkgList = deb.NewPackageList()
 	}
 
-	if pkgList != nil {
-		if err := os.MkdirAll(archDirPath, 0755); err != nil {
-			return nil, err
-		}
+	if pkgList == nil {
+		return "", utils.ChecksumInfo{}, nil
+	}
 
-		indexFilename := "Packages"
-		if isSource {
-			indexFilename = "Sources"
-		}
+	if err := os.MkdirAll(archDirPath, 0755); err != nil {
+		return "", utils.ChecksumInfo{}, err
+	}
 
-		targetFilepath := filepath.Join(archDirPath, indexFilename)
+	indexFilename := "Packages"
+	if isSource {
+		indexFilename = "Sources"
+	}
 
-		f, err := os.Create(targetFilepath)
-		if err != nil {
-			return nil, err
-		}
-		defer func() {
-			if cerr := f.Close(); cerr != nil && err == nil {
-				err = cerr
-			}
-		}()
+	targetFilepath := filepath.Join(archDirPath, indexFilename)
 
-		if err := debext.GeneratePackageIndex(f, pkgList, isSource); err != nil {
-			return nil, err
-		}
+	f, err := os.Create(targetFilepath)
+	if err != nil {
+		return "", utils.ChecksumInfo{}, err
+	}
 
-		indexFiles[relArchDirpath+"/"+indexFilename], err = utils.ChecksumsForFile(targetFilepath)
-		if err != nil {
-			return nil, err
-		}
+	// Long descriptions go into the Translation-en index of the component
+	if a.options.Translations && !isSource {
+		err = debext.GenerateTranslatedPackageIndex(f, pkgList)
+	} else {
+		err = debext.GeneratePackageIndex(f, pkgList, isSource)
+	}
+	if err != nil {
+		_ = f.Close()
+		return "", utils.ChecksumInfo{}, err
+	}
+
+	if err := f.Close(); err != nil {
+		return "", utils.ChecksumInfo{}, err
+	}
+
+	checksums, err := utils.ChecksumsForFile(targetFilepath)
+	if err != nil {
+		return "", utils.ChecksumInfo{}, err
+	}
+
+	return relArchDirpath + "/" + indexFilename, checksums, nil
+}
+
+// compressIndexFiles compresses all uncompressed index files of a distribution with all formats
+// and adds the checksums of the compressed variants to files
+func (a *Apt) compressIndexFiles(ctx context.Context, dist string, files map[string]utils.ChecksumInfo) error {
+	if len(files) == 0 {
+		return nil
+	}
+
+	distDirPath := filepath.Join(a.options.Target, "dists", dist)
+
+	sourcePaths := make([]string, 0, len(files))
+	for _, relPath := range slices.Sorted(maps.Keys(files)) {
+		sourcePaths = append(sourcePaths, filepath.Join(distDirPath, relPath))
+	}
+
+	group := a.decompressor.CompressFiles(ctx, sourcePaths,
+		common.CompressionGzip,
+		common.CompressionBzip2,
+		common.CompressionXZ,
+	)
+	results, err := group.Wait()
+	if err != nil {
+		return err
+	}
 
-		// Compress index file with all formats in parallel
-		group := a.decompressor.Compress(ctx, targetFilepath,
-			common.CompressionGzip,
-			common.CompressionBzip2,
-			common.CompressionXZ,
-		)
-		results, err := group.Wait()
+	// Generate checksums for compressed files
+	for _, result := range results {
+		compressedFilepath := result.Destination()
+
+		relPath, err := filepath.Rel(distDirPath, compressedFilepath)
 		if err != nil {
-			return nil, err
+			return err
 		}
 
-		// Generate checksums for compressed files
-		for _, result := range results {
-			compressedFilepath := result.Destination()
-			compressedFilename := filepath.Base(compressedFilepath)
-
-			indexFiles[relArchDirpath+"/"+compressedFilename], err = utils.ChecksumsForFile(compressedFilepath)
-			if err != nil {
-				return nil, err
-			}
+		files[filepath.ToSlash(relPath)], err = utils.ChecksumsForFile(compressedFilepath)
+		if err != nil {
+			return err
 		}
 	}
 
-	return indexFiles, nil
+	return nil
 }
 
 // linkPackagesToPool creates hardlinks for all package files from trusted storage to output pool
@@ -260,6 +543,9 @@ func (a *Apt) linkPackagesToPool(repo *debext.Repository, dist, comp, arch strin
 
 	// Get the full package list for this distribution and component
 	allPackages := repo.GetPackageList(dist, comp)
+	if allPackages == nil {
+		return nil
+	}
 	allPackages.PrepareIndex()
 
 	// Filter and link packages by architecture using aptly's query
@@ -311,6 +597,9 @@ func (a *Apt) linkPackageFile(pkg *deb.Package, comp string) error {
 	for _, file := range pkg.Files() {
 		sourcePath := filepath.Join(a.options.Trusted, relOrigDir, file.Filename)
 		targetPath := filepath.Join(a.options.Target, relTargetDir, file.Filename)
+		if err := a.verifyPoolFile(sourcePath, filepath.Join(relOrigDir, file.Filename), file.Checksums); err != nil {
+			return err
+		}
 		if err := common.EnsureHardlink(sourcePath, targetPath); err != nil {
 			return err
 		}
@@ -319,6 +608,13 @@ func (a *Apt) linkPackageFile(pkg *deb.Package, comp string) error {
 	return nil
 }
 
+// releaseFields returns the custom fields of the Release files: repository links and generator fields
+func (a *Apt) releaseFields() map[string]string {
+	fields := a.options.Repository.Links.ReleaseFields()
+	maps.Copy(fields, a.options.ReleaseFields)
+	return fields
+}
+
 // generateRelease generates the distribution-level Release file
 func (a *Apt) generateRelease(repo *debext.Repository, dist string, files map[string]utils.ChecksumInfo) error {
 	// Collect all unique architectures from all components
@@ -336,15 +632,26 @@ func (a *Apt) generateRelease(repo *debext.Repository, dist string, files map[st
 	}
 	slices.Sort(arches)
 
+	// Source-only distributions announce the source architecture, so apt only expects deb-src entries
+	if len(arches) == 0 && a.options.Repository.Packages.SourceOnly {
+		arches = []string{debext.SourceArchitecture}
+	}
+
+	metadata, err := a.options.Repository.Release.Resolve(a.options.Name, dist)
+	if err != nil {
+		return err
+	}
+
 	release := debext.Release{
-		Origin:        a.options.Name + " " + dist,
-		Label:         a.options.Name + " " + dist,
-		Suite:         dist,
+		Origin:        metadata.Origin,
+		Label:         metadata.Label,
+		Suite:         metadata.Suite,
 		Codename:      dist,
 		Date:          time.Now(),
 		Architectures: arches,
-		Components:    repo.GetComponents(dist),
+		Components:    a.releaseComponents(repo, dist),
 		Description:   "Generated by aarg",
+		Fields:        a.releaseFields(),
 		Files:         files,
 	}
 
@@ -375,6 +682,13 @@ func (a *Apt) generateRelease(repo *debext.Repository, dist string, files map[st
 		return err
 	}
 
+	// Every signing adds a transparency log entry, so each deployment of a distribution is recorded
+	if a.options.Sigstore != nil {
+		if err := a.options.Sigstore.SignBlob(releaseFilepath, releaseFilepath+debext.SigstoreBundleExtension); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -414,27 +728,35 @@ func (a *Apt) processFeed(feedOpts *feed.FeedOptions) error {
 		}
 	}
 
-	// Process all distributions in parallel
-	// Create subpool for feed distribution processing
-	feedDistPool := a.pool.NewSubpool(10)
-	defer feedDistPool.StopAndWait()
+	// Process all package files of all distributions in parallel
+	// Walking is cheap, so distributions are walked sequentially and only parsing is parallelized
+	// Create subpool for package file processing
+	filePool := a.pool.NewSubpool(10)
+	defer filePool.StopAndWait()
+
+	group := filePool.NewGroup()
 
-	group := feedDistPool.NewGroup()
+	var err error
 	for _, distMap := range distsToProcess {
-		group.SubmitErr(func() error {
-			return a.processFeedDist(feedOpts, distMap.Feed, distMap.Target)
-		})
+		if err = a.processFeedDist(feedOpts, distMap.Feed, distMap.Target, group); err != nil {
+			break
+		}
 	}
 
-	return group.Wait()
+	// Always wait for submitted tasks before returning
+	if waitErr := group.Wait(); err == nil {
+		err = waitErr
+	}
+
+	return err
 }
 
-// processFeedDist processes packages from a specific feed distribution directory
-func (a *Apt) processFeedDist(feedOpts *feed.FeedOptions, feedDist, targetDist string) error {
+// processFeedDist walks a specific feed distribution directory and submits each package file to group
+func (a *Apt) processFeedDist(feedOpts *feed.FeedOptions, feedDist, targetDist string, group pond.TaskGroup) error {
 	distPath := filepath.Join(a.options.Trusted, feedOpts.RelativePath, feedDist)
 
-	// Walk directory and process each file
-	return filepath.Walk(distPath, func(absPath string, info os.FileInfo, err error) error {
+	// Walk directory and submit each file
+	return filepath.WalkDir(distPath, func(absPath string, entry os.DirEntry, err error) error {
 		if err != nil {
 			// If the path does not exist, skip it (no packages for this dist)
 			if os.IsNotExist(err) {
@@ -442,7 +764,7 @@ func (a *Apt) processFeedDist(feedOpts *feed.FeedOptions, feedDist, targetDist s
 			}
 			return err
 		}
-		if info.IsDir() {
+		if entry.IsDir() {
 			return nil
 		}
 
@@ -452,12 +774,24 @@ func (a *Apt) processFeedDist(feedOpts *feed.FeedOptions, feedDist, targetDist s
 			return err
 		}
 
-		return a.processFeedPackageFile(feedOpts, relPath, targetDist)
+		group.SubmitErr(func() error {
+			return a.processFeedPackageFile(feedOpts, relPath, targetDist)
+		})
+
+		return nil
 	})
 }
 
 // processFeedPackageFile parses and filters a single package file
 func (a *Apt) processFeedPackageFile(feedOpts *feed.FeedOptions, relPath string, dist string) error {
+	// Build information is published alongside the packages it belongs to
+	if strings.HasSuffix(relPath, debext.BuildinfoExtension) {
+		if a.options.Repository.Packages.Buildinfo {
+			a.addBuildinfo(dist, relPath)
+		}
+		return nil
+	}
+
 	// Parse the file using relative path
 	pkg, err := a.parseFile(relPath)
 	if err != nil {
@@ -467,7 +801,7 @@ func (a *Apt) processFeedPackageFile(feedOpts *feed.FeedOptions, relPath string,
 		return nil
 	}
 
-	component := common.MainComponent
+	component := a.feedComponent(feedOpts)
 
 	// Filter whether source or debug packages are included
 	if pkg.IsSource && !a.options.Repository.Packages.Source {
@@ -509,6 +843,12 @@ func (a *Apt) processFeedPackageFile(feedOpts *feed.FeedOptions, relPath string,
 		}
 	}
 
+	// Remember the trusted files before redirects rewrite the package location
+	files := make([]string, 0, len(pkg.Files()))
+	for _, file := range pkg.Files() {
+		files = append(files, filepath.Join(filepath.Dir(relPath), file.Filename))
+	}
+
 	// If in redirect mode, update package metadata with redirect paths for feeds with modified filenames
 	if a.options.PoolMode == "redirect" {
 		// For GitHub feeds with source packages, normalize and write to public/dsc/
@@ -525,6 +865,15 @@ func (a *Apt) processFeedPackageFile(feedOpts *feed.FeedOptions, relPath string,
 		}
 	}
 
+	// Remember the feed for conflict resolution
+	a.origins.Store(pkg, feedOpts)
+	a.files.Store(pkg, files)
+
+	// Remember when the package arrived for the freshness of the build
+	if info, err := os.Stat(filepath.Join(a.options.Trusted, relPath)); err == nil {
+		a.fetched.Store(pkg, info.ModTime())
+	}
+
 	// Add to collector with the appropriate component
 	return a.collector.Add(dist, component, pkg)
 }
@@ -541,9 +890,12 @@ func (a *Apt) parseFile(relPath string) (*deb.Package, error) {
 		return nil, nil
 	}
 
-	// Parse binary packages
+	// Parse binary packages unless only sources are published
 	if ext == ".deb" || ext == ".ddeb" {
-		return debext.ParseBinary(absPath, filepath.Dir(relPath))
+		if a.options.Repository.Packages.SourceOnly {
+			return nil, nil
+		}
+		return debext.ParseBinary(absPath, filepath.Dir(relPath), a.options.ControlStore)
 	}
 
 	// Parse source packages
@@ -582,42 +934,243 @@ func (a *Apt) parseFile(relPath string) (*deb.Package, error) {
 }
 
 // buildRepository builds a debext.Repository from all retained packages in the collector
-func (a *Apt) buildRepository() *debext.Repository {
+// Packages are added in feed precedence order, so if several feeds provide the same
+// package version with different content the one of the preferred feed is kept
+func (a *Apt) buildRepository() (*debext.Repository, error) {
 	repo := debext.NewRepository()
 
-	// Add all kept packages to the repository with their distribution and component information
+	var kept []keptPackage
 	_ = a.collector.ForEachKept(func(dist, component, _, _ string, pkg *deb.Package) error {
-		return repo.AddPackage(pkg, dist, component)
+		origin, _ := a.origins.Load(pkg)
+		feedOpts, _ := origin.(*feed.FeedOptions)
+		kept = append(kept, keptPackage{dist: dist, component: component, pkg: pkg, feed: feedOpts})
+		return nil
 	})
 
-	return repo
+	a.sortByPrecedence(kept)
+
+	// Enforce repository limits before anything is added
+	kept, err := a.applyPolicy(kept)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep a single content per package version within a distribution
+	kept, err = a.resolveConflicts(kept)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, item := range kept {
+		if a.keptDists[item.dist] {
+			continue
+		}
+		if err := repo.AddPackage(item.pkg, item.dist, item.component); err != nil {
+			slog.Warn("Failed to add package", "repository", a.options.Name, "package", item.pkg.String(), "error", err)
+			continue
+		}
+		if fetched, ok := a.fetched.Load(item.pkg); ok && fetched.(time.Time).After(a.newest) {
+			a.newest = fetched.(time.Time)
+		}
+	}
+
+	// Kept distributions have the packages they were published with
+	if err := a.addKeptPackages(repo); err != nil {
+		return nil, err
+	}
+
+	return repo, nil
 }
 
-// loadRedirectMaps loads redirects.yaml for each feed in redirect mode
-func (a *Apt) loadRedirectMaps() error {
-	for _, feedOpts := range a.options.Feeds {
-		redirectMapPath := filepath.Join(a.options.Trusted, feedOpts.RelativePath, "redirects.yaml")
+// sortByPrecedence orders kept packages deterministically: preferred feed first, then by location and package key
+func (a *Apt) sortByPrecedence(kept []keptPackage) {
+	slices.SortStableFunc(kept, func(x, y keptPackage) int {
+		if c := a.compareFeedPrecedence(x.feed, y.feed); c != 0 {
+			return c
+		}
+		return strings.Compare(x.dist+"/"+x.component+"/"+string(x.pkg.Key("")), y.dist+"/"+y.component+"/"+string(y.pkg.Key("")))
+	})
+}
 
-		// Check if redirect map exists
-		if _, err := os.Stat(redirectMapPath); os.IsNotExist(err) {
-			continue // No redirect map for this feed, skip
-		} else if err != nil {
-			return err
+// resolveConflicts keeps a single content of each package version per distribution
+// kept must be sorted by feed precedence, so the first package of a version is the preferred one
+// Conflicts are checked across components since apt clients see all components of a distribution and
+// would get different files for the same version depending on the component or mirror they pick
+// Returns the packages to add, or ErrPackageConflict with conflict action fail
+func (a *Apt) resolveConflicts(kept []keptPackage) ([]keptPackage, error) {
+	preferred := make(map[string]keptPackage)
+	resolved := make([]keptPackage, 0, len(kept))
+
+	for _, item := range kept {
+		key := item.dist + "/" + item.pkg.String()
+
+		first, exists := preferred[key]
+		if !exists {
+			preferred[key] = item
+			resolved = append(resolved, item)
+			continue
+		}
+		if first.pkg.Equals(item.pkg) {
+			// Identical content, e.g. the same file in several components
+			resolved = append(resolved, item)
+			continue
 		}
 
-		// Read and parse redirect map
-		data, err := os.ReadFile(redirectMapPath)
-		if err != nil {
-			return err
+		conflict := PackageConflict{
+			Distribution:  item.dist,
+			Component:     item.component,
+			KeptComponent: first.component,
+			Package:       item.pkg.String(),
+			Kept:          feedName(first.feed),
+			Discarded:     feedName(item.feed),
+		}
+		a.conflicts = append(a.conflicts, conflict)
+
+		slog.Warn("Package provided with different content by several feeds",
+			"repository", a.options.Name,
+			"distribution", conflict.Distribution,
+			"component", conflict.Component,
+			"package", conflict.Package,
+			"kept", conflict.Kept,
+			"discarded", conflict.Discarded,
+			"action", a.options.Repository.Policy.GetConflicts())
+	}
+
+	if len(a.conflicts) > 0 && a.options.Repository.Policy.GetConflicts() == common.ConflictActionFail {
+		return nil, fmt.Errorf("%w: %d conflicts in %s", ErrPackageConflict, len(a.conflicts), a.options.Name)
+	}
+
+	return resolved, nil
+}
+
+// compareFeedPrecedence orders feeds by descending priority, then by configuration order
+func (a *Apt) compareFeedPrecedence(x, y *feed.FeedOptions) int {
+	if x == y {
+		return 0
+	}
+	if x != nil && y != nil && x.Priority != y.Priority {
+		return y.Priority - x.Priority
+	}
+	return slices.Index(a.options.Feeds, x) - slices.Index(a.options.Feeds, y)
+}
+
+// feedName returns the name of a feed for reporting
+func feedName(feedOpts *feed.FeedOptions) string {
+	if feedOpts == nil {
+		return ""
+	}
+	return feedOpts.Name
+}
+
+// Origin returns the feed a package in the composed repository was read from, nil if unknown
+func (a *Apt) Origin(pkg *deb.Package) *feed.FeedOptions {
+	origin, _ := a.origins.Load(pkg)
+	feedOpts, _ := origin.(*feed.FeedOptions)
+	return feedOpts
+}
+
+// Fetched returns when the trusted file of a package was fetched, zero if unknown
+func (a *Apt) Fetched(pkg *deb.Package) time.Time {
+	fetched, _ := a.fetched.Load(pkg)
+	t, _ := fetched.(time.Time)
+	return t
+}
+
+// FileUpstream is the upstream location of a trusted package file
+type FileUpstream struct {
+	Location string   // Location relative to the download URL of the feed, empty if unknown
+	Signers  []string // Key IDs of the signatures the file was verified with
+}
+
+// Upstream returns the upstream location of each file of a package in the order of pkg.Files(), nil if unknown
+func (a *Apt) Upstream(pkg *deb.Package) []FileUpstream {
+	feedOpts := a.Origin(pkg)
+	files, _ := a.files.Load(pkg)
+	relPaths, _ := files.([]string)
+	if feedOpts == nil || relPaths == nil {
+		return nil
+	}
+
+	upstream := make([]FileUpstream, 0, len(relPaths))
+	for _, relPath := range relPaths {
+		fileRelPath := strings.TrimPrefix(relPath, feedOpts.RelativePath+string(filepath.Separator))
+		upstream = append(upstream, FileUpstream{
+			Location: a.redirectMaps[feedOpts.RelativePath][fileRelPath],
+			Signers:  a.signerMaps[feedOpts.RelativePath][fileRelPath],
+		})
+	}
+	return upstream
+}
+
+// Newest returns the modification time of the newest package file of the composed repository in trusted storage, zero if unknown
+func (a *Apt) Newest() time.Time {
+	return a.newest
+}
+
+// Conflicts returns the package conflicts resolved by feed precedence during Compose
+func (a *Apt) Conflicts() []PackageConflict {
+	return a.conflicts
+}
+
+// Dropped returns the packages collected from trusted storage but dropped by retention, sorted
+func (a *Apt) Dropped() []string {
+	kept := make(map[*deb.Package]bool)
+	_ = a.collector.ForEachKept(func(_, _, _, _ string, pkg *deb.Package) error {
+		kept[pkg] = true
+		return nil
+	})
+
+	dropped := []string{}
+	a.files.Range(func(key, _ any) bool {
+		if pkg := key.(*deb.Package); !kept[pkg] {
+			dropped = append(dropped, pkg.String())
 		}
+		return true
+	})
+	slices.Sort(dropped)
+	return slices.Compact(dropped)
+}
+
+// Versions returns the source versions collected from trusted storage per source package, oldest first
+func (a *Apt) Versions() map[string][]string {
+	versions := make(map[string][]string)
+	a.files.Range(func(key, _ any) bool {
+		pkg := key.(*deb.Package)
+		source, version := pkg.GetField("$Source"), pkg.GetField("$SourceVersion")
+		if pkg.IsSource {
+			source, version = pkg.Name, pkg.Version
+		}
+		if !slices.Contains(versions[source], version) {
+			versions[source] = append(versions[source], version)
+		}
+		return true
+	})
+	for _, list := range versions {
+		slices.SortFunc(list, deb.CompareVersions)
+	}
+	return versions
+}
+
+// loadFeedMaps loads the redirect and signer maps of each feed
+func (a *Apt) loadFeedMaps() error {
+	for _, feedOpts := range a.options.Feeds {
+		feedDir := filepath.Join(a.options.Trusted, feedOpts.RelativePath)
+
+		key := common.MetadataKey(feedOpts.RelativePath)
 
 		var redirectMap map[string]string
-		if err := yaml.Unmarshal(data, &redirectMap); err != nil {
+		if found, err := common.LoadMetadataMap(a.options.Metadata, common.RedirectsBucket, key, filepath.Join(feedDir, common.RedirectsFile), &redirectMap); err != nil {
 			return err
+		} else if found {
+			a.redirectMaps[feedOpts.RelativePath] = redirectMap
 		}
 
-		// Store in redirectMaps
-		a.redirectMaps[feedOpts.RelativePath] = redirectMap
+		var signerMap map[string][]string
+		if found, err := common.LoadMetadataMap(a.options.Metadata, common.SignersBucket, key, filepath.Join(feedDir, common.SignersFile), &signerMap); err != nil {
+			return err
+		} else if found {
+			a.signerMaps[feedOpts.RelativePath] = signerMap
+		}
 	}
 
 	return nil
@@ -772,3 +1325,183 @@ func (a *Apt) applyRedirectsToPackage(pkg **deb.Package, feedRelPath string, rel
 		return debext.ModifyPackageStanza(pkg, "Filename", newPath)
 	}
 }
+
+// composeAPT composes the APT repository and provides it to dependent composers
+func composeAPT(ctx context.Context, deps Dependencies, results *Results) error {
+	repo := deps.Repository
+
+	expandedFeeds, err := expandComposeFeeds(repo.Feeds)
+	if err != nil {
+		return err
+	}
+
+	options := &AptComposeOptions{
+		ComposeOptions: ComposeOptions{
+			Target: filepath.Join(deps.StagingPath, repo.Name),
+			Name:   repo.Name,
+			Feeds:  expandedFeeds,
+		},
+		Repository: &repo.RepositoryOptions,
+		Trusted:    deps.Config.Directories.GetTrustedPath(),
+		PoolMode:   deps.Config.Generate.PoolMode,
+		Previous:   deps.PreviousPath,
+
+		PDiffHistory:   deps.Config.Generate.PDiffHistory,
+		Contents:       deps.Config.Generate.Contents,
+		Translations:   deps.Config.Generate.Translations,
+		ReleaseFields:  generatorFields(deps),
+		DebuginfoCache: filepath.Join(deps.Config.Directories.GetCachePath(), "debuginfo"),
+		VerifyPool:     deps.VerifyPool,
+		Distributions:  deps.Distributions,
+		VerifySample:   deps.Config.Generate.VerifyPoolSample,
+		Sigstore:       deps.Sigstore,
+		Metadata:       deps.Metadata,
+		ControlStore:   deps.ControlStore,
+		ContentsStore:  deps.ContentsStore,
+	}
+
+	if deps.Incremental {
+		options.Incremental = true
+		options.Settings = buildSettings(deps)
+		// Reused Release files keep their date, refresh them well before consumers consider them stale
+		if maxAge := deps.Config.Generate.HealthMaxAgeHours; maxAge > 0 {
+			options.RefreshAfter = time.Duration(maxAge) * time.Hour / 2
+		}
+	}
+
+	composer := NewApt(options, trustedVerifier(), deps.Signer, deps.DeCompressor, deps.Pool)
+
+	repository, err := composer.Compose(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to compose APT repository for %s: %w", repo.Name, err)
+	}
+
+	// Latest versions going backwards usually indicate a feed misconfiguration or re-tagged upstream release
+	if deps.PreviousPath != "" {
+		previous, err := LoadRepositoryState(deps.PreviousPath, repo.Name)
+		switch {
+		case err == nil:
+			if err := composer.checkRegressions(previous, repository); err != nil {
+				return err
+			}
+		case !errors.Is(err, os.ErrNotExist):
+			slog.Warn("Failed to load previous repository state, skipping version regression check", "repository", repo.Name, "error", err)
+		}
+	}
+
+	results.Apt = composer
+	results.Repository = repository
+
+	// Keep the published state for commands operating on the build
+	if err := SaveRepositoryState(deps.StagingPath, repo.Name, repository); err != nil {
+		return err
+	}
+
+	// Collect statistics for logging
+	dists := repository.GetDistributions()
+	var totalPkgs int
+	archSet := make(map[string]struct{})
+	compSet := make(map[string]struct{})
+	for _, dist := range dists {
+		for _, comp := range repository.GetComponents(dist) {
+			compSet[comp] = struct{}{}
+			for _, arch := range repository.GetArchitectures(dist, comp, false) {
+				archSet[arch] = struct{}{}
+			}
+		}
+		for _, comp := range PackageComponents(repository) {
+			totalPkgs += len(repository.GetPackageNames(comp))
+		}
+	}
+
+	slog.Info("APT repository generated",
+		"repository", repo.Name,
+		"distributions", len(dists),
+		"components", len(compSet),
+		"architectures", len(archSet),
+		"packages", totalPkgs,
+		"conflicts", len(composer.Conflicts()),
+		"violations", len(composer.Violations()),
+		"regressions", len(composer.Regressions()),
+		"reused", composer.Reused(),
+		"verified", composer.Verified())
+
+	return nil
+}
+
+// expandComposeFeeds expands feeds into the trusted storage layouts for APT composition
+// Other composers use the original feed list (repo.Feeds)
+func expandComposeFeeds(feeds []*feed.FeedOptions) ([]*feed.FeedOptions, error) {
+	var expanded []*feed.FeedOptions
+	for _, feedOpts := range feeds {
+		composeFeeds, err := feed.ExpandCompose(feedOpts)
+		if err != nil {
+			return nil, err
+		}
+		expanded = append(expanded, composeFeeds...)
+	}
+	return expanded, nil
+}
+
+// trustedVerifier returns the verifier for the compose phase - trust files in trusted storage
+// Files were already verified during fetch, so we accept unsigned and ignore signatures
+func trustedVerifier() *debext.Verifier {
+	return &debext.Verifier{
+		Verifier:         &pgp.GoVerifier{}, // Empty verifier, won't be used
+		AcceptUnsigned:   true,
+		IgnoreSignatures: true,
+	}
+}
+
+// buildSettings identifies the settings affecting the dists trees of all repositories
+// A changed signing key, pool mode, compression level or pdiff history invalidates every reused distribution
+func buildSettings(deps Dependencies) string {
+	key := sha256.Sum256(deps.PublicKeyBinary)
+	return fmt.Sprintf("key=%x pool=%s compression=%v pdiffs=%d contents=%t translations=%t sigstore=%t", key[:8], deps.Config.Generate.PoolMode,
+		deps.Config.Generate.Compression.Levels(), deps.Config.Generate.PDiffHistory, deps.Config.Generate.Contents, deps.Config.Generate.Translations,
+		deps.Sigstore != nil)
+}
+
+// generatorFields returns the Release fields identifying the aarg build and configuration producing the tree
+func generatorFields(deps Dependencies) map[string]string {
+	fields := map[string]string{"X-Aarg-Version": common.CurrentBuild().String()}
+	if deps.ConfigHash != "" {
+		fields["X-Aarg-Config"] = deps.ConfigHash
+	}
+	return fields
+}
+
+// indexAPT copies both ASCII and binary GPG signing keys to the staging directory
+func indexAPT(_ context.Context, deps Dependencies) error {
+	if len(deps.PublicKeyASCII) == 0 {
+		return nil
+	}
+
+	keysDir := filepath.Join(deps.StagingPath, "keys")
+	if err := os.MkdirAll(keysDir, 0755); err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(filepath.Join(keysDir, "signing-key.asc"), deps.PublicKeyASCII, 0644); err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(filepath.Join(keysDir, "signing-key.gpg"), deps.PublicKeyBinary, 0644); err != nil {
+		return err
+	}
+
+	// Key-based sigstore bundles are verified with the cosign public key, keyless ones by certificate identity
+	if deps.Sigstore != nil {
+		sigstoreKey, err := deps.Sigstore.PublicKey()
+		if err != nil {
+			return err
+		}
+		if sigstoreKey != nil {
+			if err := os.WriteFile(filepath.Join(keysDir, "sigstore.pub"), sigstoreKey, 0644); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
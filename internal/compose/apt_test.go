@@ -0,0 +1,207 @@
+package compose
+
+import (
+	"testing"
+
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/dionysius/aarg/internal/common"
+	"github.com/dionysius/aarg/internal/feed"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestApt returns an Apt composer for the feeds in configuration order
+func newTestApt(conflicts common.ConflictAction, feeds ...*feed.FeedOptions) *Apt {
+	return &Apt{options: &AptComposeOptions{
+		ComposeOptions: ComposeOptions{Name: "test", Feeds: feeds},
+		Repository:     &common.RepositoryOptions{Policy: common.PolicyOptions{Conflicts: conflicts}},
+	}}
+}
+
+// newTestPackage returns a binary package whose content is identified by sha256
+func newTestPackage(name, version, sha256 string) *deb.Package {
+	return deb.NewPackageFromControlFile(deb.Stanza{
+		"Package":      name,
+		"Version":      version,
+		"Architecture": "amd64",
+		"Filename":     "pool/main/" + name + "_" + version + "_amd64.deb",
+		"Size":         "100",
+		"SHA256":       sha256,
+	})
+}
+
+func TestApt_resolveConflicts(t *testing.T) {
+	first := &feed.FeedOptions{Name: "first"}
+	second := &feed.FeedOptions{Name: "second"}
+	preferred := &feed.FeedOptions{Name: "preferred", Priority: 10}
+
+	pkgA := func() *deb.Package { return newTestPackage("hello", "1.0-1", "aaaa") }
+	pkgB := func() *deb.Package { return newTestPackage("hello", "1.0-1", "bbbb") }
+
+	// kept describes a resolved package by its location and feed
+	type kept struct {
+		component string
+		feed      string
+	}
+
+	tests := []struct {
+		name          string
+		feeds         []*feed.FeedOptions
+		conflicts     common.ConflictAction
+		packages      []keptPackage
+		want          []kept
+		wantConflicts []PackageConflict
+		wantErr       error
+	}{
+		{
+			name:  "different content in two components",
+			feeds: []*feed.FeedOptions{first, second},
+			packages: []keptPackage{
+				{dist: "stable", component: "main", pkg: pkgA(), feed: first},
+				{dist: "stable", component: "contrib", pkg: pkgB(), feed: second},
+			},
+			want: []kept{{"main", "first"}},
+			wantConflicts: []PackageConflict{{
+				Distribution:  "stable",
+				Component:     "contrib",
+				KeptComponent: "main",
+				Package:       "hello_1.0-1_amd64",
+				Kept:          "first",
+				Discarded:     "second",
+			}},
+		},
+		{
+			name:  "identical content kept in both components",
+			feeds: []*feed.FeedOptions{first, second},
+			packages: []keptPackage{
+				{dist: "stable", component: "main", pkg: pkgA(), feed: first},
+				{dist: "stable", component: "contrib", pkg: pkgA(), feed: second},
+			},
+			want: []kept{{"main", "first"}, {"contrib", "second"}},
+		},
+		{
+			name:  "same version in other distributions",
+			feeds: []*feed.FeedOptions{first, second},
+			packages: []keptPackage{
+				{dist: "stable", component: "main", pkg: pkgA(), feed: first},
+				{dist: "testing", component: "main", pkg: pkgB(), feed: second},
+			},
+			want: []kept{{"main", "first"}, {"main", "second"}},
+		},
+		{
+			name:  "higher priority preferred over feed order",
+			feeds: []*feed.FeedOptions{first, preferred},
+			packages: []keptPackage{
+				{dist: "stable", component: "main", pkg: pkgA(), feed: first},
+				{dist: "stable", component: "main", pkg: pkgB(), feed: preferred},
+			},
+			want: []kept{{"main", "preferred"}},
+			wantConflicts: []PackageConflict{{
+				Distribution:  "stable",
+				Component:     "main",
+				KeptComponent: "main",
+				Package:       "hello_1.0-1_amd64",
+				Kept:          "preferred",
+				Discarded:     "first",
+			}},
+		},
+		{
+			name:  "feed order decides equal priorities",
+			feeds: []*feed.FeedOptions{second, first},
+			packages: []keptPackage{
+				{dist: "stable", component: "main", pkg: pkgA(), feed: first},
+				{dist: "stable", component: "main", pkg: pkgB(), feed: second},
+			},
+			want: []kept{{"main", "second"}},
+			wantConflicts: []PackageConflict{{
+				Distribution:  "stable",
+				Component:     "main",
+				KeptComponent: "main",
+				Package:       "hello_1.0-1_amd64",
+				Kept:          "second",
+				Discarded:     "first",
+			}},
+		},
+		{
+			name:      "conflicts fail",
+			feeds:     []*feed.FeedOptions{first, second},
+			conflicts: common.ConflictActionFail,
+			packages: []keptPackage{
+				{dist: "stable", component: "main", pkg: pkgA(), feed: first},
+				{dist: "stable", component: "contrib", pkg: pkgB(), feed: second},
+			},
+			wantErr: ErrPackageConflict,
+		},
+		{
+			name:      "conflicts fail without conflicts",
+			feeds:     []*feed.FeedOptions{first, second},
+			conflicts: common.ConflictActionFail,
+			packages: []keptPackage{
+				{dist: "stable", component: "main", pkg: pkgA(), feed: first},
+				{dist: "stable", component: "contrib", pkg: pkgA(), feed: second},
+			},
+			want: []kept{{"main", "first"}, {"contrib", "second"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestApt(tt.conflicts, tt.feeds...)
+			a.sortByPrecedence(tt.packages)
+
+			resolved, err := a.resolveConflicts(tt.packages)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.NotEmpty(t, a.Conflicts())
+				return
+			}
+			require.NoError(t, err)
+
+			got := make([]kept, 0, len(resolved))
+			for _, item := range resolved {
+				got = append(got, kept{item.component, item.feed.Name})
+			}
+			assert.ElementsMatch(t, tt.want, got)
+			assert.Equal(t, tt.wantConflicts, a.Conflicts())
+		})
+	}
+}
+
+func TestApt_compareFeedPrecedence(t *testing.T) {
+	first := &feed.FeedOptions{Name: "first"}
+	second := &feed.FeedOptions{Name: "second"}
+	low := &feed.FeedOptions{Name: "low", Priority: -5}
+	high := &feed.FeedOptions{Name: "high", Priority: 10}
+	alsoHigh := &feed.FeedOptions{Name: "also-high", Priority: 10}
+
+	a := newTestApt("", first, low, second, high, alsoHigh)
+
+	tests := []struct {
+		name string
+		x, y *feed.FeedOptions
+		// want is the sign of the comparison, negative if x takes precedence
+		want int
+	}{
+		{name: "same feed", x: first, y: first, want: 0},
+		{name: "equal priorities by declaration order", x: first, y: second, want: -1},
+		{name: "equal priorities by declaration order reversed", x: second, y: first, want: 1},
+		{name: "equal non-zero priorities by declaration order", x: high, y: alsoHigh, want: -1},
+		{name: "higher priority declared later", x: high, y: first, want: -1},
+		{name: "lower priority declared earlier", x: first, y: high, want: 1},
+		{name: "negative priority after default", x: low, y: second, want: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := a.compareFeedPrecedence(tt.x, tt.y)
+			switch {
+			case tt.want < 0:
+				assert.Negative(t, got)
+			case tt.want > 0:
+				assert.Positive(t, got)
+			default:
+				assert.Zero(t, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package compose
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/dionysius/aarg/debext"
+	"github.com/dionysius/aarg/internal/common"
+)
+
+// BuildinfoDir is the directory in the repository .buildinfo files are published under
+const BuildinfoDir = "buildinfo"
+
+// addBuildinfo remembers a .buildinfo file in trusted storage for the target distribution
+func (a *Apt) addBuildinfo(dist, relPath string) {
+	a.buildinfoMu.Lock()
+	defer a.buildinfoMu.Unlock()
+
+	a.buildinfos[dist] = append(a.buildinfos[dist], relPath)
+}
+
+// publishBuildinfo hardlinks the .buildinfo files of all packages in the repository
+// to buildinfo/<component>/<prefix>/<source>/ following the pool layout
+// Files are published in every pool mode, since they are not served by the feeds' redirects
+// Build information of packages dropped by retention is not published
+func (a *Apt) publishBuildinfo(repo *debext.Repository) (int, error) {
+	published := 0
+
+	for _, dist := range repo.GetDistributions() {
+		files := a.buildinfos[dist]
+		if len(files) == 0 {
+			continue
+		}
+
+		// Component of each source version in this distribution
+		components := make(map[string]string)
+		for _, comp := range repo.GetComponents(dist) {
+			_ = repo.GetPackageList(dist, comp).ForEach(func(pkg *deb.Package) error {
+				components[buildinfoKey(pkg)] = comp
+				return nil
+			})
+		}
+
+		for _, relPath := range files {
+			source, version, ok := debext.ParseBuildinfoFilename(relPath)
+			if !ok {
+				continue
+			}
+			comp, ok := components[source+"_"+version]
+			if !ok {
+				continue
+			}
+
+			// The same source version in several distributions shares the published file
+			targetDir := filepath.Join(a.options.Target, BuildinfoDir, strings.TrimPrefix(debext.GetPoolPath(comp, source), "pool/"))
+			if err := os.MkdirAll(targetDir, 0755); err != nil {
+				return published, err
+			}
+			targetPath := filepath.Join(targetDir, filepath.Base(relPath))
+			if err := common.EnsureHardlink(filepath.Join(a.options.Trusted, relPath), targetPath); err != nil {
+				return published, err
+			}
+			published++
+
+			// Remember the published path relative to the repository for the metadata composer
+			publishedPath, err := filepath.Rel(a.options.Target, targetPath)
+			if err != nil {
+				return published, err
+			}
+			key := source + "_" + version
+			if !slices.Contains(a.publishedBuildinfo[key], publishedPath) {
+				a.publishedBuildinfo[key] = append(a.publishedBuildinfo[key], publishedPath)
+			}
+		}
+	}
+
+	return published, nil
+}
+
+// Buildinfo returns the published .buildinfo paths of the source version a package was built from
+// Paths are relative to the repository directory
+func (a *Apt) Buildinfo(pkg *deb.Package) []string {
+	return a.publishedBuildinfo[buildinfoKey(pkg)]
+}
+
+// buildinfoKey returns the source name and version a package was built from as in .buildinfo filenames
+// Versions in filenames have no epoch
+func buildinfoKey(pkg *deb.Package) string {
+	version := pkg.Version
+	if !pkg.IsSource {
+		if sourceVersion := pkg.GetField("$SourceVersion"); sourceVersion != "" {
+			version = sourceVersion
+		}
+	}
+
+	components := debext.ParseVersion(version)
+	components.Epoch = ""
+	return debext.GetSourceNameFromPackage(pkg) + "_" + components.String()
+}
@@ -0,0 +1,200 @@
+package compose
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/dionysius/aarg/internal/common"
+)
+
+// BuildsDir is the directory of a build holding read-only snapshots of earlier builds
+const BuildsDir = "builds"
+
+// buildTimestampFormat is the timestamp prefix of staging build names
+const buildTimestampFormat = "20060102-150405"
+
+// BuildsData contains data for the previous builds page
+type BuildsData struct {
+	Builds     []BuildSnapshot
+	AssetsPath string
+	PageTitle  string
+}
+
+// BuildSnapshot is an earlier build published as snapshot
+type BuildSnapshot struct {
+	Name         string
+	Time         time.Time // Generation time from the build name, zero if the name has no timestamp
+	Repositories []BuildRepository
+}
+
+// BuildRepository is a repository of a build snapshot
+type BuildRepository struct {
+	Name     string
+	Metadata bool // Whether the metadata composer ran, linking metadata/packages.json
+}
+
+// previousBuilds returns up to limit complete builds generated before the build at target, newest first
+// Only builds in the same staging directory are considered, one-off builds elsewhere have no lineage
+func previousBuilds(stagingBase, target string, limit int) []string {
+	if limit <= 0 || filepath.Dir(target) != filepath.Clean(stagingBase) {
+		return nil
+	}
+
+	entries, err := os.ReadDir(stagingBase)
+	if err != nil {
+		return nil
+	}
+
+	current := filepath.Base(target)
+	var builds []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() || name >= current {
+			continue
+		}
+		// Failed builds are removed, but a build without health file may still be in progress
+		if _, err := os.Stat(filepath.Join(stagingBase, name, HealthFile)); err != nil {
+			continue
+		}
+		builds = append(builds, name)
+	}
+
+	slices.Reverse(builds)
+	if len(builds) > limit {
+		builds = builds[:limit]
+	}
+	return builds
+}
+
+// composeBuilds places snapshots of the web pages and metadata of earlier builds into builds/ and lists them
+// Package indexes and pool files are left out, snapshots show what was published without hosting it again
+func (w *Web) composeBuilds() error {
+	buildsPath := filepath.Join(w.options.Target, BuildsDir)
+	if err := os.RemoveAll(buildsPath); err != nil {
+		return err
+	}
+
+	names := previousBuilds(w.options.StagingBase, w.options.Target, w.options.PreviousBuilds)
+	if len(names) == 0 {
+		return nil
+	}
+
+	var snapshots []BuildSnapshot
+	for _, name := range names {
+		snapshot, err := snapshotBuild(filepath.Join(w.options.StagingBase, name), filepath.Join(buildsPath, name))
+		if err != nil {
+			return fmt.Errorf("failed to snapshot build %s: %w", name, err)
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+
+	data := BuildsData{
+		Builds:     snapshots,
+		AssetsPath: "../",
+		PageTitle:  "APT Repositories",
+	}
+
+	var buf bytes.Buffer
+	tmpl := template.Must(w.tmpl.Clone())
+	if _, err := tmpl.ParseFS(templatesFS, "templates/nav.html", "templates/builds.html"); err != nil {
+		return err
+	}
+	if err := tmpl.ExecuteTemplate(&buf, "base.html", data); err != nil {
+		return err
+	}
+
+	slog.Info("Previous builds generated", "builds", len(snapshots))
+	return os.WriteFile(filepath.Join(buildsPath, "index.html"), buf.Bytes(), 0644)
+}
+
+// snapshotBuild copies the web files of a build into dst
+// Files are copied since regenerating the web page of a build rewrites its files in place
+// Skipped are the dists, pool and snapshots trees of repositories, hidden state files, host configuration
+// files and the snapshots of the build itself
+func snapshotBuild(src, dst string) (BuildSnapshot, error) {
+	snapshot := BuildSnapshot{Name: filepath.Base(src)}
+	if len(snapshot.Name) >= len(buildTimestampFormat) {
+		if t, err := time.ParseInLocation(buildTimestampFormat, snapshot.Name[:len(buildTimestampFormat)], time.Local); err == nil {
+			snapshot.Time = common.InDisplayLocation(t)
+		}
+	}
+
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		return snapshot, err
+	}
+
+	err := filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil || rel == "." {
+			return err
+		}
+
+		parts := strings.Split(filepath.ToSlash(rel), "/")
+		name := parts[len(parts)-1]
+		skip := strings.HasPrefix(name, ".") ||
+			(len(parts) == 1 && (name == BuildsDir || name == HeadersFile || name == "_redirects")) ||
+			(len(parts) == 2 && d.IsDir() && (name == "dists" || name == "pool" || name == SnapshotsDir))
+		if skip {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if d.IsDir() {
+			if len(parts) == 1 {
+				if _, err := os.Stat(filepath.Join(path, RepositoryStateFile)); err == nil {
+					_, err := os.Stat(filepath.Join(path, MetadataDir, "packages.json"))
+					snapshot.Repositories = append(snapshot.Repositories, BuildRepository{Name: name, Metadata: err == nil})
+				}
+			}
+			return os.MkdirAll(filepath.Join(dst, rel), 0755)
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		return copySnapshotFile(path, filepath.Join(dst, rel))
+	})
+
+	return snapshot, err
+}
+
+// copySnapshotFile copies a file keeping its modification time
+func copySnapshotFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = in.Close() }()
+
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Chtimes(dst, info.ModTime(), info.ModTime())
+}
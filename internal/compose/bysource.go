@@ -0,0 +1,287 @@
+package compose
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/dionysius/aarg/debext"
+	"github.com/dionysius/aarg/internal/common"
+)
+
+// BySourceDir is the directory in a repository with the pages grouping binaries under their source package
+const BySourceDir = "by-source"
+
+// SourceGroup is a source package with all its versions in the repository
+type SourceGroup struct {
+	Name     string          `json:"name"`
+	Versions []SourceVersion `json:"versions"` // Newest first
+}
+
+// Latest returns the newest version of the source package
+func (g SourceGroup) Latest() SourceVersion {
+	return g.Versions[0]
+}
+
+// SourceVersion is a version of a source package with its files and the binaries built from it
+type SourceVersion struct {
+	Version       string         `json:"version"`
+	Distributions []string       `json:"distributions"`
+	Pinned        bool           `json:"pinned,omitempty"` // Version is pinned and never removed by retention
+	Dsc           *SourceFile    `json:"dsc,omitempty"`    // Nil if the source package is not published
+	Files         []SourceFile   `json:"files,omitempty"`  // Orig and debian tarballs referenced by the .dsc
+	Binaries      []SourceBinary `json:"binaries,omitempty"`
+}
+
+// SourceFile is a file of the repository, Path is relative to the repository
+type SourceFile struct {
+	Name       string           `json:"name"`
+	Path       string           `json:"path"`
+	Size       int64            `json:"size"`
+	SHA256     string           `json:"sha256"`
+	Provenance *ProvenanceEntry `json:"provenance,omitempty"` // Where the file came from, nil if unknown
+}
+
+// SourceBinary is a binary package built from a source version
+type SourceBinary struct {
+	Name          string     `json:"name"`
+	Version       string     `json:"version"`
+	Architecture  string     `json:"architecture"`
+	Distributions []string   `json:"distributions"`
+	File          SourceFile `json:"file"`
+}
+
+// SourcePageData contains data for the by-source pages of a repository
+type SourcePageData struct {
+	RepositoryName string
+	Sources        []SourceGroup // All sources on the index page, the single source on its page
+	RepositoryPath string        // Relative path to the repository directory
+	RepositoryURL  string        // Absolute URL of the repository directory for dget, empty if no base URL is configured
+	SourceOnly     bool          // Repository publishes no binary packages
+	AssetsPath     string        // Relative path to assets directory
+	PageTitle      string        // Title for the navigation bar
+}
+
+// groupBySource groups all packages of the repository under their source package and version
+// Versions listed in pins are marked as pinned, files get their entry of provenance attached
+func groupBySource(repo *debext.Repository, pins []common.PinnedVersion, provenance map[string]ProvenanceEntry) []SourceGroup {
+	type versionKey struct{ name, version string }
+
+	versions := make(map[versionKey]*SourceVersion)
+	binaries := make(map[versionKey]map[string]*SourceBinary) // source version -> name version arch -> binary
+
+	version := func(name, ver, dist string) *SourceVersion {
+		key := versionKey{name, ver}
+		v, ok := versions[key]
+		if !ok {
+			v = &SourceVersion{Version: ver, Pinned: common.IsPinned(pins, name, ver)}
+			versions[key] = v
+			binaries[key] = make(map[string]*SourceBinary)
+		}
+		if !slices.Contains(v.Distributions, dist) {
+			v.Distributions = append(v.Distributions, dist)
+		}
+		return v
+	}
+
+	for _, dist := range repo.GetDistributions() {
+		for _, comp := range repo.GetComponents(dist) {
+			_ = repo.GetPackageList(dist, comp).ForEach(func(pkg *deb.Package) error {
+				if pkg.IsSource {
+					v := version(pkg.Name, pkg.Version, dist)
+					if v.Dsc == nil {
+						v.Dsc, v.Files = sourceFiles(pkg, provenance)
+					}
+					return nil
+				}
+
+				key := versionKey{debext.GetSourceNameFromPackage(pkg), pkg.GetField("$SourceVersion")}
+				version(key.name, key.version, dist)
+
+				// A binary may be published in several distributions
+				id := pkg.Name + " " + pkg.Version + " " + pkg.Architecture
+				binary, ok := binaries[key][id]
+				if !ok {
+					binary = &SourceBinary{
+						Name:         pkg.Name,
+						Version:      pkg.Version,
+						Architecture: pkg.Architecture,
+						File:         binaryFile(pkg, provenance),
+					}
+					binaries[key][id] = binary
+				}
+				if !slices.Contains(binary.Distributions, dist) {
+					binary.Distributions = append(binary.Distributions, dist)
+				}
+				return nil
+			})
+		}
+	}
+
+	groups := make(map[string]*SourceGroup)
+	for key, v := range versions {
+		for _, binary := range binaries[key] {
+			v.Binaries = append(v.Binaries, *binary)
+		}
+		slices.SortFunc(v.Binaries, func(a, b SourceBinary) int {
+			if c := strings.Compare(a.Name, b.Name); c != 0 {
+				return c
+			}
+			return strings.Compare(a.Architecture, b.Architecture)
+		})
+		slices.Sort(v.Distributions)
+
+		group, ok := groups[key.name]
+		if !ok {
+			group = &SourceGroup{Name: key.name}
+			groups[key.name] = group
+		}
+		group.Versions = append(group.Versions, *v)
+	}
+
+	result := make([]SourceGroup, 0, len(groups))
+	for _, group := range groups {
+		slices.SortFunc(group.Versions, func(a, b SourceVersion) int {
+			return deb.CompareVersions(b.Version, a.Version)
+		})
+		result = append(result, *group)
+	}
+	slices.SortFunc(result, func(a, b SourceGroup) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return result
+}
+
+// sourceFiles returns the .dsc and the other files of a source package
+func sourceFiles(pkg *deb.Package, provenance map[string]ProvenanceEntry) (*SourceFile, []SourceFile) {
+	directory := strings.TrimSuffix(pkg.Stanza()["Directory"], "/")
+
+	var dsc *SourceFile
+	var files []SourceFile
+	for _, file := range pkg.Files() {
+		sf := SourceFile{
+			Name:   file.Filename,
+			Path:   directory + "/" + file.Filename,
+			Size:   file.Checksums.Size,
+			SHA256: file.Checksums.SHA256,
+		}
+		sf.Provenance = lookupProvenance(provenance, sf.Path)
+		if strings.HasSuffix(file.Filename, ".dsc") {
+			dsc = &sf
+			continue
+		}
+		files = append(files, sf)
+	}
+	slices.SortFunc(files, func(a, b SourceFile) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return dsc, files
+}
+
+// binaryFile returns the package file of a binary package
+func binaryFile(pkg *deb.Package, provenance map[string]ProvenanceEntry) SourceFile {
+	path := pkg.Stanza()["Filename"]
+	sf := SourceFile{Name: filepath.Base(path), Path: path, Provenance: lookupProvenance(provenance, path)}
+	if files := pkg.Files(); len(files) > 0 {
+		sf.Size = files[0].Checksums.Size
+		sf.SHA256 = files[0].Checksums.SHA256
+	}
+	return sf
+}
+
+// lookupProvenance returns the provenance of a file, nil if unknown
+func lookupProvenance(provenance map[string]ProvenanceEntry, path string) *ProvenanceEntry {
+	entry, ok := provenance[path]
+	if !ok {
+		return nil
+	}
+	return &entry
+}
+
+// isSafeSourceName reports whether a source package name can be used as directory name
+func isSafeSourceName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, `/\`)
+}
+
+// generateBySource writes the by-source pages and JSON files of a repository
+func (w *Web) generateBySource(repo *debext.Repository) error {
+	groups := groupBySource(repo, w.options.Repository.Pin, w.options.Provenance)
+	groups = slices.DeleteFunc(groups, func(g SourceGroup) bool { return !isSafeSourceName(g.Name) })
+
+	sourceDir := filepath.Join(w.options.Target, w.options.Name, BySourceDir)
+	if err := os.RemoveAll(sourceDir); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(sourceDir, 0755); err != nil {
+		return err
+	}
+
+	repositoryURL := ""
+	if w.options.BaseURL != "" {
+		repositoryURL = strings.TrimSuffix(w.options.BaseURL, "/") + "/" + w.options.Name + "/"
+	}
+	sourceOnly := w.options.Repository.Packages.SourceOnly
+
+	index := SourcePageData{
+		RepositoryName: w.options.Name,
+		Sources:        groups,
+		RepositoryPath: "../",
+		RepositoryURL:  repositoryURL,
+		SourceOnly:     sourceOnly,
+		AssetsPath:     "../../",
+		PageTitle:      "APT Repositories",
+	}
+	if err := w.renderSourcePage(filepath.Join(sourceDir, "index.html"), "templates/source-index.html", index); err != nil {
+		return err
+	}
+	if err := writeJSON(filepath.Join(sourceDir, "index.json"), groups); err != nil {
+		return err
+	}
+
+	for _, group := range groups {
+		dir := filepath.Join(sourceDir, group.Name)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+
+		page := SourcePageData{
+			RepositoryName: w.options.Name,
+			Sources:        []SourceGroup{group},
+			RepositoryPath: "../../",
+			RepositoryURL:  repositoryURL,
+			SourceOnly:     sourceOnly,
+			AssetsPath:     "../../../",
+			PageTitle:      "APT Repositories",
+		}
+		if err := w.renderSourcePage(filepath.Join(dir, "index.html"), "templates/source-package.html", page); err != nil {
+			return err
+		}
+		if err := writeJSON(filepath.Join(dir, "index.json"), group); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// renderSourcePage renders a by-source page template to path
+func (w *Web) renderSourcePage(path, page string, data SourcePageData) error {
+	// Clone template to avoid concurrent execution issues
+	tmpl := template.Must(w.tmpl.Clone())
+	if _, err := tmpl.ParseFS(templatesFS, "templates/nav.html", page); err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&buf, "base.html", data); err != nil {
+		return fmt.Errorf("failed to render %s: %w", filepath.Base(page), err)
+	}
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
@@ -0,0 +1,42 @@
+package compose
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// CarryOverRepository hardlinks a repository of the previous build into a build, including its pages
+// Used when only a subset of repositories is generated, the others stay as published
+// Returns false if the previous build doesn't contain the repository
+func CarryOverRepository(previousPath, buildPath, name string) (bool, error) {
+	src := filepath.Join(previousPath, name)
+	if info, err := os.Stat(src); err != nil || !info.IsDir() {
+		return false, nil
+	}
+
+	dst := filepath.Join(buildPath, name)
+	if err := linkTree(src, dst, nil); err != nil {
+		_ = os.RemoveAll(dst)
+		return false, err
+	}
+	return true, nil
+}
+
+// CarryOverAssets hardlinks the shared web assets of the previous build into a build, e.g. icons of carried over repositories
+// The stylesheet is left out, it's built in place for every build from the pages of all repositories
+func CarryOverAssets(previousPath, buildPath string) error {
+	src := filepath.Join(previousPath, "assets")
+	if _, err := os.Stat(src); err != nil {
+		return nil
+	}
+
+	return linkTree(src, filepath.Join(buildPath, "assets"), func(rel string, d fs.DirEntry) bool {
+		if d.IsDir() {
+			return rel == "css"
+		}
+		// Already provided by the regenerated repositories
+		_, err := os.Lstat(filepath.Join(buildPath, "assets", rel))
+		return err == nil
+	})
+}
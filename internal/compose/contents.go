@@ -0,0 +1,111 @@
+package compose
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/aptly-dev/aptly/utils"
+	"github.com/dionysius/aarg/debext"
+	"github.com/dionysius/aarg/internal/common"
+)
+
+// generateContentsIndex writes the uncompressed Contents-<arch> index of a component
+// Architecture independent packages are listed in the index of every architecture, like in the Debian archive
+// Returns the index path relative to the distribution directory, or an empty path if no package has files
+func (a *Apt) generateContentsIndex(repo *debext.Repository, dist, comp, arch string) (string, error) {
+	allPackages := repo.GetPackageList(dist, comp)
+	if allPackages == nil {
+		return "", nil
+	}
+	allPackages.PrepareIndex()
+
+	pkgList, err := allPackages.Filter(deb.FilterOptions{
+		Queries: []deb.PackageQuery{&deb.FieldQuery{Field: "$Architecture", Relation: deb.VersionEqual, Value: arch}},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to filter packages for architecture %s: %w", arch, err)
+	}
+	if pkgList == nil {
+		return "", nil
+	}
+
+	index := debext.NewContentsIndex()
+	err = pkgList.ForEach(func(pkg *deb.Package) error {
+		files, ok := a.files.Load(pkg)
+		if !ok || len(files.([]string)) == 0 || len(pkg.Files()) == 0 {
+			return nil
+		}
+
+		debFile := filepath.Join(a.options.Trusted, files.([]string)[0])
+		contents, err := debext.PackageContents(debFile, pkg.Files()[0].Checksums.SHA256, a.options.ContentsStore)
+		if os.IsNotExist(err) {
+			// E.g. packages of upstream repositories only referenced in redirect mode
+			slog.Debug("Package file not in trusted storage, left out of Contents index", "repository", a.options.Name, "file", debFile)
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read contents of %s: %w", debFile, err)
+		}
+
+		index.Add(debext.ContentsLocation(pkg), contents)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	if index.Len() == 0 {
+		return "", nil
+	}
+
+	relPath := comp + "/Contents-" + arch
+	f, err := os.Create(filepath.Join(a.options.Target, "dists", dist, relPath))
+	if err != nil {
+		return "", err
+	}
+	if _, err := index.WriteTo(f); err != nil {
+		_ = f.Close()
+		return "", err
+	}
+	return relPath, f.Close()
+}
+
+// compressContentsIndexes gzips the Contents indexes of a distribution, removes the uncompressed
+// indexes and adds the checksums of the compressed ones to files
+func (a *Apt) compressContentsIndexes(ctx context.Context, dist string, relPaths []string, files map[string]utils.ChecksumInfo) error {
+	if len(relPaths) == 0 {
+		return nil
+	}
+
+	distDirPath := filepath.Join(a.options.Target, "dists", dist)
+	sourcePaths := make([]string, 0, len(relPaths))
+	for _, relPath := range slices.Sorted(slices.Values(relPaths)) {
+		sourcePaths = append(sourcePaths, filepath.Join(distDirPath, relPath))
+	}
+
+	results, err := a.decompressor.CompressFiles(ctx, sourcePaths, common.CompressionGzip).Wait()
+	if err != nil {
+		return err
+	}
+
+	for _, result := range results {
+		relPath, err := filepath.Rel(distDirPath, result.Destination())
+		if err != nil {
+			return err
+		}
+		if files[filepath.ToSlash(relPath)], err = utils.ChecksumsForFile(result.Destination()); err != nil {
+			return err
+		}
+	}
+
+	for _, sourcePath := range sourcePaths {
+		if err := os.Remove(sourcePath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
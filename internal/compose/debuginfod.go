@@ -0,0 +1,141 @@
+package compose
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/dionysius/aarg/debext"
+	"github.com/dionysius/aarg/internal/common"
+)
+
+// DebuginfodDir is the directory in the repository serving debug files to debuginfod clients
+// Clients request {DEBUGINFOD_URLS}/buildid/<build-id>/debuginfo, which a static file server answers
+const DebuginfodDir = "debuginfod"
+
+// publishDebuginfod hardlinks the debug files of all packages in the debug component to
+// debuginfod/buildid/<build-id>/debuginfo, extracting them once into the debug info cache
+// Packages without their file in trusted storage, e.g. in redirect mode, are left out
+// Returns the number of published debug files
+func (a *Apt) publishDebuginfod(repo *debext.Repository) (int, error) {
+	// Debug packages in several distributions are published once
+	seen := make(map[string]bool)
+	buildIDs := make(map[string]string) // build ID -> cached debug file
+
+	for _, dist := range repo.GetDistributions() {
+		pkgList := repo.GetPackageList(dist, common.DebugComponent)
+		if pkgList == nil {
+			continue
+		}
+
+		err := pkgList.ForEach(func(pkg *deb.Package) error {
+			files, ok := a.files.Load(pkg)
+			if !ok || len(files.([]string)) == 0 || len(pkg.Files()) == 0 {
+				return nil
+			}
+			sha256 := pkg.Files()[0].Checksums.SHA256
+			if seen[sha256] {
+				return nil
+			}
+			seen[sha256] = true
+
+			debFile := filepath.Join(a.options.Trusted, files.([]string)[0])
+			cached, err := a.cachedDebugFiles(debFile, sha256)
+			if os.IsNotExist(err) {
+				slog.Debug("Package file not in trusted storage, left out of debuginfod", "repository", a.options.Name, "file", debFile)
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("failed to extract debug files of %s: %w", debFile, err)
+			}
+
+			for buildID, path := range cached {
+				buildIDs[buildID] = path
+			}
+			return nil
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	for buildID, path := range buildIDs {
+		targetDir := filepath.Join(a.options.Target, DebuginfodDir, "buildid", buildID)
+		if err := os.MkdirAll(targetDir, 0755); err != nil {
+			return 0, err
+		}
+		if err := common.EnsureHardlink(path, filepath.Join(targetDir, "debuginfo")); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(buildIDs), nil
+}
+
+// cachedDebugFiles returns the debug files of a package by build ID, extracted into
+// {cache}/{sha256[:2]}/{sha256}/<build-id>.debug the first time the package is seen
+func (a *Apt) cachedDebugFiles(debFile, sha256 string) (map[string]string, error) {
+	if len(sha256) < 2 {
+		return nil, fmt.Errorf("package file has no SHA256: %s", debFile)
+	}
+	cacheDir := filepath.Join(a.options.DebuginfoCache, sha256[:2], sha256)
+
+	if _, err := os.Stat(cacheDir); os.IsNotExist(err) {
+		if err := extractDebugFiles(debFile, cacheDir); err != nil {
+			return nil, err
+		}
+	}
+
+	entries, err := os.ReadDir(cacheDir)
+	if err != nil {
+		return nil, err
+	}
+	cached := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		if buildID, ok := strings.CutSuffix(entry.Name(), ".debug"); ok {
+			cached[buildID] = filepath.Join(cacheDir, entry.Name())
+		}
+	}
+	return cached, nil
+}
+
+// extractDebugFiles extracts the debug files of a package into cacheDir
+// Files are extracted into a temporary directory renamed at the end, so an interrupted extraction is retried
+func extractDebugFiles(debFile, cacheDir string) error {
+	if _, err := os.Stat(debFile); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(cacheDir), 0755); err != nil {
+		return fmt.Errorf("failed to create debug info cache directory: %w", err)
+	}
+
+	tmpDir, err := os.MkdirTemp(filepath.Dir(cacheDir), ".tmp-")
+	if err != nil {
+		return fmt.Errorf("failed to create debug info cache directory: %w", err)
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	err = debext.ExtractDebugFiles(debFile, func(buildID string, r io.Reader) error {
+		f, err := os.Create(filepath.Join(tmpDir, buildID+".debug"))
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(f, r); err != nil {
+			_ = f.Close()
+			return err
+		}
+		return f.Close()
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmpDir, cacheDir); err != nil && !os.IsExist(err) {
+		return fmt.Errorf("failed to store debug info cache: %w", err)
+	}
+	return nil
+}
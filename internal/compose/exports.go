@@ -0,0 +1,98 @@
+package compose
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/dionysius/aarg/debext"
+	"github.com/dionysius/aarg/internal/telemetry"
+)
+
+// ExportsDir is the directory of a build with exports of all its repositories for other tooling
+const ExportsDir = "exports"
+
+// PackagesExportFile is the package availability matrix in ExportsDir
+const PackagesExportFile = "packages.csv"
+
+// packagesExportHeader are the columns of the package availability matrix
+var packagesExportHeader = []string{"repository", "distribution", "component", "architecture", "package", "version", "source"}
+
+// indexExports writes the package availability matrix of all repositories in the build to exports/packages.csv
+// One row per package version, so configuration management can look up what is installable where
+// without parsing Debian metadata formats. Repositories carried over from the previous build are included
+func indexExports(ctx context.Context, deps Dependencies) (err error) {
+	_, span := telemetry.Start(ctx, "compose.exports")
+	defer func() { telemetry.End(span, err) }()
+
+	var rows [][]string
+	for _, repo := range deps.Config.Repositories {
+		if _, err := os.Stat(filepath.Join(deps.StagingPath, repo.Name, RepositoryStateFile)); os.IsNotExist(err) {
+			continue // Not part of the build
+		}
+		repository, err := LoadRepositoryState(deps.StagingPath, repo.Name)
+		if err != nil {
+			return err
+		}
+		rows = append(rows, packageRows(repo.Name, repository)...)
+	}
+
+	slices.SortFunc(rows, func(a, b []string) int {
+		return slices.Compare(a, b)
+	})
+
+	exportsDir := filepath.Join(deps.StagingPath, ExportsDir)
+	if err := os.MkdirAll(exportsDir, 0755); err != nil {
+		return err
+	}
+
+	path := filepath.Join(exportsDir, PackagesExportFile)
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", PackagesExportFile, err)
+	}
+	defer func() { _ = f.Close() }()
+
+	w := csv.NewWriter(f)
+	if err := w.Write(packagesExportHeader); err != nil {
+		return err
+	}
+	if err := w.WriteAll(rows); err != nil {
+		return fmt.Errorf("failed to write %s: %w", PackagesExportFile, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", PackagesExportFile, err)
+	}
+
+	slog.Info("Exports generated", "packages", len(rows))
+	return nil
+}
+
+// packageRows returns the rows of the package availability matrix of a repository
+// Source packages are listed with the architecture source
+func packageRows(name string, repository *debext.Repository) [][]string {
+	var rows [][]string
+	for _, dist := range repository.GetDistributions() {
+		for _, comp := range repository.GetComponents(dist) {
+			pkgList := repository.GetPackageList(dist, comp)
+			if pkgList == nil {
+				continue
+			}
+			_ = pkgList.ForEach(func(pkg *deb.Package) error {
+				arch, source := pkg.Architecture, pkg.GetField("$Source")
+				if pkg.IsSource {
+					arch, source = debext.SourceArchitecture, pkg.Name
+				}
+				rows = append(rows, []string{name, dist, comp, arch, pkg.Name, pkg.Version, strings.TrimSpace(source)})
+				return nil
+			})
+		}
+	}
+	return rows
+}
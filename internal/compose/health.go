@@ -0,0 +1,125 @@
+package compose
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/dionysius/aarg/internal/common"
+)
+
+// HealthFile is the health file at the root of every build
+const HealthFile = "healthz.json"
+
+// Health describes the freshness of a build in healthz.json for uptime monitors
+type Health struct {
+	Status        string           `json:"status"`                   // Always "ok", the file only exists in complete builds
+	Build         string           `json:"build"`                    // Staging build name (timestamp)
+	BuildID       string           `json:"build_id"`                 // Short hash of the index checksums of the build
+	GeneratedAt   time.Time        `json:"generated_at"`             // When the build was generated
+	Repositories  int              `json:"repositories"`             // Number of repositories in the build
+	NewestPackage *time.Time       `json:"newest_package,omitempty"` // Modification time of the newest package file in trusted storage
+	MaxAgeHours   int              `json:"max_age_hours,omitempty"`  // Configured maximum age of the build
+	StaleAfter    *time.Time       `json:"stale_after,omitempty"`    // Monitors should alert once this time has passed
+	AgeSeconds    int64            `json:"age_seconds"`              // Age of the build when served, 0 in the static file
+	Stale         bool             `json:"stale"`                    // Whether the build exceeded its maximum age when served
+	Generator     common.BuildInfo `json:"generator"`                // aarg build that generated the build
+	Config        string           `json:"config,omitempty"`         // Fingerprint of the configuration the build was generated with
+}
+
+// NewHealth returns the health of a build generated now
+// maxAgeHours of 0 leaves out the staleness threshold
+func NewHealth(build string, repositories int, newest time.Time, maxAgeHours int) Health {
+	health := Health{
+		Status:       "ok",
+		Build:        build,
+		GeneratedAt:  common.InDisplayLocation(time.Now()),
+		Repositories: repositories,
+		MaxAgeHours:  maxAgeHours,
+		Generator:    common.CurrentBuild(),
+	}
+	if !newest.IsZero() {
+		newest = common.InDisplayLocation(newest)
+		health.NewestPackage = &newest
+	}
+	if maxAgeHours > 0 {
+		staleAfter := health.GeneratedAt.Add(time.Duration(maxAgeHours) * time.Hour)
+		health.StaleAfter = &staleAfter
+	}
+	return health
+}
+
+// At returns the health as seen at the given time, with the age of the build and whether it's stale
+func (h Health) At(now time.Time) Health {
+	h.AgeSeconds = int64(now.Sub(h.GeneratedAt).Seconds())
+	h.Stale = h.StaleAfter != nil && now.After(*h.StaleAfter)
+	return h
+}
+
+// GenerateHealth writes healthz.json to the root of the staging directory
+// The build id is derived from the index checksums listed in the Release files, not from the Release
+// files themselves which contain their generation date, so builds with identical indices share the id
+func GenerateHealth(stagingPath string, health Health) error {
+	buildID, err := releaseHash(stagingPath)
+	if err != nil {
+		return fmt.Errorf("failed to compute build id: %w", err)
+	}
+	health.BuildID = buildID
+
+	return writeJSON(filepath.Join(stagingPath, HealthFile), health)
+}
+
+// LoadHealth reads healthz.json from the root of a build
+func LoadHealth(buildPath string) (Health, error) {
+	var health Health
+	data, err := os.ReadFile(filepath.Join(buildPath, HealthFile))
+	if err != nil {
+		return health, err
+	}
+	err = json.Unmarshal(data, &health)
+	return health, err
+}
+
+// releaseHash returns the first 12 hex characters of the SHA256 over the SHA256 sections of all Release files of the build
+func releaseHash(stagingPath string) (string, error) {
+	matches, err := filepath.Glob(filepath.Join(stagingPath, "*", "dists", "*", "Release"))
+	if err != nil {
+		return "", err
+	}
+	slices.Sort(matches)
+
+	hasher := sha256.New()
+	for _, match := range matches {
+		data, err := os.ReadFile(match)
+		if err != nil {
+			return "", err
+		}
+		rel, _ := filepath.Rel(stagingPath, match)
+		_, _ = hasher.Write([]byte(filepath.ToSlash(rel) + "\n"))
+		_, _ = hasher.Write([]byte(releaseChecksums(string(data))))
+	}
+
+	return hex.EncodeToString(hasher.Sum(nil))[:12], nil
+}
+
+// releaseChecksums returns the SHA256 section of a Release file listing checksum, size and path of every index
+func releaseChecksums(release string) string {
+	var section strings.Builder
+	inSection := false
+	for line := range strings.Lines(release) {
+		if !strings.HasPrefix(line, " ") {
+			inSection = strings.TrimSpace(line) == "SHA256:"
+			continue
+		}
+		if inSection {
+			section.WriteString(line)
+		}
+	}
+	return section.String()
+}
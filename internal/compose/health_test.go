@@ -0,0 +1,37 @@
+package compose
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReleaseHash(t *testing.T) {
+	release := func(date, checksum string) string {
+		return "Origin: test\nDate: " + date + "\nMD5Sum:\n 0123 10 main/binary-amd64/Packages\nSHA256:\n " + checksum + " 10 main/binary-amd64/Packages\n"
+	}
+	write := func(t *testing.T, content string) string {
+		t.Helper()
+		dir := t.TempDir()
+		path := filepath.Join(dir, "repo", "dists", "stable", "Release")
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+		return dir
+	}
+
+	first, err := releaseHash(write(t, release("Mon, 01 Jan 2025 12:00:00 UTC", "abcd")))
+	require.NoError(t, err)
+	assert.Len(t, first, 12)
+
+	// Builds with identical indices share the id regardless of their date
+	regenerated, err := releaseHash(write(t, release("Tue, 02 Jan 2025 12:00:00 UTC", "abcd")))
+	require.NoError(t, err)
+	assert.Equal(t, first, regenerated)
+
+	changed, err := releaseHash(write(t, release("Mon, 01 Jan 2025 12:00:00 UTC", "ef01")))
+	require.NoError(t, err)
+	assert.NotEqual(t, first, changed)
+}
@@ -0,0 +1,161 @@
+package compose
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/dionysius/aarg/debext"
+)
+
+// DistFingerprintsFile records the fingerprint of every distribution of a repository in incremental builds
+const DistFingerprintsFile = ".dists.json"
+
+// loadDistFingerprints reads the distribution fingerprints of a repository in a build, nil if it has none
+func loadDistFingerprints(repoPath string) map[string]string {
+	data, err := os.ReadFile(filepath.Join(repoPath, DistFingerprintsFile))
+	if err != nil {
+		return nil
+	}
+
+	var fingerprints map[string]string
+	if err := json.Unmarshal(data, &fingerprints); err != nil {
+		return nil
+	}
+	return fingerprints
+}
+
+// distFingerprint hashes everything the dists tree of a distribution is generated from
+// These are the package indexes as written and the Release fields, but not the Release date
+func (a *Apt) distFingerprint(repo *debext.Repository, dist string, comps []string) (string, error) {
+	hasher := sha256.New()
+	_, _ = fmt.Fprintf(hasher, "settings %s\nsource %t\n", a.options.Settings, a.options.Repository.Packages.Source)
+
+	metadata, err := a.options.Repository.Release.Resolve(a.options.Name, dist)
+	if err != nil {
+		return "", err
+	}
+	_, _ = fmt.Fprintf(hasher, "origin %s\nlabel %s\nsuite %s\n", metadata.Origin, metadata.Label, metadata.Suite)
+	fields := a.releaseFields()
+	for _, key := range slices.Sorted(maps.Keys(fields)) {
+		_, _ = fmt.Fprintf(hasher, "field %s %s\n", key, fields[key])
+	}
+
+	for _, comp := range comps {
+		allPackages := repo.GetPackageList(dist, comp)
+		if allPackages == nil {
+			// Component without packages in this distribution
+			_, _ = fmt.Fprintf(hasher, "empty %s\n", comp)
+			continue
+		}
+		allPackages.PrepareIndex()
+
+		for _, arch := range repo.GetArchitectures(dist, comp, a.options.Repository.Packages.Source) {
+			pkgList, err := allPackages.Filter(deb.FilterOptions{
+				Queries: []deb.PackageQuery{&deb.FieldQuery{Field: "$Architecture", Relation: deb.VersionEqual, Value: arch}},
+			})
+			if err != nil {
+				return "", fmt.Errorf("failed to filter packages for architecture %s: %w", arch, err)
+			}
+			if pkgList == nil {
+				continue
+			}
+
+			_, _ = fmt.Fprintf(hasher, "index %s %s\n", comp, arch)
+			if err := debext.GeneratePackageIndex(hasher, pkgList, arch == debext.SourceArchitecture); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// reuseDistribution hardlinks the dists tree of a distribution from the previous build if its fingerprint is unchanged
+// Distributions whose Release file is older than RefreshAfter are regenerated to keep their date fresh
+// Returns false if the distribution has to be generated
+func (a *Apt) reuseDistribution(dist, fingerprint string) bool {
+	if a.previousFingerprints[dist] != fingerprint {
+		return false
+	}
+
+	src := filepath.Join(a.options.Previous, a.options.Name, "dists", dist)
+	info, err := os.Stat(filepath.Join(src, "InRelease"))
+	if err != nil {
+		return false
+	}
+	if a.options.RefreshAfter > 0 && time.Since(info.ModTime()) > a.options.RefreshAfter {
+		slog.Debug("Regenerating unchanged distribution to refresh its Release date", "repository", a.options.Name, "distribution", dist)
+		return false
+	}
+
+	dst := filepath.Join(a.options.Target, "dists", dist)
+	if err := linkTree(src, dst, skipIndexes); err != nil {
+		// E.g. a one-off build on another filesystem
+		slog.Debug("Failed to reuse distribution of the previous build", "repository", a.options.Name, "distribution", dist, "error", err)
+		_ = os.RemoveAll(dst)
+		return false
+	}
+
+	return true
+}
+
+// recordFingerprint keeps the fingerprint of a generated or reused distribution for the next build
+func (a *Apt) recordFingerprint(dist, fingerprint string, reused bool) {
+	a.fingerprintMu.Lock()
+	defer a.fingerprintMu.Unlock()
+
+	a.fingerprints[dist] = fingerprint
+	if reused {
+		a.reused++
+	}
+}
+
+// Reused returns the number of distributions hardlinked from the previous build
+func (a *Apt) Reused() int {
+	return a.reused
+}
+
+// skipIndexes leaves out directory indexes and their precompressed siblings, the web composer writes them for this build
+func skipIndexes(rel string, d fs.DirEntry) bool {
+	return strings.HasPrefix(d.Name(), "index.html")
+}
+
+// linkTree recreates the directory tree at src in dst with hardlinked files
+// Entries for which skip returns true are left out, skipped directories with everything below them
+func linkTree(src, dst string, skip func(rel string, d fs.DirEntry) bool) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		if skip != nil && rel != "." && skip(rel, d) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		return os.Link(path, target)
+	})
+}
@@ -0,0 +1,194 @@
+package compose
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/dionysius/aarg/debext"
+	"github.com/dionysius/aarg/internal/common"
+	"github.com/dionysius/aarg/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApt_distFingerprint(t *testing.T) {
+	// build describes the inputs of a build, each case changes one of them
+	type build struct {
+		deps     Dependencies
+		options  AptComposeOptions
+		packages map[string][]*deb.Package // Packages by distribution, all in component main
+	}
+
+	base := func() *build {
+		return &build{
+			deps: Dependencies{
+				Config:          &config.Config{Generate: config.GenerateConfig{PoolMode: "hierarchical"}},
+				PublicKeyBinary: []byte("signing key"),
+			},
+			options: AptComposeOptions{
+				ComposeOptions: ComposeOptions{Name: "test"},
+				Repository:     &common.RepositoryOptions{},
+			},
+			packages: map[string][]*deb.Package{
+				"stable":  {newTestPackage("hello", "1.0-1", "aaaa")},
+				"testing": {newTestPackage("hello", "1.1-1", "bbbb")},
+			},
+		}
+	}
+
+	fingerprint := func(t *testing.T, b *build) string {
+		t.Helper()
+		repo := debext.NewRepository()
+		for dist, pkgs := range b.packages {
+			for _, pkg := range pkgs {
+				require.NoError(t, repo.AddPackage(pkg, dist, "main"))
+			}
+		}
+
+		options := b.options
+		options.Settings = buildSettings(b.deps)
+		a := &Apt{options: &options}
+		got, err := a.distFingerprint(repo, "stable", []string{"main"})
+		require.NoError(t, err)
+		return got
+	}
+
+	tests := []struct {
+		name    string
+		change  func(b *build)
+		changed bool
+	}{
+		{name: "unchanged", change: func(b *build) {}},
+		{name: "other distribution changed", change: func(b *build) {
+			b.packages["testing"] = []*deb.Package{newTestPackage("hello", "1.2-1", "cccc")}
+		}},
+		{name: "package added", changed: true, change: func(b *build) {
+			b.packages["stable"] = append(b.packages["stable"], newTestPackage("hello", "1.1-1", "bbbb"))
+		}},
+		{name: "package content changed", changed: true, change: func(b *build) {
+			b.packages["stable"] = []*deb.Package{newTestPackage("hello", "1.0-1", "cccc")}
+		}},
+		{name: "package removed", changed: true, change: func(b *build) {
+			delete(b.packages, "stable")
+		}},
+		{name: "signing key", changed: true, change: func(b *build) {
+			b.deps.PublicKeyBinary = []byte("other signing key")
+		}},
+		{name: "pool mode", changed: true, change: func(b *build) {
+			b.deps.Config.Generate.PoolMode = "redirect"
+		}},
+		{name: "compression", changed: true, change: func(b *build) {
+			b.deps.Config.Generate.Compression.XZ = 9
+		}},
+		{name: "pdiff history", changed: true, change: func(b *build) {
+			b.deps.Config.Generate.PDiffHistory = 10
+		}},
+		{name: "contents", changed: true, change: func(b *build) {
+			b.deps.Config.Generate.Contents = true
+		}},
+		{name: "translations", changed: true, change: func(b *build) {
+			b.deps.Config.Generate.Translations = true
+		}},
+		{name: "sigstore", changed: true, change: func(b *build) {
+			b.deps.Sigstore = &debext.SigstoreSigner{}
+		}},
+		{name: "source packages", changed: true, change: func(b *build) {
+			b.options.Repository.Packages.Source = true
+		}},
+		{name: "release origin", changed: true, change: func(b *build) {
+			b.options.Repository.Release.Origin = "Example"
+		}},
+		{name: "release suite of distribution", changed: true, change: func(b *build) {
+			b.options.Repository.Release.Distributions = map[string]common.ReleaseMetadata{"stable": {Suite: "bookworm"}}
+		}},
+		{name: "release suite of other distribution", change: func(b *build) {
+			b.options.Repository.Release.Distributions = map[string]common.ReleaseMetadata{"testing": {Suite: "trixie"}}
+		}},
+		{name: "release field", changed: true, change: func(b *build) {
+			b.options.ReleaseFields = map[string]string{"X-Example": "value"}
+		}},
+	}
+
+	want := fingerprint(t, base())
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := base()
+			tt.change(b)
+			got := fingerprint(t, b)
+			if tt.changed {
+				assert.NotEqual(t, want, got)
+			} else {
+				assert.Equal(t, want, got)
+			}
+		})
+	}
+}
+
+func TestApt_reuseDistribution(t *testing.T) {
+	const fingerprint = "fingerprint"
+
+	tests := []struct {
+		name         string
+		fingerprint  string
+		noInRelease  bool
+		age          time.Duration // Age of the previous Release files
+		refreshAfter time.Duration
+		want         bool
+	}{
+		{name: "unchanged", fingerprint: fingerprint, want: true},
+		{name: "changed", fingerprint: "other"},
+		{name: "previous build without distribution", fingerprint: fingerprint, noInRelease: true},
+		{name: "unchanged within refresh age", fingerprint: fingerprint, age: time.Hour, refreshAfter: 24 * time.Hour, want: true},
+		{name: "unchanged but Release due for refresh", fingerprint: fingerprint, age: 48 * time.Hour, refreshAfter: 24 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			previous := t.TempDir()
+			src := filepath.Join(previous, "test", "dists", "stable")
+			files := []string{"Release", "main/binary-amd64/Packages", "main/binary-amd64/Packages.xz"}
+			if !tt.noInRelease {
+				files = append(files, "InRelease")
+			}
+			for _, name := range append(files, "index.html", "main/index.html.gz") {
+				path := filepath.Join(src, name)
+				require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+				require.NoError(t, os.WriteFile(path, []byte(name), 0644))
+				modified := time.Now().Add(-tt.age)
+				require.NoError(t, os.Chtimes(path, modified, modified))
+			}
+
+			target := t.TempDir()
+			a := &Apt{
+				options: &AptComposeOptions{
+					ComposeOptions: ComposeOptions{Name: "test", Target: target},
+					Previous:       previous,
+					RefreshAfter:   tt.refreshAfter,
+				},
+				previousFingerprints: map[string]string{"stable": fingerprint},
+			}
+
+			dst := filepath.Join(target, "dists", "stable")
+			if !tt.want {
+				assert.False(t, a.reuseDistribution("stable", tt.fingerprint))
+				assert.NoDirExists(t, dst, "regenerated distributions are not linked")
+				return
+			}
+			require.True(t, a.reuseDistribution("stable", tt.fingerprint))
+
+			// Files are hardlinked, directory indexes are written by the web composer of this build
+			for _, name := range files {
+				srcInfo, err := os.Stat(filepath.Join(src, name))
+				require.NoError(t, err)
+				dstInfo, err := os.Stat(filepath.Join(dst, name))
+				require.NoError(t, err, name)
+				assert.True(t, os.SameFile(srcInfo, dstInfo), name)
+			}
+			assert.NoFileExists(t, filepath.Join(dst, "index.html"))
+			assert.NoFileExists(t, filepath.Join(dst, "main", "index.html.gz"))
+		})
+	}
+}
@@ -3,9 +3,13 @@ package compose
 import (
 	"bytes"
 	_ "embed"
+	"fmt"
 	"net/url"
 	"regexp"
+	"strings"
 	"text/template"
+
+	"github.com/dionysius/aarg/internal/common"
 )
 
 //go:embed templates/install.sh
@@ -16,7 +20,12 @@ type InstallScriptOptions struct {
 	RepoName      string   // Repository name
 	BaseURL       string   // Base URL for the repository
 	Distributions []string // Available distributions
+	Components    []string // Package components, all are configured unless the script is told otherwise, empty = main
 	KeyringName   string   // Keyring filename (sanitized domain)
+	MaxAgeHours   int      // Maximum age of the repository indexes before apt refuses them, 0 = unlimited
+	SourceOnly    bool     // Repository publishes only source packages, sources are configured as deb-src
+
+	Links common.LinkOptions // Repository links mentioned in the script header
 }
 
 var nonAlphanumericRegex = regexp.MustCompile(`[^a-zA-Z0-9]+`)
@@ -55,3 +64,33 @@ func GenerateInstallScript(opts InstallScriptOptions) (string, error) {
 
 	return buf.String(), nil
 }
+
+// GenerateSourcesFile generates a DEB822 sources file for a single distribution
+// The keyring is expected at the location the install script places it
+func GenerateSourcesFile(opts InstallScriptOptions, dist string) string {
+	types := "deb"
+	if opts.SourceOnly {
+		types = "deb-src"
+	}
+	sources := fmt.Sprintf("Types: %s\nURIs: %s/%s\nSuites: %s\nComponents: %s\nSigned-By: /etc/apt/keyrings/%s.gpg\n",
+		types, opts.BaseURL, opts.RepoName, dist, opts.ComponentList(), opts.KeyringName)
+	if validUntil := opts.ValidUntilMax(); validUntil > 0 {
+		sources += fmt.Sprintf("Valid-Until-Max: %d\n", validUntil)
+	}
+	return sources
+}
+
+// ComponentList returns the package components separated by spaces like in sources files
+func (opts InstallScriptOptions) ComponentList() string {
+	if len(opts.Components) == 0 {
+		return common.MainComponent
+	}
+	return strings.Join(opts.Components, " ")
+}
+
+// ValidUntilMax returns the seconds after the Date of a Release file apt considers it expired, 0 = unlimited
+// Release files carry no Valid-Until, with this sources option apt still refuses indexes of a stalled publish
+// (needs Check-Valid-Until, which is enabled by default)
+func (opts InstallScriptOptions) ValidUntilMax() int {
+	return opts.MaxAgeHours * 3600
+}
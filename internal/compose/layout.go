@@ -0,0 +1,106 @@
+package compose
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// LayoutFile is the file in a repository recording the column order of its web tables
+const LayoutFile = "layout.json"
+
+// Layout is the column order of the web tables of a repository
+// It is carried over from the previous build so columns keep their position, and gives
+// tools comparing builds a stable order to present repositories in
+type Layout struct {
+	Distributions []string            `json:"distributions"`
+	Architectures map[string][]string `json:"architectures"` // Table ID -> architectures, source tables have none
+}
+
+// loadLayout reads the layout of a repository in a build, nil if the build has none
+func loadLayout(buildPath, name string) *Layout {
+	if buildPath == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(filepath.Join(buildPath, name, LayoutFile))
+	if err != nil {
+		return nil
+	}
+
+	var layout Layout
+	if err := json.Unmarshal(data, &layout); err != nil {
+		return nil
+	}
+	return &layout
+}
+
+// newLayout returns the layout of prepared tables
+func newLayout(tables []PreparedPackageTable) *Layout {
+	layout := &Layout{Architectures: make(map[string][]string)}
+	for _, table := range tables {
+		if layout.Distributions == nil && len(table.DistHeaders) > 0 {
+			for _, header := range table.DistHeaders {
+				layout.Distributions = append(layout.Distributions, header.Name)
+			}
+		}
+		if table.HasArchRow {
+			layout.Architectures[table.ID] = table.Architectures
+		}
+	}
+	return layout
+}
+
+// distributions returns the previous distribution order, nil layouts have none
+func (l *Layout) distributions() []string {
+	if l == nil {
+		return nil
+	}
+	return l.Distributions
+}
+
+// architectures returns the previous architecture order of a table, nil layouts have none
+func (l *Layout) architectures(table string) []string {
+	if l == nil {
+		return nil
+	}
+	return l.Architectures[table]
+}
+
+// stickyOrder orders items like previous and places items not in previous by their order in computed
+// A new item sorted first in computed is placed first, others before the next known item following
+// them in computed, so new columns appear where expected while known columns keep their relative order
+func stickyOrder(previous, computed []string) []string {
+	if len(previous) == 0 {
+		return computed
+	}
+
+	result := make([]string, 0, len(computed))
+	for _, item := range previous {
+		if slices.Contains(computed, item) && !slices.Contains(result, item) {
+			result = append(result, item)
+		}
+	}
+
+	for i, item := range computed {
+		if slices.Contains(result, item) {
+			continue
+		}
+
+		// Items computed before all known items go first, others before their next known item
+		pos := 0
+		if slices.ContainsFunc(computed[:i], func(prev string) bool { return slices.Contains(result, prev) }) {
+			pos = len(result)
+			for _, next := range computed[i+1:] {
+				if idx := slices.Index(result, next); idx >= 0 {
+					pos = idx
+					break
+				}
+			}
+		}
+		result = slices.Insert(result, pos, item)
+	}
+
+	return result
+}
@@ -0,0 +1,197 @@
+package compose
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/dionysius/aarg/internal/common"
+	"github.com/dionysius/aarg/internal/feed"
+	"github.com/dionysius/aarg/internal/log"
+	"github.com/dionysius/aarg/internal/telemetry"
+)
+
+// MetadataDir is the directory in a repository the metadata composer writes to
+const MetadataDir = "metadata"
+
+// PackageMetadata describes a package of the repository in packages.json
+type PackageMetadata struct {
+	Name         string `json:"name"`
+	Version      string `json:"version"`
+	Architecture string `json:"architecture"`
+	Source       string `json:"source"`
+	Distribution string `json:"distribution"`
+	Component    string `json:"component"`
+}
+
+// ProvenanceEntry describes where a package file of the repository originates from in provenance.json
+type ProvenanceEntry struct {
+	Path        string     `json:"path"`                   // Path as referenced by the index, relative to the repository
+	SHA256      string     `json:"sha256"`                 // SHA256 of the file
+	Size        int64      `json:"size"`                   // Size in bytes
+	Package     string     `json:"package"`                // Package name, version and architecture
+	FeedType    string     `json:"feed_type,omitempty"`    // Type of the feed the package was read from
+	FeedName    string     `json:"feed_name,omitempty"`    // Name of the feed the package was read from
+	FeedURL     string     `json:"feed_url,omitempty"`     // Project page of the feed
+	Buildinfo   []string   `json:"buildinfo,omitempty"`    // Published .buildinfo files of the source version
+	UpstreamURL string     `json:"upstream_url,omitempty"` // URL the file was downloaded from
+	Release     string     `json:"release,omitempty"`      // Release tag the file was published in, GitHub feeds only
+	FetchedAt   *time.Time `json:"fetched_at,omitempty"`   // When the file arrived in trusted storage
+	Signers     []string   `json:"signers,omitempty"`      // Key IDs of the signatures the file was verified with
+}
+
+// Report summarizes the generation of a repository in report.json
+type Report struct {
+	Repository    string              `json:"repository"`
+	Owners        []string            `json:"owners,omitempty"`
+	GeneratedAt   time.Time           `json:"generated_at"`
+	Distributions []string            `json:"distributions"`
+	Packages      int                 `json:"packages"`
+	Conflicts     []PackageConflict   `json:"conflicts"`
+	Violations    []PolicyViolation   `json:"violations"`
+	Regressions   []VersionRegression `json:"regressions"`
+	Warnings      []log.Warning       `json:"warnings"` // Warnings of the run logged for the repository so far
+}
+
+// composeMetadata writes the JSON API, provenance and report files of a repository to metadata/
+func composeMetadata(ctx context.Context, deps Dependencies, results *Results) (err error) {
+	repo := deps.Repository
+
+	_, span := telemetry.Start(ctx, "compose.metadata", telemetry.RepositoryKey.String(repo.Name))
+	defer func() { telemetry.End(span, err) }()
+
+	packages := []PackageMetadata{}
+	provenance := []ProvenanceEntry{}
+
+	repository := results.Repository
+	for _, dist := range repository.GetDistributions() {
+		for _, comp := range repository.GetComponents(dist) {
+			_ = repository.GetPackageList(dist, comp).ForEach(func(pkg *deb.Package) error {
+				packages = append(packages, PackageMetadata{
+					Name:         pkg.Name,
+					Version:      pkg.Version,
+					Architecture: pkg.Architecture,
+					Source:       pkg.GetField("$Source"),
+					Distribution: dist,
+					Component:    comp,
+				})
+				provenance = append(provenance, packageProvenance(results.Apt, pkg)...)
+				return nil
+			})
+		}
+	}
+
+	report := Report{
+		Repository:    repo.Name,
+		Owners:        repo.Owners,
+		GeneratedAt:   common.InDisplayLocation(time.Now()),
+		Distributions: repository.GetDistributions(),
+		Packages:      len(packages),
+		Conflicts:     append([]PackageConflict{}, results.Apt.Conflicts()...),
+		Violations:    append([]PolicyViolation{}, results.Apt.Violations()...),
+		Regressions:   append([]VersionRegression{}, results.Apt.Regressions()...),
+		Warnings:      append([]log.Warning{}, log.RepositoryWarnings(repo.Name)...),
+	}
+
+	metadataDir := filepath.Join(deps.StagingPath, repo.Name, MetadataDir)
+	if err := os.MkdirAll(metadataDir, 0755); err != nil {
+		return err
+	}
+
+	files := map[string]any{
+		"packages.json":   packages,
+		"provenance.json": provenance,
+		"report.json":     report,
+	}
+	for name, content := range files {
+		if err := writeJSON(filepath.Join(metadataDir, name), content); err != nil {
+			return err
+		}
+	}
+
+	slog.Info("Metadata generated", "repository", repo.Name, "packages", len(packages), "files", len(provenance))
+	return nil
+}
+
+// packageProvenance returns the provenance entries of the files of a package
+// Paths are the ones advertised in the package index
+func packageProvenance(composer *Apt, pkg *deb.Package) []ProvenanceEntry {
+	stanza := pkg.Stanza()
+	origin := composer.Origin(pkg)
+	upstream := composer.Upstream(pkg)
+
+	entry := ProvenanceEntry{
+		Package:   pkg.String(),
+		Buildinfo: composer.Buildinfo(pkg),
+	}
+	if origin != nil {
+		entry.FeedType = string(origin.Type)
+		entry.FeedName = origin.Name
+		if origin.ProjectURL != nil {
+			entry.FeedURL = origin.ProjectURL.String()
+		}
+	}
+	if fetched := composer.Fetched(pkg); !fetched.IsZero() {
+		fetched = common.InDisplayLocation(fetched)
+		entry.FetchedAt = &fetched
+	}
+
+	var entries []ProvenanceEntry
+	for i, file := range pkg.Files() {
+		fileEntry := entry
+		if pkg.IsSource {
+			fileEntry.Path = strings.TrimSuffix(stanza["Directory"], "/") + "/" + file.Filename
+		} else {
+			fileEntry.Path = stanza["Filename"]
+		}
+		fileEntry.SHA256 = file.Checksums.SHA256
+		fileEntry.Size = file.Checksums.Size
+
+		// Upstream is only known for packages read from a feed in this run
+		if i < len(upstream) {
+			fileEntry.Signers = upstream[i].Signers
+			if location := upstream[i].Location; location != "" && origin.DownloadURL != nil {
+				fileEntry.UpstreamURL = origin.DownloadURL.JoinPath(location).String()
+				// GitHub release assets are downloaded from <tag>/<asset>
+				if feed.FeedType(origin.Type) == feed.FeedTypeGitHub {
+					fileEntry.Release, _, _ = strings.Cut(location, "/")
+				}
+			}
+		}
+		entries = append(entries, fileEntry)
+	}
+
+	return entries
+}
+
+// repositoryProvenance returns the provenance of the package files of the composed repository by path
+func repositoryProvenance(results *Results) map[string]ProvenanceEntry {
+	provenance := make(map[string]ProvenanceEntry)
+	repository := results.Repository
+	for _, dist := range repository.GetDistributions() {
+		for _, comp := range repository.GetComponents(dist) {
+			_ = repository.GetPackageList(dist, comp).ForEach(func(pkg *deb.Package) error {
+				for _, entry := range packageProvenance(results.Apt, pkg) {
+					provenance[entry.Path] = entry
+				}
+				return nil
+			})
+		}
+	}
+	return provenance
+}
+
+// writeJSON writes content as indented JSON to path
+func writeJSON(path string, content any) error {
+	data, err := json.MarshalIndent(content, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %w", filepath.Base(path), err)
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
@@ -0,0 +1,106 @@
+package compose
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/dionysius/aarg/debext"
+	"github.com/dionysius/aarg/internal/common"
+)
+
+// loadKeptDistributions determines the distributions taken unchanged from the previous build
+// These are the published distributions of the previous build not selected for generation
+// Without previous build or repository state all distributions are generated
+func (a *Apt) loadKeptDistributions() {
+	a.keptDists = make(map[string]bool)
+	if a.options.Previous == "" {
+		slog.Warn("No previous build to keep distributions from, generating all", "repository", a.options.Name)
+		return
+	}
+
+	previous, err := LoadRepositoryState(a.options.Previous, a.options.Name)
+	if err != nil {
+		slog.Warn("Failed to load previous repository state, generating all distributions", "repository", a.options.Name, "error", err)
+		return
+	}
+
+	for _, dist := range previous.GetDistributions() {
+		if slices.Contains(a.options.Distributions, dist) {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(a.options.Previous, a.options.Name, "dists", dist, "Release")); err != nil {
+			continue
+		}
+		a.keptDists[dist] = true
+	}
+	a.previousState = previous
+
+	slog.Debug("Keeping distributions of the previous build", "repository", a.options.Name, "distributions", len(a.keptDists))
+}
+
+// addKeptPackages adds the packages of the kept distributions as published in the previous build
+func (a *Apt) addKeptPackages(repo *debext.Repository) error {
+	for dist := range a.keptDists {
+		for _, comp := range a.previousState.GetComponents(dist) {
+			err := a.previousState.GetPackageList(dist, comp).ForEach(func(pkg *deb.Package) error {
+				return repo.AddPackage(pkg, dist, comp)
+			})
+			if err != nil {
+				return fmt.Errorf("failed to keep distribution %s: %w", dist, err)
+			}
+		}
+	}
+	return nil
+}
+
+// keepDistribution hardlinks the dists tree and pool files of a kept distribution from the previous build
+func (a *Apt) keepDistribution(repo *debext.Repository, dist string) error {
+	src := filepath.Join(a.options.Previous, a.options.Name, "dists", dist)
+	dst := filepath.Join(a.options.Target, "dists", dist)
+	if err := linkTree(src, dst, skipIndexes); err != nil {
+		return fmt.Errorf("failed to keep distribution %s of the previous build: %w", dist, err)
+	}
+
+	// The next incremental build compares against the fingerprint it was generated with
+	if fingerprint, ok := a.previousFingerprints[dist]; ok {
+		a.recordFingerprint(dist, fingerprint, false)
+	}
+
+	// In redirect mode the packages point to their upstream location
+	if a.options.PoolMode == "redirect" {
+		return nil
+	}
+
+	for _, comp := range repo.GetComponents(dist) {
+		err := repo.GetPackageList(dist, comp).ForEach(func(pkg *deb.Package) error {
+			return a.linkPreviousPackageFile(pkg, comp)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// linkPreviousPackageFile hardlinks the pool files of a package published in the previous build
+func (a *Apt) linkPreviousPackageFile(pkg *deb.Package, comp string) error {
+	relDir := debext.GetPoolPath(comp, debext.GetSourceNameFromPackage(pkg))
+	if err := os.MkdirAll(filepath.Join(a.options.Target, relDir), 0755); err != nil {
+		return err
+	}
+
+	for _, file := range pkg.Files() {
+		sourcePath := filepath.Join(a.options.Previous, a.options.Name, relDir, file.Filename)
+		targetPath := filepath.Join(a.options.Target, relDir, file.Filename)
+		if err := common.EnsureHardlink(sourcePath, targetPath); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
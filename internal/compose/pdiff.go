@@ -0,0 +1,173 @@
+package compose
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/aptly-dev/aptly/utils"
+	"github.com/dionysius/aarg/debext"
+	"github.com/dionysius/aarg/internal/common"
+)
+
+// pdiffTimestampFormat names patches by the time of the build, like dak does
+const pdiffTimestampFormat = "2006-01-02-1504.05"
+
+// pdiffMaxEdits limits the changed lines of a patch, larger changes are cheaper to download as full index
+// The diff needs memory quadratic to the changed lines, this keeps it in the order of megabytes
+const pdiffMaxEdits = 2000
+
+// generatePDiffs writes <index>.diff/ with patches from the indexes of the previous build for every uncompressed index
+// Returns the Index files of the patches to be listed in the Release file
+func (a *Apt) generatePDiffs(dist string, files map[string]utils.ChecksumInfo) (map[string]utils.ChecksumInfo, error) {
+	indexes := make(map[string]utils.ChecksumInfo)
+	if a.options.PDiffHistory <= 0 || a.options.Previous == "" {
+		return indexes, nil
+	}
+
+	distDirPath := filepath.Join(a.options.Target, "dists", dist)
+	previousDistPath := filepath.Join(a.options.Previous, a.options.Name, "dists", dist)
+	name := time.Now().UTC().Format(pdiffTimestampFormat)
+
+	for relPath, checksums := range files {
+		index, err := a.generatePDiff(filepath.Join(previousDistPath, relPath), filepath.Join(distDirPath, relPath), checksums, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate pdiff of %s/%s: %w", dist, relPath, err)
+		}
+		if index == "" {
+			continue
+		}
+
+		rel, err := filepath.Rel(distDirPath, index)
+		if err != nil {
+			return nil, err
+		}
+		if indexes[filepath.ToSlash(rel)], err = utils.ChecksumsForFile(index); err != nil {
+			return nil, err
+		}
+	}
+
+	return indexes, nil
+}
+
+// generatePDiff extends the patch history of the previous build of an index with a patch to the current index
+// Patches still listed are hardlinked from the previous build
+// Returns the path of the written Index, empty if there is no history to publish
+func (a *Apt) generatePDiff(previousPath, currentPath string, current utils.ChecksumInfo, name string) (string, error) {
+	previousData, err := os.ReadFile(previousPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	previous, err := utils.ChecksumsForFile(previousPath)
+	if err != nil {
+		return "", err
+	}
+
+	// An unreadable history only restarts it
+	previousDiffDir := previousPath + ".diff"
+	index := &debext.PDiffIndex{}
+	if f, err := os.Open(filepath.Join(previousDiffDir, "Index")); err == nil {
+		parsed, err := debext.ParsePDiffIndex(f)
+		_ = f.Close()
+		if err == nil && parsed.Current.SHA256 == previous.SHA256 {
+			index = parsed
+		}
+	}
+
+	diffDir := currentPath + ".diff"
+	if err := os.MkdirAll(diffDir, 0755); err != nil {
+		return "", err
+	}
+
+	if previous.SHA256 != current.SHA256 {
+		currentData, err := os.ReadFile(currentPath)
+		if err != nil {
+			return "", err
+		}
+
+		entry, err := writePDiffPatch(filepath.Join(diffDir, name), previousData, currentData)
+		if errors.Is(err, debext.ErrPDiffTooLarge) {
+			// Older versions can't be patched past this change, clients download the full index once
+			slog.Debug("Index changed too much for a patch, restarting its history", "index", currentPath)
+			index.Entries = nil
+		} else if err != nil {
+			return "", err
+		} else {
+			entry.Name = name
+			entry.History = debext.PDiffFile{SHA256: previous.SHA256, Size: previous.Size}
+			index.Entries = append(index.Entries, entry)
+		}
+	}
+
+	if len(index.Entries) > a.options.PDiffHistory {
+		index.Entries = index.Entries[len(index.Entries)-a.options.PDiffHistory:]
+	}
+
+	// Keep the patches of the previous build, a missing patch breaks the chain before it
+	for i := len(index.Entries) - 1; i >= 0; i-- {
+		entry := index.Entries[i]
+		if entry.Name == name && previous.SHA256 != current.SHA256 {
+			continue
+		}
+		patch := entry.Name + common.CompressionGzip.Extension()
+		if err := common.EnsureHardlink(filepath.Join(previousDiffDir, patch), filepath.Join(diffDir, patch)); err != nil {
+			index.Entries = index.Entries[i+1:]
+			break
+		}
+	}
+
+	if len(index.Entries) == 0 {
+		return "", os.RemoveAll(diffDir)
+	}
+
+	index.Current = debext.PDiffFile{SHA256: current.SHA256, Size: current.Size}
+	var buf bytes.Buffer
+	if err := debext.GeneratePDiffIndex(&buf, index); err != nil {
+		return "", err
+	}
+	indexPath := filepath.Join(diffDir, "Index")
+	return indexPath, os.WriteFile(indexPath, buf.Bytes(), 0644)
+}
+
+// writePDiffPatch writes the gzip compressed ed script from previous to current to path.gz
+// Returns the checksums of the patch and its download
+func writePDiffPatch(path string, previous, current []byte) (debext.PDiffEntry, error) {
+	script, err := debext.GenerateEdDiff(previous, current, pdiffMaxEdits)
+	if err != nil {
+		return debext.PDiffEntry{}, err
+	}
+	scriptSum := sha256.Sum256(script)
+
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	if _, err := gz.Write(script); err != nil {
+		return debext.PDiffEntry{}, err
+	}
+	if err := gz.Close(); err != nil {
+		return debext.PDiffEntry{}, err
+	}
+
+	download := path + common.CompressionGzip.Extension()
+	if err := os.WriteFile(download, compressed.Bytes(), 0644); err != nil {
+		return debext.PDiffEntry{}, err
+	}
+	checksums, err := utils.ChecksumsForFile(download)
+	if err != nil {
+		return debext.PDiffEntry{}, err
+	}
+
+	return debext.PDiffEntry{
+		Patch:    debext.PDiffFile{SHA256: hex.EncodeToString(scriptSum[:]), Size: int64(len(script))},
+		Download: debext.PDiffFile{SHA256: checksums.SHA256, Size: checksums.Size},
+	}, nil
+}
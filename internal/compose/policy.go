@@ -0,0 +1,125 @@
+package compose
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/dionysius/aarg/debext"
+	"github.com/dionysius/aarg/internal/common"
+)
+
+// Policy rules
+const (
+	PolicyRuleMaxFileSize          = "max_file_size_mb"
+	PolicyRuleMaxPackagesPerSource = "max_packages_per_source"
+)
+
+// ErrPolicyViolation is returned if packages violate a repository policy with action fail
+var ErrPolicyViolation = errors.New("repository policy violated")
+
+// PolicyViolation describes packages violating a repository policy
+type PolicyViolation struct {
+	Distribution string `json:"distribution"` // Target distribution
+	Package      string `json:"package"`      // Package name, version and architecture, or source name for package counts
+	Rule         string `json:"rule"`         // Violated rule
+	Detail       string `json:"detail"`       // Human-readable detail
+}
+
+// applyPolicy checks kept packages against the repository policy
+// Returns the packages to add, violating packages are left out with action skip
+func (a *Apt) applyPolicy(kept []keptPackage) ([]keptPackage, error) {
+	policy := a.options.Repository.Policy
+	if !policy.IsEnabled() {
+		return kept, nil
+	}
+
+	// Packages are identified by their position in kept
+	violating := make(map[int]bool)
+
+	if policy.MaxFileSizeMB > 0 {
+		limit := policy.MaxFileSizeMB * 1024 * 1024
+		for i, item := range kept {
+			for _, file := range item.pkg.Files() {
+				if file.Checksums.Size <= limit {
+					continue
+				}
+				violating[i] = true
+				a.violations = append(a.violations, PolicyViolation{
+					Distribution: item.dist,
+					Package:      item.pkg.String(),
+					Rule:         PolicyRuleMaxFileSize,
+					Detail:       fmt.Sprintf("%s is %s, limit %d MiB", file.Filename, common.FormatSize(uint64(file.Checksums.Size)), policy.MaxFileSizeMB),
+				})
+			}
+		}
+	}
+
+	if policy.MaxPackagesPerSource > 0 {
+		type sourceKey struct{ dist, source string }
+		names := make(map[sourceKey]map[string]struct{})
+		members := make(map[sourceKey][]int)
+		var order []sourceKey
+
+		for i, item := range kept {
+			key := sourceKey{item.dist, debext.GetSourceNameFromPackage(item.pkg)}
+			if _, exists := members[key]; !exists {
+				order = append(order, key)
+				names[key] = make(map[string]struct{})
+			}
+			members[key] = append(members[key], i)
+			if !item.pkg.IsSource {
+				names[key][item.pkg.Name] = struct{}{}
+			}
+		}
+
+		for _, key := range order {
+			if len(names[key]) <= policy.MaxPackagesPerSource {
+				continue
+			}
+			for _, i := range members[key] {
+				violating[i] = true
+			}
+			a.violations = append(a.violations, PolicyViolation{
+				Distribution: key.dist,
+				Package:      key.source,
+				Rule:         PolicyRuleMaxPackagesPerSource,
+				Detail:       fmt.Sprintf("%d packages, limit %d", len(names[key]), policy.MaxPackagesPerSource),
+			})
+		}
+	}
+
+	for _, violation := range a.violations {
+		slog.Warn("Repository policy violated",
+			"repository", a.options.Name,
+			"distribution", violation.Distribution,
+			"package", violation.Package,
+			"rule", violation.Rule,
+			"detail", violation.Detail,
+			"action", policy.GetAction())
+	}
+
+	if len(a.violations) == 0 {
+		return kept, nil
+	}
+
+	switch policy.GetAction() {
+	case common.PolicyActionFail:
+		return nil, fmt.Errorf("%w: %d violations in %s", ErrPolicyViolation, len(a.violations), a.options.Name)
+	case common.PolicyActionSkip:
+		allowed := make([]keptPackage, 0, len(kept)-len(violating))
+		for i, item := range kept {
+			if !violating[i] {
+				allowed = append(allowed, item)
+			}
+		}
+		return allowed, nil
+	default:
+		return kept, nil
+	}
+}
+
+// Violations returns the packages that violated the repository policy during Compose
+func (a *Apt) Violations() []PolicyViolation {
+	return a.violations
+}
@@ -0,0 +1,175 @@
+package compose
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// HeadersFile is the file at the root of a build setting response headers on static hosts
+const HeadersFile = "_headers"
+
+// precompressMinSize is the size below which files are not worth precompressing
+const precompressMinSize = 1024
+
+// precompressTypes are the content types of generated files precompressed as siblings by extension
+// Repository indexes are left out, apt fetches them compressed already
+var precompressTypes = map[string]string{
+	".css":  "text/css; charset=utf-8",
+	".html": "text/html; charset=utf-8",
+	".js":   "text/javascript; charset=utf-8",
+	".json": "application/json",
+	".svg":  "image/svg+xml",
+	".txt":  "text/plain; charset=utf-8",
+}
+
+// PrecompressedType returns the content type of a generated file served precompressed, empty if never precompressed
+func PrecompressedType(name string) string {
+	return precompressTypes[filepath.Ext(name)]
+}
+
+// Encoding is an encoding of precompressed sibling files
+type Encoding struct {
+	Name            string // Name in the configuration
+	Extension       string // Suffix of the sibling file
+	ContentEncoding string // HTTP Content-Encoding of the sibling file
+}
+
+// Encodings are the supported precompression encodings, in order of preference when serving
+var Encodings = []Encoding{
+	{Name: "brotli", Extension: ".br", ContentEncoding: "br"},
+	{Name: "gzip", Extension: ".gz", ContentEncoding: "gzip"},
+}
+
+// IsPrecompressed reports whether a file name is a precompressed sibling of a generated file
+func IsPrecompressed(name string) bool {
+	for _, encoding := range Encodings {
+		if base, found := strings.CutSuffix(name, encoding.Extension); found && PrecompressedType(base) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Precompress writes precompressed siblings (e.g. index.html.br) of the generated web files of a build
+// and a _headers file declaring their content type and encoding for static hosts
+// Siblings not smaller than their file are left out, existing siblings are replaced
+func Precompress(buildPath string, names []string) error {
+	var encodings []Encoding
+	for _, encoding := range Encodings {
+		if slices.Contains(names, encoding.Name) {
+			encodings = append(encodings, encoding)
+		}
+	}
+	if len(encodings) == 0 {
+		return nil
+	}
+
+	err := filepath.WalkDir(buildPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() || PrecompressedType(path) == "" {
+			return err
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if info.Size() < precompressMinSize {
+			return nil
+		}
+
+		for _, encoding := range encodings {
+			if err := precompressFile(path, info, encoding); err != nil {
+				return fmt.Errorf("failed to precompress %s: %w", path, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return writeHeaders(buildPath, encodings)
+}
+
+// precompressFile writes the sibling of a file in the encoding, keeping its modification time
+func precompressFile(path string, info fs.FileInfo, encoding Encoding) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	siblingPath := path + encoding.Extension
+	out, err := os.CreateTemp(filepath.Dir(path), ".precompress-*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(out.Name()) }()
+
+	var writer io.WriteCloser
+	switch encoding.ContentEncoding {
+	case "br":
+		writer = brotli.NewWriterLevel(out, brotli.BestCompression)
+	default:
+		writer, _ = gzip.NewWriterLevel(out, gzip.BestCompression)
+	}
+
+	if _, err := writer.Write(data); err != nil {
+		_ = out.Close()
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		_ = out.Close()
+		return err
+	}
+
+	size, err := out.Seek(0, io.SeekCurrent)
+	if err != nil {
+		_ = out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+
+	// Compressing already small files may not pay off
+	if size >= info.Size() {
+		if err := os.Remove(siblingPath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	if err := os.Chmod(out.Name(), 0644); err != nil {
+		return err
+	}
+	if err := os.Chtimes(out.Name(), info.ModTime(), info.ModTime()); err != nil {
+		return err
+	}
+	return os.Rename(out.Name(), siblingPath)
+}
+
+// writeHeaders writes the _headers file declaring content type and encoding of precompressed siblings
+// Hosts serving siblings when requested directly then deliver them decodable by browsers
+func writeHeaders(buildPath string, encodings []Encoding) error {
+	var b strings.Builder
+	b.WriteString("# Generated by aarg: precompressed siblings of generated files\n")
+	for _, ext := range slices.Sorted(maps.Keys(precompressTypes)) {
+		for _, encoding := range encodings {
+			fmt.Fprintf(&b, "/*%s%s\n", ext, encoding.Extension)
+			fmt.Fprintf(&b, "  Content-Type: %s\n", precompressTypes[ext])
+			fmt.Fprintf(&b, "  Content-Encoding: %s\n", encoding.ContentEncoding)
+			b.WriteString("  Vary: Accept-Encoding\n")
+		}
+	}
+
+	return os.WriteFile(filepath.Join(buildPath, HeadersFile), []byte(b.String()), 0644)
+}
@@ -0,0 +1,134 @@
+package compose
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/dionysius/aarg/debext"
+	"github.com/dionysius/aarg/internal/common"
+)
+
+// TrustedFiles are the files of a repository in trusted storage split by its retention
+// Paths are relative to the trusted directory, source versions are keyed like .buildinfo filenames
+type TrustedFiles struct {
+	Retained        map[string]bool // Files of packages kept by retention
+	Dropped         map[string]bool // Files of packages dropped by retention
+	RetainedSources map[string]bool // Source versions of packages kept by retention
+	DroppedSources  map[string]bool // Source versions of packages dropped by retention
+}
+
+// RetentionDecision is a package version in trusted storage and whether the retention of a repository keeps it
+type RetentionDecision struct {
+	Distribution string
+	Component    string
+	Source       string
+	Package      string
+	Architecture string
+	Version      string
+	Kept         bool
+	Pinned       bool // Kept as pinned version
+}
+
+// CollectTrustedFiles applies the retention of a repository to its feeds in trusted storage without generating anything
+// A file shared by a retained and a dropped package, like an orig tarball, is listed in both
+func CollectTrustedFiles(ctx context.Context, deps Dependencies) (*TrustedFiles, error) {
+	composer, err := collectTrusted(ctx, deps)
+	if err != nil {
+		return nil, err
+	}
+
+	kept := make(map[*deb.Package]bool)
+	_ = composer.collector.ForEachKept(func(_, _, _, _ string, pkg *deb.Package) error {
+		kept[pkg] = true
+		return nil
+	})
+
+	files := &TrustedFiles{
+		Retained:        make(map[string]bool),
+		Dropped:         make(map[string]bool),
+		RetainedSources: make(map[string]bool),
+		DroppedSources:  make(map[string]bool),
+	}
+	composer.files.Range(func(key, value any) bool {
+		pkg := key.(*deb.Package)
+		paths, sources := files.Dropped, files.DroppedSources
+		if kept[pkg] {
+			paths, sources = files.Retained, files.RetainedSources
+		}
+		for _, path := range value.([]string) {
+			paths[path] = true
+		}
+		sources[buildinfoKey(pkg)] = true
+		return true
+	})
+
+	return files, nil
+}
+
+// PreviewRetention applies the retention of a repository to its feeds in trusted storage without generating anything
+// Decisions are sorted by source, package, architecture and distribution, newest version first
+func PreviewRetention(ctx context.Context, deps Dependencies) ([]RetentionDecision, error) {
+	composer, err := collectTrusted(ctx, deps)
+	if err != nil {
+		return nil, err
+	}
+
+	var decisions []RetentionDecision
+	_ = composer.collector.ForEachItem(func(dist, component, packageName, arch string, pkg *deb.Package, kept bool) error {
+		source := debext.GetSourceNameFromPackage(pkg)
+		decisions = append(decisions, RetentionDecision{
+			Distribution: dist,
+			Component:    component,
+			Source:       source,
+			Package:      packageName,
+			Architecture: arch,
+			Version:      pkg.Version,
+			Kept:         kept,
+			Pinned:       common.IsPinned(deps.Repository.Pin, source, pkg.Version),
+		})
+		return nil
+	})
+
+	slices.SortFunc(decisions, func(a, b RetentionDecision) int {
+		return cmp.Or(
+			strings.Compare(a.Source, b.Source),
+			strings.Compare(a.Package, b.Package),
+			strings.Compare(a.Architecture, b.Architecture),
+			strings.Compare(a.Distribution, b.Distribution),
+			strings.Compare(a.Component, b.Component),
+			deb.CompareVersions(b.Version, a.Version),
+		)
+	})
+	return decisions, nil
+}
+
+// collectTrusted collects the packages of a repository from trusted storage with its retention applied
+func collectTrusted(ctx context.Context, deps Dependencies) (*Apt, error) {
+	repo := deps.Repository
+
+	feeds, err := expandComposeFeeds(repo.Feeds)
+	if err != nil {
+		return nil, err
+	}
+
+	// The pool mode only affects generated files, redirects are not needed to find the trusted ones
+	options := &AptComposeOptions{
+		ComposeOptions: ComposeOptions{
+			Name:  repo.Name,
+			Feeds: feeds,
+		},
+		Repository:   &repo.RepositoryOptions,
+		Trusted:      deps.Config.Directories.GetTrustedPath(),
+		ControlStore: deps.ControlStore,
+	}
+
+	composer := NewApt(options, trustedVerifier(), nil, deps.DeCompressor, deps.Pool)
+	if err := composer.collect(ctx); err != nil {
+		return nil, fmt.Errorf("failed to collect packages of %s: %w", repo.Name, err)
+	}
+	return composer, nil
+}
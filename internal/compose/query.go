@@ -0,0 +1,89 @@
+package compose
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/dionysius/aarg/debext"
+)
+
+// QueryResult is a package matching a package query
+type QueryResult struct {
+	Distribution string `json:"distribution"`
+	Component    string `json:"component"`
+	Source       string `json:"source"`
+	Package      string `json:"package"`
+	Version      string `json:"version"`
+	Architecture string `json:"architecture"`
+}
+
+// QueryTrusted evaluates a package query against the packages of a repository in trusted storage
+// Retention is not applied, versions it would drop match as well
+func QueryTrusted(ctx context.Context, deps Dependencies, query deb.PackageQuery) ([]QueryResult, error) {
+	composer, err := collectTrusted(ctx, deps)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []QueryResult
+	_ = composer.collector.ForEachItem(func(dist, component, _, _ string, pkg *deb.Package, _ bool) error {
+		if query.Matches(pkg) {
+			results = append(results, newQueryResult(dist, component, pkg))
+		}
+		return nil
+	})
+
+	sortQueryResults(results)
+	return results, nil
+}
+
+// QueryRepository evaluates a package query against a repository as generated into a build
+func QueryRepository(buildPath, name string, query deb.PackageQuery) ([]QueryResult, error) {
+	repository, err := LoadRepositoryState(buildPath, name)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []QueryResult
+	for _, dist := range repository.GetDistributions() {
+		for _, comp := range repository.GetComponents(dist) {
+			_ = repository.GetPackageList(dist, comp).ForEach(func(pkg *deb.Package) error {
+				if query.Matches(pkg) {
+					results = append(results, newQueryResult(dist, comp, pkg))
+				}
+				return nil
+			})
+		}
+	}
+
+	sortQueryResults(results)
+	return results, nil
+}
+
+// newQueryResult describes a matching package
+func newQueryResult(dist, component string, pkg *deb.Package) QueryResult {
+	return QueryResult{
+		Distribution: dist,
+		Component:    component,
+		Source:       debext.GetSourceNameFromPackage(pkg),
+		Package:      pkg.Name,
+		Version:      pkg.Version,
+		Architecture: pkg.Architecture,
+	}
+}
+
+// sortQueryResults sorts by distribution, component, package and architecture, newest version first
+func sortQueryResults(results []QueryResult) {
+	slices.SortFunc(results, func(a, b QueryResult) int {
+		return cmp.Or(
+			strings.Compare(a.Distribution, b.Distribution),
+			strings.Compare(a.Component, b.Component),
+			strings.Compare(a.Package, b.Package),
+			strings.Compare(a.Architecture, b.Architecture),
+			deb.CompareVersions(b.Version, a.Version),
+		)
+	})
+}
@@ -0,0 +1,175 @@
+package compose
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+
+	"github.com/alitto/pond/v2"
+	"github.com/aptly-dev/aptly/pgp"
+	"github.com/dionysius/aarg/debext"
+	"github.com/dionysius/aarg/internal/common"
+	"github.com/dionysius/aarg/internal/config"
+	"github.com/google/go-github/v80/github"
+)
+
+// Built-in composer names
+const (
+	ComposerAPT      = "apt"
+	ComposerWeb      = "web"
+	ComposerMetadata = "metadata"
+	ComposerExports  = "exports"
+)
+
+// Dependencies are the runtime components available to composers
+type Dependencies struct {
+	Config          *config.Config           // Application configuration
+	Repository      *config.RepositoryConfig // Repository to compose, nil in Index
+	StagingPath     string                   // Root directory of the staging build
+	PreviousPath    string                   // Root directory of the build being replaced, empty if none
+	Incremental     bool                     // Reuse unchanged distributions of the previous build
+	Distributions   []string                 // Distributions to generate, the others are kept from the previous build, empty = all
+	VerifyPool      string                   // Checksum verification of trusted files linked into the pool
+	ConfigHash      string                   // Fingerprint of the effective configuration, see config.Config.Fingerprint
+	Signer          pgp.Signer               // Signer for Release files
+	DeCompressor    *common.DeCompressor     // Compressor for index files
+	Downloader      *common.Downloader       // Downloader for web assets
+	GitHubClient    *github.Client           // Shared GitHub API client
+	Pool            pond.Pool                // Coordination pool for parallel operations
+	PublicKeyASCII  []byte                   // ASCII-armored public signing key
+	PublicKeyBinary []byte                   // Binary (dearmored) public signing key
+	Sigstore        *debext.SigstoreSigner   // Signer of the sigstore bundles of Release files, nil if disabled
+	Metadata        common.MetadataStore     // Store of the redirect and signer maps of feeds, nil = map files in trusted storage
+	ControlStore    debext.ControlStore      // Reuses parsed control files of trusted packages, nil = parse every time
+	ContentsStore   debext.ContentsStore     // Reuses file lists of packages for Contents indexes, nil = extract every time
+}
+
+// Results carries the outputs of the composers of a repository to the composers depending on them
+type Results struct {
+	Apt        *Apt               // APT composer after composing, nil until "apt" ran
+	Repository *debext.Repository // Repository composed by "apt", nil until "apt" ran
+}
+
+// Registration describes a composer
+type Registration struct {
+	Name string
+
+	// DependsOn lists composers whose results are needed, they run first and are added if not configured
+	DependsOn []string
+	// Compose composes a single repository, nil if the composer only indexes
+	Compose func(ctx context.Context, deps Dependencies, results *Results) error
+	// Index runs once after all repositories are composed, nil if not needed
+	Index func(ctx context.Context, deps Dependencies) error
+}
+
+var (
+	registry   = make(map[string]Registration)
+	registryMu sync.RWMutex
+)
+
+// Register adds a composer to the registry, typically called from init
+// Panics if the name is already registered
+func Register(reg Registration) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if _, exists := registry[reg.Name]; exists {
+		panic(fmt.Sprintf("composer %q already registered", reg.Name))
+	}
+	registry[reg.Name] = reg
+}
+
+// Lookup returns the registration of a composer
+func Lookup(name string) (Registration, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	reg, ok := registry[name]
+	return reg, ok
+}
+
+// Names returns all registered composer names sorted by name
+func Names() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Resolve returns the registrations of the configured composers in dependency order
+// Dependencies missing from names are added, otherwise the configured order is kept
+// Unknown configured composers are skipped with a warning, unknown dependencies and cycles are errors
+func Resolve(names []string) ([]Registration, error) {
+	var ordered []Registration
+	state := make(map[string]int) // 1 = visiting, 2 = done
+
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch state[name] {
+		case 1:
+			return fmt.Errorf("composer dependency cycle: %v", append(path, name))
+		case 2:
+			return nil
+		}
+
+		reg, ok := Lookup(name)
+		if !ok && len(path) == 0 {
+			slog.Warn("Unknown composer", "name", name, "valid", Names())
+			state[name] = 2
+			return nil
+		}
+		if !ok {
+			return fmt.Errorf("unknown composer %q, valid composers: %v", name, Names())
+		}
+
+		state[name] = 1
+		for _, dep := range reg.DependsOn {
+			if err := visit(dep, append(path, name)); err != nil {
+				return err
+			}
+		}
+		state[name] = 2
+
+		ordered = append(ordered, reg)
+		return nil
+	}
+
+	for _, name := range names {
+		if err := visit(name, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	return ordered, nil
+}
+
+func init() {
+	Register(Registration{
+		Name:    ComposerAPT,
+		Compose: composeAPT,
+		Index:   indexAPT,
+	})
+	Register(Registration{
+		Name:      ComposerWeb,
+		DependsOn: []string{ComposerAPT},
+		Compose:   composeWeb,
+		Index:     indexWeb,
+	})
+	Register(Registration{
+		Name:      ComposerMetadata,
+		DependsOn: []string{ComposerAPT},
+		Compose:   composeMetadata,
+	})
+	Register(Registration{
+		Name:      ComposerExports,
+		DependsOn: []string{ComposerAPT},
+		Index:     indexExports,
+	})
+}
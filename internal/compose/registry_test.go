@@ -0,0 +1,75 @@
+package compose
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// registerTest registers composers for the duration of the test
+func registerTest(t *testing.T, regs ...Registration) {
+	t.Helper()
+	for _, reg := range regs {
+		Register(reg)
+	}
+	t.Cleanup(func() {
+		registryMu.Lock()
+		defer registryMu.Unlock()
+		for _, reg := range regs {
+			delete(registry, reg.Name)
+		}
+	})
+}
+
+func TestResolve(t *testing.T) {
+	registerTest(t,
+		Registration{Name: "test-base"},
+		Registration{Name: "test-middle", DependsOn: []string{"test-base"}},
+		Registration{Name: "test-top", DependsOn: []string{"test-middle", "test-base"}},
+		Registration{Name: "test-cycle-a", DependsOn: []string{"test-cycle-b"}},
+		Registration{Name: "test-cycle-b", DependsOn: []string{"test-cycle-a"}},
+		Registration{Name: "test-self", DependsOn: []string{"test-self"}},
+		Registration{Name: "test-broken", DependsOn: []string{"test-missing"}},
+	)
+
+	tests := []struct {
+		name      string
+		names     []string
+		want      []string
+		errSubstr string
+	}{
+		{name: "configured order kept", names: []string{ComposerAPT, ComposerMetadata, ComposerWeb}, want: []string{ComposerAPT, ComposerMetadata, ComposerWeb}},
+		{name: "missing dependency added first", names: []string{ComposerWeb}, want: []string{ComposerAPT, ComposerWeb}},
+		{name: "dependency configured later moves first", names: []string{ComposerWeb, ComposerAPT}, want: []string{ComposerAPT, ComposerWeb}},
+		{name: "duplicates once", names: []string{ComposerAPT, ComposerAPT}, want: []string{ComposerAPT}},
+		{name: "transitive dependencies", names: []string{"test-top"}, want: []string{"test-base", "test-middle", "test-top"}},
+		{name: "unknown composer skipped", names: []string{"unknown", ComposerAPT}, want: []string{ComposerAPT}},
+		{name: "nothing configured", names: nil, want: nil},
+		{name: "cycle", names: []string{"test-cycle-a"}, errSubstr: "composer dependency cycle: [test-cycle-a test-cycle-b test-cycle-a]"},
+		{name: "self dependency", names: []string{"test-self"}, errSubstr: "composer dependency cycle: [test-self test-self]"},
+		{name: "unknown dependency", names: []string{"test-broken"}, errSubstr: `unknown composer "test-missing"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			regs, err := Resolve(tt.names)
+			if tt.errSubstr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.errSubstr)
+				return
+			}
+			require.NoError(t, err)
+
+			var got []string
+			for _, reg := range regs {
+				got = append(got, reg.Name)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRegister_Duplicate(t *testing.T) {
+	assert.Panics(t, func() { Register(Registration{Name: ComposerAPT}) })
+}
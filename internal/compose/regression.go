@@ -0,0 +1,64 @@
+package compose
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/dionysius/aarg/debext"
+	"github.com/dionysius/aarg/internal/common"
+)
+
+// ErrVersionRegression is returned if the latest version of a package went backwards and regressions fail
+var ErrVersionRegression = errors.New("package version lower than in the previous build")
+
+// VersionRegression describes a package whose latest version is lower than in the previous build
+// This usually means a feed was misconfigured or upstream re-tagged a release
+type VersionRegression struct {
+	Distribution string `json:"distribution"` // Target distribution
+	Architecture string `json:"architecture"` // Architecture, "source" for source packages
+	Package      string `json:"package"`      // Package name
+	Version      string `json:"version"`      // Latest version of this build
+	Previous     string `json:"previous"`     // Latest version of the previous build
+}
+
+// checkRegressions compares the latest versions of the composed repository with the previous build
+// Packages missing from this build are removals and not reported
+// Returns ErrVersionRegression with regression action fail
+func (a *Apt) checkRegressions(previous, current *debext.Repository) error {
+	previous.ForEachLatest(func(dist, arch string, prevPkg *deb.Package) {
+		pkg := current.GetLatest(prevPkg.Name, dist, arch)
+		if pkg == nil || deb.CompareVersions(pkg.Version, prevPkg.Version) >= 0 {
+			return
+		}
+
+		regression := VersionRegression{
+			Distribution: dist,
+			Architecture: arch,
+			Package:      prevPkg.Name,
+			Version:      pkg.Version,
+			Previous:     prevPkg.Version,
+		}
+		a.regressions = append(a.regressions, regression)
+
+		slog.Warn("Package version lower than in the previous build",
+			"repository", a.options.Name,
+			"distribution", regression.Distribution,
+			"architecture", regression.Architecture,
+			"package", regression.Package,
+			"version", regression.Version,
+			"previous", regression.Previous,
+			"action", a.options.Repository.Policy.GetRegressions())
+	})
+
+	if len(a.regressions) > 0 && a.options.Repository.Policy.GetRegressions() == common.PolicyActionFail {
+		return fmt.Errorf("%w: %d packages in %s", ErrVersionRegression, len(a.regressions), a.options.Name)
+	}
+	return nil
+}
+
+// Regressions returns the packages whose latest version is lower than in the previous build
+func (a *Apt) Regressions() []VersionRegression {
+	return a.regressions
+}
@@ -0,0 +1,153 @@
+package compose
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/dionysius/aarg/internal/common"
+)
+
+// SnapshotsDir is the directory of a repository holding its snapshots
+const SnapshotsDir = "snapshots"
+
+// snapshotTimeFormat names snapshots like snapshot.debian.org, in UTC so names sort by time
+const snapshotTimeFormat = "20060102T150405Z"
+
+// Snapshot is an immutable point-in-time state of a repository
+// Its directory holds dists and pool like the repository itself, so it's used as URI of apt sources
+type Snapshot struct {
+	Name          string    // Directory name, the time the snapshot was taken
+	Time          time.Time // Time the snapshot was taken in the display time zone
+	Distributions []string
+}
+
+// ListSnapshots returns the snapshots of a repository, newest first
+func ListSnapshots(repoPath string) []Snapshot {
+	snapshotsPath := filepath.Join(repoPath, SnapshotsDir)
+
+	var snapshots []Snapshot
+	for _, name := range snapshotNames(snapshotsPath) {
+		t, _ := time.Parse(snapshotTimeFormat, name)
+		snapshot := Snapshot{Name: name, Time: common.InDisplayLocation(t)}
+
+		entries, _ := os.ReadDir(filepath.Join(snapshotsPath, name, "dists"))
+		for _, entry := range entries {
+			if entry.IsDir() {
+				snapshot.Distributions = append(snapshot.Distributions, entry.Name())
+			}
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	return snapshots
+}
+
+// snapshotNames returns the names of the snapshots in snapshotsPath, newest first
+func snapshotNames(snapshotsPath string) []string {
+	entries, err := os.ReadDir(snapshotsPath)
+	if err != nil {
+		return nil
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if _, err := time.Parse(snapshotTimeFormat, entry.Name()); err == nil && entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+	slices.Sort(names)
+	slices.Reverse(names)
+	return names
+}
+
+// composeSnapshots carries the snapshots of the previous build over and takes a new one if the package indexes changed
+// Snapshots are hardlinked, the pool files of a snapshot stay available after retention dropped them from the repository
+func (a *Apt) composeSnapshots() error {
+	keep := a.options.Repository.Snapshots.Keep
+	snapshotsPath := filepath.Join(a.options.Target, SnapshotsDir)
+	if err := os.RemoveAll(snapshotsPath); err != nil {
+		return err
+	}
+
+	var previousPath string
+	var names []string
+	if a.options.Previous != "" {
+		previousPath = filepath.Join(a.options.Previous, a.options.Name, SnapshotsDir)
+		names = snapshotNames(previousPath)
+	}
+
+	// Unchanged indexes since the newest snapshot don't need another one, neither does a repository without any
+	name := time.Now().UTC().Format(snapshotTimeFormat)
+	digest, err := indexDigest(filepath.Join(a.options.Target, "dists"))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	take := err == nil
+	if take && len(names) > 0 {
+		previous, err := indexDigest(filepath.Join(previousPath, names[0], "dists"))
+		take = (err != nil || previous != digest) && names[0] != name
+	}
+
+	if take {
+		names = names[:min(len(names), keep-1)]
+	} else {
+		names = names[:min(len(names), keep)]
+	}
+	for _, carried := range names {
+		if err := linkTree(filepath.Join(previousPath, carried), filepath.Join(snapshotsPath, carried), nil); err != nil {
+			return fmt.Errorf("failed to carry over snapshot %s: %w", carried, err)
+		}
+	}
+
+	if !take {
+		return nil
+	}
+
+	for _, dir := range []string{"dists", "pool"} {
+		src := filepath.Join(a.options.Target, dir)
+		if _, err := os.Stat(src); err != nil {
+			continue
+		}
+		if err := linkTree(src, filepath.Join(snapshotsPath, name, dir), skipIndexes); err != nil {
+			return fmt.Errorf("failed to take snapshot %s: %w", name, err)
+		}
+	}
+
+	slog.Info("Snapshot taken", "repository", a.options.Name, "snapshot", name, "kept", len(names)+1)
+	return nil
+}
+
+// indexDigest hashes the index entries of the Release files of all distributions in distsPath
+// Release dates and fields are left out, a refreshed Release file alone doesn't make a new snapshot
+func indexDigest(distsPath string) (string, error) {
+	releases, err := filepath.Glob(filepath.Join(distsPath, "*", "Release"))
+	if err != nil {
+		return "", err
+	}
+	if len(releases) == 0 {
+		return "", os.ErrNotExist
+	}
+
+	hasher := sha256.New()
+	for _, release := range releases {
+		data, err := os.ReadFile(release)
+		if err != nil {
+			return "", err
+		}
+		_, _ = fmt.Fprintf(hasher, "dist %s\n", filepath.Base(filepath.Dir(release)))
+		for line := range strings.Lines(string(data)) {
+			// Index entries are the continuation lines of the checksum fields
+			if strings.HasPrefix(line, " ") {
+				_, _ = hasher.Write([]byte(line))
+			}
+		}
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
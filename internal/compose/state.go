@@ -0,0 +1,35 @@
+package compose
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/dionysius/aarg/debext"
+)
+
+// RepositoryStateFile stores the composed repository in every repository of a build
+// Later commands read it instead of re-parsing the trusted tree
+const RepositoryStateFile = ".repository.gz"
+
+// SaveRepositoryState writes the composed repository to the repository directory of a build
+func SaveRepositoryState(buildPath, name string, repository *debext.Repository) error {
+	path := filepath.Join(buildPath, name, RepositoryStateFile)
+	// A repository without packages has no indexes that created its directory
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to save repository state: %w", err)
+	}
+	if err := debext.SaveRepositoryFile(path, repository); err != nil {
+		return fmt.Errorf("failed to save repository state: %w", err)
+	}
+	return nil
+}
+
+// LoadRepositoryState reads the composed repository of a build
+func LoadRepositoryState(buildPath, name string) (*debext.Repository, error) {
+	repository, err := debext.LoadRepositoryFile(filepath.Join(buildPath, name, RepositoryStateFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load repository state of %s: %w", name, err)
+	}
+	return repository, nil
+}
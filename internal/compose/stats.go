@@ -0,0 +1,43 @@
+package compose
+
+import (
+	"html/template"
+	"io"
+
+	"github.com/dionysius/aarg/internal/common"
+)
+
+// StatsPath is the URL path of the download statistics page rendered in serve mode
+const StatsPath = "/stats/"
+
+// StatsData contains data for the download statistics page
+type StatsData struct {
+	Packages   []common.PackageAccess
+	Days       int // Number of days the counts cover
+	AssetsPath string
+	PageTitle  string
+}
+
+// RenderStats writes the download statistics page to w
+// The page is not part of a build, it's rendered on request from the counts of the running server
+func RenderStats(w io.Writer, packages []common.PackageAccess, days int) error {
+	tmpl, err := parseTemplates()
+	if err != nil {
+		return err
+	}
+	generator := Generator{Version: common.CurrentBuild().String()}
+	tmpl.Funcs(template.FuncMap{
+		"generator": func() Generator { return generator },
+	})
+	if _, err := tmpl.ParseFS(templatesFS, "templates/nav.html", "templates/stats.html"); err != nil {
+		return err
+	}
+
+	data := StatsData{
+		Packages:   packages,
+		Days:       days,
+		AssetsPath: "../",
+		PageTitle:  "APT Repositories",
+	}
+	return tmpl.ExecuteTemplate(w, "base.html", data)
+}
@@ -1,15 +1,27 @@
 #!/bin/bash
 # Automated installer for {{.RepoName}} APT repository
+{{- with .Links.Homepage}}
+# Homepage: {{.}}{{end}}
+{{- with .Links.Documentation}}
+# Documentation: {{.}}{{end}}
+{{- with .Links.Support}}
+# Support: {{.}}{{end}}
+{{- with .Links.Bugs}}
+# Bugs: {{.}}{{end}}
 
 set -euo pipefail
 
 # Available distributions for this repository
 AVAILABLE_DISTRIBUTIONS=({{range .Distributions}}"{{.}}" {{end}})
 
+# Available package components, all are configured unless --components selects some
+AVAILABLE_COMPONENTS=({{range .Components}}"{{.}}" {{else}}"main" {{end}})
+
 # Parse arguments
 INCLUDE_DEBUG=false
 INCLUDE_SOURCE=false
 VERSION_CODENAME=""
+SELECTED_COMPONENTS=""
 
 while [[ $# -gt 0 ]]; do
     case $1 in
@@ -24,6 +36,15 @@ while [[ $# -gt 0 ]]; do
                 exit 1
             fi
             ;;
+        --components)
+            if [[ -n "$2" && "$2" != --* ]]; then
+                SELECTED_COMPONENTS="${2//,/ }"
+                shift 2
+            else
+                echo "Error: --components requires a comma separated list of components"
+                exit 1
+            fi
+            ;;
         *) echo "Unknown option: $1"; exit 1 ;;
     esac
 done
@@ -51,6 +72,16 @@ if [[ ! " ${AVAILABLE_DISTRIBUTIONS[@]} " =~ " ${VERSION_CODENAME} " ]]; then
     exit 1
 fi
 
+# Check if selected components are available
+for component in $SELECTED_COMPONENTS; do
+    if [[ ! " ${AVAILABLE_COMPONENTS[@]} " =~ " ${component} " ]]; then
+        echo "Error: Component '$component' is not available in this repository"
+        echo ""
+        echo "Available components: ${AVAILABLE_COMPONENTS[*]}"
+        exit 1
+    fi
+done
+
 # Check for required dependencies
 MISSING_DEPS=()
 if ! command -v curl &> /dev/null; then
@@ -80,16 +111,21 @@ SOURCES_FILE="/etc/apt/sources.list.d/{{.RepoName}}.sources"
 echo "Creating $SOURCES_FILE..."
 
 # Build components list
-COMPONENTS="main"
+COMPONENTS="${SELECTED_COMPONENTS:-${AVAILABLE_COMPONENTS[*]}}"
 if [ "$INCLUDE_DEBUG" = true ]; then
     COMPONENTS="$COMPONENTS debug"
 fi
 
 # Build types list
+{{- if .SourceOnly}}
+# The repository only publishes source packages
+TYPES="deb-src"
+{{- else}}
 TYPES="deb"
 if [ "$INCLUDE_SOURCE" = true ]; then
     TYPES="$TYPES deb-src"
 fi
+{{- end}}
 
 sudo tee "$SOURCES_FILE" > /dev/null <<EOF
 Types: $TYPES
@@ -97,10 +133,17 @@ URIs: {{.BaseURL}}/{{.RepoName}}
 Suites: $VERSION_CODENAME
 Components: $COMPONENTS
 Signed-By: $SIGNED_BY
+{{- if .ValidUntilMax}}
+Valid-Until-Max: {{.ValidUntilMax}}
+{{- end}}
 EOF
 
 # Update package lists
 echo "Updating package lists..."
 sudo apt-get update
 
+{{- if .SourceOnly}}
+echo "Successfully installed {{.RepoName}} repository! You can now download sources using: apt source <package-name>"
+{{- else}}
 echo "Successfully installed {{.RepoName}} repository! You can now install packages using: apt install <package-name>"
+{{- end}}
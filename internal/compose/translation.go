@@ -0,0 +1,48 @@
+package compose
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/aptly-dev/aptly/utils"
+	"github.com/dionysius/aarg/debext"
+)
+
+// generateTranslationIndex writes the uncompressed i18n/Translation-en index of a component with the
+// descriptions of its binary packages, which are reduced to their synopsis in the Packages indexes
+// Returns the index path relative to the distribution directory, or an empty path if there are no descriptions
+func (a *Apt) generateTranslationIndex(repo *debext.Repository, dist, comp string) (string, utils.ChecksumInfo, error) {
+	pkgList := repo.GetPackageList(dist, comp)
+	if pkgList == nil {
+		return "", utils.ChecksumInfo{}, nil
+	}
+
+	relPath := comp + "/i18n/Translation-" + debext.TranslationLanguage
+	targetPath := filepath.Join(a.options.Target, "dists", dist, relPath)
+	if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
+		return "", utils.ChecksumInfo{}, err
+	}
+
+	f, err := os.Create(targetPath)
+	if err != nil {
+		return "", utils.ChecksumInfo{}, err
+	}
+	count, err := debext.GenerateTranslationIndex(f, pkgList)
+	if err != nil {
+		_ = f.Close()
+		return "", utils.ChecksumInfo{}, err
+	}
+	if err := f.Close(); err != nil {
+		return "", utils.ChecksumInfo{}, err
+	}
+
+	if count == 0 {
+		return "", utils.ChecksumInfo{}, os.RemoveAll(filepath.Dir(targetPath))
+	}
+
+	checksums, err := utils.ChecksumsForFile(targetPath)
+	if err != nil {
+		return "", utils.ChecksumInfo{}, err
+	}
+	return relPath, checksums, nil
+}
@@ -1,7 +1,11 @@
 package compose
 
 import (
+	"time"
+
+	"github.com/dionysius/aarg/debext"
 	"github.com/dionysius/aarg/internal/common"
+	"github.com/dionysius/aarg/internal/config"
 	"github.com/dionysius/aarg/internal/feed"
 	"github.com/google/go-github/v80/github"
 )
@@ -30,6 +34,54 @@ type AptComposeOptions struct {
 
 	// PoolMode is the pool organization mode: "hierarchical" or "redirect"
 	PoolMode string
+
+	// Incremental reuses the distributions of the previous build whose fingerprint is unchanged
+	Incremental bool
+
+	// Previous is the root of the previous build, empty = none
+	Previous string
+
+	// Distributions limits generation to these distributions, the others are kept from the previous build, empty = all
+	Distributions []string
+
+	// Settings identifies the build settings affecting all distributions, part of their fingerprints
+	Settings string
+
+	// ReleaseFields are custom fields added to every Release file besides the repository links
+	ReleaseFields map[string]string
+
+	// RefreshAfter is the age of the Release file after which a distribution is regenerated anyway, 0 = never
+	RefreshAfter time.Duration
+
+	// PDiffHistory is the number of patches kept per index for apt's PDiffs, 0 = none
+	PDiffHistory int
+
+	// Contents generates Contents-<arch> indexes per component
+	Contents bool
+
+	// Translations splits the long descriptions off the Packages indexes into a Translation-en index per component
+	Translations bool
+
+	// DebuginfoCache is the directory the debug files of debug packages are extracted to once for debuginfod
+	DebuginfoCache string
+
+	// VerifyPool re-checks the checksums of trusted files linked into the pool: VerifyPoolSample, VerifyPoolFull or off
+	VerifyPool string
+
+	// VerifySample is the percentage of trusted files checked with VerifyPoolSample
+	VerifySample int
+
+	// Sigstore writes a sigstore bundle next to every Release file, nil = none
+	Sigstore *debext.SigstoreSigner
+
+	// Metadata holds the redirect and signer maps of the feeds, nil = map files in trusted storage
+	Metadata common.MetadataStore
+
+	// ControlStore reuses parsed control files of trusted packages, nil = parse every time
+	ControlStore debext.ControlStore
+
+	// ContentsStore reuses the file lists of packages for Contents indexes, nil = extract every time
+	ContentsStore debext.ContentsStore
 }
 
 // WebComposeOptions contains configuration for web page generation
@@ -39,6 +91,9 @@ type WebComposeOptions struct {
 	// Description is a markdown-formatted description of the repository
 	Description string
 
+	// Owners maintain the repository (emails or handles)
+	Owners []string
+
 	// Repository contains the repository configuration
 	Repository *common.RepositoryOptions
 
@@ -62,4 +117,28 @@ type WebComposeOptions struct {
 
 	// RepositoryConfig is the original repository configuration (used for YAML export)
 	RepositoryConfig any
+
+	// PreviousTarget is the root of the build being replaced, its layout is kept (empty = none)
+	PreviousTarget string
+
+	// Repositories are the configured repositories, used by the index page for categories and summaries
+	Repositories []*config.RepositoryConfig
+
+	// Categories is the order of repository categories on the index page
+	Categories []string
+
+	// StagingBase is the directory of the staging builds, earlier builds are snapshotted from there
+	StagingBase string
+
+	// PreviousBuilds is the number of earlier builds published as snapshots under builds/, 0 = none
+	PreviousBuilds int
+
+	// MaxAgeHours is the age after which pages show a staleness banner and apt refuses the indexes, 0 = never
+	MaxAgeHours int
+
+	// ConfigHash is the fingerprint of the configuration shown in the page footer, empty = hidden
+	ConfigHash string
+
+	// Provenance maps the paths of the package files to their provenance, shown on the source pages
+	Provenance map[string]ProvenanceEntry
 }
@@ -0,0 +1,112 @@
+package compose
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"path/filepath"
+
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/aptly-dev/aptly/utils"
+)
+
+// ErrPoolChecksumMismatch is returned if a file in trusted storage no longer matches the checksums of its package index
+var ErrPoolChecksumMismatch = errors.New("trusted file does not match its recorded checksum")
+
+// Pool verification modes of AptComposeOptions.VerifyPool
+const (
+	VerifyPoolNone   = "none"
+	VerifyPoolSample = "sample"
+	VerifyPoolFull   = "full"
+)
+
+// loadPoolChecksums returns the checksums of the files of a repository in a build by their path relative to TrustedDir
+// Returns nil if the build has no state of the repository
+func loadPoolChecksums(buildPath, name string) map[string]utils.ChecksumInfo {
+	if buildPath == "" {
+		return nil
+	}
+	repository, err := LoadRepositoryState(buildPath, name)
+	if err != nil {
+		return nil
+	}
+
+	checksums := make(map[string]utils.ChecksumInfo)
+	for _, dist := range repository.GetDistributions() {
+		for _, comp := range repository.GetComponents(dist) {
+			_ = repository.GetPackageList(dist, comp).ForEach(func(pkg *deb.Package) error {
+				dir := filepath.Dir(pkg.Stanza()["Filename"])
+				if pkg.IsSource {
+					dir = pkg.Stanza()["Directory"]
+				}
+				for _, file := range pkg.Files() {
+					checksums[filepath.Join(dir, file.Filename)] = file.Checksums
+				}
+				return nil
+			})
+		}
+	}
+	return checksums
+}
+
+// verifyPoolFile checks a trusted file at relPath relative to TrustedDir before it is linked into the build
+// Files of package indexes are checked against the index, files parsed from trusted storage itself against
+// the checksums the previous build published for the same file, which catches changes since then
+// Every file is decided on once, also if it is linked into several distributions
+func (a *Apt) verifyPoolFile(path, relPath string, expected utils.ChecksumInfo) error {
+	switch a.options.VerifyPool {
+	case VerifyPoolFull:
+	case VerifyPoolSample:
+		if rand.IntN(100) >= a.options.VerifySample {
+			return nil
+		}
+	default:
+		return nil
+	}
+	if _, seen := a.checked.LoadOrStore(path, struct{}{}); seen {
+		return nil
+	}
+
+	actual, err := utils.ChecksumsForFile(path)
+	if err != nil {
+		return err
+	}
+	if err := compareChecksums(expected, actual); err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrPoolChecksumMismatch, path, err)
+	}
+	if previous, ok := a.previousChecksums[relPath]; ok {
+		if err := compareChecksums(previous, actual); err != nil {
+			return fmt.Errorf("%w: %s changed since the previous build: %w", ErrPoolChecksumMismatch, path, err)
+		}
+	}
+	a.verified.Add(1)
+	return nil
+}
+
+// compareChecksums compares the size and strongest checksum recorded in expected
+func compareChecksums(expected, actual utils.ChecksumInfo) error {
+	if expected.Size != actual.Size {
+		return fmt.Errorf("size %d, expected %d", actual.Size, expected.Size)
+	}
+
+	for _, sum := range []struct{ name, expected, actual string }{
+		{"SHA512", expected.SHA512, actual.SHA512},
+		{"SHA256", expected.SHA256, actual.SHA256},
+		{"SHA1", expected.SHA1, actual.SHA1},
+		{"MD5", expected.MD5, actual.MD5},
+	} {
+		if sum.expected == "" {
+			continue
+		}
+		if sum.expected != sum.actual {
+			return fmt.Errorf("%s %s, expected %s", sum.name, sum.actual, sum.expected)
+		}
+		return nil
+	}
+	return errors.New("no checksum recorded")
+}
+
+// Verified returns the number of trusted files whose checksums were verified while linking
+func (a *Apt) Verified() int64 {
+	return a.verified.Load()
+}
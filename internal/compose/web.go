@@ -6,11 +6,16 @@ import (
 	"embed"
 	"fmt"
 	"html/template"
+	"log/slog"
 	"maps"
+	"net/url"
 	"os"
 	"path/filepath"
 	"slices"
 	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/Masterminds/sprig/v3"
 	"github.com/aptly-dev/aptly/deb"
@@ -18,6 +23,7 @@ import (
 	"github.com/dionysius/aarg/internal/common"
 	"github.com/dionysius/aarg/internal/config"
 	"github.com/dionysius/aarg/internal/feed"
+	"github.com/dionysius/aarg/internal/telemetry"
 	"github.com/google/go-github/v80/github"
 	"github.com/yuin/goldmark"
 	"github.com/yuin/goldmark/extension"
@@ -38,11 +44,12 @@ var defaultIconURLs = map[string]string{
 	"github": "https://cdn.simpleicons.org/github",
 	"apt":    "https://cdn.simpleicons.org/debian",
 	"obs":    "https://cdn.simpleicons.org/opensuse",
+	"ppa":    "https://cdn.simpleicons.org/launchpad",
 }
 
 // getNewestUpstreamVersion finds the newest upstream version for a package across specified distributions and architectures
 // This is a generalized version that works for all table types based on configuration
-func getNewestUpstreamVersion(repo *debext.Repository, packageName string, distributions []string, component string, archMode string) string {
+func getNewestUpstreamVersion(repo *debext.Repository, packageName string, distributions []string, components []string, archMode string) string {
 	var newest string
 
 	for _, dist := range distributions {
@@ -56,7 +63,7 @@ func getNewestUpstreamVersion(repo *debext.Repository, packageName string, distr
 			}
 		} else {
 			// Multi-arch mode: check all architectures
-			for _, arch := range repo.GetArchitectures(dist, component, false) {
+			for _, arch := range componentArchitectures(repo, dist, components) {
 				if pkg := repo.GetLatest(packageName, dist, arch); pkg != nil {
 					upstream := debext.ParseVersion(pkg.Version).Upstream
 					if newest == "" || deb.CompareVersions(upstream, newest) > 0 {
@@ -70,6 +77,28 @@ func getNewestUpstreamVersion(repo *debext.Repository, packageName string, distr
 	return newest
 }
 
+// componentArchitectures returns the architectures of the given components of a distribution
+func componentArchitectures(repo *debext.Repository, dist string, components []string) []string {
+	archSet := make(map[string]bool)
+	for _, component := range components {
+		for _, arch := range repo.GetArchitectures(dist, component, false) {
+			archSet[arch] = true
+		}
+	}
+	return slices.Sorted(maps.Keys(archSet))
+}
+
+// componentPackageNames returns the package names of the given components across all distributions
+func componentPackageNames(repo *debext.Repository, components []string) []string {
+	nameSet := make(map[string]bool)
+	for _, component := range components {
+		for _, name := range repo.GetPackageNames(component) {
+			nameSet[name] = true
+		}
+	}
+	return slices.Sorted(maps.Keys(nameSet))
+}
+
 // findPrimaryPackage determines the primary package name using the following order:
 // 1. Explicitly provided primary package name
 // 2. Repository name itself
@@ -80,7 +109,7 @@ func findPrimaryPackage(repo *debext.Repository, repoName, explicitPrimary strin
 		return explicitPrimary
 	}
 
-	allPackages := repo.GetPackageNames(common.MainComponent)
+	allPackages := componentPackageNames(repo, PackageComponents(repo))
 
 	// Check if repository name exists as a package
 	if slices.Contains(allPackages, repoName) {
@@ -180,7 +209,10 @@ type PackageTableConfig struct {
 	ID               string   // HTML element ID
 	Distributions    []string // List of distributions to display
 	ArchitectureMode string   // "multi" for multiple architectures, "source" for source only
-	Component        string   // Component name
+	Components       []string // Component names
+	ShowComponent    bool     // Whether rows name the component of their package
+
+	Pins []common.PinnedVersion // Versions retention never removes, shown with a pin badge
 }
 
 // TableHeaderColumn represents a column in the table header
@@ -194,49 +226,59 @@ type TableCell struct {
 	Version      string // Full version string
 	ShortVersion string // Version without distribution suffix
 	IsNewest     bool   // Whether this is the newest version
+	IsPinned     bool   // Whether the version is pinned and never removed by retention
 	HasPackage   bool   // Whether a package exists for this cell
 }
 
 // TableRow represents a row in the table body
 type TableRow struct {
 	PackageName string
+	Component   string // Component of the package, empty if not shown
 	Cells       []TableCell
 }
 
 // PreparedPackageTable contains all the pre-computed data for rendering a table
 type PreparedPackageTable struct {
-	ID           string
-	DistHeaders  []TableHeaderColumn // First header row (distributions)
-	ArchHeaders  []TableHeaderColumn // Second header row (architectures), empty for source mode
-	Rows         []TableRow
-	HasArchRow   bool   // Whether to show the architecture row
-	IsEmpty      bool   // Whether the table has no packages
-	EmptyMessage string // Message to show when empty
+	ID            string
+	DistHeaders   []TableHeaderColumn // First header row (distributions)
+	ArchHeaders   []TableHeaderColumn // Second header row (architectures), empty for source mode
+	Architectures []string            // Architecture columns of each distribution, empty for source mode
+	Rows          []TableRow
+	HasArchRow    bool   // Whether to show the architecture row
+	IsEmpty       bool   // Whether the table has no packages
+	EmptyMessage  string // Message to show when empty
 }
 
 // getPackageTableConfig returns the configuration for a specific table type
-func getPackageTableConfig(tableType string, repo *debext.Repository, repoName string, primaryPackage string) PackageTableConfig {
+// Distributions known from the previous layout keep their order
+func getPackageTableConfig(tableType string, repo *debext.Repository, repoName string, primaryPackage string, previous *Layout) PackageTableConfig {
 	allDists := repo.GetDistributions()
-	sortedDists := sortDistributionsByPrimaryPackage(repo, allDists, repoName, primaryPackage)
+	sortedDists := stickyOrder(previous.distributions(), sortDistributionsByPrimaryPackage(repo, allDists, repoName, primaryPackage))
+
+	// Packages of repositories with a component per feed are labeled with their component
+	components := PackageComponents(repo)
+	showComponent := !slices.Equal(components, []string{common.MainComponent})
 
 	configs := map[string]PackageTableConfig{
 		"packages": {
 			ID:               "packages",
 			Distributions:    sortedDists,
 			ArchitectureMode: "multi",
-			Component:        common.MainComponent,
+			Components:       components,
+			ShowComponent:    showComponent,
 		},
 		"debug": {
 			ID:               "debug",
 			Distributions:    sortedDists,
 			ArchitectureMode: "multi",
-			Component:        common.DebugComponent,
+			Components:       []string{common.DebugComponent},
 		},
 		"sources": {
 			ID:               "sources",
 			Distributions:    sortedDists,
 			ArchitectureMode: "source",
-			Component:        common.MainComponent,
+			Components:       components,
+			ShowComponent:    showComponent,
 		},
 	}
 
@@ -244,16 +286,17 @@ func getPackageTableConfig(tableType string, repo *debext.Repository, repoName s
 }
 
 // getTableConfigs returns all table configurations
-func getTableConfigs(repo *debext.Repository, repoName string, primaryPackage string) []PackageTableConfig {
+func getTableConfigs(repo *debext.Repository, repoName string, primaryPackage string, previous *Layout) []PackageTableConfig {
 	return []PackageTableConfig{
-		getPackageTableConfig("packages", repo, repoName, primaryPackage),
-		getPackageTableConfig("debug", repo, repoName, primaryPackage),
-		getPackageTableConfig("sources", repo, repoName, primaryPackage),
+		getPackageTableConfig("packages", repo, repoName, primaryPackage, previous),
+		getPackageTableConfig("debug", repo, repoName, primaryPackage, previous),
+		getPackageTableConfig("sources", repo, repoName, primaryPackage, previous),
 	}
 }
 
 // preparePackageTable pre-computes all table data based on configuration
-func preparePackageTable(repo *debext.Repository, config PackageTableConfig, allPackages []string) PreparedPackageTable {
+// Architectures known from previousArchs keep their order
+func preparePackageTable(repo *debext.Repository, config PackageTableConfig, allPackages []string, previousArchs []string) PreparedPackageTable {
 	table := PreparedPackageTable{
 		ID:         config.ID,
 		HasArchRow: config.ArchitectureMode != "source",
@@ -271,12 +314,13 @@ func preparePackageTable(repo *debext.Repository, config PackageTableConfig, all
 	if config.ArchitectureMode != "source" {
 		archSet := make(map[string]bool)
 		for _, dist := range config.Distributions {
-			for _, arch := range repo.GetArchitectures(dist, config.Component, false) {
+			for _, arch := range componentArchitectures(repo, dist, config.Components) {
 				archSet[arch] = true
 			}
 		}
-		allArchs = slices.Sorted(maps.Keys(archSet))
+		allArchs = stickyOrder(previousArchs, slices.Sorted(maps.Keys(archSet)))
 	}
+	table.Architectures = allArchs
 
 	// Build header columns
 	for _, dist := range config.Distributions {
@@ -302,9 +346,20 @@ func preparePackageTable(repo *debext.Repository, config PackageTableConfig, all
 		}
 	}
 
+	// Component of each package, the first one if several feeds provide it
+	componentOf := make(map[string]string)
+	if config.ShowComponent {
+		for _, component := range slices.Backward(config.Components) {
+			for _, pkgName := range repo.GetPackageNames(component) {
+				componentOf[pkgName] = component
+			}
+		}
+	}
+
 	// Build rows
 	for _, pkgName := range allPackages {
 		row := buildTableRow(repo, pkgName, config, allArchs)
+		row.Component = componentOf[pkgName]
 		if len(row.Cells) > 0 && hasAnyPackage(row.Cells) {
 			table.Rows = append(table.Rows, row)
 		}
@@ -319,7 +374,7 @@ func preparePackageTable(repo *debext.Repository, config PackageTableConfig, all
 }
 
 // buildTableCell creates a table cell for a package in a specific distribution/architecture
-func buildTableCell(pkg *deb.Package, newestUpstream string) TableCell {
+func buildTableCell(pkg *deb.Package, newestUpstream string, pins []common.PinnedVersion) TableCell {
 	cell := TableCell{}
 	if pkg != nil {
 		cell.HasPackage = true
@@ -327,6 +382,7 @@ func buildTableCell(pkg *deb.Package, newestUpstream string) TableCell {
 		cell.ShortVersion = stripDistributionSuffix(pkg.Version)
 		upstream := debext.ParseVersion(pkg.Version).Upstream
 		cell.IsNewest = (upstream == newestUpstream)
+		cell.IsPinned = common.IsPinned(pins, debext.GetSourceNameFromPackage(pkg), pkg.Version)
 	}
 	return cell
 }
@@ -334,17 +390,17 @@ func buildTableCell(pkg *deb.Package, newestUpstream string) TableCell {
 // buildTableRow builds a complete row for a package across all distributions/architectures
 func buildTableRow(repo *debext.Repository, pkgName string, config PackageTableConfig, allArchs []string) TableRow {
 	row := TableRow{PackageName: pkgName}
-	newestUpstream := getNewestUpstreamVersion(repo, pkgName, config.Distributions, config.Component, config.ArchitectureMode)
+	newestUpstream := getNewestUpstreamVersion(repo, pkgName, config.Distributions, config.Components, config.ArchitectureMode)
 
 	for _, dist := range config.Distributions {
 		if config.ArchitectureMode == "source" {
 			pkg := repo.GetLatest(pkgName, dist, debext.SourceArchitecture)
-			row.Cells = append(row.Cells, buildTableCell(pkg, newestUpstream))
+			row.Cells = append(row.Cells, buildTableCell(pkg, newestUpstream, config.Pins))
 		} else {
 			// Use consistent architecture list across all distributions
 			for _, arch := range allArchs {
 				pkg := repo.GetLatest(pkgName, dist, arch)
-				row.Cells = append(row.Cells, buildTableCell(pkg, newestUpstream))
+				row.Cells = append(row.Cells, buildTableCell(pkg, newestUpstream, config.Pins))
 			}
 		}
 	}
@@ -363,14 +419,16 @@ func hasAnyPackage(cells []TableCell) bool {
 }
 
 // prepareAllPackageTables prepares all package tables for rendering
-func prepareAllPackageTables(repo *debext.Repository, repoName string, primaryPackage string) []PreparedPackageTable {
-	configs := getTableConfigs(repo, repoName, primaryPackage)
+// pins are the pinned versions of the repository, marked in the tables
+func prepareAllPackageTables(repo *debext.Repository, repoName string, primaryPackage string, previous *Layout, pins []common.PinnedVersion) []PreparedPackageTable {
+	configs := getTableConfigs(repo, repoName, primaryPackage, previous)
 
 	tables := make([]PreparedPackageTable, len(configs))
 	for i, config := range configs {
-		// Get packages for the specific component of this table
-		allPackages := repo.GetPackageNames(config.Component)
-		tables[i] = preparePackageTable(repo, config, allPackages)
+		config.Pins = pins
+		// Get packages for the specific components of this table
+		allPackages := componentPackageNames(repo, config.Components)
+		tables[i] = preparePackageTable(repo, config, allPackages, previous.architectures(config.ID))
 	}
 
 	return tables
@@ -379,11 +437,19 @@ func prepareAllPackageTables(repo *debext.Repository, repoName string, primaryPa
 // parseTemplates loads and parses all HTML templates with sprig functions
 func parseTemplates() (*template.Template, error) {
 	funcs := sprig.FuncMap()
-	// No custom functions needed - all logic is now in Go
+	// Replaced per composer in NewWeb, declared here so templates parse
+	funcs["freshness"] = func() *Freshness { return nil }
+	funcs["generator"] = func() Generator { return Generator{} }
 	tmpl := template.New("").Funcs(funcs)
 	return tmpl.ParseFS(templatesFS, "templates/*.html")
 }
 
+// Freshness is embedded into every page to show a banner once the build is older than its maximum age
+type Freshness struct {
+	Generated   int64 // Generation time in Unix milliseconds
+	MaxAgeHours int
+}
+
 // Web generates static HTML pages for repository browsing
 type Web struct {
 	options      *WebComposeOptions
@@ -419,15 +485,25 @@ type FeedInfo struct {
 
 // IndexData contains data for the root index page
 type IndexData struct {
+	Groups     []RepositoryGroup // Active repositories by category
+	Archived   []RepositoryLink  // Archived repositories, listed collapsed
+	BuildsPath string            // Relative path to the previous builds page, empty if none
+	AssetsPath string            // Relative path to assets directory
+	PageTitle  string            // Title for the navigation bar
+}
+
+// RepositoryGroup is a category of repositories on the index page
+type RepositoryGroup struct {
+	Name         string // Category name, empty = uncategorized
 	Repositories []RepositoryLink
-	AssetsPath   string // Relative path to assets directory
-	PageTitle    string // Title for the navigation bar
 }
 
 // RepositoryLink contains minimal info for linking to a repository
 type RepositoryLink struct {
-	Name string
-	Path string // Relative path to repository HTML file
+	Name     string
+	Path     string // Relative path to repository HTML file
+	Summary  string // Short plain-text description
+	Packages int    // Number of binary package names, 0 = unknown
 }
 
 // RepositoryPageData contains data for a repository detail page
@@ -443,6 +519,17 @@ type RepositoryPageData struct {
 	PageTitle         string                 // Title for the navigation bar
 	KeyringName       string                 // Keyring filename (sanitized domain)
 	RepositoryIcon    string                 // Repository icon filename (without extension) or empty for letter box
+	Owners            []OwnerLink            // Maintainers shown as "maintained by"
+	ValidUntilMax     int                    // Valid-Until-Max of the sources configuration in seconds, 0 = none
+	HasDebug          bool                   // Whether any distribution publishes the debug component
+	Components        []string               // Package components selectable in the install instructions
+	Snapshots         []Snapshot             // Snapshots of the repository, newest first
+}
+
+// OwnerLink is a maintainer of a repository with a link to contact them
+type OwnerLink struct {
+	Name string
+	URL  string // mailto: for emails, GitHub profile or team for @handles, empty if unknown
 }
 
 // DirectoryListingData contains data for a directory browsing page
@@ -463,6 +550,12 @@ type DirectoryEntry struct {
 	Modified    string
 }
 
+// Generator identifies the aarg build and configuration in the footer of every page
+type Generator struct {
+	Version string // aarg version with abbreviated commit
+	Config  string // Fingerprint of the configuration, empty if unknown
+}
+
 // NewWeb creates a new web composer
 func NewWeb(options *WebComposeOptions, downloader *common.Downloader) (*Web, error) {
 	// Parse templates
@@ -471,6 +564,17 @@ func NewWeb(options *WebComposeOptions, downloader *common.Downloader) (*Web, er
 		return nil, err
 	}
 
+	// Pages reveal the staleness banner in the browser once the build exceeds its maximum age
+	var freshness *Freshness
+	if options.MaxAgeHours > 0 {
+		freshness = &Freshness{Generated: time.Now().UnixMilli(), MaxAgeHours: options.MaxAgeHours}
+	}
+	generator := Generator{Version: common.CurrentBuild().String(), Config: options.ConfigHash}
+	tmpl.Funcs(template.FuncMap{
+		"freshness": func() *Freshness { return freshness },
+		"generator": func() Generator { return generator },
+	})
+
 	return &Web{
 		options:      options,
 		downloader:   downloader,
@@ -515,8 +619,12 @@ func (w *Web) Compose(ctx context.Context, repo *debext.Repository) error {
 
 	keyringName := GenerateKeyringName(w.options.BaseURL)
 
-	// Prepare tables first to get sorted distributions
-	tables := prepareAllPackageTables(repo, w.options.Name, w.options.PrimaryPackage)
+	// Prepare tables first to get sorted distributions, columns keep their order of the previous build
+	previous := loadLayout(w.options.PreviousTarget, w.options.Name)
+	tables := prepareAllPackageTables(repo, w.options.Name, w.options.PrimaryPackage, previous, w.options.Repository.Pin)
+	if w.options.Repository.Packages.SourceOnly {
+		tables = slices.DeleteFunc(tables, func(t PreparedPackageTable) bool { return t.ID != "sources" })
+	}
 
 	// Use sorted distributions from tables (all tables use the same sorting)
 	if len(tables) > 0 && len(tables[0].DistHeaders) > 0 {
@@ -563,6 +671,13 @@ func (w *Web) Compose(ctx context.Context, repo *debext.Repository) error {
 		PageTitle:         "APT Repositories",
 		KeyringName:       keyringName,
 		RepositoryIcon:    repoIcon,
+		Owners:            ownerLinks(w.options.Owners),
+		ValidUntilMax:     InstallScriptOptions{MaxAgeHours: w.options.MaxAgeHours}.ValidUntilMax(),
+		HasDebug: slices.ContainsFunc(repo.GetDistributions(), func(dist string) bool {
+			return EmitsDebugComponent(w.options.Repository.Packages, repo, dist)
+		}),
+		Components: PackageComponents(repo),
+		Snapshots:  ListSnapshots(filepath.Join(w.options.Target, w.options.Name)),
 	}
 
 	// Render template by executing base.html which will use the repository.html blocks
@@ -577,6 +692,7 @@ func (w *Web) Compose(ctx context.Context, repo *debext.Repository) error {
 		"templates/repo-description.html",
 		"templates/repo-feeds.html",
 		"templates/repo-installation.html",
+		"templates/repo-snapshots.html",
 		"templates/repo-packages.html",
 	); err != nil {
 		return err
@@ -595,13 +711,23 @@ func (w *Web) Compose(ctx context.Context, repo *debext.Repository) error {
 		return err
 	}
 
+	// Record the column order for the next build
+	if err := writeJSON(filepath.Join(repoDir, LayoutFile), newLayout(tables)); err != nil {
+		return err
+	}
+
 	// Generate install.sh script for this repository
-	installScript, err := GenerateInstallScript(InstallScriptOptions{
+	installOpts := InstallScriptOptions{
 		RepoName:      w.options.Name,
 		BaseURL:       w.options.BaseURL,
 		Distributions: repo.GetDistributions(),
+		Components:    PackageComponents(repo),
 		KeyringName:   keyringName,
-	})
+		MaxAgeHours:   w.options.MaxAgeHours,
+		SourceOnly:    w.options.Repository.Packages.SourceOnly,
+		Links:         w.options.Repository.Links,
+	}
+	installScript, err := GenerateInstallScript(installOpts)
 	if err != nil {
 		return err
 	}
@@ -610,6 +736,18 @@ func (w *Web) Compose(ctx context.Context, repo *debext.Repository) error {
 		return err
 	}
 
+	// Generate a sources file per distribution for manual setup
+	sourcesDir := filepath.Join(repoDir, "sources")
+	if err := os.MkdirAll(sourcesDir, 0755); err != nil {
+		return err
+	}
+	for _, dist := range repo.GetDistributions() {
+		sourcesPath := filepath.Join(sourcesDir, dist+".sources")
+		if err := os.WriteFile(sourcesPath, []byte(GenerateSourcesFile(installOpts, dist)), 0644); err != nil {
+			return err
+		}
+	}
+
 	// Export repository config as YAML
 	configYAML, err := yaml.Marshal(w.options.RepositoryConfig)
 	if err != nil {
@@ -620,6 +758,11 @@ func (w *Web) Compose(ctx context.Context, repo *debext.Repository) error {
 		return err
 	}
 
+	// Group binaries under their source package for browsing by source project
+	if err := w.generateBySource(repo); err != nil {
+		return err
+	}
+
 	// Generate directory indexes for browsing dists/ and subdirectories
 	if err := w.GenerateDirectoryIndexes(ctx, w.options.Name); err != nil {
 		return err
@@ -631,34 +774,70 @@ func (w *Web) Compose(ctx context.Context, repo *debext.Repository) error {
 // Index scans for repository subdirectories and generates the root index.html
 func (w *Web) Index(ctx context.Context) error {
 
+	// Snapshots of earlier builds are listed on their own page
+	if err := w.composeBuilds(); err != nil {
+		return err
+	}
+
 	// Scan for repository subdirectories in TargetDir
 	entries, err := os.ReadDir(w.options.Target)
 	if err != nil {
 		return err
 	}
 
-	var repositories []RepositoryLink
+	configs := make(map[string]*config.RepositoryConfig, len(w.options.Repositories))
+	for _, repo := range w.options.Repositories {
+		configs[repo.Name] = repo
+	}
+
+	categories := make(map[string][]RepositoryLink)
+	var archived []RepositoryLink
 	for _, entry := range entries {
 		if !entry.IsDir() {
 			continue
 		}
 
 		name := entry.Name()
+		if name == BuildsDir {
+			continue
+		}
 
 		// Check if directory contains an index.html file (indicating it's a repository)
 		indexPath := filepath.Join(w.options.Target, name, "index.html")
-		if _, err := os.Stat(indexPath); err == nil {
-			repositories = append(repositories, RepositoryLink{
-				Name: name,
-				Path: name + "/", // Link to directory
-			})
+		if _, err := os.Stat(indexPath); err != nil {
+			continue
+		}
+
+		link := RepositoryLink{
+			Name: name,
+			Path: name + "/", // Link to directory
+		}
+		if repository, err := LoadRepositoryState(w.options.Target, name); err == nil {
+			link.Packages = countPackageNames(repository)
+		}
+
+		repo := configs[name]
+		switch {
+		case repo == nil:
+			categories[""] = append(categories[""], link)
+		case repo.Archived:
+			link.Summary = repositorySummary(repo)
+			archived = append(archived, link)
+		default:
+			link.Summary = repositorySummary(repo)
+			categories[repo.Category] = append(categories[repo.Category], link)
 		}
 	}
 
 	data := IndexData{
-		Repositories: repositories,
-		AssetsPath:   "", // Root index is at same level as assets directory
-		PageTitle:    "APT Repositories",
+		Groups:     groupRepositories(categories, w.options.Categories),
+		Archived:   archived,
+		AssetsPath: "", // Root index is at same level as assets directory
+		PageTitle:  "APT Repositories",
+	}
+
+	if _, err := os.Stat(filepath.Join(w.options.Target, BuildsDir, "index.html")); err == nil {
+		data.BuildsPath = BuildsDir + "/"
 	}
 
 	// Render template by executing base.html which will use the index.html blocks
@@ -688,6 +867,74 @@ func (w *Web) Index(ctx context.Context) error {
 	return nil
 }
 
+// groupRepositories orders categories as configured, followed by unlisted categories alphabetically
+// and uncategorized repositories last
+func groupRepositories(categories map[string][]RepositoryLink, order []string) []RepositoryGroup {
+	names := slices.DeleteFunc(slices.Sorted(maps.Keys(categories)), func(name string) bool {
+		return name == "" || slices.Contains(order, name)
+	})
+	names = append(slices.Clone(order), names...)
+	names = append(names, "")
+
+	var groups []RepositoryGroup
+	for _, name := range names {
+		if repositories := categories[name]; len(repositories) > 0 {
+			groups = append(groups, RepositoryGroup{Name: name, Repositories: repositories})
+		}
+	}
+	return groups
+}
+
+// repositorySummary returns the summary of a repository, or the first text line of its description
+func repositorySummary(repo *config.RepositoryConfig) string {
+	if repo.Summary != "" {
+		return repo.Summary
+	}
+	for line := range strings.Lines(repo.Description) {
+		line = strings.TrimSpace(line)
+		// Skip headings, the repository name is shown anyway
+		if line != "" && !strings.HasPrefix(line, "#") {
+			return line
+		}
+	}
+	return ""
+}
+
+// countPackageNames returns the number of distinct binary package names in a repository
+func countPackageNames(repository *debext.Repository) int {
+	names := make(map[string]struct{})
+	for _, dist := range repository.GetDistributions() {
+		for _, component := range repository.GetComponents(dist) {
+			_ = repository.GetPackageList(dist, component).ForEach(func(pkg *deb.Package) error {
+				if !pkg.IsSource {
+					names[pkg.Name] = struct{}{}
+				}
+				return nil
+			})
+		}
+	}
+	return len(names)
+}
+
+// ownerLinks links owners like CODEOWNERS entries: emails by mailto, @user and @org/team on GitHub
+func ownerLinks(owners []string) []OwnerLink {
+	links := make([]OwnerLink, 0, len(owners))
+	for _, owner := range owners {
+		link := OwnerLink{Name: owner}
+		if handle, ok := strings.CutPrefix(owner, "@"); ok {
+			if org, team, isTeam := strings.Cut(handle, "/"); isTeam {
+				link.URL = "https://github.com/orgs/" + url.PathEscape(org) + "/teams/" + url.PathEscape(team)
+			} else {
+				link.URL = "https://github.com/" + url.PathEscape(handle)
+			}
+		} else if strings.Contains(owner, "@") {
+			link.URL = "mailto:" + owner
+		}
+		links = append(links, link)
+	}
+	return links
+}
+
 // prepareFeedInfo extracts feed information for template rendering
 func (w *Web) prepareFeedInfo() []FeedInfo {
 	feeds := make([]FeedInfo, 0, len(w.options.Feeds))
@@ -730,6 +977,11 @@ func (w *Web) prepareFeedInfo() []FeedInfo {
 			}
 		}
 
+		// Component the feed is published in
+		if w.options.Repository.ComponentPerFeed && feedOpts.TargetComponent != "" {
+			details = append(details, FeedDetail{Text: "Component: " + feedOpts.TargetComponent})
+		}
+
 		// Source filtering
 		if len(feedOpts.FromSources) > 0 {
 			details = append(details, FeedDetail{Text: "From sources: " + strings.Join(feedOpts.FromSources, ", ")})
@@ -749,10 +1001,33 @@ func (w *Web) prepareFeedInfo() []FeedInfo {
 			})
 		}
 
+		// Plugin feeds are trusted as provided by the plugin
+		if feedOpts.Type == feed.FeedTypePlugin {
+			details = append(details, FeedDetail{
+				Text:    "Plugin: " + feedOpts.Plugin,
+				Hover:   "Packages are provided by an external plugin and are not verified against an upstream signature.",
+				Warning: true,
+			})
+		}
+
+		// Manifest feeds are trusted by the checksums listed in the manifest
+		if feedOpts.Type == feed.FeedTypeManifest {
+			details = append(details, FeedDetail{
+				Text:    "Curated manifest",
+				Hover:   "Packages are listed with their checksums in a curated manifest and are not verified against an upstream signature.",
+				Warning: true,
+			})
+		}
+
+		projectURL := ""
+		if feedOpts.ProjectURL != nil {
+			projectURL = feedOpts.ProjectURL.String()
+		}
+
 		feeds = append(feeds, FeedInfo{
 			Type:      feedOpts.Type,
 			Name:      feedOpts.Name,
-			URL:       feedOpts.ProjectURL.String(),
+			URL:       projectURL,
 			Icon:      icon,
 			Details:   details,
 			NoChanges: feedOpts.NoChanges,
@@ -881,7 +1156,13 @@ func (w *Web) downloadAsset(ctx context.Context, url, destPath string) error {
 	return err
 }
 
+// maxDirectoryIndexDepth limits the nesting of directory indexes below dists/
+// Regular trees are at most dists/{dist}/{component}/binary-{arch}/by-hash/{hash} deep
+const maxDirectoryIndexDepth = 8
+
 // GenerateDirectoryIndexes creates browsable index.html files for dists/ and subdirectories
+// Feeds may introduce arbitrary file names into the tree, so names are escaped in links, names with
+// control characters are left out and symlinks are only listed if they stay within the target
 func (w *Web) GenerateDirectoryIndexes(ctx context.Context, repoName string) error {
 	repoDir := filepath.Join(w.options.Target, repoName)
 	distsDir := filepath.Join(repoDir, "dists")
@@ -891,12 +1172,22 @@ func (w *Web) GenerateDirectoryIndexes(ctx context.Context, repoName string) err
 		return nil // No dists directory, nothing to do
 	}
 
+	// Symlinks are checked against the resolved target, which itself may be a symlink
+	root, err := filepath.EvalSymlinks(w.options.Target)
+	if err != nil {
+		return fmt.Errorf("resolving target directory: %w", err)
+	}
+
 	// Generate indexes for dists/ and all subdirectories
-	return w.generateDirectoryIndex(ctx, distsDir, repoName, "dists")
+	return w.generateDirectoryIndex(ctx, root, distsDir, repoName, "dists")
 }
 
 // generateDirectoryIndex recursively generates index.html for a directory and its subdirectories
-func (w *Web) generateDirectoryIndex(ctx context.Context, dirPath, repoName, relativePath string) error {
+func (w *Web) generateDirectoryIndex(ctx context.Context, root, dirPath, repoName, relativePath string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	entries, err := os.ReadDir(dirPath)
 	if err != nil {
 		return fmt.Errorf("reading directory %s: %w", dirPath, err)
@@ -905,46 +1196,69 @@ func (w *Web) generateDirectoryIndex(ctx context.Context, dirPath, repoName, rel
 	var dirEntries []DirectoryEntry
 
 	for _, entry := range entries {
+		// Skip index.html files and their precompressed siblings
+		if entry.Name() == "index.html" || (IsPrecompressed(entry.Name()) && strings.HasPrefix(entry.Name(), "index.html")) {
+			continue
+		}
+
+		if !isListableName(entry.Name()) {
+			slog.Warn("Skipping file with unsafe name in directory index", "repository", repoName, "dir", relativePath, "name", fmt.Sprintf("%q", entry.Name()))
+			continue
+		}
+
+		entryPath := filepath.Join(dirPath, entry.Name())
 		info, err := entry.Info()
 		if err != nil {
 			continue
 		}
 
-		// Skip index.html files
-		if entry.Name() == "index.html" {
-			continue
+		// Symlinks are listed as their target, but only if it stays within the target directory
+		isSymlink := info.Mode()&os.ModeSymlink != 0
+		if isSymlink {
+			resolved, err := filepath.EvalSymlinks(entryPath)
+			if err != nil || !isWithin(root, resolved) {
+				slog.Warn("Skipping symlink pointing outside of the repository in directory index", "repository", repoName, "dir", relativePath, "name", entry.Name())
+				continue
+			}
+			if info, err = os.Stat(resolved); err != nil {
+				continue
+			}
 		}
 
-		isDir := entry.IsDir()
+		isDir := info.IsDir()
 
 		// Format size
 		size := "-"
 		if !isDir {
-			size = formatFileSize(info.Size())
+			size = common.FormatSize(uint64(info.Size()))
 		}
 
 		// Format modified time
-		modified := info.ModTime().Format("2006-01-02 15:04:05")
+		modified := common.FormatDisplayTime(info.ModTime())
 
-		// Create URL
-		url := entry.Name()
+		// Create URL, escaped and relative so names can't be taken as scheme, query or fragment
+		href := "./" + url.PathEscape(entry.Name())
 		if isDir {
-			url += "/"
+			href += "/"
 		}
 
 		dirEntries = append(dirEntries, DirectoryEntry{
 			Name:        entry.Name(),
-			URL:         url,
+			URL:         href,
 			IsDirectory: isDir,
 			Size:        size,
 			Modified:    modified,
 		})
 
-		// Recursively generate index for subdirectories
-		if isDir {
-			subDirPath := filepath.Join(dirPath, entry.Name())
+		// Recursively generate index for subdirectories, symlinked ones are indexed where they are placed
+		// since recursing into them would write an index into the symlink target
+		if isDir && !isSymlink {
 			subRelativePath := filepath.Join(relativePath, entry.Name())
-			if err := w.generateDirectoryIndex(ctx, subDirPath, repoName, subRelativePath); err != nil {
+			if strings.Count(subRelativePath, "/") > maxDirectoryIndexDepth {
+				slog.Warn("Skipping directory index nested too deep", "repository", repoName, "dir", subRelativePath, "max_depth", maxDirectoryIndexDepth)
+				continue
+			}
+			if err := w.generateDirectoryIndex(ctx, root, entryPath, repoName, subRelativePath); err != nil {
 				return err
 			}
 		}
@@ -988,8 +1302,11 @@ func (w *Web) generateDirectoryIndex(ctx context.Context, dirPath, repoName, rel
 		return fmt.Errorf("executing directory template: %w", err)
 	}
 
-	// Write index.html to directory
+	// Write index.html to directory, removed first to never write through a symlink placed there
 	indexPath := filepath.Join(dirPath, "index.html")
+	if err := os.Remove(indexPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("replacing directory index: %w", err)
+	}
 	if err := os.WriteFile(indexPath, buf.Bytes(), 0644); err != nil {
 		return fmt.Errorf("writing directory index: %w", err)
 	}
@@ -997,16 +1314,86 @@ func (w *Web) generateDirectoryIndex(ctx context.Context, dirPath, repoName, rel
 	return nil
 }
 
-// formatFileSize formats a file size in bytes to a human-readable string
-func formatFileSize(bytes int64) string {
-	const unit = 1024
-	if bytes < unit {
-		return fmt.Sprintf("%d B", bytes)
+// isListableName reports whether a file name can be listed in a directory index
+// Names must be valid UTF-8 without control characters, which could break or spoof the listing
+func isListableName(name string) bool {
+	if !utf8.ValidString(name) {
+		return false
 	}
-	div, exp := int64(unit), 0
-	for n := bytes / unit; n >= unit; n /= unit {
-		div *= unit
-		exp++
+	return !strings.ContainsFunc(name, unicode.IsControl)
+}
+
+// isWithin reports whether path is root or below it, both must be resolved
+func isWithin(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// composeWeb generates the web page of a repository from the repository composed by apt
+func composeWeb(ctx context.Context, deps Dependencies, results *Results) (err error) {
+	repo := deps.Repository
+
+	ctx, span := telemetry.Start(ctx, "compose.web", telemetry.RepositoryKey.String(repo.Name))
+	defer func() { telemetry.End(span, err) }()
+
+	options := &WebComposeOptions{
+		ComposeOptions: ComposeOptions{
+			Target: deps.StagingPath,
+			Name:   repo.Name,
+			Feeds:  repo.Feeds,
+		},
+		Description:      repo.Description,
+		Owners:           repo.Owners,
+		Repository:       &repo.RepositoryOptions,
+		BaseURL:          deps.Config.URL,
+		Downloads:        deps.Config.Directories.GetDownloadsPath(),
+		PrimaryPackage:   repo.Packages.Primary,
+		IconURLs:         deps.Config.Web.GetIconURLs(),
+		GitHubClient:     deps.GitHubClient,
+		TailwindRelease:  deps.Config.Web.Tailwind.Release,
+		RepositoryConfig: repo,
+		PreviousTarget:   deps.PreviousPath,
+		MaxAgeHours:      deps.Config.Generate.HealthMaxAgeHours,
+		ConfigHash:       deps.ConfigHash,
+		Provenance:       repositoryProvenance(results),
+	}
+
+	composer, err := NewWeb(options, deps.Downloader)
+	if err != nil {
+		return fmt.Errorf("failed to initialize web composer for %s: %w", repo.Name, err)
+	}
+
+	if err := composer.Compose(ctx, results.Repository); err != nil {
+		return fmt.Errorf("failed to compose web page for %s: %w", repo.Name, err)
+	}
+
+	slog.Info("Web page generated", "repository", repo.Name)
+	return nil
+}
+
+// indexWeb generates the index.html overview page
+func indexWeb(ctx context.Context, deps Dependencies) error {
+	options := &WebComposeOptions{
+		ComposeOptions: ComposeOptions{
+			Target: deps.StagingPath,
+		},
+		BaseURL:         deps.Config.URL,
+		Downloads:       deps.Config.Directories.GetDownloadsPath(),
+		IconURLs:        deps.Config.Web.GetIconURLs(),
+		GitHubClient:    deps.GitHubClient,
+		TailwindRelease: deps.Config.Web.Tailwind.Release,
+		Repositories:    deps.Config.Repositories,
+		Categories:      deps.Config.Web.Categories,
+		StagingBase:     deps.Config.Directories.GetStagingPath(),
+		PreviousBuilds:  deps.Config.Web.PreviousBuilds,
+		MaxAgeHours:     deps.Config.Generate.HealthMaxAgeHours,
+		ConfigHash:      deps.ConfigHash,
+	}
+
+	composer, err := NewWeb(options, deps.Downloader)
+	if err != nil {
+		return err
 	}
-	return fmt.Sprintf("%.1f %ciB", float64(bytes)/float64(div), "KMGTPE"[exp])
+
+	return composer.Index(ctx)
 }
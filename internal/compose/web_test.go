@@ -0,0 +1,94 @@
+package compose
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsWithin(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		want bool
+	}{
+		{name: "root itself", path: "/srv/apt", want: true},
+		{name: "below root", path: "/srv/apt/repo/dists", want: true},
+		{name: "dot dot prefixed name below root", path: "/srv/apt/..hidden", want: true},
+		{name: "dot dot prefixed directory below root", path: "/srv/apt/..d/file", want: true},
+		{name: "parent", path: "/srv", want: false},
+		{name: "sibling", path: "/srv/other", want: false},
+		{name: "sibling sharing the prefix", path: "/srv/apt-other/file", want: false},
+		{name: "outside", path: "/etc/passwd", want: false},
+		{name: "relative", path: "repo/dists", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, isWithin("/srv/apt", tt.path))
+		})
+	}
+}
+
+func TestIsListableName(t *testing.T) {
+	tests := []struct {
+		name     string
+		fileName string
+		want     bool
+	}{
+		{name: "plain", fileName: "Release", want: true},
+		{name: "spaces and unicode", fileName: "café notes.txt", want: true},
+		{name: "dot dot prefix", fileName: "..hidden", want: true},
+		{name: "markup", fileName: "<script>.deb", want: true},
+		{name: "newline", fileName: "a\nb", want: false},
+		{name: "carriage return", fileName: "a\rb", want: false},
+		{name: "tab", fileName: "a\tb", want: false},
+		{name: "nul", fileName: "a\x00b", want: false},
+		{name: "escape", fileName: "\x1b[31mred", want: false},
+		{name: "delete", fileName: "a\x7fb", want: false},
+		{name: "c1 control", fileName: "a\u0085b", want: false},
+		{name: "invalid utf-8", fileName: "a\xffb", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, isListableName(tt.fileName))
+		})
+	}
+}
+
+func TestWeb_GenerateDirectoryIndexes_Symlinks(t *testing.T) {
+	target := t.TempDir()
+	dists := filepath.Join(target, "repo", "dists")
+	require.NoError(t, os.MkdirAll(filepath.Join(dists, "stable", "main"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dists, "stable", "Release"), []byte("release"), 0644))
+
+	// Symlinks to a directory within the target but outside of dists, and to one outside of the target
+	shared := filepath.Join(target, "shared")
+	require.NoError(t, os.MkdirAll(shared, 0755))
+	require.NoError(t, os.Symlink(shared, filepath.Join(dists, "bookworm")))
+	outside := t.TempDir()
+	require.NoError(t, os.Symlink(outside, filepath.Join(dists, "outside")))
+
+	w, err := NewWeb(&WebComposeOptions{ComposeOptions: ComposeOptions{Target: target, Name: "repo"}}, nil)
+	require.NoError(t, err)
+	require.NoError(t, w.GenerateDirectoryIndexes(context.Background(), "repo"))
+
+	index, err := os.ReadFile(filepath.Join(dists, "index.html"))
+	require.NoError(t, err)
+	assert.Contains(t, string(index), "./bookworm/")
+	assert.NotContains(t, string(index), "./outside/")
+	assert.FileExists(t, filepath.Join(dists, "stable", "index.html"))
+	assert.FileExists(t, filepath.Join(dists, "stable", "main", "index.html"))
+
+	// Symlinked directories are not recursed into, no index is written into their target
+	assert.NoFileExists(t, filepath.Join(shared, "index.html"))
+	assert.NoFileExists(t, filepath.Join(outside, "index.html"))
+	info, err := os.Lstat(filepath.Join(dists, "bookworm"))
+	require.NoError(t, err)
+	assert.NotZero(t, info.Mode()&os.ModeSymlink)
+}
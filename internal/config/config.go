@@ -1,31 +1,47 @@
 package config
 
 import (
+	"bytes"
 	"maps"
 	"os"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"strings"
+	"text/template"
+	"time"
 
 	"github.com/dionysius/aarg/internal/common"
 	"github.com/dionysius/aarg/internal/feed"
+	"github.com/dionysius/aarg/internal/plugin"
 	"gopkg.in/yaml.v3"
 )
 
 // Config represents the complete application configuration
 type Config struct {
-	Directories  DirectoriesConfig   `yaml:"directories"`
-	HTTP         HTTPConfig          `yaml:"http,omitempty"`
-	Signing      SigningConfig       `yaml:"signing"`
-	GitHub       GitHubConfig        `yaml:"github,omitempty"`
-	Cloudflare   CloudflareConfig    `yaml:"cloudflare,omitempty"`
-	URL          string              `yaml:"url"`
-	Generate     GenerateConfig      `yaml:"generate,omitempty"`
-	Web          WebConfig           `yaml:"web,omitempty"`
-	Serve        ServeConfig         `yaml:"serve,omitempty"`
-	Workers      WorkersConfig       `yaml:"workers"`
-	Repositories []*RepositoryConfig `yaml:"repositories"` // Loaded from Directories.Repositories/*.yaml
-	ConfigDir    string              `yaml:"-"`            // Directory containing config.yaml (set during Load)
+	Directories   DirectoriesConfig         `yaml:"directories"`
+	HTTP          HTTPConfig                `yaml:"http,omitempty"`
+	Signing       SigningConfig             `yaml:"signing"`
+	GitHub        GitHubConfig              `yaml:"github,omitempty"`
+	OBS           OBSConfig                 `yaml:"obs,omitempty"`
+	Cloudflare    CloudflareConfig          `yaml:"cloudflare,omitempty"`
+	URL           string                    `yaml:"url"`
+	Timezone      string                    `yaml:"timezone,omitempty"` // Time zone of timestamps in web pages, reports and logs (IANA name), empty = UTC
+	Generate      GenerateConfig            `yaml:"generate,omitempty"`
+	Publish       PublishConfig             `yaml:"publish,omitempty"`
+	Web           WebConfig                 `yaml:"web,omitempty"`
+	Serve         ServeConfig               `yaml:"serve,omitempty"`
+	Watch         WatchConfig               `yaml:"watch,omitempty"`
+	Workers       WorkersConfig             `yaml:"workers"`
+	Tracing       TracingConfig             `yaml:"tracing,omitempty"`
+	Notifications NotificationsConfig       `yaml:"notifications,omitempty"`
+	Fetch         FetchConfig               `yaml:"fetch,omitempty"`
+	Preflight     PreflightConfig           `yaml:"preflight,omitempty"`
+	GC            GCConfig                  `yaml:"gc,omitempty"`
+	Storage       StorageConfig             `yaml:"storage,omitempty"`
+	Plugins       map[string]plugin.Command `yaml:"plugins,omitempty"` // External feed and provider plugins by name
+	Repositories  []*RepositoryConfig       `yaml:"repositories"`      // Loaded from Directories.Repositories/*.yaml
+	ConfigDir     string                    `yaml:"-"`                 // Directory containing config.yaml (set during Load)
 }
 
 // DirectoriesConfig defines directory paths
@@ -36,6 +52,11 @@ type DirectoriesConfig struct {
 	Trusted      string `yaml:"trusted"`      // Relative to Root if not absolute
 	Staging      string `yaml:"staging"`      // Relative to Root if not absolute, contains timestamped build directories
 	Public       string `yaml:"public"`       // Relative to Root if not absolute
+	Cache        string `yaml:"cache"`        // Relative to Root if not absolute, contains reusable parse results
+
+	// PublicStaging enables a review environment, relative to Root if not absolute
+	// When set, generate updates this symlink and promote moves a build to Public
+	PublicStaging string `yaml:"public_staging,omitempty"`
 }
 
 // GetDownloadsPath returns the absolute path to the downloads directory
@@ -70,15 +91,99 @@ func (d *DirectoriesConfig) GetPublicPath() string {
 	return filepath.Join(d.Root, d.Public)
 }
 
+// GetCachePath returns the absolute path to the cache directory
+func (d *DirectoriesConfig) GetCachePath() string {
+	if filepath.IsAbs(d.Cache) {
+		return d.Cache
+	}
+	return filepath.Join(d.Root, d.Cache)
+}
+
+// GetPublicStagingPath returns the absolute path to the public staging symlink, or empty if not configured
+func (d *DirectoriesConfig) GetPublicStagingPath() string {
+	if d.PublicStaging == "" || filepath.IsAbs(d.PublicStaging) {
+		return d.PublicStaging
+	}
+	return filepath.Join(d.Root, d.PublicStaging)
+}
+
 // SigningConfig contains GPG signing configuration
 type SigningConfig struct {
+	PrivateKey string             `yaml:"private_key"`
+	PublicKey  string             `yaml:"public_key"`
+	Passphrase string             `yaml:"passphrase,omitempty"` // Optional passphrase for the private key
+	Key        string             `yaml:"key,omitempty"`        // Fingerprint or long key ID of the key or subkey to sign with, empty picks the first signing key
+	Additional []SigningKeyConfig `yaml:"additional,omitempty"` // Further keys signing alongside, e.g. the old key during a key rotation
+	Backend    string             `yaml:"backend,omitempty"`    // SigningBackendFile (default) or SigningBackendGPGAgent
+	GPG        string             `yaml:"gpg,omitempty"`        // gpg binary of the gpg-agent backend, empty = gpg from PATH
+	GnuPGHome  string             `yaml:"gnupg_home,omitempty"` // GnuPG home directory of the gpg-agent backend, empty = gpg's default
+	Sigstore   SigstoreConfig     `yaml:"sigstore,omitempty"`   // Sigstore bundles of Release files alongside the GPG signatures
+}
+
+// SigstoreConfig contains the sigstore signing of Release files with cosign
+type SigstoreConfig struct {
+	Enabled    bool   `yaml:"enabled,omitempty"`
+	Cosign     string `yaml:"cosign,omitempty"`     // cosign binary, empty = cosign from PATH
+	Key        string `yaml:"key,omitempty"`        // cosign private key file (relative to config dir) or KMS URI, empty = keyless
+	Passphrase string `yaml:"passphrase,omitempty"` // Passphrase of the cosign private key
+	FulcioURL  string `yaml:"fulcio_url,omitempty"` // Fulcio instance for keyless certificates, empty = public sigstore instance
+	RekorURL   string `yaml:"rekor_url,omitempty"`  // Rekor transparency log, empty = public sigstore instance
+	SkipTlog   bool   `yaml:"skip_tlog,omitempty"`  // Don't upload transparency log entries
+}
+
+// GetKeyPath returns the absolute path to the cosign private key, KMS URIs are returned as-is
+func (s *SigstoreConfig) GetKeyPath(configDir string) string {
+	if s.Key == "" || filepath.IsAbs(s.Key) || strings.Contains(s.Key, "://") {
+		return s.Key
+	}
+	return filepath.Join(configDir, s.Key)
+}
+
+// Signing backends
+const (
+	SigningBackendFile     = "file"      // Private keys are read from key files
+	SigningBackendGPGAgent = "gpg-agent" // gpg signs, the private keys stay with gpg-agent or a hardware token
+)
+
+// UsesGPGAgent reports whether gpg signs with keys held by gpg-agent
+func (s *SigningConfig) UsesGPGAgent() bool {
+	return s.Backend == SigningBackendGPGAgent
+}
+
+// GetGnuPGHome returns the absolute path to the GnuPG home directory
+func (s *SigningConfig) GetGnuPGHome(configDir string) string {
+	if s.GnuPGHome == "" || filepath.IsAbs(s.GnuPGHome) {
+		return s.GnuPGHome
+	}
+	return filepath.Join(configDir, s.GnuPGHome)
+}
+
+// GetPrivateKeyPath returns the absolute path to the private key
+func (s *SigningConfig) GetPrivateKeyPath(configDir string) string {
+	if s.PrivateKey == "" || filepath.IsAbs(s.PrivateKey) {
+		return s.PrivateKey
+	}
+	return filepath.Join(configDir, s.PrivateKey)
+}
+
+// GetPublicKeyPath returns the absolute path to the public key
+func (s *SigningConfig) GetPublicKeyPath(configDir string) string {
+	if s.PublicKey == "" || filepath.IsAbs(s.PublicKey) {
+		return s.PublicKey
+	}
+	return filepath.Join(configDir, s.PublicKey)
+}
+
+// SigningKeyConfig contains an additional signing key
+type SigningKeyConfig struct {
 	PrivateKey string `yaml:"private_key"`
 	PublicKey  string `yaml:"public_key"`
 	Passphrase string `yaml:"passphrase,omitempty"` // Optional passphrase for the private key
+	Key        string `yaml:"key,omitempty"`        // Fingerprint or long key ID of the key or subkey to sign with
 }
 
 // GetPrivateKeyPath returns the absolute path to the private key
-func (s *SigningConfig) GetPrivateKeyPath(configDir string) string {
+func (s *SigningKeyConfig) GetPrivateKeyPath(configDir string) string {
 	if s.PrivateKey == "" || filepath.IsAbs(s.PrivateKey) {
 		return s.PrivateKey
 	}
@@ -86,7 +191,7 @@ func (s *SigningConfig) GetPrivateKeyPath(configDir string) string {
 }
 
 // GetPublicKeyPath returns the absolute path to the public key
-func (s *SigningConfig) GetPublicKeyPath(configDir string) string {
+func (s *SigningKeyConfig) GetPublicKeyPath(configDir string) string {
 	if s.PublicKey == "" || filepath.IsAbs(s.PublicKey) {
 		return s.PublicKey
 	}
@@ -95,10 +200,132 @@ func (s *SigningConfig) GetPublicKeyPath(configDir string) string {
 
 // HTTPConfig contains HTTP client configuration
 type HTTPConfig struct {
-	UserAgent       string `yaml:"user_agent,omitempty"`         // Custom User-Agent header
-	Timeout         int    `yaml:"timeout"`                      // Request timeout in seconds
-	MaxIdleConns    int    `yaml:"max_idle_conns,omitempty"`     // Maximum idle connections
-	MaxConnsPerHost int    `yaml:"max_conns_per_host,omitempty"` // Maximum connections per host
+	UserAgent             string `yaml:"user_agent,omitempty"`                    // Custom User-Agent header
+	Timeout               int    `yaml:"timeout"`                                 // Request timeout in seconds
+	MaxIdleConns          int    `yaml:"max_idle_conns,omitempty"`                // Maximum idle connections
+	MaxConnsPerHost       int    `yaml:"max_conns_per_host,omitempty"`            // Maximum connections per host
+	MaxBytesPerSecond     int64  `yaml:"max_bytes_per_second,omitempty"`          // Download bandwidth across all hosts, 0 = unlimited
+	MaxBytesPerSecondHost int64  `yaml:"max_bytes_per_second_per_host,omitempty"` // Download bandwidth per host, 0 = unlimited
+}
+
+// FetchConfig contains settings of the fetch phase
+type FetchConfig struct {
+	// FeedTimeout in seconds after which fetching a feed is cancelled and reported without failing the run,
+	// its previously fetched packages are kept (default: 0, unlimited). Feeds can override it with timeout
+	FeedTimeout int `yaml:"feed_timeout,omitempty"`
+	// ClockSkew in seconds tolerated between the local clock and the clocks of upstream signers when
+	// checking the validity times of signatures, keys and Release files (default: 0, 300 seconds).
+	// Negative values disable the checks
+	ClockSkew int `yaml:"clock_skew,omitempty"`
+}
+
+// ClockSkewDuration returns the tolerated clock skew, 0 for the default and negative if disabled
+func (f FetchConfig) ClockSkewDuration() time.Duration {
+	return time.Duration(f.ClockSkew) * time.Second
+}
+
+// PreflightConfig contains the disk space checks run before fetch and generate
+type PreflightConfig struct {
+	Disabled      bool   `yaml:"disabled,omitempty"`       // Skip all disk space checks
+	MinFreeMB     uint64 `yaml:"min_free_mb,omitempty"`    // Free space to keep on top of estimates and the downloads in progress (default: 1024)
+	MarginPercent uint64 `yaml:"margin_percent,omitempty"` // Margin added to estimates based on previous runs (default: 20)
+}
+
+// MinFreeBytes returns the free space to keep in bytes, 0 if checks are disabled
+func (p PreflightConfig) MinFreeBytes() uint64 {
+	if p.Disabled {
+		return 0
+	}
+	return p.MinFreeMB * 1024 * 1024
+}
+
+// WithMargin returns the estimate increased by the configured margin
+func (p PreflightConfig) WithMargin(estimate uint64) uint64 {
+	return estimate + estimate*p.MarginPercent/100
+}
+
+// GCConfig contains cleanup policies applied after fetch and by 'aarg gc'
+type GCConfig struct {
+	Downloads DownloadsGCConfig `yaml:"downloads,omitempty"`
+}
+
+// StorageConfig selects where metadata like parsed control files, feed maps and checksums is kept
+type StorageConfig struct {
+	Backend  string `yaml:"backend,omitempty"`  // "files" (default) or "sqlite"
+	Database string `yaml:"database,omitempty"` // SQLite database, relative to the cache directory if not absolute (default: metadata.db)
+}
+
+// GetDatabasePath returns the absolute path to the SQLite database
+func (s StorageConfig) GetDatabasePath(cacheDir string) string {
+	if filepath.IsAbs(s.Database) {
+		return s.Database
+	}
+	return filepath.Join(cacheDir, s.Database)
+}
+
+// DownloadsGCConfig limits the downloads cache, files hardlinked into trusted storage are never evicted
+type DownloadsGCConfig struct {
+	MaxSizeMB  uint64 `yaml:"max_size_mb,omitempty"`  // Max size of files only in downloads, least recently used are evicted first (0 = unlimited)
+	MaxAgeDays int    `yaml:"max_age_days,omitempty"` // Evict files not used for this many days (0 = unlimited)
+}
+
+// Policy returns the cache policy of the downloads directory
+func (d DownloadsGCConfig) Policy() common.CachePolicy {
+	return common.CachePolicy{
+		MaxSize: d.MaxSizeMB * 1024 * 1024,
+		MaxAge:  time.Duration(d.MaxAgeDays) * 24 * time.Hour,
+	}
+}
+
+// TracingConfig contains OpenTelemetry trace export configuration
+// Tracing is enabled if an endpoint is set here or through the OTEL_EXPORTER_OTLP_* environment variables
+type TracingConfig struct {
+	Endpoint    string            `yaml:"endpoint,omitempty"`     // OTLP/HTTP endpoint, "host:port" or full URL
+	Insecure    bool              `yaml:"insecure,omitempty"`     // Use plain HTTP for "host:port" endpoints
+	Headers     map[string]string `yaml:"headers,omitempty"`      // Additional export headers (e.g., authentication)
+	ServiceName string            `yaml:"service_name,omitempty"` // Reported service name (default: aarg)
+	SampleRatio *float64          `yaml:"sample_ratio,omitempty"` // Fraction of traces to sample, 0-1 (default: 1)
+}
+
+// GetSampleRatio returns the fraction of traces to sample, 0 samples none
+func (t TracingConfig) GetSampleRatio() float64 {
+	if t.SampleRatio == nil {
+		return 1
+	}
+	return *t.SampleRatio
+}
+
+// IsEnabled reports whether traces should be exported
+func (t TracingConfig) IsEnabled() bool {
+	return t.Endpoint != "" || os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != ""
+}
+
+// NotificationsConfig configures webhooks receiving the outcome of runs with warnings or failures
+// Repositories are routed to the webhooks of their owners like CODEOWNERS, everything else to the default webhook
+type NotificationsConfig struct {
+	Webhook string            `yaml:"webhook,omitempty"` // Default webhook, also receiving repositories without routed owner
+	Owners  map[string]string `yaml:"owners,omitempty"`  // Webhook per owner as listed in repository owners
+	Always  bool              `yaml:"always,omitempty"`  // Also notify runs finishing without warnings
+}
+
+// IsEnabled reports whether any webhook is configured
+func (n NotificationsConfig) IsEnabled() bool {
+	return n.Webhook != "" || len(n.Owners) > 0
+}
+
+// Route returns the webhooks notified about a repository with the given owners
+// Every owner with a webhook is notified, the default webhook only if no owner has one
+func (n NotificationsConfig) Route(owners []string) []string {
+	var webhooks []string
+	for _, owner := range owners {
+		if webhook := n.Owners[owner]; webhook != "" && !slices.Contains(webhooks, webhook) {
+			webhooks = append(webhooks, webhook)
+		}
+	}
+	if len(webhooks) == 0 && n.Webhook != "" {
+		webhooks = append(webhooks, n.Webhook)
+	}
+	return webhooks
 }
 
 // GitHubConfig contains GitHub API configuration
@@ -106,12 +333,59 @@ type GitHubConfig struct {
 	Token string `yaml:"token,omitempty"` // GitHub personal access token
 }
 
+// OBSConfig contains the credentials of the OBS API used by obs feeds with obs_api
+type OBSConfig struct {
+	Username string `yaml:"username,omitempty"`
+	Password string `yaml:"password,omitempty"`
+}
+
 // CloudflareConfig contains Cloudflare Pages deployment configuration
 type CloudflareConfig struct {
 	APIToken    string        `yaml:"api_token,omitempty"`
 	AccountID   string        `yaml:"account_id,omitempty"`
 	ProjectName string        `yaml:"project_name,omitempty"`
 	Cleanup     CleanupConfig `yaml:"cleanup,omitempty"`
+
+	// ProductionBranch is the production branch of the Pages projects
+	ProductionBranch string `yaml:"production_branch,omitempty"`
+
+	// StagingBranch is the preview branch used for builds not yet promoted to production
+	StagingBranch string `yaml:"staging_branch,omitempty"`
+
+	// CanaryProject is a separate Pages project that receives production builds first for verification
+	CanaryProject string `yaml:"canary_project,omitempty"`
+
+	// RepositoryProject is a template of a Pages project per repository with {{.Repository}} available
+	// Each repository is then uploaded to its own project, project_name only receives the shared files
+	RepositoryProject string `yaml:"repository_project,omitempty"`
+
+	// Concurrency is the number of parallel check-missing and upload requests
+	Concurrency int `yaml:"concurrency,omitempty"`
+
+	// RequestsPerMinute limits the API requests per API token, Cloudflare allows about 1200 per 5 minutes
+	RequestsPerMinute int `yaml:"requests_per_minute,omitempty"`
+
+	// ManageDomain adds the host of url as custom domain to project_name and verifies it points at the project
+	ManageDomain bool `yaml:"manage_domain,omitempty"`
+}
+
+// RepositoryProjectName returns the Pages project of a repository, empty if repositories aren't split
+func (c CloudflareConfig) RepositoryProjectName(repository string) (string, error) {
+	if c.RepositoryProject == "" {
+		return "", nil
+	}
+
+	tmpl, err := template.New("project").Option("missingkey=error").Parse(c.RepositoryProject)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, struct{ Repository string }{repository}); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
 }
 
 // CleanupConfig contains deployment cleanup settings
@@ -125,6 +399,124 @@ type GenerateConfig struct {
 	PoolMode string   `yaml:"pool_mode,omitempty"` // "hierarchical" or "redirect"
 	Compose  []string `yaml:"compose,omitempty"`   // List of composers to run
 	KeepLast int      `yaml:"keep_last"`           // Number of staging builds to keep
+
+	// HealthMaxAgeHours is the age after which healthz.json reports the build as stale (0 = never)
+	HealthMaxAgeHours int `yaml:"health_max_age_hours,omitempty"`
+
+	Compression CompressionConfig `yaml:"compression,omitempty"` // Compression levels for index files
+
+	// Incremental reuses the dists of the previous build for distributions whose packages and settings are unchanged
+	Incremental bool `yaml:"incremental,omitempty"`
+
+	// PDiffHistory is the number of patches kept per index for apt clients downloading changes only (0 = no pdiffs)
+	PDiffHistory int `yaml:"pdiff_history,omitempty"`
+
+	// Contents generates Contents-<arch> indexes of the files installed by the binary packages, e.g. for apt-file
+	Contents bool `yaml:"contents,omitempty"`
+
+	// Translations moves the long descriptions of binary packages into i18n/Translation-en indexes per Debian policy
+	Translations bool `yaml:"translations,omitempty"`
+
+	// Precompress lists the encodings ("gzip", "brotli") of precompressed siblings written for web files
+	Precompress []string `yaml:"precompress,omitempty"`
+
+	// VerifyPool re-checks the checksums of trusted files while linking them into the pool ("sample" or "full", "none" or empty = off)
+	VerifyPool string `yaml:"verify_pool,omitempty"`
+
+	// VerifyPoolSample is the percentage of files checked by verify_pool "sample"
+	VerifyPoolSample int `yaml:"verify_pool_sample,omitempty"`
+}
+
+// PublishConfig contains publish safety settings
+type PublishConfig struct {
+	MaxRemoved        int     `yaml:"max_removed,omitempty"`         // Max packages removed per repository compared to the last published build (0 = disabled)
+	MaxRemovedPercent float64 `yaml:"max_removed_percent,omitempty"` // Max percentage of packages removed per repository (0 = disabled)
+
+	// Plugin is the name of a provider plugin used instead of Cloudflare Pages
+	Plugin string `yaml:"plugin,omitempty"`
+
+	// Directory publishes into a local directory instead of Cloudflare Pages
+	Directory DirectoryConfig `yaml:"directory,omitempty"`
+
+	// Providers publishes to several providers in turn, e.g. Cloudflare Pages and a mirror through a plugin
+	// Empty = the provider plugin, directory or Cloudflare Pages, whichever is configured
+	Providers []ProviderConfig `yaml:"providers,omitempty"`
+}
+
+// Names of the built-in providers in publish providers, any other name refers to a provider plugin
+const (
+	ProviderCloudflare = "cloudflare"
+	ProviderDirectory  = "directory"
+)
+
+// ProviderConfig enables a provider to publish to
+type ProviderConfig struct {
+	Name    string `yaml:"name"`              // "cloudflare", "directory" or the name of a provider plugin
+	Enabled *bool  `yaml:"enabled,omitempty"` // Publish to the provider (default: true)
+}
+
+// IsEnabled reports whether the provider is published to
+func (p ProviderConfig) IsEnabled() bool {
+	return p.Enabled == nil || *p.Enabled
+}
+
+// ProviderNames returns the names of the enabled providers in publish order
+func (p PublishConfig) ProviderNames() []string {
+	if len(p.Providers) == 0 {
+		switch {
+		case p.Plugin != "":
+			return []string{p.Plugin}
+		case p.Directory.Path != "":
+			return []string{ProviderDirectory}
+		default:
+			return []string{ProviderCloudflare}
+		}
+	}
+
+	var names []string
+	for _, prov := range p.Providers {
+		if prov.IsEnabled() {
+			names = append(names, prov.Name)
+		}
+	}
+	return names
+}
+
+// DirectoryConfig configures publishing into a local directory served by an existing web server
+// Every publish is copied into a new release next to the path, then the path symlink is swapped to it
+type DirectoryConfig struct {
+	Path        string `yaml:"path,omitempty"`         // Symlink to the published release, served by the web server (empty = disabled)
+	StagingPath string `yaml:"staging_path,omitempty"` // Symlink to the release published to staging, required with public_staging
+	Mode        string `yaml:"mode,omitempty"`         // "hardlink" (default, falls back to copying across filesystems) or "copy"
+	Keep        int    `yaml:"keep,omitempty"`         // Previous releases kept per path for rollback (default: 2)
+}
+
+// GetPath returns the absolute symlink path for production or staging, relative paths are below the root directory
+func (d *DirectoryConfig) GetPath(root string, production bool) string {
+	path := d.Path
+	if !production {
+		path = d.StagingPath
+	}
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(root, path)
+}
+
+// CompressionConfig contains compression levels per format (1-9, 0 = library default)
+type CompressionConfig struct {
+	Gzip  int `yaml:"gzip,omitempty"`
+	Bzip2 int `yaml:"bzip2,omitempty"`
+	XZ    int `yaml:"xz,omitempty"`
+}
+
+// Levels returns the compression levels keyed by compression format
+func (c CompressionConfig) Levels() common.CompressionLevels {
+	return common.CompressionLevels{
+		common.CompressionGzip:  c.Gzip,
+		common.CompressionBzip2: c.Bzip2,
+		common.CompressionXZ:    c.XZ,
+	}
 }
 
 // TailwindConfig contains Tailwind CSS configuration
@@ -136,12 +528,61 @@ type TailwindConfig struct {
 type WebConfig struct {
 	Tailwind TailwindConfig    `yaml:"tailwind,omitempty"`
 	IconURLs map[string]string `yaml:"icon_urls,omitempty"`
+
+	// Categories is the order of repository categories on the index page, unlisted categories follow alphabetically
+	Categories []string `yaml:"categories,omitempty"`
+
+	// PreviousBuilds is the number of earlier staging builds whose web pages and metadata are published
+	// as read-only snapshots under builds/<build>/, 0 = disabled. Limited by generate.keep_last
+	PreviousBuilds int `yaml:"previous_builds,omitempty"`
 }
 
 // ServeConfig contains HTTP server configuration
 type ServeConfig struct {
 	Host string `yaml:"host,omitempty"` // Host to bind to (default: localhost)
 	Port int    `yaml:"port,omitempty"` // Port to listen on (default: 8080)
+
+	// Ingest watches the downloads directory and links new signed .changes files into trusted storage
+	Ingest bool `yaml:"ingest,omitempty"`
+
+	// Stats counts package downloads per day and shows the most downloaded packages at /stats/
+	// Clients are told apart by a daily rotating salted hash, addresses are never stored
+	Stats bool `yaml:"stats,omitempty"`
+
+	// Auth requires HTTP Basic Auth for all requests but the health file, disabled without username
+	Auth ServeAuthConfig `yaml:"auth,omitempty"`
+
+	// AccessLog logs every request with status, size and duration
+	AccessLog bool `yaml:"access_log,omitempty"`
+
+	// Redirects emulates the pool redirects of pool mode redirect: "redirect" (default) or "proxy"
+	Redirects string `yaml:"redirects,omitempty"`
+}
+
+// Pool redirect emulation of serve mode
+const (
+	ServeRedirectsRedirect = "redirect" // Redirect to the upstream URL like the published build
+	ServeRedirectsProxy    = "proxy"    // Download from the upstream URL and pass the file through
+)
+
+// ServeAuthConfig contains the Basic Auth credentials of serve mode
+type ServeAuthConfig struct {
+	Username string `yaml:"username,omitempty"`
+	Password string `yaml:"password,omitempty"` // Default: AARG_SERVE_PASSWORD environment variable
+}
+
+// WatchConfig contains the webhook listener configuration of the watch command
+type WatchConfig struct {
+	Host string `yaml:"host,omitempty"` // Host to bind to (default: localhost)
+	Port int    `yaml:"port,omitempty"` // Port to listen on (default: 8090)
+
+	// Secret authenticates webhooks, GitHub signs its payloads with it and generic triggers
+	// send it as bearer token (default: AARG_WATCH_SECRET environment variable)
+	Secret string `yaml:"secret,omitempty"`
+
+	// DebounceSeconds waits for further webhooks before building, so a burst of release
+	// events results in a single build (default: 30)
+	DebounceSeconds int `yaml:"debounce_seconds,omitempty"`
 }
 
 // GetIconURLs returns the icon URLs with defaults applied
@@ -150,6 +591,7 @@ func (w *WebConfig) GetIconURLs() map[string]string {
 		"github": "https://cdn.simpleicons.org/github",
 		"apt":    "https://cdn.simpleicons.org/debian",
 		"obs":    "https://cdn.simpleicons.org/opensuse",
+		"ppa":    "https://cdn.simpleicons.org/launchpad",
 	}
 
 	if w.IconURLs == nil {
@@ -165,9 +607,10 @@ func (w *WebConfig) GetIconURLs() map[string]string {
 
 // WorkersConfig defines worker pool sizes
 type WorkersConfig struct {
-	Main        uint `yaml:"main"`
-	Download    uint `yaml:"download"`
-	Compression uint `yaml:"compression"`
+	Main         uint `yaml:"main"`
+	Download     uint `yaml:"download"`
+	Compression  uint `yaml:"compression"`
+	StallTimeout uint `yaml:"stall_timeout"` // Seconds a saturated pool may complete no task before it is reported as stalled
 }
 
 // RepositoryConfig represents a single repository configuration
@@ -178,11 +621,35 @@ type RepositoryConfig struct {
 	// Icon is the name of an icon from simpleicons.org (e.g., "immich") or empty to try repository name
 	// Can be overridden in global config.yaml web.icon_urls to use custom URL
 	Icon                     string                  `yaml:"icon,omitempty"`
+	// Summary is a short plain-text description on the index page, empty = first line of the description
+	Summary                  string                  `yaml:"summary,omitempty"`
+	// Category groups the repository on the index page, empty = uncategorized
+	Category                 string                  `yaml:"category,omitempty"`
+	// Archived lists the repository in the collapsed archived section of the index page
+	Archived                 bool                    `yaml:"archived,omitempty"`
+	// Owners maintain the repository (emails or handles like @org/team), shown on the web page
+	// Notifications about the repository are routed to the webhooks of its owners
+	Owners                   []string                `yaml:"owners,omitempty"`
+	// Schedule is a cron expression of the builds by aarg daemon, e.g. "@hourly" or "30 4 * * *"
+	// Empty = not built by the daemon
+	Schedule                 string                  `yaml:"schedule,omitempty"`
 	common.RepositoryOptions `yaml:",inline"`
 	Verification             VerificationConfig      `yaml:"verification,omitempty"`
+	Upload                   UploadConfig            `yaml:"upload,omitempty"`
 	Feeds                    []*feed.FeedOptions     `yaml:"feeds"`
 }
 
+// UploadConfig configures uploading generated install files as assets of a GitHub release
+type UploadConfig struct {
+	GitHub string `yaml:"github,omitempty"` // Repository in format owner/repo
+	Tag    string `yaml:"tag,omitempty"`    // Tag of the existing release receiving the assets
+}
+
+// IsEnabled reports whether uploading is configured
+func (u UploadConfig) IsEnabled() bool {
+	return u.GitHub != ""
+}
+
 // VerificationConfig contains package verification settings
 type VerificationConfig struct {
 	Keyring string   `yaml:"keyring,omitempty"`
@@ -210,6 +677,18 @@ func (v *VerificationConfig) GetKeyPaths(configDir string) []string {
 	return paths
 }
 
+// DisplayLocation returns the configured time zone of displayed timestamps, UTC if not configured or invalid
+func (c *Config) DisplayLocation() *time.Location {
+	if c.Timezone == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(c.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
 // defaults applies default values to the configuration
 func (c *Config) defaults() {
 	// Load environment variables
@@ -218,6 +697,18 @@ func (c *Config) defaults() {
 			c.GitHub.Token = token
 		}
 	}
+	if c.OBS.Username == "" {
+		c.OBS.Username = os.Getenv("OBS_USERNAME")
+	}
+	if c.OBS.Password == "" {
+		c.OBS.Password = os.Getenv("OBS_PASSWORD")
+	}
+	if c.Watch.Secret == "" {
+		c.Watch.Secret = os.Getenv("AARG_WATCH_SECRET")
+	}
+	if c.Serve.Auth.Username != "" && c.Serve.Auth.Password == "" {
+		c.Serve.Auth.Password = os.Getenv("AARG_SERVE_PASSWORD")
+	}
 
 	// Directories defaults
 	if c.Directories.Root == "" {
@@ -238,6 +729,60 @@ func (c *Config) defaults() {
 	if c.Directories.Public == "" {
 		c.Directories.Public = "public"
 	}
+	if c.Directories.Cache == "" {
+		c.Directories.Cache = "cache"
+	}
+
+	// Plugin commands with a path are relative to the config dir, bare names are looked up in PATH
+	for name, command := range c.Plugins {
+		if strings.ContainsRune(command.Command, filepath.Separator) && !filepath.IsAbs(command.Command) {
+			command.Command = filepath.Join(c.ConfigDir, command.Command)
+			c.Plugins[name] = command
+		}
+	}
+
+	// Directory publishing defaults
+	if c.Publish.Directory.Mode == "" {
+		c.Publish.Directory.Mode = "hardlink"
+	}
+	if c.Publish.Directory.Keep == 0 {
+		c.Publish.Directory.Keep = 2
+	}
+
+	// Storage defaults
+	if c.Storage.Backend == "" {
+		c.Storage.Backend = common.MetadataBackendFiles
+	}
+	if c.Storage.Database == "" {
+		c.Storage.Database = "metadata.db"
+	}
+
+	// Preflight defaults
+	if c.Preflight.MinFreeMB == 0 {
+		c.Preflight.MinFreeMB = 1024
+	}
+	if c.Preflight.MarginPercent == 0 {
+		c.Preflight.MarginPercent = 20
+	}
+
+	// Tracing defaults
+	if c.Tracing.ServiceName == "" {
+		c.Tracing.ServiceName = "aarg"
+	}
+
+	// Cloudflare defaults
+	if c.Cloudflare.ProductionBranch == "" {
+		c.Cloudflare.ProductionBranch = "main"
+	}
+	if c.Cloudflare.StagingBranch == "" {
+		c.Cloudflare.StagingBranch = "staging"
+	}
+	if c.Cloudflare.Concurrency == 0 {
+		c.Cloudflare.Concurrency = 3
+	}
+	if c.Cloudflare.RequestsPerMinute == 0 {
+		c.Cloudflare.RequestsPerMinute = 200
+	}
 
 	// Worker pool defaults
 	if c.Workers.Main == 0 {
@@ -253,6 +798,9 @@ func (c *Config) defaults() {
 	if c.Workers.Compression == 0 {
 		c.Workers.Compression = uint(runtime.NumCPU())
 	}
+	if c.Workers.StallTimeout == 0 {
+		c.Workers.StallTimeout = 120
+	}
 
 	// Generate defaults
 	if c.Generate.PoolMode == "" {
@@ -264,6 +812,9 @@ func (c *Config) defaults() {
 	if c.Generate.KeepLast == 0 {
 		c.Generate.KeepLast = 5
 	}
+	if c.Generate.VerifyPoolSample == 0 {
+		c.Generate.VerifyPoolSample = 10
+	}
 }
 
 // loadRepositories loads all repository configurations from the repositories directory
@@ -311,6 +862,18 @@ func (c *Config) loadRepositories() error {
 		}
 		repo.Name = repoName
 
+		// Local manifests are relative to the config dir like plugin commands
+		for _, feedOpts := range repo.Feeds {
+			if feedOpts.Type == feed.FeedTypeManifest && !strings.Contains(feedOpts.Manifest, "://") && !filepath.IsAbs(feedOpts.Manifest) {
+				feedOpts.Manifest = filepath.Join(c.ConfigDir, feedOpts.Manifest)
+			}
+
+			// Resolved before expansion, expanded feeds are named after their download URL
+			if repo.ComponentPerFeed && feedOpts.TargetComponent == "" {
+				feedOpts.TargetComponent = feedOpts.DefaultComponent()
+			}
+		}
+
 		repos = append(repos, &repo)
 	}
 
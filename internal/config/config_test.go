@@ -138,6 +138,44 @@ func TestDirectoriesConfig_GetPublicPath(t *testing.T) {
 	}
 }
 
+func TestDirectoriesConfig_GetPublicStagingPath(t *testing.T) {
+	tests := []struct {
+		name          string
+		root          string
+		publicStaging string
+		want          string
+	}{
+		{
+			name:          "not configured",
+			root:          "/var/lib/aarg",
+			publicStaging: "",
+			want:          "",
+		},
+		{
+			name:          "absolute path",
+			root:          "/var/lib/aarg",
+			publicStaging: "/var/www/public-staging",
+			want:          "/var/www/public-staging",
+		},
+		{
+			name:          "relative path",
+			root:          "/var/lib/aarg",
+			publicStaging: "public-staging",
+			want:          "/var/lib/aarg/public-staging",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &DirectoriesConfig{
+				Root:          tt.root,
+				PublicStaging: tt.publicStaging,
+			}
+			assert.Equal(t, tt.want, d.GetPublicStagingPath())
+		})
+	}
+}
+
 func TestSigningConfig_GetPrivateKeyPath(t *testing.T) {
 	tests := []struct {
 		name       string
@@ -225,6 +263,7 @@ func TestWebConfig_GetIconURLs(t *testing.T) {
 				"github": "https://cdn.simpleicons.org/github",
 				"apt":    "https://cdn.simpleicons.org/debian",
 				"obs":    "https://cdn.simpleicons.org/opensuse",
+				"ppa":    "https://cdn.simpleicons.org/launchpad",
 			},
 		},
 		{
@@ -234,6 +273,7 @@ func TestWebConfig_GetIconURLs(t *testing.T) {
 				"github": "https://cdn.simpleicons.org/github",
 				"apt":    "https://cdn.simpleicons.org/debian",
 				"obs":    "https://cdn.simpleicons.org/opensuse",
+				"ppa":    "https://cdn.simpleicons.org/launchpad",
 			},
 		},
 		{
@@ -245,6 +285,7 @@ func TestWebConfig_GetIconURLs(t *testing.T) {
 				"github": "https://example.com/github.svg",
 				"apt":    "https://cdn.simpleicons.org/debian",
 				"obs":    "https://cdn.simpleicons.org/opensuse",
+				"ppa":    "https://cdn.simpleicons.org/launchpad",
 			},
 		},
 		{
@@ -256,6 +297,7 @@ func TestWebConfig_GetIconURLs(t *testing.T) {
 				"github": "https://cdn.simpleicons.org/github",
 				"apt":    "https://cdn.simpleicons.org/debian",
 				"obs":    "https://cdn.simpleicons.org/opensuse",
+				"ppa":    "https://cdn.simpleicons.org/launchpad",
 				"custom": "https://example.com/custom.svg",
 			},
 		},
@@ -351,6 +393,80 @@ func TestVerificationConfig_GetKeyPaths(t *testing.T) {
 	}
 }
 
+func TestNotificationsConfig_Route(t *testing.T) {
+	notifications := NotificationsConfig{
+		Webhook: "https://hooks.example.com/all",
+		Owners: map[string]string{
+			"@org/web":        "https://hooks.example.com/web",
+			"ops@example.com": "https://hooks.example.com/ops",
+			"@org/web-admins": "https://hooks.example.com/web",
+		},
+	}
+
+	tests := []struct {
+		name   string
+		owners []string
+		want   []string
+	}{
+		{"no owners", nil, []string{"https://hooks.example.com/all"}},
+		{"owner without webhook", []string{"@someone"}, []string{"https://hooks.example.com/all"}},
+		{"owner with webhook", []string{"@someone", "@org/web"}, []string{"https://hooks.example.com/web"}},
+		{"several owners", []string{"ops@example.com", "@org/web"}, []string{"https://hooks.example.com/ops", "https://hooks.example.com/web"}},
+		{"shared webhook once", []string{"@org/web", "@org/web-admins"}, []string{"https://hooks.example.com/web"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, notifications.Route(tt.owners))
+		})
+	}
+
+	assert.Nil(t, NotificationsConfig{}.Route([]string{"@org/web"}))
+	assert.False(t, NotificationsConfig{}.IsEnabled())
+}
+
+func TestPublishConfig_ProviderNames(t *testing.T) {
+	disabled := false
+
+	tests := []struct {
+		name    string
+		publish PublishConfig
+		want    []string
+	}{
+		{"cloudflare by default", PublishConfig{}, []string{ProviderCloudflare}},
+		{"plugin replaces cloudflare", PublishConfig{Plugin: "s3"}, []string{"s3"}},
+		{"directory replaces cloudflare", PublishConfig{Directory: DirectoryConfig{Path: "/srv/www/apt"}}, []string{ProviderDirectory}},
+		{"providers in order", PublishConfig{Providers: []ProviderConfig{{Name: "s3"}, {Name: ProviderCloudflare}}}, []string{"s3", ProviderCloudflare}},
+		{"disabled providers skipped", PublishConfig{Providers: []ProviderConfig{{Name: ProviderCloudflare}, {Name: "s3", Enabled: &disabled}}}, []string{ProviderCloudflare}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.publish.ProviderNames())
+		})
+	}
+}
+
+func TestTracingConfig_GetSampleRatio(t *testing.T) {
+	zero, half := 0.0, 0.5
+
+	tests := []struct {
+		name    string
+		tracing TracingConfig
+		want    float64
+	}{
+		{"all by default", TracingConfig{}, 1},
+		{"none", TracingConfig{SampleRatio: &zero}, 0},
+		{"fraction", TracingConfig{SampleRatio: &half}, 0.5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.tracing.GetSampleRatio())
+		})
+	}
+}
+
 func TestConfig_defaults(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -367,6 +483,7 @@ func TestConfig_defaults(t *testing.T) {
 				assert.Equal(t, "trusted", c.Directories.Trusted)
 				assert.Equal(t, "staging", c.Directories.Staging)
 				assert.Equal(t, "public", c.Directories.Public)
+				assert.Equal(t, "cache", c.Directories.Cache)
 			},
 		},
 		{
@@ -376,6 +493,7 @@ func TestConfig_defaults(t *testing.T) {
 				assert.Equal(t, uint(runtime.NumCPU()*10), c.Workers.Main)
 				assert.Equal(t, uint(20), c.Workers.Download)
 				assert.Equal(t, uint(runtime.NumCPU()), c.Workers.Compression)
+				assert.Equal(t, uint(120), c.Workers.StallTimeout)
 			},
 		},
 		{
@@ -385,6 +503,45 @@ func TestConfig_defaults(t *testing.T) {
 				assert.Equal(t, "hierarchical", c.Generate.PoolMode)
 				assert.Equal(t, []string{"apt"}, c.Generate.Compose)
 				assert.Equal(t, 5, c.Generate.KeepLast)
+				assert.Equal(t, 10, c.Generate.VerifyPoolSample)
+			},
+		},
+		{
+			name: "applies preflight defaults",
+			cfg:  &Config{},
+			checkFn: func(t *testing.T, c *Config) {
+				assert.Equal(t, uint64(1024), c.Preflight.MinFreeMB)
+				assert.Equal(t, uint64(20), c.Preflight.MarginPercent)
+				assert.Equal(t, uint64(1024*1024*1024), c.Preflight.MinFreeBytes())
+				assert.Equal(t, uint64(120), c.Preflight.WithMargin(100))
+			},
+		},
+		{
+			name: "applies storage defaults",
+			cfg:  &Config{Directories: DirectoriesConfig{Root: "/srv/aarg", Cache: "cache"}},
+			checkFn: func(t *testing.T, c *Config) {
+				assert.Equal(t, "files", c.Storage.Backend)
+				assert.Equal(t, "metadata.db", c.Storage.Database)
+				assert.Equal(t, "/srv/aarg/cache/metadata.db", c.Storage.GetDatabasePath(c.Directories.GetCachePath()))
+			},
+		},
+		{
+			name: "applies tracing defaults",
+			cfg:  &Config{},
+			checkFn: func(t *testing.T, c *Config) {
+				assert.Equal(t, "aarg", c.Tracing.ServiceName)
+				assert.Nil(t, c.Tracing.SampleRatio)
+				assert.Equal(t, 1.0, c.Tracing.GetSampleRatio())
+			},
+		},
+		{
+			name: "applies cloudflare defaults",
+			cfg:  &Config{},
+			checkFn: func(t *testing.T, c *Config) {
+				assert.Equal(t, "main", c.Cloudflare.ProductionBranch)
+				assert.Equal(t, "staging", c.Cloudflare.StagingBranch)
+				assert.Equal(t, 3, c.Cloudflare.Concurrency)
+				assert.Equal(t, 200, c.Cloudflare.RequestsPerMinute)
 			},
 		},
 		{
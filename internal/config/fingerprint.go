@@ -0,0 +1,52 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"slices"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Fingerprint returns a short hash of the effective configuration including all repositories
+// Secrets and settings not affecting the generated tree (serve, watch, fetch, storage, workers, tracing, notifications) are left out,
+// so the fingerprint only changes with the configuration producing the published tree
+func (c *Config) Fingerprint() (string, error) {
+	effective := *c
+	effective.Signing.Passphrase = ""
+	effective.Signing.Additional = slices.Clone(c.Signing.Additional)
+	for i := range effective.Signing.Additional {
+		effective.Signing.Additional[i].Passphrase = ""
+	}
+	effective.Signing.Sigstore.Passphrase = ""
+	effective.GitHub.Token = ""
+	effective.OBS = OBSConfig{}
+	effective.Cloudflare.APIToken = ""
+	effective.Serve = ServeConfig{}
+	effective.Watch = WatchConfig{}
+	effective.Fetch = FetchConfig{}
+	effective.Storage = StorageConfig{}
+	effective.Workers = WorkersConfig{}
+	effective.Tracing = TracingConfig{}
+	effective.Notifications = NotificationsConfig{}
+	effective.Repositories = nil
+
+	hasher := sha256.New()
+	if err := yaml.NewEncoder(hasher).Encode(effective); err != nil {
+		return "", err
+	}
+
+	// Repository names are derived from their filenames and not part of their YAML
+	for _, repo := range c.Repositories {
+		_, _ = fmt.Fprintf(hasher, "repository %s\n", repo.Name)
+		// When the daemon builds a repository doesn't change what is built
+		scheduled := *repo
+		scheduled.Schedule = ""
+		if err := yaml.NewEncoder(hasher).Encode(scheduled); err != nil {
+			return "", err
+		}
+	}
+
+	return hex.EncodeToString(hasher.Sum(nil))[:12], nil
+}
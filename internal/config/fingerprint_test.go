@@ -0,0 +1,52 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/dionysius/aarg/internal/feed"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_Fingerprint(t *testing.T) {
+	newConfig := func() *Config {
+		return &Config{
+			Generate: GenerateConfig{PoolMode: "hierarchical"},
+			Repositories: []*RepositoryConfig{
+				{Name: "test", Feeds: []*feed.FeedOptions{{Type: "github", Name: "owner/repo"}}},
+			},
+		}
+	}
+	base, err := newConfig().Fingerprint()
+	require.NoError(t, err)
+	assert.Len(t, base, 12)
+
+	tests := []struct {
+		name    string
+		modify  func(c *Config)
+		changed bool
+	}{
+		{name: "identical", modify: func(c *Config) {}, changed: false},
+		{name: "secret", modify: func(c *Config) { c.Cloudflare.APIToken = "token" }, changed: false},
+		{name: "watch", modify: func(c *Config) { c.Watch = WatchConfig{Port: 9000, Secret: "secret"} }, changed: false},
+		{name: "storage", modify: func(c *Config) { c.Storage = StorageConfig{Backend: "sqlite"} }, changed: false},
+		{name: "schedule", modify: func(c *Config) { c.Repositories[0].Schedule = "@hourly" }, changed: false},
+		{name: "workers", modify: func(c *Config) { c.Workers.Main = 200 }, changed: false},
+		{name: "generate setting", modify: func(c *Config) { c.Generate.PoolMode = "redirect" }, changed: true},
+		{name: "repository name", modify: func(c *Config) { c.Repositories[0].Name = "other" }, changed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newConfig()
+			tt.modify(c)
+			fingerprint, err := c.Fingerprint()
+			require.NoError(t, err)
+			if tt.changed {
+				assert.NotEqual(t, base, fingerprint)
+			} else {
+				assert.Equal(t, base, fingerprint)
+			}
+		})
+	}
+}
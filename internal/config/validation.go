@@ -3,22 +3,44 @@ package config
 import (
 	"errors"
 	"fmt"
+	"maps"
 	"net/url"
+	"path/filepath"
 	"regexp"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
 
+	"github.com/dionysius/aarg/internal/common"
 	"github.com/dionysius/aarg/internal/feed"
 )
 
 // reservedRepoNames contains repository names that cannot be used
 // because they conflict with system paths or special directories
 var reservedRepoNames = map[string]bool{
-	"assets": true, // Web composer static assets directory (css, icons, etc.)
-	"keys":   true, // Repository public keys
+	"assets":  true, // Web composer static assets directory (css, icons, etc.)
+	"keys":    true, // Repository public keys
+	"builds":  true, // Snapshots of previous builds
+	"exports": true, // Exports composer package availability matrix
+	"stats":   true, // Download statistics page of serve mode
 }
 
 // repoNamePattern matches valid repository names (alphanumeric, dash, underscore)
 var repoNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
 
+// signingKeyPattern matches long key IDs and v4 or v5 fingerprints, short key IDs are too easy to collide
+var signingKeyPattern = regexp.MustCompile(`^(0[xX])?([0-9a-fA-F]{16}|[0-9a-fA-F]{40}|[0-9a-fA-F]{64})$`)
+
+// projectNamePattern matches valid Cloudflare Pages project names
+var projectNamePattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,56}[a-z0-9])?$`)
+
+// componentPattern matches valid APT component names
+var componentPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9.+-]*$`)
+
+// routeSuffixPattern matches valid distribution suffixes of release routes
+var routeSuffixPattern = regexp.MustCompile(`^[a-zA-Z0-9._+-]+$`)
+
 // Validation errors
 var (
 	ErrNoRepositories         = errors.New("no repositories configured")
@@ -34,8 +56,43 @@ var (
 	ErrFeedLocationScheme     = errors.New("feed location should not include URL scheme")
 	ErrFeedLocationQuery      = errors.New("feed location cannot contain query strings")
 	ErrFeedLocationFragment   = errors.New("feed location cannot contain fragments")
+	ErrFeedTimeoutNegative    = errors.New("feed timeout must not be negative")
 	ErrPoolModeInvalid        = errors.New("pool mode must be either 'hierarchical' or 'redirect'")
+	ErrTimezoneInvalid        = errors.New("timezone must be an IANA time zone name like Europe/Zurich or UTC")
 	ErrNoChangesRequiresDist  = errors.New("no_changes requires distribution mappings to be configured")
+	ErrComponentsNotSupported = errors.New("components are only supported for apt and obs feeds")
+	ErrLinkInvalid            = errors.New("link must be an absolute http or https URL")
+	ErrReleaseInvalid         = errors.New("invalid release metadata")
+	ErrRoutesNotSupported     = errors.New("routes are only supported for github feeds")
+	ErrRouteInvalid           = errors.New("invalid release route")
+	ErrDraftRequiresToken     = errors.New("draft releases require a github token with push access")
+	ErrDraftRequiresPool      = errors.New("draft releases require pool mode 'hierarchical' since their assets are not public")
+	ErrUploadInvalid          = errors.New("invalid upload configuration")
+	ErrUploadRequiresToken    = errors.New("upload requires a github token with push access")
+	ErrPolicyInvalid          = errors.New("invalid repository policy")
+	ErrExcludeAssetsInvalid   = errors.New("exclude_assets is only supported for github feeds and requires valid glob patterns")
+	ErrChecksumsInvalid       = errors.New("checksums is only supported for github feeds in no_changes mode and requires valid glob patterns")
+	ErrPluginInvalid          = errors.New("invalid plugin configuration")
+	ErrPluginNotConfigured    = errors.New("plugin not configured")
+	ErrPluginRequiresPool     = errors.New("plugin feeds require pool mode 'hierarchical' since their files have no upstream URL")
+	ErrManifestRequiresPool   = errors.New("manifest feeds require pool mode 'hierarchical' since their files have no common upstream URL")
+	ErrProjectInvalid         = errors.New("invalid cloudflare pages project")
+	ErrDirectoryInvalid       = errors.New("invalid publish directory configuration")
+	ErrNotificationsInvalid   = errors.New("invalid notifications configuration")
+	ErrOwnersInvalid          = errors.New("owners must be non-empty emails or handles without whitespace")
+	ErrSourceOnlyRequiresSrc  = errors.New("source_only requires source packages, set packages.source to true")
+	ErrDebuginfodRequiresDbg  = errors.New("debuginfod requires debug packages, set packages.debug to true")
+	ErrAlwaysEmitRequiresDbg  = errors.New("always_emit_debug requires debug packages, set packages.debug to true")
+	ErrSigningKeysInvalid     = errors.New("signing_keys and key_change are only supported for apt and obs feeds and require fingerprints or long key IDs")
+	ErrOBSAPIUnsupported      = errors.New("obs_api fetches without repository metadata, signing_keys, key_change and components are not supported")
+	ErrTargetComponentInvalid = errors.New("invalid target component")
+	ErrSnapshotsInvalid       = errors.New("invalid snapshots configuration")
+	ErrSnapshotsRequirePool   = errors.New("snapshots require pool mode 'hierarchical' since redirected files are not part of them")
+	ErrSigningInvalid         = errors.New("invalid signing configuration")
+	ErrProvidersInvalid       = errors.New("invalid publish providers")
+	ErrPinInvalid             = errors.New("pin requires non-empty versions")
+	ErrServeAuthInvalid       = errors.New("serve auth requires username and password")
+	ErrServeRedirectsInvalid  = errors.New("serve redirects must be either 'redirect' or 'proxy'")
 )
 
 // validate performs validation on the loaded configuration
@@ -54,11 +111,128 @@ func validate(cfg *Config) error {
 		}
 	}
 
+	// Validate display time zone
+	if cfg.Timezone != "" {
+		if _, err := time.LoadLocation(cfg.Timezone); err != nil || cfg.Timezone == "Local" {
+			return fmt.Errorf("%w: %q", ErrTimezoneInvalid, cfg.Timezone)
+		}
+	}
+
+	// Validate signing keys
+	if err := validateSigning(&cfg.Signing); err != nil {
+		return err
+	}
+
 	// Validate pool mode
 	if cfg.Generate.PoolMode != "hierarchical" && cfg.Generate.PoolMode != "redirect" {
 		return fmt.Errorf("%w: %q", ErrPoolModeInvalid, cfg.Generate.PoolMode)
 	}
 
+	// Validate downloads cache policy
+	if cfg.GC.Downloads.MaxAgeDays < 0 {
+		return fmt.Errorf("gc downloads max_age_days must not be negative")
+	}
+
+	// Validate bandwidth limits
+	if cfg.HTTP.MaxBytesPerSecond < 0 || cfg.HTTP.MaxBytesPerSecondHost < 0 {
+		return fmt.Errorf("http max_bytes_per_second and max_bytes_per_second_per_host must not be negative")
+	}
+
+	// Validate metadata store
+	if cfg.Storage.Backend != "" && !slices.Contains(common.MetadataBackends, cfg.Storage.Backend) {
+		return fmt.Errorf("storage backend must be one of %v, got %q", common.MetadataBackends, cfg.Storage.Backend)
+	}
+
+	// Validate health threshold
+	if cfg.Generate.HealthMaxAgeHours < 0 {
+		return fmt.Errorf("generate health_max_age_hours must not be negative")
+	}
+
+	// Validate feed timeout
+	if cfg.Fetch.FeedTimeout < 0 {
+		return fmt.Errorf("fetch feed_timeout must not be negative")
+	}
+
+	// Validate removal guard
+	if cfg.Publish.MaxRemoved < 0 {
+		return fmt.Errorf("publish max_removed must not be negative")
+	}
+	if cfg.Publish.MaxRemovedPercent < 0 || cfg.Publish.MaxRemovedPercent > 100 {
+		return fmt.Errorf("publish max_removed_percent must be between 0 and 100")
+	}
+
+	// Validate Cloudflare API budget
+	if cfg.Cloudflare.Concurrency < 0 {
+		return fmt.Errorf("cloudflare concurrency must not be negative")
+	}
+	if cfg.Cloudflare.RequestsPerMinute < 0 {
+		return fmt.Errorf("cloudflare requests_per_minute must not be negative")
+	}
+	if cfg.Cloudflare.ManageDomain && cfg.URL == "" {
+		return fmt.Errorf("cloudflare manage_domain requires url to be set")
+	}
+
+	// Validate pdiff history
+	if cfg.Generate.PDiffHistory < 0 {
+		return fmt.Errorf("generate pdiff_history must not be negative")
+	}
+
+	// Validate previous builds
+	if cfg.Web.PreviousBuilds < 0 {
+		return fmt.Errorf("web previous_builds must not be negative")
+	}
+
+	// Validate tracing
+	if ratio := cfg.Tracing.GetSampleRatio(); ratio < 0 || ratio > 1 {
+		return fmt.Errorf("tracing sample_ratio must be between 0 and 1")
+	}
+
+	// Validate compression levels
+	for format, level := range cfg.Generate.Compression.Levels() {
+		if err := common.ValidateCompressionLevel(format, level); err != nil {
+			return fmt.Errorf("generate compression: %w", err)
+		}
+	}
+
+	// Validate precompression encodings
+	for _, encoding := range cfg.Generate.Precompress {
+		if encoding != "gzip" && encoding != "brotli" {
+			return fmt.Errorf("generate precompress must list 'gzip' or 'brotli', got %q", encoding)
+		}
+	}
+
+	// Validate pool verification
+	if err := ValidateVerifyPool(cfg.Generate.VerifyPool); err != nil {
+		return fmt.Errorf("generate verify_pool: %w", err)
+	}
+	if cfg.Generate.VerifyPoolSample < 0 || cfg.Generate.VerifyPoolSample > 100 {
+		return fmt.Errorf("generate verify_pool_sample must be between 0 and 100")
+	}
+
+	// Validate plugins
+	for name, command := range cfg.Plugins {
+		if !repoNamePattern.MatchString(name) {
+			return fmt.Errorf("%w: name %q must contain only letters, digits, dashes and underscores", ErrPluginInvalid, name)
+		}
+		if command.Command == "" {
+			return fmt.Errorf("%w: %s: command is required", ErrPluginInvalid, name)
+		}
+		if name == ProviderCloudflare || name == ProviderDirectory {
+			return fmt.Errorf("%w: name %q is reserved for a built-in provider", ErrPluginInvalid, name)
+		}
+	}
+	if cfg.Publish.Plugin != "" {
+		if _, ok := cfg.Plugins[cfg.Publish.Plugin]; !ok {
+			return fmt.Errorf("publish: %w: %s", ErrPluginNotConfigured, cfg.Publish.Plugin)
+		}
+	}
+	if err := validatePublishDirectory(cfg); err != nil {
+		return err
+	}
+	if err := validatePublishProviders(cfg); err != nil {
+		return err
+	}
+
 	// Validate repositories
 	if len(cfg.Repositories) == 0 {
 		return ErrNoRepositories
@@ -70,6 +244,210 @@ func validate(cfg *Config) error {
 		}
 	}
 
+	if err := validateRepositoryProjects(cfg); err != nil {
+		return err
+	}
+
+	if err := validateNotifications(cfg); err != nil {
+		return err
+	}
+
+	// A password without username would silently serve without authentication
+	if (cfg.Serve.Auth.Username == "") != (cfg.Serve.Auth.Password == "") {
+		return ErrServeAuthInvalid
+	}
+	switch cfg.Serve.Redirects {
+	case "", ServeRedirectsRedirect, ServeRedirectsProxy:
+	default:
+		return fmt.Errorf("%w: %q", ErrServeRedirectsInvalid, cfg.Serve.Redirects)
+	}
+
+	// Draft release assets are only reachable through the authenticated API
+	for _, repo := range cfg.Repositories {
+		for _, feedOpts := range repo.Feeds {
+			if feed.FeedType(feedOpts.Type) != feed.FeedTypeGitHub || !slices.Contains(feedOpts.Releases, feed.ReleaseTypeDraft) {
+				continue
+			}
+			if cfg.GitHub.Token == "" {
+				return fmt.Errorf("repository %s: %w: %s", repo.Name, ErrDraftRequiresToken, feedOpts.Name)
+			}
+			if cfg.Generate.PoolMode != "hierarchical" {
+				return fmt.Errorf("repository %s: %w: %s", repo.Name, ErrDraftRequiresPool, feedOpts.Name)
+			}
+		}
+	}
+
+	// Release assets can only be uploaded through the authenticated API
+	for _, repo := range cfg.Repositories {
+		if repo.Upload.IsEnabled() && cfg.GitHub.Token == "" {
+			return fmt.Errorf("repository %s: %w: %s", repo.Name, ErrUploadRequiresToken, repo.Upload.GitHub)
+		}
+	}
+
+	// Plugin feeds reference plugins of the application config
+	for _, repo := range cfg.Repositories {
+		for _, feedOpts := range repo.Feeds {
+			if feed.FeedType(feedOpts.Type) != feed.FeedTypePlugin {
+				continue
+			}
+			if _, ok := cfg.Plugins[feedOpts.Plugin]; !ok {
+				return fmt.Errorf("repository %s: %w: %s", repo.Name, ErrPluginNotConfigured, feedOpts.Plugin)
+			}
+			if cfg.Generate.PoolMode != "hierarchical" {
+				return fmt.Errorf("repository %s: %w: %s", repo.Name, ErrPluginRequiresPool, feedOpts.Name)
+			}
+		}
+	}
+
+	// Snapshots keep the pool files referenced by their indexes
+	for _, repo := range cfg.Repositories {
+		if repo.Snapshots.Keep > 0 && cfg.Generate.PoolMode != "hierarchical" {
+			return fmt.Errorf("repository %s: %w", repo.Name, ErrSnapshotsRequirePool)
+		}
+	}
+
+	// Manifest files are fetched from arbitrary URLs which can't be redirected to by suffix
+	for _, repo := range cfg.Repositories {
+		for _, feedOpts := range repo.Feeds {
+			if feed.FeedType(feedOpts.Type) == feed.FeedTypeManifest && cfg.Generate.PoolMode != "hierarchical" {
+				return fmt.Errorf("repository %s: %w: %s", repo.Name, ErrManifestRequiresPool, feedOpts.Name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateNotifications validates the webhooks and that routed owners are owners of a repository
+func validateNotifications(cfg *Config) error {
+	webhooks := map[string]string{"webhook": cfg.Notifications.Webhook}
+	for owner, webhook := range cfg.Notifications.Owners {
+		webhooks["owner "+owner] = webhook
+	}
+	for name, webhook := range webhooks {
+		if webhook == "" && name == "webhook" {
+			continue
+		}
+		u, err := url.Parse(webhook)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("%w: %s must be an http or https URL", ErrNotificationsInvalid, name)
+		}
+	}
+
+	// A misspelled owner would silently send notifications to the default webhook
+	for owner := range cfg.Notifications.Owners {
+		owned := slices.ContainsFunc(cfg.Repositories, func(repo *RepositoryConfig) bool {
+			return slices.Contains(repo.Owners, owner)
+		})
+		if !owned {
+			return fmt.Errorf("%w: owner %q is not an owner of any repository", ErrNotificationsInvalid, owner)
+		}
+	}
+
+	return nil
+}
+
+// validatePublishDirectory validates publishing into a local directory
+func validatePublishDirectory(cfg *Config) error {
+	dir := cfg.Publish.Directory
+	if dir.Path == "" {
+		if dir.StagingPath != "" {
+			return fmt.Errorf("%w: staging_path requires path", ErrDirectoryInvalid)
+		}
+		return nil
+	}
+
+	if cfg.Publish.Plugin != "" {
+		return fmt.Errorf("%w: cannot be combined with a publish plugin", ErrDirectoryInvalid)
+	}
+	// With publish providers, repository and canary projects only apply to Cloudflare Pages
+	if len(cfg.Publish.Providers) == 0 && (cfg.Cloudflare.RepositoryProject != "" || cfg.Cloudflare.CanaryProject != "") {
+		return fmt.Errorf("%w: repository_project and canary_project are only supported with Cloudflare Pages", ErrDirectoryInvalid)
+	}
+	if dir.Mode != "hardlink" && dir.Mode != "copy" {
+		return fmt.Errorf("%w: mode must be either 'hardlink' or 'copy', got %q", ErrDirectoryInvalid, dir.Mode)
+	}
+	if dir.Keep < 0 {
+		return fmt.Errorf("%w: keep must not be negative", ErrDirectoryInvalid)
+	}
+	if cfg.Directories.PublicStaging != "" && dir.StagingPath == "" {
+		return fmt.Errorf("%w: staging_path is required with public_staging", ErrDirectoryInvalid)
+	}
+	if dir.StagingPath != "" && dir.GetPath(cfg.Directories.Root, false) == dir.GetPath(cfg.Directories.Root, true) {
+		return fmt.Errorf("%w: staging_path must differ from path", ErrDirectoryInvalid)
+	}
+	// Pool redirects are written as Cloudflare _redirects file, other web servers ignore it
+	if cfg.Generate.PoolMode != "hierarchical" {
+		return fmt.Errorf("%w: requires pool mode 'hierarchical' since web servers don't read _redirects", ErrDirectoryInvalid)
+	}
+
+	return nil
+}
+
+// validatePublishProviders validates publishing to several providers
+func validatePublishProviders(cfg *Config) error {
+	if len(cfg.Publish.Providers) == 0 {
+		return nil
+	}
+	if cfg.Publish.Plugin != "" {
+		return fmt.Errorf("%w: plugin cannot be combined with providers, list the plugin as provider instead", ErrProvidersInvalid)
+	}
+
+	seen := make(map[string]bool, len(cfg.Publish.Providers))
+	for _, prov := range cfg.Publish.Providers {
+		switch prov.Name {
+		case "":
+			return fmt.Errorf("%w: name is required", ErrProvidersInvalid)
+		case ProviderCloudflare:
+		case ProviderDirectory:
+			if cfg.Publish.Directory.Path == "" {
+				return fmt.Errorf("%w: directory requires publish directory path", ErrProvidersInvalid)
+			}
+		default:
+			if _, ok := cfg.Plugins[prov.Name]; !ok {
+				return fmt.Errorf("publish providers: %w: %s", ErrPluginNotConfigured, prov.Name)
+			}
+		}
+		if seen[prov.Name] {
+			return fmt.Errorf("%w: %s is listed more than once", ErrProvidersInvalid, prov.Name)
+		}
+		seen[prov.Name] = true
+	}
+
+	if len(cfg.Publish.ProviderNames()) == 0 {
+		return fmt.Errorf("%w: no provider enabled", ErrProvidersInvalid)
+	}
+	return nil
+}
+
+// validateRepositoryProjects validates the Pages projects of repositories split into their own projects
+func validateRepositoryProjects(cfg *Config) error {
+	if cfg.Cloudflare.RepositoryProject == "" {
+		return nil
+	}
+	if cfg.Publish.Plugin != "" {
+		return fmt.Errorf("%w: repository_project is not supported with a publish plugin", ErrProjectInvalid)
+	}
+
+	// Every repository needs its own project, separate from the shared and canary projects
+	projects := map[string]string{
+		cfg.Cloudflare.ProjectName:   "project_name",
+		cfg.Cloudflare.CanaryProject: "canary_project",
+	}
+	for _, repo := range cfg.Repositories {
+		project, err := cfg.Cloudflare.RepositoryProjectName(repo.Name)
+		if err != nil {
+			return fmt.Errorf("%w: repository_project: %w", ErrProjectInvalid, err)
+		}
+		if !projectNamePattern.MatchString(project) {
+			return fmt.Errorf("%w: %q of repository %s must be lowercase letters, digits and dashes", ErrProjectInvalid, project, repo.Name)
+		}
+		if other, exists := projects[project]; exists {
+			return fmt.Errorf("%w: %q of repository %s is also used by %s", ErrProjectInvalid, project, repo.Name, other)
+		}
+		projects[project] = "repository " + repo.Name
+	}
+
 	return nil
 }
 
@@ -90,6 +468,92 @@ func validateRepository(repo *RepositoryConfig) error {
 		return fmt.Errorf("%w: %q (must contain only letters, numbers, dashes, and underscores)", ErrRepositoryNameInvalid, repo.Name)
 	}
 
+	// Validate links
+	for field, link := range repo.Links.ReleaseFields() {
+		u, err := url.Parse(link)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return fmt.Errorf("%w: %s %q", ErrLinkInvalid, field, link)
+		}
+	}
+
+	// Validate release metadata templates by rendering them for every overridden distribution
+	for _, dist := range append([]string{""}, slices.Sorted(maps.Keys(repo.Release.Distributions))...) {
+		metadata, err := repo.Release.Resolve(repo.Name, dist)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrReleaseInvalid, err)
+		}
+		if strings.ContainsAny(metadata.Origin+metadata.Label+metadata.Suite, "\n\r") {
+			return fmt.Errorf("%w: values must be single line", ErrReleaseInvalid)
+		}
+	}
+
+	// Validate upload target
+	if repo.Upload.IsEnabled() || repo.Upload.Tag != "" {
+		if parts := strings.Split(repo.Upload.GitHub, "/"); len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return fmt.Errorf("%w: github must be in format owner/repo, got %q", ErrUploadInvalid, repo.Upload.GitHub)
+		}
+		if repo.Upload.Tag == "" {
+			return fmt.Errorf("%w: tag is required", ErrUploadInvalid)
+		}
+	}
+
+	// Validate package options
+	if repo.Packages.SourceOnly && !repo.Packages.Source {
+		return ErrSourceOnlyRequiresSrc
+	}
+	if repo.Packages.Debuginfod && !repo.Packages.Debug {
+		return ErrDebuginfodRequiresDbg
+	}
+	if repo.Packages.AlwaysEmitDebug && !repo.Packages.Debug {
+		return ErrAlwaysEmitRequiresDbg
+	}
+
+	// Validate schedule
+	if repo.Schedule != "" {
+		if _, err := common.ParseSchedule(repo.Schedule); err != nil {
+			return err
+		}
+	}
+
+	// Validate owners
+	for _, owner := range repo.Owners {
+		if owner == "" || strings.ContainsFunc(owner, unicode.IsSpace) {
+			return fmt.Errorf("%w: %q", ErrOwnersInvalid, owner)
+		}
+	}
+
+	// Validate policy
+	if repo.Policy.MaxFileSizeMB < 0 || repo.Policy.MaxPackagesPerSource < 0 {
+		return fmt.Errorf("%w: limits must not be negative", ErrPolicyInvalid)
+	}
+	switch repo.Policy.GetAction() {
+	case common.PolicyActionWarn, common.PolicyActionSkip, common.PolicyActionFail:
+	default:
+		return fmt.Errorf("%w: action must be warn, skip or fail, got %q", ErrPolicyInvalid, repo.Policy.Action)
+	}
+	switch repo.Policy.GetConflicts() {
+	case common.ConflictActionPrefer, common.ConflictActionFail:
+	default:
+		return fmt.Errorf("%w: conflicts must be prefer or fail, got %q", ErrPolicyInvalid, repo.Policy.Conflicts)
+	}
+	switch repo.Policy.GetRegressions() {
+	case common.PolicyActionWarn, common.PolicyActionFail:
+	default:
+		return fmt.Errorf("%w: regressions must be warn or fail, got %q", ErrPolicyInvalid, repo.Policy.Regressions)
+	}
+
+	// Validate pinned versions
+	for i, pin := range repo.Pin {
+		if len(pin.Versions) == 0 || slices.Contains(pin.Versions, "") {
+			return fmt.Errorf("pin %d: %w", i, ErrPinInvalid)
+		}
+	}
+
+	// Validate snapshots
+	if repo.Snapshots.Keep < 0 {
+		return fmt.Errorf("%w: keep must not be negative", ErrSnapshotsInvalid)
+	}
+
 	// Validate feeds
 	if len(repo.Feeds) == 0 {
 		return ErrNoFeeds
@@ -101,6 +565,32 @@ func validateRepository(repo *RepositoryConfig) error {
 		}
 	}
 
+	return validateTargetComponents(repo)
+}
+
+// validateTargetComponents validates that every feed of a repository with component_per_feed has its own component
+func validateTargetComponents(repo *RepositoryConfig) error {
+	used := make(map[string]int)
+	for i, feedOpts := range repo.Feeds {
+		component := feedOpts.TargetComponent
+		if !repo.ComponentPerFeed {
+			if component != "" {
+				return fmt.Errorf("feed %d: %w: target_component requires component_per_feed", i, ErrTargetComponentInvalid)
+			}
+			continue
+		}
+
+		if !componentPattern.MatchString(component) {
+			return fmt.Errorf("feed %d: %w: %q must be lowercase letters, digits, dots, plus and dashes, set target_component", i, ErrTargetComponentInvalid, component)
+		}
+		if component == common.DebugComponent {
+			return fmt.Errorf("feed %d: %w: %q is reserved for debug packages, set target_component", i, ErrTargetComponentInvalid, component)
+		}
+		if other, exists := used[component]; exists {
+			return fmt.Errorf("feed %d: %w: %q is also used by feed %d, set target_component", i, ErrTargetComponentInvalid, component, other)
+		}
+		used[component] = i
+	}
 	return nil
 }
 
@@ -113,7 +603,8 @@ func validateFeed(feedOpts *feed.FeedOptions) error {
 
 	// Validate Type is valid
 	feedType := feed.FeedType(feedOpts.Type)
-	if feedType != feed.FeedTypeGitHub && feedType != feed.FeedTypeAPT && feedType != feed.FeedTypeOBS {
+	reg, ok := feed.Lookup(feedType)
+	if !ok {
 		return fmt.Errorf("%w: %s", ErrFeedTypeInvalid, feedOpts.Type)
 	}
 
@@ -167,13 +658,172 @@ func validateFeed(feedOpts *feed.FeedOptions) error {
 		return fmt.Errorf("%w: %s", ErrFeedLocationFragment, name)
 	}
 
+	if feedOpts.Timeout < 0 {
+		return fmt.Errorf("%w: %s", ErrFeedTimeoutNegative, name)
+	}
+
 	// Validate GitHub-specific options
 	if feedType == feed.FeedTypeGitHub {
 		// no_changes requires distribution mappings
 		if feedOpts.NoChanges && len(feedOpts.Distributions) == 0 {
 			return fmt.Errorf("%w: %s", ErrNoChangesRequiresDist, name)
 		}
+
+		for _, pattern := range feedOpts.ExcludeAssets {
+			if _, err := filepath.Match(pattern, ""); err != nil {
+				return fmt.Errorf("%w: %s: %q", ErrExcludeAssetsInvalid, name, pattern)
+			}
+		}
+
+		if len(feedOpts.Checksums) > 0 && !feedOpts.NoChanges {
+			return fmt.Errorf("%w: %s", ErrChecksumsInvalid, name)
+		}
+		for _, pattern := range feedOpts.Checksums {
+			if _, err := filepath.Match(pattern, ""); err != nil {
+				return fmt.Errorf("%w: %s: %q", ErrChecksumsInvalid, name, pattern)
+			}
+		}
+	} else if len(feedOpts.ExcludeAssets) > 0 {
+		return fmt.Errorf("%w: %s", ErrExcludeAssetsInvalid, name)
+	} else if len(feedOpts.Checksums) > 0 {
+		return fmt.Errorf("%w: %s", ErrChecksumsInvalid, name)
+	}
+
+	hasComponents := len(feedOpts.Components) > 0
+	for _, distMap := range feedOpts.Distributions {
+		hasComponents = hasComponents || len(distMap.Components) > 0
+	}
+
+	// The OBS API lists the published files, there is no signed Release to pin keys or pick components from
+	if feedOpts.OBSAPI != nil && (len(feedOpts.SigningKeys) > 0 || feedOpts.KeyChange != "" || hasComponents) {
+		return fmt.Errorf("%w: %s", ErrOBSAPIUnsupported, name)
 	}
 
+	if err := validateSigningKeys(feedOpts, reg.Capabilities.SigningKeys); err != nil {
+		return fmt.Errorf("%w: %s", err, name)
+	}
+
+	// Component whitelisting only applies to feeds supporting it
+	if !reg.Capabilities.Components && hasComponents {
+		return fmt.Errorf("%w: %s", ErrComponentsNotSupported, name)
+	}
+
+	if reg.Capabilities.Routes {
+		if err := validateRoutes(feedOpts); err != nil {
+			return fmt.Errorf("%w: %s", err, name)
+		}
+	} else if len(feedOpts.Routes) > 0 {
+		return fmt.Errorf("%w: %s", ErrRoutesNotSupported, name)
+	}
+
+	return nil
+}
+
+// validateRoutes validates the release routes of a GitHub feed
+func validateRoutes(feedOpts *feed.FeedOptions) error {
+	for i, route := range feedOpts.Routes {
+		if !routeSuffixPattern.MatchString(route.Suffix) {
+			return fmt.Errorf("%w: route %d suffix %q must be a non-empty distribution name suffix", ErrRouteInvalid, i, route.Suffix)
+		}
+
+		// Routes can only apply to fetched release types
+		for _, releaseType := range route.Releases {
+			if !slices.Contains(feedOpts.Releases, releaseType) {
+				return fmt.Errorf("%w: route %d matches release type %q which is not fetched", ErrRouteInvalid, i, releaseType)
+			}
+		}
+	}
+
+	// Flat distributions have no name to suffix
+	if len(feedOpts.Routes) > 0 {
+		for _, distMap := range feedOpts.Distributions {
+			if distMap.Feed == "/" {
+				return fmt.Errorf("%w: routes cannot be used with flat distribution mappings", ErrRouteInvalid)
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateSigningKeys validates the acknowledged upstream signing keys of a feed
+func validateSigningKeys(feedOpts *feed.FeedOptions, supported bool) error {
+	if !supported {
+		if len(feedOpts.SigningKeys) > 0 || feedOpts.KeyChange != "" {
+			return ErrSigningKeysInvalid
+		}
+		return nil
+	}
+
+	for _, key := range feedOpts.SigningKeys {
+		if !signingKeyPattern.MatchString(strings.ReplaceAll(key, " ", "")) {
+			return fmt.Errorf("%w: %q", ErrSigningKeysInvalid, key)
+		}
+	}
+
+	switch feedOpts.KeyChange {
+	case "", feed.KeyChangeRefuse, feed.KeyChangeWarn:
+		return nil
+	default:
+		return fmt.Errorf("%w: key_change must be %q or %q, got %q", ErrSigningKeysInvalid, feed.KeyChangeRefuse, feed.KeyChangeWarn, feedOpts.KeyChange)
+	}
+}
+
+// validateSigning validates the key references and additional keys of the signing configuration
+func validateSigning(signing *SigningConfig) error {
+	if signing.Key != "" && !signingKeyPattern.MatchString(strings.ReplaceAll(signing.Key, " ", "")) {
+		return fmt.Errorf("%w: key must be a fingerprint or long key ID, got %q", ErrSigningInvalid, signing.Key)
+	}
+
+	switch signing.Backend {
+	case "", SigningBackendFile:
+		if signing.GPG != "" || signing.GnuPGHome != "" {
+			return fmt.Errorf("%w: gpg and gnupg_home require backend %q", ErrSigningInvalid, SigningBackendGPGAgent)
+		}
+	case SigningBackendGPGAgent:
+		return validateGPGAgentSigning(signing)
+	default:
+		return fmt.Errorf("%w: backend must be %q or %q, got %q", ErrSigningInvalid, SigningBackendFile, SigningBackendGPGAgent, signing.Backend)
+	}
+
+	if len(signing.Additional) > 0 && (signing.PrivateKey == "" || signing.PublicKey == "") {
+		return fmt.Errorf("%w: additional keys require private_key and public_key of the main key", ErrSigningInvalid)
+	}
+	for i, key := range signing.Additional {
+		if key.PrivateKey == "" || key.PublicKey == "" {
+			return fmt.Errorf("%w: additional key %d requires private_key and public_key", ErrSigningInvalid, i+1)
+		}
+		if key.Key != "" && !signingKeyPattern.MatchString(strings.ReplaceAll(key.Key, " ", "")) {
+			return fmt.Errorf("%w: additional key %d must be a fingerprint or long key ID, got %q", ErrSigningInvalid, i+1, key.Key)
+		}
+	}
+	return nil
+}
+
+// validateGPGAgentSigning validates the signing configuration of the gpg-agent backend
+// gpg holds all keys, they are referenced by fingerprint and their public keys are exported from gpg
+func validateGPGAgentSigning(signing *SigningConfig) error {
+	if signing.Key == "" {
+		return fmt.Errorf("%w: backend %q requires key", ErrSigningInvalid, SigningBackendGPGAgent)
+	}
+	if signing.PrivateKey != "" || signing.PublicKey != "" {
+		return fmt.Errorf("%w: backend %q takes the keys from gpg, remove private_key and public_key", ErrSigningInvalid, SigningBackendGPGAgent)
+	}
+	for i, key := range signing.Additional {
+		if key.Key == "" || key.PrivateKey != "" || key.PublicKey != "" || key.Passphrase != "" {
+			return fmt.Errorf("%w: additional key %d of backend %q requires only key", ErrSigningInvalid, i+1, SigningBackendGPGAgent)
+		}
+		if !signingKeyPattern.MatchString(strings.ReplaceAll(key.Key, " ", "")) {
+			return fmt.Errorf("%w: additional key %d must be a fingerprint or long key ID, got %q", ErrSigningInvalid, i+1, key.Key)
+		}
+	}
+	return nil
+}
+
+// ValidateVerifyPool checks a pool verification mode, also used for the generate --verify-pool flag
+func ValidateVerifyPool(mode string) error {
+	if mode != "" && mode != "none" && mode != "sample" && mode != "full" {
+		return fmt.Errorf("must be 'none', 'sample' or 'full', got %q", mode)
+	}
 	return nil
 }
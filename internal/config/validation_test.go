@@ -1,9 +1,12 @@
 package config
 
 import (
+	"net/url"
 	"testing"
 
+	"github.com/dionysius/aarg/internal/common"
 	"github.com/dionysius/aarg/internal/feed"
+	"github.com/dionysius/aarg/internal/plugin"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -127,6 +130,189 @@ func TestValidateFeed(t *testing.T) {
 			},
 			wantErr: ErrFeedLocationFragment,
 		},
+		{
+			name: "apt feed with components",
+			feed: &feed.FeedOptions{
+				Type:       "apt",
+				Name:       "archive.ubuntu.com/ubuntu",
+				Components: []string{"main", "universe"},
+			},
+		},
+		{
+			name: "github feed with distribution components",
+			feed: &feed.FeedOptions{
+				Type:          "github",
+				Name:          "owner/repo",
+				Distributions: []feed.DistributionMap{{Feed: "noble", Target: "noble", Components: []string{"main"}}},
+			},
+			wantErr: ErrComponentsNotSupported,
+		},
+		{
+			name: "github feed with routes",
+			feed: &feed.FeedOptions{
+				Type:     "github",
+				Name:     "owner/repo",
+				Releases: []feed.ReleaseType{feed.ReleaseTypeRelease, feed.ReleaseTypePrerelease},
+				Routes:   []feed.ReleaseRoute{{Releases: []feed.ReleaseType{feed.ReleaseTypePrerelease}, Suffix: "-beta"}},
+			},
+		},
+		{
+			name: "route with empty suffix",
+			feed: &feed.FeedOptions{
+				Type:   "github",
+				Name:   "owner/repo",
+				Routes: []feed.ReleaseRoute{{Tags: []string{"*-rc*"}}},
+			},
+			wantErr: ErrRouteInvalid,
+		},
+		{
+			name: "route for release type not fetched",
+			feed: &feed.FeedOptions{
+				Type:     "github",
+				Name:     "owner/repo",
+				Releases: []feed.ReleaseType{feed.ReleaseTypeRelease},
+				Routes:   []feed.ReleaseRoute{{Releases: []feed.ReleaseType{feed.ReleaseTypePrerelease}, Suffix: "-beta"}},
+			},
+			wantErr: ErrRouteInvalid,
+		},
+		{
+			name: "apt feed with routes",
+			feed: &feed.FeedOptions{
+				Type:   "apt",
+				Name:   "deb.debian.org/debian",
+				Routes: []feed.ReleaseRoute{{Suffix: "-beta"}},
+			},
+			wantErr: ErrRoutesNotSupported,
+		},
+		{
+			name: "github feed with exclude assets",
+			feed: &feed.FeedOptions{
+				Type:          "github",
+				Name:          "owner/repo",
+				ExcludeAssets: []string{"*-musl_*.deb", "*-vendor*"},
+			},
+		},
+		{
+			name: "github feed with invalid exclude pattern",
+			feed: &feed.FeedOptions{
+				Type:          "github",
+				Name:          "owner/repo",
+				ExcludeAssets: []string{"[musl"},
+			},
+			wantErr: ErrExcludeAssetsInvalid,
+		},
+		{
+			name: "apt feed with exclude assets",
+			feed: &feed.FeedOptions{
+				Type:          "apt",
+				Name:          "deb.debian.org/debian",
+				ExcludeAssets: []string{"*.deb"},
+			},
+			wantErr: ErrExcludeAssetsInvalid,
+		},
+		{
+			name: "github no_changes feed with checksums",
+			feed: &feed.FeedOptions{
+				Type:          "github",
+				Name:          "owner/repo",
+				NoChanges:     true,
+				Distributions: []feed.DistributionMap{{Feed: "/", Target: "stable"}},
+				Checksums:     []string{"*.sha256sums"},
+			},
+		},
+		{
+			name: "github feed with checksums without no_changes",
+			feed: &feed.FeedOptions{
+				Type:      "github",
+				Name:      "owner/repo",
+				Checksums: []string{"SHA256SUMS"},
+			},
+			wantErr: ErrChecksumsInvalid,
+		},
+		{
+			name: "github no_changes feed with invalid checksums pattern",
+			feed: &feed.FeedOptions{
+				Type:          "github",
+				Name:          "owner/repo",
+				NoChanges:     true,
+				Distributions: []feed.DistributionMap{{Feed: "/", Target: "stable"}},
+				Checksums:     []string{"[sums"},
+			},
+			wantErr: ErrChecksumsInvalid,
+		},
+		{
+			name: "apt feed with signing keys",
+			feed: &feed.FeedOptions{
+				Type:        "apt",
+				Name:        "deb.debian.org/debian",
+				SigningKeys: []string{"6ED0E7B82643E131", "B8B8 0B5B 623E AB6A D877  5C45 B7C5 D7D6 3509 47F8"},
+				KeyChange:   feed.KeyChangeWarn,
+			},
+		},
+		{
+			name: "apt feed with short key ID",
+			feed: &feed.FeedOptions{
+				Type:        "apt",
+				Name:        "deb.debian.org/debian",
+				SigningKeys: []string{"2643E131"},
+			},
+			wantErr: ErrSigningKeysInvalid,
+		},
+		{
+			name: "apt feed with invalid key change policy",
+			feed: &feed.FeedOptions{
+				Type:      "apt",
+				Name:      "deb.debian.org/debian",
+				KeyChange: "ignore",
+			},
+			wantErr: ErrSigningKeysInvalid,
+		},
+		{
+			name: "github feed with signing keys",
+			feed: &feed.FeedOptions{
+				Type:        "github",
+				Name:        "owner/repo",
+				SigningKeys: []string{"6ED0E7B82643E131"},
+			},
+			wantErr: ErrSigningKeysInvalid,
+		},
+		{
+			name: "obs api feed",
+			feed: &feed.FeedOptions{
+				Type:   "obs",
+				Name:   "home:user:project",
+				OBSAPI: &url.URL{Scheme: "https", Host: "api.opensuse.org"},
+			},
+		},
+		{
+			name: "obs api feed with signing keys",
+			feed: &feed.FeedOptions{
+				Type:        "obs",
+				Name:        "home:user:project",
+				OBSAPI:      &url.URL{Scheme: "https", Host: "api.opensuse.org"},
+				SigningKeys: []string{"6ED0E7B82643E131"},
+			},
+			wantErr: ErrOBSAPIUnsupported,
+		},
+		{
+			name: "obs api feed with components",
+			feed: &feed.FeedOptions{
+				Type:          "obs",
+				Name:          "home:user:project",
+				OBSAPI:        &url.URL{Scheme: "https", Host: "api.opensuse.org"},
+				Distributions: []feed.DistributionMap{{Feed: "Debian_12", Target: "bookworm", Components: []string{"main"}}},
+			},
+			wantErr: ErrOBSAPIUnsupported,
+		},
+		{
+			name: "negative timeout",
+			feed: &feed.FeedOptions{
+				Type:    "apt",
+				Name:    "deb.debian.org/debian",
+				Timeout: -1,
+			},
+			wantErr: ErrFeedTimeoutNegative,
+		},
 	}
 
 	for _, tt := range tests {
@@ -248,40 +434,52 @@ func TestValidateConfig(t *testing.T) {
 			errSubstr: "invalid",
 		},
 		{
-			name: "repository without name",
+			name: "valid timezone",
 			cfg: &Config{
+				Timezone: "Europe/Zurich",
 				Generate: GenerateConfig{
 					PoolMode: "hierarchical",
 				},
 				Repositories: []*RepositoryConfig{
 					{
-						Name: "",
+						Name: "test",
 						Feeds: []*feed.FeedOptions{
 							{Type: "github", Name: "owner/repo"},
 						},
 					},
 				},
 			},
-			wantErr: ErrRepositoryNameEmpty,
 		},
 		{
-			name: "repository without feeds",
+			name: "invalid timezone",
 			cfg: &Config{
+				Timezone: "Mars/Olympus_Mons",
 				Generate: GenerateConfig{
 					PoolMode: "hierarchical",
 				},
 				Repositories: []*RepositoryConfig{
 					{
-						Name:  "test",
-						Feeds: []*feed.FeedOptions{},
+						Name: "test",
+						Feeds: []*feed.FeedOptions{
+							{Type: "github", Name: "owner/repo"},
+						},
 					},
 				},
 			},
-			wantErr: ErrNoFeeds,
+			wantErr:   ErrTimezoneInvalid,
+			errSubstr: "Mars/Olympus_Mons",
 		},
 		{
-			name: "repository with invalid feed",
+			name: "signing subkey and additional key",
 			cfg: &Config{
+				Signing: SigningConfig{
+					PrivateKey: "keys/new-private.asc",
+					PublicKey:  "keys/new-public.asc",
+					Key:        "0x1234 5678 9ABC DEF0 1234  5678 9ABC DEF0 1234 5678",
+					Additional: []SigningKeyConfig{
+						{PrivateKey: "keys/old-private.asc", PublicKey: "keys/old-public.asc", Key: "0123456789ABCDEF"},
+					},
+				},
 				Generate: GenerateConfig{
 					PoolMode: "hierarchical",
 				},
@@ -289,155 +487,1013 @@ func TestValidateConfig(t *testing.T) {
 					{
 						Name: "test",
 						Feeds: []*feed.FeedOptions{
-							{Type: "github", Name: "owner/repo?query=bad"},
+							{Type: "github", Name: "owner/repo"},
 						},
 					},
 				},
 			},
-			wantErr: ErrFeedLocationQuery,
 		},
 		{
-			name: "repository with reserved name",
+			name: "signing key with short key ID",
 			cfg: &Config{
+				Signing: SigningConfig{
+					PrivateKey: "keys/private.asc",
+					PublicKey:  "keys/public.asc",
+					Key:        "89ABCDEF",
+				},
 				Generate: GenerateConfig{
 					PoolMode: "hierarchical",
 				},
 				Repositories: []*RepositoryConfig{
 					{
-						Name: "assets",
+						Name: "test",
 						Feeds: []*feed.FeedOptions{
 							{Type: "github", Name: "owner/repo"},
 						},
 					},
 				},
 			},
-			wantErr:   ErrRepositoryNameReserved,
-			errSubstr: "assets",
+			wantErr:   ErrSigningInvalid,
+			errSubstr: "89ABCDEF",
 		},
 		{
-			name: "repository name with invalid characters",
+			name: "additional signing key without public key",
 			cfg: &Config{
+				Signing: SigningConfig{
+					PrivateKey: "keys/new-private.asc",
+					PublicKey:  "keys/new-public.asc",
+					Additional: []SigningKeyConfig{
+						{PrivateKey: "keys/old-private.asc"},
+					},
+				},
 				Generate: GenerateConfig{
 					PoolMode: "hierarchical",
 				},
 				Repositories: []*RepositoryConfig{
 					{
-						Name: "my.repo",
+						Name: "test",
 						Feeds: []*feed.FeedOptions{
 							{Type: "github", Name: "owner/repo"},
 						},
 					},
 				},
 			},
-			wantErr:   ErrRepositoryNameInvalid,
-			errSubstr: "must contain only",
+			wantErr: ErrSigningInvalid,
 		},
 		{
-			name: "valid repository name with dash underscore and numbers",
+			name: "additional signing key without main key",
 			cfg: &Config{
+				Signing: SigningConfig{
+					Additional: []SigningKeyConfig{
+						{PrivateKey: "keys/old-private.asc", PublicKey: "keys/old-public.asc"},
+					},
+				},
 				Generate: GenerateConfig{
-					PoolMode: "redirect",
+					PoolMode: "hierarchical",
 				},
 				Repositories: []*RepositoryConfig{
 					{
-						Name: "my_repo-123",
+						Name: "test",
 						Feeds: []*feed.FeedOptions{
 							{Type: "github", Name: "owner/repo"},
 						},
 					},
 				},
 			},
+			wantErr: ErrSigningInvalid,
 		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			err := validate(tt.cfg)
-			if tt.wantErr != nil {
-				require.Error(t, err)
-				assert.ErrorIs(t, err, tt.wantErr)
-				if tt.errSubstr != "" {
-					assert.Contains(t, err.Error(), tt.errSubstr)
-				}
-			} else if tt.errSubstr != "" {
-				require.Error(t, err)
-				assert.Contains(t, err.Error(), tt.errSubstr)
-			} else {
-				require.NoError(t, err)
-			}
-		})
-	}
-}
-
-func TestValidateRepository(t *testing.T) {
-	tests := []struct {
-		name      string
-		repo      *RepositoryConfig
-		wantErr   error
-		errSubstr string
-	}{
 		{
-			name: "valid repository",
-			repo: &RepositoryConfig{
-				Name: "test",
-				Feeds: []*feed.FeedOptions{
-					{Type: "github", Name: "owner/repo"},
+			name: "gpg-agent signing backend",
+			cfg: &Config{
+				Signing: SigningConfig{
+					Backend:   SigningBackendGPGAgent,
+					Key:       "0123456789ABCDEF0123456789ABCDEF01234567",
+					GnuPGHome: "gnupg",
+					Additional: []SigningKeyConfig{
+						{Key: "89ABCDEF01234567"},
+					},
+				},
+				Generate: GenerateConfig{
+					PoolMode: "hierarchical",
+				},
+				Repositories: []*RepositoryConfig{
+					{
+						Name: "test",
+						Feeds: []*feed.FeedOptions{
+							{Type: "github", Name: "owner/repo"},
+						},
+					},
 				},
 			},
 		},
 		{
-			name: "empty name",
-			repo: &RepositoryConfig{
-				Name: "",
-				Feeds: []*feed.FeedOptions{
-					{Type: "github", Name: "owner/repo"},
+			name: "gpg-agent signing backend without key",
+			cfg: &Config{
+				Signing: SigningConfig{
+					Backend: SigningBackendGPGAgent,
+				},
+				Generate: GenerateConfig{
+					PoolMode: "hierarchical",
+				},
+				Repositories: []*RepositoryConfig{
+					{
+						Name: "test",
+						Feeds: []*feed.FeedOptions{
+							{Type: "github", Name: "owner/repo"},
+						},
+					},
 				},
 			},
-			wantErr: ErrRepositoryNameEmpty,
+			wantErr:   ErrSigningInvalid,
+			errSubstr: "requires key",
 		},
 		{
-			name: "reserved name - assets",
-			repo: &RepositoryConfig{
-				Name: "assets",
-				Feeds: []*feed.FeedOptions{
-					{Type: "github", Name: "owner/repo"},
+			name: "gpg-agent signing backend with key files",
+			cfg: &Config{
+				Signing: SigningConfig{
+					Backend:    SigningBackendGPGAgent,
+					Key:        "0123456789ABCDEF",
+					PrivateKey: "keys/private.asc",
+				},
+				Generate: GenerateConfig{
+					PoolMode: "hierarchical",
+				},
+				Repositories: []*RepositoryConfig{
+					{
+						Name: "test",
+						Feeds: []*feed.FeedOptions{
+							{Type: "github", Name: "owner/repo"},
+						},
+					},
 				},
 			},
-			wantErr:   ErrRepositoryNameReserved,
-			errSubstr: "assets",
+			wantErr:   ErrSigningInvalid,
+			errSubstr: "private_key",
 		},
 		{
-			name: "reserved name - keys",
-			repo: &RepositoryConfig{
-				Name: "keys",
-				Feeds: []*feed.FeedOptions{
-					{Type: "github", Name: "owner/repo"},
+			name: "unknown signing backend",
+			cfg: &Config{
+				Signing: SigningConfig{
+					Backend: "pkcs11",
+				},
+				Generate: GenerateConfig{
+					PoolMode: "hierarchical",
+				},
+				Repositories: []*RepositoryConfig{
+					{
+						Name: "test",
+						Feeds: []*feed.FeedOptions{
+							{Type: "github", Name: "owner/repo"},
+						},
+					},
 				},
 			},
-			wantErr:   ErrRepositoryNameReserved,
-			errSubstr: "keys",
+			wantErr:   ErrSigningInvalid,
+			errSubstr: "pkcs11",
 		},
 		{
-			name: "invalid characters",
-			repo: &RepositoryConfig{
-				Name: "my@repo!",
-				Feeds: []*feed.FeedOptions{
-					{Type: "github", Name: "owner/repo"},
+			name: "repository without name",
+			cfg: &Config{
+				Generate: GenerateConfig{
+					PoolMode: "hierarchical",
+				},
+				Repositories: []*RepositoryConfig{
+					{
+						Name: "",
+						Feeds: []*feed.FeedOptions{
+							{Type: "github", Name: "owner/repo"},
+						},
+					},
 				},
 			},
-			wantErr:   ErrRepositoryNameInvalid,
-			errSubstr: "must contain only",
+			wantErr: ErrRepositoryNameEmpty,
 		},
 		{
-			name: "no feeds",
-			repo: &RepositoryConfig{
-				Name:  "test",
-				Feeds: []*feed.FeedOptions{},
+			name: "repository without feeds",
+			cfg: &Config{
+				Generate: GenerateConfig{
+					PoolMode: "hierarchical",
+				},
+				Repositories: []*RepositoryConfig{
+					{
+						Name:  "test",
+						Feeds: []*feed.FeedOptions{},
+					},
+				},
 			},
 			wantErr: ErrNoFeeds,
 		},
 		{
-			name: "invalid feed",
+			name: "repository with invalid feed",
+			cfg: &Config{
+				Generate: GenerateConfig{
+					PoolMode: "hierarchical",
+				},
+				Repositories: []*RepositoryConfig{
+					{
+						Name: "test",
+						Feeds: []*feed.FeedOptions{
+							{Type: "github", Name: "owner/repo?query=bad"},
+						},
+					},
+				},
+			},
+			wantErr: ErrFeedLocationQuery,
+		},
+		{
+			name: "repository with reserved name",
+			cfg: &Config{
+				Generate: GenerateConfig{
+					PoolMode: "hierarchical",
+				},
+				Repositories: []*RepositoryConfig{
+					{
+						Name: "assets",
+						Feeds: []*feed.FeedOptions{
+							{Type: "github", Name: "owner/repo"},
+						},
+					},
+				},
+			},
+			wantErr:   ErrRepositoryNameReserved,
+			errSubstr: "assets",
+		},
+		{
+			name: "repository name with invalid characters",
+			cfg: &Config{
+				Generate: GenerateConfig{
+					PoolMode: "hierarchical",
+				},
+				Repositories: []*RepositoryConfig{
+					{
+						Name: "my.repo",
+						Feeds: []*feed.FeedOptions{
+							{Type: "github", Name: "owner/repo"},
+						},
+					},
+				},
+			},
+			wantErr:   ErrRepositoryNameInvalid,
+			errSubstr: "must contain only",
+		},
+		{
+			name: "draft releases with token",
+			cfg: &Config{
+				GitHub:   GitHubConfig{Token: "token"},
+				Generate: GenerateConfig{PoolMode: "hierarchical"},
+				Repositories: []*RepositoryConfig{
+					{
+						Name: "test",
+						Feeds: []*feed.FeedOptions{
+							{Type: "github", Name: "owner/repo", Releases: []feed.ReleaseType{feed.ReleaseTypeDraft}},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "draft releases without token",
+			cfg: &Config{
+				Generate: GenerateConfig{PoolMode: "hierarchical"},
+				Repositories: []*RepositoryConfig{
+					{
+						Name: "test",
+						Feeds: []*feed.FeedOptions{
+							{Type: "github", Name: "owner/repo", Releases: []feed.ReleaseType{feed.ReleaseTypeDraft}},
+						},
+					},
+				},
+			},
+			wantErr: ErrDraftRequiresToken,
+		},
+		{
+			name: "upload with token",
+			cfg: &Config{
+				GitHub:   GitHubConfig{Token: "token"},
+				Generate: GenerateConfig{PoolMode: "hierarchical"},
+				Repositories: []*RepositoryConfig{
+					{
+						Name:   "test",
+						Upload: UploadConfig{GitHub: "owner/repo", Tag: "apt"},
+						Feeds:  []*feed.FeedOptions{{Type: "github", Name: "owner/repo"}},
+					},
+				},
+			},
+		},
+		{
+			name: "upload without token",
+			cfg: &Config{
+				Generate: GenerateConfig{PoolMode: "hierarchical"},
+				Repositories: []*RepositoryConfig{
+					{
+						Name:   "test",
+						Upload: UploadConfig{GitHub: "owner/repo", Tag: "apt"},
+						Feeds:  []*feed.FeedOptions{{Type: "github", Name: "owner/repo"}},
+					},
+				},
+			},
+			wantErr: ErrUploadRequiresToken,
+		},
+		{
+			name: "draft releases in redirect pool mode",
+			cfg: &Config{
+				GitHub:   GitHubConfig{Token: "token"},
+				Generate: GenerateConfig{PoolMode: "redirect"},
+				Repositories: []*RepositoryConfig{
+					{
+						Name: "test",
+						Feeds: []*feed.FeedOptions{
+							{Type: "github", Name: "owner/repo", Releases: []feed.ReleaseType{feed.ReleaseTypeDraft}},
+						},
+					},
+				},
+			},
+			wantErr: ErrDraftRequiresPool,
+		},
+		{
+			name: "notifications routed to repository owner",
+			cfg: &Config{
+				Generate: GenerateConfig{PoolMode: "hierarchical"},
+				Notifications: NotificationsConfig{
+					Webhook: "https://hooks.example.com/all",
+					Owners:  map[string]string{"@org/web": "https://hooks.example.com/web"},
+				},
+				Repositories: []*RepositoryConfig{
+					{
+						Name:   "test",
+						Owners: []string{"@org/web"},
+						Feeds: []*feed.FeedOptions{
+							{Type: "github", Name: "owner/repo"},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "notifications with invalid webhook",
+			cfg: &Config{
+				Generate:      GenerateConfig{PoolMode: "hierarchical"},
+				Notifications: NotificationsConfig{Webhook: "hooks.example.com/all"},
+				Repositories: []*RepositoryConfig{
+					{
+						Name: "test",
+						Feeds: []*feed.FeedOptions{
+							{Type: "github", Name: "owner/repo"},
+						},
+					},
+				},
+			},
+			wantErr: ErrNotificationsInvalid,
+		},
+		{
+			name: "notifications for unknown owner",
+			cfg: &Config{
+				Generate: GenerateConfig{PoolMode: "hierarchical"},
+				Notifications: NotificationsConfig{
+					Owners: map[string]string{"@org/wbe": "https://hooks.example.com/web"},
+				},
+				Repositories: []*RepositoryConfig{
+					{
+						Name:   "test",
+						Owners: []string{"@org/web"},
+						Feeds: []*feed.FeedOptions{
+							{Type: "github", Name: "owner/repo"},
+						},
+					},
+				},
+			},
+			wantErr: ErrNotificationsInvalid,
+		},
+		{
+			name: "valid plugin feed",
+			cfg: &Config{
+				Generate: GenerateConfig{PoolMode: "hierarchical"},
+				Plugins:  map[string]plugin.Command{"local": {Command: "aarg-plugin-local"}},
+				Repositories: []*RepositoryConfig{
+					{
+						Name: "test",
+						Feeds: []*feed.FeedOptions{
+							{Type: "plugin", Plugin: "local", Name: "builds"},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "plugin feed without configured plugin",
+			cfg: &Config{
+				Generate: GenerateConfig{PoolMode: "hierarchical"},
+				Repositories: []*RepositoryConfig{
+					{
+						Name: "test",
+						Feeds: []*feed.FeedOptions{
+							{Type: "plugin", Plugin: "local", Name: "builds"},
+						},
+					},
+				},
+			},
+			wantErr: ErrPluginNotConfigured,
+		},
+		{
+			name: "plugin feed in redirect pool mode",
+			cfg: &Config{
+				Generate: GenerateConfig{PoolMode: "redirect"},
+				Plugins:  map[string]plugin.Command{"local": {Command: "aarg-plugin-local"}},
+				Repositories: []*RepositoryConfig{
+					{
+						Name: "test",
+						Feeds: []*feed.FeedOptions{
+							{Type: "plugin", Plugin: "local", Name: "builds"},
+						},
+					},
+				},
+			},
+			wantErr: ErrPluginRequiresPool,
+		},
+		{
+			name: "manifest feed in redirect pool mode",
+			cfg: &Config{
+				Generate: GenerateConfig{PoolMode: "redirect"},
+				Repositories: []*RepositoryConfig{
+					{
+						Name: "test",
+						Feeds: []*feed.FeedOptions{
+							{Type: "manifest", Manifest: "manifests/tools.yaml", Name: "manifests/tools.yaml"},
+						},
+					},
+				},
+			},
+			wantErr: ErrManifestRequiresPool,
+		},
+		{
+			name: "snapshots in redirect pool mode",
+			cfg: &Config{
+				Generate: GenerateConfig{PoolMode: "redirect"},
+				Repositories: []*RepositoryConfig{
+					{
+						Name: "test",
+						RepositoryOptions: common.RepositoryOptions{
+							Snapshots: common.SnapshotOptions{Keep: 10},
+						},
+						Feeds: []*feed.FeedOptions{
+							{Type: "github", Name: "owner/repo"},
+						},
+					},
+				},
+			},
+			wantErr: ErrSnapshotsRequirePool,
+		},
+		{
+			name: "plugin without command",
+			cfg: &Config{
+				Generate: GenerateConfig{PoolMode: "hierarchical"},
+				Plugins:  map[string]plugin.Command{"local": {}},
+				Repositories: []*RepositoryConfig{
+					{
+						Name: "test",
+						Feeds: []*feed.FeedOptions{
+							{Type: "github", Name: "owner/repo"},
+						},
+					},
+				},
+			},
+			wantErr: ErrPluginInvalid,
+		},
+		{
+			name: "publish plugin not configured",
+			cfg: &Config{
+				Generate: GenerateConfig{PoolMode: "hierarchical"},
+				Publish:  PublishConfig{Plugin: "local"},
+				Repositories: []*RepositoryConfig{
+					{
+						Name: "test",
+						Feeds: []*feed.FeedOptions{
+							{Type: "github", Name: "owner/repo"},
+						},
+					},
+				},
+			},
+			wantErr: ErrPluginNotConfigured,
+		},
+		{
+			name: "valid compression levels",
+			cfg: &Config{
+				Generate: GenerateConfig{
+					PoolMode:    "hierarchical",
+					Compression: CompressionConfig{Gzip: 9, Bzip2: 1, XZ: 6},
+				},
+				Repositories: []*RepositoryConfig{
+					{
+						Name: "test",
+						Feeds: []*feed.FeedOptions{
+							{Type: "github", Name: "owner/repo"},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "invalid compression level",
+			cfg: &Config{
+				Generate: GenerateConfig{
+					PoolMode:    "hierarchical",
+					Compression: CompressionConfig{XZ: 10},
+				},
+				Repositories: []*RepositoryConfig{
+					{
+						Name: "test",
+						Feeds: []*feed.FeedOptions{
+							{Type: "github", Name: "owner/repo"},
+						},
+					},
+				},
+			},
+			errSubstr: "xz compression level must be between 1 and 9",
+		},
+		{
+			name: "negative bandwidth limit",
+			cfg: &Config{
+				Generate: GenerateConfig{PoolMode: "hierarchical"},
+				HTTP:     HTTPConfig{MaxBytesPerSecondHost: -1},
+				Repositories: []*RepositoryConfig{
+					{Name: "test", Feeds: []*feed.FeedOptions{{Type: "github", Name: "owner/repo"}}},
+				},
+			},
+			errSubstr: "max_bytes_per_second",
+		},
+		{
+			name: "negative pdiff history",
+			cfg: &Config{
+				Generate: GenerateConfig{PoolMode: "hierarchical", PDiffHistory: -1},
+				Repositories: []*RepositoryConfig{
+					{
+						Name: "test",
+						Feeds: []*feed.FeedOptions{
+							{Type: "github", Name: "owner/repo"},
+						},
+					},
+				},
+			},
+			errSubstr: "pdiff_history",
+		},
+		{
+			name: "negative previous builds",
+			cfg: &Config{
+				Generate: GenerateConfig{PoolMode: "hierarchical"},
+				Web:      WebConfig{PreviousBuilds: -1},
+				Repositories: []*RepositoryConfig{
+					{
+						Name: "test",
+						Feeds: []*feed.FeedOptions{
+							{Type: "github", Name: "owner/repo"},
+						},
+					},
+				},
+			},
+			errSubstr: "previous_builds",
+		},
+		{
+			name: "invalid removal percentage",
+			cfg: &Config{
+				Generate: GenerateConfig{
+					PoolMode: "hierarchical",
+				},
+				Publish: PublishConfig{MaxRemovedPercent: 150},
+				Repositories: []*RepositoryConfig{
+					{
+						Name: "test",
+						Feeds: []*feed.FeedOptions{
+							{Type: "github", Name: "owner/repo"},
+						},
+					},
+				},
+			},
+			errSubstr: "max_removed_percent",
+		},
+		{
+			name: "valid repository projects",
+			cfg: &Config{
+				Generate:   GenerateConfig{PoolMode: "redirect"},
+				Cloudflare: CloudflareConfig{ProjectName: "apt", RepositoryProject: "apt-{{.Repository}}"},
+				Repositories: []*RepositoryConfig{
+					{Name: "one", Feeds: []*feed.FeedOptions{{Type: "github", Name: "owner/one"}}},
+					{Name: "two", Feeds: []*feed.FeedOptions{{Type: "github", Name: "owner/two"}}},
+				},
+			},
+		},
+		{
+			name: "repository projects without repository name",
+			cfg: &Config{
+				Generate:   GenerateConfig{PoolMode: "redirect"},
+				Cloudflare: CloudflareConfig{RepositoryProject: "apt-repo"},
+				Repositories: []*RepositoryConfig{
+					{Name: "one", Feeds: []*feed.FeedOptions{{Type: "github", Name: "owner/one"}}},
+					{Name: "two", Feeds: []*feed.FeedOptions{{Type: "github", Name: "owner/two"}}},
+				},
+			},
+			wantErr:   ErrProjectInvalid,
+			errSubstr: "also used by repository one",
+		},
+		{
+			name: "repository project with invalid characters",
+			cfg: &Config{
+				Generate:   GenerateConfig{PoolMode: "redirect"},
+				Cloudflare: CloudflareConfig{RepositoryProject: "apt-{{.Repository}}"},
+				Repositories: []*RepositoryConfig{
+					{Name: "my_repo", Feeds: []*feed.FeedOptions{{Type: "github", Name: "owner/repo"}}},
+				},
+			},
+			wantErr: ErrProjectInvalid,
+		},
+		{
+			name: "repository project with publish plugin",
+			cfg: &Config{
+				Generate:   GenerateConfig{PoolMode: "redirect"},
+				Cloudflare: CloudflareConfig{RepositoryProject: "apt-{{.Repository}}"},
+				Plugins:    map[string]plugin.Command{"local": {Command: "aarg-local"}},
+				Publish:    PublishConfig{Plugin: "local"},
+				Repositories: []*RepositoryConfig{
+					{Name: "one", Feeds: []*feed.FeedOptions{{Type: "github", Name: "owner/one"}}},
+				},
+			},
+			wantErr: ErrProjectInvalid,
+		},
+		{
+			name: "negative health max age",
+			cfg: &Config{
+				Generate: GenerateConfig{PoolMode: "hierarchical", HealthMaxAgeHours: -1},
+				Repositories: []*RepositoryConfig{
+					{Name: "test", Feeds: []*feed.FeedOptions{{Type: "github", Name: "owner/repo"}}},
+				},
+			},
+			errSubstr: "health_max_age_hours must not be negative",
+		},
+		{
+			name: "negative feed timeout",
+			cfg: &Config{
+				Generate: GenerateConfig{PoolMode: "hierarchical"},
+				Fetch:    FetchConfig{FeedTimeout: -1},
+				Repositories: []*RepositoryConfig{
+					{Name: "test", Feeds: []*feed.FeedOptions{{Type: "github", Name: "owner/repo"}}},
+				},
+			},
+			errSubstr: "feed_timeout must not be negative",
+		},
+		{
+			name: "valid publish directory",
+			cfg: &Config{
+				Generate: GenerateConfig{PoolMode: "hierarchical"},
+				Publish:  PublishConfig{Directory: DirectoryConfig{Path: "/srv/www/apt", Mode: "hardlink", Keep: 2}},
+				Repositories: []*RepositoryConfig{
+					{Name: "test", Feeds: []*feed.FeedOptions{{Type: "github", Name: "owner/repo"}}},
+				},
+			},
+		},
+		{
+			name: "publish directory with redirect pool mode",
+			cfg: &Config{
+				Generate: GenerateConfig{PoolMode: "redirect"},
+				Publish:  PublishConfig{Directory: DirectoryConfig{Path: "/srv/www/apt", Mode: "hardlink"}},
+				Repositories: []*RepositoryConfig{
+					{Name: "test", Feeds: []*feed.FeedOptions{{Type: "github", Name: "owner/repo"}}},
+				},
+			},
+			wantErr: ErrDirectoryInvalid,
+		},
+		{
+			name: "publish directory invalid mode",
+			cfg: &Config{
+				Generate: GenerateConfig{PoolMode: "hierarchical"},
+				Publish:  PublishConfig{Directory: DirectoryConfig{Path: "/srv/www/apt", Mode: "rsync"}},
+				Repositories: []*RepositoryConfig{
+					{Name: "test", Feeds: []*feed.FeedOptions{{Type: "github", Name: "owner/repo"}}},
+				},
+			},
+			wantErr:   ErrDirectoryInvalid,
+			errSubstr: "mode",
+		},
+		{
+			name: "publish directory with public staging requires staging path",
+			cfg: &Config{
+				Directories: DirectoriesConfig{PublicStaging: "public-staging"},
+				Generate:    GenerateConfig{PoolMode: "hierarchical"},
+				Publish:     PublishConfig{Directory: DirectoryConfig{Path: "/srv/www/apt", Mode: "copy"}},
+				Repositories: []*RepositoryConfig{
+					{Name: "test", Feeds: []*feed.FeedOptions{{Type: "github", Name: "owner/repo"}}},
+				},
+			},
+			wantErr:   ErrDirectoryInvalid,
+			errSubstr: "staging_path",
+		},
+		{
+			name: "publish directory with publish plugin",
+			cfg: &Config{
+				Generate: GenerateConfig{PoolMode: "hierarchical"},
+				Plugins:  map[string]plugin.Command{"local": {Command: "aarg-local"}},
+				Publish:  PublishConfig{Plugin: "local", Directory: DirectoryConfig{Path: "/srv/www/apt", Mode: "hardlink"}},
+				Repositories: []*RepositoryConfig{
+					{Name: "test", Feeds: []*feed.FeedOptions{{Type: "github", Name: "owner/repo"}}},
+				},
+			},
+			wantErr: ErrDirectoryInvalid,
+		},
+		{
+			name: "valid publish providers",
+			cfg: &Config{
+				Generate: GenerateConfig{PoolMode: "hierarchical"},
+				Plugins:  map[string]plugin.Command{"s3": {Command: "aarg-s3"}},
+				Publish: PublishConfig{
+					Directory: DirectoryConfig{Path: "/srv/www/apt", Mode: "hardlink"},
+					Providers: []ProviderConfig{{Name: ProviderCloudflare}, {Name: "s3"}, {Name: ProviderDirectory, Enabled: new(bool)}},
+				},
+				Cloudflare: CloudflareConfig{CanaryProject: "apt-canary"},
+				Repositories: []*RepositoryConfig{
+					{Name: "test", Feeds: []*feed.FeedOptions{{Type: "github", Name: "owner/repo"}}},
+				},
+			},
+		},
+		{
+			name: "publish providers with unknown plugin",
+			cfg: &Config{
+				Generate: GenerateConfig{PoolMode: "hierarchical"},
+				Publish:  PublishConfig{Providers: []ProviderConfig{{Name: ProviderCloudflare}, {Name: "s3"}}},
+				Repositories: []*RepositoryConfig{
+					{Name: "test", Feeds: []*feed.FeedOptions{{Type: "github", Name: "owner/repo"}}},
+				},
+			},
+			wantErr: ErrPluginNotConfigured,
+		},
+		{
+			name: "publish providers listed twice",
+			cfg: &Config{
+				Generate: GenerateConfig{PoolMode: "hierarchical"},
+				Publish:  PublishConfig{Providers: []ProviderConfig{{Name: ProviderCloudflare}, {Name: ProviderCloudflare}}},
+				Repositories: []*RepositoryConfig{
+					{Name: "test", Feeds: []*feed.FeedOptions{{Type: "github", Name: "owner/repo"}}},
+				},
+			},
+			wantErr:   ErrProvidersInvalid,
+			errSubstr: "more than once",
+		},
+		{
+			name: "publish providers directory without path",
+			cfg: &Config{
+				Generate: GenerateConfig{PoolMode: "hierarchical"},
+				Publish:  PublishConfig{Providers: []ProviderConfig{{Name: ProviderDirectory}}},
+				Repositories: []*RepositoryConfig{
+					{Name: "test", Feeds: []*feed.FeedOptions{{Type: "github", Name: "owner/repo"}}},
+				},
+			},
+			wantErr:   ErrProvidersInvalid,
+			errSubstr: "path",
+		},
+		{
+			name: "publish providers all disabled",
+			cfg: &Config{
+				Generate: GenerateConfig{PoolMode: "hierarchical"},
+				Publish:  PublishConfig{Providers: []ProviderConfig{{Name: ProviderCloudflare, Enabled: new(bool)}}},
+				Repositories: []*RepositoryConfig{
+					{Name: "test", Feeds: []*feed.FeedOptions{{Type: "github", Name: "owner/repo"}}},
+				},
+			},
+			wantErr:   ErrProvidersInvalid,
+			errSubstr: "no provider enabled",
+		},
+		{
+			name: "serve auth with username and password",
+			cfg: &Config{
+				Generate: GenerateConfig{PoolMode: "hierarchical"},
+				Serve:    ServeConfig{Auth: ServeAuthConfig{Username: "apt", Password: "secret"}},
+				Repositories: []*RepositoryConfig{
+					{Name: "test", Feeds: []*feed.FeedOptions{{Type: "github", Name: "owner/repo"}}},
+				},
+			},
+		},
+		{
+			name: "serve auth without password",
+			cfg: &Config{
+				Generate: GenerateConfig{PoolMode: "hierarchical"},
+				Serve:    ServeConfig{Auth: ServeAuthConfig{Username: "apt"}},
+				Repositories: []*RepositoryConfig{
+					{Name: "test", Feeds: []*feed.FeedOptions{{Type: "github", Name: "owner/repo"}}},
+				},
+			},
+			wantErr: ErrServeAuthInvalid,
+		},
+		{
+			name: "serve redirects proxy",
+			cfg: &Config{
+				Generate: GenerateConfig{PoolMode: "redirect"},
+				Serve:    ServeConfig{Redirects: ServeRedirectsProxy},
+				Repositories: []*RepositoryConfig{
+					{Name: "test", Feeds: []*feed.FeedOptions{{Type: "github", Name: "owner/repo"}}},
+				},
+			},
+		},
+		{
+			name: "serve redirects invalid",
+			cfg: &Config{
+				Generate: GenerateConfig{PoolMode: "redirect"},
+				Serve:    ServeConfig{Redirects: "rewrite"},
+				Repositories: []*RepositoryConfig{
+					{Name: "test", Feeds: []*feed.FeedOptions{{Type: "github", Name: "owner/repo"}}},
+				},
+			},
+			wantErr: ErrServeRedirectsInvalid,
+		},
+		{
+			name: "publish providers with publish plugin",
+			cfg: &Config{
+				Generate: GenerateConfig{PoolMode: "hierarchical"},
+				Plugins:  map[string]plugin.Command{"s3": {Command: "aarg-s3"}},
+				Publish:  PublishConfig{Plugin: "s3", Providers: []ProviderConfig{{Name: ProviderCloudflare}}},
+				Repositories: []*RepositoryConfig{
+					{Name: "test", Feeds: []*feed.FeedOptions{{Type: "github", Name: "owner/repo"}}},
+				},
+			},
+			wantErr: ErrProvidersInvalid,
+		},
+		{
+			name: "invalid precompress encoding",
+			cfg: &Config{
+				Generate: GenerateConfig{PoolMode: "hierarchical", Precompress: []string{"gzip", "zstd"}},
+				Repositories: []*RepositoryConfig{
+					{Name: "test", Feeds: []*feed.FeedOptions{{Type: "github", Name: "owner/repo"}}},
+				},
+			},
+			errSubstr: "precompress",
+		},
+		{
+			name: "invalid verify pool mode",
+			cfg: &Config{
+				Generate: GenerateConfig{PoolMode: "hierarchical", VerifyPool: "some"},
+				Repositories: []*RepositoryConfig{
+					{Name: "test", Feeds: []*feed.FeedOptions{{Type: "github", Name: "owner/repo"}}},
+				},
+			},
+			errSubstr: "verify_pool",
+		},
+		{
+			name: "unknown storage backend",
+			cfg: &Config{
+				Generate: GenerateConfig{PoolMode: "hierarchical"},
+				Storage:  StorageConfig{Backend: "postgres"},
+				Repositories: []*RepositoryConfig{
+					{Name: "test", Feeds: []*feed.FeedOptions{{Type: "github", Name: "owner/repo"}}},
+				},
+			},
+			errSubstr: "storage backend",
+		},
+		{
+			name: "manage domain without URL",
+			cfg: &Config{
+				Generate:   GenerateConfig{PoolMode: "hierarchical"},
+				Cloudflare: CloudflareConfig{ProjectName: "apt", ManageDomain: true},
+				Repositories: []*RepositoryConfig{
+					{Name: "test", Feeds: []*feed.FeedOptions{{Type: "github", Name: "owner/repo"}}},
+				},
+			},
+			errSubstr: "manage_domain requires url",
+		},
+		{
+			name: "valid repository name with dash underscore and numbers",
+			cfg: &Config{
+				Generate: GenerateConfig{
+					PoolMode: "redirect",
+				},
+				Repositories: []*RepositoryConfig{
+					{
+						Name: "my_repo-123",
+						Feeds: []*feed.FeedOptions{
+							{Type: "github", Name: "owner/repo"},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validate(tt.cfg)
+			if tt.wantErr != nil {
+				require.Error(t, err)
+				assert.ErrorIs(t, err, tt.wantErr)
+				if tt.errSubstr != "" {
+					assert.Contains(t, err.Error(), tt.errSubstr)
+				}
+			} else if tt.errSubstr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.errSubstr)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestValidateRepository(t *testing.T) {
+	tests := []struct {
+		name      string
+		repo      *RepositoryConfig
+		wantErr   error
+		errSubstr string
+	}{
+		{
+			name: "valid repository",
+			repo: &RepositoryConfig{
+				Name: "test",
+				Feeds: []*feed.FeedOptions{
+					{Type: "github", Name: "owner/repo"},
+				},
+			},
+		},
+		{
+			name: "empty name",
+			repo: &RepositoryConfig{
+				Name: "",
+				Feeds: []*feed.FeedOptions{
+					{Type: "github", Name: "owner/repo"},
+				},
+			},
+			wantErr: ErrRepositoryNameEmpty,
+		},
+		{
+			name: "reserved name - assets",
+			repo: &RepositoryConfig{
+				Name: "assets",
+				Feeds: []*feed.FeedOptions{
+					{Type: "github", Name: "owner/repo"},
+				},
+			},
+			wantErr:   ErrRepositoryNameReserved,
+			errSubstr: "assets",
+		},
+		{
+			name: "reserved name - keys",
+			repo: &RepositoryConfig{
+				Name: "keys",
+				Feeds: []*feed.FeedOptions{
+					{Type: "github", Name: "owner/repo"},
+				},
+			},
+			wantErr:   ErrRepositoryNameReserved,
+			errSubstr: "keys",
+		},
+		{
+			name: "reserved name - builds",
+			repo: &RepositoryConfig{
+				Name: "builds",
+				Feeds: []*feed.FeedOptions{
+					{Type: "github", Name: "owner/repo"},
+				},
+			},
+			wantErr:   ErrRepositoryNameReserved,
+			errSubstr: "builds",
+		},
+		{
+			name: "reserved name - exports",
+			repo: &RepositoryConfig{
+				Name: "exports",
+				Feeds: []*feed.FeedOptions{
+					{Type: "github", Name: "owner/repo"},
+				},
+			},
+			wantErr:   ErrRepositoryNameReserved,
+			errSubstr: "exports",
+		},
+		{
+			name: "reserved name - stats",
+			repo: &RepositoryConfig{
+				Name: "stats",
+				Feeds: []*feed.FeedOptions{
+					{Type: "github", Name: "owner/repo"},
+				},
+			},
+			wantErr:   ErrRepositoryNameReserved,
+			errSubstr: "stats",
+		},
+		{
+			name: "invalid characters",
+			repo: &RepositoryConfig{
+				Name: "my@repo!",
+				Feeds: []*feed.FeedOptions{
+					{Type: "github", Name: "owner/repo"},
+				},
+			},
+			wantErr:   ErrRepositoryNameInvalid,
+			errSubstr: "must contain only",
+		},
+		{
+			name: "no feeds",
+			repo: &RepositoryConfig{
+				Name:  "test",
+				Feeds: []*feed.FeedOptions{},
+			},
+			wantErr: ErrNoFeeds,
+		},
+		{
+			name: "invalid feed",
 			repo: &RepositoryConfig{
 				Name: "test",
 				Feeds: []*feed.FeedOptions{
@@ -446,6 +1502,371 @@ func TestValidateRepository(t *testing.T) {
 			},
 			wantErr: ErrFeedTypeInvalid,
 		},
+		{
+			name: "valid links",
+			repo: &RepositoryConfig{
+				Name: "test",
+				RepositoryOptions: common.RepositoryOptions{
+					Links: common.LinkOptions{
+						Homepage: "https://example.com",
+						Bugs:     "https://github.com/owner/repo/issues",
+					},
+				},
+				Feeds: []*feed.FeedOptions{
+					{Type: "github", Name: "owner/repo"},
+				},
+			},
+		},
+		{
+			name: "relative link",
+			repo: &RepositoryConfig{
+				Name: "test",
+				RepositoryOptions: common.RepositoryOptions{
+					Links: common.LinkOptions{Support: "/support"},
+				},
+				Feeds: []*feed.FeedOptions{
+					{Type: "github", Name: "owner/repo"},
+				},
+			},
+			wantErr:   ErrLinkInvalid,
+			errSubstr: "X-Support",
+		},
+		{
+			name: "valid release metadata",
+			repo: &RepositoryConfig{
+				Name: "test",
+				RepositoryOptions: common.RepositoryOptions{
+					Release: common.ReleaseOptions{
+						ReleaseMetadata: common.ReleaseMetadata{Origin: "example.com", Label: "{{.Repository}}"},
+						Distributions: map[string]common.ReleaseMetadata{
+							"noble": {Suite: "stable"},
+						},
+					},
+				},
+				Feeds: []*feed.FeedOptions{
+					{Type: "github", Name: "owner/repo"},
+				},
+			},
+		},
+		{
+			name: "invalid release template",
+			repo: &RepositoryConfig{
+				Name: "test",
+				RepositoryOptions: common.RepositoryOptions{
+					Release: common.ReleaseOptions{
+						Distributions: map[string]common.ReleaseMetadata{
+							"noble": {Label: "{{.Unknown}}"},
+						},
+					},
+				},
+				Feeds: []*feed.FeedOptions{
+					{Type: "github", Name: "owner/repo"},
+				},
+			},
+			wantErr: ErrReleaseInvalid,
+		},
+		{
+			name: "valid policy",
+			repo: &RepositoryConfig{
+				Name: "test",
+				RepositoryOptions: common.RepositoryOptions{
+					Policy: common.PolicyOptions{MaxFileSizeMB: 100, MaxPackagesPerSource: 20, Action: common.PolicyActionSkip},
+				},
+				Feeds: []*feed.FeedOptions{
+					{Type: "github", Name: "owner/repo"},
+				},
+			},
+		},
+		{
+			name: "policy with invalid action",
+			repo: &RepositoryConfig{
+				Name: "test",
+				RepositoryOptions: common.RepositoryOptions{
+					Policy: common.PolicyOptions{MaxFileSizeMB: 100, Action: "drop"},
+				},
+				Feeds: []*feed.FeedOptions{
+					{Type: "github", Name: "owner/repo"},
+				},
+			},
+			wantErr: ErrPolicyInvalid,
+		},
+		{
+			name: "policy failing on conflicts",
+			repo: &RepositoryConfig{
+				Name: "test",
+				RepositoryOptions: common.RepositoryOptions{
+					Policy: common.PolicyOptions{Conflicts: common.ConflictActionFail},
+				},
+				Feeds: []*feed.FeedOptions{
+					{Type: "github", Name: "owner/repo"},
+				},
+			},
+		},
+		{
+			name: "pin without versions",
+			repo: &RepositoryConfig{
+				Name: "test",
+				RepositoryOptions: common.RepositoryOptions{
+					Pin: []common.PinnedVersion{{FromSources: []string{"nginx"}}},
+				},
+				Feeds: []*feed.FeedOptions{
+					{Type: "github", Name: "owner/repo"},
+				},
+			},
+			wantErr: ErrPinInvalid,
+		},
+		{
+			name: "policy with invalid conflict action",
+			repo: &RepositoryConfig{
+				Name: "test",
+				RepositoryOptions: common.RepositoryOptions{
+					Policy: common.PolicyOptions{Conflicts: "skip"},
+				},
+				Feeds: []*feed.FeedOptions{
+					{Type: "github", Name: "owner/repo"},
+				},
+			},
+			wantErr: ErrPolicyInvalid,
+		},
+		{
+			name: "owners",
+			repo: &RepositoryConfig{
+				Name:   "test",
+				Owners: []string{"ops@example.com", "@org/team"},
+				Feeds: []*feed.FeedOptions{
+					{Type: "github", Name: "owner/repo"},
+				},
+			},
+		},
+		{
+			name: "owner with whitespace",
+			repo: &RepositoryConfig{
+				Name:   "test",
+				Owners: []string{"ops team"},
+				Feeds: []*feed.FeedOptions{
+					{Type: "github", Name: "owner/repo"},
+				},
+			},
+			wantErr: ErrOwnersInvalid,
+		},
+		{
+			name: "source only with sources",
+			repo: &RepositoryConfig{
+				Name: "test",
+				RepositoryOptions: common.RepositoryOptions{
+					Packages: common.PackageOptions{Source: true, SourceOnly: true},
+				},
+				Feeds: []*feed.FeedOptions{
+					{Type: "github", Name: "owner/repo"},
+				},
+			},
+		},
+		{
+			name: "source only without sources",
+			repo: &RepositoryConfig{
+				Name: "test",
+				RepositoryOptions: common.RepositoryOptions{
+					Packages: common.PackageOptions{SourceOnly: true},
+				},
+				Feeds: []*feed.FeedOptions{
+					{Type: "github", Name: "owner/repo"},
+				},
+			},
+			wantErr: ErrSourceOnlyRequiresSrc,
+		},
+		{
+			name: "valid schedule",
+			repo: &RepositoryConfig{
+				Name:     "test",
+				Schedule: "*/30 * * * *",
+				Feeds: []*feed.FeedOptions{
+					{Type: "github", Name: "owner/repo"},
+				},
+			},
+		},
+		{
+			name: "invalid schedule",
+			repo: &RepositoryConfig{
+				Name:     "test",
+				Schedule: "every hour",
+				Feeds: []*feed.FeedOptions{
+					{Type: "github", Name: "owner/repo"},
+				},
+			},
+			wantErr: common.ErrScheduleInvalid,
+		},
+		{
+			name: "debuginfod without debug packages",
+			repo: &RepositoryConfig{
+				Name: "test",
+				RepositoryOptions: common.RepositoryOptions{
+					Packages: common.PackageOptions{Debuginfod: true},
+				},
+				Feeds: []*feed.FeedOptions{
+					{Type: "github", Name: "owner/repo"},
+				},
+			},
+			wantErr: ErrDebuginfodRequiresDbg,
+		},
+		{
+			name: "always emitted debug component without debug packages",
+			repo: &RepositoryConfig{
+				Name: "test",
+				RepositoryOptions: common.RepositoryOptions{
+					Packages: common.PackageOptions{AlwaysEmitDebug: true},
+				},
+				Feeds: []*feed.FeedOptions{
+					{Type: "github", Name: "owner/repo"},
+				},
+			},
+			wantErr: ErrAlwaysEmitRequiresDbg,
+		},
+		{
+			name: "component per feed",
+			repo: &RepositoryConfig{
+				Name: "test",
+				RepositoryOptions: common.RepositoryOptions{
+					ComponentPerFeed: true,
+				},
+				Feeds: []*feed.FeedOptions{
+					{Type: "github", Name: "owner/repo", TargetComponent: "repo"},
+					{Type: "obs", Name: "home:user:project", TargetComponent: "project"},
+				},
+			},
+		},
+		{
+			name: "negative snapshots",
+			repo: &RepositoryConfig{
+				Name: "test",
+				RepositoryOptions: common.RepositoryOptions{
+					Snapshots: common.SnapshotOptions{Keep: -1},
+				},
+				Feeds: []*feed.FeedOptions{
+					{Type: "github", Name: "owner/repo"},
+				},
+			},
+			wantErr: ErrSnapshotsInvalid,
+		},
+		{
+			name: "target component without component per feed",
+			repo: &RepositoryConfig{
+				Name: "test",
+				Feeds: []*feed.FeedOptions{
+					{Type: "github", Name: "owner/repo", TargetComponent: "repo"},
+				},
+			},
+			wantErr: ErrTargetComponentInvalid,
+		},
+		{
+			name: "duplicate target components",
+			repo: &RepositoryConfig{
+				Name: "test",
+				RepositoryOptions: common.RepositoryOptions{
+					ComponentPerFeed: true,
+				},
+				Feeds: []*feed.FeedOptions{
+					{Type: "github", Name: "owner/repo", TargetComponent: "repo"},
+					{Type: "github", Name: "other/repo", TargetComponent: "repo"},
+				},
+			},
+			wantErr: ErrTargetComponentInvalid,
+		},
+		{
+			name: "debug target component",
+			repo: &RepositoryConfig{
+				Name: "test",
+				RepositoryOptions: common.RepositoryOptions{
+					ComponentPerFeed: true,
+				},
+				Feeds: []*feed.FeedOptions{
+					{Type: "github", Name: "owner/repo", TargetComponent: "debug"},
+				},
+			},
+			wantErr: ErrTargetComponentInvalid,
+		},
+		{
+			name: "invalid target component",
+			repo: &RepositoryConfig{
+				Name: "test",
+				RepositoryOptions: common.RepositoryOptions{
+					ComponentPerFeed: true,
+				},
+				Feeds: []*feed.FeedOptions{
+					{Type: "github", Name: "owner/repo", TargetComponent: "Repo"},
+				},
+			},
+			wantErr: ErrTargetComponentInvalid,
+		},
+		{
+			name: "policy failing on version regressions",
+			repo: &RepositoryConfig{
+				Name: "test",
+				RepositoryOptions: common.RepositoryOptions{
+					Policy: common.PolicyOptions{Regressions: common.PolicyActionFail},
+				},
+				Feeds: []*feed.FeedOptions{
+					{Type: "github", Name: "owner/repo"},
+				},
+			},
+		},
+		{
+			name: "policy skipping version regressions",
+			repo: &RepositoryConfig{
+				Name: "test",
+				RepositoryOptions: common.RepositoryOptions{
+					Policy: common.PolicyOptions{Regressions: common.PolicyActionSkip},
+				},
+				Feeds: []*feed.FeedOptions{
+					{Type: "github", Name: "owner/repo"},
+				},
+			},
+			wantErr: ErrPolicyInvalid,
+		},
+		{
+			name: "policy with negative limit",
+			repo: &RepositoryConfig{
+				Name: "test",
+				RepositoryOptions: common.RepositoryOptions{
+					Policy: common.PolicyOptions{MaxPackagesPerSource: -1},
+				},
+				Feeds: []*feed.FeedOptions{
+					{Type: "github", Name: "owner/repo"},
+				},
+			},
+			wantErr: ErrPolicyInvalid,
+		},
+		{
+			name: "valid upload",
+			repo: &RepositoryConfig{
+				Name:   "test",
+				Upload: UploadConfig{GitHub: "owner/repo", Tag: "apt"},
+				Feeds: []*feed.FeedOptions{
+					{Type: "github", Name: "owner/repo"},
+				},
+			},
+		},
+		{
+			name: "upload without tag",
+			repo: &RepositoryConfig{
+				Name:   "test",
+				Upload: UploadConfig{GitHub: "owner/repo"},
+				Feeds: []*feed.FeedOptions{
+					{Type: "github", Name: "owner/repo"},
+				},
+			},
+			wantErr: ErrUploadInvalid,
+		},
+		{
+			name: "upload with invalid repository",
+			repo: &RepositoryConfig{
+				Name:   "test",
+				Upload: UploadConfig{GitHub: "owner", Tag: "apt"},
+				Feeds: []*feed.FeedOptions{
+					{Type: "github", Name: "owner/repo"},
+				},
+			},
+			wantErr: ErrUploadInvalid,
+		},
 	}
 
 	for _, tt := range tests {
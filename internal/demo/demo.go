@@ -0,0 +1,177 @@
+// Package demo sets up a self-contained aarg installation built from generated fixture packages
+// It needs no network access: the packages are provided by aarg itself acting as feed plugin and published
+// into a local directory
+package demo
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/dionysius/aarg/debext"
+)
+
+const (
+	// PluginName is the name of the demo plugin in the generated configuration
+	PluginName = "demo"
+	// RepositoryName is the name of the demo repository
+	RepositoryName = "demo"
+
+	// Directories and files below the demo directory
+	ConfigFile   = "config.yaml"
+	FixturesDir  = "fixtures"
+	PublishedDir = "published" // Symlink to the current release published by the directory provider
+	keysDir      = "keys"
+	reposDir     = "repos.d"
+)
+
+// Options configures the generated demo installation
+type Options struct {
+	Dir        string   // Directory the demo is created in
+	Executable string   // aarg executable serving the demo plugin
+	PluginArgs []string // Arguments making Executable serve the demo plugin
+	Port       int      // Port of the local server, also used for the repository URL
+	Web        bool     // Compose the web page, downloads Tailwind CSS on first use
+}
+
+// Setup writes fixture packages, a throwaway signing key and the configuration of the demo
+// Existing files are replaced, so the demo can be recreated in the same directory
+// Returns the path of the configuration file
+func Setup(opts Options) (string, error) {
+	dir, err := filepath.Abs(opts.Dir)
+	if err != nil {
+		return "", err
+	}
+
+	if err := WriteFixtures(filepath.Join(dir, FixturesDir), Fixtures); err != nil {
+		return "", err
+	}
+
+	if err := writeSigningKey(filepath.Join(dir, keysDir)); err != nil {
+		return "", fmt.Errorf("failed to create signing key: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Join(dir, reposDir), 0755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(filepath.Join(dir, reposDir, RepositoryName+".yaml"), []byte(repositoryConfig(dir)), 0644); err != nil {
+		return "", err
+	}
+
+	configPath := filepath.Join(dir, ConfigFile)
+	if err := os.WriteFile(configPath, []byte(mainConfig(dir, opts)), 0644); err != nil {
+		return "", err
+	}
+
+	return configPath, nil
+}
+
+// mainConfig returns the config.yaml of the demo
+func mainConfig(dir string, opts Options) string {
+	compose := "[apt, metadata]"
+	if opts.Web {
+		compose = "[apt, web, metadata]"
+	}
+
+	args := make([]string, len(opts.PluginArgs))
+	for i, arg := range opts.PluginArgs {
+		args[i] = fmt.Sprintf("%q", arg)
+	}
+
+	return fmt.Sprintf(`# Generated by aarg demo, a self-contained installation without network access
+# Packages come from fixtures/ through the demo plugin, the published output goes to published/
+
+directories:
+  root: %[1]q
+
+signing:
+  # Throwaway key created for the demo, never use it for a real repository
+  private_key: keys/signing-private.asc
+  public_key: keys/signing-public.asc
+
+url: "http://localhost:%[2]d"
+
+generate:
+  # Plugin feeds require hierarchical pool mode, package files are part of the output
+  pool_mode: hierarchical
+  compose: %[3]s
+
+publish:
+  # Published releases are kept next to it in published.releases/
+  directory:
+    path: %[7]q
+
+serve:
+  port: %[2]d
+
+preflight:
+  disabled: true
+
+plugins:
+  %[4]s:
+    # aarg itself serves as feed plugin of the demo
+    command: %[5]q
+    args: [%[6]s]
+`, dir, opts.Port, compose, PluginName, opts.Executable, strings.Join(args, ", "), filepath.Join(dir, PublishedDir))
+}
+
+// repositoryConfig returns the repository configuration of the demo
+func repositoryConfig(dir string) string {
+	return fmt.Sprintf(`# Generated by aarg demo
+description: |
+  Demo repository generated by `+"`aarg demo`"+` from fixture packages.
+
+# Keep the last two minor versions
+retention:
+  - pattern: "*.#.*-*"
+    amount: [2]
+
+# Plugin feeds have no upstream signatures, the demo key is listed to not fall back to ~/.gnupg
+verification:
+  keys:
+    - %[3]q
+
+feeds:
+  - plugin: %[1]s
+    location: fixtures
+    settings:
+      path: %[2]q
+`, PluginName, filepath.Join(dir, FixturesDir), filepath.Join(dir, keysDir, "signing-public.asc"))
+}
+
+// writeSigningKey creates a new signing key pair in dir
+func writeSigningKey(dir string) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	entity, err := openpgp.NewEntity("aarg demo", "throwaway key", "demo@example.invalid", nil)
+	if err != nil {
+		return err
+	}
+
+	private, err := os.OpenFile(filepath.Join(dir, "signing-private.asc"), os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if err := debext.WriteArmored(private, openpgp.PrivateKeyType, func(w io.Writer) error { return entity.SerializePrivate(w, nil) }); err != nil {
+		_ = private.Close()
+		return err
+	}
+	if err := private.Close(); err != nil {
+		return err
+	}
+
+	public, err := os.Create(filepath.Join(dir, "signing-public.asc"))
+	if err != nil {
+		return err
+	}
+	if err := debext.WriteArmored(public, openpgp.PublicKeyType, entity.Serialize); err != nil {
+		_ = public.Close()
+		return err
+	}
+	return public.Close()
+}
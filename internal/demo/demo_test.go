@@ -0,0 +1,77 @@
+package demo
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/dionysius/aarg/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildDeb(t *testing.T) {
+	tests := []struct {
+		name       string
+		pkg        Package
+		wantFile   string
+		wantSource string
+	}{
+		{
+			name:       "source defaults to name",
+			pkg:        Package{Name: "hello", Version: "1.0-1", Architecture: "amd64", Description: "hello"},
+			wantFile:   "hello_1.0-1_amd64.deb",
+			wantSource: "hello",
+		},
+		{
+			name:       "epoch is not part of the file name",
+			pkg:        Package{Name: "hello-doc", Source: "hello", Version: "2:1.0-1", Architecture: "all", Description: "hello docs"},
+			wantFile:   "hello-doc_1.0-1_all.deb",
+			wantSource: "hello",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.wantFile, tt.pkg.Filename())
+
+			path := filepath.Join(t.TempDir(), tt.pkg.Filename())
+			file, err := os.Create(path)
+			require.NoError(t, err)
+			require.NoError(t, BuildDeb(file, tt.pkg))
+			require.NoError(t, file.Close())
+
+			stanza, err := deb.GetControlFileFromDeb(path)
+			require.NoError(t, err)
+			assert.Equal(t, tt.pkg.Name, stanza["Package"])
+			assert.Equal(t, tt.pkg.Version, stanza["Version"])
+			assert.Equal(t, tt.pkg.Architecture, stanza["Architecture"])
+			assert.Equal(t, tt.wantSource, stanza["Source"])
+		})
+	}
+}
+
+func TestSetup(t *testing.T) {
+	dir := t.TempDir()
+
+	configPath, err := Setup(Options{Dir: dir, Executable: "/usr/bin/aarg", PluginArgs: []string{"demo", "plugin"}, Port: 8081})
+	require.NoError(t, err)
+
+	cfg, err := config.Load(configPath)
+	require.NoError(t, err)
+
+	require.Len(t, cfg.Repositories, 1)
+	assert.Equal(t, RepositoryName, cfg.Repositories[0].Name)
+	assert.Equal(t, "http://localhost:8081", cfg.URL)
+	assert.Equal(t, []string{config.ProviderDirectory}, cfg.Publish.ProviderNames())
+	assert.Equal(t, filepath.Join(dir, PublishedDir), cfg.Publish.Directory.GetPath(cfg.Directories.Root, true))
+	assert.Equal(t, []string{"demo", "plugin"}, cfg.Plugins[PluginName].Args)
+	assert.Equal(t, []string{"apt", "metadata"}, cfg.Generate.Compose)
+
+	for _, pkg := range Fixtures {
+		assert.FileExists(t, filepath.Join(dir, FixturesDir, pkg.Distribution, pkg.Filename()))
+	}
+	assert.FileExists(t, filepath.Join(dir, keysDir, "signing-private.asc"))
+	assert.FileExists(t, filepath.Join(dir, keysDir, "signing-public.asc"))
+}
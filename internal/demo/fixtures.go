@@ -0,0 +1,68 @@
+package demo
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/dionysius/aarg/debext/testutil"
+)
+
+// Package is a fixture binary package built into a .deb file
+type Package struct {
+	Name         string
+	Source       string // Source package name, empty = Name
+	Version      string
+	Architecture string
+	Distribution string // Directory the package is placed in
+	Description  string
+}
+
+// Filename returns the .deb file name of the package
+func (p Package) Filename() string {
+	return p.testutil().Filename()
+}
+
+// testutil returns the package to build with testutil
+func (p Package) testutil() testutil.Package {
+	return testutil.Package{
+		Name:         p.Name,
+		Source:       p.Source,
+		Version:      p.Version,
+		Architecture: p.Architecture,
+		Maintainer:   "aarg demo <demo@example.invalid>",
+		Description:  p.Description + "\nGenerated by aarg demo to show the repository pipeline without network access.",
+		Files: map[string]string{
+			"./usr/share/doc/" + p.Name + "/README": fmt.Sprintf("%s %s built by aarg demo\n", p.Name, p.Version),
+		},
+	}
+}
+
+// Fixtures are the packages of the demo repository
+// Two versions in one distribution show retention and version columns, the second distribution
+// lags behind to show distribution columns with differing versions
+var Fixtures = []Package{
+	{Name: "aarg-demo", Version: "1.0.0-1", Architecture: "amd64", Distribution: "bookworm", Description: "aarg demo package"},
+	{Name: "aarg-demo", Version: "1.0.0-1", Architecture: "arm64", Distribution: "bookworm", Description: "aarg demo package"},
+	{Name: "aarg-demo", Version: "1.1.0-1", Architecture: "amd64", Distribution: "bookworm", Description: "aarg demo package"},
+	{Name: "aarg-demo", Version: "1.1.0-1", Architecture: "arm64", Distribution: "bookworm", Description: "aarg demo package"},
+	{Name: "aarg-demo-doc", Source: "aarg-demo", Version: "1.1.0-1", Architecture: "all", Distribution: "bookworm", Description: "aarg demo package documentation"},
+	{Name: "aarg-demo", Version: "1.0.0-1", Architecture: "amd64", Distribution: "noble", Description: "aarg demo package"},
+	{Name: "aarg-demo-doc", Source: "aarg-demo", Version: "1.0.0-1", Architecture: "all", Distribution: "noble", Description: "aarg demo package documentation"},
+}
+
+// WriteFixtures builds the .deb files of packages into dir laid out as {dir}/{distribution}/{file}
+func WriteFixtures(dir string, packages []Package) error {
+	for _, pkg := range packages {
+		if _, err := testutil.WriteDeb(filepath.Join(dir, pkg.Distribution), pkg.testutil()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// BuildDeb writes a minimal .deb archive of the package to w
+// It contains a control file and a README below /usr/share/doc, enough for all index fields
+func BuildDeb(w io.Writer, pkg Package) error {
+	return testutil.BuildDeb(w, pkg.testutil())
+}
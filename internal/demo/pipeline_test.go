@@ -0,0 +1,176 @@
+package demo_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dionysius/aarg/internal/app"
+	"github.com/dionysius/aarg/internal/config"
+	"github.com/dionysius/aarg/internal/demo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pluginEnv makes the test binary serve the demo plugin, like aarg demo plugin does for the aarg binary
+const pluginEnv = "AARG_DEMO_TEST_PLUGIN"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(pluginEnv) != "" {
+		if err := demo.Serve(context.Background(), os.Stdin, os.Stdout); err != nil {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// seedWebAssets places the web assets normally downloaded on first use into the downloads directory
+// The Tailwind CLI is replaced by a script writing an empty stylesheet to its -o argument
+func seedWebAssets(t *testing.T, downloads string) {
+	t.Helper()
+	tailwind := filepath.Join(downloads, "assets", "tailwindcss", "tailwindcss")
+	require.NoError(t, os.MkdirAll(filepath.Dir(tailwind), 0755))
+	script := "#!/bin/sh\nwhile [ $# -gt 0 ]; do [ \"$1\" = -o ] && : > \"$2\"; shift; done\n"
+	require.NoError(t, os.WriteFile(tailwind, []byte(script), 0755))
+
+	icons := filepath.Join(downloads, "assets", "icons")
+	require.NoError(t, os.MkdirAll(icons, 0755))
+	for _, name := range []string{"github", "apt", "obs", "ppa"} {
+		require.NoError(t, os.WriteFile(filepath.Join(icons, name+".svg"), []byte("<svg/>"), 0644))
+	}
+}
+
+// newPipeline sets up the demo in a temporary directory with the test binary serving the plugin
+// Returns the demo directory and the application of its configuration
+func newPipeline(t *testing.T, web bool) (string, *app.Application) {
+	t.Helper()
+	executable, err := os.Executable()
+	require.NoError(t, err)
+	t.Setenv(pluginEnv, "1")
+
+	dir := t.TempDir()
+	configPath, err := demo.Setup(demo.Options{Dir: dir, Executable: executable, Port: 8080, Web: web})
+	require.NoError(t, err)
+	cfg, err := config.Load(configPath)
+	require.NoError(t, err)
+	if web {
+		seedWebAssets(t, cfg.Directories.GetDownloadsPath())
+	}
+
+	application, err := app.New(context.Background(), cfg)
+	require.NoError(t, err)
+	t.Cleanup(application.Shutdown)
+	return dir, application
+}
+
+// build fetches, generates and publishes the demo repository
+func build(t *testing.T, application *app.Application, opts app.GenerateOptions) {
+	t.Helper()
+	ctx := context.Background()
+	repos := []string{demo.RepositoryName}
+	require.NoError(t, application.Fetch(ctx, repos))
+	require.NoError(t, application.Generate(ctx, repos, opts))
+	require.NoError(t, application.Publish(ctx, "", "", false))
+}
+
+func TestPipeline(t *testing.T) {
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Skip("no shell to stand in for the Tailwind CLI")
+	}
+
+	dir, application := newPipeline(t, true)
+	build(t, application, app.GenerateOptions{})
+
+	published := filepath.Join(dir, demo.PublishedDir)
+	repo := filepath.Join(published, demo.RepositoryName)
+
+	// Signed Release files of both distributions
+	for _, dist := range []string{"bookworm", "noble"} {
+		release, err := os.ReadFile(filepath.Join(repo, "dists", dist, "Release"))
+		require.NoError(t, err, dist)
+		assert.Contains(t, string(release), "Suite: "+dist)
+		assert.FileExists(t, filepath.Join(repo, "dists", dist, "InRelease"))
+		assert.FileExists(t, filepath.Join(repo, "dists", dist, "Release.gpg"))
+	}
+
+	// Retention keeps both bookworm versions, noble lags behind
+	packages, err := os.ReadFile(filepath.Join(repo, "dists", "bookworm", "main", "binary-amd64", "Packages"))
+	require.NoError(t, err)
+	assert.Contains(t, string(packages), "Package: aarg-demo\n")
+	assert.Contains(t, string(packages), "Version: 1.0.0-1\n")
+	assert.Contains(t, string(packages), "Version: 1.1.0-1\n")
+	assert.Contains(t, string(packages), "Package: aarg-demo-doc\n")
+
+	packages, err = os.ReadFile(filepath.Join(repo, "dists", "noble", "main", "binary-amd64", "Packages"))
+	require.NoError(t, err)
+	assert.Contains(t, string(packages), "Version: 1.0.0-1\n")
+	assert.NotContains(t, string(packages), "Version: 1.1.0-1\n")
+
+	// Pool files are published, not only referenced
+	for _, pkg := range demo.Fixtures {
+		matches, err := filepath.Glob(filepath.Join(repo, "pool", "*", "*", "*", pkg.Filename()))
+		require.NoError(t, err)
+		assert.Len(t, matches, 1, pkg.Filename())
+	}
+
+	// Web page of the index and the repository
+	index, err := os.ReadFile(filepath.Join(published, "index.html"))
+	require.NoError(t, err)
+	assert.Contains(t, string(index), demo.RepositoryName)
+	page, err := os.ReadFile(filepath.Join(repo, "index.html"))
+	require.NoError(t, err)
+	assert.Contains(t, string(page), "aarg-demo-doc")
+	assert.Contains(t, string(page), "1.1.0-1")
+	assert.FileExists(t, filepath.Join(published, "assets", "css", "tailwind.css"))
+
+	// The published path is a release symlink of the directory provider
+	info, err := os.Lstat(published)
+	require.NoError(t, err)
+	assert.NotZero(t, info.Mode()&os.ModeSymlink)
+}
+
+func TestPipeline_GenerateDistribution(t *testing.T) {
+	dir, application := newPipeline(t, false)
+	build(t, application, app.GenerateOptions{})
+
+	repo := filepath.Join(dir, demo.PublishedDir, demo.RepositoryName)
+	readDist := func(dist string) map[string][]byte {
+		files := make(map[string][]byte)
+		for _, name := range []string{"Release", "InRelease", "main/binary-amd64/Packages"} {
+			data, err := os.ReadFile(filepath.Join(repo, "dists", dist, name))
+			require.NoError(t, err, name)
+			files[name] = data
+		}
+		return files
+	}
+	noble := readDist("noble")
+
+	// New versions in both distributions, only bookworm is regenerated
+	updates := []demo.Package{
+		{Name: "aarg-demo", Version: "1.2.0-1", Architecture: "amd64", Distribution: "bookworm", Description: "aarg demo package"},
+		{Name: "aarg-demo", Version: "1.1.0-1", Architecture: "amd64", Distribution: "noble", Description: "aarg demo package"},
+	}
+	require.NoError(t, demo.WriteFixtures(filepath.Join(dir, demo.FixturesDir), updates))
+	// The label keeps the staging build apart from the previous one generated within the same second
+	build(t, application, app.GenerateOptions{Label: "bookworm", Distributions: []string{"bookworm"}})
+
+	packages, err := os.ReadFile(filepath.Join(repo, "dists", "bookworm", "main", "binary-amd64", "Packages"))
+	require.NoError(t, err)
+	assert.Contains(t, string(packages), "Version: 1.2.0-1\n")
+	assert.NotContains(t, string(packages), "Version: 1.0.0-1\n", "dropped by retention")
+
+	// The unselected distribution keeps its previous indexes and signatures
+	assert.Equal(t, noble, readDist("noble"))
+
+	// Pool files only referenced by the kept distribution are still published
+	for _, pkg := range demo.Fixtures {
+		if pkg.Distribution != "noble" {
+			continue
+		}
+		matches, err := filepath.Glob(filepath.Join(repo, "pool", "*", "*", "*", pkg.Filename()))
+		require.NoError(t, err)
+		assert.Len(t, matches, 1, pkg.Filename())
+	}
+}
@@ -0,0 +1,38 @@
+package demo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+
+	"github.com/dionysius/aarg/internal/plugin"
+)
+
+// Serve answers plugin requests on r and w, providing the fixtures as feed
+// The demo is published by the directory provider, the plugin only acts as feed
+func Serve(ctx context.Context, r io.Reader, w io.Writer) error {
+	server := &plugin.Server{
+		Name:  PluginName,
+		Kinds: []plugin.Kind{plugin.KindFeed},
+		Handlers: map[string]plugin.Handler{
+			plugin.MethodFetch: fetch,
+		},
+	}
+	return server.ServeIO(ctx, r, w)
+}
+
+// fetch copies the .deb files of the fixtures directory into the download directory
+func fetch(ctx context.Context, raw json.RawMessage) (any, error) {
+	var params plugin.FetchParams
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return nil, err
+	}
+
+	root := params.Settings["path"]
+	if root == "" {
+		return nil, errors.New("setting 'path' is required")
+	}
+
+	return plugin.FetchDirectory(ctx, root, params.DownloadDir)
+}
@@ -5,7 +5,9 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"path"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/alitto/pond/v2"
@@ -13,6 +15,7 @@ import (
 	"github.com/aptly-dev/aptly/utils"
 	"github.com/dionysius/aarg/debext"
 	"github.com/dionysius/aarg/internal/common"
+	"github.com/dionysius/aarg/internal/telemetry"
 )
 
 // Apt handles APT repository downloads
@@ -22,17 +25,19 @@ type Apt struct {
 	verifier   *debext.Verifier
 	storage    *common.Storage
 	pool       pond.Pool
+	shared     *SharedFetch
 }
 
 // NewApt creates a single APT feed instance.
 // Feed expansion should be done at the app level before calling this.
-func NewApt(storage *common.Storage, verifier *debext.Verifier, options *FeedOptions, repository *common.RepositoryOptions, pool pond.Pool) (*Apt, error) {
+func NewApt(storage *common.Storage, verifier *debext.Verifier, options *FeedOptions, repository *common.RepositoryOptions, pool pond.Pool, shared *SharedFetch) (*Apt, error) {
 	return &Apt{
 		options:    options,
 		repository: repository,
 		verifier:   verifier,
 		storage:    storage,
 		pool:       pool,
+		shared:     shared,
 	}, nil
 }
 
@@ -69,6 +74,12 @@ func ExpandAptFeedOptions(options *FeedOptions) []*FeedOptions {
 			targetDist = distName
 		}
 
+		// Per distribution components take precedence over the feed-level default
+		components := distMap.Components
+		if len(components) == 0 {
+			components = options.Components
+		}
+
 		// Build download URL
 		downloadURL := options.DownloadURL
 		if prefix != "" {
@@ -85,14 +96,19 @@ func ExpandAptFeedOptions(options *FeedOptions) []*FeedOptions {
 
 		// Create feed options for this single distribution
 		singleOptions := &FeedOptions{
-			Name:          downloadURL.Host + downloadURL.Path,
-			Type:          FeedTypeAPT,
-			DownloadURL:   downloadURL,
-			ProjectURL:    options.ProjectURL,
-			RelativePath:  relativePath,
-			Distributions: []DistributionMap{{Feed: distName, Target: targetDist}},
-			FromSources:   options.FromSources,
-			Packages:      options.Packages,
+			Name:            downloadURL.Host + downloadURL.Path,
+			Type:            FeedTypeAPT,
+			DownloadURL:     downloadURL,
+			ProjectURL:      options.ProjectURL,
+			RelativePath:    relativePath,
+			Distributions:   []DistributionMap{{Feed: distName, Target: targetDist, Components: components}},
+			FromSources:     options.FromSources,
+			Packages:        options.Packages,
+			Priority:        options.Priority,
+			Timeout:         options.Timeout,
+			SigningKeys:     options.SigningKeys,
+			KeyChange:       options.KeyChange,
+			TargetComponent: options.TargetComponent,
 		}
 
 		expandedOptions = append(expandedOptions, singleOptions)
@@ -112,7 +128,13 @@ func (s *Apt) Run(ctx context.Context) error {
 	// Process each dist
 	for _, distMap := range s.options.Distributions {
 		group.SubmitErr(func() error {
-			return s.processDist(ctx, distMap)
+			ctx, span := telemetry.Start(ctx, "apt.distribution",
+				telemetry.FeedNameKey.String(s.options.Name),
+				telemetry.DistributionKey.String(distMap.Feed),
+			)
+			err := s.processDist(ctx, distMap)
+			telemetry.End(span, err)
+			return err
 		})
 	}
 
@@ -143,13 +165,15 @@ func (s *Apt) processDist(ctx context.Context, distMap DistributionMap) error {
 
 	releasePath := s.storage.GetDownloadPath(localPath, "InRelease")
 
-	// Submit download request and wait for completion
-	req := &common.DownloadRequest{
-		URL:         releaseURL,
-		Destination: filepath.Join(localPath, "InRelease"),
-	}
-	group := s.storage.Download(ctx, req)
-	_, err := group.Wait()
+	// Submit download request and wait for completion, once per run for feeds shared by repositories
+	err := s.shared.Do(releaseURL+"|"+releasePath, func() error {
+		req := &common.DownloadRequest{
+			URL:         releaseURL,
+			Destination: filepath.Join(localPath, "InRelease"),
+		}
+		_, err := s.storage.Download(ctx, req).Wait()
+		return err
+	})
 	if err != nil {
 		return err
 	}
@@ -160,6 +184,11 @@ func (s *Apt) processDist(ctx context.Context, distMap DistributionMap) error {
 		return fmt.Errorf("failed to parse InRelease: %w", err)
 	}
 
+	// Never trust a silently swapped upstream key
+	if err := s.checkSigningKeys(localPath, distMap.Feed, release.Signers); err != nil {
+		return err
+	}
+
 	// Construct distribution path infix for URL construction
 	// Flat repos: "", Standard repos: "/dists/{dist}"
 	var urlPath string
@@ -168,7 +197,7 @@ func (s *Apt) processDist(ctx context.Context, distMap DistributionMap) error {
 	}
 
 	// Find and process all package indices from the Release file
-	packageFiles, err := s.processIndices(ctx, localPath, release, urlPath)
+	packageFiles, err := s.processIndices(ctx, localPath, release, urlPath, distMap.Components)
 	if err != nil {
 		return fmt.Errorf("failed to process indices: %w", err)
 	}
@@ -178,9 +207,18 @@ func (s *Apt) processDist(ctx context.Context, distMap DistributionMap) error {
 	return s.storage.LinkFilesToTrusted(ctx, allFiles)
 }
 
-func (s *Apt) processIndices(ctx context.Context, localPath string, release *debext.Release, urlPath string) ([]*common.FileForTrust, error) {
+func (s *Apt) processIndices(ctx context.Context, localPath string, release *debext.Release, urlPath string, components []string) ([]*common.FileForTrust, error) {
+	// Warn about whitelisted components the upstream doesn't provide
+	if len(release.Components) > 0 {
+		for _, comp := range components {
+			if !slices.Contains(release.Components, comp) {
+				slog.Warn("Component not found in upstream Release", "component", comp, "feed", s.options.Name, "available", release.Components)
+			}
+		}
+	}
+
 	// Find all unique package/source indices
-	indices := s.findUniqueBaseIndices(release)
+	indices := s.findUniqueBaseIndices(release, components)
 
 	// Process all indices in parallel
 	// Create subpool for index processing
@@ -235,7 +273,30 @@ func (s *Apt) processIndex(ctx context.Context, localPath string, indexPath stri
 
 	// Construct download URL
 	downloadURL := s.options.DownloadURL.JoinPath(urlPath, compressedPath).String()
+
+	// Download and parse the index once per run for feeds shared by repositories
+	pkgs, err := s.shared.Packages(downloadURL+"|"+uncompressedInfo.SHA256, func() ([]*deb.Package, error) {
+		return s.downloadIndex(ctx, localPath, indexPath, compressedPath, downloadURL, uncompressedInfo, compressedInfo, isSource)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Download all packages for this index
+	// Use localPath as the distribution for organizing downloaded files
+	packageFiles, err := s.downloadPackageFiles(ctx, localPath, pkgs, release.Signers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download packages: %w", err)
+	}
+
+	allFiles = append(allFiles, packageFiles...)
+	return allFiles, nil
+}
+
+// downloadIndex downloads, optionally decompresses and parses a package index
+func (s *Apt) downloadIndex(ctx context.Context, localPath, indexPath, compressedPath, downloadURL string, uncompressedInfo, compressedInfo utils.ChecksumInfo, isSource bool) ([]*deb.Package, error) {
 	var result string
+	var err error
 
 	// Download and optionally decompress the index
 	if compressedPath == indexPath {
@@ -255,29 +316,15 @@ func (s *Apt) processIndex(ctx context.Context, localPath string, indexPath stri
 		}
 	}
 
-	uncompressedPath := result
-
 	// Parse index
-	pkgs, err := debext.ParsePackageIndex(uncompressedPath, isSource)
-	if err != nil {
-		return nil, err
-	}
-
-	// Download all packages for this index
-	// Use localPath as the distribution for organizing downloaded files
-	packageFiles, err := s.downloadPackageFiles(ctx, localPath, pkgs)
-	if err != nil {
-		return nil, fmt.Errorf("failed to download packages: %w", err)
-	}
-
-	allFiles = append(allFiles, packageFiles...)
-	return allFiles, nil
+	return debext.ParsePackageIndex(result, isSource)
 }
 
-func (s *Apt) downloadPackageFiles(ctx context.Context, dist string, packages []*deb.Package) ([]*common.FileForTrust, error) {
+// downloadPackageFiles downloads the files of the kept packages, recording the keys the index was signed with
+func (s *Apt) downloadPackageFiles(ctx context.Context, dist string, packages []*deb.Package, signers []string) ([]*common.FileForTrust, error) {
 	// Collect packages and filter
 	// Use NoMatchKeep to preserve packages with unexpected version formats
-	collector := common.NewPackageRetentionCollector(s.repository.Retention)
+	collector := common.NewPackageRetentionCollector(s.repository.Retention, s.repository.Pin)
 
 	for _, pkg := range packages {
 		// Filter by source first
@@ -331,6 +378,7 @@ func (s *Apt) downloadPackageFiles(ctx context.Context, dist string, packages []
 				Hash:         sha256,
 				Source:       sourcePkgName,
 				Redirect:     relPath,
+				Signers:      signers,
 			})
 
 			// Submit download as parallel task
@@ -389,7 +437,8 @@ func selectSmallestFile(basePath string, filesMap map[string]utils.ChecksumInfo)
 }
 
 // findUniqueBaseIndices returns a deduplicated list of package/source index base paths from the release file
-func (s *Apt) findUniqueBaseIndices(release *debext.Release) []string {
+// If components is not empty, only indices of those components are returned
+func (s *Apt) findUniqueBaseIndices(release *debext.Release, components []string) []string {
 	var indices []string
 	seen := make(map[string]bool)
 
@@ -406,7 +455,10 @@ func (s *Apt) findUniqueBaseIndices(release *debext.Release) []string {
 
 		// Identify Packages or Sources indices
 		if baseName == "Packages" {
-			// Always include Packages indices
+			// Include Packages indices unless only sources are published
+			if s.repository.Packages.SourceOnly {
+				continue
+			}
 		} else if baseName == "Sources" {
 			if !s.repository.Packages.Source {
 				continue
@@ -415,6 +467,11 @@ func (s *Apt) findUniqueBaseIndices(release *debext.Release) []string {
 			continue
 		}
 
+		// Skip components not whitelisted
+		if !matchesComponent(basePath, components) {
+			continue
+		}
+
 		seen[basePath] = true
 		indices = append(indices, basePath)
 	}
@@ -422,6 +479,27 @@ func (s *Apt) findUniqueBaseIndices(release *debext.Release) []string {
 	return indices
 }
 
+// matchesComponent reports whether an index base path belongs to one of the components (empty = all)
+// Index paths look like "main/binary-amd64/Packages" or "main/source/Sources", flat repos have no component
+func matchesComponent(basePath string, components []string) bool {
+	if len(components) == 0 {
+		return true
+	}
+
+	component := path.Dir(path.Dir(basePath))
+	if component == "." {
+		return true
+	}
+
+	for _, comp := range components {
+		if component == comp || strings.HasPrefix(component, comp+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
 // verifyDscFile verifies the signature of a .dsc file, accepting unsigned files with a warning
 func (s *Apt) verifyDscFile(dscPath, filename string) error {
 	// Try to parse with signature verification first
@@ -4,6 +4,7 @@ import (
 	"net/url"
 	"testing"
 
+	"github.com/dionysius/aarg/internal/common"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -135,6 +136,70 @@ func TestExpandAptFeedOptions(t *testing.T) {
 	}
 }
 
+func TestExpandAptFeedOptions_Components(t *testing.T) {
+	input := &FeedOptions{
+		Type:         FeedTypeAPT,
+		DownloadURL:  mustParseURL("http://archive.ubuntu.com/ubuntu"),
+		RelativePath: "archive.ubuntu.com/ubuntu",
+		Components:   []string{"main"},
+		Distributions: []DistributionMap{
+			{Feed: "noble", Target: "noble"},
+			{Feed: "jammy", Target: "jammy", Components: []string{"main", "universe"}},
+		},
+	}
+
+	result := ExpandAptFeedOptions(input)
+	require.Len(t, result, 2)
+
+	// Feed-level components apply when the mapping has none
+	assert.Equal(t, []string{"main"}, result[0].Distributions[0].Components)
+	// Mapping components take precedence
+	assert.Equal(t, []string{"main", "universe"}, result[1].Distributions[0].Components)
+}
+
+func TestExpandAptFeedOptions_PriorityAndTimeout(t *testing.T) {
+	input := &FeedOptions{
+		Type:         FeedTypeAPT,
+		DownloadURL:  mustParseURL("http://archive.ubuntu.com/ubuntu"),
+		RelativePath: "archive.ubuntu.com/ubuntu",
+		Priority:     10,
+		Timeout:      300,
+		Distributions: []DistributionMap{
+			{Feed: "noble", Target: "noble"},
+			{Feed: "jammy", Target: "jammy"},
+		},
+	}
+
+	// Every expanded feed keeps the priority for conflict resolution and its timeout
+	for _, opts := range ExpandAptFeedOptions(input) {
+		assert.Equal(t, 10, opts.Priority)
+		assert.Equal(t, 300, opts.Timeout)
+	}
+}
+
+func TestMatchesComponent(t *testing.T) {
+	tests := []struct {
+		name       string
+		basePath   string
+		components []string
+		want       bool
+	}{
+		{name: "no whitelist", basePath: "restricted/binary-amd64/Packages", want: true},
+		{name: "whitelisted binary", basePath: "main/binary-amd64/Packages", components: []string{"main"}, want: true},
+		{name: "whitelisted source", basePath: "universe/source/Sources", components: []string{"main", "universe"}, want: true},
+		{name: "not whitelisted", basePath: "multiverse/binary-amd64/Packages", components: []string{"main"}, want: false},
+		{name: "nested component", basePath: "main/debian-installer/binary-amd64/Packages", components: []string{"main"}, want: true},
+		{name: "multi-level component", basePath: "updates/main/binary-amd64/Packages", components: []string{"updates/main"}, want: true},
+		{name: "flat repository", basePath: "Packages", components: []string{"main"}, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, matchesComponent(tt.basePath, tt.components))
+		})
+	}
+}
+
 func mustParseURL(rawURL string) *url.URL {
 	u, err := url.Parse(rawURL)
 	if err != nil {
@@ -142,3 +207,48 @@ func mustParseURL(rawURL string) *url.URL {
 	}
 	return u
 }
+
+func TestAptCheckSigningKeys(t *testing.T) {
+	const (
+		oldKey = "6ED0E7B82643E131"
+		newKey = "B7C5D7D6350947F8"
+	)
+
+	newApt := func(t *testing.T, options *FeedOptions) *Apt {
+		options.Name = "deb.example.com/debian"
+		return &Apt{options: options, storage: common.NewStorage(nil, t.TempDir(), t.TempDir())}
+	}
+
+	t.Run("records keys on first use and refuses changes", func(t *testing.T) {
+		s := newApt(t, &FeedOptions{})
+
+		require.NoError(t, s.checkSigningKeys("bookworm", "bookworm", []string{oldKey}))
+		require.NoError(t, s.checkSigningKeys("bookworm", "bookworm", []string{oldKey}))
+
+		err := s.checkSigningKeys("bookworm", "bookworm", []string{newKey})
+		require.ErrorIs(t, err, ErrSigningKeyChanged)
+		assert.Contains(t, err.Error(), newKey)
+
+		// The new key is not recorded, so it keeps failing
+		assert.ErrorIs(t, s.checkSigningKeys("bookworm", "bookworm", []string{newKey}), ErrSigningKeyChanged)
+	})
+
+	t.Run("acknowledged fingerprints match key IDs", func(t *testing.T) {
+		s := newApt(t, &FeedOptions{SigningKeys: []string{"B8B8 0B5B 623E AB6A D877  5C45 " + newKey[:4] + " " + newKey[4:8] + " " + newKey[8:12] + " " + newKey[12:]}})
+
+		require.NoError(t, s.checkSigningKeys("bookworm", "bookworm", []string{newKey}))
+		assert.ErrorIs(t, s.checkSigningKeys("bookworm", "bookworm", []string{oldKey}), ErrSigningKeyChanged)
+	})
+
+	t.Run("warn policy continues", func(t *testing.T) {
+		s := newApt(t, &FeedOptions{SigningKeys: []string{oldKey}, KeyChange: KeyChangeWarn})
+
+		assert.NoError(t, s.checkSigningKeys("bookworm", "bookworm", []string{newKey}))
+	})
+
+	t.Run("unsigned releases are left to the verifier", func(t *testing.T) {
+		s := newApt(t, &FeedOptions{SigningKeys: []string{oldKey}})
+
+		assert.NoError(t, s.checkSigningKeys("bookworm", "bookworm", nil))
+	})
+}
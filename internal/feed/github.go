@@ -5,21 +5,31 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"maps"
+	"os"
 	"path/filepath"
 	"regexp"
 	"slices"
 	"strings"
+	"sync"
 
 	"github.com/alitto/pond/v2"
 	"github.com/aptly-dev/aptly/deb"
 	"github.com/dionysius/aarg/debext"
 	"github.com/dionysius/aarg/internal/common"
+	"github.com/dionysius/aarg/internal/telemetry"
 	"github.com/google/go-github/v80/github"
 )
 
 var (
 	// githubNormalizeRegex matches characters that GitHub doesn't allow in filenames
 	githubNormalizeRegex = regexp.MustCompile(`[^a-zA-Z0-9._-]`)
+
+	// DefaultChecksumsPatterns are the checksum file names looked up in releases in no_changes mode
+	DefaultChecksumsPatterns = []string{"SHA256SUMS", "SHA256SUMS.txt", "sha256sums", "sha256sums.txt", "checksums.txt", "*_checksums.txt"}
+
+	// checksumsSignatureSuffixes are the detached signature companions of checksum files
+	checksumsSignatureSuffixes = []string{".asc", ".sig", ".gpg"}
 )
 
 // Github handles github release downloads
@@ -32,11 +42,16 @@ type Github struct {
 	verifier   *debext.Verifier
 	storage    *common.Storage
 	pool       pond.Pool
+	controls   debext.ControlStore
 	collector  any // Either *GenericRetentionCollector[githubChanges] or *GenericRetentionCollector[githubBinaryPackage]
+
+	// stats tracks asset caching per release tag
+	stats   map[string]*common.DownloadStats
+	statsMu sync.Mutex
 }
 
 // NewGithub creates a new Github feed.
-func NewGithub(storage *common.Storage, client *github.Client, verifier *debext.Verifier, options *FeedOptions, repository *common.RepositoryOptions, pool pond.Pool) (*Github, error) {
+func NewGithub(storage *common.Storage, client *github.Client, verifier *debext.Verifier, options *FeedOptions, repository *common.RepositoryOptions, pool pond.Pool, controls debext.ControlStore) (*Github, error) {
 	// parse github repository
 	parts := strings.SplitN(options.Name, "/", 2)
 	if len(parts) != 2 {
@@ -48,9 +63,9 @@ func NewGithub(storage *common.Storage, client *github.Client, verifier *debext.
 	// Choose collector type based on no_changes mode
 	var collector any
 	if options.NoChanges {
-		collector = newGithubBinaryPackageRetentionCollector(repository.Retention)
+		collector = newGithubBinaryPackageRetentionCollector(repository.Retention, repository.Pin)
 	} else {
-		collector = newGithubChangesRetentionCollector(repository.Retention)
+		collector = newGithubChangesRetentionCollector(repository.Retention, repository.Pin)
 	}
 
 	return &Github{
@@ -62,10 +77,45 @@ func NewGithub(storage *common.Storage, client *github.Client, verifier *debext.
 		verifier:   verifier,
 		storage:    storage,
 		pool:       pool,
+		controls:   controls,
 		collector:  collector,
+		stats:      make(map[string]*common.DownloadStats),
 	}, nil
 }
 
+// ExpandGithubFeedOptions adds the routed distribution mappings of a GitHub FeedOptions
+// so composers pick up the suffixed distributions next to the configured ones.
+// Only base mappings are expanded, mappings of an already routed distribution are kept as configured.
+// Without distribution mappings routed distributions are discovered from trusted storage.
+func ExpandGithubFeedOptions(options *FeedOptions) []*FeedOptions {
+	if len(options.Routes) == 0 || len(options.Distributions) == 0 {
+		return []*FeedOptions{options}
+	}
+
+	expanded := *options
+	expanded.Distributions = slices.Clone(options.Distributions)
+
+	isRouted := func(distMap DistributionMap) bool {
+		return slices.ContainsFunc(options.Routes, func(route ReleaseRoute) bool {
+			return strings.HasSuffix(distMap.Feed, route.Suffix)
+		})
+	}
+
+	for _, route := range options.Routes {
+		for _, distMap := range options.Distributions {
+			if isRouted(distMap) {
+				continue
+			}
+			routed := DistributionMap{Feed: distMap.Feed + route.Suffix, Target: distMap.Target + route.Suffix}
+			if !slices.ContainsFunc(expanded.Distributions, func(d DistributionMap) bool { return d.Feed == routed.Feed }) {
+				expanded.Distributions = append(expanded.Distributions, routed)
+			}
+		}
+	}
+
+	return []*FeedOptions{&expanded}
+}
+
 // Run executes the complete download and verification process
 func (s *Github) Run(ctx context.Context) error {
 	// Log warning if no_changes mode is enabled
@@ -73,6 +123,11 @@ func (s *Github) Run(ctx context.Context) error {
 		slog.Warn("Processing feed in no_changes mode - packages downloaded without signature verification", "feed", s.options.Name)
 	}
 
+	// Reset asset statistics for this run
+	s.statsMu.Lock()
+	s.stats = make(map[string]*common.DownloadStats)
+	s.statsMu.Unlock()
+
 	// Create subpool for release processing (limit concurrent releases)
 	releasePool := s.pool.NewSubpool(10)
 	defer releasePool.StopAndWait()
@@ -100,7 +155,13 @@ func (s *Github) Run(ctx context.Context) error {
 			}
 
 			group.SubmitErr(func() error {
-				return s.processRelease(ctx, release)
+				ctx, span := telemetry.Start(ctx, "github.release",
+					telemetry.FeedNameKey.String(s.options.Name),
+					telemetry.ReleaseKey.String(release.GetTagName()),
+				)
+				err := s.processRelease(ctx, release)
+				telemetry.End(span, err)
+				return err
 			})
 		}
 
@@ -147,7 +208,103 @@ func (s *Github) Run(ctx context.Context) error {
 		}
 	}
 
-	return group.Wait()
+	if err := group.Wait(); err != nil {
+		return err
+	}
+
+	s.logStats()
+
+	return nil
+}
+
+// downloadAsset returns the path to a release asset, downloading it unless the cached file matches the digest
+// Records whether the asset was cached in the per-release statistics
+func (s *Github) downloadAsset(ctx context.Context, release *github.RepositoryRelease, asset *github.ReleaseAsset, hashMethod, expectedHash, filename string) (string, error) {
+	tag := release.GetTagName()
+	cached := s.storage.DownloadFileExistsWithHash(hashMethod, expectedHash, tag, filename)
+
+	path := s.storage.GetDownloadPath(tag, filename)
+	if !cached {
+		downloadURL := asset.GetBrowserDownloadURL()
+
+		// Draft assets are not public, resolve a signed download URL through the authenticated API
+		if release.GetDraft() {
+			var err error
+			if downloadURL, err = s.draftAssetURL(ctx, asset); err != nil {
+				return "", err
+			}
+		}
+
+		var err error
+		path, err = s.storage.FileExistsOrDownload(ctx, hashMethod, expectedHash, downloadURL, tag, filename)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	var size int64
+	if info, err := os.Stat(path); err == nil {
+		size = info.Size()
+	}
+	s.releaseStats(tag).Record(cached, size)
+
+	return path, nil
+}
+
+// draftAssetURL returns the short-lived download URL GitHub redirects authenticated asset requests to
+func (s *Github) draftAssetURL(ctx context.Context, asset *github.ReleaseAsset) (string, error) {
+	rc, redirectURL, err := s.client.Repositories.DownloadReleaseAsset(ctx, s.owner, s.repo, asset.GetID(), nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve draft asset %s: %w", asset.GetName(), err)
+	}
+	if rc != nil {
+		_ = rc.Close()
+		return "", fmt.Errorf("failed to resolve draft asset %s: no download redirect returned", asset.GetName())
+	}
+	return redirectURL, nil
+}
+
+// releaseStats returns the statistics of a release tag
+func (s *Github) releaseStats(tag string) *common.DownloadStats {
+	s.statsMu.Lock()
+	defer s.statsMu.Unlock()
+
+	stats, ok := s.stats[tag]
+	if !ok {
+		stats = &common.DownloadStats{}
+		s.stats[tag] = stats
+	}
+	return stats
+}
+
+// Stats returns the asset caching statistics per release tag of the last run
+func (s *Github) Stats() map[string]common.DownloadStatsSnapshot {
+	s.statsMu.Lock()
+	defer s.statsMu.Unlock()
+
+	result := make(map[string]common.DownloadStatsSnapshot, len(s.stats))
+	for tag, stats := range s.stats {
+		result[tag] = stats.Snapshot()
+	}
+	return result
+}
+
+// logStats logs the asset caching statistics per release and in total
+func (s *Github) logStats() {
+	var total common.DownloadStatsSnapshot
+
+	stats := s.Stats()
+	for _, tag := range slices.Sorted(maps.Keys(stats)) {
+		release := stats[tag]
+		slog.Debug("Release assets", "feed", s.options.Name, "tag", tag,
+			"cached", release.Cached, "downloaded", release.Downloaded,
+			"bytes_saved", release.BytesSaved, "bytes_downloaded", release.BytesDownloaded)
+		total = total.Add(release)
+	}
+
+	slog.Info("Feed assets", "feed", s.options.Name, "releases", len(stats),
+		"cached", total.Cached, "downloaded", total.Downloaded,
+		"bytes_saved", total.BytesSaved, "bytes_downloaded", total.BytesDownloaded)
 }
 
 type githubChanges struct {
@@ -159,6 +316,7 @@ type githubBinaryPackage struct {
 	pkg     *deb.Package
 	release *github.RepositoryRelease
 	asset   *github.ReleaseAsset
+	hash    string // SHA256 the download was verified against
 }
 
 func (s *Github) processRelease(ctx context.Context, release *github.RepositoryRelease) error {
@@ -170,20 +328,26 @@ func (s *Github) processRelease(ctx context.Context, release *github.RepositoryR
 
 	// Check if no_changes mode is enabled
 	if s.options.NoChanges {
+		// Checksum files published with the release take precedence over GitHub's digests
+		sums, err := s.releaseChecksums(ctx, release)
+		if err != nil {
+			return err
+		}
+
 		// In no_changes mode, directly process package files without .changes
 		for _, asset := range release.Assets {
 			assetName := asset.GetName()
 			// Process .deb files (binary packages only in no_changes mode)
-			if strings.HasSuffix(assetName, ".deb") {
+			if strings.HasSuffix(assetName, ".deb") && !s.isExcludedAsset(assetName) && !s.repository.Packages.SourceOnly {
 				group.SubmitErr(func() error {
-					return s.processPackageFileNoChanges(ctx, asset, release)
+					return s.processPackageFileNoChanges(ctx, asset, release, sums)
 				})
 			}
 		}
 	} else {
 		// Normal mode: Find .changes files in this release
 		for _, asset := range release.Assets {
-			if strings.HasSuffix(asset.GetName(), ".changes") {
+			if strings.HasSuffix(asset.GetName(), ".changes") && !s.isExcludedAsset(asset.GetName()) {
 				group.SubmitErr(func() error {
 					return s.processChangesFile(ctx, asset, release)
 				})
@@ -195,12 +359,10 @@ func (s *Github) processRelease(ctx context.Context, release *github.RepositoryR
 }
 
 func (s *Github) processChangesFile(ctx context.Context, changesAsset *github.ReleaseAsset, release *github.RepositoryRelease) error {
-	tag := release.GetTagName()
-
 	// Download .changes file if not already present
 	// Use GitHub's digest for the .changes file itself
 	algo, hash := ParseGitHubDigest(changesAsset.GetDigest())
-	changesPath, err := s.storage.FileExistsOrDownload(ctx, algo, hash, changesAsset.GetBrowserDownloadURL(), tag, changesAsset.GetName())
+	changesPath, err := s.downloadAsset(ctx, release, changesAsset, algo, hash, changesAsset.GetName())
 	if err != nil {
 		return err
 	}
@@ -230,8 +392,9 @@ func (s *Github) processChangesFile(ctx context.Context, changesAsset *github.Re
 	}
 
 	// Add changes file to collector (changes files go in main component)
+	// Routed releases are retained separately in their suffixed distribution
 	changesCollector := s.collector.(*common.GenericRetentionCollector[githubChanges])
-	if err := changesCollector.Add(dist, common.MainComponent, githubChanges{changes: changes, release: release}); err != nil {
+	if err := changesCollector.Add(dist+s.routeSuffix(release), common.MainComponent, githubChanges{changes: changes, release: release}); err != nil {
 		return err
 	}
 
@@ -239,20 +402,31 @@ func (s *Github) processChangesFile(ctx context.Context, changesAsset *github.Re
 }
 
 func (s *Github) processKeptChangesFile(ctx context.Context, changes *deb.Changes, release *github.RepositoryRelease) error {
-	// Get distribution and source package from .changes file
-	dist := changes.Distribution
+	// Get distribution and source package from .changes file, routed releases go to the suffixed distribution
+	dist := changes.Distribution + s.routeSuffix(release)
 	sourcePkgName := changes.Source
 
+	// The referenced files are trusted through the signature of the .changes file
+	signers := make([]string, 0, len(changes.SignatureKeys))
+	for _, key := range changes.SignatureKeys {
+		signers = append(signers, string(key))
+	}
+
 	group := s.pool.NewGroup()
 	var fileResults [][]*common.FileForTrust
 
 	for _, referencedFile := range changes.Files {
+		// Skip excluded assets before downloading them
+		if s.isExcludedAsset(referencedFile.Filename) {
+			continue
+		}
+
 		// Add .dsc files if requested
 		if strings.HasSuffix(referencedFile.Filename, ".dsc") && s.repository.Packages.Source {
 			idx := len(fileResults)
 			fileResults = append(fileResults, nil)
 			group.SubmitErr(func() error {
-				refFiles, err := s.processDscFile(ctx, referencedFile, release, dist, sourcePkgName)
+				refFiles, err := s.processDscFile(ctx, referencedFile, release, dist, sourcePkgName, signers)
 				if err != nil {
 					return err
 				}
@@ -261,10 +435,15 @@ func (s *Github) processKeptChangesFile(ctx context.Context, changes *deb.Change
 			})
 		}
 
-		// Add binary packages
-		if strings.HasSuffix(referencedFile.Filename, ".deb") || strings.HasSuffix(referencedFile.Filename, ".ddeb") {
-			// Skip debug packages if not included
-			if debext.IsDebugByName(referencedFile.Filename) && !s.repository.Packages.Debug {
+		// Add binary packages and, if requested, their build information
+		isBinary := strings.HasSuffix(referencedFile.Filename, ".deb") || strings.HasSuffix(referencedFile.Filename, ".ddeb")
+		isBuildinfo := strings.HasSuffix(referencedFile.Filename, debext.BuildinfoExtension) && s.repository.Packages.Buildinfo
+		if isBinary || isBuildinfo {
+			// Skip binary packages if only sources are published and debug packages if not included
+			if isBinary && s.repository.Packages.SourceOnly {
+				continue
+			}
+			if isBinary && debext.IsDebugByName(referencedFile.Filename) && !s.repository.Packages.Debug {
 				continue
 			}
 
@@ -289,6 +468,7 @@ func (s *Github) processKeptChangesFile(ctx context.Context, changes *deb.Change
 					Hash:         referencedFile.Checksums.SHA256,
 					Source:       sourcePkgName,
 					Redirect:     relPath,
+					Signers:      signers,
 				}}
 				return nil
 			})
@@ -310,19 +490,30 @@ func (s *Github) processKeptChangesFile(ctx context.Context, changes *deb.Change
 }
 
 // processPackageFileNoChanges handles binary package files in no_changes mode
-func (s *Github) processPackageFileNoChanges(ctx context.Context, asset *github.ReleaseAsset, release *github.RepositoryRelease) error {
-	tag := release.GetTagName()
+// Packages are verified against the release checksums if there are any, otherwise against GitHub's digest
+func (s *Github) processPackageFileNoChanges(ctx context.Context, asset *github.ReleaseAsset, release *github.RepositoryRelease, sums map[string]string) error {
 	assetName := asset.GetName()
 
-	// Download package file using GitHub digest
 	algo, hash := ParseGitHubDigest(asset.GetDigest())
-	filePath, err := s.storage.FileExistsOrDownload(ctx, algo, hash, asset.GetBrowserDownloadURL(), tag, assetName)
+	if sums != nil {
+		sum, ok := sums[assetName]
+		if !ok {
+			slog.Warn("Skipping package not listed in release checksums", "feed", s.options.Name, "tag", release.GetTagName(), "asset", assetName)
+			return nil
+		}
+		if algo == "sha256" && !strings.EqualFold(hash, sum) {
+			return fmt.Errorf("%w: %s: GitHub digest %s does not match release checksum %s", debext.ErrChecksumMismatch, assetName, hash, sum)
+		}
+		algo, hash = "sha256", sum
+	}
+
+	filePath, err := s.downloadAsset(ctx, release, asset, algo, hash, assetName)
 	if err != nil {
 		return err
 	}
 
 	// Parse .deb file to extract metadata
-	pkg, err := debext.ParseBinary(filePath, "")
+	pkg, err := debext.ParseBinary(filePath, "", s.controls)
 	if err != nil {
 		return err
 	}
@@ -339,12 +530,13 @@ func (s *Github) processPackageFileNoChanges(ctx context.Context, asset *github.
 
 	// Add to collector for retention processing
 	// Use first target distribution for grouping since no_changes mode requires explicit dist mappings
-	dist := s.options.Distributions[0].Target
+	dist := s.options.Distributions[0].Target + s.routeSuffix(release)
 	binaryCollector := s.collector.(*common.GenericRetentionCollector[githubBinaryPackage])
 	return binaryCollector.Add(dist, common.MainComponent, githubBinaryPackage{
 		pkg:     pkg,
 		release: release,
 		asset:   asset,
+		hash:    hash,
 	})
 }
 
@@ -352,9 +544,7 @@ func (s *Github) processPackageFileNoChanges(ctx context.Context, asset *github.
 func (s *Github) processKeptBinaryPackageNoChanges(ctx context.Context, pkgData githubBinaryPackage) error {
 	pkg := pkgData.pkg
 	asset := pkgData.asset
-
-	// Get hash from asset
-	_, hash := ParseGitHubDigest(asset.GetDigest())
+	hash := pkgData.hash
 
 	// Prepare redirect path
 	assetURL := asset.GetBrowserDownloadURL()
@@ -372,10 +562,12 @@ func (s *Github) processKeptBinaryPackageNoChanges(ctx context.Context, pkgData
 	// For trusted storage, we use the source distribution structure (Feed), not the target.
 	// This allows the same file to be linked to multiple target distributions.
 	// Flat repos (Feed == "/") use "." as the distribution path, similar to APT behavior.
+	suffix := s.routeSuffix(pkgData.release)
+
 	var downloadedFiles []*common.FileForTrust
 	for _, distMap := range s.options.Distributions {
 		// Determine distribution path for trusted storage based on source structure
-		dist := distMap.Feed
+		dist := distMap.Feed + suffix
 		if dist == "/" {
 			dist = "."
 		}
@@ -392,9 +584,68 @@ func (s *Github) processKeptBinaryPackageNoChanges(ctx context.Context, pkgData
 	return s.storage.LinkFilesToTrusted(ctx, downloadedFiles)
 }
 
-func (s *Github) processDscFile(ctx context.Context, file deb.PackageFile, release *github.RepositoryRelease, dist string, sourcePkg string) ([]*common.FileForTrust, error) {
-	tag := release.GetTagName()
+// releaseChecksums downloads and parses the checksum file of a release matching the checksums patterns
+// A detached signature next to it, or a clear signature, is verified against the trusted keys
+// Returns nil if the release has no checksum file
+func (s *Github) releaseChecksums(ctx context.Context, release *github.RepositoryRelease) (map[string]string, error) {
+	patterns := s.options.Checksums
+	if len(patterns) == 0 {
+		patterns = DefaultChecksumsPatterns
+	}
+
+	assets := make(map[string]*github.ReleaseAsset, len(release.Assets))
+	for _, asset := range release.Assets {
+		assets[asset.GetName()] = asset
+	}
+
+	// Patterns are evaluated in order, the first matching asset wins
+	var checksumsAsset *github.ReleaseAsset
+	for _, pattern := range patterns {
+		for _, asset := range release.Assets {
+			if m, _ := filepath.Match(pattern, asset.GetName()); m && !s.isExcludedAsset(asset.GetName()) {
+				checksumsAsset = asset
+				break
+			}
+		}
+		if checksumsAsset != nil {
+			break
+		}
+	}
+	if checksumsAsset == nil {
+		return nil, nil
+	}
+
+	algo, hash := ParseGitHubDigest(checksumsAsset.GetDigest())
+	checksumsPath, err := s.downloadAsset(ctx, release, checksumsAsset, algo, hash, checksumsAsset.GetName())
+	if err != nil {
+		return nil, err
+	}
+
+	var signaturePath string
+	for _, suffix := range checksumsSignatureSuffixes {
+		signatureAsset, ok := assets[checksumsAsset.GetName()+suffix]
+		if !ok {
+			continue
+		}
+		algo, hash := ParseGitHubDigest(signatureAsset.GetDigest())
+		if signaturePath, err = s.downloadAsset(ctx, release, signatureAsset, algo, hash, signatureAsset.GetName()); err != nil {
+			return nil, err
+		}
+		break
+	}
 
+	sums, signed, err := debext.ParseChecksumsFile(checksumsPath, signaturePath, s.verifier)
+	if err != nil {
+		return nil, fmt.Errorf("release %s: %w", release.GetTagName(), err)
+	}
+
+	slog.Debug("Using release checksums", "feed", s.options.Name, "tag", release.GetTagName(),
+		"file", checksumsAsset.GetName(), "signed", signed, "entries", len(sums))
+
+	return sums, nil
+}
+
+func (s *Github) processDscFile(ctx context.Context, file deb.PackageFile, release *github.RepositoryRelease, dist string, sourcePkg string, signers []string) ([]*common.FileForTrust, error) {
 	asset, err := s.findFileInRelease(file, release)
 	if err != nil {
 		return nil, err
@@ -402,7 +653,7 @@ func (s *Github) processDscFile(ctx context.Context, file deb.PackageFile, relea
 
 	// Download .dsc file if not already present
 	// Use checksum from .changes file (Debian chain of trust)
-	dscPath, err := s.storage.FileExistsOrDownload(ctx, "sha256", file.Checksums.SHA256, asset.GetBrowserDownloadURL(), tag, file.Filename)
+	dscPath, err := s.downloadAsset(ctx, release, asset, "sha256", file.Checksums.SHA256, file.Filename)
 	if err != nil {
 		return nil, err
 	}
@@ -449,6 +700,7 @@ func (s *Github) processDscFile(ctx context.Context, file deb.PackageFile, relea
 		Hash:         file.Checksums.SHA256,
 		Source:       sourcePkg,
 		Redirect:     relPath,
+		Signers:      signers,
 	})
 
 	// Download all referenced files in parallel (excluding .dsc itself)
@@ -466,6 +718,7 @@ func (s *Github) processDscFile(ctx context.Context, file deb.PackageFile, relea
 			Distribution: dist,
 			Hash:         referencedFile.Checksums.SHA256,
 			Source:       sourcePkg,
+			Signers:      signers,
 		})
 
 		// Submit download as parallel task
@@ -497,8 +750,6 @@ func (s *Github) processDscFile(ctx context.Context, file deb.PackageFile, relea
 }
 
 func (s *Github) downloadReferencedFileWithAsset(ctx context.Context, file deb.PackageFile, release *github.RepositoryRelease) (string, *github.ReleaseAsset, error) {
-	tag := release.GetTagName()
-
 	asset, err := s.findFileInRelease(file, release)
 	if err != nil {
 		return "", nil, err
@@ -506,7 +757,7 @@ func (s *Github) downloadReferencedFileWithAsset(ctx context.Context, file deb.P
 
 	// Download file if not already present
 	// Use checksum from Debian metadata (.changes or .dsc file) to maintain chain of trust
-	filePath, err := s.storage.FileExistsOrDownload(ctx, "sha256", file.Checksums.SHA256, asset.GetBrowserDownloadURL(), tag, file.Filename)
+	filePath, err := s.downloadAsset(ctx, release, asset, "sha256", file.Checksums.SHA256, file.Filename)
 	return filePath, asset, err
 }
 
@@ -551,9 +802,11 @@ func (s *Github) findFileInRelease(file deb.PackageFile, release *github.Reposit
 // grouping by source name only and arch is always "source"
 func newGithubChangesRetentionCollector(
 	retention []common.RetentionPolicy,
+	pins []common.PinnedVersion,
 ) *common.GenericRetentionCollector[githubChanges] {
 	return common.NewGenericRetentionCollector(
 		retention,
+		pins,
 		func(pkg githubChanges) (string, string, string, string) {
 			return pkg.changes.Source, pkg.changes.Source, debext.SourceArchitecture, pkg.changes.GetField("Version")
 		},
@@ -564,9 +817,11 @@ func newGithubChangesRetentionCollector(
 // grouping by package name and architecture
 func newGithubBinaryPackageRetentionCollector(
 	retention []common.RetentionPolicy,
+	pins []common.PinnedVersion,
 ) *common.GenericRetentionCollector[githubBinaryPackage] {
 	return common.NewGenericRetentionCollector(
 		retention,
+		pins,
 		func(pkg githubBinaryPackage) (string, string, string, string) {
 			// Get source name from package
 			sourceName := debext.GetSourceNameFromPackage(pkg.pkg)
@@ -575,17 +830,41 @@ func newGithubBinaryPackageRetentionCollector(
 	)
 }
 
-// matchesReleaseType checks if a release matches the configured release type filters.
-func (s *Github) matchesReleaseType(release *github.RepositoryRelease) bool {
-	// Determine the release type
-	var releaseType ReleaseType
+// githubReleaseType determines the release type of a GitHub release
+func githubReleaseType(release *github.RepositoryRelease) ReleaseType {
 	if release.GetDraft() {
-		releaseType = ReleaseTypeDraft
+		return ReleaseTypeDraft
 	} else if release.GetPrerelease() {
-		releaseType = ReleaseTypePrerelease
-	} else {
-		releaseType = ReleaseTypeRelease
+		return ReleaseTypePrerelease
 	}
+	return ReleaseTypeRelease
+}
+
+// routeSuffix returns the distribution suffix of the first route matching the release, or empty
+func (s *Github) routeSuffix(release *github.RepositoryRelease) string {
+	releaseType := githubReleaseType(release)
+	for _, route := range s.options.Routes {
+		if route.Matches(releaseType, release.GetTagName()) {
+			return route.Suffix
+		}
+	}
+	return ""
+}
+
+// isExcludedAsset reports whether an asset name matches any of the exclude patterns.
+func (s *Github) isExcludedAsset(name string) bool {
+	for _, pattern := range s.options.ExcludeAssets {
+		if m, _ := filepath.Match(pattern, name); m {
+			slog.Debug("Asset excluded", "feed", s.options.Name, "asset", name, "pattern", pattern)
+			return true
+		}
+	}
+	return false
+}
+
+// matchesReleaseType checks if a release matches the configured release type filters.
+func (s *Github) matchesReleaseType(release *github.RepositoryRelease) bool {
+	releaseType := githubReleaseType(release)
 
 	// No filter = only normal releases
 	if len(s.options.Releases) == 0 {
@@ -612,3 +891,97 @@ func (s *Github) shouldIncludeDistribution(dist string) bool {
 
 	return false
 }
+
+// IngestChanges verifies a .changes file placed in the downloads directory by an external tool
+// and links it with all referenced files found next to it into trusted storage.
+// Files are expected in the same layout fetch uses: <tag>/<filename>.
+func (s *Github) IngestChanges(ctx context.Context, changesPath string) error {
+	changes, err := debext.ParseChanges(changesPath, s.verifier)
+	if err != nil {
+		return err
+	}
+
+	dist := changes.Distribution
+	sourcePkgName := changes.Source
+
+	// Apply the same filters as fetch
+	if !s.shouldIncludeDistribution(dist) ||
+		!common.MatchesGlobPatterns(s.options.FromSources, sourcePkgName) ||
+		!common.MatchesGlobPatterns(s.options.Packages, sourcePkgName) {
+		slog.Debug("Skipping filtered .changes file", "file", changesPath)
+		return nil
+	}
+
+	// Tag directory relative to the feed's download scope
+	dir := filepath.Dir(changesPath)
+	tag, err := filepath.Rel(s.storage.GetDownloadPath(), dir)
+	if err != nil {
+		return err
+	}
+
+	var files []*common.FileForTrust
+	for _, referencedFile := range changes.Files {
+		isDsc := strings.HasSuffix(referencedFile.Filename, ".dsc")
+		isBinary := strings.HasSuffix(referencedFile.Filename, ".deb") || strings.HasSuffix(referencedFile.Filename, ".ddeb")
+		isBuildinfo := strings.HasSuffix(referencedFile.Filename, debext.BuildinfoExtension)
+
+		if isDsc && !s.repository.Packages.Source {
+			continue
+		}
+		if isBinary && (s.repository.Packages.SourceOnly || debext.IsDebugByName(referencedFile.Filename) && !s.repository.Packages.Debug) {
+			continue
+		}
+		if isBuildinfo && !s.repository.Packages.Buildinfo {
+			continue
+		}
+		if !isDsc && !isBinary && !isBuildinfo {
+			continue
+		}
+
+		file, err := s.ingestFile(dir, tag, referencedFile, dist, sourcePkgName)
+		if err != nil {
+			return err
+		}
+		files = append(files, file)
+
+		// Source packages reference further files (orig tarball, debian tarball)
+		if isDsc {
+			pkg, err := debext.ParseSource(file.Path, &debext.Verifier{
+				Verifier:         s.verifier.Verifier,
+				AcceptUnsigned:   true, // Checksum chain from the signed .changes file
+				IgnoreSignatures: s.verifier.IgnoreSignatures,
+			}, "")
+			if err != nil {
+				return err
+			}
+
+			for _, sourceFile := range pkg.Files() {
+				if sourceFile.Filename == referencedFile.Filename {
+					continue
+				}
+				file, err := s.ingestFile(dir, tag, sourceFile, dist, sourcePkgName)
+				if err != nil {
+					return err
+				}
+				files = append(files, file)
+			}
+		}
+	}
+
+	return s.storage.LinkFilesToTrusted(ctx, files)
+}
+
+// ingestFile checks that a referenced file exists next to its .changes file with the expected checksum
+func (s *Github) ingestFile(dir, tag string, file deb.PackageFile, dist, sourcePkg string) (*common.FileForTrust, error) {
+	if !s.storage.DownloadFileExistsWithHash("sha256", file.Checksums.SHA256, tag, file.Filename) {
+		return nil, fmt.Errorf("referenced file %s missing or checksum mismatch in %s", file.Filename, dir)
+	}
+
+	return &common.FileForTrust{
+		Path:         filepath.Join(dir, file.Filename),
+		Distribution: dist,
+		Hash:         file.Checksums.SHA256,
+		Source:       sourcePkg,
+		Redirect:     tag + "/" + NormalizeGithubFilename(file.Filename),
+	}, nil
+}
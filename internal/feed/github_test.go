@@ -0,0 +1,76 @@
+package feed
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpandGithubFeedOptions(t *testing.T) {
+	tests := []struct {
+		name   string
+		dists  []DistributionMap
+		routes []ReleaseRoute
+		want   []DistributionMap
+	}{
+		{
+			name:  "no routes",
+			dists: []DistributionMap{{Feed: "noble", Target: "noble"}},
+			want:  []DistributionMap{{Feed: "noble", Target: "noble"}},
+		},
+		{
+			name:   "discover mode unchanged",
+			routes: []ReleaseRoute{{Suffix: "-beta"}},
+		},
+		{
+			name: "routed distributions appended",
+			dists: []DistributionMap{
+				{Feed: "noble", Target: "noble"},
+				{Feed: "xUbuntu_24.04", Target: "stable"},
+			},
+			routes: []ReleaseRoute{{Releases: []ReleaseType{ReleaseTypePrerelease}, Suffix: "-beta"}},
+			want: []DistributionMap{
+				{Feed: "noble", Target: "noble"},
+				{Feed: "xUbuntu_24.04", Target: "stable"},
+				{Feed: "noble-beta", Target: "noble-beta"},
+				{Feed: "xUbuntu_24.04-beta", Target: "stable-beta"},
+			},
+		},
+		{
+			name:   "explicit mapping not duplicated",
+			dists:  []DistributionMap{{Feed: "noble", Target: "noble"}, {Feed: "noble-beta", Target: "testing"}},
+			routes: []ReleaseRoute{{Suffix: "-beta"}},
+			want: []DistributionMap{
+				{Feed: "noble", Target: "noble"},
+				{Feed: "noble-beta", Target: "testing"},
+			},
+		},
+		{
+			name:  "routed mappings not expanded by other routes",
+			dists: []DistributionMap{{Feed: "noble", Target: "noble"}, {Feed: "noble-beta", Target: "testing"}},
+			routes: []ReleaseRoute{
+				{Releases: []ReleaseType{ReleaseTypePrerelease}, Suffix: "-beta"},
+				{Releases: []ReleaseType{ReleaseTypeDraft}, Suffix: "-nightly"},
+			},
+			want: []DistributionMap{
+				{Feed: "noble", Target: "noble"},
+				{Feed: "noble-beta", Target: "testing"},
+				{Feed: "noble-nightly", Target: "noble-nightly"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := &FeedOptions{Type: FeedTypeGitHub, Name: "owner/repo", Distributions: tt.dists, Routes: tt.routes}
+
+			result := ExpandGithubFeedOptions(options)
+			require.Len(t, result, 1)
+			assert.Equal(t, tt.want, result[0].Distributions)
+
+			// Original options are not modified
+			assert.Equal(t, tt.dists, options.Distributions)
+		})
+	}
+}
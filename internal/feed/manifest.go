@@ -0,0 +1,186 @@
+package feed
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/dionysius/aarg/internal/common"
+	"github.com/dionysius/aarg/internal/log"
+	"gopkg.in/yaml.v3"
+)
+
+// ManifestFileName is the name of a downloaded manifest in the download directory of its feed
+const ManifestFileName = "manifest.yaml"
+
+// ErrManifestInvalid is returned for manifests that can't be fetched safely
+var ErrManifestInvalid = errors.New("invalid manifest")
+
+// Manifest is a curated list of package files with their checksums, written as YAML or JSON
+// It's meant to be kept in version control, so changes to the published files are reviewed as diff
+type Manifest struct {
+	Files []ManifestFile `yaml:"files"`
+}
+
+// ManifestFile is a file listed in a manifest
+type ManifestFile struct {
+	URL          string `yaml:"url"`              // Direct download URL of the file
+	SHA256       string `yaml:"sha256"`           // Expected SHA256 checksum of the file
+	Distribution string `yaml:"distribution"`     // Distribution the file is placed in
+	Source       string `yaml:"source,omitempty"` // Source package name, empty = package name of the file name
+}
+
+// Filename returns the file name of the URL
+func (f ManifestFile) Filename() string {
+	u, err := url.Parse(f.URL)
+	if err != nil {
+		return ""
+	}
+	return path.Base(u.Path)
+}
+
+// ParseManifest parses and validates a manifest
+// Every file needs an http(s) URL, a SHA256 checksum and a distribution, file names must be unique per distribution
+func ParseManifest(data []byte) (*Manifest, error) {
+	// Unknown fields are rejected, a misspelled key must not silently change what is published
+	var manifest Manifest
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&manifest); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%w: %w", ErrManifestInvalid, err)
+	}
+
+	seen := make(map[string]string)
+	for i, file := range manifest.Files {
+		u, err := url.Parse(file.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("%w: file %d: url must be an absolute http or https URL: %q", ErrManifestInvalid, i+1, file.URL)
+		}
+
+		name := file.Filename()
+		if name == "" || name == "/" || name == "." || !filepath.IsLocal(name) {
+			return nil, fmt.Errorf("%w: file %d: url has no file name: %s", ErrManifestInvalid, i+1, file.URL)
+		}
+		if sum, err := hex.DecodeString(file.SHA256); err != nil || len(sum) != 32 {
+			return nil, fmt.Errorf("%w: %s: sha256 must be 64 hex characters", ErrManifestInvalid, name)
+		}
+		if file.Distribution == "" || !filepath.IsLocal(file.Distribution) || strings.ContainsAny(file.Distribution, `/\`) {
+			return nil, fmt.Errorf("%w: %s: distribution is required and must be a plain name", ErrManifestInvalid, name)
+		}
+		if file.Source != "" && !filepath.IsLocal(file.Source) {
+			return nil, fmt.Errorf("%w: %s: source must be a plain name", ErrManifestInvalid, name)
+		}
+
+		key := file.Distribution + "/" + name
+		if previous, exists := seen[key]; exists && previous != file.URL {
+			return nil, fmt.Errorf("%w: %s listed twice in %s with different URLs", ErrManifestInvalid, name, file.Distribution)
+		}
+		seen[key] = file.URL
+	}
+
+	return &manifest, nil
+}
+
+// ManifestFeed fetches the files listed in a manifest.
+// Files are trusted by the checksums of the manifest since there is no upstream signature to verify.
+type ManifestFeed struct {
+	options *FeedOptions
+	storage *common.Storage
+}
+
+// NewManifest creates a feed fetching the files of the manifest in the options
+func NewManifest(storage *common.Storage, options *FeedOptions) (*ManifestFeed, error) {
+	return &ManifestFeed{
+		options: options,
+		storage: storage,
+	}, nil
+}
+
+// Run loads the manifest, downloads missing or changed files and links them into trusted storage
+func (s *ManifestFeed) Run(ctx context.Context) error {
+	manifest, err := s.load(ctx)
+	if err != nil {
+		return err
+	}
+
+	var requests []*common.DownloadRequest
+	var trustFiles []*common.FileForTrust
+	for _, file := range manifest.Files {
+		trustFile := s.trustFile(file)
+		if trustFile == nil {
+			continue
+		}
+
+		relPath := filepath.Join(file.Distribution, file.Filename())
+		if !s.storage.DownloadFileExistsWithHash("sha256", file.SHA256, relPath) {
+			requests = append(requests, &common.DownloadRequest{
+				URL:         file.URL,
+				Destination: relPath,
+				Checksum:    file.SHA256,
+			})
+		}
+		trustFiles = append(trustFiles, trustFile)
+	}
+
+	if len(requests) > 0 {
+		if _, err := s.storage.Download(ctx, requests...).Wait(); err != nil {
+			return err
+		}
+	}
+
+	if err := s.storage.LinkFilesToTrusted(ctx, trustFiles); err != nil {
+		return err
+	}
+
+	slog.Info("Fetched manifest feed", "manifest", s.options.Name, "files", len(trustFiles), "downloaded", len(requests), log.Success())
+	return nil
+}
+
+// load reads a local manifest or downloads a remote one, e.g. the raw file of a git repository
+func (s *ManifestFeed) load(ctx context.Context) (*Manifest, error) {
+	location := s.options.Manifest
+
+	if strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://") {
+		req := &common.DownloadRequest{URL: location, Destination: ManifestFileName}
+		if _, err := s.storage.Download(ctx, req).Wait(); err != nil {
+			return nil, fmt.Errorf("failed to download manifest: %w", err)
+		}
+		location = s.storage.GetDownloadPath(ManifestFileName)
+	}
+
+	data, err := os.ReadFile(location)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	manifest, err := ParseManifest(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", s.options.Name, err)
+	}
+	return manifest, nil
+}
+
+// trustFile maps a manifest file to its target distribution, nil if filtered out
+func (s *ManifestFeed) trustFile(file ManifestFile) *common.FileForTrust {
+	filename := file.Filename()
+	dist, source, ok := s.options.selectFile(filename, file.Distribution, file.Source)
+	if !ok {
+		return nil
+	}
+
+	return &common.FileForTrust{
+		Path:         s.storage.GetDownloadPath(file.Distribution, filename),
+		Distribution: dist,
+		Hash:         file.SHA256,
+		Source:       source,
+	}
+}
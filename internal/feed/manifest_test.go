@@ -0,0 +1,92 @@
+package feed
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSHA256 = "2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"
+
+func TestParseManifest(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr bool
+	}{
+		{
+			name: "yaml",
+			data: `
+files:
+  - url: https://example.com/tools/foo_1.0_amd64.deb
+    sha256: ` + testSHA256 + `
+    distribution: bookworm
+  - url: https://example.com/tools/foo_1.0_amd64.deb
+    sha256: ` + testSHA256 + `
+    distribution: trixie
+`,
+		},
+		{
+			name: "json",
+			data: `{"files": [{"url": "https://example.com/foo_1.0_amd64.deb", "sha256": "` + testSHA256 + `", "distribution": "bookworm", "source": "foo-src"}]}`,
+		},
+		{
+			name: "empty",
+			data: `files: []`,
+		},
+		{
+			name:    "not http",
+			data:    `files: [{url: "ftp://example.com/foo_1.0_amd64.deb", sha256: ` + testSHA256 + `, distribution: bookworm}]`,
+			wantErr: true,
+		},
+		{
+			name:    "no file name",
+			data:    `files: [{url: "https://example.com/", sha256: ` + testSHA256 + `, distribution: bookworm}]`,
+			wantErr: true,
+		},
+		{
+			name:    "short checksum",
+			data:    `files: [{url: "https://example.com/foo_1.0_amd64.deb", sha256: abc, distribution: bookworm}]`,
+			wantErr: true,
+		},
+		{
+			name:    "missing distribution",
+			data:    `files: [{url: "https://example.com/foo_1.0_amd64.deb", sha256: ` + testSHA256 + `}]`,
+			wantErr: true,
+		},
+		{
+			name:    "distribution with path",
+			data:    `files: [{url: "https://example.com/foo_1.0_amd64.deb", sha256: ` + testSHA256 + `, distribution: ../bookworm}]`,
+			wantErr: true,
+		},
+		{
+			name: "same file name from different urls",
+			data: `
+files:
+  - {url: "https://a.example.com/foo_1.0_amd64.deb", sha256: ` + testSHA256 + `, distribution: bookworm}
+  - {url: "https://b.example.com/foo_1.0_amd64.deb", sha256: ` + testSHA256 + `, distribution: bookworm}
+`,
+			wantErr: true,
+		},
+		{
+			name:    "unknown field",
+			data:    `files: [{url: "https://example.com/foo_1.0_amd64.deb", sha256: ` + testSHA256 + `, distribution: bookworm, dist: trixie}]`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manifest, err := ParseManifest([]byte(tt.data))
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrManifestInvalid)
+				return
+			}
+			require.NoError(t, err)
+			for _, file := range manifest.Files {
+				assert.Equal(t, "foo_1.0_amd64.deb", file.Filename())
+			}
+		})
+	}
+}
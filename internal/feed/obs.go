@@ -1,26 +1,76 @@
 package feed
 
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/alitto/pond/v2"
+	"github.com/dionysius/aarg/debext"
+	"github.com/dionysius/aarg/internal/common"
+	"github.com/dionysius/aarg/internal/log"
+)
+
+// ErrOBSAPI is returned if the OBS API can't be queried
+var ErrOBSAPI = errors.New("OBS API request failed")
+
+// obsMetadataFiles are the repository metadata files OBS publishes next to the packages
+var obsMetadataFiles = []string{"Packages", "Sources", "Release", "InRelease", "repodata", "repocache"}
+
+// OBSCredentials authenticate requests to the OBS API, api.opensuse.org requires an account
+type OBSCredentials struct {
+	Username string
+	Password string
+}
+
+// obsDirectory is a directory listing of the OBS API
+type obsDirectory struct {
+	Entries []struct {
+		Name string `xml:"name,attr"`
+	} `xml:"entry"`
+}
+
 // ExpandOBSFeedOptions expands an OBS FeedOptions into flat APT FeedOptions,
 // one per distribution. OBS distributions are converted to APT prefix notation
 // where each OBS dist becomes a prefix with a flat repo (/).
+// Feeds using the OBS API are not expanded, they discover repositories themselves.
 func ExpandOBSFeedOptions(options *FeedOptions) []*FeedOptions {
+	if options.OBSAPI != nil {
+		return []*FeedOptions{options}
+	}
+
 	// Convert OBS distributions to APT prefix notation: "Debian_12" -> "Debian_12/"
 	aptOptions := &FeedOptions{
-		Name:          options.Name,
-		Type:          FeedTypeAPT,
-		DownloadURL:   options.DownloadURL,
-		ProjectURL:    options.ProjectURL,
-		RelativePath:  options.RelativePath,
-		FromSources:   options.FromSources,
-		Packages:      options.Packages,
-		Distributions: make([]DistributionMap, len(options.Distributions)),
+		Name:            options.Name,
+		Type:            FeedTypeAPT,
+		DownloadURL:     options.DownloadURL,
+		ProjectURL:      options.ProjectURL,
+		RelativePath:    options.RelativePath,
+		Components:      options.Components,
+		FromSources:     options.FromSources,
+		Packages:        options.Packages,
+		Priority:        options.Priority,
+		Timeout:         options.Timeout,
+		SigningKeys:     options.SigningKeys,
+		KeyChange:       options.KeyChange,
+		TargetComponent: options.TargetComponent,
+		Distributions:   make([]DistributionMap, len(options.Distributions)),
 	}
 
 	for i, distMap := range options.Distributions {
 		// Convert "Debian_12" -> "Debian_12/" (prefix with flat repo)
 		aptOptions.Distributions[i] = DistributionMap{
-			Feed:   distMap.Feed + "/",
-			Target: distMap.Target,
+			Feed:       distMap.Feed + "/",
+			Target:     distMap.Target,
+			Components: distMap.Components,
 		}
 	}
 
@@ -28,59 +78,292 @@ func ExpandOBSFeedOptions(options *FeedOptions) []*FeedOptions {
 	return ExpandAptFeedOptions(aptOptions)
 }
 
-// TODO: Support Ubuntu Debug Packages (.ddeb files)
-//
-// Issue: https://github.com/openSUSE/open-build-service/issues/19057
-//
-// Problem:
-// OBS builds Ubuntu debug packages with .ddeb extension but does NOT include them
-// in the Packages index. This is inconsistent with Debian repositories where debug
-// packages (.deb with -dbgsym suffix) ARE included in the Packages index.
-//
-// Example:
-// - Debian (works): https://download.opensuse.org/repositories/home:/dionysius:/immich/Debian_13/Packages
-//   Contains: libvips-tools-dbgsym_8.17.3-1_amd64.deb with full checksums
-//
-// - Ubuntu (broken): https://download.opensuse.org/repositories/home:/dionysius:/immich/xUbuntu_24.04/Packages
-//   Missing: libvips-tools-dbgsym_8.17.3-1_amd64.ddeb (but file exists in amd64/ directory)
-//
-// Recommended Solution: Use OBS Public API
-//
-// The OBS API reliably lists ALL built files including .ddeb packages:
-//
-//   API URL Format:
-//   https://api.opensuse.org/public/build/{project}/{repository}/{arch}/{package}
-//
-//   Example:
-//   curl -fsSL "https://api.opensuse.org/public/build/home:dionysius:immich/xUbuntu_24.04/x86_64/vips"
-//
-//   Returns XML with:
-//   <binarylist>
-//     <binary filename="libvips-tools-dbgsym_8.17.3-1_amd64.ddeb" size="51610" mtime="1764605048"/>
-//     <binary filename="libvips42t64-dbgsym_8.17.3-1_amd64.ddeb" size="3211272" mtime="1764605048"/>
-//     ...
-//   </binarylist>
-//
-// Advantages:
-// - Lists ALL built files including .ddeb
-// - Provides size and mtime metadata for verification
-// - Structured XML format (programmatically reliable)
-// - No authentication required for public projects
-//
-// Disadvantages:
-// - No checksums (MD5/SHA256) in API response
-// - Need to construct download URLs manually
-// - Requires XML parsing
-//
-// Verification Strategy:
-// 1. Query API to get expected file size
-// 2. Download file from: https://download.opensuse.org/repositories/{project}/{repo}/{arch}/{filename}
-// 3. Verify downloaded file size matches API metadata
-// 4. Optionally compute local checksum for integrity
-//
-// Implementation Notes:
-// - Parse XML response from OBS API
-// - Filter for .ddeb files
-// - Match with corresponding .deb packages
-// - Store metadata for verification
-// - Consider caching API responses to reduce load
+// OBSFeed fetches the binaries an OBS project publishes, listed by the published binaries endpoint of the OBS API.
+// Unlike the APT expansion it doesn't depend on the Packages and Release files OBS generates, which lag behind
+// the published binaries and miss Ubuntu debug packages (.ddeb): https://github.com/openSUSE/open-build-service/issues/19057
+// The listing has no checksums, files are trusted by fetching listing and files over HTTPS from the OBS instance.
+type OBSFeed struct {
+	options     *FeedOptions
+	storage     *common.Storage
+	client      *http.Client
+	credentials OBSCredentials
+	verifier    *debext.Verifier
+	repository  *common.RepositoryOptions
+	pool        pond.Pool
+	controls    debext.ControlStore
+}
+
+// NewOBS creates a feed fetching the published binaries of an OBS project through its API
+func NewOBS(storage *common.Storage, client *http.Client, credentials OBSCredentials, verifier *debext.Verifier, options *FeedOptions, repository *common.RepositoryOptions, pool pond.Pool, controls debext.ControlStore) (*OBSFeed, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &OBSFeed{
+		options:     options,
+		storage:     storage,
+		client:      client,
+		credentials: credentials,
+		verifier:    verifier,
+		repository:  repository,
+		pool:        pool,
+		controls:    controls,
+	}, nil
+}
+
+// Run lists the published repositories, downloads their package files and links them into trusted storage
+func (s *OBSFeed) Run(ctx context.Context) error {
+	repos := make([]string, 0, len(s.options.Distributions))
+	for _, distMap := range s.options.Distributions {
+		repos = append(repos, distMap.Feed)
+	}
+
+	// Without distribution mappings all published repositories are fetched
+	if len(repos) == 0 {
+		entries, err := s.list(ctx)
+		if err != nil {
+			return err
+		}
+		for _, repo := range entries {
+			if len(s.repository.Distributions) == 0 || slices.Contains(s.repository.Distributions, repo) {
+				repos = append(repos, repo)
+			}
+		}
+	}
+
+	repoPool := s.pool.NewSubpool(10)
+	defer repoPool.StopAndWait()
+
+	group := repoPool.NewGroup()
+	for _, repo := range repos {
+		group.SubmitErr(func() error {
+			return s.processRepository(ctx, repo)
+		})
+	}
+
+	return group.Wait()
+}
+
+// processRepository fetches the package files of a published repository
+// Binaries are published in a directory per architecture, source packages in the repository directory
+func (s *OBSFeed) processRepository(ctx context.Context, repo string) error {
+	entries, err := s.list(ctx, repo)
+	if err != nil {
+		return err
+	}
+
+	var binaries, sources []string
+	for _, name := range entries {
+		switch {
+		case strings.HasSuffix(name, ".dsc"):
+			if s.repository.Packages.Source {
+				sources = append(sources, name)
+			}
+		case isOBSBinary(name):
+			binaries = append(binaries, name)
+		case !strings.Contains(name, ".") && !slices.Contains(obsMetadataFiles, name):
+			files, err := s.list(ctx, repo, name)
+			if err != nil {
+				return err
+			}
+			for _, file := range files {
+				if isOBSBinary(file) {
+					binaries = append(binaries, path.Join(name, file))
+				}
+			}
+		}
+	}
+
+	filePool := s.pool.NewSubpool(10)
+	defer filePool.StopAndWait()
+
+	group := filePool.NewGroup()
+	var mu sync.Mutex
+	var trustFiles []*common.FileForTrust
+	collect := func(files ...*common.FileForTrust) {
+		mu.Lock()
+		trustFiles = append(trustFiles, files...)
+		mu.Unlock()
+	}
+
+	for _, file := range binaries {
+		if !s.includeBinary(path.Base(file)) {
+			continue
+		}
+		group.SubmitErr(func() error {
+			trustFile, err := s.fetchBinary(ctx, repo, file)
+			if err != nil || trustFile == nil {
+				return err
+			}
+			collect(trustFile)
+			return nil
+		})
+	}
+	for _, file := range sources {
+		group.SubmitErr(func() error {
+			files, err := s.fetchSource(ctx, repo, file)
+			if err != nil {
+				return err
+			}
+			collect(files...)
+			return nil
+		})
+	}
+
+	if err := group.Wait(); err != nil {
+		return err
+	}
+
+	if err := s.storage.LinkFilesToTrusted(ctx, trustFiles); err != nil {
+		return err
+	}
+
+	slog.Info("Fetched OBS repository", "project", s.options.OBSProject, "repository", repo, "files", len(trustFiles), log.Success())
+	return nil
+}
+
+// isOBSBinary reports whether a published file is a binary package
+func isOBSBinary(name string) bool {
+	return strings.HasSuffix(name, ".deb") || strings.HasSuffix(name, ".udeb") || strings.HasSuffix(name, ".ddeb")
+}
+
+// includeBinary reports whether a binary package file is fetched, judged by its file name before downloading
+func (s *OBSFeed) includeBinary(filename string) bool {
+	if s.repository.Packages.SourceOnly {
+		return false
+	}
+	if debext.IsDebugPackageByFilename(filename) && !s.repository.Packages.Debug {
+		return false
+	}
+	pkgName, _, _ := strings.Cut(filename, "_")
+	return common.MatchesGlobPatterns(s.options.Packages, pkgName)
+}
+
+// fetchBinary downloads a binary package file, nil if its source package is filtered out
+func (s *OBSFeed) fetchBinary(ctx context.Context, repo, file string) (*common.FileForTrust, error) {
+	relPath := path.Join(repo, file)
+	localPath, err := s.download(ctx, relPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// The source package name is only known from the control file
+	pkg, err := debext.ParseBinary(localPath, "", s.controls)
+	if err != nil {
+		return nil, err
+	}
+	source := debext.GetSourceNameFromPackage(pkg)
+	if !common.MatchesGlobPatterns(s.options.FromSources, source) {
+		return nil, nil
+	}
+
+	return &common.FileForTrust{
+		Path:         localPath,
+		Distribution: repo,
+		Hash:         pkg.Files()[0].Checksums.SHA256,
+		Source:       source,
+		Redirect:     relPath,
+	}, nil
+}
+
+// fetchSource downloads a .dsc file and the files it references, verified by the checksums of the .dsc
+func (s *OBSFeed) fetchSource(ctx context.Context, repo, file string) ([]*common.FileForTrust, error) {
+	relPath := path.Join(repo, file)
+	dscPath, err := s.download(ctx, relPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// OBS doesn't sign .dsc files even when their input is signed
+	unsignedVerifier := &debext.Verifier{
+		Verifier:         s.verifier.Verifier,
+		AcceptUnsigned:   true,
+		IgnoreSignatures: s.verifier.IgnoreSignatures,
+	}
+	pkg, err := debext.ParseSource(dscPath, unsignedVerifier, "")
+	if err != nil {
+		return nil, err
+	}
+	if !common.MatchesGlobPatterns(s.options.FromSources, pkg.Name) {
+		return nil, nil
+	}
+
+	var files []*common.FileForTrust
+	for _, referencedFile := range pkg.Files() {
+		referencedPath := path.Join(repo, referencedFile.Filename)
+		localPath := dscPath
+		if referencedFile.Filename != file {
+			localPath, err = s.storage.FileExistsOrDownload(ctx, "sha256", referencedFile.Checksums.SHA256,
+				s.options.DownloadURL.JoinPath(referencedPath).String(), referencedPath)
+			if err != nil {
+				return nil, err
+			}
+		}
+		files = append(files, &common.FileForTrust{
+			Path:         localPath,
+			Distribution: repo,
+			Hash:         referencedFile.Checksums.SHA256,
+			Source:       pkg.Name,
+			Redirect:     referencedPath,
+		})
+	}
+	return files, nil
+}
+
+// download downloads a published file unless it was downloaded before and returns its local path
+// OBS publishes rebuilds with a new release number, an existing file of the same name is not downloaded again
+func (s *OBSFeed) download(ctx context.Context, relPath string) (string, error) {
+	localPath := s.storage.GetDownloadPath(relPath)
+	if _, err := os.Stat(localPath); err == nil {
+		return localPath, nil
+	}
+
+	req := &common.DownloadRequest{
+		URL:         s.options.DownloadURL.JoinPath(relPath).String(),
+		Destination: relPath,
+	}
+	if _, err := s.storage.Download(ctx, req).Wait(); err != nil {
+		return "", err
+	}
+	return localPath, nil
+}
+
+// list returns the entry names of a directory of the published binaries of the project
+func (s *OBSFeed) list(ctx context.Context, parts ...string) ([]string, error) {
+	listURL := s.options.OBSAPI.JoinPath(append([]string{"published", s.options.OBSProject}, parts...)...)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, listURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if s.credentials.Username != "" {
+		req.SetBasicAuth(s.credentials.Username, s.credentials.Password)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrOBSAPI, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized:
+		return nil, fmt.Errorf("%w: %s: %s, configure obs.username and obs.password", ErrOBSAPI, listURL, resp.Status)
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("%w: %s: %s", ErrOBSAPI, listURL, resp.Status)
+	}
+
+	var dir obsDirectory
+	if err := xml.NewDecoder(resp.Body).Decode(&dir); err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", ErrOBSAPI, listURL, err)
+	}
+
+	names := make([]string, 0, len(dir.Entries))
+	for _, entry := range dir.Entries {
+		// Entries are joined into download paths, never leave the listed directory
+		if entry.Name == "" || entry.Name == "." || entry.Name == ".." || strings.ContainsAny(entry.Name, `/\`) {
+			continue
+		}
+		names = append(names, entry.Name)
+	}
+	return names, nil
+}
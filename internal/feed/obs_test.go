@@ -1,10 +1,15 @@
 package feed
 
 import (
+	"context"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
+	"github.com/dionysius/aarg/internal/common"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
 )
 
 func TestExpandOBSFeedOptions(t *testing.T) {
@@ -84,3 +89,163 @@ func TestExpandOBSFeedOptions(t *testing.T) {
 		})
 	}
 }
+
+func TestExpandOBSFeedOptions_API(t *testing.T) {
+	input := &FeedOptions{
+		Type:          FeedTypeOBS,
+		DownloadURL:   mustParseURL("https://download.opensuse.org/repositories/home:/user:/project"),
+		RelativePath:  "download.opensuse.org/repositories/home:/user:/project",
+		OBSAPI:        mustParseURL("https://api.opensuse.org"),
+		OBSProject:    "home:user:project",
+		Distributions: []DistributionMap{{Feed: "Debian_12", Target: "bookworm"}},
+	}
+
+	// The OBS API feed discovers the repositories itself and keeps the OBS repositories as distributions
+	result := ExpandOBSFeedOptions(input)
+	require.Len(t, result, 1)
+	assert.Same(t, input, result[0])
+}
+
+func TestFeedOptions_UnmarshalYAML_OBSAPI(t *testing.T) {
+	tests := []struct {
+		name        string
+		yamlInput   string
+		wantProject string
+		wantErr     string
+	}{
+		{
+			name:        "project identifier",
+			yamlInput:   "obs: home:user:project\nobs_api: https://api.opensuse.org",
+			wantProject: "home:user:project",
+		},
+		{
+			name:        "custom instance",
+			yamlInput:   "obs: https://download.example.com/repositories/home:/user:/project\nobs_api: https://api.example.com",
+			wantProject: "home:user:project",
+		},
+		{
+			name:      "custom instance without project",
+			yamlInput: "obs: https://download.example.com/debian\nobs_api: https://api.example.com",
+			wantErr:   "requires a project",
+		},
+		{
+			name:      "invalid scheme",
+			yamlInput: "obs: home:user:project\nobs_api: ftp://api.opensuse.org",
+			wantErr:   "must be http or https",
+		},
+		{
+			name:      "not an obs feed",
+			yamlInput: "apt: https://deb.debian.org/debian\nobs_api: https://api.opensuse.org",
+			wantErr:   "only supported for obs feeds",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts FeedOptions
+			err := yaml.Unmarshal([]byte(tt.yamlInput), &opts)
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantProject, opts.OBSProject)
+
+			// Round-trips with the API URL
+			out, err := yaml.Marshal(opts)
+			require.NoError(t, err)
+			assert.Contains(t, string(out), "obs_api: "+opts.OBSAPI.String())
+		})
+	}
+}
+
+func TestOBSFeed_List(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, password, ok := r.BasicAuth(); !ok || user != "user" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/published/home:user:project":
+			_, _ = w.Write([]byte(`<directory><entry name="Debian_12"/><entry name="xUbuntu_24.04"/></directory>`))
+		case "/published/home:user:project/xUbuntu_24.04/amd64":
+			_, _ = w.Write([]byte(`<directory><entry name="hello_1.0-1_amd64.deb"/><entry name="hello-dbgsym_1.0-1_amd64.ddeb"/><entry name=".."/></directory>`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name        string
+		credentials OBSCredentials
+		parts       []string
+		want        []string
+		wantErr     string
+	}{
+		{
+			name:        "repositories",
+			credentials: OBSCredentials{Username: "user", Password: "secret"},
+			want:        []string{"Debian_12", "xUbuntu_24.04"},
+		},
+		{
+			name:        "architecture directory skips entries leaving it",
+			credentials: OBSCredentials{Username: "user", Password: "secret"},
+			parts:       []string{"xUbuntu_24.04", "amd64"},
+			want:        []string{"hello_1.0-1_amd64.deb", "hello-dbgsym_1.0-1_amd64.ddeb"},
+		},
+		{
+			name:        "missing repository",
+			credentials: OBSCredentials{Username: "user", Password: "secret"},
+			parts:       []string{"Fedora_42"},
+			wantErr:     "404",
+		},
+		{
+			name:    "without credentials",
+			wantErr: "configure obs.username and obs.password",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := &FeedOptions{Type: FeedTypeOBS, OBSAPI: mustParseURL(server.URL), OBSProject: "home:user:project"}
+			obs, err := NewOBS(nil, server.Client(), tt.credentials, nil, options, &common.RepositoryOptions{}, nil, nil)
+			require.NoError(t, err)
+
+			names, err := obs.list(context.Background(), tt.parts...)
+			if tt.wantErr != "" {
+				require.ErrorIs(t, err, ErrOBSAPI)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, names)
+		})
+	}
+}
+
+func TestOBSFeed_IncludeBinary(t *testing.T) {
+	tests := []struct {
+		name     string
+		packages common.PackageOptions
+		filters  []string
+		filename string
+		want     bool
+	}{
+		{name: "binary", filename: "hello_1.0-1_amd64.deb", want: true},
+		{name: "debug package without debug", filename: "hello-dbgsym_1.0-1_amd64.ddeb", want: false},
+		{name: "debug package with debug", packages: common.PackageOptions{Debug: true}, filename: "hello-dbgsym_1.0-1_amd64.ddeb", want: true},
+		{name: "source only", packages: common.PackageOptions{Source: true, SourceOnly: true}, filename: "hello_1.0-1_amd64.deb", want: false},
+		{name: "filtered package", filters: []string{"!hello"}, filename: "hello_1.0-1_amd64.deb", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := &FeedOptions{Type: FeedTypeOBS, Packages: tt.filters}
+			obs, err := NewOBS(nil, nil, OBSCredentials{}, nil, options, &common.RepositoryOptions{Packages: tt.packages}, nil, nil)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, obs.includeBinary(tt.filename))
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package feed
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+
+	"github.com/dionysius/aarg/internal/common"
+	"github.com/dionysius/aarg/internal/log"
+	"github.com/dionysius/aarg/internal/plugin"
+)
+
+// Plugin fetches packages through an external feed plugin.
+// The plugin places files into the download directory and reports them with their checksums,
+// the files are trusted as reported since there is no upstream signature to verify.
+type Plugin struct {
+	options *FeedOptions
+	command plugin.Command
+	storage *common.Storage
+}
+
+// NewPlugin creates a feed backed by the plugin named in the options
+func NewPlugin(storage *common.Storage, plugins map[string]plugin.Command, options *FeedOptions) (*Plugin, error) {
+	command, ok := plugins[options.Plugin]
+	if !ok {
+		return nil, fmt.Errorf("plugin not configured: %s", options.Plugin)
+	}
+
+	return &Plugin{
+		options: options,
+		command: command,
+		storage: storage,
+	}, nil
+}
+
+// Run starts the plugin, lets it fetch into the download directory and links the reported files into trusted storage
+func (s *Plugin) Run(ctx context.Context) error {
+	downloadDir := s.storage.GetDownloadPath()
+	if err := os.MkdirAll(downloadDir, 0755); err != nil {
+		return fmt.Errorf("failed to create download directory: %w", err)
+	}
+
+	client, err := plugin.Start(ctx, s.command, plugin.KindFeed)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := client.Close(); err != nil {
+			slog.Warn("Failed to stop plugin", "plugin", client.Name(), "error", err)
+		}
+	}()
+
+	// Feed settings override the plugin defaults
+	settings := maps.Clone(s.command.Settings)
+	if settings == nil {
+		settings = make(map[string]string)
+	}
+	maps.Copy(settings, s.options.Settings)
+
+	params := plugin.FetchParams{
+		Location:    s.options.Name,
+		Settings:    settings,
+		DownloadDir: downloadDir,
+	}
+	for _, distMap := range s.options.Distributions {
+		params.Distributions = append(params.Distributions, plugin.DistributionMapping{Feed: distMap.Feed, Target: distMap.Target})
+	}
+
+	var result plugin.FetchResult
+	if err := client.Call(ctx, plugin.MethodFetch, params, &result); err != nil {
+		return fmt.Errorf("plugin %s fetch failed: %w", client.Name(), err)
+	}
+
+	var trustFiles []*common.FileForTrust
+	for _, file := range result.Files {
+		trustFile, err := s.trustFile(file)
+		if err != nil {
+			return fmt.Errorf("plugin %s: %w", client.Name(), err)
+		}
+		if trustFile != nil {
+			trustFiles = append(trustFiles, trustFile)
+		}
+	}
+
+	if err := s.storage.LinkFilesToTrusted(ctx, trustFiles); err != nil {
+		return err
+	}
+
+	slog.Info("Fetched plugin feed", "plugin", client.Name(), "location", s.options.Name, "files", len(trustFiles), log.Success())
+	return nil
+}
+
+// trustFile checks a reported file and maps it to its target distribution, nil if filtered out
+func (s *Plugin) trustFile(file plugin.FetchedFile) (*common.FileForTrust, error) {
+	if !filepath.IsLocal(file.Path) {
+		return nil, fmt.Errorf("file outside download directory: %s", file.Path)
+	}
+	if file.Distribution == "" {
+		return nil, fmt.Errorf("file without distribution: %s", file.Path)
+	}
+
+	dist, source, ok := s.options.selectFile(file.Path, file.Distribution, file.Source)
+	if !ok {
+		return nil, nil
+	}
+
+	if !s.storage.DownloadFileExistsWithHash("sha256", file.SHA256, file.Path) {
+		return nil, fmt.Errorf("file missing or checksum mismatch: %s", file.Path)
+	}
+
+	return &common.FileForTrust{
+		Path:         s.storage.GetDownloadPath(file.Path),
+		Distribution: dist,
+		Hash:         file.SHA256,
+		Source:       source,
+	}, nil
+}
@@ -0,0 +1,170 @@
+package feed
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/dionysius/aarg/debext"
+)
+
+// ErrPPAKeyMismatch is returned if the key served for a PPA does not have the fingerprint reported by Launchpad
+var ErrPPAKeyMismatch = errors.New("PPA signing key does not match its Launchpad fingerprint")
+
+// Launchpad endpoints, variables to be replaced in tests
+var (
+	launchpadAPIURL = "https://api.launchpad.net/1.0"
+	keyserverURL    = "https://keyserver.ubuntu.com"
+)
+
+// ppaKeysDir is the directory of the cached PPA signing keys in the cache directory
+const ppaKeysDir = "ppa-keys"
+
+// parsePPA splits a PPA reference in "ppa:owner/name" or "owner/name" notation
+func parsePPA(ref string) (owner, name string, err error) {
+	owner, name, found := strings.Cut(strings.TrimPrefix(ref, "ppa:"), "/")
+	if !found || owner == "" || name == "" || strings.Contains(name, "/") {
+		return "", "", fmt.Errorf("PPA must be ppa:owner/name: %s", ref)
+	}
+	return owner, name, nil
+}
+
+// ExpandPPAFeedOptions expands a PPA FeedOptions into APT FeedOptions, one per distribution
+// PPAs are regular APT repositories with Ubuntu series as distributions and a main component
+func ExpandPPAFeedOptions(options *FeedOptions) []*FeedOptions {
+	aptOptions := &FeedOptions{
+		Name:            options.Name,
+		Type:            FeedTypeAPT,
+		DownloadURL:     options.DownloadURL,
+		ProjectURL:      options.ProjectURL,
+		RelativePath:    options.RelativePath,
+		Components:      options.Components,
+		FromSources:     options.FromSources,
+		Packages:        options.Packages,
+		Priority:        options.Priority,
+		Timeout:         options.Timeout,
+		SigningKeys:     options.SigningKeys,
+		KeyChange:       options.KeyChange,
+		TargetComponent: options.TargetComponent,
+		Distributions:   options.Distributions,
+	}
+
+	return ExpandAptFeedOptions(aptOptions)
+}
+
+// PPASigningKey returns the path of the signing key of a PPA feed in cacheDir
+// The fingerprint is looked up on Launchpad every time to pick up key rotations, the key itself is
+// only downloaded from the Ubuntu keyserver if not cached yet. If Launchpad is unreachable the last
+// cached key is used.
+func PPASigningKey(ctx context.Context, client *http.Client, options *FeedOptions, cacheDir string) (string, error) {
+	owner, name, err := parsePPA(options.Name)
+	if err != nil {
+		return "", err
+	}
+	keyDir := filepath.Join(cacheDir, ppaKeysDir, owner, name)
+
+	fingerprint, err := ppaFingerprint(ctx, client, owner, name)
+	if err != nil {
+		cached, _ := filepath.Glob(filepath.Join(keyDir, "*.asc"))
+		if len(cached) == 0 {
+			return "", fmt.Errorf("failed to look up signing key of ppa:%s/%s: %w", owner, name, err)
+		}
+		slices.SortFunc(cached, func(a, b string) int { return modTime(b).Compare(modTime(a)) })
+		slog.Warn("Failed to look up PPA signing key, using cached key", "feed", "ppa:"+options.Name, "key", filepath.Base(cached[0]), "error", err)
+		return cached[0], nil
+	}
+
+	keyPath := filepath.Join(keyDir, fingerprint+".asc")
+	if _, err := os.Stat(keyPath); err == nil {
+		return keyPath, nil
+	}
+
+	data, err := fetchPPAKey(ctx, client, fingerprint)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch signing key of ppa:%s/%s: %w", owner, name, err)
+	}
+
+	if err := os.MkdirAll(keyDir, 0755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(keyPath, data, 0644); err != nil {
+		return "", err
+	}
+	slog.Info("Fetched PPA signing key", "feed", "ppa:"+options.Name, "fingerprint", fingerprint)
+	return keyPath, nil
+}
+
+// ppaFingerprint returns the fingerprint of the signing key of a PPA from the Launchpad API
+func ppaFingerprint(ctx context.Context, client *http.Client, owner, name string) (string, error) {
+	apiURL := fmt.Sprintf("%s/~%s/+archive/ubuntu/%s", launchpadAPIURL, url.PathEscape(owner), url.PathEscape(name))
+	body, err := httpGet(ctx, client, apiURL)
+	if err != nil {
+		return "", err
+	}
+
+	var archive struct {
+		SigningKeyFingerprint string `json:"signing_key_fingerprint"`
+	}
+	if err := json.Unmarshal(body, &archive); err != nil {
+		return "", fmt.Errorf("failed to parse Launchpad archive: %w", err)
+	}
+	if archive.SigningKeyFingerprint == "" {
+		return "", errors.New("PPA has no signing key yet, it is created with the first upload")
+	}
+	return strings.ToUpper(archive.SigningKeyFingerprint), nil
+}
+
+// fetchPPAKey downloads a key from the Ubuntu keyserver and checks it has the expected fingerprint
+func fetchPPAKey(ctx context.Context, client *http.Client, fingerprint string) ([]byte, error) {
+	keyURL := fmt.Sprintf("%s/pks/lookup?op=get&options=mr&search=0x%s", keyserverURL, url.QueryEscape(fingerprint))
+	data, err := httpGet(ctx, client, keyURL)
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := debext.ReadKeyData(data)
+	if err != nil {
+		return nil, err
+	}
+	infos := debext.DescribeKeys(keys)
+	if len(infos) != 1 || infos[0].Fingerprint != fingerprint {
+		return nil, fmt.Errorf("%w: expected %s, got %s", ErrPPAKeyMismatch, fingerprint, strings.Join(debext.KeyIDs(keys), ", "))
+	}
+	return data, nil
+}
+
+// httpGet returns the body of a successful GET request
+func httpGet(ctx context.Context, client *http.Client, rawURL string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", rawURL, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// modTime returns the modification time of a file, zero if it can't be read
+func modTime(path string) (t time.Time) {
+	if info, err := os.Stat(path); err == nil {
+		t = info.ModTime()
+	}
+	return t
+}
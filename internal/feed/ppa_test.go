@@ -0,0 +1,137 @@
+package feed
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestFeedOptions_UnmarshalYAML_PPA(t *testing.T) {
+	tests := []struct {
+		name      string
+		yamlInput string
+		wantName  string
+		wantErr   bool
+	}{
+		{name: "shorthand", yamlInput: `ppa: "ppa:deadsnakes/ppa"`, wantName: "deadsnakes/ppa"},
+		{name: "without prefix", yamlInput: `ppa: "deadsnakes/ppa"`, wantName: "deadsnakes/ppa"},
+		{name: "missing name", yamlInput: `ppa: "ppa:deadsnakes"`, wantErr: true},
+		{name: "too many parts", yamlInput: `ppa: "ppa:deadsnakes/ppa/ubuntu"`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts FeedOptions
+			err := yaml.Unmarshal([]byte(tt.yamlInput), &opts)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, FeedTypePPA, opts.Type)
+			assert.Equal(t, tt.wantName, opts.Name)
+			assert.Equal(t, "ppa.launchpadcontent.net/deadsnakes/ppa/ubuntu", opts.RelativePath)
+			assert.Equal(t, "https://ppa.launchpadcontent.net/deadsnakes/ppa/ubuntu", opts.DownloadURL.String())
+			assert.Equal(t, "https://launchpad.net/~deadsnakes/+archive/ubuntu/ppa", opts.ProjectURL.String())
+
+			out, err := yaml.Marshal(opts)
+			require.NoError(t, err)
+			assert.Contains(t, string(out), "ppa: ppa:deadsnakes/ppa")
+		})
+	}
+}
+
+func TestExpandPPAFeedOptions(t *testing.T) {
+	var opts FeedOptions
+	require.NoError(t, yaml.Unmarshal([]byte("ppa: ppa:deadsnakes/ppa\ndistributions: [noble, {jammy: jammy-backports}]\n"), &opts))
+
+	result := ExpandPPAFeedOptions(&opts)
+	require.Len(t, result, 2)
+	for i, want := range []DistributionMap{{Feed: "noble", Target: "noble"}, {Feed: "jammy", Target: "jammy-backports"}} {
+		assert.Equal(t, FeedTypeAPT, result[i].Type)
+		assert.Equal(t, "https://ppa.launchpadcontent.net/deadsnakes/ppa/ubuntu", result[i].DownloadURL.String())
+		assert.Equal(t, "ppa.launchpadcontent.net/deadsnakes/ppa/ubuntu", result[i].RelativePath)
+		assert.Equal(t, want.Feed, result[i].Distributions[0].Feed)
+		assert.Equal(t, want.Target, result[i].Distributions[0].Target)
+	}
+}
+
+func TestPPASigningKey(t *testing.T) {
+	entity, err := openpgp.NewEntity("ppa", "", "ppa@example.com", nil)
+	require.NoError(t, err)
+	var key bytes.Buffer
+	w, err := armor.Encode(&key, openpgp.PublicKeyType, nil)
+	require.NoError(t, err)
+	require.NoError(t, entity.Serialize(w))
+	require.NoError(t, w.Close())
+	fingerprint := strings.ToUpper(hex.EncodeToString(entity.PrimaryKey.Fingerprint))
+
+	// setup serves the Launchpad API reporting apiFingerprint and a keyserver always returning the key
+	setup := func(t *testing.T, apiFingerprint string, apiStatus int) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch {
+			case r.URL.Path == "/~owner/+archive/ubuntu/name":
+				w.WriteHeader(apiStatus)
+				_, _ = w.Write([]byte(`{"signing_key_fingerprint": "` + apiFingerprint + `"}`))
+			case r.URL.Path == "/pks/lookup":
+				_, _ = w.Write(key.Bytes())
+			default:
+				http.NotFound(w, r)
+			}
+		}))
+		t.Cleanup(server.Close)
+
+		previousAPI, previousKeyserver := launchpadAPIURL, keyserverURL
+		launchpadAPIURL, keyserverURL = server.URL, server.URL
+		t.Cleanup(func() { launchpadAPIURL, keyserverURL = previousAPI, previousKeyserver })
+	}
+	options := &FeedOptions{Type: FeedTypePPA, Name: "owner/name"}
+
+	t.Run("fetches and caches key", func(t *testing.T) {
+		setup(t, strings.ToLower(fingerprint), http.StatusOK)
+		cacheDir := t.TempDir()
+
+		path, err := PPASigningKey(context.Background(), http.DefaultClient, options, cacheDir)
+		require.NoError(t, err)
+		assert.Equal(t, filepath.Join(cacheDir, ppaKeysDir, "owner", "name", fingerprint+".asc"), path)
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, key.Bytes(), data)
+	})
+
+	t.Run("key with other fingerprint", func(t *testing.T) {
+		other := strings.Repeat("A", 40)
+		setup(t, other, http.StatusOK)
+
+		_, err := PPASigningKey(context.Background(), http.DefaultClient, options, t.TempDir())
+		assert.ErrorIs(t, err, ErrPPAKeyMismatch)
+	})
+
+	t.Run("launchpad unreachable uses cached key", func(t *testing.T) {
+		setup(t, "", http.StatusServiceUnavailable)
+		cacheDir := t.TempDir()
+
+		_, err := PPASigningKey(context.Background(), http.DefaultClient, options, cacheDir)
+		require.Error(t, err)
+
+		cached := filepath.Join(cacheDir, ppaKeysDir, "owner", "name", fingerprint+".asc")
+		require.NoError(t, os.MkdirAll(filepath.Dir(cached), 0755))
+		require.NoError(t, os.WriteFile(cached, key.Bytes(), 0644))
+		path, err := PPASigningKey(context.Background(), http.DefaultClient, options, cacheDir)
+		require.NoError(t, err)
+		assert.Equal(t, cached, path)
+	})
+}
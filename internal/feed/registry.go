@@ -0,0 +1,185 @@
+package feed
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+
+	"github.com/alitto/pond/v2"
+	"github.com/dionysius/aarg/debext"
+	"github.com/dionysius/aarg/internal/common"
+	"github.com/dionysius/aarg/internal/plugin"
+	"github.com/google/go-github/v80/github"
+)
+
+// Capabilities describes what a feed type supports
+type Capabilities struct {
+	Source                      bool // Provides source packages
+	RetentionPrefetch           bool // Applies retention on metadata before downloading package files
+	RequiresDistributionMapping bool // Fetches nothing without configured distributions (cannot discover them)
+	Components                  bool // Supports component whitelisting
+	Routes                      bool // Supports routing releases to suffixed distributions
+	SigningKeys                 bool // Pins the keys signing the upstream Release files
+}
+
+// Dependencies are the runtime components available to feed constructors
+type Dependencies struct {
+	Storage      *common.Storage           // Storage scoped to the feed's relative path
+	GitHubClient *github.Client            // Shared GitHub API client
+	HTTPClient   *http.Client              // Shared HTTP client for APIs without own client
+	OBS          OBSCredentials            // Credentials of the OBS API
+	Verifier     *debext.Verifier          // Verifier of the repository
+	Repository   *common.RepositoryOptions // Options of the repository the feed belongs to
+	Pool         pond.Pool                 // Coordination pool for parallel operations
+	Plugins      map[string]plugin.Command // Configured plugin executables by name
+	Shared       *SharedFetch              // Deduplicates upstream work across repositories, nil = no sharing
+	ControlStore debext.ControlStore       // Reuses parsed control files of downloaded packages, nil = parse every time
+}
+
+// Registration describes a feed type
+type Registration struct {
+	Type         FeedType
+	Capabilities Capabilities
+
+	// Expand converts configured options into the options of feed instances to fetch, nil = no expansion
+	Expand func(options *FeedOptions) []*FeedOptions
+	// ExpandCompose converts configured options into the trusted storage layouts to compose, nil = Expand
+	ExpandCompose func(options *FeedOptions) []*FeedOptions
+	// New creates a feed instance from expanded options, nil if the type only expands into other types
+	New func(options *FeedOptions, deps Dependencies) (Feed, error)
+}
+
+// StatsReporter is implemented by feeds counting the release assets found cached or downloaded per run
+type StatsReporter interface {
+	// Stats returns the statistics of the last run per release
+	Stats() map[string]common.DownloadStatsSnapshot
+}
+
+// ChangesIngester is implemented by feeds able to ingest .changes files placed in their downloads directory
+type ChangesIngester interface {
+	IngestChanges(ctx context.Context, changesPath string) error
+}
+
+var (
+	registry   = make(map[FeedType]Registration)
+	registryMu sync.RWMutex
+)
+
+// Register adds a feed type to the registry, typically called from init
+// Panics if the type is already registered
+func Register(reg Registration) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if _, exists := registry[reg.Type]; exists {
+		panic(fmt.Sprintf("feed type %q already registered", reg.Type))
+	}
+	registry[reg.Type] = reg
+}
+
+// Lookup returns the registration of a feed type
+func Lookup(feedType FeedType) (Registration, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	reg, ok := registry[feedType]
+	return reg, ok
+}
+
+// Types returns all registered feed types sorted by name
+func Types() []FeedType {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	types := make([]FeedType, 0, len(registry))
+	for feedType := range registry {
+		types = append(types, feedType)
+	}
+	slices.Sort(types)
+	return types
+}
+
+// CapabilitiesOf returns the capabilities of a feed type, zero for unknown types
+func CapabilitiesOf(feedType FeedType) Capabilities {
+	reg, _ := Lookup(feedType)
+	return reg.Capabilities
+}
+
+// Expand expands configured options into the options of the feed instances to fetch
+func Expand(options *FeedOptions) ([]*FeedOptions, error) {
+	reg, ok := Lookup(options.Type)
+	if !ok {
+		return nil, fmt.Errorf("unsupported feed type: %s", options.Type)
+	}
+	if reg.Expand == nil {
+		return []*FeedOptions{options}, nil
+	}
+	return reg.Expand(options), nil
+}
+
+// ExpandCompose expands configured options into the trusted storage layouts to compose
+func ExpandCompose(options *FeedOptions) ([]*FeedOptions, error) {
+	reg, ok := Lookup(options.Type)
+	if !ok {
+		return nil, fmt.Errorf("unsupported feed type: %s", options.Type)
+	}
+	if reg.ExpandCompose == nil {
+		return Expand(options)
+	}
+	return reg.ExpandCompose(options), nil
+}
+
+// New creates a feed instance from expanded options
+func New(options *FeedOptions, deps Dependencies) (Feed, error) {
+	reg, ok := Lookup(options.Type)
+	if !ok || reg.New == nil {
+		return nil, fmt.Errorf("unsupported expanded feed type: %s", options.Type)
+	}
+	return reg.New(options, deps)
+}
+
+func init() {
+	Register(Registration{
+		Type:          FeedTypeGitHub,
+		Capabilities:  Capabilities{Source: true, RetentionPrefetch: true, Routes: true},
+		ExpandCompose: ExpandGithubFeedOptions,
+		New: func(options *FeedOptions, deps Dependencies) (Feed, error) {
+			return NewGithub(deps.Storage, deps.GitHubClient, deps.Verifier, options, deps.Repository, deps.Pool, deps.ControlStore)
+		},
+	})
+	Register(Registration{
+		Type:         FeedTypeAPT,
+		Capabilities: Capabilities{Source: true, RetentionPrefetch: true, RequiresDistributionMapping: true, Components: true, SigningKeys: true},
+		Expand:       ExpandAptFeedOptions,
+		New: func(options *FeedOptions, deps Dependencies) (Feed, error) {
+			return NewApt(deps.Storage, deps.Verifier, options, deps.Repository, deps.Pool, deps.Shared)
+		},
+	})
+	Register(Registration{
+		Type:         FeedTypeOBS,
+		Capabilities: Capabilities{Source: true, RetentionPrefetch: true, RequiresDistributionMapping: true, Components: true, SigningKeys: true},
+		Expand:       ExpandOBSFeedOptions,
+		New: func(options *FeedOptions, deps Dependencies) (Feed, error) {
+			return NewOBS(deps.Storage, deps.HTTPClient, deps.OBS, deps.Verifier, options, deps.Repository, deps.Pool, deps.ControlStore)
+		},
+	})
+	Register(Registration{
+		Type:         FeedTypePPA,
+		Capabilities: Capabilities{Source: true, RetentionPrefetch: true, RequiresDistributionMapping: true, Components: true, SigningKeys: true},
+		Expand:       ExpandPPAFeedOptions,
+	})
+	Register(Registration{
+		Type: FeedTypePlugin,
+		New: func(options *FeedOptions, deps Dependencies) (Feed, error) {
+			return NewPlugin(deps.Storage, deps.Plugins, options)
+		},
+	})
+	Register(Registration{
+		Type: FeedTypeManifest,
+		New: func(options *FeedOptions, deps Dependencies) (Feed, error) {
+			return NewManifest(deps.Storage, options)
+		},
+	})
+}
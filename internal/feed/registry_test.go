@@ -0,0 +1,63 @@
+package feed
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry_BuiltinTypes(t *testing.T) {
+	assert.Equal(t, []FeedType{FeedTypeAPT, FeedTypeGitHub, FeedTypeManifest, FeedTypeOBS, FeedTypePlugin, FeedTypePPA}, Types())
+
+	tests := []struct {
+		feedType FeedType
+		want     Capabilities
+		creates  bool
+	}{
+		{FeedTypeGitHub, Capabilities{Source: true, RetentionPrefetch: true, Routes: true}, true},
+		{FeedTypeAPT, Capabilities{Source: true, RetentionPrefetch: true, RequiresDistributionMapping: true, Components: true, SigningKeys: true}, true},
+		{FeedTypeOBS, Capabilities{Source: true, RetentionPrefetch: true, RequiresDistributionMapping: true, Components: true, SigningKeys: true}, true},
+		{FeedTypePPA, Capabilities{Source: true, RetentionPrefetch: true, RequiresDistributionMapping: true, Components: true, SigningKeys: true}, false},
+		{FeedTypePlugin, Capabilities{}, true},
+		{FeedTypeManifest, Capabilities{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.feedType), func(t *testing.T) {
+			reg, ok := Lookup(tt.feedType)
+			require.True(t, ok)
+			assert.Equal(t, tt.want, reg.Capabilities)
+			assert.Equal(t, tt.creates, reg.New != nil)
+		})
+	}
+}
+
+func TestRegistry_Register(t *testing.T) {
+	const feedType FeedType = "test"
+	t.Cleanup(func() {
+		registryMu.Lock()
+		delete(registry, feedType)
+		registryMu.Unlock()
+	})
+
+	Register(Registration{Type: feedType})
+	assert.Panics(t, func() { Register(Registration{Type: feedType}) })
+
+	// Without expansion functions the options are used as-is
+	opts := &FeedOptions{Type: feedType, Name: "example"}
+	expanded, err := Expand(opts)
+	require.NoError(t, err)
+	assert.Equal(t, []*FeedOptions{opts}, expanded)
+
+	expanded, err = ExpandCompose(opts)
+	require.NoError(t, err)
+	assert.Equal(t, []*FeedOptions{opts}, expanded)
+
+	// Types without constructor cannot be instantiated
+	_, err = New(opts, Dependencies{})
+	assert.Error(t, err)
+
+	_, err = Expand(&FeedOptions{Type: "unknown"})
+	assert.Error(t, err)
+}
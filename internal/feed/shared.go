@@ -0,0 +1,82 @@
+package feed
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/aptly-dev/aptly/deb"
+)
+
+// SharedFetch deduplicates upstream work of feeds referenced by several repositories within one run
+// Release files are downloaded once per URL and package indices are parsed once per URL and checksum,
+// verification, filtering and retention still happen per repository
+type SharedFetch struct {
+	mu      sync.Mutex
+	entries map[string]*sharedEntry
+}
+
+// sharedEntry holds the outcome of a deduplicated operation
+type sharedEntry struct {
+	mu       sync.Mutex
+	done     bool
+	packages []*deb.Package
+	err      error
+}
+
+// NewSharedFetch creates an empty shared fetch cache for one run
+func NewSharedFetch() *SharedFetch {
+	return &SharedFetch{entries: make(map[string]*sharedEntry)}
+}
+
+// entry returns the entry of key, creating it if needed
+func (s *SharedFetch) entry(key string) *sharedEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entries[key]
+	if !ok {
+		e = &sharedEntry{}
+		s.entries[key] = e
+	}
+	return e
+}
+
+// run runs fn unless an earlier call completed, concurrent callers wait for the running one
+// Context errors are not kept: they belong to the calling feed, e.g. cancelled after its timeout,
+// and the next caller runs fn again with its own context
+func (e *sharedEntry) run(fn func() ([]*deb.Package, error)) ([]*deb.Package, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.done {
+		return e.packages, e.err
+	}
+
+	packages, err := fn()
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return nil, err
+	}
+	e.packages, e.err, e.done = packages, err, true
+	return packages, err
+}
+
+// Do runs fn once per key and returns its error to every caller, nil receiver runs fn directly
+func (s *SharedFetch) Do(key string, fn func() error) error {
+	if s == nil {
+		return fn()
+	}
+
+	_, err := s.entry("do|" + key).run(func() ([]*deb.Package, error) { return nil, fn() })
+	return err
+}
+
+// Packages runs fn once per key and returns the shared parsed packages, nil receiver runs fn directly
+// The returned packages are shared between repositories and must not be modified
+func (s *SharedFetch) Packages(key string, fn func() ([]*deb.Package, error)) ([]*deb.Package, error) {
+	if s == nil {
+		return fn()
+	}
+
+	return s.entry("packages|" + key).run(fn)
+}
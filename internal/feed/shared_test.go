@@ -0,0 +1,98 @@
+package feed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSharedFetch(t *testing.T) {
+	t.Run("runs once per key", func(t *testing.T) {
+		shared := NewSharedFetch()
+		var calls atomic.Int32
+
+		var wg sync.WaitGroup
+		for range 10 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				pkgs, err := shared.Packages("index", func() ([]*deb.Package, error) {
+					calls.Add(1)
+					return []*deb.Package{{Name: "hello"}}, nil
+				})
+				assert.NoError(t, err)
+				assert.Len(t, pkgs, 1)
+			}()
+		}
+		wg.Wait()
+
+		assert.Equal(t, int32(1), calls.Load())
+	})
+
+	t.Run("shares errors", func(t *testing.T) {
+		shared := NewSharedFetch()
+		failure := errors.New("failed")
+		var calls int
+
+		for range 2 {
+			err := shared.Do("release", func() error {
+				calls++
+				return failure
+			})
+			assert.ErrorIs(t, err, failure)
+		}
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("retries context errors", func(t *testing.T) {
+		tests := []struct {
+			name string
+			err  error
+		}{
+			{name: "canceled", err: context.Canceled},
+			{name: "deadline exceeded", err: fmt.Errorf("download: %w", context.DeadlineExceeded)},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				shared := NewSharedFetch()
+
+				// A caller whose own context ended does not fail the next caller
+				_, err := shared.Packages("index", func() ([]*deb.Package, error) { return nil, tt.err })
+				assert.ErrorIs(t, err, tt.err)
+
+				pkgs, err := shared.Packages("index", func() ([]*deb.Package, error) {
+					return []*deb.Package{{Name: "hello"}}, nil
+				})
+				require.NoError(t, err)
+				assert.Len(t, pkgs, 1)
+			})
+		}
+	})
+
+	t.Run("separates keys", func(t *testing.T) {
+		shared := NewSharedFetch()
+		var calls int
+
+		require.NoError(t, shared.Do("a", func() error { calls++; return nil }))
+		require.NoError(t, shared.Do("b", func() error { calls++; return nil }))
+		assert.Equal(t, 2, calls)
+	})
+
+	t.Run("nil runs directly", func(t *testing.T) {
+		var shared *SharedFetch
+		var calls int
+
+		for range 2 {
+			require.NoError(t, shared.Do("a", func() error { calls++; return nil }))
+		}
+		assert.Equal(t, 2, calls)
+	})
+}
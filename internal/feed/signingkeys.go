@@ -0,0 +1,92 @@
+package feed
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// ErrSigningKeyChanged is returned when an upstream Release file is signed by an unacknowledged key
+var ErrSigningKeyChanged = errors.New("upstream signing key changed")
+
+// signingKeysFile records the keys that signed the Release file of a distribution, next to the InRelease download
+const signingKeysFile = ".signing-keys"
+
+// checkSigningKeys compares the keys that signed InRelease with the acknowledged keys of the feed
+// Without configured signing_keys the keys seen on the first fetch are recorded and trusted
+// Unacknowledged keys fail the distribution or are warned about per key_change policy and are
+// never recorded, so an upstream key swap stays visible until the operator acknowledges it
+func (s *Apt) checkSigningKeys(localPath, dist string, signers []string) error {
+	if len(signers) == 0 {
+		return nil
+	}
+
+	recordPath := s.storage.GetDownloadPath(localPath, signingKeysFile)
+	acknowledged := s.options.SigningKeys
+	source := "signing_keys"
+	if len(acknowledged) == 0 {
+		acknowledged = readSigningKeys(recordPath)
+		source = "previous fetch"
+	}
+
+	// Trust on first use
+	if len(acknowledged) == 0 {
+		slog.Info("Recording upstream signing keys, add them to signing_keys to pin them",
+			"feed", s.options.Name, "distribution", dist, "keys", strings.Join(signers, ", "))
+		return writeSigningKeys(recordPath, signers)
+	}
+
+	var unknown []string
+	for _, signer := range signers {
+		if !slices.ContainsFunc(acknowledged, func(key string) bool { return matchesKey(key, signer) }) {
+			unknown = append(unknown, signer)
+		}
+	}
+
+	if len(unknown) == 0 {
+		return writeSigningKeys(recordPath, signers)
+	}
+
+	err := fmt.Errorf("%w: %s %s is signed by %s, acknowledged by %s: %s, add the new key to signing_keys after verifying the upstream announcement",
+		ErrSigningKeyChanged, s.options.Name, dist, strings.Join(unknown, ", "), source, strings.Join(acknowledged, ", "))
+	if s.options.KeyChange == KeyChangeWarn {
+		slog.Warn("Upstream signing key changed", "feed", s.options.Name, "distribution", dist, "error", err)
+		return nil
+	}
+	return err
+}
+
+// matchesKey reports whether an acknowledged fingerprint or key ID identifies the signing key ID
+// Key IDs are the last 16 hex digits of the fingerprint, spaces and 0x prefixes are ignored
+func matchesKey(acknowledged, signer string) bool {
+	normalize := func(key string) string {
+		key = strings.ToUpper(strings.ReplaceAll(key, " ", ""))
+		return strings.TrimPrefix(key, "0X")
+	}
+	a, b := normalize(acknowledged), normalize(signer)
+	if a == "" || b == "" {
+		return false
+	}
+	return strings.HasSuffix(a, b) || strings.HasSuffix(b, a)
+}
+
+// readSigningKeys returns the recorded signing keys, empty if none were recorded
+func readSigningKeys(path string) []string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	return strings.Fields(string(data))
+}
+
+// writeSigningKeys records the signing keys
+func writeSigningKeys(path string, keys []string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(strings.Join(keys, "\n")+"\n"), 0644)
+}
@@ -3,9 +3,15 @@ package feed
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"net/url"
+	"path"
+	"path/filepath"
+	"slices"
 	"strings"
 
+	"github.com/dionysius/aarg/internal/common"
+
 	"gopkg.in/yaml.v3"
 )
 
@@ -14,10 +20,13 @@ type FeedType string
 
 // Feed type constants
 const (
-	FeedTypeGitHub  FeedType = "github"
-	FeedTypeAPT     FeedType = "apt"
-	FeedTypeOBS     FeedType = "obs"
-	FeedTypeUnknown FeedType = "unknown"
+	FeedTypeGitHub   FeedType = "github"
+	FeedTypeAPT      FeedType = "apt"
+	FeedTypeOBS      FeedType = "obs"
+	FeedTypePlugin   FeedType = "plugin"
+	FeedTypeManifest FeedType = "manifest"
+	FeedTypePPA      FeedType = "ppa"
+	FeedTypeUnknown  FeedType = "unknown"
 )
 
 func (f FeedType) String() string {
@@ -34,6 +43,15 @@ const (
 	ReleaseTypeDraft      ReleaseType = "draft"       // Draft release
 )
 
+// KeyChangePolicy decides how unacknowledged upstream signing keys are handled
+type KeyChangePolicy string
+
+// Key change policies of APT and OBS feeds
+const (
+	KeyChangeRefuse KeyChangePolicy = "refuse" // Fail the distribution until the key is acknowledged (default)
+	KeyChangeWarn   KeyChangePolicy = "warn"   // Warn on every fetch until the key is acknowledged
+)
+
 // Feed represents any download source type
 type Feed interface {
 	// Run executes the complete download and verification process
@@ -43,13 +61,16 @@ type Feed interface {
 // FeedOptions contains fully-resolved configuration for a feed source.
 // All values are already inherited/merged from repository-level config.
 type FeedOptions struct {
-	// Feed type: github, apt, obs
+	// Feed type: github, apt, obs, plugin, manifest, ppa
 	Type FeedType
 
 	// Name identifies the feed source as configured. Format depends on feed type:
 	// - GitHub: "owner/repo"
 	// - APT: base URL without scheme (e.g., "deb.debext.org/debian")
 	// - OBS: project identifier (e.g., "home:dionysius:immich")
+	// - Plugin: location passed to the plugin (e.g., "artifactory.example.com/debian")
+	// - Manifest: manifest location without scheme (e.g., "raw.githubusercontent.com/org/debs/main/manifest.yaml")
+	// - PPA: Launchpad owner and archive name (e.g., "deadsnakes/ppa")
 	Name string
 
 	// Derived URLs and paths (calculated during unmarshal)
@@ -58,30 +79,93 @@ type FeedOptions struct {
 	RelativePath string   // Relative path for downloads and trusted directory
 
 	// GitHub-specific
-	Releases  []ReleaseType // Release types to include
-	Tags      []string      // Tag name filters (glob patterns, ! prefix for negation)
-	NoChanges bool          // Skip .changes files and directly download package files (requires dist mapping)
+	Releases  []ReleaseType  // Release types to include
+	Tags      []string       // Tag name filters (glob patterns, ! prefix for negation)
+	NoChanges bool           // Skip .changes files and directly download package files (requires dist mapping)
+	Routes    []ReleaseRoute // Route matching releases to suffixed distributions, first match wins
+
+	// ExcludeAssets are asset name patterns (glob) never downloaded, evaluated before any download
+	ExcludeAssets []string
+
+	// Checksums are asset name patterns (glob) of release checksum files used in no_changes mode
+	// Empty uses DefaultChecksumsPatterns
+	Checksums []string
+
+	// Plugin-specific
+	Plugin   string            // Name of the feed plugin configured in the application config
+	Settings map[string]string // Settings passed to the plugin
+
+	// Manifest-specific
+	Manifest string // Path or http(s) URL of the manifest listing the files to fetch
+
+	// OBS-specific
+	OBSAPI     *url.URL // API of the OBS instance listing the published binaries, nil = fetch the published APT repositories
+	OBSProject string   // Project name in the OBS API (e.g., "home:dionysius:immich")
 
 	// Common to all feeds
 	Distributions []DistributionMap // Distribution mappings from feed to target repository
 
+	// APT/OBS/PPA-specific
+	Components []string // Components to process for all distributions, empty = all
+
+	// SigningKeys are the acknowledged fingerprints or long key IDs of the keys signing InRelease
+	// Empty trusts the keys seen on the first fetch, later changes need to be acknowledged here
+	SigningKeys []string
+	// KeyChange decides what happens if InRelease is signed by an unacknowledged key
+	KeyChange KeyChangePolicy
+
 	// Package source filtering - which packages to include
 	FromSources []string // Source name patterns (glob, ! for negation), empty = include all
 
 	// Package name filtering - which packages to include
 	Packages []string // Package name patterns (glob, ! for negation), empty = include all
+
+	// Priority decides which feed wins if several provide the same package version with different content
+	// Higher wins, equal priorities fall back to feed order
+	Priority int
+
+	// Timeout in seconds after which fetching the feed is cancelled, 0 = fetch.feed_timeout
+	Timeout int
+
+	// TargetComponent is the component the packages are published in with component_per_feed
+	// Empty is resolved to DefaultComponent() when the configuration is loaded
+	TargetComponent string
+}
+
+// ReleaseRoute routes GitHub releases matching the release types and tag patterns
+// to distributions with a suffix (e.g., pre-releases of "noble" to "noble-beta")
+type ReleaseRoute struct {
+	Releases []ReleaseType `yaml:"releases,omitempty"` // Release types to match, empty = any
+	Tags     []string      `yaml:"tags,omitempty"`     // Tag name patterns (glob, ! for negation), empty = any
+	Suffix   string        `yaml:"suffix"`             // Appended to feed and target distribution names
+}
+
+// Matches reports whether a release of the given type and tag is routed
+func (r ReleaseRoute) Matches(releaseType ReleaseType, tag string) bool {
+	if len(r.Releases) > 0 && !slices.Contains(r.Releases, releaseType) {
+		return false
+	}
+	return common.MatchesGlobPatterns(r.Tags, tag)
 }
 
 // DistributionMap represents a mapping from a feed's distribution name to the target repository distribution name.
 // After config resolution, both Feed and Target are always set (identity mapping if no rename specified).
 type DistributionMap struct {
-	Feed   string // Distribution name in the feed (e.g., "/" for flat repos, "Debian_13", "noble")
-	Target string // Distribution name in our repository (e.g., "noble", "trixie")
+	Feed       string   // Distribution name in the feed (e.g., "/" for flat repos, "Debian_13", "noble")
+	Target     string   // Distribution name in our repository (e.g., "noble", "trixie")
+	Components []string // Components to process from this distribution (APT/OBS), empty = feed default
+}
+
+// distributionMapOptions is the extended map value of a distribution mapping
+type distributionMapOptions struct {
+	Target     string   `yaml:"target,omitempty"`
+	Components []string `yaml:"components,omitempty"`
 }
 
-// UnmarshalYAML implements custom unmarshaling for DistributionMap to support both formats:
+// UnmarshalYAML implements custom unmarshaling for DistributionMap to support these formats:
 // - String: "noble" -> {Feed: "noble", Target: ""} (Target auto-mapped by expansion)
 // - Map: {"focal": "stable"} -> {Feed: "focal", Target: "stable"}
+// - Extended map: {"noble": {target: "stable", components: [main]}} -> {Feed: "noble", Target: "stable", Components: [main]}
 func (d *DistributionMap) UnmarshalYAML(node *yaml.Node) error {
 	// Try unmarshaling as string first
 	if node.Kind == yaml.ScalarNode {
@@ -96,16 +180,27 @@ func (d *DistributionMap) UnmarshalYAML(node *yaml.Node) error {
 
 	// Try unmarshaling as map
 	if node.Kind == yaml.MappingNode {
-		var m map[string]string
+		var m map[string]yaml.Node
 		if err := node.Decode(&m); err != nil {
 			return err
 		}
 		if len(m) != 1 {
 			return fmt.Errorf("distribution mapping must have exactly one key-value pair")
 		}
-		for feed, target := range m {
+		for feed, value := range m {
 			d.Feed = feed
-			d.Target = target
+
+			// Value is either the target name or the extended options
+			if value.Kind == yaml.MappingNode {
+				var opts distributionMapOptions
+				if err := value.Decode(&opts); err != nil {
+					return err
+				}
+				d.Target = opts.Target
+				d.Components = opts.Components
+			} else if err := value.Decode(&d.Target); err != nil {
+				return err
+			}
 		}
 		return nil
 	}
@@ -114,9 +209,17 @@ func (d *DistributionMap) UnmarshalYAML(node *yaml.Node) error {
 }
 
 // MarshalYAML implements custom marshaling for DistributionMap:
+// - If Components are set: output as extended map {"noble": {target: "stable", components: [main]}}
 // - If Target is empty or equals Feed: output as string "noble"
 // - If Feed != Target: output as map {"focal": "stable"}
 func (d DistributionMap) MarshalYAML() (any, error) {
+	if len(d.Components) > 0 {
+		opts := distributionMapOptions{Components: d.Components}
+		if d.Target != d.Feed {
+			opts.Target = d.Target
+		}
+		return map[string]distributionMapOptions{d.Feed: opts}, nil
+	}
 	if d.Target == "" || d.Feed == d.Target {
 		return d.Feed, nil
 	}
@@ -131,21 +234,94 @@ func validateURLScheme(u *url.URL, originalURL string) error {
 	return nil
 }
 
+// obsProjectFromPath derives the OBS project of a download path like /repositories/home:/user:/project
+// Returns empty if the path doesn't point to a project below /repositories/
+func obsProjectFromPath(downloadPath string) string {
+	_, project, found := strings.Cut(downloadPath, "/repositories/")
+	if !found {
+		return ""
+	}
+	return strings.ReplaceAll(strings.Trim(project, "/"), ":/", ":")
+}
+
+// DefaultComponent derives the component of the feed with component_per_feed from the last part of its name,
+// e.g. "immich" for "home:dionysius:immich", lowercased and limited to characters valid in component names
+func (f *FeedOptions) DefaultComponent() string {
+	name := strings.TrimRight(f.Name, "/")
+	name = name[strings.LastIndexAny(name, "/:")+1:]
+	if f.Type == FeedTypeManifest {
+		name = strings.TrimSuffix(name, path.Ext(name))
+	}
+
+	component := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '.', r == '+', r == '-':
+			return r
+		default:
+			return '-'
+		}
+	}, strings.ToLower(name))
+	return strings.Trim(component, ".+-")
+}
+
+// selectFile maps the distribution of a fetched file to its target and returns the source package it is grouped by
+// The source falls back to the package name of the filename. Files of distributions missing in the configured
+// mappings and files filtered by from_sources or packages are skipped
+func (f *FeedOptions) selectFile(filename, dist, source string) (target, group string, ok bool) {
+	target = dist
+	if len(f.Distributions) > 0 {
+		i := slices.IndexFunc(f.Distributions, func(distMap DistributionMap) bool { return distMap.Feed == dist })
+		if i < 0 {
+			slog.Debug("Skipping file of unmapped distribution", "file", filename, "distribution", dist)
+			return "", "", false
+		}
+		target = f.Distributions[i].Target
+	}
+
+	pkgName, _, _ := strings.Cut(filepath.Base(filename), "_")
+	group = source
+	if group == "" {
+		group = pkgName
+	}
+
+	if !common.MatchesGlobPatterns(f.FromSources, group) {
+		return "", "", false
+	}
+	if strings.HasSuffix(filename, ".deb") && !common.MatchesGlobPatterns(f.Packages, pkgName) {
+		return "", "", false
+	}
+	return target, group, true
+}
+
 // UnmarshalYAML implements custom unmarshaling for FeedOptions to handle feed type fields implicitly.
 // Detects feed type from github/apt/obs fields and sets Type and Location accordingly.
 func (f *FeedOptions) UnmarshalYAML(node *yaml.Node) (err error) {
 	// Create auxiliary struct with all fields as pointers/slices to detect what's set
 	type feedOptionsAlias struct {
-		GitHub        *string           `yaml:"github"`
-		APT           *string           `yaml:"apt"`
-		OBS           *string           `yaml:"obs"`
-		Releases      []ReleaseType     `yaml:"releases"`
-		Tags          []string          `yaml:"tags"`
-		NoChanges     bool              `yaml:"no_changes"`
-		Components    []string          `yaml:"components"`
-		Distributions []DistributionMap `yaml:"distributions"`
-		FromSources   []string          `yaml:"from_sources"`
-		Packages      []string          `yaml:"packages"`
+		GitHub          *string           `yaml:"github"`
+		APT             *string           `yaml:"apt"`
+		OBS             *string           `yaml:"obs"`
+		OBSAPI          *string           `yaml:"obs_api"`
+		Plugin          *string           `yaml:"plugin"`
+		Manifest        *string           `yaml:"manifest"`
+		PPA             *string           `yaml:"ppa"`
+		Location        string            `yaml:"location"`
+		Settings        map[string]string `yaml:"settings"`
+		Releases        []ReleaseType     `yaml:"releases"`
+		Tags            []string          `yaml:"tags"`
+		NoChanges       bool              `yaml:"no_changes"`
+		Routes          []ReleaseRoute    `yaml:"routes"`
+		ExcludeAssets   []string          `yaml:"exclude_assets"`
+		Checksums       []string          `yaml:"checksums"`
+		Components      []string          `yaml:"components"`
+		SigningKeys     []string          `yaml:"signing_keys"`
+		KeyChange       KeyChangePolicy   `yaml:"key_change"`
+		Distributions   []DistributionMap `yaml:"distributions"`
+		FromSources     []string          `yaml:"from_sources"`
+		Packages        []string          `yaml:"packages"`
+		Priority        int               `yaml:"priority"`
+		Timeout         int               `yaml:"timeout"`
+		TargetComponent string            `yaml:"target_component"`
 	}
 
 	var aux feedOptionsAlias
@@ -195,7 +371,10 @@ func (f *FeedOptions) UnmarshalYAML(node *yaml.Node) (err error) {
 			f.RelativePath = obsURL.Host + obsURL.Path
 			f.ProjectURL = obsURL
 			f.DownloadURL = obsURL
+			f.OBSProject = obsProjectFromPath(obsURL.Path)
 		} else {
+			f.OBSProject = *aux.OBS
+
 			// Project identifier format: home:dionysius:immich
 			// Convert to download format: home:/dionysius:/immich
 			downloadPath := strings.ReplaceAll(*aux.OBS, ":", ":/")
@@ -209,8 +388,66 @@ func (f *FeedOptions) UnmarshalYAML(node *yaml.Node) (err error) {
 				return fmt.Errorf("failed to parse OBS download URL: %w", err)
 			}
 		}
+	} else if aux.Plugin != nil {
+		f.Type = FeedTypePlugin
+		f.Plugin = *aux.Plugin
+		f.Name = aux.Location
+		f.Settings = aux.Settings
+		f.RelativePath = "plugin/" + *aux.Plugin + "/" + aux.Location
+	} else if aux.Manifest != nil {
+		f.Type = FeedTypeManifest
+		f.Manifest = *aux.Manifest
+		f.Name = *aux.Manifest
+
+		// Remote manifests are named like APT feeds, local ones by their path
+		if strings.Contains(*aux.Manifest, "://") {
+			manifestURL, err := url.Parse(*aux.Manifest)
+			if err != nil {
+				return fmt.Errorf("failed to parse manifest URL: %w", err)
+			}
+			if err := validateURLScheme(manifestURL, *aux.Manifest); err != nil {
+				return fmt.Errorf("%s, %w", "manifest", err)
+			}
+			f.Name = manifestURL.Host + manifestURL.Path
+			f.ProjectURL = manifestURL
+		}
+		f.RelativePath = "manifest/" + strings.TrimPrefix(path.Clean("/"+strings.TrimSuffix(f.Name, path.Ext(f.Name))), "/")
+	} else if aux.PPA != nil {
+		f.Type = FeedTypePPA
+		owner, name, err := parsePPA(*aux.PPA)
+		if err != nil {
+			return err
+		}
+		f.Name = owner + "/" + name
+		f.RelativePath = "ppa.launchpadcontent.net/" + f.Name + "/ubuntu"
+
+		f.ProjectURL, err = url.Parse("https://launchpad.net/~" + owner + "/+archive/ubuntu/" + name)
+		if err != nil {
+			return fmt.Errorf("failed to parse PPA project URL: %w", err)
+		}
+		f.DownloadURL, err = url.Parse("https://" + f.RelativePath)
+		if err != nil {
+			return fmt.Errorf("failed to parse PPA download URL: %w", err)
+		}
 	} else {
-		return fmt.Errorf("feed must specify one of: github, apt, obs")
+		return fmt.Errorf("feed must specify one of: github, apt, obs, plugin, manifest, ppa")
+	}
+
+	// The OBS API replaces the published APT metadata of OBS feeds
+	if aux.OBSAPI != nil {
+		if f.Type != FeedTypeOBS {
+			return fmt.Errorf("obs_api is only supported for obs feeds")
+		}
+		f.OBSAPI, err = url.Parse(*aux.OBSAPI)
+		if err != nil {
+			return fmt.Errorf("failed to parse OBS API URL: %w", err)
+		}
+		if err := validateURLScheme(f.OBSAPI, *aux.OBSAPI); err != nil {
+			return fmt.Errorf("%s, %w", "obs_api", err)
+		}
+		if f.OBSProject == "" {
+			return fmt.Errorf("obs_api requires a project, a custom obs URL must end in /repositories/<project>: %s", *aux.OBS)
+		}
 	}
 
 	// Default to "release" if no release types specified
@@ -222,9 +459,18 @@ func (f *FeedOptions) UnmarshalYAML(node *yaml.Node) (err error) {
 	f.Releases = aux.Releases
 	f.Tags = aux.Tags
 	f.NoChanges = aux.NoChanges
+	f.Routes = aux.Routes
+	f.ExcludeAssets = aux.ExcludeAssets
+	f.Checksums = aux.Checksums
 	f.Distributions = aux.Distributions
+	f.Components = aux.Components
+	f.SigningKeys = aux.SigningKeys
+	f.KeyChange = aux.KeyChange
 	f.FromSources = aux.FromSources
 	f.Packages = aux.Packages
+	f.Priority = aux.Priority
+	f.Timeout = aux.Timeout
+	f.TargetComponent = aux.TargetComponent
 
 	return nil
 }
@@ -248,18 +494,49 @@ func (f FeedOptions) MarshalYAML() (any, error) {
 		} else {
 			output["obs"] = f.Name
 		}
+		if f.OBSAPI != nil {
+			output["obs_api"] = f.OBSAPI.String()
+		}
+	case FeedTypeManifest:
+		output["manifest"] = f.Manifest
+	case FeedTypePPA:
+		output["ppa"] = "ppa:" + f.Name
+	case FeedTypePlugin:
+		output["plugin"] = f.Plugin
+		output["location"] = f.Name
+		if len(f.Settings) > 0 {
+			output["settings"] = f.Settings
+		}
 	}
 
 	// Add common fields
 	if len(f.Distributions) > 0 {
 		output["distributions"] = f.Distributions
 	}
+	if len(f.Components) > 0 {
+		output["components"] = f.Components
+	}
+	if len(f.SigningKeys) > 0 {
+		output["signing_keys"] = f.SigningKeys
+	}
+	if f.KeyChange != "" {
+		output["key_change"] = f.KeyChange
+	}
 	if len(f.FromSources) > 0 {
 		output["from_sources"] = f.FromSources
 	}
 	if len(f.Packages) > 0 {
 		output["packages"] = f.Packages
 	}
+	if f.Priority != 0 {
+		output["priority"] = f.Priority
+	}
+	if f.Timeout != 0 {
+		output["timeout"] = f.Timeout
+	}
+	if f.TargetComponent != "" {
+		output["target_component"] = f.TargetComponent
+	}
 	if len(f.Releases) > 0 {
 		releases := make([]string, len(f.Releases))
 		for i, r := range f.Releases {
@@ -273,6 +550,15 @@ func (f FeedOptions) MarshalYAML() (any, error) {
 	if f.NoChanges {
 		output["no_changes"] = true
 	}
+	if len(f.Routes) > 0 {
+		output["routes"] = f.Routes
+	}
+	if len(f.ExcludeAssets) > 0 {
+		output["exclude_assets"] = f.ExcludeAssets
+	}
+	if len(f.Checksums) > 0 {
+		output["checksums"] = f.Checksums
+	}
 
 	return output, nil
 }
@@ -259,3 +259,247 @@ func TestFeedOptions_MarshalYAML_OBS(t *testing.T) {
 		})
 	}
 }
+
+func TestDistributionMap_YAML(t *testing.T) {
+	tests := []struct {
+		name     string
+		yaml     string
+		want     DistributionMap
+		wantYAML string
+	}{
+		{
+			name:     "string",
+			yaml:     `noble`,
+			want:     DistributionMap{Feed: "noble"},
+			wantYAML: "noble\n",
+		},
+		{
+			name:     "rename",
+			yaml:     `{focal: stable}`,
+			want:     DistributionMap{Feed: "focal", Target: "stable"},
+			wantYAML: "focal: stable\n",
+		},
+		{
+			name:     "extended with components",
+			yaml:     `{noble: {target: stable, components: [main, universe]}}`,
+			want:     DistributionMap{Feed: "noble", Target: "stable", Components: []string{"main", "universe"}},
+			wantYAML: "noble:\n    target: stable\n    components:\n        - main\n        - universe\n",
+		},
+		{
+			name:     "extended without target",
+			yaml:     `{noble: {components: [main]}}`,
+			want:     DistributionMap{Feed: "noble", Components: []string{"main"}},
+			wantYAML: "noble:\n    components:\n        - main\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var d DistributionMap
+			require.NoError(t, yaml.Unmarshal([]byte(tt.yaml), &d))
+			assert.Equal(t, tt.want, d)
+
+			data, err := yaml.Marshal(d)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantYAML, string(data))
+		})
+	}
+}
+
+func TestReleaseRoute_Matches(t *testing.T) {
+	tests := []struct {
+		name        string
+		route       ReleaseRoute
+		releaseType ReleaseType
+		tag         string
+		want        bool
+	}{
+		{
+			name:        "release type matches",
+			route:       ReleaseRoute{Releases: []ReleaseType{ReleaseTypePrerelease}, Suffix: "-beta"},
+			releaseType: ReleaseTypePrerelease,
+			tag:         "v1.0.0",
+			want:        true,
+		},
+		{
+			name:        "release type differs",
+			route:       ReleaseRoute{Releases: []ReleaseType{ReleaseTypePrerelease}, Suffix: "-beta"},
+			releaseType: ReleaseTypeRelease,
+			tag:         "v1.0.0",
+			want:        false,
+		},
+		{
+			name:        "tag pattern matches any type",
+			route:       ReleaseRoute{Tags: []string{"*-rc*"}, Suffix: "-rc"},
+			releaseType: ReleaseTypeRelease,
+			tag:         "v1.0.0-rc1",
+			want:        true,
+		},
+		{
+			name:        "both must match",
+			route:       ReleaseRoute{Releases: []ReleaseType{ReleaseTypePrerelease}, Tags: []string{"*-rc*"}, Suffix: "-rc"},
+			releaseType: ReleaseTypePrerelease,
+			tag:         "v1.0.0-beta1",
+			want:        false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.route.Matches(tt.releaseType, tt.tag))
+		})
+	}
+}
+
+func TestFeedOptions_UnmarshalYAML_Plugin(t *testing.T) {
+	var opts FeedOptions
+	err := yaml.Unmarshal([]byte(`
+plugin: local
+location: builds
+settings:
+  path: /srv/builds
+`), &opts)
+	require.NoError(t, err)
+
+	assert.Equal(t, FeedTypePlugin, opts.Type)
+	assert.Equal(t, "local", opts.Plugin)
+	assert.Equal(t, "builds", opts.Name)
+	assert.Equal(t, "plugin/local/builds", opts.RelativePath)
+	assert.Equal(t, map[string]string{"path": "/srv/builds"}, opts.Settings)
+	assert.Nil(t, opts.ProjectURL)
+
+	// Round trip through marshaling
+	data, err := yaml.Marshal(opts)
+	require.NoError(t, err)
+	var roundTrip FeedOptions
+	require.NoError(t, yaml.Unmarshal(data, &roundTrip))
+	assert.Equal(t, opts, roundTrip)
+}
+
+func TestFeedOptions_UnmarshalYAML_Manifest(t *testing.T) {
+	tests := []struct {
+		name         string
+		manifest     string
+		wantName     string
+		wantRelative string
+	}{
+		{"remote", "https://raw.example.com/org/debs/main/manifest.yaml", "raw.example.com/org/debs/main/manifest.yaml", "manifest/raw.example.com/org/debs/main/manifest"},
+		{"local", "manifests/tools.json", "manifests/tools.json", "manifest/manifests/tools"},
+		{"local outside config dir", "../manifests/tools.yaml", "../manifests/tools.yaml", "manifest/manifests/tools"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts FeedOptions
+			require.NoError(t, yaml.Unmarshal([]byte("manifest: "+tt.manifest), &opts))
+
+			assert.Equal(t, FeedTypeManifest, opts.Type)
+			assert.Equal(t, tt.manifest, opts.Manifest)
+			assert.Equal(t, tt.wantName, opts.Name)
+			assert.Equal(t, tt.wantRelative, opts.RelativePath)
+
+			data, err := yaml.Marshal(opts)
+			require.NoError(t, err)
+			var roundTrip FeedOptions
+			require.NoError(t, yaml.Unmarshal(data, &roundTrip))
+			assert.Equal(t, opts, roundTrip)
+		})
+	}
+
+	var opts FeedOptions
+	assert.Error(t, yaml.Unmarshal([]byte("manifest: ftp://example.com/manifest.yaml"), &opts))
+}
+
+func TestFeedOptions_UnmarshalYAML_ExcludeAssets(t *testing.T) {
+	var opts FeedOptions
+	err := yaml.Unmarshal([]byte(`
+github: owner/repo
+exclude_assets: ["*-musl_*.deb"]
+`), &opts)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"*-musl_*.deb"}, opts.ExcludeAssets)
+
+	data, err := yaml.Marshal(opts)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "exclude_assets")
+}
+
+func TestFeedOptions_UnmarshalYAML_Checksums(t *testing.T) {
+	var opts FeedOptions
+	err := yaml.Unmarshal([]byte(`
+github: owner/repo
+no_changes: true
+checksums: ["*.sha256sums"]
+`), &opts)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"*.sha256sums"}, opts.Checksums)
+
+	data, err := yaml.Marshal(opts)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "checksums")
+}
+
+func TestFeedOptions_DefaultComponent(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		want string
+	}{
+		{"github", "github: owner/Vault_Warden", "vault-warden"},
+		{"obs project", "obs: home:dionysius:immich", "immich"},
+		{"custom obs", "obs: https://download.example.org/repositories/home:/user:/project/", "project"},
+		{"apt", "apt: https://deb.example.org/debian", "debian"},
+		{"manifest", "manifest: https://raw.example.com/org/debs/main/tools.yaml", "tools"},
+		{"ppa", "ppa: ppa:deadsnakes/ppa", "ppa"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts FeedOptions
+			require.NoError(t, yaml.Unmarshal([]byte(tt.yaml), &opts))
+			assert.Equal(t, tt.want, opts.DefaultComponent())
+		})
+	}
+
+	var opts FeedOptions
+	require.NoError(t, yaml.Unmarshal([]byte("github: owner/repo\ntarget_component: tools"), &opts))
+	assert.Equal(t, "tools", opts.TargetComponent)
+
+	data, err := yaml.Marshal(opts)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "target_component: tools")
+}
+
+func TestFeedOptions_selectFile(t *testing.T) {
+	mapped := &FeedOptions{Distributions: []DistributionMap{{Feed: "bookworm", Target: "stable"}}}
+	filtered := &FeedOptions{FromSources: []string{"hello*"}, Packages: []string{"hello"}}
+
+	tests := []struct {
+		name       string
+		options    *FeedOptions
+		filename   string
+		dist       string
+		source     string
+		wantTarget string
+		wantSource string
+		wantOK     bool
+	}{
+		{name: "unmapped feed", options: &FeedOptions{}, filename: "bookworm/hello_1.0_amd64.deb", dist: "bookworm", wantTarget: "bookworm", wantSource: "hello", wantOK: true},
+		{name: "mapped distribution", options: mapped, filename: "hello_1.0_amd64.deb", dist: "bookworm", wantTarget: "stable", wantSource: "hello", wantOK: true},
+		{name: "distribution not mapped", options: mapped, filename: "hello_1.0_amd64.deb", dist: "trixie"},
+		{name: "reported source", options: &FeedOptions{}, filename: "hello-doc_1.0_all.deb", dist: "bookworm", source: "hello", wantTarget: "bookworm", wantSource: "hello", wantOK: true},
+		{name: "source matches from_sources", options: filtered, filename: "hello_1.0_amd64.deb", dist: "bookworm", wantTarget: "bookworm", wantSource: "hello", wantOK: true},
+		{name: "source filtered by from_sources", options: filtered, filename: "other_1.0_amd64.deb", dist: "bookworm"},
+		{name: "binary filtered by packages", options: filtered, filename: "hello-doc_1.0_all.deb", dist: "bookworm", source: "hello"},
+		{name: "packages only filter binaries", options: filtered, filename: "hello-doc_1.0_amd64.buildinfo", dist: "bookworm", source: "hello", wantTarget: "bookworm", wantSource: "hello", wantOK: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target, source, ok := tt.options.selectFile(tt.filename, tt.dist, tt.source)
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.wantTarget, target)
+			assert.Equal(t, tt.wantSource, source)
+		})
+	}
+}
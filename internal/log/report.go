@@ -0,0 +1,130 @@
+package log
+
+import (
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/dionysius/aarg/internal/common"
+)
+
+// Report formats
+const (
+	ReportNone = ""
+	ReportJSON = "json"
+)
+
+// ReportFormats are the supported formats of the run report printed to stdout
+var ReportFormats = []string{ReportNone, ReportJSON}
+
+// RepositoryRecord is what the run recorded about a repository for the run report
+type RepositoryRecord struct {
+	Added    []string                                // Packages and versions not in the previous build, as dist/component/arch/name version
+	Removed  []string                                // Packages not in the build anymore, as dist/component/arch/name
+	Dropped  []string                                // Packages in trusted storage dropped by retention
+	Upstream map[string][]string                     // Versions in trusted storage per source package
+	Errors   []string                                // Errors failing the repository
+	Timings  map[string]float64                      // Duration of the phases in seconds
+	Assets   map[string]common.DownloadStatsSnapshot // Release assets cached and downloaded per feed
+}
+
+// records collects the repository records of the run
+var records struct {
+	mu           sync.Mutex
+	repositories map[string]*RepositoryRecord
+}
+
+// record calls fn with the record of a repository
+func record(repository string, fn func(*RepositoryRecord)) {
+	records.mu.Lock()
+	defer records.mu.Unlock()
+
+	if records.repositories == nil {
+		records.repositories = make(map[string]*RepositoryRecord)
+	}
+	if records.repositories[repository] == nil {
+		records.repositories[repository] = &RepositoryRecord{
+			Upstream: make(map[string][]string),
+			Timings:  make(map[string]float64),
+			Assets:   make(map[string]common.DownloadStatsSnapshot),
+		}
+	}
+	fn(records.repositories[repository])
+}
+
+// RecordChanges records the packages added and removed by a generated build compared to the previous one
+func RecordChanges(repository string, added, removed []string) {
+	record(repository, func(r *RepositoryRecord) {
+		r.Added = append(r.Added, added...)
+		r.Removed = append(r.Removed, removed...)
+	})
+}
+
+// RecordRetention records the packages dropped by retention and the versions seen per source package
+func RecordRetention(repository string, dropped []string, upstream map[string][]string) {
+	record(repository, func(r *RepositoryRecord) {
+		r.Dropped = append(r.Dropped, dropped...)
+		maps.Copy(r.Upstream, upstream)
+	})
+}
+
+// RecordError records an error failing a repository
+func RecordError(repository string, err error) {
+	record(repository, func(r *RepositoryRecord) {
+		r.Errors = append(r.Errors, err.Error())
+	})
+}
+
+// RecordTiming records the duration of a phase for repositories
+// A phase recorded several times, like fetch of each feed running in parallel, keeps its longest duration
+func RecordTiming(phase string, d time.Duration, repositories ...string) {
+	seconds := d.Round(time.Millisecond).Seconds()
+	for _, repository := range repositories {
+		record(repository, func(r *RepositoryRecord) {
+			r.Timings[phase] = max(r.Timings[phase], seconds)
+		})
+	}
+}
+
+// RecordAssets records the release assets a feed found cached or downloaded, stats are per release
+// The totals are also counted for the run summary
+func RecordAssets(repository, feed string, stats map[string]common.DownloadStatsSnapshot) {
+	var total common.DownloadStatsSnapshot
+	for _, release := range stats {
+		total = total.Add(release)
+	}
+
+	counters.cachedAssets.Add(total.Cached)
+	counters.saved.Add(total.BytesSaved)
+	record(repository, func(r *RepositoryRecord) {
+		r.Assets[feed] = r.Assets[feed].Add(total)
+	})
+}
+
+// ResetRecords drops the repository records of the run, long-lived commands start every build with none
+func ResetRecords() {
+	records.mu.Lock()
+	defer records.mu.Unlock()
+	records.repositories = nil
+}
+
+// Record returns a copy of the record of a repository, empty if nothing was recorded
+func Record(repository string) RepositoryRecord {
+	records.mu.Lock()
+	defer records.mu.Unlock()
+
+	r, ok := records.repositories[repository]
+	if !ok {
+		return RepositoryRecord{Upstream: map[string][]string{}, Timings: map[string]float64{}, Assets: map[string]common.DownloadStatsSnapshot{}}
+	}
+	return RepositoryRecord{
+		Added:    slices.Clone(r.Added),
+		Removed:  slices.Clone(r.Removed),
+		Dropped:  slices.Clone(r.Dropped),
+		Upstream: maps.Clone(r.Upstream),
+		Errors:   slices.Clone(r.Errors),
+		Timings:  maps.Clone(r.Timings),
+		Assets:   maps.Clone(r.Assets),
+	}
+}
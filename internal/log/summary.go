@@ -0,0 +1,177 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dionysius/aarg/internal/common"
+)
+
+// Summary formats
+const (
+	SummaryText = "text"
+	SummaryJSON = "json"
+)
+
+// SummaryFormats are the supported formats of the end of run summary
+var SummaryFormats = []string{SummaryText, SummaryJSON}
+
+// Summary is the outcome of a run printed at its end
+type Summary struct {
+	Command         string           `json:"command"`
+	Status          string           `json:"status"` // "ok" or "failed"
+	Error           string           `json:"error,omitempty"`
+	Repositories    int64            `json:"repositories"`
+	PackagesAdded   int64            `json:"packages_added"`
+	PackagesKept    int64            `json:"packages_kept"`
+	PackagesRemoved int64            `json:"packages_removed"`
+	BytesDownloaded int64            `json:"bytes_downloaded"`
+	BytesUploaded   int64            `json:"bytes_uploaded"`
+	AssetsCached    int64            `json:"assets_cached"` // Release assets not downloaded again since the cached file matched
+	BytesSaved      int64            `json:"bytes_saved"`
+	Warnings        int              `json:"warnings"`
+	DurationSeconds float64          `json:"duration_seconds"`
+	Generator       common.BuildInfo `json:"generator"`
+	Config          string           `json:"config,omitempty"` // Fingerprint of the configuration, empty if it couldn't be loaded
+}
+
+// counters accumulates the summary of the run
+var counters struct {
+	repositories sync.Map // name -> struct{}, repositories are counted once per run
+	added        atomic.Int64
+	kept         atomic.Int64
+	removed      atomic.Int64
+	downloaded   atomic.Int64
+	uploaded     atomic.Int64
+	cachedAssets atomic.Int64
+	saved        atomic.Int64
+}
+
+// CountRepositories records repositories processed by the run
+func CountRepositories(names ...string) {
+	for _, name := range names {
+		counters.repositories.Store(name, struct{}{})
+	}
+}
+
+// CountPackages records package changes of a generated build compared to the previous one
+func CountPackages(added, kept, removed int) {
+	counters.added.Add(int64(added))
+	counters.kept.Add(int64(kept))
+	counters.removed.Add(int64(removed))
+}
+
+// CountDownloaded records bytes downloaded by the run
+func CountDownloaded(bytes int64) {
+	counters.downloaded.Add(bytes)
+}
+
+// CountUploaded records bytes uploaded by the run
+func CountUploaded(bytes int64) {
+	counters.uploaded.Add(bytes)
+}
+
+// ResetCounters zeroes the summary counters of the run, long-lived commands start every build with none
+func ResetCounters() {
+	counters.repositories.Clear()
+	counters.added.Store(0)
+	counters.kept.Store(0)
+	counters.removed.Store(0)
+	counters.downloaded.Store(0)
+	counters.uploaded.Store(0)
+	counters.cachedAssets.Store(0)
+	counters.saved.Store(0)
+}
+
+// ResetRun drops the warnings, records and counters of the run
+func ResetRun() {
+	ResetWarnings()
+	ResetRecords()
+	ResetCounters()
+}
+
+// NewSummary returns the summary of the run of command started at start, err is the outcome of the run
+func NewSummary(command string, start time.Time, err error) Summary {
+	summary := Summary{
+		Command:         command,
+		Status:          "ok",
+		Repositories:    countRepositories(),
+		PackagesAdded:   counters.added.Load(),
+		PackagesKept:    counters.kept.Load(),
+		PackagesRemoved: counters.removed.Load(),
+		BytesDownloaded: counters.downloaded.Load(),
+		BytesUploaded:   counters.uploaded.Load(),
+		AssetsCached:    counters.cachedAssets.Load(),
+		BytesSaved:      counters.saved.Load(),
+		Warnings:        len(Warnings()),
+		DurationSeconds: time.Since(start).Round(time.Millisecond).Seconds(),
+		Generator:       common.CurrentBuild(),
+	}
+	if err != nil {
+		summary.Status = "failed"
+		summary.Error = err.Error()
+	}
+	return summary
+}
+
+// Write prints the summary to w in format
+func (s Summary) Write(w io.Writer, format string) error {
+	if format == SummaryJSON {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(s)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	rows := [][2]string{
+		{"Command", s.Command},
+		{"Status", s.Status},
+		{"Repositories", fmt.Sprint(s.Repositories)},
+		{"Packages", fmt.Sprintf("%d added, %d kept, %d removed", s.PackagesAdded, s.PackagesKept, s.PackagesRemoved)},
+		{"Downloaded", common.FormatSize(uint64(s.BytesDownloaded))},
+		{"Uploaded", common.FormatSize(uint64(s.BytesUploaded))},
+		{"Cached", fmt.Sprintf("%d assets, %s saved", s.AssetsCached, common.FormatSize(uint64(s.BytesSaved)))},
+		{"Warnings", fmt.Sprint(s.Warnings)},
+		{"Duration", time.Duration(s.DurationSeconds * float64(time.Second)).String()},
+		{"Version", s.Generator.String()},
+	}
+	if s.Config != "" {
+		rows = append(rows, [2]string{"Config", s.Config})
+	}
+	if s.Error != "" {
+		rows = append(rows, [2]string{"Error", s.Error})
+	}
+
+	_, _ = fmt.Fprintln(tw, "Summary")
+	for _, row := range rows {
+		_, _ = fmt.Fprintf(tw, "  %s\t%s\n", row[0], row[1])
+	}
+	return tw.Flush()
+}
+
+// Repositories returns the names of the repositories recorded, sorted by name
+func Repositories() []string {
+	var names []string
+	counters.repositories.Range(func(name, _ any) bool {
+		names = append(names, name.(string))
+		return true
+	})
+	slices.Sort(names)
+	return names
+}
+
+// countRepositories returns the number of distinct repositories recorded
+func countRepositories() int64 {
+	var n int64
+	counters.repositories.Range(func(_, _ any) bool {
+		n++
+		return true
+	})
+	return n
+}
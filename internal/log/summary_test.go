@@ -0,0 +1,69 @@
+package log
+
+import (
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/dionysius/aarg/internal/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResetRun(t *testing.T) {
+	ResetRun()
+	t.Cleanup(ResetRun)
+
+	slog.New(NewWarningCollector(slog.DiscardHandler)).Warn("warning")
+	CountRepositories("hello")
+	CountPackages(1, 2, 3)
+	CountDownloaded(10)
+	CountUploaded(20)
+	RecordError("hello", errors.New("failed"))
+	RecordTiming("fetch", time.Second, "hello")
+	RecordAssets("hello", "owner/hello", map[string]common.DownloadStatsSnapshot{"v1": {Cached: 1, BytesSaved: 5}})
+
+	summary := NewSummary("daemon", time.Now(), nil)
+	assert.Equal(t, int64(1), summary.Repositories)
+	assert.Equal(t, int64(10), summary.BytesDownloaded)
+	assert.Equal(t, 1, summary.Warnings)
+	assert.Equal(t, []string{"failed"}, Record("hello").Errors)
+
+	ResetRun()
+
+	summary = NewSummary("daemon", time.Now(), nil)
+	assert.Zero(t, summary.Repositories)
+	assert.Zero(t, summary.PackagesAdded+summary.PackagesKept+summary.PackagesRemoved)
+	assert.Zero(t, summary.BytesDownloaded+summary.BytesUploaded)
+	assert.Zero(t, summary.AssetsCached+summary.BytesSaved)
+	assert.Zero(t, summary.Warnings)
+	assert.Empty(t, Repositories())
+	assert.Empty(t, Record("hello").Errors)
+	assert.Empty(t, Record("hello").Timings)
+	assert.Empty(t, Record("hello").Assets)
+}
+
+func TestRecordAssets(t *testing.T) {
+	ResetRun()
+	t.Cleanup(ResetRun)
+
+	RecordAssets("hello", "owner/hello", map[string]common.DownloadStatsSnapshot{
+		"v1.0": {Cached: 2, BytesSaved: 200},
+		"v1.1": {Cached: 1, Downloaded: 1, BytesSaved: 100, BytesDownloaded: 50},
+	})
+	RecordAssets("hello", "owner/other", map[string]common.DownloadStatsSnapshot{
+		"v2.0": {Downloaded: 1, BytesDownloaded: 10},
+	})
+	RecordAssets("world", "owner/hello", nil)
+
+	// Releases are summed per feed
+	assert.Equal(t, map[string]common.DownloadStatsSnapshot{
+		"owner/hello": {Cached: 3, Downloaded: 1, BytesSaved: 300, BytesDownloaded: 50},
+		"owner/other": {Downloaded: 1, BytesDownloaded: 10},
+	}, Record("hello").Assets)
+	assert.Equal(t, map[string]common.DownloadStatsSnapshot{"owner/hello": {}}, Record("world").Assets)
+
+	summary := NewSummary("fetch", time.Now(), nil)
+	assert.Equal(t, int64(3), summary.AssetsCached)
+	assert.Equal(t, int64(300), summary.BytesSaved)
+}
@@ -0,0 +1,165 @@
+package log
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/dionysius/aarg/internal/common"
+)
+
+// RepositoryKey is the attribute key warnings are assigned to repositories by
+const RepositoryKey = "repository"
+
+// Warning is a warning logged during the run
+type Warning struct {
+	Time    time.Time         `json:"time"`
+	Message string            `json:"message"`
+	Attrs   map[string]string `json:"attrs,omitempty"`
+}
+
+// maxWarnings is the number of warnings kept, long-lived commands like serve drop the oldest beyond it
+const maxWarnings = 1000
+
+// warningStore collects the warnings of the run, shared by all collectors derived from one another
+type warningStore struct {
+	mu       sync.Mutex
+	warnings []Warning
+}
+
+// defaultStore collects the warnings of the default logger
+var defaultStore = &warningStore{}
+
+// WarningCollector is a slog handler recording warnings before passing all records on
+type WarningCollector struct {
+	next  slog.Handler
+	attrs []slog.Attr
+	group string
+	store *warningStore
+}
+
+// NewWarningCollector wraps next and records its warnings for Warnings and PrintWarnings
+func NewWarningCollector(next slog.Handler) *WarningCollector {
+	return &WarningCollector{next: next, store: defaultStore}
+}
+
+// Enabled reports whether the handler handles records at the given level
+// Warnings are always recorded, even if next is configured to drop them
+func (c *WarningCollector) Enabled(ctx context.Context, level slog.Level) bool {
+	return level == slog.LevelWarn || c.next.Enabled(ctx, level)
+}
+
+// Handle records warnings and passes the record to the wrapped handler
+func (c *WarningCollector) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level == slog.LevelWarn {
+		warning := Warning{Time: common.InDisplayLocation(r.Time), Message: r.Message, Attrs: make(map[string]string)}
+		addAttr := func(a slog.Attr) bool {
+			if a.Key != SuccessKey {
+				warning.Attrs[c.group+a.Key] = a.Value.String()
+			}
+			return true
+		}
+		for _, a := range c.attrs {
+			addAttr(a)
+		}
+		r.Attrs(addAttr)
+
+		c.store.add(warning)
+	}
+
+	if !c.next.Enabled(ctx, r.Level) {
+		return nil
+	}
+	return c.next.Handle(ctx, r)
+}
+
+// WithAttrs returns a new WarningCollector with the given attributes
+func (c *WarningCollector) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &WarningCollector{
+		next:  c.next.WithAttrs(attrs),
+		attrs: append(append([]slog.Attr{}, c.attrs...), attrs...),
+		group: c.group,
+		store: c.store,
+	}
+}
+
+// WithGroup returns a new WarningCollector with the given group
+func (c *WarningCollector) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return c
+	}
+	return &WarningCollector{
+		next:  c.next.WithGroup(name),
+		attrs: c.attrs,
+		group: c.group + name + ".",
+		store: c.store,
+	}
+}
+
+// PrintWarnings repeats the recorded warnings as summary through the wrapped handler
+func (c *WarningCollector) PrintWarnings(ctx context.Context) {
+	warnings := c.store.list()
+	if len(warnings) == 0 {
+		return
+	}
+
+	summary := slog.NewRecord(time.Now(), slog.LevelWarn, fmt.Sprintf("Run finished with %d warnings", len(warnings)), 0)
+	_ = c.next.Handle(ctx, summary)
+
+	for _, warning := range warnings {
+		r := slog.NewRecord(warning.Time, slog.LevelWarn, "  "+warning.Message, 0)
+		for _, key := range slices.Sorted(maps.Keys(warning.Attrs)) {
+			r.AddAttrs(slog.String(key, warning.Attrs[key]))
+		}
+		_ = c.next.Handle(ctx, r)
+	}
+}
+
+// add records a warning, dropping the oldest beyond maxWarnings
+func (s *warningStore) add(warning Warning) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.warnings) >= maxWarnings {
+		s.warnings = slices.Delete(s.warnings, 0, len(s.warnings)-maxWarnings+1)
+	}
+	s.warnings = append(s.warnings, warning)
+}
+
+// reset drops the recorded warnings
+func (s *warningStore) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.warnings = nil
+}
+
+// list returns a copy of the recorded warnings
+func (s *warningStore) list() []Warning {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Warning{}, s.warnings...)
+}
+
+// Warnings returns the warnings recorded so far
+func Warnings() []Warning {
+	return defaultStore.list()
+}
+
+// ResetWarnings drops the warnings recorded so far, long-lived commands start every build with none
+func ResetWarnings() {
+	defaultStore.reset()
+}
+
+// RepositoryWarnings returns the warnings recorded so far for a repository
+func RepositoryWarnings(repository string) []Warning {
+	var warnings []Warning
+	for _, warning := range defaultStore.list() {
+		if warning.Attrs[RepositoryKey] == repository {
+			warnings = append(warnings, warning)
+		}
+	}
+	return warnings
+}
@@ -0,0 +1,58 @@
+package log
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWarningCollector(t *testing.T) {
+	ResetWarnings()
+	t.Cleanup(ResetWarnings)
+
+	logger := slog.New(NewWarningCollector(slog.DiscardHandler))
+	logger.Info("not recorded")
+	logger.With(RepositoryKey, "hello").Warn("first", "feed", "github")
+	logger.WithGroup("upload").Warn("second", "file", "a.deb")
+
+	warnings := Warnings()
+	require.Len(t, warnings, 2)
+	assert.Equal(t, "first", warnings[0].Message)
+	assert.Equal(t, map[string]string{RepositoryKey: "hello", "feed": "github"}, warnings[0].Attrs)
+	assert.Equal(t, map[string]string{"upload.file": "a.deb"}, warnings[1].Attrs)
+
+	assert.Len(t, RepositoryWarnings("hello"), 1)
+	assert.Empty(t, RepositoryWarnings("other"))
+
+	ResetWarnings()
+	assert.Empty(t, Warnings())
+	assert.Empty(t, RepositoryWarnings("hello"))
+
+	// Warnings after a reset are recorded again
+	logger.Warn("third")
+	assert.Len(t, Warnings(), 1)
+}
+
+func TestWarningCollector_MaxWarnings(t *testing.T) {
+	ResetWarnings()
+	t.Cleanup(ResetWarnings)
+
+	collector := NewWarningCollector(slog.DiscardHandler)
+	logger := slog.New(collector)
+	for i := range maxWarnings + 5 {
+		logger.Warn(fmt.Sprint(i))
+	}
+
+	warnings := Warnings()
+	require.Len(t, warnings, maxWarnings)
+	assert.Equal(t, "5", warnings[0].Message)
+	assert.Equal(t, fmt.Sprint(maxWarnings+4), warnings[maxWarnings-1].Message)
+
+	// The summary is printed through the wrapped handler without recording it again
+	collector.PrintWarnings(context.Background())
+	assert.Len(t, Warnings(), maxWarnings)
+}
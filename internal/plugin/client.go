@@ -0,0 +1,213 @@
+package plugin
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os/exec"
+	"slices"
+	"sync"
+	"time"
+)
+
+// maxMessageSize limits a single protocol line
+const maxMessageSize = 16 * 1024 * 1024
+
+// shutdownTimeout is how long a plugin gets to exit after the shutdown request
+const shutdownTimeout = 5 * time.Second
+
+// Command configures how a plugin executable is started
+type Command struct {
+	Command  string            `yaml:"command"`            // Path to the plugin executable
+	Args     []string          `yaml:"args,omitempty"`     // Arguments passed to the executable
+	Settings map[string]string `yaml:"settings,omitempty"` // Plugin-specific settings passed with each request
+}
+
+// Client talks to a running plugin process
+type Client struct {
+	name      string
+	cmd       *exec.Cmd
+	stdin     io.WriteCloser
+	responses chan *Response
+	done      chan struct{}
+	readErr   error
+	stopped   chan struct{}
+	stopOnce  sync.Once
+
+	mu     sync.Mutex // Serializes calls, the protocol handles one request at a time
+	nextID uint64
+}
+
+// Start launches the plugin and performs the handshake for the requested kind
+func Start(ctx context.Context, command Command, kind Kind) (*Client, error) {
+	cmd := exec.Command(command.Command, command.Args...)
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create plugin stdin: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create plugin stdout: %w", err)
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create plugin stderr: %w", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start plugin %s: %w", command.Command, err)
+	}
+
+	c := &Client{
+		name:      command.Command,
+		cmd:       cmd,
+		stdin:     stdin,
+		responses: make(chan *Response),
+		done:      make(chan struct{}),
+		stopped:   make(chan struct{}),
+	}
+
+	go c.forwardStderr(stderr)
+	go c.readResponses(stdout)
+
+	var result HandshakeResult
+	if err := c.Call(ctx, MethodHandshake, HandshakeParams{ProtocolVersion: ProtocolVersion, Kind: kind}, &result); err != nil {
+		c.abort()
+		return nil, fmt.Errorf("plugin %s handshake failed: %w", command.Command, err)
+	}
+	if result.ProtocolVersion != ProtocolVersion {
+		c.abort()
+		return nil, fmt.Errorf("%w: plugin %s speaks %d, expected %d", ErrProtocolVersion, command.Command, result.ProtocolVersion, ProtocolVersion)
+	}
+	if !slices.Contains(result.Kinds, kind) {
+		c.abort()
+		return nil, fmt.Errorf("%w: %s is not a %s plugin", ErrKindMismatch, command.Command, kind)
+	}
+	if result.Name != "" {
+		c.name = result.Name
+	}
+
+	slog.Debug("Started plugin", "plugin", c.name, "kind", kind)
+	return c, nil
+}
+
+// Name returns the name the plugin reported during the handshake
+func (c *Client) Name() string {
+	return c.name
+}
+
+// Call sends a request and decodes the result into result (may be nil)
+func (c *Client) Call(ctx context.Context, method string, params, result any) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.nextID++
+	req := Request{JSONRPC: "2.0", ID: c.nextID, Method: method}
+	if params != nil {
+		data, err := json.Marshal(params)
+		if err != nil {
+			return fmt.Errorf("failed to encode %s params: %w", method, err)
+		}
+		req.Params = data
+	}
+
+	line, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s request: %w", method, err)
+	}
+	if _, err := c.stdin.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to send %s request: %w", method, err)
+	}
+
+	select {
+	case <-ctx.Done():
+		// The plugin state is unknown after an abandoned request
+		c.kill()
+		return ctx.Err()
+	case <-c.done:
+		return fmt.Errorf("%w during %s: %v", ErrPluginExited, method, c.readErr)
+	case resp := <-c.responses:
+		if resp.ID != req.ID {
+			return fmt.Errorf("plugin answered request %d, expected %d", resp.ID, req.ID)
+		}
+		if resp.Error != nil {
+			return resp.Error
+		}
+		if result != nil && len(resp.Result) > 0 {
+			if err := json.Unmarshal(resp.Result, result); err != nil {
+				return fmt.Errorf("failed to decode %s result: %w", method, err)
+			}
+		}
+		return nil
+	}
+}
+
+// Close asks the plugin to shut down and waits for it to exit
+func (c *Client) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := c.Call(ctx, MethodShutdown, nil, nil); err != nil {
+		slog.Debug("Plugin did not acknowledge shutdown", "plugin", c.name, "error", err)
+	}
+	_ = c.stdin.Close()
+
+	exited := make(chan error, 1)
+	go func() { exited <- c.cmd.Wait() }()
+
+	select {
+	case err := <-exited:
+		return err
+	case <-ctx.Done():
+		c.kill()
+		return fmt.Errorf("plugin %s did not exit in time", c.name)
+	}
+}
+
+// kill terminates the plugin process without waiting for a graceful shutdown
+func (c *Client) kill() {
+	c.stopOnce.Do(func() { close(c.stopped) })
+	_ = c.stdin.Close()
+	if c.cmd.Process != nil {
+		_ = c.cmd.Process.Kill()
+	}
+}
+
+// abort kills the plugin process of a failed start and reaps it, no client is returned to close it
+func (c *Client) abort() {
+	c.kill()
+	_ = c.cmd.Wait()
+}
+
+// readResponses reads line-delimited responses until the plugin closes stdout
+func (c *Client) readResponses(stdout io.Reader) {
+	defer close(c.done)
+
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), maxMessageSize)
+	for scanner.Scan() {
+		var resp Response
+		if err := json.Unmarshal(scanner.Bytes(), &resp); err != nil {
+			c.readErr = fmt.Errorf("invalid response: %w", err)
+			return
+		}
+		select {
+		case c.responses <- &resp:
+		case <-c.stopped:
+			return
+		}
+	}
+	c.readErr = scanner.Err()
+}
+
+// forwardStderr logs everything the plugin writes to stderr
+func (c *Client) forwardStderr(stderr io.Reader) {
+	scanner := bufio.NewScanner(stderr)
+	for scanner.Scan() {
+		slog.Debug(scanner.Text(), "plugin", c.name)
+	}
+}
@@ -0,0 +1,75 @@
+package plugin
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FetchDirectory copies the .deb files of root laid out as {root}/{distribution}/*.deb into downloadDir
+// Feed plugins providing local builds answer fetch requests with the returned result
+func FetchDirectory(ctx context.Context, root, downloadDir string) (FetchResult, error) {
+	var result FetchResult
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(path, ".deb") {
+			return err
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		dist, _, found := strings.Cut(filepath.ToSlash(rel), "/")
+		if !found {
+			return nil // Files must be placed in a distribution directory
+		}
+
+		hash, err := CopyFile(path, filepath.Join(downloadDir, rel))
+		if err != nil {
+			return err
+		}
+
+		result.Files = append(result.Files, FetchedFile{Path: filepath.ToSlash(rel), Distribution: dist, SHA256: hash})
+		return ctx.Err()
+	})
+
+	return result, err
+}
+
+// CopyFile copies src to dst (following symlinks) and returns the SHA256 of the content
+// dst is replaced by rename since existing files may be hardlinked elsewhere
+func CopyFile(src, dst string) (string, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = in.Close() }()
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return "", err
+	}
+	out, err := os.CreateTemp(filepath.Dir(dst), ".tmp-*")
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = os.Remove(out.Name()) }()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(out, hasher), in); err != nil {
+		_ = out.Close()
+		return "", err
+	}
+	if err := out.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Chmod(out.Name(), 0644); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hasher.Sum(nil)), os.Rename(out.Name(), dst)
+}
@@ -0,0 +1,99 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMain lets the test binary act as a feed plugin when started by the tests
+func TestMain(m *testing.M) {
+	if os.Getenv("AARG_TEST_PLUGIN") == "1" {
+		if pidFile := os.Getenv("AARG_TEST_PLUGIN_PIDFILE"); pidFile != "" {
+			_ = os.WriteFile(pidFile, []byte(strconv.Itoa(os.Getpid())), 0644)
+		}
+		server := &Server{
+			Name:  "test",
+			Kinds: []Kind{KindFeed},
+			Handlers: map[string]Handler{
+				MethodFetch: func(ctx context.Context, raw json.RawMessage) (any, error) {
+					var params FetchParams
+					if err := json.Unmarshal(raw, &params); err != nil {
+						return nil, err
+					}
+					if params.Location == "fail" {
+						return nil, errors.New("fetch failed")
+					}
+					return FetchResult{Files: []FetchedFile{{Path: params.Location + ".deb", Distribution: "stable", SHA256: "abc"}}}, nil
+				},
+			},
+		}
+		if err := server.Serve(context.Background()); err != nil {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func startTestPlugin(t *testing.T, kind Kind) (*Client, error) {
+	t.Helper()
+	t.Setenv("AARG_TEST_PLUGIN", "1")
+
+	executable, err := os.Executable()
+	require.NoError(t, err)
+
+	return Start(context.Background(), Command{Command: executable}, kind)
+}
+
+func TestClient_Call(t *testing.T) {
+	client, err := startTestPlugin(t, KindFeed)
+	require.NoError(t, err)
+	assert.Equal(t, "test", client.Name())
+
+	var result FetchResult
+	require.NoError(t, client.Call(context.Background(), MethodFetch, FetchParams{Location: "pkg"}, &result))
+	assert.Equal(t, []FetchedFile{{Path: "pkg.deb", Distribution: "stable", SHA256: "abc"}}, result.Files)
+
+	// Handler errors are returned as protocol errors
+	err = client.Call(context.Background(), MethodFetch, FetchParams{Location: "fail"}, &result)
+	var rpcErr *Error
+	require.ErrorAs(t, err, &rpcErr)
+	assert.Equal(t, CodeInternal, rpcErr.Code)
+
+	// Unknown methods are reported
+	err = client.Call(context.Background(), MethodPublish, PublishParams{}, nil)
+	require.ErrorAs(t, err, &rpcErr)
+	assert.Equal(t, CodeMethodNotFound, rpcErr.Code)
+
+	assert.NoError(t, client.Close())
+}
+
+func TestStart_KindMismatch(t *testing.T) {
+	pidFile := filepath.Join(t.TempDir(), "pid")
+	t.Setenv("AARG_TEST_PLUGIN_PIDFILE", pidFile)
+
+	_, err := startTestPlugin(t, KindProvider)
+	assert.ErrorIs(t, err, ErrKindMismatch)
+
+	// The rejected plugin is reaped instead of left behind as zombie
+	if _, err := os.Stat("/proc/self/stat"); err != nil {
+		t.Skip("no /proc to look up the plugin process")
+	}
+	pid, err := os.ReadFile(pidFile)
+	require.NoError(t, err)
+	_, err = os.Stat(filepath.Join("/proc", string(pid)))
+	assert.True(t, os.IsNotExist(err), "plugin process %s still exists", pid)
+}
+
+func TestStart_MissingCommand(t *testing.T) {
+	_, err := Start(context.Background(), Command{Command: "/nonexistent/plugin"}, KindFeed)
+	assert.Error(t, err)
+}
@@ -0,0 +1,120 @@
+package plugin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ProtocolVersion is the plugin protocol version spoken by this build
+// Plugins must answer the handshake with the same version
+const ProtocolVersion = 1
+
+// Kind is the extension point a plugin implements
+type Kind string
+
+// Plugin kinds
+const (
+	KindFeed     Kind = "feed"     // Custom package source fetching files into the downloads directory
+	KindProvider Kind = "provider" // Custom deployment target publishing the generated output
+)
+
+// Method names of the protocol
+const (
+	MethodHandshake = "handshake"
+	MethodShutdown  = "shutdown"
+	MethodFetch     = "fetch"   // Feed plugins
+	MethodPublish   = "publish" // Provider plugins
+)
+
+var (
+	ErrProtocolVersion = errors.New("unsupported plugin protocol version")
+	ErrKindMismatch    = errors.New("plugin does not implement requested kind")
+	ErrPluginExited    = errors.New("plugin exited")
+)
+
+// Request is a JSON-RPC 2.0 request, sent as a single line on the plugin's stdin
+type Request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      uint64          `json:"id"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// Response is a JSON-RPC 2.0 response, read as a single line from the plugin's stdout
+type Response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      uint64          `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+// Error is a JSON-RPC 2.0 error object
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// JSON-RPC 2.0 error codes
+const (
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeInternal       = -32603
+)
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("plugin error %d: %s", e.Code, e.Message)
+}
+
+// HandshakeParams is sent by the core when a plugin starts
+type HandshakeParams struct {
+	ProtocolVersion int  `json:"protocol_version"`
+	Kind            Kind `json:"kind"`
+}
+
+// HandshakeResult is returned by the plugin to confirm compatibility
+type HandshakeResult struct {
+	ProtocolVersion int    `json:"protocol_version"`
+	Name            string `json:"name"`
+	Kinds           []Kind `json:"kinds"`
+}
+
+// DistributionMapping maps a distribution of the plugin source to a target distribution
+type DistributionMapping struct {
+	Feed   string `json:"feed"`
+	Target string `json:"target"`
+}
+
+// FetchParams asks a feed plugin to place package files into DownloadDir
+type FetchParams struct {
+	Location      string                `json:"location"`
+	Settings      map[string]string     `json:"settings,omitempty"`
+	Distributions []DistributionMapping `json:"distributions,omitempty"`
+	DownloadDir   string                `json:"download_dir"`
+}
+
+// FetchedFile is a package file placed by a feed plugin
+type FetchedFile struct {
+	Path         string `json:"path"`         // Path relative to the download directory
+	Distribution string `json:"distribution"` // Target distribution
+	Source       string `json:"source"`       // Source package name used for grouping
+	SHA256       string `json:"sha256"`
+}
+
+// FetchResult lists all files a feed plugin provides
+type FetchResult struct {
+	Files []FetchedFile `json:"files"`
+}
+
+// PublishParams asks a provider plugin to publish the output directory
+type PublishParams struct {
+	OutputDir  string            `json:"output_dir"`
+	Project    string            `json:"project,omitempty"`
+	Production bool              `json:"production"`
+	Settings   map[string]string `json:"settings,omitempty"`
+}
+
+// PublishResult is returned by a provider plugin after publishing
+type PublishResult struct {
+	URL string `json:"url"` // Base URL where the published content is served
+}
@@ -0,0 +1,85 @@
+package plugin
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Handler handles a single request method in a plugin
+// params holds the raw JSON params, the returned value is encoded as the result
+type Handler func(ctx context.Context, params json.RawMessage) (any, error)
+
+// Server implements the plugin side of the protocol, for plugins written in Go
+type Server struct {
+	Name     string
+	Kinds    []Kind
+	Handlers map[string]Handler
+}
+
+// Serve answers requests on stdin/stdout until shutdown or end of input
+func (s *Server) Serve(ctx context.Context) error {
+	return s.ServeIO(ctx, os.Stdin, os.Stdout)
+}
+
+// ServeIO answers requests read from r and writes responses to w
+func (s *Server) ServeIO(ctx context.Context, r io.Reader, w io.Writer) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxMessageSize)
+	encoder := json.NewEncoder(w)
+
+	for scanner.Scan() {
+		var req Request
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			return fmt.Errorf("invalid request: %w", err)
+		}
+
+		resp := s.handle(ctx, &req)
+		if err := encoder.Encode(resp); err != nil {
+			return fmt.Errorf("failed to write response: %w", err)
+		}
+
+		if req.Method == MethodShutdown {
+			return nil
+		}
+	}
+
+	return scanner.Err()
+}
+
+// handle dispatches a request to the built-in methods or the registered handlers
+func (s *Server) handle(ctx context.Context, req *Request) *Response {
+	resp := &Response{JSONRPC: "2.0", ID: req.ID}
+
+	var result any
+	var err error
+	switch req.Method {
+	case MethodHandshake:
+		result = HandshakeResult{ProtocolVersion: ProtocolVersion, Name: s.Name, Kinds: s.Kinds}
+	case MethodShutdown:
+		result = struct{}{}
+	default:
+		handler, ok := s.Handlers[req.Method]
+		if !ok {
+			resp.Error = &Error{Code: CodeMethodNotFound, Message: "method not found: " + req.Method}
+			return resp
+		}
+		result, err = handler(ctx, req.Params)
+	}
+
+	if err != nil {
+		resp.Error = &Error{Code: CodeInternal, Message: err.Error()}
+		return resp
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		resp.Error = &Error{Code: CodeInternal, Message: err.Error()}
+		return resp
+	}
+	resp.Result = data
+	return resp
+}
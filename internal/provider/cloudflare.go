@@ -9,17 +9,25 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
+	"maps"
 	"mime"
 	"mime/multipart"
 	"net/http"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"strings"
 	"time"
 
+	"github.com/alitto/pond/v2"
+	"github.com/dionysius/aarg/internal/common"
+	"github.com/dionysius/aarg/internal/compose"
 	"github.com/dionysius/aarg/internal/config"
 	"github.com/dionysius/aarg/internal/feed"
+	"github.com/dionysius/aarg/internal/log"
 	"github.com/zeebo/blake3"
+	"golang.org/x/time/rate"
 )
 
 // PagesProvider implements the provider.Provider interface for Cloudflare Pages.
@@ -32,8 +40,22 @@ type PagesProvider struct {
 	cleanupConfig CloudflareCleanupConfig
 	repositories  []*config.RepositoryConfig
 	poolMode      string
+	target        CloudflareTarget
+	filter        func(relPath string) bool // Selects the files to upload, nil = all
+	limits        CloudflareLimits
+	limiter       *rate.Limiter        // Request budget shared by all providers of the API token, nil = unlimited
+	stateStore    common.MetadataStore // Store of the upload state resuming failed publishes, nil = not persisted
 }
 
+// CloudflareTarget selects the branch deployments are created for.
+type CloudflareTarget struct {
+	Branch     string // Branch of the deployment
+	Production bool   // Whether Branch is the production branch of the project
+}
+
+// branchAliasPattern matches characters Cloudflare replaces in preview branch aliases
+var branchAliasPattern = regexp.MustCompile(`[^a-z0-9-]+`)
+
 // CloudflareCleanupConfig contains deployment cleanup settings.
 // Cleanup is automatically enabled when OlderThanDays or KeepLast is set (> 0).
 type CloudflareCleanupConfig struct {
@@ -41,22 +63,52 @@ type CloudflareCleanupConfig struct {
 	KeepLast      int
 }
 
+// CloudflareLimits contains the API request budget of deployments.
+type CloudflareLimits struct {
+	Concurrency       int // Parallel check-missing and upload requests
+	RequestsPerMinute int // API requests per minute shared per API token (0 = unlimited)
+}
+
 // New creates a new Cloudflare Pages provider.
-func NewCloudflare(apiToken, accountID, projectName string, cleanup CloudflareCleanupConfig, repositories []*config.RepositoryConfig, poolMode string) (*PagesProvider, error) {
+// target selects the deployment branch and whether it is the production branch.
+func NewCloudflare(apiToken, accountID, projectName string, target CloudflareTarget, cleanup CloudflareCleanupConfig, limits CloudflareLimits, repositories []*config.RepositoryConfig, poolMode string) (*PagesProvider, error) {
+	if limits.Concurrency < 1 {
+		limits.Concurrency = 1
+	}
+
 	return &PagesProvider{
 		accountID:     accountID,
 		projectName:   projectName,
 		apiToken:      apiToken,
 		cleanupConfig: cleanup,
+		limits:        limits,
+		limiter:       tokenLimiter(apiToken, limits.RequestsPerMinute),
 		repositories:  repositories,
 		poolMode:      poolMode,
+		target:        target,
 		httpClient:    &http.Client{Timeout: 30 * time.Second},
 	}, nil
 }
 
+// SetFilter restricts the uploaded files to relative paths (with forward slashes) the filter accepts.
+// Used to split a build into several projects.
+func (p *PagesProvider) SetFilter(filter func(relPath string) bool) {
+	p.filter = filter
+}
+
+// SetStateStore persists the upload progress in store, so a failed publish resumes with the assets not uploaded yet.
+func (p *PagesProvider) SetStateStore(store common.MetadataStore) {
+	p.stateStore = store
+}
+
+// stateKey returns the key of the upload state of the project and branch.
+func (p *PagesProvider) stateKey() string {
+	return fmt.Sprintf("cloudflare-%s-%s", p.projectName, branchAlias(p.target.Branch))
+}
+
 // Publish uploads files to Cloudflare Pages using Direct Upload API.
 func (p *PagesProvider) Publish(ctx context.Context, outputDir string) error {
-	slog.Info("Starting Cloudflare Pages deployment", "project", p.projectName)
+	slog.Info("Starting Cloudflare Pages deployment", "project", p.projectName, "branch", p.target.Branch)
 
 	// Resolve symlink if outputDir is a symlink
 	resolvedDir, err := filepath.EvalSymlinks(outputDir)
@@ -89,11 +141,19 @@ func (p *PagesProvider) Publish(ctx context.Context, outputDir string) error {
 	if err != nil {
 		return fmt.Errorf("failed to check missing hashes: %w", err)
 	}
+
+	// Assets uploaded by a failed attempt are reported missing until a deployment references them
+	state := loadUploadState(p.stateStore, p.stateKey())
+	pendingHashes := state.pending(missingHashes)
+	if resumed := len(missingHashes) - len(pendingHashes); resumed > 0 {
+		slog.Info("Resuming upload of previous attempt", "uploaded", resumed, "since", common.FormatDisplayTime(state.Updated))
+	}
+	missingHashes = pendingHashes
 	slog.Info("Upload status", "total", len(fileHashes), "missing", len(missingHashes), "skipped", len(fileHashes)-len(missingHashes))
 
 	// Upload only missing files
 	if len(missingHashes) > 0 {
-		if err := p.uploadAssets(ctx, jwt, outputDir, files, manifest, missingHashes); err != nil {
+		if err := p.uploadAssets(ctx, jwt, outputDir, files, manifest, missingHashes, state); err != nil {
 			return fmt.Errorf("failed to upload assets: %w", err)
 		}
 	}
@@ -109,11 +169,14 @@ func (p *PagesProvider) Publish(ctx context.Context, outputDir string) error {
 	if err := p.waitForDeployment(ctx, deploymentID); err != nil {
 		return fmt.Errorf("deployment failed: %w", err)
 	}
+	if err := state.clear(); err != nil {
+		slog.Warn("Failed to clear upload state", "error", err)
+	}
 
 	slog.Info("Successfully deployed to Cloudflare Pages",
 		"project", p.projectName,
 		"preview_url", deploymentURL,
-		"production_url", p.GetURL())
+		"branch_url", p.GetURL())
 
 	// Cleanup old deployments if criteria are configured
 	if p.cleanupConfig.OlderThanDays > 0 || p.cleanupConfig.KeepLast > 0 {
@@ -126,11 +189,30 @@ func (p *PagesProvider) Publish(ctx context.Context, outputDir string) error {
 	return nil
 }
 
-// GetURL returns the production URL for the project.
+// Name returns the name of Cloudflare Pages in the publish configuration.
+func (p *PagesProvider) Name() string {
+	return config.ProviderCloudflare
+}
+
+// GetURL returns the URL of the configured branch for the project.
 func (p *PagesProvider) GetURL() string {
+	if !p.target.Production {
+		return fmt.Sprintf("https://%s.%s.pages.dev", branchAlias(p.target.Branch), p.projectName)
+	}
 	return fmt.Sprintf("https://%s.pages.dev", p.projectName)
 }
 
+// branchAlias returns the subdomain Cloudflare serves the latest preview deployment of a branch under.
+// Branch names are lowercased, other characters than letters, digits and dashes are replaced with dashes
+// and the alias is truncated to 28 characters.
+func branchAlias(branch string) string {
+	alias := branchAliasPattern.ReplaceAllString(strings.ToLower(branch), "-")
+	if len(alias) > 28 {
+		alias = alias[:28]
+	}
+	return strings.Trim(alias, "-")
+}
+
 // collectFiles scans the directory and returns list of files to upload.
 // Excludes _redirects and _headers as they need special handling.
 func (p *PagesProvider) collectFiles(outputDir string) ([]string, error) {
@@ -158,6 +240,10 @@ func (p *PagesProvider) collectFiles(outputDir string) ([]string, error) {
 			return nil
 		}
 
+		if p.filter != nil && !p.filter(relPath) {
+			return nil
+		}
+
 		files = append(files, relPath)
 		return nil
 	})
@@ -165,6 +251,11 @@ func (p *PagesProvider) collectFiles(outputDir string) ([]string, error) {
 	return files, err
 }
 
+// do sends an API request within the request budget of the API token
+func (p *PagesProvider) do(client *http.Client, req *http.Request) (*http.Response, error) {
+	return doThrottled(client, p.limiter, req)
+}
+
 // getUploadToken fetches a JWT token for uploading assets
 func (p *PagesProvider) getUploadToken(ctx context.Context) (string, error) {
 	url := fmt.Sprintf("https://api.cloudflare.com/client/v4/accounts/%s/pages/projects/%s/upload-token",
@@ -177,7 +268,7 @@ func (p *PagesProvider) getUploadToken(ctx context.Context) (string, error) {
 
 	req.Header.Set("Authorization", "Bearer "+p.apiToken)
 
-	resp, err := p.httpClient.Do(req)
+	resp, err := p.do(p.httpClient, req)
 	if err != nil {
 		return "", err
 	}
@@ -232,8 +323,44 @@ func (p *PagesProvider) buildManifest(outputDir string, files []string) (map[str
 	return manifest, hashes, nil
 }
 
+// checkMissingChunkSize is the number of hashes checked per request
+const checkMissingChunkSize = 1000
+
 // checkMissingHashes checks which file hashes need to be uploaded.
+// Hashes are checked in chunks with the configured concurrency.
 func (p *PagesProvider) checkMissingHashes(ctx context.Context, jwt string, hashes []string) ([]string, error) {
+	pool := pond.NewPool(p.limits.Concurrency)
+	defer pool.StopAndWait()
+
+	group := pool.NewGroup()
+
+	var chunks [][]string
+	for chunk := range slices.Chunk(hashes, checkMissingChunkSize) {
+		chunks = append(chunks, chunk)
+	}
+
+	results := make([][]string, len(chunks))
+	for i, chunk := range chunks {
+		group.SubmitErr(func() error {
+			missing, err := p.checkMissingChunk(ctx, jwt, chunk)
+			results[i] = missing
+			return err
+		})
+	}
+
+	if err := group.Wait(); err != nil {
+		return nil, err
+	}
+
+	var missing []string
+	for _, result := range results {
+		missing = append(missing, result...)
+	}
+	return missing, nil
+}
+
+// checkMissingChunk checks which hashes of a chunk need to be uploaded.
+func (p *PagesProvider) checkMissingChunk(ctx context.Context, jwt string, hashes []string) ([]string, error) {
 	url := "https://api.cloudflare.com/client/v4/pages/assets/check-missing"
 
 	payload := map[string][]string{
@@ -253,7 +380,7 @@ func (p *PagesProvider) checkMissingHashes(ctx context.Context, jwt string, hash
 	req.Header.Set("Authorization", "Bearer "+jwt)
 	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := p.httpClient.Do(req)
+	resp, err := p.do(p.httpClient, req)
 	if err != nil {
 		return nil, err
 	}
@@ -289,7 +416,8 @@ type uploadFile struct {
 }
 
 // uploadAssets uploads the actual file contents as base64-encoded JSON.
-func (p *PagesProvider) uploadAssets(ctx context.Context, jwt string, outputDir string, files []string, manifest map[string]string, missingHashes []string) error {
+// Every uploaded batch is recorded in state.
+func (p *PagesProvider) uploadAssets(ctx context.Context, jwt string, outputDir string, files []string, manifest map[string]string, missingHashes []string, state *uploadState) error {
 	// Create a set of missing hashes for quick lookup
 	missingSet := make(map[string]bool)
 	for _, hash := range missingHashes {
@@ -298,6 +426,7 @@ func (p *PagesProvider) uploadAssets(ctx context.Context, jwt string, outputDir
 
 	// Build upload payload
 	var uploads []uploadFile
+	var uploadedBytes int64
 
 	for _, relPath := range files {
 		manifestPath := "/" + strings.TrimPrefix(relPath, "/")
@@ -325,6 +454,7 @@ func (p *PagesProvider) uploadAssets(ctx context.Context, jwt string, outputDir
 			contentType = "application/octet-stream"
 		}
 
+		uploadedBytes += int64(len(fileData))
 		uploads = append(uploads, uploadFile{
 			Key:   hash,
 			Value: encoded,
@@ -342,9 +472,14 @@ func (p *PagesProvider) uploadAssets(ctx context.Context, jwt string, outputDir
 		return nil
 	}
 
-	// Upload in batches (Cloudflare has size limits)
+	// Upload in batches (Cloudflare has size limits) with the configured concurrency
 	const maxBatchSize = 50 * 1024 * 1024 // 50 MB
 
+	pool := pond.NewPool(p.limits.Concurrency)
+	defer pool.StopAndWait()
+
+	group := pool.NewGroup()
+
 	for i := 0; i < len(uploads); i++ {
 		batch := []uploadFile{uploads[i]}
 
@@ -359,12 +494,28 @@ func (p *PagesProvider) uploadAssets(ctx context.Context, jwt string, outputDir
 			i = j
 		}
 
-		if err := p.uploadBatch(ctx, jwt, batch); err != nil {
-			return err
-		}
+		group.SubmitErr(func() error {
+			if err := p.uploadBatch(ctx, jwt, batch); err != nil {
+				return err
+			}
+
+			hashes := make([]string, len(batch))
+			for i, file := range batch {
+				hashes[i] = file.Key
+			}
+			if err := state.record(hashes); err != nil {
+				slog.Warn("Failed to record upload state", "error", err)
+			}
+			return nil
+		})
+	}
+
+	if err := group.Wait(); err != nil {
+		return err
 	}
 
-	slog.Info("Uploaded files", "count", len(uploads))
+	log.CountUploaded(uploadedBytes)
+	slog.Info("Uploaded files", "count", len(uploads), "size", common.FormatSize(uint64(uploadedBytes)))
 	return nil
 }
 
@@ -385,8 +536,9 @@ func (p *PagesProvider) uploadBatch(ctx context.Context, jwt string, batch []upl
 	req.Header.Set("Authorization", "Bearer "+jwt)
 	req.Header.Set("Content-Type", "application/json")
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	// Large batches may take longer than the timeout of the API client
+	client := &http.Client{Transport: p.httpClient.Transport}
+	resp, err := p.do(client, req)
 	if err != nil {
 		return err
 	}
@@ -436,7 +588,7 @@ func (p *PagesProvider) createDeployment(ctx context.Context, outputDir string,
 	}
 
 	// Add branch field
-	if err := writer.WriteField("branch", "main"); err != nil {
+	if err := writer.WriteField("branch", p.target.Branch); err != nil {
 		return "", "", err
 	}
 
@@ -455,7 +607,7 @@ func (p *PagesProvider) createDeployment(ctx context.Context, outputDir string,
 
 	// Generate and add _redirects file if in redirect mode
 	if p.poolMode == "redirect" {
-		redirectsData, err := p.generateRedirects()
+		redirectsData, err := p.generateRedirects(outputDir)
 		if err != nil {
 			return "", "", fmt.Errorf("failed to generate redirects: %w", err)
 		}
@@ -483,7 +635,7 @@ func (p *PagesProvider) createDeployment(ctx context.Context, outputDir string,
 	req.Header.Set("Authorization", "Bearer "+p.apiToken)
 	req.Header.Set("Content-Type", writer.FormDataContentType())
 
-	resp, err := p.httpClient.Do(req)
+	resp, err := p.do(p.httpClient, req)
 	if err != nil {
 		return "", "", err
 	}
@@ -518,12 +670,15 @@ func (p *PagesProvider) createDeployment(ctx context.Context, outputDir string,
 	return result.Result.ID, result.Result.URL, nil
 }
 
+// deploymentPollInterval is the time between checks of the deployment status.
+var deploymentPollInterval = 3 * time.Second
+
 // waitForDeployment polls the deployment status until it's complete.
 func (p *PagesProvider) waitForDeployment(ctx context.Context, deploymentID string) error {
 	url := fmt.Sprintf("https://api.cloudflare.com/client/v4/accounts/%s/pages/projects/%s/deployments/%s",
 		p.accountID, p.projectName, deploymentID)
 
-	ticker := time.NewTicker(3 * time.Second)
+	ticker := time.NewTicker(deploymentPollInterval)
 	defer ticker.Stop()
 
 	timeout := time.After(5 * time.Minute)
@@ -542,7 +697,7 @@ func (p *PagesProvider) waitForDeployment(ctx context.Context, deploymentID stri
 
 			req.Header.Set("Authorization", "Bearer "+p.apiToken)
 
-			resp, err := p.httpClient.Do(req)
+			resp, err := p.do(p.httpClient, req)
 			if err != nil {
 				return err
 			}
@@ -632,7 +787,7 @@ func (p *PagesProvider) listDeployments(ctx context.Context) ([]deploymentInfo,
 
 	req.Header.Set("Authorization", "Bearer "+p.apiToken)
 
-	resp, err := p.httpClient.Do(req)
+	resp, err := p.do(p.httpClient, req)
 	if err != nil {
 		return nil, err
 	}
@@ -670,7 +825,7 @@ func (p *PagesProvider) deleteDeployment(ctx context.Context, deploymentID strin
 
 	req.Header.Set("Authorization", "Bearer "+p.apiToken)
 
-	resp, err := p.httpClient.Do(req)
+	resp, err := p.do(p.httpClient, req)
 	if err != nil {
 		return err
 	}
@@ -755,7 +910,7 @@ func (p *PagesProvider) cleanupOldDeployments(ctx context.Context) error {
 	deleted := 0
 	failed := 0
 	for _, dep := range toDelete {
-		slog.Debug("Deleting deployment", "id", dep.ID, "created", dep.CreatedOn.Format("2006-01-02"))
+		slog.Debug("Deleting deployment", "id", dep.ID, "created", common.InDisplayLocation(dep.CreatedOn).Format(time.DateOnly))
 
 		if err := p.deleteDeployment(ctx, dep.ID); err != nil {
 			slog.Warn("Failed to delete deployment", "id", dep.ID, "error", err)
@@ -776,9 +931,51 @@ func (p *PagesProvider) cleanupOldDeployments(ctx context.Context) error {
 
 // generateRedirects creates the _redirects file content for Cloudflare Pages.
 // Returns nil if no feeds requiring redirects are found.
-func (p *PagesProvider) generateRedirects() ([]byte, error) {
+// Wildcard rules are replaced by exact rules of the files in outputDir if they exceed the provider limits.
+func (p *PagesProvider) generateRedirects(outputDir string) ([]byte, error) {
+	groups := redirectGroups(p.repositories)
+	if len(groups) == 0 {
+		return nil, nil
+	}
+
+	// Exact rules need the files referenced by the indices of every repository
+	expandable := false
+	if dynamicRedirects(groups) > cloudflareRedirectLimits.Dynamic {
+		files := make(map[string][]string)
+		expandable = true
+		for _, repo := range p.repositories {
+			repository, err := compose.LoadRepositoryState(outputDir, repo.Name)
+			if err != nil {
+				slog.Warn("Cannot replace wildcard redirects by exact rules", "repository", repo.Name, "error", err)
+				expandable = false
+				break
+			}
+			files[repo.Name] = repositoryFiles(repository)
+		}
+		if expandable {
+			assignRedirectFiles(groups, files)
+		}
+	}
+
+	rules, err := planRedirects(groups, cloudflareRedirectLimits, expandable)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateRedirects(rules, cloudflareRedirectLimits); err != nil {
+		return nil, err
+	}
+
 	var buf bytes.Buffer
-	hasRedirects := false
+	for _, rule := range rules {
+		fmt.Fprintln(&buf, rule.String())
+	}
+
+	return buf.Bytes(), nil
+}
+
+// redirectGroups returns the wildcard redirect rules of the pools of all repositories in matching order.
+func redirectGroups(repositories []*config.RepositoryConfig) []*redirectGroup {
+	var groups []*redirectGroup
 
 	// Trust patterns: detect services that support user-scoped redirects
 	// This keeps redirect count low while maintaining trust boundaries
@@ -787,7 +984,7 @@ func (p *PagesProvider) generateRedirects() ([]byte, error) {
 	// 1. GitHub: pool/github.com/owner/repo/releases/download/...
 	// Per-owner redirects for trustworthiness
 	githubOwners := make(map[string]bool)
-	for _, repo := range p.repositories {
+	for _, repo := range repositories {
 		for _, feedOpts := range repo.Feeds {
 			if feed.FeedType(feedOpts.Type) == feed.FeedTypeGitHub {
 				parts := strings.Split(feedOpts.Name, "/")
@@ -799,15 +996,39 @@ func (p *PagesProvider) generateRedirects() ([]byte, error) {
 	}
 
 	if len(githubOwners) > 0 {
-		hasRedirects = true
-
 		// GitHub .dsc files redirect to dsc/ subdirectory
 		// .dsc files in dsc/ contain corrected filenames (GitHub normalizes ~ to .)
-		fmt.Fprintf(&buf, "/:aptrepo/pool/github.com/*.dsc /:aptrepo/dsc/github.com/:splat.dsc 301\n")
+		groups = append(groups, &redirectGroup{
+			wildcard: RedirectRule{From: "/:aptrepo/pool/github.com/*.dsc", To: "/:aptrepo/dsc/github.com/:splat.dsc", Status: 301},
+			target: func(repo, poolPath string) (string, bool) {
+				if !strings.HasPrefix(poolPath, "github.com/") || !strings.HasSuffix(poolPath, ".dsc") {
+					return "", false
+				}
+				return "/" + repo + "/dsc/" + poolPath, true
+			},
+		})
 
 		// Per-owner redirects for all other files
-		for owner := range githubOwners {
-			fmt.Fprintf(&buf, "/:aptrepo/pool/github.com/%s/:repo/* https://github.com/%s/:repo/releases/download/:splat 301\n", owner, owner)
+		for _, owner := range slices.Sorted(maps.Keys(githubOwners)) {
+			prefix := "github.com/" + owner + "/"
+			groups = append(groups, &redirectGroup{
+				wildcard: RedirectRule{
+					From:   fmt.Sprintf("/:aptrepo/pool/github.com/%s/:repo/*", owner),
+					To:     fmt.Sprintf("https://github.com/%s/:repo/releases/download/:splat", owner),
+					Status: 301,
+				},
+				target: func(_, poolPath string) (string, bool) {
+					rest, ok := strings.CutPrefix(poolPath, prefix)
+					if !ok {
+						return "", false
+					}
+					project, splat, ok := strings.Cut(rest, "/")
+					if !ok {
+						return "", false
+					}
+					return "https://github.com/" + owner + "/" + project + "/releases/download/" + splat, true
+				},
+			})
 		}
 	}
 
@@ -817,7 +1038,7 @@ func (p *PagesProvider) generateRedirects() ([]byte, error) {
 	// -> https://download.opensuse.org/repositories/home:/dionysius:/:splat
 	// Note: Colons NOT followed by letters are treated as literals (no escaping needed)
 	obsUsers := make(map[string]bool)
-	for _, repo := range p.repositories {
+	for _, repo := range repositories {
 		for _, feedOpts := range repo.Feeds {
 			if feed.FeedType(feedOpts.Type) == feed.FeedTypeOBS {
 				// Check if it's download.opensuse.org
@@ -835,10 +1056,10 @@ func (p *PagesProvider) generateRedirects() ([]byte, error) {
 		}
 	}
 
-	for user := range obsUsers {
+	for _, user := range slices.Sorted(maps.Keys(obsUsers)) {
 		// Colons followed by / or at end are literals, no escaping needed
-		fmt.Fprintf(&buf, "/:aptrepo/pool/download.opensuse.org/repositories/home:/%s:/* https://download.opensuse.org/repositories/home:/%s:/:splat 301\n", user, user)
-		hasRedirects = true
+		path := fmt.Sprintf("download.opensuse.org/repositories/home:/%s:/", user)
+		groups = append(groups, prefixRedirectGroup(path, "https://"+path))
 	}
 
 	// 3. APT and other feeds (per-domain redirects to minimize redirect count)
@@ -847,7 +1068,7 @@ func (p *PagesProvider) generateRedirects() ([]byte, error) {
 	// This creates ONE redirect per domain, regardless of how many repos from that domain
 	domains := make(map[string]bool)
 
-	for _, repo := range p.repositories {
+	for _, repo := range repositories {
 		for _, feedOpts := range repo.Feeds {
 			if feedOpts.DownloadURL == nil {
 				continue
@@ -869,15 +1090,24 @@ func (p *PagesProvider) generateRedirects() ([]byte, error) {
 		}
 	}
 
-	for domain := range domains {
+	for _, domain := range slices.Sorted(maps.Keys(domains)) {
 		// One redirect per domain - matches any path under that domain
-		fmt.Fprintf(&buf, "/:aptrepo/pool/%s/* https://%s/:splat 301\n", domain, domain)
-		hasRedirects = true
+		groups = append(groups, prefixRedirectGroup(domain+"/", "https://"+domain+"/"))
 	}
 
-	if !hasRedirects {
-		return nil, nil
-	}
+	return groups
+}
 
-	return buf.Bytes(), nil
+// prefixRedirectGroup redirects all pool paths below prefix to the same path below target.
+func prefixRedirectGroup(prefix, target string) *redirectGroup {
+	return &redirectGroup{
+		wildcard: RedirectRule{From: "/:aptrepo/pool/" + prefix + "*", To: target + ":splat", Status: 301},
+		target: func(_, poolPath string) (string, bool) {
+			splat, ok := strings.CutPrefix(poolPath, prefix)
+			if !ok {
+				return "", false
+			}
+			return target + splat, true
+		},
+	}
 }
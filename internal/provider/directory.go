@@ -0,0 +1,272 @@
+package provider
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"syscall"
+
+	"github.com/dionysius/aarg/internal/common"
+	"github.com/dionysius/aarg/internal/config"
+	"github.com/dionysius/aarg/internal/log"
+)
+
+// ErrDirectoryTarget is returned if the publish path exists but is not a symlink, it is never replaced.
+var ErrDirectoryTarget = errors.New("publish path exists and is not a symlink")
+
+// DirectoryProvider implements the provider.Provider interface by publishing into a local directory.
+// Every publish is placed into a new release directory next to the path and the path symlink is
+// swapped to it afterwards, so a web server serving the path never sees a partially copied build.
+type DirectoryProvider struct {
+	path        string
+	hardlink    bool
+	keep        int
+	crossDevice bool  // Hardlinking failed across filesystems, files are copied from then on
+	copied      int64 // Bytes copied by the last publish
+}
+
+// NewDirectory creates a provider publishing to the given symlink path.
+// With hardlink files are linked from the build instead of copied where possible,
+// keep is the number of previous releases kept for rollback.
+func NewDirectory(path string, hardlink bool, keep int) (*DirectoryProvider, error) {
+	if path == "" {
+		return nil, fmt.Errorf("no publish directory configured for this environment")
+	}
+
+	return &DirectoryProvider{
+		path:     filepath.Clean(path),
+		hardlink: hardlink,
+		keep:     keep,
+	}, nil
+}
+
+// Publish places the output directory as new release and swaps the path symlink to it
+func (d *DirectoryProvider) Publish(ctx context.Context, outputDir string) error {
+	resolvedDir, err := filepath.EvalSymlinks(outputDir)
+	if err != nil {
+		return fmt.Errorf("failed to resolve output directory: %w", err)
+	}
+
+	// The path is replaced on every publish, so it must be a symlink if it exists
+	var current string
+	info, err := os.Lstat(d.path)
+	switch {
+	case err == nil && info.Mode()&os.ModeSymlink == 0:
+		return fmt.Errorf("%w: %s", ErrDirectoryTarget, d.path)
+	case err == nil:
+		// A dangling symlink has no previous release to reuse files from
+		current, _ = filepath.EvalSymlinks(d.path)
+	case !errors.Is(err, fs.ErrNotExist):
+		return fmt.Errorf("failed to check publish path: %w", err)
+	}
+
+	release, err := d.newRelease(filepath.Base(resolvedDir))
+	if err != nil {
+		return fmt.Errorf("failed to create release directory: %w", err)
+	}
+
+	slog.Info("Publishing to directory", "path", d.path, "release", release, "hardlink", d.hardlink)
+
+	d.copied = 0
+	if err := d.copyTree(ctx, resolvedDir, release, current); err != nil {
+		_ = os.RemoveAll(release)
+		return fmt.Errorf("failed to place release: %w", err)
+	}
+	log.CountUploaded(d.copied)
+
+	if err := common.SwapSymlink(d.path, release); err != nil {
+		_ = os.RemoveAll(release)
+		return fmt.Errorf("failed to switch publish path: %w", err)
+	}
+
+	d.prune(release)
+
+	return nil
+}
+
+// GetURL returns the file URL of the publish path
+func (d *DirectoryProvider) GetURL() string {
+	return "file://" + d.path
+}
+
+// Name returns the name of the directory provider in the publish configuration
+func (d *DirectoryProvider) Name() string {
+	return config.ProviderDirectory
+}
+
+// releasesDir returns the directory holding the releases of the path
+func (d *DirectoryProvider) releasesDir() string {
+	return d.path + ".releases"
+}
+
+// newRelease creates an empty release directory named after the build
+// A build published again (e.g. after regenerating the web page) gets a numbered suffix
+func (d *DirectoryProvider) newRelease(name string) (string, error) {
+	if err := os.MkdirAll(d.releasesDir(), 0755); err != nil {
+		return "", err
+	}
+
+	release := filepath.Join(d.releasesDir(), name)
+	for i := 2; ; i++ {
+		err := os.Mkdir(release, 0755)
+		if err == nil {
+			return release, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return "", err
+		}
+		release = filepath.Join(d.releasesDir(), name+"."+strconv.Itoa(i))
+	}
+}
+
+// copyTree places all files of src into dst, previous is the same directory in the current release
+// Symlinks in the build (e.g. pool files pointing to downloads) are placed as their target
+func (d *DirectoryProvider) copyTree(ctx context.Context, src, dst, previous string) error {
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		srcPath := filepath.Join(src, entry.Name())
+		dstPath := filepath.Join(dst, entry.Name())
+		var prevPath string
+		if previous != "" {
+			prevPath = filepath.Join(previous, entry.Name())
+		}
+
+		info, err := os.Stat(srcPath)
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			if err := d.copyTree(ctx, srcPath, dstPath, prevPath); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// Hardlinking a symlink would link the symlink itself, not its target
+		if entry.Type()&fs.ModeSymlink != 0 {
+			if srcPath, err = filepath.EvalSymlinks(srcPath); err != nil {
+				return err
+			}
+		}
+
+		if err := d.placeFile(srcPath, dstPath, prevPath, info); err != nil {
+			return fmt.Errorf("%s: %w", srcPath, err)
+		}
+	}
+
+	return nil
+}
+
+// placeFile places a single file into the release
+// Files are hardlinked from the build if enabled, otherwise unchanged files are hardlinked from
+// the previous release like rsync --link-dest and only changed files are copied
+func (d *DirectoryProvider) placeFile(src, dst, previous string, info fs.FileInfo) error {
+	if d.hardlink && !d.crossDevice {
+		err := os.Link(src, dst)
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, syscall.EXDEV) {
+			return err
+		}
+		slog.Debug("Build and publish path are on different filesystems, copying files", "path", d.path)
+		d.crossDevice = true
+	}
+
+	if previous != "" {
+		prevInfo, err := os.Stat(previous)
+		if err == nil && prevInfo.Mode().IsRegular() && prevInfo.Size() == info.Size() && prevInfo.ModTime().Equal(info.ModTime()) {
+			if err := os.Link(previous, dst); err == nil {
+				return nil
+			}
+		}
+	}
+
+	return d.copyFile(src, dst, info)
+}
+
+// copyFile copies src to dst keeping permissions and modification time
+func (d *DirectoryProvider) copyFile(src, dst string, info fs.FileInfo) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = in.Close() }()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_EXCL|os.O_WRONLY, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+
+	n, err := io.Copy(out, in)
+	if err != nil {
+		_ = out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	d.copied += n
+
+	// Keeping the modification time lets the next publish link the file from this release
+	return os.Chtimes(dst, info.ModTime(), info.ModTime())
+}
+
+// prune removes the oldest releases besides the current one beyond the number to keep
+// Failures are only logged since the release is already published
+func (d *DirectoryProvider) prune(current string) {
+	entries, err := os.ReadDir(d.releasesDir())
+	if err != nil {
+		slog.Warn("Failed to list releases", "path", d.releasesDir(), "error", err)
+		return
+	}
+
+	type release struct {
+		path    string
+		modTime int64
+	}
+	var releases []release
+	for _, entry := range entries {
+		path := filepath.Join(d.releasesDir(), entry.Name())
+		if !entry.IsDir() || path == current {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		releases = append(releases, release{path: path, modTime: info.ModTime().UnixNano()})
+	}
+
+	// Newest first
+	slices.SortFunc(releases, func(a, b release) int {
+		return cmp.Compare(b.modTime, a.modTime)
+	})
+
+	for i := d.keep; i < len(releases); i++ {
+		slog.Debug("Removing old release", "release", releases[i].path)
+		if err := os.RemoveAll(releases[i].path); err != nil {
+			slog.Warn("Failed to remove old release", "release", releases[i].path, "error", err)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package provider
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeBuild creates a build directory with the given files relative to it
+func writeBuild(t *testing.T, dir string, files map[string]string) string {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+	return dir
+}
+
+// sameFile reports whether both paths are hardlinks of the same file
+func sameFile(t *testing.T, a, b string) bool {
+	t.Helper()
+	infoA, err := os.Stat(a)
+	require.NoError(t, err)
+	infoB, err := os.Stat(b)
+	require.NoError(t, err)
+	return os.SameFile(infoA, infoB)
+}
+
+func TestDirectoryProvider_Publish(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "www")
+	build := writeBuild(t, filepath.Join(dir, "staging", "20250101-120000"), map[string]string{
+		"index.html":                "index",
+		"dists/stable/Release":      "release",
+		"pool/main/h/hello/a_1.deb": "deb",
+	})
+
+	d, err := NewDirectory(path, false, 2)
+	require.NoError(t, err)
+	require.NoError(t, d.Publish(context.Background(), build))
+
+	target, err := os.Readlink(path)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "www.releases", "20250101-120000"), target)
+
+	data, err := os.ReadFile(filepath.Join(path, "dists", "stable", "Release"))
+	require.NoError(t, err)
+	assert.Equal(t, "release", string(data))
+
+	// Without hardlink files are copied, the build can be removed
+	assert.False(t, sameFile(t, filepath.Join(build, "index.html"), filepath.Join(path, "index.html")))
+	assert.Equal(t, int64(len("index")+len("release")+len("deb")), d.copied)
+}
+
+func TestDirectoryProvider_Publish_Numbering(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "www")
+	build := writeBuild(t, filepath.Join(dir, "staging", "20250101-120000"), map[string]string{
+		"unchanged": "same",
+		"changed":   "first",
+	})
+
+	d, err := NewDirectory(path, false, 5)
+	require.NoError(t, err)
+	require.NoError(t, d.Publish(context.Background(), build))
+	first, err := os.Readlink(path)
+	require.NoError(t, err)
+
+	// A rewritten file gets another modification time than in the previous release
+	require.NoError(t, os.WriteFile(filepath.Join(build, "changed"), []byte("other"), 0644))
+	later := time.Now().Add(time.Hour)
+	require.NoError(t, os.Chtimes(filepath.Join(build, "changed"), later, later))
+
+	// The same build published again gets a numbered release
+	require.NoError(t, d.Publish(context.Background(), build))
+	second, err := os.Readlink(path)
+	require.NoError(t, err)
+	assert.Equal(t, first+".2", second)
+
+	// Unchanged files are linked from the previous release like rsync --link-dest, changed ones copied
+	assert.True(t, sameFile(t, filepath.Join(first, "unchanged"), filepath.Join(second, "unchanged")))
+	assert.False(t, sameFile(t, filepath.Join(first, "changed"), filepath.Join(second, "changed")))
+	assert.Equal(t, int64(len("other")), d.copied)
+
+	data, err := os.ReadFile(filepath.Join(path, "changed"))
+	require.NoError(t, err)
+	assert.Equal(t, "other", string(data))
+
+	require.NoError(t, d.Publish(context.Background(), build))
+	third, err := os.Readlink(path)
+	require.NoError(t, err)
+	assert.Equal(t, first+".3", third)
+}
+
+func TestDirectoryProvider_Publish_Hardlink(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "www")
+	build := writeBuild(t, filepath.Join(dir, "staging", "build"), map[string]string{"file": "content"})
+
+	// Symlinks of the build, like pool files pointing to trusted storage, are placed as their target
+	trusted := writeBuild(t, filepath.Join(dir, "trusted"), map[string]string{"a_1.deb": "deb"})
+	require.NoError(t, os.Symlink(filepath.Join(trusted, "a_1.deb"), filepath.Join(build, "a_1.deb")))
+
+	d, err := NewDirectory(path, true, 1)
+	require.NoError(t, err)
+	require.NoError(t, d.Publish(context.Background(), build))
+
+	assert.True(t, sameFile(t, filepath.Join(build, "file"), filepath.Join(path, "file")))
+	info, err := os.Lstat(filepath.Join(path, "a_1.deb"))
+	require.NoError(t, err)
+	assert.True(t, info.Mode().IsRegular())
+	assert.True(t, sameFile(t, filepath.Join(trusted, "a_1.deb"), filepath.Join(path, "a_1.deb")))
+	assert.Zero(t, d.copied)
+	assert.False(t, d.crossDevice)
+}
+
+func TestDirectoryProvider_Publish_CrossDevice(t *testing.T) {
+	dir := t.TempDir()
+	build := writeBuild(t, filepath.Join(dir, "staging", "build"), map[string]string{"a": "first", "b": "second"})
+
+	// Publishing to another filesystem needs one, /dev/shm is a tmpfs on most Linux systems
+	other, err := os.MkdirTemp("/dev/shm", "aarg-test-")
+	if err != nil {
+		t.Skip("no other filesystem available")
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(other) })
+	var buildStat, otherStat syscall.Stat_t
+	require.NoError(t, syscall.Stat(build, &buildStat))
+	require.NoError(t, syscall.Stat(other, &otherStat))
+	if buildStat.Dev == otherStat.Dev {
+		t.Skip("/dev/shm is on the same filesystem")
+	}
+
+	d, err := NewDirectory(filepath.Join(other, "www"), true, 1)
+	require.NoError(t, err)
+	require.NoError(t, d.Publish(context.Background(), build))
+
+	// Hardlinking failed with EXDEV once, all files are copied
+	assert.True(t, d.crossDevice)
+	assert.Equal(t, int64(len("first")+len("second")), d.copied)
+	data, err := os.ReadFile(filepath.Join(other, "www", "b"))
+	require.NoError(t, err)
+	assert.Equal(t, "second", string(data))
+}
+
+func TestDirectoryProvider_Publish_Target(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(t *testing.T, path string)
+		wantErr error
+	}{
+		{name: "missing", setup: func(t *testing.T, path string) {}},
+		{name: "dangling symlink", setup: func(t *testing.T, path string) {
+			require.NoError(t, os.Symlink(path+".gone", path))
+		}},
+		{name: "directory", setup: func(t *testing.T, path string) {
+			require.NoError(t, os.Mkdir(path, 0755))
+		}, wantErr: ErrDirectoryTarget},
+		{name: "file", setup: func(t *testing.T, path string) {
+			require.NoError(t, os.WriteFile(path, nil, 0644))
+		}, wantErr: ErrDirectoryTarget},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "www")
+			build := writeBuild(t, filepath.Join(dir, "staging", "build"), map[string]string{"file": "content"})
+			tt.setup(t, path)
+
+			d, err := NewDirectory(path, false, 1)
+			require.NoError(t, err)
+			err = d.Publish(context.Background(), build)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				// Nothing is placed for a refused target
+				assert.NoDirExists(t, d.releasesDir())
+				return
+			}
+			require.NoError(t, err)
+			assert.FileExists(t, filepath.Join(path, "file"))
+		})
+	}
+}
+
+func TestDirectoryProvider_Prune(t *testing.T) {
+	dir := t.TempDir()
+	d, err := NewDirectory(filepath.Join(dir, "www"), false, 2)
+	require.NoError(t, err)
+
+	// Releases with ascending modification times, names deliberately not in that order
+	now := time.Now()
+	names := []string{"c", "a", "d", "b", "current"}
+	for i, name := range names {
+		release := filepath.Join(d.releasesDir(), name)
+		require.NoError(t, os.MkdirAll(release, 0755))
+		modTime := now.Add(time.Duration(i) * time.Minute)
+		require.NoError(t, os.Chtimes(release, modTime, modTime))
+	}
+	// Files next to the releases are not releases
+	require.NoError(t, os.WriteFile(filepath.Join(d.releasesDir(), "notes"), nil, 0644))
+
+	d.prune(filepath.Join(d.releasesDir(), "current"))
+
+	entries, err := os.ReadDir(d.releasesDir())
+	require.NoError(t, err)
+	var remaining []string
+	for _, entry := range entries {
+		remaining = append(remaining, entry.Name())
+	}
+	// The current release and the 2 newest others are kept
+	assert.ElementsMatch(t, []string{"current", "d", "b", "notes"}, remaining)
+}
+
+func TestNewDirectory(t *testing.T) {
+	_, err := NewDirectory("", false, 1)
+	assert.Error(t, err)
+
+	d, err := NewDirectory("/srv/www/", false, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "/srv/www", d.path)
+	assert.Equal(t, "/srv/www.releases", d.releasesDir())
+	assert.Equal(t, "file:///srv/www", d.GetURL())
+}
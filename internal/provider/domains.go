@@ -0,0 +1,192 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// DomainStatus is the state of a custom domain at the provider.
+type DomainStatus struct {
+	Name    string // Domain name
+	Target  string // Host the domain has to point at
+	Status  string // Provider status, "active" once the domain is served
+	Message string // Verification error reported by the provider, if any
+	Created bool   // Whether the domain was added by this call
+}
+
+// Active reports whether the provider serves the domain.
+func (s *DomainStatus) Active() bool {
+	return s.Status == "active"
+}
+
+// pagesDomain is a custom domain of a Pages project as returned by the API.
+type pagesDomain struct {
+	Name             string `json:"name"`
+	Status           string `json:"status"`
+	VerificationData struct {
+		Status       string `json:"status"`
+		ErrorMessage string `json:"error_message"`
+	} `json:"verification_data"`
+	ValidationData struct {
+		Status       string `json:"status"`
+		ErrorMessage string `json:"error_message"`
+	} `json:"validation_data"`
+}
+
+// EnsureDomain adds the custom domain to the project if missing and returns its status.
+func (p *PagesProvider) EnsureDomain(ctx context.Context, domain string) (*DomainStatus, error) {
+	domains, err := p.listDomains(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	created := false
+	idx := slices.IndexFunc(domains, func(d pagesDomain) bool { return strings.EqualFold(d.Name, domain) })
+	var current pagesDomain
+	if idx >= 0 {
+		current = domains[idx]
+	} else {
+		added, err := p.addDomain(ctx, domain)
+		if err != nil {
+			return nil, err
+		}
+		current = *added
+		created = true
+	}
+
+	status := &DomainStatus{
+		Name:    current.Name,
+		Target:  p.projectName + ".pages.dev",
+		Status:  current.Status,
+		Created: created,
+	}
+	switch {
+	case current.VerificationData.ErrorMessage != "":
+		status.Message = current.VerificationData.ErrorMessage
+	case current.ValidationData.ErrorMessage != "":
+		status.Message = current.ValidationData.ErrorMessage
+	}
+
+	return status, nil
+}
+
+// listDomains fetches the custom domains of the project.
+func (p *PagesProvider) listDomains(ctx context.Context) ([]pagesDomain, error) {
+	url := fmt.Sprintf("https://api.cloudflare.com/client/v4/accounts/%s/pages/projects/%s/domains",
+		p.accountID, p.projectName)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+p.apiToken)
+
+	resp, err := p.do(p.httpClient, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to list domains: %s - %s", resp.Status, string(body))
+	}
+
+	var result struct {
+		Result []pagesDomain `json:"result"`
+	}
+
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return result.Result, nil
+}
+
+// addDomain adds a custom domain to the project.
+func (p *PagesProvider) addDomain(ctx context.Context, domain string) (*pagesDomain, error) {
+	url := fmt.Sprintf("https://api.cloudflare.com/client/v4/accounts/%s/pages/projects/%s/domains",
+		p.accountID, p.projectName)
+
+	payload, err := json.Marshal(map[string]string{"name": domain})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+p.apiToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.do(p.httpClient, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to add domain %s: %s - %s", domain, resp.Status, string(body))
+	}
+
+	var result struct {
+		Result pagesDomain `json:"result"`
+	}
+
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return &result.Result, nil
+}
+
+// CheckDomainDNS verifies that domain resolves to target.
+// A CNAME to target is accepted, otherwise both hosts must share an address,
+// as proxied or flattened records don't expose the CNAME.
+func CheckDomainDNS(ctx context.Context, domain, target string) error {
+	resolver := net.DefaultResolver
+
+	cname, err := resolver.LookupCNAME(ctx, domain)
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", domain, err)
+	}
+	if strings.EqualFold(strings.TrimSuffix(cname, "."), target) {
+		return nil
+	}
+
+	domainAddrs, err := resolver.LookupHost(ctx, domain)
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", domain, err)
+	}
+	targetAddrs, err := resolver.LookupHost(ctx, target)
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", target, err)
+	}
+
+	for _, addr := range domainAddrs {
+		if slices.Contains(targetAddrs, addr) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%s resolves to %v, expected a CNAME to %s", domain, domainAddrs, target)
+}
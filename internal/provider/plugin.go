@@ -0,0 +1,74 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+
+	"github.com/dionysius/aarg/internal/plugin"
+)
+
+// PluginProvider implements the provider.Provider interface through an external provider plugin.
+// The plugin is started for each publish and stopped afterwards.
+type PluginProvider struct {
+	name       string
+	command    plugin.Command
+	project    string
+	production bool
+	url        string
+}
+
+// NewPlugin creates a provider publishing through the plugin command configured under name
+func NewPlugin(name string, command plugin.Command, project string, production bool) (*PluginProvider, error) {
+	return &PluginProvider{
+		name:       name,
+		command:    command,
+		project:    project,
+		production: production,
+	}, nil
+}
+
+// Publish hands the output directory to the plugin
+func (p *PluginProvider) Publish(ctx context.Context, outputDir string) error {
+	resolvedDir, err := filepath.EvalSymlinks(outputDir)
+	if err != nil {
+		return fmt.Errorf("failed to resolve output directory: %w", err)
+	}
+
+	client, err := plugin.Start(ctx, p.command, plugin.KindProvider)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := client.Close(); err != nil {
+			slog.Warn("Failed to stop plugin", "plugin", client.Name(), "error", err)
+		}
+	}()
+
+	slog.Info("Publishing through plugin", "plugin", client.Name(), "project", p.project, "production", p.production)
+
+	var result plugin.PublishResult
+	params := plugin.PublishParams{
+		OutputDir:  resolvedDir,
+		Project:    p.project,
+		Production: p.production,
+		Settings:   p.command.Settings,
+	}
+	if err := client.Call(ctx, plugin.MethodPublish, params, &result); err != nil {
+		return fmt.Errorf("plugin %s publish failed: %w", client.Name(), err)
+	}
+
+	p.url = result.URL
+	return nil
+}
+
+// GetURL returns the URL reported by the plugin for the last publish
+func (p *PluginProvider) GetURL() string {
+	return p.url
+}
+
+// Name returns the name the plugin is configured under
+func (p *PluginProvider) Name() string {
+	return p.name
+}
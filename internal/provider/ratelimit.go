@@ -0,0 +1,108 @@
+package provider
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	// maxRateLimitRetries is how often a request answered with 429 is retried
+	maxRateLimitRetries = 5
+	// defaultRetryAfter is the wait before retrying a 429 response without Retry-After header
+	defaultRetryAfter = 30 * time.Second
+)
+
+var (
+	// limiters share the request budget of an API token between all providers using it
+	limiters   = make(map[string]*rate.Limiter)
+	limitersMu sync.Mutex
+)
+
+// tokenLimiter returns the request limiter of an API token, nil if requestsPerMinute is not positive
+// The limit of the first caller applies to all providers with the same token
+func tokenLimiter(apiToken string, requestsPerMinute int) *rate.Limiter {
+	if requestsPerMinute <= 0 {
+		return nil
+	}
+
+	limitersMu.Lock()
+	defer limitersMu.Unlock()
+
+	limiter, ok := limiters[apiToken]
+	if !ok {
+		// Allow short bursts, e.g. the parallel requests of one upload round
+		burst := max(1, requestsPerMinute/20)
+		limiter = rate.NewLimiter(rate.Limit(float64(requestsPerMinute)/60), burst)
+		limiters[apiToken] = limiter
+	}
+	return limiter
+}
+
+// doThrottled sends a request within the request budget of limiter
+// Responses with status 429 are retried after the time given by the Retry-After header
+// Requests with a body must be replayable through GetBody, which http.NewRequest sets for in-memory bodies
+func doThrottled(client *http.Client, limiter *rate.Limiter, req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		if limiter != nil {
+			if err := limiter.Wait(req.Context()); err != nil {
+				return nil, err
+			}
+		}
+
+		resp, err := client.Do(req)
+		if err != nil || resp.StatusCode != http.StatusTooManyRequests || attempt >= maxRateLimitRetries || (req.Body != nil && req.GetBody == nil) {
+			return resp, err
+		}
+		_ = resp.Body.Close()
+
+		wait := retryAfter(resp.Header.Get("Retry-After"))
+		slog.Warn("Cloudflare API rate limit reached, retrying", "url", req.URL.Path, "wait", wait, "attempt", attempt+1)
+
+		if err := sleepContext(req.Context(), wait); err != nil {
+			return nil, err
+		}
+
+		// Replay the request body for the next attempt
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+}
+
+// retryAfter parses a Retry-After header given in seconds or as HTTP date
+func retryAfter(header string) time.Duration {
+	if seconds, err := strconv.Atoi(header); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(header); err == nil {
+		if wait := time.Until(date); wait > 0 {
+			return wait
+		}
+		return 0
+	}
+	return defaultRetryAfter
+}
+
+// sleepContext waits for the duration or until the context is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,372 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/dionysius/aarg/debext"
+	"github.com/dionysius/aarg/internal/config"
+)
+
+var (
+	// ErrRedirectLimit is returned when the redirect rules of a build cannot fit the provider limits.
+	ErrRedirectLimit = errors.New("redirect rules exceed provider limits")
+	// ErrRedirectInvalid is returned when generated redirect rules would not work as intended.
+	ErrRedirectInvalid = errors.New("invalid redirect rules")
+)
+
+// RedirectLimits are the constraints a provider puts on redirect rules.
+// Static rules match a single path, dynamic rules contain splats or placeholders.
+type RedirectLimits struct {
+	Static     int // Maximum number of static rules
+	Dynamic    int // Maximum number of dynamic rules
+	LineLength int // Maximum length of a rule line
+}
+
+// cloudflareRedirectLimits are the _redirects limits of Cloudflare Pages.
+var cloudflareRedirectLimits = RedirectLimits{Static: 2000, Dynamic: 100, LineLength: 1000}
+
+// maxRedirectProblems limits the problems listed in validation errors.
+const maxRedirectProblems = 10
+
+// placeholderPattern matches named placeholders, colons not followed by a letter are literals.
+var placeholderPattern = regexp.MustCompile(`:[A-Za-z]\w*`)
+
+// redirectStatuses are the status codes allowed for redirects.
+var redirectStatuses = []int{301, 302, 303, 307, 308}
+
+// RedirectRule is a single line of a _redirects file.
+type RedirectRule struct {
+	From   string
+	To     string
+	Status int
+
+	pattern *regexp.Regexp // Compiled From, nil = compiled on use
+}
+
+// Dynamic reports whether the rule matches more than one path.
+func (r RedirectRule) Dynamic() bool {
+	return strings.Contains(r.From, "*") || placeholderPattern.MatchString(r.From)
+}
+
+// String formats the rule as _redirects line.
+func (r RedirectRule) String() string {
+	return fmt.Sprintf("%s %s %d", r.From, r.To, r.Status)
+}
+
+// Resolve returns the target of the rule for a path, false if the rule doesn't match it.
+// Placeholders and :splat in the target are replaced by the path segments they matched.
+func (r RedirectRule) Resolve(path string) (string, bool) {
+	pattern := r.pattern
+	if pattern == nil {
+		pattern = redirectPattern(r.From)
+	}
+	match := pattern.FindStringSubmatch(path)
+	if match == nil {
+		return "", false
+	}
+
+	values := make(map[string]string)
+	for i, name := range pattern.SubexpNames() {
+		if name != "" {
+			values[":"+name] = match[i]
+		}
+	}
+	return placeholderPattern.ReplaceAllStringFunc(r.To, func(name string) string {
+		if value, ok := values[name]; ok {
+			return value
+		}
+		return name
+	}), true
+}
+
+// RedirectRules returns the wildcard redirect rules of the pools of repositories in matching order.
+// These are the rules published to Cloudflare Pages unless they exceed its limits.
+// The sources are compiled once, so resolving paths with the rules is cheap.
+func RedirectRules(repositories []*config.RepositoryConfig) []RedirectRule {
+	groups := redirectGroups(repositories)
+	rules := make([]RedirectRule, 0, len(groups))
+	for _, group := range groups {
+		rule := group.wildcard
+		rule.pattern = redirectPattern(rule.From)
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// redirectGroup is a wildcard rule together with the exact rules of the files of a build it covers.
+// Groups are replaced by their exact rules when the wildcards exceed the dynamic rule limit.
+type redirectGroup struct {
+	wildcard RedirectRule
+	exact    []RedirectRule
+	// target returns the exact redirect target of a path below pool/ of a repository, false if not covered
+	target func(repo, poolPath string) (string, bool)
+}
+
+// assignRedirectFiles adds an exact rule for each pool file to the first group covering it.
+// files maps repository names to the paths referenced by their indices.
+func assignRedirectFiles(groups []*redirectGroup, files map[string][]string) {
+	repos := make([]string, 0, len(files))
+	for repo := range files {
+		repos = append(repos, repo)
+	}
+	slices.Sort(repos)
+
+	for _, repo := range repos {
+		for _, path := range files[repo] {
+			poolPath, ok := strings.CutPrefix(path, "pool/")
+			if !ok {
+				continue
+			}
+			for _, group := range groups {
+				if to, ok := group.target(repo, poolPath); ok {
+					group.exact = append(group.exact, RedirectRule{From: "/" + repo + "/" + path, To: to, Status: group.wildcard.Status})
+					break
+				}
+			}
+		}
+	}
+}
+
+// planRedirects selects the most compact rule set within limits.
+// Wildcards are kept while they fit, otherwise the groups with the fewest files are replaced
+// by exact rules until the dynamic rules fit. Without known files (expandable false) groups
+// cannot be replaced and the wildcards are returned as they are.
+func planRedirects(groups []*redirectGroup, limits RedirectLimits, expandable bool) ([]RedirectRule, error) {
+	dynamic := dynamicRedirects(groups)
+
+	expanded := make(map[*redirectGroup]bool)
+	static := 0
+	if dynamic > limits.Dynamic {
+		if !expandable {
+			return nil, fmt.Errorf("%w: %d dynamic rules, %d allowed", ErrRedirectLimit, dynamic, limits.Dynamic)
+		}
+
+		// Replacing the smallest groups first replaces the most wildcards for the static budget
+		candidates := slices.Clone(groups)
+		slices.SortStableFunc(candidates, func(a, b *redirectGroup) int {
+			return len(a.exact) - len(b.exact)
+		})
+		for _, group := range candidates {
+			if dynamic <= limits.Dynamic {
+				break
+			}
+			if !group.wildcard.Dynamic() || static+len(group.exact) > limits.Static {
+				continue
+			}
+			expanded[group] = true
+			static += len(group.exact)
+			dynamic--
+		}
+
+		if dynamic > limits.Dynamic {
+			return nil, fmt.Errorf("%w: %d dynamic rules with %d static rules, %d and %d allowed",
+				ErrRedirectLimit, dynamic, static, limits.Dynamic, limits.Static)
+		}
+	}
+
+	// Static rules first, they are more specific than wildcards
+	var rules, wildcards []RedirectRule
+	for _, group := range groups {
+		if expanded[group] {
+			rules = append(rules, group.exact...)
+		} else {
+			wildcards = append(wildcards, group.wildcard)
+		}
+	}
+
+	return append(rules, wildcards...), nil
+}
+
+// validateRedirects checks rules against the provider limits and the _redirects syntax and
+// detects rules never reached because an earlier rule matches the same paths.
+// All problems are reported in one error instead of deploying redirects that silently stop working.
+func validateRedirects(rules []RedirectRule, limits RedirectLimits) error {
+	var problems []string
+	static, dynamic := 0, 0
+
+	for i, rule := range rules {
+		line := rule.String()
+		if rule.Dynamic() {
+			dynamic++
+		} else {
+			static++
+		}
+
+		if len(line) > limits.LineLength {
+			problems = append(problems, fmt.Sprintf("line %d is %d characters long, %d allowed: %s", i+1, len(line), limits.LineLength, truncate(line, 80)))
+		}
+		if err := checkRedirectSyntax(rule); err != nil {
+			problems = append(problems, fmt.Sprintf("line %d %v: %s", i+1, err, line))
+		}
+	}
+
+	if static > limits.Static {
+		problems = append(problems, fmt.Sprintf("%d static rules, %d allowed", static, limits.Static))
+	}
+	if dynamic > limits.Dynamic {
+		problems = append(problems, fmt.Sprintf("%d dynamic rules, %d allowed", dynamic, limits.Dynamic))
+	}
+
+	problems = append(problems, redirectConflicts(rules)...)
+
+	if len(problems) == 0 {
+		return nil
+	}
+	if len(problems) > maxRedirectProblems {
+		problems = append(problems[:maxRedirectProblems], fmt.Sprintf("and %d more", len(problems)-maxRedirectProblems))
+	}
+	return fmt.Errorf("%w:\n  %s", ErrRedirectInvalid, strings.Join(problems, "\n  "))
+}
+
+// checkRedirectSyntax checks a single rule for mistakes the provider would ignore or reject.
+func checkRedirectSyntax(rule RedirectRule) error {
+	if !strings.HasPrefix(rule.From, "/") {
+		return errors.New("source must start with /")
+	}
+	if strings.ContainsAny(rule.From+rule.To, " \t") {
+		return errors.New("contains whitespace")
+	}
+	if strings.Count(rule.From, "*") > 1 {
+		return errors.New("source has more than one splat")
+	}
+	if !slices.Contains(redirectStatuses, rule.Status) {
+		return fmt.Errorf("has unsupported status %d", rule.Status)
+	}
+	if !strings.HasPrefix(rule.To, "/") && !strings.HasPrefix(rule.To, "https://") && !strings.HasPrefix(rule.To, "http://") {
+		return errors.New("target must be a path or URL")
+	}
+
+	// Every placeholder of the target must be captured by the source
+	defined := make(map[string]bool)
+	for _, name := range placeholderPattern.FindAllString(rule.From, -1) {
+		if defined[name] {
+			return fmt.Errorf("source defines %s twice", name)
+		}
+		defined[name] = true
+	}
+	if strings.Contains(rule.From, "*") {
+		defined[":splat"] = true
+	}
+	target := rule.To
+	if scheme, rest, ok := strings.Cut(target, "://"); ok {
+		target = scheme + rest // The scheme colon is no placeholder
+	}
+	for _, name := range placeholderPattern.FindAllString(target, -1) {
+		if !defined[name] {
+			return fmt.Errorf("target uses %s not captured by the source", name)
+		}
+	}
+
+	return nil
+}
+
+// redirectConflicts returns rules with the same source as an earlier rule or only matching paths an earlier rule already matches.
+func redirectConflicts(rules []RedirectRule) []string {
+	var problems []string
+	patterns := make([]*regexp.Regexp, len(rules))
+	sources := make(map[string]int)
+
+	for i, rule := range rules {
+		patterns[i] = redirectPattern(rule.From)
+
+		if j, ok := sources[rule.From]; ok {
+			if rules[j].To != rule.To {
+				problems = append(problems, fmt.Sprintf("line %d conflicts with line %d, %s redirects to both %s and %s", i+1, j+1, rule.From, rules[j].To, rule.To))
+			} else {
+				problems = append(problems, fmt.Sprintf("line %d duplicates line %d: %s", i+1, j+1, rule.From))
+			}
+			continue
+		}
+		sources[rule.From] = i
+
+		// A path matched by this rule, placeholders replaced by sample segments
+		sample := placeholderPattern.ReplaceAllString(strings.ReplaceAll(rule.From, "*", "x"), "x")
+		for j := range i {
+			if rules[j].Dynamic() && patterns[j].MatchString(sample) {
+				problems = append(problems, fmt.Sprintf("line %d is shadowed by line %d, %s already matches the paths of %s", i+1, j+1, rules[j].From, rule.From))
+				break
+			}
+		}
+	}
+
+	return problems
+}
+
+// redirectPattern converts a rule source into a regular expression matching the same paths.
+// Placeholders are captured by their name, the splat as splat.
+func redirectPattern(from string) *regexp.Regexp {
+	var pattern strings.Builder
+	pattern.WriteString("^")
+	for len(from) > 0 {
+		if loc := placeholderPattern.FindStringIndex(from); loc != nil && loc[0] == 0 {
+			pattern.WriteString("(?P<" + from[1:loc[1]] + ">[^/]+)")
+			from = from[loc[1]:]
+			continue
+		}
+		if from[0] == '*' {
+			pattern.WriteString("(?P<splat>.*)")
+			from = from[1:]
+			continue
+		}
+		pattern.WriteString(regexp.QuoteMeta(from[:1]))
+		from = from[1:]
+	}
+	pattern.WriteString("$")
+	return regexp.MustCompile(pattern.String())
+}
+
+// truncate shortens s to n characters for error messages.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
+
+// dynamicRedirects returns the number of dynamic wildcard rules of groups.
+func dynamicRedirects(groups []*redirectGroup) int {
+	dynamic := 0
+	for _, group := range groups {
+		if group.wildcard.Dynamic() {
+			dynamic++
+		}
+	}
+	return dynamic
+}
+
+// repositoryFiles returns the paths of all package files referenced by the indices of a repository.
+func repositoryFiles(repository *debext.Repository) []string {
+	seen := make(map[string]bool)
+	var paths []string
+	add := func(path string) {
+		if !seen[path] {
+			seen[path] = true
+			paths = append(paths, path)
+		}
+	}
+
+	for _, dist := range repository.GetDistributions() {
+		for _, comp := range repository.GetComponents(dist) {
+			_ = repository.GetPackageList(dist, comp).ForEach(func(pkg *deb.Package) error {
+				stanza := pkg.Stanza()
+				if !pkg.IsSource {
+					add(stanza["Filename"])
+					return nil
+				}
+				directory := strings.TrimSuffix(stanza["Directory"], "/")
+				for _, file := range pkg.Files() {
+					add(directory + "/" + file.Filename)
+				}
+				return nil
+			})
+		}
+	}
+
+	slices.Sort(paths)
+	return paths
+}
@@ -0,0 +1,397 @@
+package provider
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/dionysius/aarg/internal/config"
+	"github.com/dionysius/aarg/internal/feed"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testGroup returns a group with a wildcard rule and n exact rules
+func testGroup(from string, n int) *redirectGroup {
+	group := &redirectGroup{wildcard: RedirectRule{From: from, To: "https://example.com/:splat", Status: 302}}
+	for i := range n {
+		group.exact = append(group.exact, RedirectRule{From: fmt.Sprintf("%s/file%d", strings.TrimSuffix(from, "/*"), i), To: "https://example.com/file", Status: 302})
+	}
+	return group
+}
+
+// ruleSources returns the sources of rules in order
+func ruleSources(rules []RedirectRule) []string {
+	sources := make([]string, 0, len(rules))
+	for _, rule := range rules {
+		sources = append(sources, rule.From)
+	}
+	return sources
+}
+
+func TestPlanRedirects(t *testing.T) {
+	large := testGroup("/a/pool/*", 3)
+	small := testGroup("/b/pool/*", 1)
+	medium := testGroup("/c/pool/*", 2)
+	static := &redirectGroup{wildcard: RedirectRule{From: "/d/pool/file", To: "https://example.com/file", Status: 302}}
+
+	tests := []struct {
+		name       string
+		groups     []*redirectGroup
+		limits     RedirectLimits
+		expandable bool
+		want       []string
+		wantErr    bool
+	}{
+		{
+			name:   "wildcards fit",
+			groups: []*redirectGroup{large, small, medium},
+			limits: RedirectLimits{Static: 10, Dynamic: 3},
+			want:   []string{"/a/pool/*", "/b/pool/*", "/c/pool/*"},
+		},
+		{
+			name:    "overflow without known files",
+			groups:  []*redirectGroup{large, small, medium},
+			limits:  RedirectLimits{Static: 10, Dynamic: 2},
+			wantErr: true,
+		},
+		{
+			name:       "smallest group expanded first",
+			groups:     []*redirectGroup{large, small, medium},
+			limits:     RedirectLimits{Static: 10, Dynamic: 2},
+			expandable: true,
+			want:       []string{"/b/pool/file0", "/a/pool/*", "/c/pool/*"},
+		},
+		{
+			name:       "several groups expanded, static rules first in group order",
+			groups:     []*redirectGroup{large, small, medium},
+			limits:     RedirectLimits{Static: 10, Dynamic: 1},
+			expandable: true,
+			want:       []string{"/b/pool/file0", "/c/pool/file0", "/c/pool/file1", "/a/pool/*"},
+		},
+		{
+			name:       "group too large for static budget is skipped",
+			groups:     []*redirectGroup{large, medium},
+			limits:     RedirectLimits{Static: 2, Dynamic: 1},
+			expandable: true,
+			want:       []string{"/c/pool/file0", "/c/pool/file1", "/a/pool/*"},
+		},
+		{
+			name:       "static budget exceeded",
+			groups:     []*redirectGroup{large, small, medium},
+			limits:     RedirectLimits{Static: 2, Dynamic: 1},
+			expandable: true,
+			wantErr:    true,
+		},
+		{
+			name:       "static wildcards do not count as dynamic",
+			groups:     []*redirectGroup{large, static},
+			limits:     RedirectLimits{Static: 10, Dynamic: 1},
+			expandable: true,
+			want:       []string{"/a/pool/*", "/d/pool/file"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, err := planRedirects(tt.groups, tt.limits, tt.expandable)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrRedirectLimit)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, ruleSources(rules))
+		})
+	}
+}
+
+func TestAssignRedirectFiles(t *testing.T) {
+	first := &redirectGroup{
+		wildcard: RedirectRule{From: "/a/pool/main/*", To: "https://example.com/:splat", Status: 302},
+		target: func(repo, poolPath string) (string, bool) {
+			rest, ok := strings.CutPrefix(poolPath, "main/")
+			return "https://example.com/" + rest, ok && repo == "a"
+		},
+	}
+	second := &redirectGroup{
+		wildcard: RedirectRule{From: "/a/pool/*", To: "https://other.example.com/:splat", Status: 301},
+		target: func(repo, poolPath string) (string, bool) {
+			return "https://other.example.com/" + poolPath, repo == "a"
+		},
+	}
+
+	assignRedirectFiles([]*redirectGroup{first, second}, map[string][]string{
+		"a": {"pool/main/h/hello.deb", "pool/contrib/w/world.deb", "dists/stable/Release"},
+		"b": {"pool/main/h/hello.deb"},
+	})
+
+	// Files go to the first covering group only, files outside pool/ and of other repositories are left out
+	assert.Equal(t, []RedirectRule{{From: "/a/pool/main/h/hello.deb", To: "https://example.com/h/hello.deb", Status: 302}}, first.exact)
+	assert.Equal(t, []RedirectRule{{From: "/a/pool/contrib/w/world.deb", To: "https://other.example.com/contrib/w/world.deb", Status: 301}}, second.exact)
+}
+
+func TestValidateRedirects(t *testing.T) {
+	limits := RedirectLimits{Static: 2, Dynamic: 1, LineLength: 60}
+
+	tests := []struct {
+		name  string
+		rules []RedirectRule
+		want  []string // Expected parts of the error, nil = valid
+	}{
+		{
+			name: "valid",
+			rules: []RedirectRule{
+				{From: "/a/pool/file", To: "https://example.com/file", Status: 302},
+				{From: "/a/pool/*", To: "https://example.com/:splat", Status: 302},
+			},
+		},
+		{
+			name: "line too long",
+			rules: []RedirectRule{
+				{From: "/a/pool/" + strings.Repeat("x", 60), To: "https://example.com/file", Status: 302},
+			},
+			want: []string{"line 1 is 97 characters long, 60 allowed"},
+		},
+		{
+			name: "too many static rules",
+			rules: []RedirectRule{
+				{From: "/a", To: "/x", Status: 302},
+				{From: "/b", To: "/x", Status: 302},
+				{From: "/c", To: "/x", Status: 302},
+			},
+			want: []string{"3 static rules, 2 allowed"},
+		},
+		{
+			name: "too many dynamic rules",
+			rules: []RedirectRule{
+				{From: "/a/*", To: "/x/:splat", Status: 302},
+				{From: "/b/:name", To: "/x/:name", Status: 302},
+			},
+			want: []string{"2 dynamic rules, 1 allowed"},
+		},
+		{
+			name: "syntax error with line number",
+			rules: []RedirectRule{
+				{From: "/a", To: "/x", Status: 302},
+				{From: "b", To: "/x", Status: 302},
+			},
+			want: []string{"line 2 source must start with /: b /x 302"},
+		},
+		{
+			name: "conflict",
+			rules: []RedirectRule{
+				{From: "/a", To: "/x", Status: 302},
+				{From: "/a", To: "/y", Status: 302},
+			},
+			want: []string{"line 2 conflicts with line 1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateRedirects(tt.rules, limits)
+			if tt.want == nil {
+				assert.NoError(t, err)
+				return
+			}
+			require.ErrorIs(t, err, ErrRedirectInvalid)
+			for _, want := range tt.want {
+				assert.Contains(t, err.Error(), want)
+			}
+		})
+	}
+}
+
+func TestValidateRedirects_MaxProblems(t *testing.T) {
+	var rules []RedirectRule
+	for i := range maxRedirectProblems + 3 {
+		rules = append(rules, RedirectRule{From: fmt.Sprintf("file%d", i), To: "/x", Status: 302})
+	}
+
+	err := validateRedirects(rules, RedirectLimits{Static: 100, Dynamic: 100, LineLength: 100})
+	require.ErrorIs(t, err, ErrRedirectInvalid)
+	assert.Contains(t, err.Error(), "and 3 more")
+	assert.NotContains(t, err.Error(), fmt.Sprintf("line %d ", maxRedirectProblems+1))
+}
+
+func TestCheckRedirectSyntax(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    RedirectRule
+		wantErr string
+	}{
+		{name: "static", rule: RedirectRule{From: "/a/file", To: "/b/file", Status: 301}},
+		{name: "splat", rule: RedirectRule{From: "/a/*", To: "https://example.com/:splat", Status: 302}},
+		{name: "placeholders", rule: RedirectRule{From: "/:repo/pool/:file", To: "http://example.com/:repo/:file", Status: 308}},
+		{name: "port in target is no placeholder", rule: RedirectRule{From: "/a", To: "https://example.com:8443/a", Status: 302}},
+		{name: "relative source", rule: RedirectRule{From: "a/*", To: "/b", Status: 302}, wantErr: "source must start with /"},
+		{name: "whitespace", rule: RedirectRule{From: "/a b", To: "/b", Status: 302}, wantErr: "contains whitespace"},
+		{name: "tab in target", rule: RedirectRule{From: "/a", To: "/b\t", Status: 302}, wantErr: "contains whitespace"},
+		{name: "two splats", rule: RedirectRule{From: "/*/pool/*", To: "/b/:splat", Status: 302}, wantErr: "more than one splat"},
+		{name: "unsupported status", rule: RedirectRule{From: "/a", To: "/b", Status: 200}, wantErr: "unsupported status 200"},
+		{name: "relative target", rule: RedirectRule{From: "/a", To: "b", Status: 302}, wantErr: "target must be a path or URL"},
+		{name: "ftp target", rule: RedirectRule{From: "/a", To: "ftp://example.com/a", Status: 302}, wantErr: "target must be a path or URL"},
+		{name: "placeholder twice", rule: RedirectRule{From: "/:a/:a", To: "/:a", Status: 302}, wantErr: "source defines :a twice"},
+		{name: "uncaptured placeholder", rule: RedirectRule{From: "/:a", To: "/:b", Status: 302}, wantErr: "target uses :b not captured"},
+		{name: "splat without splat source", rule: RedirectRule{From: "/a", To: "/:splat", Status: 302}, wantErr: "target uses :splat not captured"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkRedirectSyntax(tt.rule)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestRedirectConflicts(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules []RedirectRule
+		want  []string
+	}{
+		{
+			name: "none",
+			rules: []RedirectRule{
+				{From: "/a/pool/file", To: "/x"},
+				{From: "/a/pool/*", To: "/y/:splat"},
+				{From: "/b/pool/*", To: "/z/:splat"},
+			},
+		},
+		{
+			name: "duplicate",
+			rules: []RedirectRule{
+				{From: "/a", To: "/x"},
+				{From: "/a", To: "/x"},
+			},
+			want: []string{"line 2 duplicates line 1: /a"},
+		},
+		{
+			name: "same source different targets",
+			rules: []RedirectRule{
+				{From: "/a", To: "/x"},
+				{From: "/a", To: "/y"},
+			},
+			want: []string{"line 2 conflicts with line 1, /a redirects to both /x and /y"},
+		},
+		{
+			name: "static shadowed by wildcard",
+			rules: []RedirectRule{
+				{From: "/a/*", To: "/x/:splat"},
+				{From: "/a/pool/file", To: "/y"},
+			},
+			want: []string{"line 2 is shadowed by line 1, /a/* already matches the paths of /a/pool/file"},
+		},
+		{
+			name: "wildcard shadowed by placeholder",
+			rules: []RedirectRule{
+				{From: "/:repo/pool/*", To: "/x/:repo/:splat"},
+				{From: "/a/pool/main/*", To: "/y/:splat"},
+			},
+			want: []string{"line 2 is shadowed by line 1, /:repo/pool/* already matches the paths of /a/pool/main/*"},
+		},
+		{
+			name: "static rules do not shadow",
+			rules: []RedirectRule{
+				{From: "/a/x", To: "/x"},
+				{From: "/a/*", To: "/y/:splat"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, redirectConflicts(tt.rules))
+		})
+	}
+}
+
+func TestRedirectPattern(t *testing.T) {
+	tests := []struct {
+		name  string
+		from  string
+		path  string
+		match bool
+	}{
+		{name: "static", from: "/a/pool/file.deb", path: "/a/pool/file.deb", match: true},
+		{name: "static other", from: "/a/pool/file.deb", path: "/a/pool/file.debx", match: false},
+		{name: "dot is literal", from: "/a/pool/file.deb", path: "/a/pool/fileXdeb", match: false},
+		{name: "splat spans segments", from: "/a/pool/*", path: "/a/pool/main/h/hello.deb", match: true},
+		{name: "splat matches empty", from: "/a/pool/*", path: "/a/pool/", match: true},
+		{name: "placeholder single segment", from: "/:repo/pool", path: "/a/pool", match: true},
+		{name: "placeholder not across segments", from: "/:repo/pool", path: "/a/b/pool", match: false},
+		{name: "colon without letter is literal", from: "/a/:1", path: "/a/:1", match: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.match, redirectPattern(tt.from).MatchString(tt.path))
+		})
+	}
+}
+
+func TestRedirectRule_Resolve(t *testing.T) {
+	tests := []struct {
+		name   string
+		rule   RedirectRule
+		path   string
+		want   string
+		wantOK bool
+	}{
+		{
+			name:   "splat",
+			rule:   RedirectRule{From: "/a/pool/*", To: "https://example.com/download/:splat"},
+			path:   "/a/pool/main/h/hello.deb",
+			want:   "https://example.com/download/main/h/hello.deb",
+			wantOK: true,
+		},
+		{
+			name:   "placeholders",
+			rule:   RedirectRule{From: "/:repo/pool/:file", To: "https://example.com/:repo/releases/:file"},
+			path:   "/a/pool/hello.deb",
+			want:   "https://example.com/a/releases/hello.deb",
+			wantOK: true,
+		},
+		{
+			name: "no match",
+			rule: RedirectRule{From: "/a/pool/*", To: "https://example.com/:splat"},
+			path: "/b/pool/hello.deb",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.rule.Resolve(tt.path)
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRedirectRules(t *testing.T) {
+	rules := RedirectRules([]*config.RepositoryConfig{{
+		Name:  "apt",
+		Feeds: []*feed.FeedOptions{{Type: feed.FeedTypeGitHub, Name: "owner/project"}},
+	}})
+	require.NotEmpty(t, rules)
+
+	// Rules come with their compiled source
+	for _, rule := range rules {
+		require.NotNil(t, rule.pattern, rule.From)
+		assert.Equal(t, redirectPattern(rule.From).String(), rule.pattern.String())
+	}
+
+	var target string
+	for _, rule := range rules {
+		if resolved, ok := rule.Resolve("/apt/pool/github.com/owner/project/v1.0/hello_1.0_amd64.deb"); ok {
+			target = resolved
+			break
+		}
+	}
+	assert.Equal(t, "https://github.com/owner/project/releases/download/v1.0/hello_1.0_amd64.deb", target)
+}
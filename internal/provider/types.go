@@ -7,4 +7,16 @@ type Provider interface {
 	// Publish uploads the repository contents to the provider
 	// outputDir is the path to the directory containing the files to publish
 	Publish(ctx context.Context, outputDir string) error
+
+	// GetURL returns the base URL where published content is served
+	GetURL() string
+
+	// Name returns the name of the provider as used in the publish configuration and publish --only
+	Name() string
+}
+
+// DomainManager is implemented by providers able to manage the custom domain content is served under
+type DomainManager interface {
+	// EnsureDomain adds the custom domain to the publish target if missing and returns its status
+	EnsureDomain(ctx context.Context, domain string) (*DomainStatus, error)
 }
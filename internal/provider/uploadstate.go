@@ -0,0 +1,92 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/dionysius/aarg/internal/common"
+)
+
+// uploadStateMaxAge is how long uploaded assets of a failed publish are trusted to still be stored by the provider
+const uploadStateMaxAge = 24 * time.Hour
+
+// uploadState is the upload progress of a publish, persisted so a retried publish resumes where it left off.
+// Thread-safe, batches are recorded concurrently.
+type uploadState struct {
+	store    common.MetadataStore
+	key      string
+	Updated  time.Time       `json:"updated"`  // When the last asset was recorded
+	Uploaded map[string]bool `json:"uploaded"` // Hashes of the uploaded assets
+	mu       sync.Mutex
+}
+
+// loadUploadState reads the upload state of key from store, state older than uploadStateMaxAge is discarded.
+// A nil store disables persistence.
+func loadUploadState(store common.MetadataStore, key string) *uploadState {
+	state := &uploadState{store: store, key: key, Uploaded: make(map[string]bool)}
+	if store == nil {
+		return state
+	}
+
+	data, ok, err := store.Load(common.UploadsBucket, key)
+	if err != nil {
+		slog.Warn("Failed to load upload state, uploading all files", "key", key, "error", err)
+		return state
+	}
+	if !ok {
+		return state
+	}
+	var saved uploadState
+	if err := json.Unmarshal(data, &saved); err != nil {
+		slog.Debug("Ignoring unreadable upload state", "key", key, "error", err)
+		return state
+	}
+	if time.Since(saved.Updated) > uploadStateMaxAge || saved.Uploaded == nil {
+		return state
+	}
+
+	state.Updated, state.Uploaded = saved.Updated, saved.Uploaded
+	return state
+}
+
+// pending returns the hashes not uploaded by a previous attempt
+func (s *uploadState) pending(hashes []string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.DeleteFunc(slices.Clone(hashes), func(hash string) bool { return s.Uploaded[hash] })
+}
+
+// record marks hashes as uploaded and persists the state
+func (s *uploadState) record(hashes []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, hash := range hashes {
+		s.Uploaded[hash] = true
+	}
+	s.Updated = time.Now()
+	if s.store == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return s.store.Store(common.UploadsBucket, s.key, data)
+}
+
+// clear removes the persisted state after a completed publish
+func (s *uploadState) clear() error {
+	if s.store == nil {
+		return nil
+	}
+	if err := s.store.Delete(common.UploadsBucket, s.key); err != nil {
+		return fmt.Errorf("failed to remove upload state: %w", err)
+	}
+	return nil
+}
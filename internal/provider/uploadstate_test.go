@@ -0,0 +1,178 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dionysius/aarg/internal/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUploadState_pending(t *testing.T) {
+	store := common.NewFileMetadataStore(t.TempDir())
+	state := loadUploadState(store, "key")
+	assert.Equal(t, []string{"a", "b", "c"}, state.pending([]string{"a", "b", "c"}))
+
+	require.NoError(t, state.record([]string{"a", "c"}))
+	assert.Equal(t, []string{"b"}, state.pending([]string{"a", "b", "c"}))
+
+	// A retried publish resumes with the recorded hashes
+	assert.Equal(t, []string{"b", "d"}, loadUploadState(store, "key").pending([]string{"a", "b", "c", "d"}))
+	assert.Equal(t, []string{"a", "b"}, loadUploadState(store, "other").pending([]string{"a", "b"}))
+}
+
+func TestLoadUploadState(t *testing.T) {
+	// saved returns a persisted state of asset a, recorded age ago
+	saved := func(age time.Duration) []byte {
+		data, err := json.Marshal(&uploadState{Updated: time.Now().Add(-age), Uploaded: map[string]bool{"a": true}})
+		require.NoError(t, err)
+		return data
+	}
+
+	tests := []struct {
+		name  string
+		saved []byte // Persisted state, nil = none
+		want  []string
+	}{
+		{name: "none", want: []string{"a", "b"}},
+		{name: "recent", saved: saved(time.Hour), want: []string{"b"}},
+		{name: "expired", saved: saved(uploadStateMaxAge + time.Hour), want: []string{"a", "b"}},
+		{name: "unreadable", saved: []byte(`{"updated":`), want: []string{"a", "b"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := common.NewFileMetadataStore(t.TempDir())
+			if tt.saved != nil {
+				require.NoError(t, store.Store(common.UploadsBucket, "key", tt.saved))
+			}
+
+			assert.Equal(t, tt.want, loadUploadState(store, "key").pending([]string{"a", "b"}))
+		})
+	}
+
+	// Without store nothing is persisted
+	state := loadUploadState(nil, "key")
+	require.NoError(t, state.record([]string{"a"}))
+	assert.Equal(t, []string{"b"}, state.pending([]string{"a", "b"}))
+	assert.NoError(t, state.clear())
+}
+
+// pagesAPI fakes the Cloudflare Pages API of a project
+// Assets are reported missing until a deployment succeeded, like uploaded assets of a failed publish
+type pagesAPI struct {
+	mu       sync.Mutex
+	fail     bool            // Whether deployments fail
+	uploaded map[string]int  // Upload count per asset hash
+	deployed map[string]bool // Hashes referenced by successful deployments
+}
+
+func (api *pagesAPI) RoundTrip(req *http.Request) (*http.Response, error) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+	}
+
+	var result any
+	switch path := req.URL.Path; {
+	case strings.HasSuffix(path, "/upload-token"):
+		result = map[string]string{"jwt": "jwt"}
+	case strings.HasSuffix(path, "/assets/check-missing"):
+		var payload struct {
+			Hashes []string `json:"hashes"`
+		}
+		if err := json.Unmarshal(body, &payload); err != nil {
+			return nil, err
+		}
+		missing := []string{}
+		for _, hash := range payload.Hashes {
+			if !api.deployed[hash] {
+				missing = append(missing, hash)
+			}
+		}
+		result = missing
+	case strings.HasSuffix(path, "/assets/upload"):
+		var files []uploadFile
+		if err := json.Unmarshal(body, &files); err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			api.uploaded[file.Key]++
+		}
+	case strings.HasSuffix(path, "/deployments") && req.Method == http.MethodPost:
+		result = map[string]string{"id": "deployment", "url": "https://deployment.project.pages.dev"}
+	case strings.HasSuffix(path, "/deployments/deployment"):
+		status := "success"
+		if api.fail {
+			status = "failure"
+		} else {
+			for hash := range api.uploaded {
+				api.deployed[hash] = true
+			}
+		}
+		result = map[string]any{"latest_stage": map[string]string{"name": "deploy", "status": status}}
+	default:
+		return nil, http.ErrNotSupported
+	}
+
+	data, err := json.Marshal(map[string]any{"success": true, "result": result})
+	if err != nil {
+		return nil, err
+	}
+	recorder := httptest.NewRecorder()
+	_, _ = recorder.Write(data)
+	return recorder.Result(), nil
+}
+
+func TestPagesProvider_Publish_UploadState(t *testing.T) {
+	interval := deploymentPollInterval
+	deploymentPollInterval = time.Millisecond
+	t.Cleanup(func() { deploymentPollInterval = interval })
+
+	api := &pagesAPI{fail: true, uploaded: make(map[string]int), deployed: make(map[string]bool)}
+	store := common.NewFileMetadataStore(t.TempDir())
+	newProvider := func() *PagesProvider {
+		p, err := NewCloudflare("token", "account", "project", CloudflareTarget{Branch: "main", Production: true}, CloudflareCleanupConfig{}, CloudflareLimits{}, nil, "hierarchical")
+		require.NoError(t, err)
+		p.httpClient.Transport = api
+		p.SetStateStore(store)
+		return p
+	}
+	build := writeBuild(t, t.TempDir(), map[string]string{
+		"index.html":             "index",
+		"dists/stable/Release":   "release",
+		"dists/stable/InRelease": "inrelease",
+	})
+
+	// The failed deployment keeps the state of the uploaded assets
+	err := newProvider().Publish(context.Background(), build)
+	require.ErrorContains(t, err, "deployment failed")
+	assert.Len(t, api.uploaded, 3)
+	_, ok, err := store.Load(common.UploadsBucket, newProvider().stateKey())
+	require.NoError(t, err)
+	assert.True(t, ok, "state kept after failed deployment")
+
+	// The retry uploads nothing again and clears the state once deployed
+	api.fail = false
+	require.NoError(t, newProvider().Publish(context.Background(), build))
+	for hash, count := range api.uploaded {
+		assert.Equal(t, 1, count, hash)
+	}
+	_, ok, err = store.Load(common.UploadsBucket, newProvider().stateKey())
+	require.NoError(t, err)
+	assert.False(t, ok, "state cleared after successful deployment")
+}
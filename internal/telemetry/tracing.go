@@ -0,0 +1,97 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName identifies the tracer of aarg
+const instrumentationName = "github.com/dionysius/aarg"
+
+// Span attribute keys shared across the pipeline
+const (
+	RepositoryKey   = attribute.Key("aarg.repository")
+	FeedTypeKey     = attribute.Key("aarg.feed.type")
+	FeedNameKey     = attribute.Key("aarg.feed.name")
+	DistributionKey = attribute.Key("aarg.distribution")
+	ReleaseKey      = attribute.Key("aarg.release")
+	ComposerKey     = attribute.Key("aarg.composer")
+	ProviderKey     = attribute.Key("aarg.provider")
+	EnvironmentKey  = attribute.Key("aarg.environment")
+)
+
+// TracingOptions configures the OTLP trace export
+type TracingOptions struct {
+	Endpoint    string            // OTLP/HTTP endpoint, "host:port" or full URL, empty = OTEL_EXPORTER_OTLP_* environment
+	Insecure    bool              // Use plain HTTP for "host:port" endpoints
+	Headers     map[string]string // Additional headers sent with each export (e.g., authentication)
+	ServiceName string            // Reported service name
+	SampleRatio float64           // Fraction of traces to sample (0-1)
+}
+
+// SetupTracing installs a global tracer provider exporting via OTLP
+// The returned function flushes pending spans and must be called before exiting
+// Without setup all spans are no-ops
+func SetupTracing(ctx context.Context, opts TracingOptions) (func(context.Context) error, error) {
+	var exporterOpts []otlptracehttp.Option
+	if strings.Contains(opts.Endpoint, "://") {
+		exporterOpts = append(exporterOpts, otlptracehttp.WithEndpointURL(opts.Endpoint))
+	} else if opts.Endpoint != "" {
+		exporterOpts = append(exporterOpts, otlptracehttp.WithEndpoint(opts.Endpoint))
+		if opts.Insecure {
+			exporterOpts = append(exporterOpts, otlptracehttp.WithInsecure())
+		}
+	}
+	if len(opts.Headers) > 0 {
+		exporterOpts = append(exporterOpts, otlptracehttp.WithHeaders(opts.Headers))
+	}
+
+	exporter, err := otlptracehttp.New(ctx, exporterOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP trace exporter: %w", err)
+	}
+
+	res, err := resource.New(ctx,
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+		resource.WithAttributes(semconv.ServiceName(opts.ServiceName)),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(opts.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	return provider.Shutdown, nil
+}
+
+// Start starts a span as child of the span in ctx
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End records err on the span (if any) and ends it
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -6,6 +6,7 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	_ "time/tzdata" // Time zone database for the configured timezone on systems without one
 
 	"github.com/dionysius/aarg/internal/cmd"
 )